@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/config"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+)
+
+func newSeedCmd() *cobra.Command {
+	var users, items int
+	var balance float64
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Insert sample users and items (for development and staging)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			repo := newShopRepository(cfg, pool)
+			return repo.RunAtomic(ctx, func(ctx context.Context) error {
+				for i := 1; i <= users; i++ {
+					u := &model.User{FirstName: "Seed", LastName: fmt.Sprintf("User %d", i), Balance: model.NewMoney(balance, model.DefaultCurrency)}
+					if err := repo.CreateUser(ctx, u); err != nil {
+						return err
+					}
+				}
+				for i := 1; i <= items; i++ {
+					item := &model.Item{Name: fmt.Sprintf("Seed Item %d", i), Price: model.Money{Amount: int64(i) * 500}, Stock: 100}
+					if err := repo.CreateItem(ctx, item); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "created %d users and %d items\n", users, items)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&users, "users", 10, "number of users to create")
+	cmd.Flags().IntVar(&items, "items", 20, "number of items to create")
+	cmd.Flags().Float64Var(&balance, "balance", 1000, "opening balance of each user")
+	return cmd
+}
+
+func newUsersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage users",
+	}
+
+	var firstName, lastName string
+	var balance float64
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			svc := newShopService(cfg, pool)
+			user, err := svc.CreateUser(ctx, firstName, lastName, balance)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd, user)
+		},
+	}
+	create.Flags().StringVar(&firstName, "first-name", "", "first name (required)")
+	create.Flags().StringVar(&lastName, "last-name", "", "last name (required)")
+	create.Flags().Float64Var(&balance, "balance", 0, "opening balance")
+	create.MarkFlagRequired("first-name")
+	create.MarkFlagRequired("last-name")
+
+	setEmail := &cobra.Command{
+		Use:     "set-email <user_id> <email>",
+		Short:   "Set the address notifications are sent to (empty removes it)",
+		Example: `  admin users set-email 42 ada@example.com`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid user id %q", args[0])
+			}
+
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			svc := newShopService(cfg, pool)
+			if err := svc.SetUserEmail(ctx, userID, args[1]); err != nil {
+				return err
+			}
+			user, err := svc.GetUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd, user)
+		},
+	}
+
+	setRegion := &cobra.Command{
+		Use:     "set-region <user_id> <region>",
+		Short:   "Set the tax region of a user (empty removes it)",
+		Example: `  admin users set-region 42 US-CA`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid user id %q", args[0])
+			}
+
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			svc := newShopService(cfg, pool)
+			if err := svc.SetUserRegion(ctx, userID, args[1]); err != nil {
+				return err
+			}
+			user, err := svc.GetUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd, user)
+		},
+	}
+
+	cmd.AddCommand(create, setEmail, setRegion)
+	return cmd
+}
+
+func newBalanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "balance",
+		Short: "Manage user balances",
+	}
+
+	var code, reason string
+	adjust := &cobra.Command{
+		Use:   "adjust <user_id> <delta>",
+		Short: "Credit (positive delta) or debit a user's balance",
+		Example: `  admin balance adjust 42 25.00 --code goodwill --reason "late delivery"
+  admin balance adjust --code chargeback --reason "disputed card payment" -- 42 -10`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid user id %q", args[0])
+			}
+			var delta model.Money
+			if err := delta.UnmarshalJSON([]byte(args[1])); err != nil {
+				return fmt.Errorf("invalid delta %q: %w", args[1], err)
+			}
+
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			adj, err := newShopService(cfg, pool).AdjustBalance(ctx, model.BalanceAdjustment{
+				UserID: userID, Delta: delta, ReasonCode: code, Reason: reason, Source: "cli",
+			})
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd, adj)
+		},
+	}
+	adjust.Flags().StringVar(&code, "code", "", "reason code: "+strings.Join(model.AdjustmentReasonCodes, ", ")+" (required)")
+	adjust.Flags().StringVar(&reason, "reason", "", "reason recorded with the adjustment (required)")
+	adjust.MarkFlagRequired("code")
+	adjust.MarkFlagRequired("reason")
+
+	cmd.AddCommand(adjust)
+	return cmd
+}
+
+func newShopService(cfg *config.Config, pool *pgxpool.Pool) *service.ShopService {
+	auditService := service.NewAuditService(repository.NewAuditRepository(pool))
+	shopRepo := newShopRepository(cfg, pool)
+	return service.NewShopService(shopRepo, shopRepo, service.WithAuditLog(auditService))
+}
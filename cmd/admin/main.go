@@ -0,0 +1,116 @@
+// Command admin is the operator CLI: it talks to the database directly for data
+// and schema tasks, and to the admin HTTP API for state held by running servers.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/buildinfo"
+	"fsanano/go-test/internal/config"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+)
+
+type options struct {
+	addr    string
+	token   string
+	timeout time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:          "admin",
+		Short:        "Operator tool for the shop service",
+		Version:      buildinfo.Get().Version,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			// Attribute audited changes to the operator running the tool
+			ctx = audit.WithActor(ctx, "admin:cli:"+operatorName())
+			cmd.SetContext(ctx)
+			cobra.OnFinalize(cancel)
+			return nil
+		},
+	}
+
+	defaultAddr := os.Getenv("ADMIN_API_ADDR")
+	if defaultAddr == "" {
+		defaultAddr = "http://localhost:8080"
+	}
+	root.PersistentFlags().StringVar(&opts.addr, "addr", defaultAddr, "API base URL for commands acting on running servers ($ADMIN_API_ADDR)")
+	root.PersistentFlags().StringVar(&opts.token, "token", os.Getenv("ADMIN_TOKEN"), "admin API bearer token ($ADMIN_TOKEN)")
+	root.PersistentFlags().DurationVar(&opts.timeout, "timeout", 5*time.Minute, "overall command timeout")
+
+	root.AddCommand(
+		newMigrateCmd(),
+		newSeedCmd(),
+		newUsersCmd(),
+		newBalanceCmd(),
+		newSkinportCmd(opts),
+		newEncryptionCmd(),
+		newConfigCmd(),
+	)
+	return root
+}
+
+// openDB connects to the database configured for the server (DATABASE_URL / .env)
+func openDB(ctx context.Context) (*config.Config, *pgxpool.Pool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	poolConfig.AfterConnect = model.RegisterPgTypes
+	if err := cfg.Database.Pool.Apply(poolConfig); err != nil {
+		return nil, nil, fmt.Errorf("failed to configure database pool: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return cfg, pool, nil
+}
+
+func newShopRepository(cfg *config.Config, pool *pgxpool.Pool) *repository.ShopRepository {
+	return repository.NewShopRepository(pool,
+		repository.WithStatementTimeout(cfg.Database.StatementTimeout),
+		repository.WithRetry(cfg.Database.TxMaxAttempts, 20*time.Millisecond),
+	)
+}
+
+func operatorName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
+
+func printJSON(cmd *cobra.Command, v any) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"text/tabwriter"
+
+	"fsanano/go-test/internal/config"
+	"fsanano/go-test/migrations"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply or inspect database migrations (embedded in the binary)",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return withProvider(func(p *goose.Provider) error {
+					results, err := p.Up(cmd.Context())
+					for _, r := range results {
+						fmt.Fprintln(cmd.OutOrStdout(), r)
+					}
+					if err == nil && len(results) == 0 {
+						fmt.Fprintln(cmd.OutOrStdout(), "no pending migrations")
+					}
+					return err
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "down",
+			Short: "Roll back the most recent migration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return withProvider(func(p *goose.Provider) error {
+					result, err := p.Down(cmd.Context())
+					if result != nil {
+						fmt.Fprintln(cmd.OutOrStdout(), result)
+					}
+					return err
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show which migrations are applied",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return withProvider(func(p *goose.Provider) error {
+					statuses, err := p.Status(cmd.Context())
+					if err != nil {
+						return err
+					}
+					w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+					for _, s := range statuses {
+						appliedAt := "-"
+						if !s.AppliedAt.IsZero() {
+							appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+						}
+						fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+					}
+					return w.Flush()
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+func withProvider(fn func(p *goose.Provider) error) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("pgx", cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return fn(provider)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"text/tabwriter"
+	"time"
+
+	"fsanano/go-test/internal/config"
+	"fsanano/go-test/internal/crypto"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/pkg/sdk"
+
+	"github.com/spf13/cobra"
+)
+
+func newSkinportCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "skinport",
+		Short: "Manage the Skinport cache of running servers",
+	}
+
+	var appID, currency string
+	invalidate := &cobra.Command{
+		Use:   "invalidate",
+		Short: "Drop cached Skinport items so the next request refetches them",
+		Long: `Calls DELETE /v1/admin/skinport/cache on --addr. The cache lives in each
+server's memory, so behind a load balancer run it against every instance.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.token == "" {
+				return fmt.Errorf("an admin token is required (--token or $ADMIN_TOKEN)")
+			}
+			client := sdk.NewClient(opts.addr).WithToken(opts.token)
+			if err := client.InvalidateSkinportCache(cmd.Context(), appID, currency); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "skinport cache invalidated on %s\n", opts.addr)
+			return nil
+		},
+	}
+	invalidate.Flags().StringVar(&appID, "app-id", "", "Skinport app id (default 730)")
+	invalidate.Flags().StringVar(&currency, "currency", "", "currency (default EUR)")
+
+	cmd.AddCommand(invalidate)
+	return cmd
+}
+
+func newEncryptionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "Manage the keys encrypting secrets stored in the database",
+	}
+
+	var id string
+	generate := &cobra.Command{
+		Use:   "generate-key",
+		Short: "Print a new key to put in front of ENCRYPTION_KEYS",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				return err
+			}
+			if id == "" {
+				id = time.Now().UTC().Format("20060102")
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", id, key)
+			return nil
+		},
+	}
+	generate.Flags().StringVar(&id, "id", "", "key id (default today's date)")
+
+	rotate := &cobra.Command{
+		Use:   "rotate",
+		Short: "Re-encrypt stored secrets with the primary key",
+		Long: `Re-encrypts the stored secrets sealed with an older key of ENCRYPTION_KEYS, or
+with the former SECRETS_KEY, with its first, primary, key. Deploy the new
+ENCRYPTION_KEYS to every server first; the older keys and SECRETS_KEY can be
+removed once this has run.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			cfg, pool, err := openDB(ctx)
+			if err != nil {
+				return err
+			}
+			defer pool.Close()
+
+			tenants := service.NewTenantService(repository.NewTenantRepository(pool), nil, cfg.EncryptionKeys, nil)
+			rotated, err := tenants.RotateSkinportCredentials(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "re-encrypted the skinport credentials of %d tenants with key %s\n", rotated, cfg.EncryptionKeys.Primary())
+			return nil
+		},
+	}
+
+	cmd.AddCommand(generate, rotate)
+	return cmd
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the server configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "dump",
+		Short: "Print the effective configuration (environment and .env) with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			redactedCfg := redactConfig(*cfg)
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			printFields(w, "", reflect.ValueOf(redactedCfg))
+			return w.Flush()
+		},
+	})
+	return cmd
+}
+
+// redactConfig hides credentials so dumps can be pasted into tickets
+func redactConfig(cfg config.Config) config.Config {
+	if u, err := url.Parse(cfg.DatabaseURL); err == nil {
+		cfg.DatabaseURL = u.Redacted()
+	} else {
+		cfg.DatabaseURL = redacted
+	}
+	if cfg.Admin.Token != "" {
+		cfg.Admin.Token = redacted
+	}
+	if cfg.Skinport.APIKey != "" {
+		cfg.Skinport.APIKey = redacted
+	}
+	if cfg.ErrorReporting.SentryDSN != "" {
+		cfg.ErrorReporting.SentryDSN = redacted
+	}
+	if cfg.Alerting.SlackWebhookURL != "" {
+		cfg.Alerting.SlackWebhookURL = redacted
+	}
+	if cfg.Alerting.DiscordWebhookURL != "" {
+		cfg.Alerting.DiscordWebhookURL = redacted
+	}
+	if cfg.Secrets.Values != nil {
+		values := make(map[string]string, len(cfg.Secrets.Values))
+		for key := range cfg.Secrets.Values {
+			values[key] = redacted
+		}
+		cfg.Secrets.Values = values
+	}
+	return cfg
+}
+
+const redacted = "xxxxx"
+
+// printFields writes one "Section.Field  value" line per leaf field; fmt renders
+// durations as "5s", which JSON would encode as nanoseconds
+func printFields(w io.Writer, prefix string, v reflect.Value) {
+	for i := range v.NumField() {
+		name := prefix + v.Type().Field(i).Name
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			printFields(w, name+".", field)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%v\n", name, field.Interface())
+	}
+}
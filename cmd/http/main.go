@@ -2,19 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"fsanano/go-test/internal/alerting"
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/buildinfo"
 	"fsanano/go-test/internal/config"
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/fx"
+	"fsanano/go-test/internal/graph"
 	"fsanano/go-test/internal/handler"
+	"fsanano/go-test/internal/jobs"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/notifications"
+	"fsanano/go-test/internal/payments"
+	"fsanano/go-test/internal/payouts"
 	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/secretstore"
 	"fsanano/go-test/internal/service"
 	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/steam"
+	"fsanano/go-test/internal/storage"
+	"fsanano/go-test/internal/supplier"
+	"fsanano/go-test/internal/tax"
+	"fsanano/go-test/internal/telegram"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -26,33 +47,523 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	logOpts := &slog.HandlerOptions{Level: cfg.Logging.Level}
+	var logHandler slog.Handler = slog.NewTextHandler(os.Stdout, logOpts)
+	if cfg.Logging.Format == "json" {
+		logHandler = slog.NewJSONHandler(os.Stdout, logOpts)
+	}
+	// Lines logged with a request's context carry its request_id
+	slog.SetDefault(slog.New(audit.NewLogHandler(logHandler)))
+	slog.Info("Starting shop API", buildinfo.Get().LogAttrs()...)
+
 	// 2. Setup Database
 	ctx := context.Background()
-	dbPool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to parse database URL: %v", err)
+	}
+	poolConfig.AfterConnect = model.RegisterPgTypes
+	// Secrets refreshed from a secret manager rotate the credentials of new connections
+	dbCredentials := &repository.DatabaseCredentials{}
+	poolConfig.BeforeConnect = dbCredentials.BeforeConnect
+	if err := cfg.Database.Pool.Apply(poolConfig); err != nil {
+		log.Fatalf("Failed to configure database pool: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = repository.NewQueryTracer(slog.Default(), cfg.Database.SlowQueryThreshold, cfg.Database.LogQueries)
+	if cfg.Database.QueryTimeout > 0 {
+		// Transactions tighten this with SET LOCAL (DB_STATEMENT_TIMEOUT)
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.Database.QueryTimeout.Milliseconds(), 10)
+	}
+
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer dbPool.Close()
 
-	if err := dbPool.Ping(ctx); err != nil {
+	// Postgres may still be starting next to us, e.g. in docker-compose
+	if err := repository.WaitForDatabase(ctx, dbPool, cfg.Database.ConnectAttempts, cfg.Database.ConnectBackoff); err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 	fmt.Println("Connected to database")
 
 	// 3. Setup Logic
+	// Blob storage keeps item images, import reports and catalog snapshots
+	blobs, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+	// The local backend serves its signed URLs itself
+	var storageHandler http.Handler
+	if local, ok := blobs.(*storage.Local); ok {
+		storageHandler = local
+	}
+
+	// Domain events for in-process subscribers, forwarded to EVENTBUS_BROKER when set
+	bus := eventbus.New(cfg.EventBus.QueueSize)
+	broker, err := eventbus.NewBroker(cfg.EventBus)
+	if err != nil {
+		log.Fatalf("Failed to configure the event broker: %v", err)
+	}
+	if broker != nil {
+		eventbus.Forward(bus, broker)
+	}
+
+	// Panics and unexpected errors to SENTRY_DSN, nil without
+	sentry, err := errreport.New(cfg.ErrorReporting)
+	if err != nil {
+		log.Fatalf("Failed to configure error reporting: %v", err)
+	}
+	var errorReporter errreport.Reporter
+	if sentry != nil {
+		errorReporter = sentry
+		eventbus.Subscribe(bus, eventbus.UpstreamFailed, func(ctx context.Context, e eventbus.UpstreamFailedEvent) {
+			errreport.Capture(errreport.WithReporter(ctx, errorReporter), errreport.KindUpstream, errors.New(e.Error),
+				map[string]string{"source": e.Source, "app_id": e.AppID, "currency": e.Currency, "view": e.View})
+		})
+	}
+
+	// Operational alerts to ALERT_SLACK_WEBHOOK_URL / ALERT_DISCORD_WEBHOOK_URL, nil without
+	alerter := alerting.New(cfg.Alerting)
+	alerting.WatchSkinport(bus, alerter, cfg.Alerting.SkinportFailures)
+	purchaseErrors := alerter.NewErrorRate(alerting.KindPurchaseErrors, "Purchase",
+		cfg.Alerting.PurchaseErrorRate, cfg.Alerting.PurchaseWindow, cfg.Alerting.PurchaseMinRequests)
+
 	// Logic - Shop
-	shopRepo := repository.NewShopRepository(dbPool)
-	shopService := service.NewShopService(shopRepo)
+	shopRepo := repository.NewShopRepository(dbPool,
+		repository.WithStatementTimeout(cfg.Database.StatementTimeout),
+		repository.WithRetry(cfg.Database.TxMaxAttempts, 20*time.Millisecond),
+		repository.WithReadCache(cfg.Database.ReadCacheSize, cfg.Database.ReadCacheTTL),
+	)
+	auditService := service.NewAuditService(repository.NewAuditRepository(dbPool))
+	promoService := service.NewPromoService(repository.NewPromoRepository(dbPool), shopRepo, auditService)
+	dropService := service.NewDropService(repository.NewDropRepository(dbPool), shopRepo, shopRepo, auditService)
+	orderEvents := service.NewOrderEventService(repository.NewOrderEventRepository(dbPool))
+	taxCalculator, err := tax.NewCalculator(cfg.Purchase.Tax)
+	if err != nil {
+		log.Fatalf("Failed to configure taxes: %v", err)
+	}
+	receiptService := service.NewReceiptService(shopRepo, blobs, cfg.Receipts.CacheSize, cfg.Receipts.CacheTTL)
+	var waitlistRepo *repository.WaitlistRepository
+	// waitlistEntries stays a nil interface, which disables the waitlist, unless enabled
+	var waitlistEntries service.WaitlistEntryStore
+	if cfg.Purchase.WaitlistEnabled {
+		waitlistRepo = repository.NewWaitlistRepository(dbPool)
+		waitlistEntries = waitlistRepo
+	}
+	shopService := service.NewShopService(shopRepo, shopRepo,
+		service.WithAuditLog(auditService),
+		service.WithPromoCodes(promoService),
+		service.WithDrops(dropService),
+		service.WithOrderEvents(orderEvents),
+		service.WithEventBus(bus),
+		service.WithPurchaseLimits(service.PurchaseLimits{
+			MaxOrdersPerMinute: cfg.Purchase.MaxOrdersPerMinute,
+			MaxSpendPerDay:     model.NewMoney(cfg.Purchase.MaxSpendPerDay, model.DefaultCurrency),
+			MaxQuantityPerItem: cfg.Purchase.MaxQuantityPerItem,
+		}),
+		service.WithTaxCalculator(taxCalculator),
+		service.WithSingleStatementPurchase(cfg.Purchase.SingleStatement),
+		service.WithPurchaseQueue(service.PurchaseQueueOptions{
+			MaxWaiting: cfg.Purchase.QueueMaxWaiting,
+			MaxWait:    cfg.Purchase.QueueMaxWait,
+		}),
+		service.WithQuotes(service.QuoteOptions{SigningKey: cfg.Purchase.QuoteSigningKey, TTL: cfg.Purchase.QuoteTTL}),
+		service.WithWaitlist(waitlistEntries),
+		service.WithRefundPolicy(service.RefundPolicy{
+			Window:                  cfg.Purchase.RefundWindow,
+			NonRefundableCategories: cfg.Purchase.NonRefundableCategories,
+		}),
+		service.WithReceipts(receiptService),
+	)
 	shopHandler := handler.NewShopHandler(shopService)
 
-	// Logic - Skinport
-	skinportClient := skinport.NewClient(skinport.Config{
-		APIURL:   cfg.Skinport.APIURL,
-		ClientID: cfg.Skinport.ClientID,
-		APIKey:   cfg.Skinport.APIKey,
+	// Logic - Skinport: shops with credentials of their own get a client of their own
+	tenantRepo := repository.NewTenantRepository(dbPool)
+	skinportClients := skinport.NewFactory(skinport.Config{
+		APIURL:                cfg.Skinport.APIURL,
+		ClientID:              cfg.Skinport.ClientID,
+		APIKey:                cfg.Skinport.APIKey,
+		FetchTimeout:          cfg.Skinport.FetchTimeout,
+		RequestTimeout:        cfg.Skinport.RequestTimeout,
+		UserAgent:             cfg.Skinport.UserAgent,
+		DefaultAppID:          cfg.Skinport.DefaultAppID,
+		DefaultCurrency:       cfg.Skinport.DefaultCurrency,
+		RateLimit:             cfg.Skinport.RateLimit,
+		RateLimitWindow:       cfg.Skinport.RateLimitWindow,
+		MaxConcurrentRequests: cfg.Skinport.MaxConcurrentRequests,
+		CacheMaxBytes:         cfg.Skinport.CacheMaxBytes,
+		MergeWorkers:          cfg.Skinport.MergeWorkers,
+		DecodeMode:            cfg.Skinport.DecodeMode,
+		SnapshotHistory:       cfg.Skinport.SnapshotHistory,
+		Transport: skinport.TransportConfig{
+			ProxyURL:            cfg.Skinport.ProxyURL,
+			TLSConfig:           cfg.Skinport.TLS,
+			DialTimeout:         cfg.Skinport.DialTimeout,
+			MaxIdleConns:        cfg.Skinport.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.Skinport.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.Skinport.MaxConnsPerHost,
+			IdleConnTimeout:     cfg.Skinport.IdleConnTimeout,
+		},
+		Events: bus,
+	}, service.NewSkinportCredentialStore(tenantRepo, cfg.EncryptionKeys))
+	skinportClient := skinportClients.Shared()
+
+	fxConverter, err := fx.NewConverter(cfg.Skinport.FX)
+	if err != nil {
+		log.Fatalf("Failed to configure currency conversion: %v", err)
+	}
+
+	// Logic - Admin
+	statsRepo := repository.NewStatsRepository(dbPool)
+	statsService := service.NewStatsService(statsRepo, cfg.Admin.StatsUseDailyView)
+	balanceImportService := service.NewBalanceImportService(shopRepo, shopRepo, auditService, blobs)
+	orderImportService := service.NewOrderImportService(shopRepo, shopRepo, auditService, blobs)
+	categoryRepo := repository.NewCategoryRepository(dbPool, shopRepo)
+	itemImportService := service.NewItemImportService(shopRepo, categoryRepo, shopRepo, auditService, blobs)
+	adminHandler := handler.NewAdminHandler(statsService, balanceImportService, orderImportService, itemImportService, auditService)
+
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(errreport.WithReporter(ctx, errorReporter))
+	defer stopJobs()
+
+	// Exclusive jobs run once per period across all instances, coordinated via job_runs
+	scheduler := jobs.NewScheduler(repository.NewJobRepository(dbPool))
+	// /readyz fails until the first ping answers and again while the database is down
+	var ready func() error
+	if cfg.Database.HealthCheckInterval > 0 {
+		dbHealth := repository.NewDatabaseHealth(dbPool)
+		scheduler.Add(jobs.Job{
+			Name:     "database_health",
+			Schedule: jobs.Every(cfg.Database.HealthCheckInterval),
+			Run:      dbHealth.Check,
+			Timeout:  cfg.Database.HealthCheckInterval,
+		})
+		ready = dbHealth.Ready
+	}
+	if alerter != nil && cfg.Alerting.PoolCheckInterval > 0 {
+		poolMonitor := alerting.NewPoolMonitor(alerter, func() alerting.PoolStats {
+			stat := dbPool.Stat()
+			return alerting.PoolStats{
+				Acquired:      stat.AcquiredConns(),
+				Total:         stat.TotalConns(),
+				Max:           stat.MaxConns(),
+				EmptyAcquires: stat.EmptyAcquireCount(),
+			}
+		})
+		scheduler.Add(jobs.Job{
+			Name:     "alert_pool_check",
+			Schedule: jobs.Every(cfg.Alerting.PoolCheckInterval),
+			Run:      poolMonitor.Check,
+		})
+	}
+	if statsService.UsesDailyView() && cfg.Admin.StatsRefreshInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "stats_refresh",
+			Schedule:  jobs.Every(cfg.Admin.StatsRefreshInterval),
+			Run:       statsService.Refresh,
+			Exclusive: true,
+		})
+	}
+	leaderboardService := service.NewLeaderboardService(repository.NewLeaderboardRepository(dbPool), cfg.Jobs.LeaderboardSize)
+	if cfg.Jobs.LeaderboardInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "leaderboard",
+			Schedule:  jobs.Every(cfg.Jobs.LeaderboardInterval),
+			Run:       leaderboardService.Refresh,
+			Exclusive: true,
+			Timeout:   5 * time.Minute,
+		})
+	}
+	if cfg.Jobs.PriceSnapshotInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "price_snapshot",
+			Schedule:  jobs.Every(cfg.Jobs.PriceSnapshotInterval),
+			Run:       shopService.SnapshotItemPrices,
+			Exclusive: true,
+		})
+	}
+
+	// Logic - Order event streams
+	var orderEventsHandler *handler.OrderEventsHandler
+	if cfg.OrderEvents.PollInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:     "order_events_relay",
+			Schedule: jobs.Every(cfg.OrderEvents.PollInterval),
+			Run:      orderEvents.Relay,
+		})
+		orderEventsHandler = handler.NewOrderEventsHandler(orderEvents)
+	}
+
+	// Logic - Catalog snapshot
+	var catalogService *service.CatalogSnapshotService
+	if cfg.Catalog.SnapshotEnabled {
+		var publisher service.SnapshotPublisher = &service.DirPublisher{Dir: cfg.Catalog.SnapshotDir, BaseURL: cfg.Catalog.SnapshotBaseURL}
+		if cfg.Catalog.SnapshotStorage == "storage" {
+			publisher = &service.BlobPublisher{Blob: blobs, Prefix: "catalog", BaseURL: cfg.Catalog.SnapshotBaseURL}
+		}
+		catalogService = service.NewCatalogSnapshotService(shopRepo, tenantRepo, skinportClient, publisher, cfg.Catalog.SnapshotSkinportTop)
+		go catalogService.Run(jobsCtx, cfg.Catalog.SnapshotInterval)
+	}
+	catalogHandler := handler.NewCatalogHandler(catalogService)
+
+	// Load the default Skinport items while the server starts, so the first requests do
+	// not pay for the cold fetch; requests arriving meanwhile wait for it
+	if cfg.Skinport.WarmupOnStart {
+		go func() {
+			ctx, cancel := context.WithTimeout(jobsCtx, cfg.Skinport.WarmupTimeout)
+			defer cancel()
+			start := time.Now()
+			if err := skinportClients.Shared().Prefetch(ctx, skinport.ItemsParams{}); err != nil {
+				slog.Warn("skinport warm-up on start failed", "error", err, "duration", time.Since(start))
+				return
+			}
+			slog.Info("skinport warm-up on start done", "duration", time.Since(start))
+		}()
+	}
+	// Skinport cache is per instance, so every instance warms its own
+	if cfg.Jobs.SkinportWarmupInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:     "skinport_warmup",
+			Schedule: jobs.Every(cfg.Jobs.SkinportWarmupInterval),
+			Run:      skinportClients.WarmUp,
+			Timeout:  time.Minute,
+		})
+	}
+	// Every instance re-fetches its own copy of the rotated secrets
+	if cfg.Secrets.Source != nil && cfg.Secrets.RefreshInterval > 0 {
+		watcher := secretstore.NewWatcher(cfg.Secrets.Source, cfg.Secrets.Values, func(values map[string]string) {
+			if url := values[config.SecretDatabaseURL]; url != "" {
+				if err := dbCredentials.Rotate(url); err != nil {
+					slog.Error("Failed to rotate database credentials", "error", err)
+				}
+			}
+			clientID, apiKey := values[config.SecretSkinportClientID], values[config.SecretSkinportAPIKey]
+			if clientID != "" && apiKey != "" {
+				skinportClients.SetCredentials(skinport.Credentials{ClientID: clientID, APIKey: apiKey})
+			}
+		})
+		scheduler.Add(jobs.Job{
+			Name:     "secrets_refresh",
+			Schedule: jobs.Every(cfg.Secrets.RefreshInterval),
+			Run:      watcher.Refresh,
+			Timeout:  time.Minute,
+		})
+	}
+	// Logic - Notifications
+	favoriteRepo := repository.NewFavoriteRepository(dbPool)
+	var channels notifications.Channels
+	if cfg.Notifications.EmailEnabled {
+		emailSender, err := notifications.NewEmailSender(cfg.Notifications.Email)
+		if err != nil {
+			log.Fatalf("Failed to configure email notifications: %v", err)
+		}
+		channels.Email = emailSender
+	}
+	if cfg.Notifications.TelegramEnabled {
+		channels.Chat = telegram.NewClient(cfg.Telegram.BotToken, cfg.Telegram.APIURL)
+	}
+	notifier := notifications.New(channels, notifications.Options{
+		Receipts:    cfg.Notifications.Receipts,
+		PriceAlerts: cfg.Notifications.PriceAlerts,
+		Waitlist:    cfg.Notifications.Waitlist,
 	})
+	notificationService := service.NewNotificationService(shopRepo, favoriteRepo, shopRepo, skinportClient, notifier,
+		cfg.Notifications.PriceAlertCooldown)
+
+	// Receipts are claimed with SKIP LOCKED, every instance can share the work
+	if notifier.ReceiptsEnabled() && cfg.Notifications.ReceiptInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:     "purchase_receipts",
+			Schedule: jobs.Every(cfg.Notifications.ReceiptInterval),
+			Run:      notificationService.SendReceipts,
+			Timeout:  time.Minute,
+		})
+	}
+	if notifier.PriceAlertsEnabled() && cfg.Notifications.PriceAlertInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "price_alerts",
+			Schedule:  jobs.Every(cfg.Notifications.PriceAlertInterval),
+			Run:       notificationService.SendPriceAlerts,
+			Exclusive: true,
+			Timeout:   time.Minute,
+		})
+	}
+
+	// Logic - Waitlist: entries are served in order, by one instance at a time
+	var waitlistService *service.WaitlistService
+	if waitlistRepo != nil {
+		waitlistService = service.NewWaitlistService(waitlistRepo, shopService, notifier)
+	}
+	if waitlistService != nil && cfg.Purchase.WaitlistInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "waitlist",
+			Schedule:  jobs.Every(cfg.Purchase.WaitlistInterval),
+			Run:       waitlistService.ProcessWaitlist,
+			Exclusive: true,
+			Timeout:   5 * time.Minute,
+		})
+	}
+
+	// Logic - Supplier restocks, followed by a waitlist run
+	supplierFeed, err := supplier.NewFeed(cfg.Supplier.Feed)
+	if err != nil {
+		log.Fatalf("Failed to configure the supplier feed: %v", err)
+	}
+	if supplierFeed != nil && cfg.Supplier.Interval > 0 {
+		restockService := service.NewRestockService(shopRepo, shopRepo, supplierFeed, waitlistService)
+		scheduler.Add(jobs.Job{
+			Name:      "supplier_restocks",
+			Schedule:  jobs.Every(cfg.Supplier.Interval),
+			Run:       restockService.IngestRestocks,
+			Exclusive: true,
+			Timeout:   10 * time.Minute,
+		})
+	}
+
+	// Logic - Payouts
+	payoutProvider, err := payouts.NewProvider(cfg.Payouts.Provider)
+	if err != nil {
+		log.Fatalf("Failed to configure payouts: %v", err)
+	}
+	if payoutProvider.Name() == "stub" {
+		slog.Warn("payouts use the stub provider, withdrawals are not paid out")
+	}
+	payoutService := service.NewPayoutService(repository.NewPayoutRepository(dbPool), shopRepo, shopRepo, payoutProvider, auditService)
+	// Payouts are claimed with SKIP LOCKED, every instance can share the work
+	if cfg.Payouts.Interval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:     "payouts",
+			Schedule: jobs.Every(cfg.Payouts.Interval),
+			Run:      payoutService.ProcessPayouts,
+			Timeout:  time.Minute,
+		})
+	}
+
+	// Logic - Price sync
+	priceSyncService := service.NewPriceSyncService(repository.NewPriceSyncRepository(dbPool, shopRepo), shopRepo, shopRepo,
+		skinportClient, service.PriceSyncOptions{
+			Markup:            cfg.PriceSync.Markup,
+			Currency:          cfg.PriceSync.Currency,
+			ApprovalThreshold: cfg.PriceSync.ApprovalThreshold,
+			Events:            bus,
+		}, auditService)
+	if cfg.PriceSync.Interval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "price_sync",
+			Schedule:  jobs.Every(cfg.PriceSync.Interval),
+			Run:       priceSyncService.Sync,
+			Exclusive: true,
+			Timeout:   time.Minute,
+		})
+	}
+
+	// Logic - Item media
+	itemMediaHandler := handler.NewItemMediaHandler(service.NewItemMediaService(shopRepo, shopRepo, blobs, auditService))
+
+	// Logic - Receipts
+	receiptHandler := handler.NewReceiptHandler(receiptService)
 
-	h := handler.NewHandler(skinportClient, shopHandler)
+	// Logic - Deposits
+	var depositHandler *handler.DepositHandler
+	if cfg.Deposits.Enabled {
+		depositService := service.NewDepositService(repository.NewDepositRepository(dbPool), shopRepo, shopRepo,
+			payments.NewStripe(cfg.Deposits.Stripe), auditService)
+		depositHandler = handler.NewDepositHandler(depositService)
+		if cfg.Deposits.ReconcileInterval > 0 {
+			scheduler.Add(jobs.Job{
+				Name:      "deposit_reconcile",
+				Schedule:  jobs.Every(cfg.Deposits.ReconcileInterval),
+				Run:       depositService.Reconcile,
+				Exclusive: true,
+				Timeout:   time.Minute,
+			})
+		}
+	}
+	// Logic - Ledger
+	if cfg.Jobs.LedgerCheckInterval > 0 {
+		scheduler.Add(jobs.Job{
+			Name:      "ledger_check",
+			Schedule:  jobs.Every(cfg.Jobs.LedgerCheckInterval),
+			Run:       service.NewLedgerService(repository.NewLedgerRepository(dbPool)).Check,
+			Exclusive: true,
+			Timeout:   10 * time.Minute,
+		})
+	}
+	scheduler.Start(jobsCtx)
+
+	// Logic - Steam login
+	var steamHandler *handler.SteamHandler
+	if cfg.Steam.ReturnURL != "" {
+		steamService := service.NewSteamService(repository.NewSteamRepository(dbPool), shopRepo, shopRepo, steam.NewClient(cfg.Steam), skinportClients, auditService)
+		steamHandler = handler.NewSteamHandler(steamService)
+	}
+
+	// Logic - Inventories
+	inventoryRepo := repository.NewInventoryRepository(dbPool)
+
+	// Logic - GraphQL
+	var graphqlServer, graphqlPlayground http.Handler
+	if cfg.GraphQL.Enabled {
+		graphqlServer = graph.NewServer(graph.NewResolver(shopService, skinportClients), cfg.GraphQL.ComplexityLimit)
+		if cfg.GraphQL.Playground {
+			graphqlPlayground = graph.NewPlayground("/v1/graphql")
+		}
+	}
+
+	h := handler.NewHandler(handler.Dependencies{
+		SkinportClients: skinportClients,
+		FX:              fxConverter,
+		ShopHandler:     shopHandler,
+		AdminHandler:    adminHandler,
+		CatalogHandler:  catalogHandler,
+		PromoHandler:    handler.NewPromoHandler(promoService),
+		CategoryHandler: handler.NewCategoryHandler(
+			service.NewCategoryService(categoryRepo, shopRepo, auditService),
+		),
+		Leaderboard: handler.NewLeaderboardHandler(leaderboardService),
+		InventoryHandler: handler.NewInventoryHandler(
+			service.NewInventoryService(inventoryRepo, shopRepo, auditService),
+			service.NewPortfolioService(inventoryRepo, shopRepo, skinportClients, cfg.PriceSync.Currency),
+		),
+		FavoriteHandler: handler.NewFavoriteHandler(
+			service.NewFavoriteService(favoriteRepo, skinportClients),
+		),
+		TelegramHandler: handler.NewTelegramHandler(
+			service.NewTelegramService(repository.NewTelegramRepository(dbPool), shopRepo, auditService, cfg.Telegram.LinkTokenTTL),
+		),
+		PayoutHandler:     handler.NewPayoutHandler(payoutService),
+		DepositHandler:    depositHandler,
+		DropHandler:       handler.NewDropHandler(dropService),
+		ItemMedia:         itemMediaHandler,
+		Receipts:          receiptHandler,
+		PriceSync:         handler.NewPriceSyncHandler(priceSyncService),
+		Steam:             steamHandler,
+		Storage:           storageHandler,
+		OrderEvents:       orderEventsHandler,
+		GraphQL:           graphqlServer,
+		GraphQLPlayground: graphqlPlayground,
+		Tenants: handler.NewTenantHandler(
+			service.NewTenantService(tenantRepo, auditService, cfg.EncryptionKeys, skinportClients),
+		),
+		TenantOptions: handler.TenantOptions{
+			Header:     cfg.Tenants.Header,
+			BaseDomain: cfg.Tenants.BaseDomain,
+			Default:    cfg.Tenants.Default,
+		},
+		AdminToken:     cfg.Admin.Token,
+		Debug:          cfg.Admin.DebugEndpoints,
+		RequestTimeout: cfg.RequestTimeout,
+		QueryTimeout:   cfg.Database.QueryTimeout,
+		RequestLog: handler.RequestLogOptions{
+			BodySampleRate: cfg.Logging.BodySampleRate,
+			MaxBodyBytes:   cfg.Logging.BodyMaxBytes,
+			RedactFields:   cfg.Logging.RedactFields,
+		},
+		Ready:           ready,
+		ObservePurchase: purchaseErrors.Record,
+		ErrorReporter:   errorReporter,
+	})
 
 	// 4. Setup Server
 	server := &http.Server{
@@ -73,6 +584,9 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	fmt.Println("Shutting down server...")
+	stopJobs()
+	scheduler.Wait()
+	orderEvents.Close()
 
 	// Create a deadline to wait for.
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -81,6 +595,10 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	// Deliver the events of the last requests, then the alerts they raised
+	bus.Close()
+	alerter.Close()
+	sentry.Close()
 
 	fmt.Println("Server exiting")
 }
@@ -0,0 +1,184 @@
+// Command loadtest drives concurrent traffic against a running instance and reports
+// latency percentiles and error rates per endpoint.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const usage = `loadtest - drive concurrent traffic against the shop API
+
+Usage:
+  loadtest [flags]
+
+Example:
+  loadtest -addr http://localhost:8080 -duration 30s -concurrency 32 -mix buy=1,skinport=4 -users 1-100 -items 1-20
+
+Flags:
+`
+
+func main() {
+	defaultAddr := os.Getenv("SHOPCTL_ADDR")
+	if defaultAddr == "" {
+		defaultAddr = "http://localhost:8080"
+	}
+
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	addr := fs.String("addr", defaultAddr, "API base URL")
+	duration := fs.Duration("duration", 30*time.Second, "how long to run")
+	concurrency := fs.Int("concurrency", 16, "number of concurrent workers")
+	rate := fs.Float64("rate", 0, "total requests per second across workers, 0 means as fast as possible")
+	mix := fs.String("mix", "buy=1,skinport=1", "relative weight of each scenario (buy, skinport)")
+	users := fs.String("users", "1-10", "user id range for purchases")
+	items := fs.String("items", "1-10", "item id range for purchases")
+	count := fs.Int("count", 1, "quantity per purchase")
+	skinportQuery := fs.String("skinport-query", "limit=100", "query string for /v1/skinport/items")
+	timeout := fs.Duration("timeout", 10*time.Second, "per-request timeout")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	maxErrorRate := fs.Float64("max-error-rate", -1, "exit with status 1 when any scenario's error rate exceeds this (0..1), negative disables")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+
+	cfg := config{
+		baseURL:       strings.TrimRight(*addr, "/"),
+		count:         *count,
+		skinportQuery: *skinportQuery,
+	}
+	var err error
+	if cfg.users, err = parseRange(*users); err != nil {
+		fatalf("invalid -users: %v", err)
+	}
+	if cfg.items, err = parseRange(*items); err != nil {
+		fatalf("invalid -items: %v", err)
+	}
+	weights, err := parseMix(*mix)
+	if err != nil {
+		fatalf("invalid -mix: %v", err)
+	}
+	if *concurrency <= 0 {
+		fatalf("-concurrency must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        *concurrency,
+			MaxIdleConnsPerHost: *concurrency,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	fmt.Fprintf(os.Stderr, "running %s against %s with %d workers...\n", *duration, cfg.baseURL, *concurrency)
+	rep := run(ctx, client, cfg, weights, *concurrency, *rate)
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		rep.print(os.Stdout)
+	}
+
+	if *maxErrorRate >= 0 {
+		for _, s := range rep.Scenarios {
+			if s.ErrorRate > *maxErrorRate {
+				fatalf("scenario %s error rate %.2f%% exceeds %.2f%%", s.Name, s.ErrorRate*100, *maxErrorRate*100)
+			}
+		}
+	}
+}
+
+// run starts the workers and collects their results until ctx is done
+func run(ctx context.Context, client *http.Client, cfg config, weights []weighted, concurrency int, rate float64) *report {
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	recorders := map[string]*recorder{}
+	for _, w := range weights {
+		recorders[w.scenario.name] = newRecorder()
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if tick != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tick:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+
+				sc := pick(weights)
+				began := time.Now()
+				status, err := sc.do(ctx, client, cfg)
+				if ctx.Err() != nil && err != nil {
+					// Requests cut short by the end of the run are not failures
+					return
+				}
+				recorders[sc.name].record(time.Since(began), status, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return newReport(time.Since(start), weights, recorders)
+}
+
+func parseRange(s string) ([2]int, error) {
+	lo, hi, found := strings.Cut(s, "-")
+	from, err := strconv.Atoi(lo)
+	if err != nil {
+		return [2]int{}, err
+	}
+	to := from
+	if found {
+		if to, err = strconv.Atoi(hi); err != nil {
+			return [2]int{}, err
+		}
+	}
+	if from <= 0 || to < from {
+		return [2]int{}, fmt.Errorf("range %q must be positive and ascending", s)
+	}
+	return [2]int{from, to}, nil
+}
+
+// randIn returns a random id within the inclusive range
+func randIn(r [2]int) int {
+	return r[0] + rand.IntN(r[1]-r[0]+1)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
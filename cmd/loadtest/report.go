@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects every latency sample of a scenario; runs are short enough to keep them all
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[string]int
+	errors    int
+}
+
+func newRecorder() *recorder {
+	return &recorder{statuses: map[string]int{}}
+}
+
+func (r *recorder) record(d time.Duration, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies = append(r.latencies, d)
+	switch {
+	case err != nil:
+		r.errors++
+		r.statuses["error"]++
+	case status < 200 || status >= 300:
+		r.errors++
+		r.statuses[strconv.Itoa(status)]++
+	default:
+		r.statuses[strconv.Itoa(status)]++
+	}
+}
+
+type scenarioReport struct {
+	Name      string         `json:"name"`
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	ErrorRate float64        `json:"error_rate"`
+	RPS       float64        `json:"rps"`
+	P50       time.Duration  `json:"p50_ns"`
+	P95       time.Duration  `json:"p95_ns"`
+	P99       time.Duration  `json:"p99_ns"`
+	Max       time.Duration  `json:"max_ns"`
+	Statuses  map[string]int `json:"statuses"`
+}
+
+type report struct {
+	Duration  time.Duration    `json:"duration_ns"`
+	Scenarios []scenarioReport `json:"scenarios"`
+}
+
+func newReport(elapsed time.Duration, weights []weighted, recorders map[string]*recorder) *report {
+	rep := &report{Duration: elapsed}
+	for _, w := range weights {
+		r := recorders[w.name]
+		slices.Sort(r.latencies)
+
+		s := scenarioReport{
+			Name:     w.name,
+			Requests: len(r.latencies),
+			Errors:   r.errors,
+			RPS:      float64(len(r.latencies)) / elapsed.Seconds(),
+			P50:      percentile(r.latencies, 50),
+			P95:      percentile(r.latencies, 95),
+			P99:      percentile(r.latencies, 99),
+			Statuses: r.statuses,
+		}
+		if s.Requests > 0 {
+			s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+			s.Max = r.latencies[len(r.latencies)-1]
+		}
+		rep.Scenarios = append(rep.Scenarios, s)
+	}
+	return rep
+}
+
+// percentile uses the nearest-rank method on sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+func (rep *report) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tREQUESTS\tRPS\tERRORS\tP50\tP95\tP99\tMAX\tSTATUSES")
+	for _, s := range rep.Scenarios {
+		codes := make([]string, 0, len(s.Statuses))
+		for code := range s.Statuses {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		var statuses string
+		for i, code := range codes {
+			if i > 0 {
+				statuses += " "
+			}
+			statuses += fmt.Sprintf("%s=%d", code, s.Statuses[code])
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d (%.2f%%)\t%s\t%s\t%s\t%s\t%s\n",
+			s.Name, s.Requests, s.RPS, s.Errors, s.ErrorRate*100,
+			round(s.P50), round(s.P95), round(s.P99), round(s.Max), statuses)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nelapsed %s\n", round(rep.Duration))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
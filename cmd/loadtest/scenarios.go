@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type config struct {
+	baseURL       string
+	users         [2]int
+	items         [2]int
+	count         int
+	skinportQuery string
+}
+
+// scenario issues one request and returns its status code
+type scenario struct {
+	name string
+	do   func(ctx context.Context, client *http.Client, cfg config) (int, error)
+}
+
+var scenarios = map[string]scenario{
+	"buy":      {name: "buy", do: buy},
+	"skinport": {name: "skinport", do: skinportItems},
+}
+
+func buy(ctx context.Context, client *http.Client, cfg config) (int, error) {
+	body, err := json.Marshal(map[string]int{
+		"user_id": randIn(cfg.users),
+		"item_id": randIn(cfg.items),
+		"count":   cfg.count,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.baseURL+"/v1/buy", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(client, req)
+}
+
+func skinportItems(ctx context.Context, client *http.Client, cfg config) (int, error) {
+	u := cfg.baseURL + "/v1/skinport/items"
+	if cfg.skinportQuery != "" {
+		u += "?" + cfg.skinportQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	return send(client, req)
+}
+
+// send reads the whole body so latency includes the transfer and connections are reused
+func send(client *http.Client, req *http.Request) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+type weighted struct {
+	scenario
+	weight int
+}
+
+// parseMix parses "buy=1,skinport=4"
+func parseMix(s string) ([]weighted, error) {
+	var out []weighted
+	for _, part := range strings.Split(s, ",") {
+		name, w, found := strings.Cut(strings.TrimSpace(part), "=")
+		sc, ok := scenarios[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scenario %q", name)
+		}
+		weight := 1
+		if found {
+			var err error
+			if weight, err = strconv.Atoi(w); err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight %q for %s", w, name)
+			}
+		}
+		if weight > 0 {
+			out = append(out, weighted{scenario: sc, weight: weight})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no scenario has a positive weight")
+	}
+	return out, nil
+}
+
+func pick(weights []weighted) scenario {
+	total := 0
+	for _, w := range weights {
+		total += w.weight
+	}
+	n := rand.IntN(total)
+	for _, w := range weights {
+		if n < w.weight {
+			return w.scenario
+		}
+		n -= w.weight
+	}
+	return weights[len(weights)-1].scenario
+}
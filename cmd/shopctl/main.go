@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/pkg/sdk"
+)
+
+const usage = `shopctl - operator client for the shop API
+
+Usage:
+  shopctl [-addr URL] [-token TOKEN] <command> [flags]
+
+Commands:
+  items                          List shop items
+  balance <user_id>              Show a user's balance
+  buy -user ID -item ID [-count N]
+                                 Place a purchase
+  skinport-items [-app-id ID] [-currency CUR]
+                                 Show cached Skinport items count
+  skinport-refresh [-app-id ID] [-currency CUR]
+                                 Force a Skinport cache refresh (admin)
+
+The API address defaults to $SHOPCTL_ADDR or http://localhost:8080, the admin token
+to $ADMIN_TOKEN.
+`
+
+func main() {
+	defaultAddr := os.Getenv("SHOPCTL_ADDR")
+	if defaultAddr == "" {
+		defaultAddr = "http://localhost:8080"
+	}
+
+	fs := flag.NewFlagSet("shopctl", flag.ExitOnError)
+	addr := fs.String("addr", defaultAddr, "API base URL")
+	token := fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin API bearer token")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := sdk.NewClient(*addr).WithToken(*token)
+	if err := run(ctx, client, fs.Arg(0), fs.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, client *sdk.Client, cmd string, args []string) error {
+	switch cmd {
+	case "items":
+		items, err := client.ListItems(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(items)
+
+	case "balance":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: shopctl balance <user_id>")
+		}
+		userID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid user id %q", args[0])
+		}
+		user, err := client.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("user %d (%s %s): balance %.2f\n", user.ID, user.FirstName, user.LastName, user.Balance)
+		return nil
+
+	case "buy":
+		fs := flag.NewFlagSet("buy", flag.ExitOnError)
+		userID := fs.Int("user", 0, "user id")
+		itemID := fs.Int("item", 0, "item id")
+		count := fs.Int("count", 1, "quantity")
+		fs.Parse(args)
+		if *userID == 0 || *itemID == 0 {
+			return fmt.Errorf("usage: shopctl buy -user ID -item ID [-count N]")
+		}
+		if err := client.Buy(ctx, sdk.BuyRequest{UserID: *userID, ItemID: *itemID, Count: *count}); err != nil {
+			return err
+		}
+		fmt.Println("purchase succeeded")
+		return nil
+
+	case "skinport-items", "skinport-refresh":
+		fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+		appID := fs.String("app-id", "", "Skinport app id (server default if empty)")
+		currency := fs.String("currency", "", "currency (server default if empty)")
+		fs.Parse(args)
+
+		if cmd == "skinport-refresh" {
+			n, err := client.RefreshSkinportCache(ctx, *appID, *currency)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("cache refreshed: %d items\n", n)
+			return nil
+		}
+
+		items, err := client.GetSkinportItems(ctx, *appID, *currency)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d items\n", len(items))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q (run shopctl -h)", cmd)
+	}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,126 @@
+// Command telegrambot runs the shop's Telegram bot: users link their chat with a token
+// from POST /v1/admin/users/{id}/telegram/link-token, then check Skinport prices and buy
+// items through the same services as the HTTP API. Run a single instance per bot token.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fsanano/go-test/internal/buildinfo"
+	"fsanano/go-test/internal/config"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/telegram"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Telegram.BotToken == "" {
+		log.Fatal("TELEGRAM_BOT_TOKEN must be set")
+	}
+
+	logOpts := &slog.HandlerOptions{Level: cfg.Logging.Level}
+	var logHandler slog.Handler = slog.NewTextHandler(os.Stdout, logOpts)
+	if cfg.Logging.Format == "json" {
+		logHandler = slog.NewJSONHandler(os.Stdout, logOpts)
+	}
+	slog.SetDefault(slog.New(logHandler))
+	slog.Info("Starting Telegram bot", buildinfo.Get().LogAttrs()...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to parse database URL: %v", err)
+	}
+	poolConfig.AfterConnect = model.RegisterPgTypes
+	if err := cfg.Database.Pool.Apply(poolConfig); err != nil {
+		log.Fatalf("Failed to configure database pool: %v", err)
+	}
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbPool.Close()
+	if err := repository.WaitForDatabase(ctx, dbPool, cfg.Database.ConnectAttempts, cfg.Database.ConnectBackoff); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	// Purchases go through the same rules as the HTTP API
+	shopRepo := repository.NewShopRepository(dbPool,
+		repository.WithStatementTimeout(cfg.Database.StatementTimeout),
+		repository.WithRetry(cfg.Database.TxMaxAttempts, 20*time.Millisecond),
+	)
+	auditService := service.NewAuditService(repository.NewAuditRepository(dbPool))
+	shopService := service.NewShopService(shopRepo, shopRepo,
+		service.WithAuditLog(auditService),
+		service.WithPromoCodes(service.NewPromoService(repository.NewPromoRepository(dbPool), shopRepo, auditService)),
+		service.WithDrops(service.NewDropService(repository.NewDropRepository(dbPool), shopRepo, shopRepo, auditService)),
+		service.WithOrderEvents(service.NewOrderEventService(repository.NewOrderEventRepository(dbPool))),
+		service.WithPurchaseLimits(service.PurchaseLimits{
+			MaxOrdersPerMinute: cfg.Purchase.MaxOrdersPerMinute,
+			MaxSpendPerDay:     model.NewMoney(cfg.Purchase.MaxSpendPerDay, model.DefaultCurrency),
+			MaxQuantityPerItem: cfg.Purchase.MaxQuantityPerItem,
+		}),
+		service.WithSingleStatementPurchase(cfg.Purchase.SingleStatement),
+		service.WithPurchaseQueue(service.PurchaseQueueOptions{
+			MaxWaiting: cfg.Purchase.QueueMaxWaiting,
+			MaxWait:    cfg.Purchase.QueueMaxWait,
+		}),
+	)
+	skinportClients := skinport.NewFactory(skinport.Config{
+		APIURL:                cfg.Skinport.APIURL,
+		ClientID:              cfg.Skinport.ClientID,
+		APIKey:                cfg.Skinport.APIKey,
+		FetchTimeout:          cfg.Skinport.FetchTimeout,
+		RequestTimeout:        cfg.Skinport.RequestTimeout,
+		UserAgent:             cfg.Skinport.UserAgent,
+		DefaultAppID:          cfg.Skinport.DefaultAppID,
+		DefaultCurrency:       cfg.Skinport.DefaultCurrency,
+		RateLimit:             cfg.Skinport.RateLimit,
+		RateLimitWindow:       cfg.Skinport.RateLimitWindow,
+		MaxConcurrentRequests: cfg.Skinport.MaxConcurrentRequests,
+		CacheMaxBytes:         cfg.Skinport.CacheMaxBytes,
+		MergeWorkers:          cfg.Skinport.MergeWorkers,
+		DecodeMode:            cfg.Skinport.DecodeMode,
+		Transport: skinport.TransportConfig{
+			ProxyURL:            cfg.Skinport.ProxyURL,
+			TLSConfig:           cfg.Skinport.TLS,
+			DialTimeout:         cfg.Skinport.DialTimeout,
+			MaxIdleConns:        cfg.Skinport.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.Skinport.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.Skinport.MaxConnsPerHost,
+			IdleConnTimeout:     cfg.Skinport.IdleConnTimeout,
+		},
+	}, nil)
+
+	bot := telegram.NewBot(
+		telegram.NewClient(cfg.Telegram.BotToken, cfg.Telegram.APIURL),
+		service.NewTelegramService(repository.NewTelegramRepository(dbPool), shopRepo, auditService, cfg.Telegram.LinkTokenTTL),
+		shopService,
+		service.NewFavoriteService(repository.NewFavoriteRepository(dbPool), skinportClients),
+		skinportClients.Shared(),
+		cfg.Telegram.PollTimeout,
+	)
+
+	fmt.Println("Telegram bot started")
+	if err := bot.Run(ctx); err != nil {
+		log.Fatalf("Telegram bot failed: %v", err)
+	}
+	fmt.Println("Telegram bot exiting")
+}
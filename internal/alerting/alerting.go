@@ -0,0 +1,189 @@
+// Package alerting posts operational alerts (Skinport failing, a saturated database
+// pool, failing purchases) to Slack and Discord webhooks, throttled per alert.
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config selects the webhooks alerts are posted to and when they are raised
+type Config struct {
+	SlackWebhookURL   string
+	DiscordWebhookURL string
+	// Throttle is the least time between two alerts of the same kind, the ones in between
+	// are counted and reported with the next
+	Throttle time.Duration
+	// SkinportFailures alerts after that many Skinport fetches in a row failed (0 disables)
+	SkinportFailures int
+	// PoolCheckInterval is how often the database pool is checked for saturation
+	PoolCheckInterval time.Duration
+	// PurchaseErrorRate alerts when more than this percent of the purchases of the last
+	// PurchaseWindow failed, out of at least PurchaseMinRequests (0 disables)
+	PurchaseErrorRate   float64
+	PurchaseWindow      time.Duration
+	PurchaseMinRequests int
+}
+
+// Sender posts an alert to one destination
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, text string) error
+}
+
+// Webhook posts alerts as JSON to an incoming webhook URL
+type Webhook struct {
+	name   string
+	URL    string
+	Client *http.Client
+	// body builds the JSON payload of an alert's text
+	body func(text string) any
+}
+
+// NewSlack posts alerts to a Slack incoming webhook
+func NewSlack(url string) *Webhook {
+	return &Webhook{name: "slack", URL: url, Client: &http.Client{Timeout: 10 * time.Second},
+		body: func(text string) any { return map[string]string{"text": text} }}
+}
+
+// NewDiscord posts alerts to a Discord webhook
+func NewDiscord(url string) *Webhook {
+	return &Webhook{name: "discord", URL: url, Client: &http.Client{Timeout: 10 * time.Second},
+		body: func(text string) any { return map[string]string{"content": text} }}
+}
+
+func (w *Webhook) Name() string { return w.name }
+
+func (w *Webhook) Send(ctx context.Context, text string) error {
+	payload, err := json.Marshal(w.body(text))
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alert request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sendTimeout bounds the delivery of an alert to every sender
+const sendTimeout = 15 * time.Second
+
+// Alerter sends alerts to its senders in the background, at most one per kind per
+// throttle. A nil *Alerter sends nothing.
+type Alerter struct {
+	senders  []Sender
+	throttle time.Duration
+	// instance names this process in alerts, several instances may raise the same one
+	instance string
+	// now is replaced by tests
+	now func() time.Time
+
+	mu    sync.Mutex
+	kinds map[string]*throttleState
+	wg    sync.WaitGroup
+}
+
+type throttleState struct {
+	sentAt     time.Time
+	suppressed int
+}
+
+// New returns an Alerter posting to the webhooks of cfg, nil when none is configured
+func New(cfg Config) *Alerter {
+	var senders []Sender
+	if cfg.SlackWebhookURL != "" {
+		senders = append(senders, NewSlack(cfg.SlackWebhookURL))
+	}
+	if cfg.DiscordWebhookURL != "" {
+		senders = append(senders, NewDiscord(cfg.DiscordWebhookURL))
+	}
+	return NewAlerter(cfg.Throttle, senders...)
+}
+
+// NewAlerter returns an Alerter posting to senders, nil without any
+func NewAlerter(throttle time.Duration, senders ...Sender) *Alerter {
+	if len(senders) == 0 {
+		return nil
+	}
+	instance, _ := os.Hostname()
+	return &Alerter{
+		senders:  senders,
+		throttle: throttle,
+		instance: instance,
+		now:      time.Now,
+		kinds:    make(map[string]*throttleState),
+	}
+}
+
+// Alert posts message unless an alert of the same kind was posted within the throttle.
+// It does not wait for the delivery, failures are logged.
+func (a *Alerter) Alert(ctx context.Context, kind, message string) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	state, ok := a.kinds[kind]
+	if !ok {
+		state = &throttleState{}
+		a.kinds[kind] = state
+	}
+	now := a.now()
+	if !state.sentAt.IsZero() && now.Sub(state.sentAt) < a.throttle {
+		state.suppressed++
+		a.mu.Unlock()
+		return
+	}
+	suppressed := state.suppressed
+	state.sentAt, state.suppressed = now, 0
+	a.mu.Unlock()
+
+	text := message
+	if suppressed > 0 {
+		text += fmt.Sprintf(" (%d similar alerts suppressed)", suppressed)
+	}
+	if a.instance != "" {
+		text = fmt.Sprintf("[%s] %s", a.instance, text)
+	}
+	slog.WarnContext(ctx, "alert raised", "kind", kind, "message", message, "suppressed", suppressed)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+		defer cancel()
+		for _, sender := range a.senders {
+			if err := sender.Send(ctx, text); err != nil {
+				slog.ErrorContext(ctx, "failed to send alert", "sender", sender.Name(), "kind", kind, "error", err)
+			}
+		}
+	}()
+}
+
+// Close waits until the alerts raised so far were sent
+func (a *Alerter) Close() {
+	if a != nil {
+		a.wg.Wait()
+	}
+}
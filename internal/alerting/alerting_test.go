@@ -0,0 +1,177 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (s *recordingSender) Name() string { return "recording" }
+
+func (s *recordingSender) Send(_ context.Context, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.texts = append(s.texts, text)
+	return nil
+}
+
+func newTestAlerter(throttle time.Duration) (*Alerter, *recordingSender, *time.Time) {
+	sender := &recordingSender{}
+	alerter := NewAlerter(throttle, sender)
+	alerter.instance = ""
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerter.now = func() time.Time { return now }
+	return alerter, sender, &now
+}
+
+func TestAlerter_Throttle(t *testing.T) {
+	alerter, sender, now := newTestAlerter(time.Minute)
+	ctx := context.Background()
+
+	alerter.Alert(ctx, "a", "first")
+	alerter.Alert(ctx, "a", "second")
+	alerter.Alert(ctx, "a", "third")
+	alerter.Alert(ctx, "b", "other kind")
+	*now = now.Add(time.Minute)
+	alerter.Alert(ctx, "a", "fourth")
+	alerter.Close()
+
+	assert.ElementsMatch(t, []string{"first", "other kind", "fourth (2 similar alerts suppressed)"}, sender.texts)
+}
+
+func TestAlerter_Nil(t *testing.T) {
+	alerter := New(Config{})
+	assert.Nil(t, alerter)
+	alerter.Alert(context.Background(), "a", "dropped")
+	alerter.Close()
+	assert.Nil(t, alerter.NewErrorRate(KindPurchaseErrors, "Purchase", 10, time.Minute, 1))
+	(*ErrorRate)(nil).Record(context.Background(), true)
+}
+
+func TestWebhook(t *testing.T) {
+	var bodies []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewSlack(server.URL+"/slack").Send(context.Background(), "hello"))
+	require.NoError(t, NewDiscord(server.URL+"/discord").Send(context.Background(), "hello"))
+	err := NewSlack(server.URL+"/fail").Send(context.Background(), "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400: invalid_payload")
+
+	assert.Equal(t, []map[string]string{{"text": "hello"}, {"content": "hello"}, {"text": "hello"}}, bodies)
+}
+
+func TestWatchSkinport(t *testing.T) {
+	alerter, sender, _ := newTestAlerter(0)
+	bus := eventbus.New(0)
+	WatchSkinport(bus, alerter, 2)
+
+	ctx := context.Background()
+	fail := eventbus.UpstreamFailedEvent{Source: eventbus.PriceSourceSkinport, AppID: "730", Currency: "EUR", Error: "timeout"}
+
+	eventbus.Publish(ctx, bus, eventbus.UpstreamFailed, fail)
+	eventbus.Publish(ctx, bus, eventbus.UpstreamFailed, eventbus.UpstreamFailedEvent{Source: "steam"})
+	eventbus.Publish(ctx, bus, eventbus.UpstreamFailed, fail)
+	bus.Close()
+	alerter.Close()
+
+	assert.Equal(t, []string{"Skinport is failing: 2 fetches in a row failed, last for app_id=730 currency=EUR: timeout"}, sender.texts)
+}
+
+func TestWatchSkinport_Recovery(t *testing.T) {
+	alerter, sender, _ := newTestAlerter(0)
+	bus := eventbus.New(0)
+	WatchSkinport(bus, alerter, 1)
+
+	ctx := context.Background()
+	eventbus.Publish(ctx, bus, eventbus.UpstreamFailed, eventbus.UpstreamFailedEvent{Source: eventbus.PriceSourceSkinport, Error: "timeout"})
+	require.Eventually(t, func() bool {
+		sender.mu.Lock()
+		defer sender.mu.Unlock()
+		return len(sender.texts) == 1
+	}, time.Second, time.Millisecond)
+
+	eventbus.Publish(ctx, bus, eventbus.PriceRefreshed, eventbus.PriceRefreshedEvent{Source: eventbus.PriceSourceSkinport})
+	eventbus.Publish(ctx, bus, eventbus.PriceRefreshed, eventbus.PriceRefreshedEvent{Source: eventbus.PriceSourceSkinport})
+	bus.Close()
+	alerter.Close()
+
+	assert.Equal(t, "Skinport recovered after 1 failed fetches", sender.texts[1])
+	assert.Len(t, sender.texts, 2, "only the first success after an alert reports a recovery")
+}
+
+func TestPoolMonitor(t *testing.T) {
+	alerter, sender, _ := newTestAlerter(0)
+	stats := PoolStats{Acquired: 5, Total: 5, Max: 5, EmptyAcquires: 10}
+	monitor := NewPoolMonitor(alerter, func() PoolStats { return stats })
+	ctx := context.Background()
+
+	require.NoError(t, monitor.Check(ctx), "the first check only records the stats")
+	require.NoError(t, monitor.Check(ctx), "no acquire waited since")
+	stats.Total, stats.EmptyAcquires = 4, 12
+	require.NoError(t, monitor.Check(ctx), "the pool can still grow")
+	stats.Total, stats.EmptyAcquires = 5, 15
+	require.NoError(t, monitor.Check(ctx))
+	alerter.Close()
+
+	assert.Equal(t, []string{"Database pool saturated: 5/5 connections in use, 3 acquires waited for a connection"}, sender.texts)
+}
+
+func TestErrorRate(t *testing.T) {
+	alerter, sender, now := newTestAlerter(0)
+	rate := alerter.NewErrorRate(KindPurchaseErrors, "Purchase", 20, 10*time.Minute, 5)
+	ctx := context.Background()
+
+	rate.Record(ctx, true)
+	rate.Record(ctx, true)
+	rate.Record(ctx, false)
+	rate.Record(ctx, false)
+	alerter.Close()
+	assert.Empty(t, sender.texts, "fewer requests than the minimum")
+
+	rate.Record(ctx, true)
+	alerter.Close()
+	assert.Equal(t, []string{"Purchase error rate at 60.0%: 3 of the last 5 failed within 10m0s"}, sender.texts)
+
+	*now = now.Add(10 * time.Minute)
+	for range 9 {
+		rate.Record(ctx, false)
+	}
+	rate.Record(ctx, true)
+	alerter.Close()
+	assert.Len(t, sender.texts, 1, "the failures left the window, 1 of 10 is under the threshold")
+}
+
+func TestAlerter_SenderFailure(t *testing.T) {
+	alerter := NewAlerter(0, failingSender{})
+	alerter.Alert(context.Background(), "a", "lost")
+	alerter.Close()
+}
+
+type failingSender struct{}
+
+func (failingSender) Name() string                       { return "failing" }
+func (failingSender) Send(context.Context, string) error { return errors.New("unreachable") }
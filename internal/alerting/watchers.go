@@ -0,0 +1,183 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/eventbus"
+)
+
+// Alert kinds, each throttled on its own
+const (
+	KindSkinportFailing   = "skinport_failing"
+	KindSkinportRecovered = "skinport_recovered"
+	KindDatabasePool      = "database_pool_saturated"
+	KindPurchaseErrors    = "purchase_error_rate"
+)
+
+// WatchSkinport alerts once failures Skinport fetches in a row failed, and again when a
+// fetch succeeds after such an alert. It does nothing when alerter is nil or failures 0.
+func WatchSkinport(bus *eventbus.Bus, alerter *Alerter, failures int) (unsubscribe func()) {
+	if alerter == nil || failures <= 0 {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	var streak int
+	var alerted bool
+	unsubFailed := eventbus.Subscribe(bus, eventbus.UpstreamFailed, func(ctx context.Context, e eventbus.UpstreamFailedEvent) {
+		if e.Source != eventbus.PriceSourceSkinport {
+			return
+		}
+		mu.Lock()
+		streak++
+		n := streak
+		if n >= failures {
+			alerted = true
+		}
+		mu.Unlock()
+		if n >= failures {
+			alerter.Alert(ctx, KindSkinportFailing, fmt.Sprintf("Skinport is failing: %d fetches in a row failed, last for app_id=%s currency=%s: %s",
+				n, e.AppID, e.Currency, e.Error))
+		}
+	})
+	unsubRefreshed := eventbus.Subscribe(bus, eventbus.PriceRefreshed, func(ctx context.Context, e eventbus.PriceRefreshedEvent) {
+		if e.Source != eventbus.PriceSourceSkinport {
+			return
+		}
+		mu.Lock()
+		n, recovered := streak, alerted
+		streak, alerted = 0, false
+		mu.Unlock()
+		if recovered {
+			alerter.Alert(ctx, KindSkinportRecovered, fmt.Sprintf("Skinport recovered after %d failed fetches", n))
+		}
+	})
+	return func() {
+		unsubFailed()
+		unsubRefreshed()
+	}
+}
+
+// PoolStats is the part of a database pool's statistics PoolMonitor looks at
+type PoolStats struct {
+	Acquired int32
+	Total    int32
+	Max      int32
+	// EmptyAcquires counts the acquires that had to wait for a connection
+	EmptyAcquires int64
+}
+
+// PoolMonitor alerts when the database pool is saturated: every connection is open and
+// acquires had to wait for one since the previous check
+type PoolMonitor struct {
+	alerter *Alerter
+	stats   func() PoolStats
+
+	mu   sync.Mutex
+	last *PoolStats
+}
+
+func NewPoolMonitor(alerter *Alerter, stats func() PoolStats) *PoolMonitor {
+	return &PoolMonitor{alerter: alerter, stats: stats}
+}
+
+// Check compares the pool's statistics with the previous check, to run as a job
+func (m *PoolMonitor) Check(ctx context.Context) error {
+	stats := m.stats()
+
+	m.mu.Lock()
+	last := m.last
+	m.last = &stats
+	m.mu.Unlock()
+
+	if last == nil || stats.Max == 0 || stats.Total < stats.Max {
+		return nil
+	}
+	if waited := stats.EmptyAcquires - last.EmptyAcquires; waited > 0 {
+		m.alerter.Alert(ctx, KindDatabasePool, fmt.Sprintf("Database pool saturated: %d/%d connections in use, %d acquires waited for a connection",
+			stats.Acquired, stats.Max, waited))
+	}
+	return nil
+}
+
+// errorRateBuckets is the number of slices the window of an ErrorRate is counted in
+const errorRateBuckets = 10
+
+// ErrorRate alerts when the share of failed requests over a sliding window exceeds a
+// threshold. A nil *ErrorRate records nothing.
+type ErrorRate struct {
+	alerter *Alerter
+	kind    string
+	label   string
+	// threshold is in percent
+	threshold   float64
+	minRequests int
+	bucketSize  time.Duration
+
+	mu      sync.Mutex
+	buckets [errorRateBuckets]rateBucket
+}
+
+type rateBucket struct {
+	start  time.Time
+	total  int
+	failed int
+}
+
+// NewErrorRate alerts with kind when more than thresholdPercent of the label requests of
+// the last window failed, out of at least minRequests. It returns nil when alerter is
+// nil or thresholdPercent 0.
+func (a *Alerter) NewErrorRate(kind, label string, thresholdPercent float64, window time.Duration, minRequests int) *ErrorRate {
+	if a == nil || thresholdPercent <= 0 || window <= 0 {
+		return nil
+	}
+	return &ErrorRate{
+		alerter:     a,
+		kind:        kind,
+		label:       label,
+		threshold:   thresholdPercent,
+		minRequests: max(minRequests, 1),
+		bucketSize:  window / errorRateBuckets,
+	}
+}
+
+// Record counts a request and alerts when the error rate is over the threshold
+func (r *ErrorRate) Record(ctx context.Context, failed bool) {
+	if r == nil {
+		return
+	}
+
+	now := r.alerter.now()
+	start := now.Truncate(r.bucketSize)
+	oldest := start.Add(-r.bucketSize * (errorRateBuckets - 1))
+
+	r.mu.Lock()
+	bucket := &r.buckets[start.UnixNano()/int64(r.bucketSize)%errorRateBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = rateBucket{start: start}
+	}
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+	var total, failures int
+	for _, b := range r.buckets {
+		if !b.start.Before(oldest) {
+			total += b.total
+			failures += b.failed
+		}
+	}
+	r.mu.Unlock()
+
+	if !failed || total < r.minRequests {
+		return
+	}
+	rate := float64(failures) / float64(total) * 100
+	if rate > r.threshold {
+		r.alerter.Alert(ctx, r.kind, fmt.Sprintf("%s error rate at %.1f%%: %d of the last %d failed within %s",
+			r.label, rate, failures, total, r.bucketSize*errorRateBuckets))
+	}
+}
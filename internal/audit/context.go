@@ -0,0 +1,33 @@
+// Package audit carries who performed a request and under which request ID,
+// so the service layer can attribute audit log entries without knowing about HTTP.
+package audit
+
+import "context"
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+// WithActor stores the identity performing the current operation
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored in ctx, or fallback if none is set
+func ActorFrom(ctx context.Context, fallback string) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return fallback
+}
+
+// WithRequestID stores the request ID of the current operation
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID stored in ctx, if any
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the request ID stored in the context to every record logged with one
+// (slog.InfoContext, ...), so log lines of a request correlate with its access log entry
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h
+func NewLogHandler(h slog.Handler) *LogHandler {
+	return &LogHandler{Handler: h}
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFrom(ctx); requestID != "" && !hasAttr(record, "request_id") {
+		record = record.Clone()
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// hasAttr reports whether the record already carries key, as the access log and the
+// Skinport client log the request ID themselves
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&out, nil)))
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	logger.InfoContext(ctx, "with id")
+	logger.With("component", "shop").InfoContext(ctx, "derived logger")
+	logger.InfoContext(ctx, "explicit", "request_id", "req-1")
+	logger.Info("without context")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "request_id=req-1")
+	assert.Contains(t, lines[1], "component=shop request_id=req-1")
+	assert.Equal(t, 1, strings.Count(lines[2], "request_id="))
+	assert.NotContains(t, lines[3], "request_id")
+}
@@ -0,0 +1,162 @@
+// Package awsv4 signs requests to AWS APIs and S3-compatible services with AWS Signature
+// Version 4.
+package awsv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs requests to one service of one region
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent with temporary credentials (empty for long-term keys)
+	SessionToken string
+	Region       string
+	// Service is the signing name of the service, e.g. s3 or secretsmanager
+	Service string
+}
+
+// Sign adds the Signature Version 4 headers to req, signing the host and every header
+// already set. payloadHash is the hex SHA-256 of the body (see PayloadHash).
+func (s Signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(date)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+s.signature(amzDate, scope, canonicalRequest))
+}
+
+// Presign adds query string authentication for a GET of u to u
+func (s Signer) Presign(u *url.URL, ttl time.Duration, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := s.scope(amzDate[:8])
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	query.Set("X-Amz-Signature", s.signature(amzDate, scope, canonicalRequest))
+	u.RawQuery = CanonicalQuery(query)
+}
+
+func (s Signer) scope(date string) string {
+	return date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// signature signs a canonical request with a key derived from the secret for the scope's date
+func (s Signer) signature(amzDate, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// PayloadHash returns the hex SHA-256 of a request body
+func PayloadHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// CanonicalQuery encodes query sorted and escaped as SigV4 requires
+func CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, Escape(name)+"="+Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// Escape percent-encodes everything but the unreserved characters, as SigV4 requires
+func Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// EscapePath escapes every segment of path
+func EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
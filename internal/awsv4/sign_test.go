@@ -0,0 +1,32 @@
+package awsv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The IAM example of the AWS Signature Version 4 documentation. S3 is covered by the
+// storage package's tests.
+func TestSigner_Sign(t *testing.T) {
+	s := Signer{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "iam"}
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	s.Sign(req, PayloadHash(nil), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", req.Header.Get("Authorization"))
+	assert.Empty(t, req.Header.Get("X-Amz-Content-Sha256"), "only S3 wants the payload hash header")
+
+	s.SessionToken = "session"
+	req, err = http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/", nil)
+	require.NoError(t, err)
+	s.Sign(req, PayloadHash(nil), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+}
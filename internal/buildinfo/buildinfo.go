@@ -0,0 +1,64 @@
+// Package buildinfo describes the running build. The version, commit and build time are
+// injected at link time (see the Makefile):
+//
+//	go build -ldflags "-X fsanano/go-test/internal/buildinfo.Version=v1.4.0 \
+//		-X fsanano/go-test/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X fsanano/go-test/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags -X; builds without them report "dev" and the commit go build stamps
+// into the binary
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info is what GET /v1/version reports
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's info
+var Get = sync.OnceValue(func() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.fillFromVCS(build.Settings)
+	}
+	return info
+})
+
+// fillFromVCS falls back to the commit go build stamps, marking builds with uncommitted
+// changes -dirty
+func (i *Info) fillFromVCS(settings []debug.BuildSetting) {
+	if i.Commit != "" {
+		return
+	}
+	var revision, modified string
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	i.Commit = revision
+}
+
+// LogAttrs are the startup log's attributes naming the build
+func (i Info) LogAttrs() []any {
+	return []any{"version", i.Version, "commit", i.Commit, "build_time", i.BuildTime}
+}
@@ -0,0 +1,34 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+	assert.Equal(t, "dev", info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+}
+
+func TestFillFromVCS(t *testing.T) {
+	settings := []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "3017d81"},
+		{Key: "vcs.modified", Value: "true"},
+	}
+
+	var info Info
+	info.fillFromVCS(settings)
+	assert.Equal(t, "3017d81-dirty", info.Commit)
+
+	info = Info{Commit: "abc123"}
+	info.fillFromVCS(settings)
+	assert.Equal(t, "abc123", info.Commit, "the injected commit wins")
+
+	info = Info{}
+	info.fillFromVCS(nil)
+	assert.Empty(t, info.Commit)
+}
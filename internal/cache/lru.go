@@ -0,0 +1,126 @@
+// Package cache keeps recently read values in memory, bounded in count and age.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a fixed-capacity cache evicting the least recently used entry when full.
+// Entries also expire ttl after they were set. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	// now is replaced by tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is the most recently used
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New returns a cache of up to capacity entries living for ttl each (0 never expires
+// them). A capacity below 1 returns nil, a cache keeping nothing.
+func New[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	if capacity < 1 {
+		return nil
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[K]*list.Element, capacity),
+	}
+}
+
+// Get returns the live value of key
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if c.ttl > 0 && !c.now().Before(e.expires) {
+		c.remove(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key, evicting the least recently used entry when full
+func (c *LRU[K, V]) Set(key K, value V) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete drops key
+func (c *LRU[K, V]) Delete(key K) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge drops every entry
+func (c *LRU[K, V]) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of entries, expired ones included until they are read or evicted
+func (c *LRU[K, V]) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_Eviction(t *testing.T) {
+	c := New[string, int](2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Reading a makes b the least recently used
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Set("c", 3)
+	_, ok = c.Get("b")
+	assert.False(t, ok, "b was evicted")
+	assert.Equal(t, 2, c.Len())
+
+	c.Set("a", 10)
+	v, _ = c.Get("a")
+	assert.Equal(t, 10, v, "set replaces")
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	c.Purge()
+	assert.Zero(t, c.Len())
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New[int, string](10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set(1, "one")
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get(1)
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get(1)
+	assert.False(t, ok, "expired")
+	assert.Zero(t, c.Len(), "expired entries are dropped on read")
+}
+
+func TestLRU_Disabled(t *testing.T) {
+	c := New[int, int](0, time.Minute)
+	assert.Nil(t, c)
+
+	c.Set(1, 1)
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+	c.Delete(1)
+	c.Purge()
+	assert.Zero(t, c.Len())
+}
+
+func TestLRU_Concurrent(t *testing.T) {
+	c := New[string, int](64, time.Minute)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				key := fmt.Sprint((i + j) % 100)
+				c.Set(key, j)
+				c.Get(key)
+				if j%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, c.Len(), 64)
+}
@@ -1,8 +1,33 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/alerting"
+	"fsanano/go-test/internal/crypto"
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/fx"
+	"fsanano/go-test/internal/notifications"
+	"fsanano/go-test/internal/payments"
+	"fsanano/go-test/internal/payouts"
+	"fsanano/go-test/internal/pricing"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/secretstore"
+	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/steam"
+	"fsanano/go-test/internal/storage"
+	"fsanano/go-test/internal/supplier"
+	"fsanano/go-test/internal/tax"
 
 	"github.com/joho/godotenv"
 )
@@ -11,10 +36,283 @@ type Config struct {
 	ServerPort  string
 	DatabaseURL string
 
+	// Secrets is the secret manager DATABASE_URL and the Skinport credentials are read
+	// from, taking precedence over the environment
+	Secrets struct {
+		// Source is nil with SECRETS_PROVIDER=none
+		Source secretstore.Source
+		// Values are the settings fetched at startup
+		Values map[string]string
+		// RefreshInterval re-fetches them to pick up rotated secrets (0 disables)
+		RefreshInterval time.Duration
+	}
+	// RequestTimeout is the deadline of a single HTTP request, streams excepted (0 disables)
+	RequestTimeout time.Duration
+
+	Database struct {
+		// LogQueries logs every SQL statement at debug level
+		LogQueries bool
+		// SlowQueryThreshold marks queries taking longer as slow (0 disables)
+		SlowQueryThreshold time.Duration
+		// StatementTimeout bounds every statement run inside a transaction (0 disables)
+		StatementTimeout time.Duration
+		// QueryTimeout is the connection-level statement_timeout, bounding statements
+		// outside transactions too, and the deadline of each query of an API request,
+		// which also covers the wait for a pooled connection (0 disables)
+		QueryTimeout time.Duration
+		// TxMaxAttempts bounds retries of transactions aborted by serialization failures/deadlocks
+		TxMaxAttempts int
+		// ConnectAttempts bounds the pings at startup waiting for the database, ConnectBackoff
+		// is the first wait between them, doubling after every attempt
+		ConnectAttempts int
+		ConnectBackoff  time.Duration
+		// HealthCheckInterval is how often the database is pinged for /readyz (0 disables)
+		HealthCheckInterval time.Duration
+		// ReadCacheSize bounds the items, item listings and users each kept in memory by
+		// the API for ReadCacheTTL (0 disables the cache)
+		ReadCacheSize int
+		ReadCacheTTL  time.Duration
+		// Pool selects pgx's query exec mode and statement caches and whether purchase
+		// statements are prepared on connect
+		Pool repository.PoolConfig
+	}
+
+	// Tenants selects the shop each API request is served for
+	Tenants struct {
+		// Header carries the shop's slug
+		Header string
+		// BaseDomain resolves the shop from the subdomain of hosts under it (empty disables)
+		BaseDomain string
+		// Default is the slug of requests selecting no shop
+		Default string
+	}
+
+	// EncryptionKeys encrypt the secrets stored in the database, such as the Skinport
+	// credentials shops configure; nil disables storing them
+	EncryptionKeys *crypto.Keyring
+
+	Admin struct {
+		// Token is the bearer token required by /v1/admin endpoints (empty disables them)
+		Token string
+		// StatsUseDailyView serves stats from the order_stats_daily materialized view
+		StatsUseDailyView bool
+		// StatsRefreshInterval is how often the materialized view is refreshed
+		StatsRefreshInterval time.Duration
+		// DebugEndpoints serves pprof and expvar under /debug, behind Token
+		DebugEndpoints bool
+	}
+
+	// Purchase limits are per-user anti-fraud rules, 0 disables a rule
+	Purchase struct {
+		MaxOrdersPerMinute int
+		MaxSpendPerDay     float64
+		MaxQuantityPerItem int
+		// SingleStatement buys through one CTE-based SQL statement when no promo code or limit applies
+		SingleStatement bool
+		// QueueMaxWaiting is how many purchases of one item may queue behind the one in
+		// progress before new ones get 429, 0 disables the per-item queue
+		QueueMaxWaiting int
+		// QueueMaxWait is how long a queued purchase waits for its turn, 0 until the request deadline
+		QueueMaxWait time.Duration
+		// WaitlistEnabled lets purchases of out-of-stock items join the item's waitlist
+		WaitlistEnabled bool
+		// WaitlistInterval is how often the waitlists of restocked items are served
+		WaitlistInterval time.Duration
+		// RefundWindow is how long after purchase an order may be refunded, 0 for no limit
+		RefundWindow time.Duration
+		// NonRefundableCategories are the slugs of categories whose items are never refunded
+		NonRefundableCategories []string
+		// Tax selects the calculator that taxes purchases
+		Tax tax.Config
+		// QuoteSigningKey signs price quotes (POST /v1/quotes); empty disables quotes
+		QuoteSigningKey string
+		// QuoteTTL is how long a quote holds its price
+		QuoteTTL time.Duration
+	}
+
+	Catalog struct {
+		// SnapshotEnabled publishes a static JSON catalogue for CDN distribution
+		SnapshotEnabled bool
+		// SnapshotStorage is "dir" (SnapshotDir) or "storage" (the blob storage, under catalog/)
+		SnapshotStorage  string
+		SnapshotDir      string
+		SnapshotBaseURL  string
+		SnapshotInterval time.Duration
+		// SnapshotSkinportTop embeds the N most listed Skinport items (0 disables)
+		SnapshotSkinportTop int
+	}
+
+	// Jobs are recurring background tasks, an interval of 0 disables a job
+	Jobs struct {
+		// SkinportWarmupInterval refreshes cached Skinport items before they expire
+		SkinportWarmupInterval time.Duration
+		// PriceSnapshotInterval records item prices into item_price_snapshots
+		PriceSnapshotInterval time.Duration
+		// LedgerCheckInterval verifies the ledger invariants (nightly by default)
+		LedgerCheckInterval time.Duration
+		// LeaderboardInterval rebuilds the leaderboards of GET /v1/stats/leaderboard
+		LeaderboardInterval time.Duration
+		// LeaderboardSize is how many buyers and items each leaderboard keeps
+		LeaderboardSize int
+	}
+
+	// PriceSync prices items mapped to Skinport items from their lowest listing
+	PriceSync struct {
+		// Interval is how often prices are synced (0 disables the worker)
+		Interval time.Duration
+		// Currency of the Skinport prices, empty for Skinport's default
+		Currency string
+		Markup   pricing.Markup
+		// ApprovalThreshold queues changes moving a price by more than this percent for
+		// admin approval (0 applies every change)
+		ApprovalThreshold float64
+	}
+
+	OrderEvents struct {
+		// PollInterval is how often the order_events outbox is polled for stream subscribers (0 disables streaming)
+		PollInterval time.Duration
+	}
+
+	Notifications struct {
+		// EmailEnabled turns on the email channel, configured by Email
+		EmailEnabled bool
+		Email        notifications.EmailConfig
+		// TelegramEnabled sends notifications to linked Telegram chats, needs Telegram.BotToken
+		TelegramEnabled bool
+		// Receipts, PriceAlerts and Waitlist enable each kind of notification
+		Receipts    bool
+		PriceAlerts bool
+		Waitlist    bool
+		// ReceiptInterval and PriceAlertInterval are how often the workers run
+		ReceiptInterval    time.Duration
+		PriceAlertInterval time.Duration
+		// PriceAlertCooldown is the minimum time between two alerts for the same favorite
+		PriceAlertCooldown time.Duration
+	}
+
+	Payouts struct {
+		// Provider sends the payouts queued by withdrawals
+		Provider payouts.Config
+		// Interval is how often pending payouts are sent (0 disables the worker)
+		Interval time.Duration
+	}
+
+	Supplier struct {
+		// Feed delivers restocks from a supplier
+		Feed supplier.Config
+		// Interval is how often the feed is ingested (0 disables the worker)
+		Interval time.Duration
+	}
+
+	// Storage keeps uploaded files such as item images
+	Storage storage.Config
+
+	// Receipts are PDF order receipts, stored in Storage once rendered
+	Receipts struct {
+		// CacheSize bounds the receipts also kept in memory for CacheTTL (0 keeps none)
+		CacheSize int
+		CacheTTL  time.Duration
+	}
+
+	// EventBus forwards domain events to an external broker
+	EventBus eventbus.Config
+
+	// Alerting posts operational alerts to Slack and Discord; it is disabled while no
+	// webhook is set
+	Alerting alerting.Config
+
+	// ErrorReporting sends panics and unexpected errors to Sentry; it is disabled while
+	// SENTRY_DSN is empty
+	ErrorReporting errreport.Config
+
+	Deposits struct {
+		// Enabled takes deposits through Stripe Checkout, configured by Stripe
+		Enabled bool
+		Stripe  payments.StripeConfig
+		// ReconcileInterval is how often pending deposits are checked against Stripe, for
+		// missed webhooks (0 disables)
+		ReconcileInterval time.Duration
+	}
+
+	// Steam configures Steam OpenID login; it is disabled while ReturnURL is empty
+	Steam steam.Config
+
+	Telegram struct {
+		// BotToken authenticates the bot (cmd/telegrambot) and the notification channel
+		BotToken string
+		// APIURL overrides the Bot API endpoint
+		APIURL string
+		// LinkTokenTTL is how long an account-linking token stays valid
+		LinkTokenTTL time.Duration
+		// PollTimeout is how long the bot long-polls for updates
+		PollTimeout time.Duration
+	}
+
+	GraphQL struct {
+		// Enabled serves the GraphQL API at /v1/graphql
+		Enabled bool
+		// Playground serves GraphiQL at /v1/graphql/playground
+		Playground bool
+		// ComplexityLimit bounds the fields a single query may select (0 disables)
+		ComplexityLimit int
+	}
+
+	Logging struct {
+		// Format is "text" or "json"
+		Format string
+		Level  slog.Level
+		// BodySampleRate is the fraction (0..1) of requests whose bodies are logged
+		BodySampleRate float64
+		BodyMaxBytes   int
+		// RedactFields are extra JSON keys/query parameters masked in logged bodies
+		RedactFields []string
+	}
+
 	Skinport struct {
 		APIURL   string
 		ClientID string
 		APIKey   string
+		// FetchTimeout bounds a whole catalogue fetch (both upstream requests and the merge),
+		// independently of the HTTP client's per-request timeout (0 disables)
+		FetchTimeout   time.Duration
+		RequestTimeout time.Duration
+		// RateLimit bounds the requests per RateLimitWindow of the deployment's client and
+		// of every shop's own client (0 disables)
+		RateLimit       int
+		RateLimitWindow time.Duration
+		// MaxConcurrentRequests bounds the requests in flight to Skinport across every
+		// client (0 disables)
+		MaxConcurrentRequests int
+		// UserAgent is sent on Skinport requests, the client's default when empty
+		UserAgent string
+		// DefaultAppID and DefaultCurrency apply to requests without app_id or currency
+		DefaultAppID    string
+		DefaultCurrency string
+		// ProxyURL overrides HTTP_PROXY/HTTPS_PROXY for Skinport requests (nil keeps them)
+		ProxyURL *url.URL
+		// TLS trusts the extra CA file, or skips verification; nil keeps the defaults
+		TLS                 *tls.Config
+		DialTimeout         time.Duration
+		MaxIdleConns        int
+		MaxIdleConnsPerHost int
+		MaxConnsPerHost     int
+		IdleConnTimeout     time.Duration
+		// FX converts Skinport prices for ?convert_to=
+		FX fx.Config
+		// SnapshotHistory is the number of previous snapshots the API keeps per cache
+		// entry for /v1/skinport/diff (0 keeps none)
+		SnapshotHistory int
+		// CacheMaxBytes bounds the estimated memory of every client's cache, evicting the
+		// least recently used entries (0 disables)
+		CacheMaxBytes int64
+		// MergeWorkers merge large catalogues concurrently (0 uses GOMAXPROCS, 1 disables)
+		MergeWorkers int
+		// DecodeMode is how strictly Skinport responses are decoded, see skinport.DecodeMode
+		DecodeMode skinport.DecodeMode
+		// WarmupOnStart loads the default items in the background on startup, giving up
+		// after WarmupTimeout
+		WarmupOnStart bool
+		WarmupTimeout time.Duration
 	}
 }
 
@@ -27,7 +325,28 @@ func Load() (*Config, error) {
 		serverPort = "8080"
 	}
 
-	databaseURL := os.Getenv("DATABASE_URL")
+	secretSource, err := loadSecretSource()
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]string
+	if secretSource != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		secrets, err = secretSource.Fetch(ctx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch secrets from %s: %w", secretSource.Name(), err)
+		}
+	}
+	// secret reads a setting from the secret manager, falling back to the environment
+	secret := func(key string) string {
+		if v := secrets[key]; v != "" {
+			return v
+		}
+		return os.Getenv(key)
+	}
+
+	databaseURL := secret(SecretDatabaseURL)
 	if databaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL must be set")
 	}
@@ -37,27 +356,695 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("SKINPORT_API_URL must be set")
 	}
 
-	skinportClientID := os.Getenv("SKINPORT_CLIENT_ID")
+	skinportClientID := secret(SecretSkinportClientID)
 	if skinportClientID == "" {
 		return nil, fmt.Errorf("SKINPORT_CLIENT_ID must be set")
 	}
 
-	skinportAPIKey := os.Getenv("SKINPORT_API_KEY")
+	skinportAPIKey := secret(SecretSkinportAPIKey)
 	if skinportAPIKey == "" {
 		return nil, fmt.Errorf("SKINPORT_API_KEY must be set")
 	}
 
-	return &Config{
+	cfg := &Config{
 		ServerPort:  serverPort,
 		DatabaseURL: databaseURL,
-		Skinport: struct {
-			APIURL   string
-			ClientID string
-			APIKey   string
-		}{
-			APIURL:   skinportAPIURL,
-			ClientID: skinportClientID,
-			APIKey:   skinportAPIKey,
+	}
+	cfg.Secrets.Source = secretSource
+	cfg.Secrets.Values = secrets
+	cfg.Secrets.RefreshInterval, err = getEnvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.APIURL = skinportAPIURL
+	cfg.Skinport.ClientID = skinportClientID
+	cfg.Skinport.APIKey = skinportAPIKey
+	cfg.Skinport.UserAgent = os.Getenv("SKINPORT_USER_AGENT")
+	cfg.Skinport.DefaultAppID = getEnv("SKINPORT_DEFAULT_APP_ID", skinport.DefaultAppID)
+	if err := skinport.ValidateAppID(cfg.Skinport.DefaultAppID); err != nil {
+		return nil, fmt.Errorf("invalid SKINPORT_DEFAULT_APP_ID: %w", err)
+	}
+	cfg.Skinport.DefaultCurrency, err = skinport.NormalizeCurrency(getEnv("SKINPORT_DEFAULT_CURRENCY", "EUR"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SKINPORT_DEFAULT_CURRENCY: %w", err)
+	}
+	cfg.Skinport.FX.Provider = getEnv("FX_PROVIDER", "none")
+	cfg.Skinport.FX.StaticRates = os.Getenv("FX_STATIC_RATES")
+	cfg.Skinport.FX.ECBURL = os.Getenv("FX_ECB_URL")
+
+	cfg.RequestTimeout, err = getEnvDuration("HTTP_REQUEST_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.FX.CacheTTL, err = getEnvDuration("FX_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.FetchTimeout, err = getEnvDuration("SKINPORT_FETCH_TIMEOUT", 8*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.RateLimit, err = getEnvInt("SKINPORT_RATE_LIMIT", 8)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.RateLimitWindow, err = getEnvDuration("SKINPORT_RATE_LIMIT_WINDOW", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.MaxConcurrentRequests, err = getEnvInt("SKINPORT_MAX_CONCURRENT_REQUESTS", 4)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.SnapshotHistory, err = getEnvInt("SKINPORT_SNAPSHOT_HISTORY", 1)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.MergeWorkers, err = getEnvInt("SKINPORT_MERGE_WORKERS", 0)
+	if err != nil {
+		return nil, err
+	}
+	cacheMaxBytes, err := getEnvInt("SKINPORT_CACHE_MAX_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.CacheMaxBytes = int64(cacheMaxBytes)
+	cfg.Skinport.DecodeMode, err = skinport.ParseDecodeMode(getEnv("SKINPORT_DECODE_MODE", string(skinport.DecodeStandard)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SKINPORT_DECODE_MODE: %w", err)
+	}
+	cfg.Skinport.WarmupOnStart, err = getEnvBool("SKINPORT_WARMUP_ON_START", true)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Skinport.WarmupTimeout, err = getEnvDuration("SKINPORT_WARMUP_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadSkinportTransport(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.EncryptionKeys, err = loadEncryptionKeys(); err != nil {
+		return nil, err
+	}
+
+	cfg.Logging.Format = getEnv("LOG_FORMAT", "text")
+	if cfg.Logging.Format != "text" && cfg.Logging.Format != "json" {
+		return nil, fmt.Errorf("LOG_FORMAT must be text or json, got %q", cfg.Logging.Format)
+	}
+	if err := cfg.Logging.Level.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	cfg.Logging.BodySampleRate, err = getEnvFloat("HTTP_LOG_BODY_SAMPLE_RATE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Logging.BodyMaxBytes, err = getEnvInt("HTTP_LOG_BODY_MAX_BYTES", 2048)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range strings.Split(getEnv("HTTP_LOG_REDACT_FIELDS", ""), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			cfg.Logging.RedactFields = append(cfg.Logging.RedactFields, field)
+		}
+	}
+
+	cfg.Database.LogQueries, err = getEnvBool("DB_LOG_QUERIES", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.SlowQueryThreshold, err = getEnvDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.StatementTimeout, err = getEnvDuration("DB_STATEMENT_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.QueryTimeout, err = getEnvDuration("DB_QUERY_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.TxMaxAttempts, err = getEnvInt("DB_TX_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.ConnectAttempts, err = getEnvInt("DB_CONNECT_ATTEMPTS", 10)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.ConnectBackoff, err = getEnvDuration("DB_CONNECT_BACKOFF", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.HealthCheckInterval, err = getEnvDuration("DB_HEALTH_CHECK_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.ReadCacheSize, err = getEnvInt("DB_READ_CACHE_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.ReadCacheTTL, err = getEnvDuration("DB_READ_CACHE_TTL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.Pool.QueryExecMode = getEnv("DB_QUERY_EXEC_MODE", "cache_statement")
+	cfg.Database.Pool.StatementCacheCapacity, err = getEnvInt("DB_STATEMENT_CACHE_CAPACITY", 512)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.Pool.DescriptionCacheCapacity, err = getEnvInt("DB_DESCRIPTION_CACHE_CAPACITY", 512)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database.Pool.PreparePurchases, err = getEnvBool("DB_PREPARE_PURCHASE_STATEMENTS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Tenants.Header = getEnv("TENANT_HEADER", "X-Tenant")
+	cfg.Tenants.BaseDomain = os.Getenv("TENANT_BASE_DOMAIN")
+	cfg.Tenants.Default = getEnv("TENANT_DEFAULT", "default")
+
+	cfg.Admin.Token = os.Getenv("ADMIN_TOKEN")
+	cfg.Admin.StatsUseDailyView, err = getEnvBool("ADMIN_STATS_USE_DAILY_VIEW", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Admin.StatsRefreshInterval, err = getEnvDuration("ADMIN_STATS_REFRESH_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Admin.DebugEndpoints, err = getEnvBool("ADMIN_DEBUG_ENDPOINTS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Catalog.SnapshotEnabled, err = getEnvBool("CATALOG_SNAPSHOT_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Catalog.SnapshotStorage = getEnv("CATALOG_SNAPSHOT_STORAGE", "dir")
+	cfg.Catalog.SnapshotDir = getEnv("CATALOG_SNAPSHOT_DIR", "./public/catalog")
+	cfg.Catalog.SnapshotBaseURL = getEnv("CATALOG_SNAPSHOT_BASE_URL", "/catalog")
+	cfg.Catalog.SnapshotInterval, err = getEnvDuration("CATALOG_SNAPSHOT_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Catalog.SnapshotSkinportTop, err = getEnvInt("CATALOG_SNAPSHOT_SKINPORT_TOP", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Purchase.MaxOrdersPerMinute, err = getEnvInt("PURCHASE_MAX_ORDERS_PER_MINUTE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.MaxSpendPerDay, err = getEnvFloat("PURCHASE_MAX_SPEND_PER_DAY", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.MaxQuantityPerItem, err = getEnvInt("PURCHASE_MAX_QUANTITY_PER_ITEM", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.SingleStatement, err = getEnvBool("PURCHASE_SINGLE_STATEMENT", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.QueueMaxWaiting, err = getEnvInt("PURCHASE_QUEUE_MAX_WAITING", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.QueueMaxWait, err = getEnvDuration("PURCHASE_QUEUE_MAX_WAIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.WaitlistEnabled, err = getEnvBool("WAITLIST_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.WaitlistInterval, err = getEnvDuration("JOBS_WAITLIST_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.RefundWindow, err = getEnvDuration("REFUND_WINDOW", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, slug := range strings.Split(getEnv("REFUND_NONREFUNDABLE_CATEGORIES", ""), ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			cfg.Purchase.NonRefundableCategories = append(cfg.Purchase.NonRefundableCategories, slug)
+		}
+	}
+	cfg.Purchase.Tax.Calculator = getEnv("TAX_CALCULATOR", "none")
+	cfg.Purchase.Tax.Rate, err = getEnvFloat("TAX_RATE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Purchase.Tax.RegionRates = os.Getenv("TAX_REGION_RATES")
+	cfg.Purchase.QuoteSigningKey = os.Getenv("QUOTE_SIGNING_KEY")
+	cfg.Purchase.QuoteTTL, err = getEnvDuration("QUOTE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Jobs.SkinportWarmupInterval, err = getEnvDuration("JOBS_SKINPORT_WARMUP_INTERVAL", 4*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Jobs.PriceSnapshotInterval, err = getEnvDuration("JOBS_PRICE_SNAPSHOT_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Jobs.LedgerCheckInterval, err = getEnvDuration("JOBS_LEDGER_CHECK_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Jobs.LeaderboardInterval, err = getEnvDuration("JOBS_LEADERBOARD_INTERVAL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Jobs.LeaderboardSize, err = getEnvInt("LEADERBOARD_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PriceSync.Interval, err = getEnvDuration("JOBS_PRICE_SYNC_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PriceSync.Currency = os.Getenv("PRICE_SYNC_CURRENCY")
+	cfg.PriceSync.Markup.Percent, err = getEnvFloat("PRICE_SYNC_MARKUP_PERCENT", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PriceSync.Markup.Floor, err = getEnvFloat("PRICE_SYNC_FLOOR", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PriceSync.Markup.Ceiling, err = getEnvFloat("PRICE_SYNC_CEILING", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PriceSync.Markup.Rounding, err = getEnvFloat("PRICE_SYNC_ROUNDING", 0.01)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PriceSync.Markup.RoundingMode = getEnv("PRICE_SYNC_ROUNDING_MODE", pricing.RoundNearest)
+	cfg.PriceSync.ApprovalThreshold, err = getEnvFloat("PRICE_SYNC_APPROVAL_THRESHOLD", 0)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PriceSync.ApprovalThreshold < 0 {
+		return nil, fmt.Errorf("PRICE_SYNC_APPROVAL_THRESHOLD must not be negative")
+	}
+	if err := cfg.PriceSync.Markup.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PRICE_SYNC markup: %w", err)
+	}
+
+	cfg.OrderEvents.PollInterval, err = getEnvDuration("ORDER_EVENTS_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Notifications.EmailEnabled, err = getEnvBool("NOTIFY_EMAIL_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.Email.Provider = getEnv("NOTIFY_EMAIL_PROVIDER", "smtp")
+	cfg.Notifications.Email.From = os.Getenv("NOTIFY_EMAIL_FROM")
+	cfg.Notifications.Email.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.Notifications.Email.SMTPPort, err = getEnvInt("SMTP_PORT", 587)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.Email.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.Notifications.Email.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.Notifications.Email.SendGridAPIKey = os.Getenv("SENDGRID_API_KEY")
+	cfg.Notifications.Email.SESRegion = os.Getenv("SES_REGION")
+	cfg.Notifications.TelegramEnabled, err = getEnvBool("NOTIFY_TELEGRAM_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.Receipts, err = getEnvBool("NOTIFY_RECEIPTS_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.PriceAlerts, err = getEnvBool("NOTIFY_PRICE_ALERTS_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.Waitlist, err = getEnvBool("NOTIFY_WAITLIST_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.ReceiptInterval, err = getEnvDuration("JOBS_RECEIPTS_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.PriceAlertInterval, err = getEnvDuration("JOBS_PRICE_ALERTS_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications.PriceAlertCooldown, err = getEnvDuration("PRICE_ALERT_COOLDOWN", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Steam.ReturnURL = os.Getenv("STEAM_LOGIN_RETURN_URL")
+	cfg.Steam.OpenIDURL = os.Getenv("STEAM_OPENID_URL")
+	cfg.Steam.APIURL = os.Getenv("STEAM_API_URL")
+	cfg.Steam.APIKey = os.Getenv("STEAM_API_KEY")
+	cfg.Steam.CommunityURL = os.Getenv("STEAM_COMMUNITY_URL")
+	cfg.Steam.InventoryCacheTTL, err = getEnvDuration("STEAM_INVENTORY_CACHE_TTL", steam.DefaultInventoryCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Telegram.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+	cfg.Telegram.APIURL = os.Getenv("TELEGRAM_API_URL")
+	cfg.Telegram.LinkTokenTTL, err = getEnvDuration("TELEGRAM_LINK_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Telegram.PollTimeout, err = getEnvDuration("TELEGRAM_POLL_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Notifications.TelegramEnabled && cfg.Telegram.BotToken == "" {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN must be set when NOTIFY_TELEGRAM_ENABLED is true")
+	}
+
+	cfg.GraphQL.Enabled, err = getEnvBool("GRAPHQL_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Payouts.Provider.Provider = getEnv("PAYOUT_PROVIDER", "stub")
+	cfg.Payouts.Provider.StripeAPIKey = os.Getenv("STRIPE_API_KEY")
+	cfg.Payouts.Provider.Currency = strings.ToLower(getEnv("PAYOUT_CURRENCY", "usd"))
+	cfg.Payouts.Interval, err = getEnvDuration("JOBS_PAYOUTS_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Supplier.Feed = supplier.Config{
+		Feed:     os.Getenv("SUPPLIER_FEED"),
+		Dir:      getEnv("SUPPLIER_FEED_DIR", "./data/supplier"),
+		URL:      os.Getenv("SUPPLIER_FEED_URL"),
+		APIToken: os.Getenv("SUPPLIER_FEED_TOKEN"),
+	}
+	cfg.Supplier.Interval, err = getEnvDuration("JOBS_SUPPLIER_FEED_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Storage = storage.Config{
+		Backend:    getEnv("STORAGE_BACKEND", "local"),
+		Dir:        getEnv("STORAGE_DIR", "./data/storage"),
+		PublicURL:  getEnv("STORAGE_PUBLIC_URL", "/storage"),
+		SigningKey: os.Getenv("STORAGE_SIGNING_KEY"),
+		S3: storage.S3Config{
+			Endpoint:        os.Getenv("STORAGE_S3_ENDPOINT"),
+			Region:          os.Getenv("STORAGE_S3_REGION"),
+			Bucket:          os.Getenv("STORAGE_S3_BUCKET"),
+			AccessKeyID:     os.Getenv("STORAGE_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("STORAGE_S3_SECRET_ACCESS_KEY"),
 		},
-	}, nil
+	}
+
+	cfg.Receipts.CacheSize, err = getEnvInt("RECEIPT_CACHE_SIZE", 256)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Receipts.CacheTTL, err = getEnvDuration("RECEIPT_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.EventBus.Broker = getEnv("EVENTBUS_BROKER", "none")
+	cfg.EventBus.HTTPURL = os.Getenv("EVENTBUS_HTTP_URL")
+	cfg.EventBus.QueueSize, err = getEnvInt("EVENTBUS_QUEUE_SIZE", eventbus.DefaultQueueSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ErrorReporting.SentryDSN = os.Getenv("SENTRY_DSN")
+	cfg.ErrorReporting.Environment = os.Getenv("SENTRY_ENVIRONMENT")
+	cfg.ErrorReporting.QueueSize, err = getEnvInt("SENTRY_QUEUE_SIZE", errreport.DefaultQueueSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Alerting.SlackWebhookURL = os.Getenv("ALERT_SLACK_WEBHOOK_URL")
+	cfg.Alerting.DiscordWebhookURL = os.Getenv("ALERT_DISCORD_WEBHOOK_URL")
+	cfg.Alerting.Throttle, err = getEnvDuration("ALERT_THROTTLE", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting.SkinportFailures, err = getEnvInt("ALERT_SKINPORT_FAILURES", 3)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting.PoolCheckInterval, err = getEnvDuration("ALERT_POOL_CHECK_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting.PurchaseErrorRate, err = getEnvFloat("ALERT_PURCHASE_ERROR_RATE", 20)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting.PurchaseWindow, err = getEnvDuration("ALERT_PURCHASE_WINDOW", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Alerting.PurchaseMinRequests, err = getEnvInt("ALERT_PURCHASE_MIN_REQUESTS", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Deposits.Enabled, err = getEnvBool("DEPOSITS_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Deposits.Stripe = payments.StripeConfig{
+		SecretKey:     os.Getenv("STRIPE_API_KEY"),
+		WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		Currency:      strings.ToLower(getEnv("DEPOSIT_CURRENCY", "usd")),
+		SuccessURL:    os.Getenv("DEPOSIT_SUCCESS_URL"),
+		CancelURL:     os.Getenv("DEPOSIT_CANCEL_URL"),
+	}
+	cfg.Deposits.ReconcileInterval, err = getEnvDuration("JOBS_DEPOSIT_RECONCILE_INTERVAL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Deposits.Enabled {
+		s := cfg.Deposits.Stripe
+		if s.SecretKey == "" || s.WebhookSecret == "" || s.SuccessURL == "" || s.CancelURL == "" {
+			return nil, fmt.Errorf("STRIPE_API_KEY, STRIPE_WEBHOOK_SECRET, DEPOSIT_SUCCESS_URL and DEPOSIT_CANCEL_URL must be set when DEPOSITS_ENABLED is true")
+		}
+	}
+
+	cfg.GraphQL.Playground, err = getEnvBool("GRAPHQL_PLAYGROUND", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.GraphQL.ComplexityLimit, err = getEnvInt("GRAPHQL_COMPLEXITY_LIMIT", 200)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadSkinportTransport reads the SKINPORT_* HTTP client settings: proxy, TLS, dialer and
+// connection pool. Zero values keep Go's defaults.
+func loadSkinportTransport(cfg *Config) error {
+	var err error
+	if cfg.Skinport.RequestTimeout, err = getEnvDuration("SKINPORT_REQUEST_TIMEOUT", 10*time.Second); err != nil {
+		return err
+	}
+	if cfg.Skinport.DialTimeout, err = getEnvDuration("SKINPORT_DIAL_TIMEOUT", 0); err != nil {
+		return err
+	}
+	if cfg.Skinport.IdleConnTimeout, err = getEnvDuration("SKINPORT_IDLE_CONN_TIMEOUT", 0); err != nil {
+		return err
+	}
+	if cfg.Skinport.MaxIdleConns, err = getEnvInt("SKINPORT_MAX_IDLE_CONNS", 0); err != nil {
+		return err
+	}
+	if cfg.Skinport.MaxIdleConnsPerHost, err = getEnvInt("SKINPORT_MAX_IDLE_CONNS_PER_HOST", 0); err != nil {
+		return err
+	}
+	if cfg.Skinport.MaxConnsPerHost, err = getEnvInt("SKINPORT_MAX_CONNS_PER_HOST", 0); err != nil {
+		return err
+	}
+
+	if v := os.Getenv("SKINPORT_PROXY_URL"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("SKINPORT_PROXY_URL must be an absolute URL, got %q", v)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("SKINPORT_PROXY_URL scheme must be http, https, socks5 or socks5h, got %q", u.Scheme)
+		}
+		cfg.Skinport.ProxyURL = u
+	}
+
+	insecure, err := getEnvBool("SKINPORT_TLS_INSECURE_SKIP_VERIFY", false)
+	if err != nil {
+		return err
+	}
+	caFile := os.Getenv("SKINPORT_TLS_CA_FILE")
+	if caFile == "" && !insecure {
+		return nil
+	}
+	cfg.Skinport.TLS = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if caFile != "" {
+		// The CA of a TLS-intercepting proxy is trusted on top of the system roots
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("SKINPORT_TLS_CA_FILE: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("SKINPORT_TLS_CA_FILE %s contains no PEM certificate", caFile)
+		}
+		cfg.Skinport.TLS.RootCAs = pool
+	}
+	return nil
+}
+
+// Settings the secret manager can provide, named like the environment variables
+const (
+	SecretDatabaseURL      = "DATABASE_URL"
+	SecretSkinportClientID = "SKINPORT_CLIENT_ID"
+	SecretSkinportAPIKey   = "SKINPORT_API_KEY"
+)
+
+// loadSecretSource configures the secret manager selected by SECRETS_PROVIDER: none,
+// vault or aws
+func loadSecretSource() (secretstore.Source, error) {
+	switch provider := getEnv("SECRETS_PROVIDER", "none"); provider {
+	case "none":
+		return nil, nil
+	case "vault":
+		cfg := secretstore.VaultConfig{
+			Addr:      os.Getenv("VAULT_ADDR"),
+			Token:     os.Getenv("VAULT_TOKEN"),
+			Namespace: os.Getenv("VAULT_NAMESPACE"),
+			Path:      os.Getenv("VAULT_SECRET_PATH"),
+		}
+		if cfg.Addr == "" || cfg.Token == "" || cfg.Path == "" {
+			return nil, fmt.Errorf("SECRETS_PROVIDER=vault requires VAULT_ADDR, VAULT_TOKEN and VAULT_SECRET_PATH")
+		}
+		return secretstore.NewVault(cfg), nil
+	case "aws":
+		cfg := secretstore.AWSConfig{
+			Region:          os.Getenv("AWS_REGION"),
+			SecretID:        os.Getenv("AWS_SECRETS_MANAGER_SECRET_ID"),
+			Endpoint:        os.Getenv("AWS_SECRETS_MANAGER_ENDPOINT"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if cfg.Region == "" || cfg.SecretID == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, fmt.Errorf("SECRETS_PROVIDER=aws requires AWS_REGION, AWS_SECRETS_MANAGER_SECRET_ID, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return secretstore.NewAWSSecretsManager(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q (none, vault or aws)", provider)
+	}
+}
+
+// loadEncryptionKeys reads ENCRYPTION_KEYS, or the file named by ENCRYPTION_KEYS_FILE
+// where a KMS or secret manager provisions them, and SECRETS_KEY, the single key that
+// sealed secrets before them
+func loadEncryptionKeys() (*crypto.Keyring, error) {
+	spec := os.Getenv("ENCRYPTION_KEYS")
+	if file := os.Getenv("ENCRYPTION_KEYS_FILE"); file != "" {
+		if spec != "" {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS and ENCRYPTION_KEYS_FILE cannot be combined")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS_FILE: %w", err)
+		}
+		// One key per line works as well as a comma separated list
+		spec = strings.Join(strings.Fields(string(data)), ",")
+	}
+	keys, err := crypto.ParseKeys(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEYS: %w", err)
+	}
+	if legacy := os.Getenv("SECRETS_KEY"); legacy != "" {
+		if keys == nil {
+			return nil, fmt.Errorf("SECRETS_KEY requires ENCRYPTION_KEYS to re-encrypt the secrets it sealed")
+		}
+		if err := keys.AddLegacyKey(legacy); err != nil {
+			return nil, fmt.Errorf("SECRETS_KEY: %w", err)
+		}
+	}
+	return keys, nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+	return b, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", key, err)
+	}
+	return f, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %w", key, err)
+	}
+	return d, nil
 }
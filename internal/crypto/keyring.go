@@ -0,0 +1,223 @@
+// Package crypto encrypts secrets stored in the database, such as the Skinport API keys
+// of tenants, with AES-256-GCM keys from the environment or a key file provisioned by a
+// KMS or secret manager.
+//
+// Every sealed value names the key it was sealed with, so keys can be rotated: a new key
+// is added in front of the keyring, values are sealed with it from then on, and older
+// values stay readable until they are re-sealed (see Keyring.Reseal) and the old key is
+// dropped. Values sealed by the single SECRETS_KEY that preceded the keyring open with
+// AddLegacyKey until they are re-sealed the same way.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoKey is returned by a nil Keyring, when no encryption keys are configured
+var ErrNoKey = errors.New("encryption keys are not configured")
+
+// KeySize is the length of keys, in bytes, before base64 encoding
+const KeySize = 32
+
+// formatV1 is the first byte of values sealed as: version, length of the key ID, key ID,
+// nonce, ciphertext with the GCM tag
+const formatV1 = 1
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Keyring seals values with its primary key and opens values sealed with any of its keys
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+	// legacy opens values of the former single-key format, nil when not configured
+	legacy cipher.AEAD
+}
+
+// ParseKeys reads a keyring from a comma separated list of id:key pairs, keys being 32
+// random bytes in base64, e.g. "2026-10:3q2+7w...,2026-01:q83v..."; the first key is the
+// primary one. An empty spec returns nil.
+func ParseKeys(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	for _, pair := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid encryption key %q: want id:base64-key, the id being up to 64 letters, digits and _.-", truncate(pair))
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("encryption key %q must be %d bytes in base64", id, KeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	return k, nil
+}
+
+// GenerateKey returns a new random key in base64, for ParseKeys
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// truncate keeps key material out of error messages
+func truncate(s string) string {
+	if len(s) > 8 {
+		return s[:8] + "..."
+	}
+	return s
+}
+
+// AddLegacyKey makes Open also accept values sealed by the former single-key format:
+// the nonce followed by the ciphertext, under the SHA-256 of secret and without
+// associated data. Such values are never current, so Reseal moves them to the primary key.
+func (k *Keyring) AddLegacyKey(secret string) error {
+	if k == nil {
+		return ErrNoKey
+	}
+	sum := sha256.Sum256([]byte(secret))
+	aead, err := newAEAD(sum[:])
+	if err != nil {
+		return err
+	}
+	k.legacy = aead
+	return nil
+}
+
+// Primary returns the ID of the key new values are sealed with
+func (k *Keyring) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// Seal encrypts plaintext with the primary key. associatedData, e.g. the table and row
+// the value is stored in, is authenticated but not stored: Open fails unless given the
+// same, so a sealed value cannot be moved to another row.
+func (k *Keyring) Seal(plaintext, associatedData []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	aead := k.keys[k.primary]
+
+	header := make([]byte, 0, 2+len(k.primary)+aead.NonceSize())
+	header = append(header, formatV1, byte(len(k.primary)))
+	header = append(header, k.primary...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, plaintext, associatedData), nil
+}
+
+// Open decrypts a value sealed with any key of the keyring, or with its legacy key
+func (k *Keyring) Open(sealed, associatedData []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	plaintext, err := k.open(sealed, associatedData)
+	if err != nil && k.legacy != nil {
+		// A legacy value has no format byte, its nonce is random: GCM authentication
+		// tells which format it is
+		if plaintext, legacyErr := openLegacy(k.legacy, sealed); legacyErr == nil {
+			return plaintext, nil
+		}
+	}
+	return plaintext, err
+}
+
+func (k *Keyring) open(sealed, associatedData []byte) ([]byte, error) {
+	id, rest, err := KeyID(sealed)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("secret is sealed with unknown encryption key %q", id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("sealed secret is too short")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+func openLegacy(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed secret is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+// Current reports whether sealed is sealed with the primary key, that is whether it needs
+// no Reseal
+func (k *Keyring) Current(sealed []byte) bool {
+	id, _, err := KeyID(sealed)
+	return err == nil && id == k.Primary()
+}
+
+// Reseal seals the value again with the primary key
+func (k *Keyring) Reseal(sealed, associatedData []byte) ([]byte, error) {
+	plaintext, err := k.Open(sealed, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return k.Seal(plaintext, associatedData)
+}
+
+// KeyID returns the ID of the key a value was sealed with, and the rest of the value
+func KeyID(sealed []byte) (id string, rest []byte, err error) {
+	if len(sealed) < 2 || sealed[0] != formatV1 {
+		return "", nil, errors.New("unknown sealed secret format")
+	}
+	n := int(sealed[1])
+	if len(sealed) < 2+n {
+		return "", nil, errors.New("sealed secret is too short")
+	}
+	return string(sealed[2 : 2+n]), sealed[2+n:], nil
+}
+
+// String describes the keyring without its key material
+func (k *Keyring) String() string {
+	if k == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d keys, primary %s", len(k.keys), k.primary)
+}
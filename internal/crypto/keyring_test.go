@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T, id string) string {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	return id + ":" + key
+}
+
+func TestKeyring(t *testing.T) {
+	old := testKey(t, "2026-01")
+	keyring, err := ParseKeys(old)
+	require.NoError(t, err)
+	ad := []byte("tenant_skinport_credentials:2")
+
+	sealed, err := keyring.Seal([]byte("sk_live_123"), ad)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "sk_live_123")
+	again, err := keyring.Seal([]byte("sk_live_123"), ad)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every value gets its own nonce")
+
+	plaintext, err := keyring.Open(sealed, ad)
+	require.NoError(t, err)
+	assert.Equal(t, "sk_live_123", string(plaintext))
+	_, err = keyring.Open(sealed, []byte("tenant_skinport_credentials:3"))
+	assert.Error(t, err, "values are bound to their associated data")
+	_, err = keyring.Open(sealed[:5], ad)
+	assert.Error(t, err)
+
+	t.Run("rotation", func(t *testing.T) {
+		rotated, err := ParseKeys(testKey(t, "2026-10") + "," + old)
+		require.NoError(t, err)
+		assert.Equal(t, "2026-10", rotated.Primary())
+		assert.False(t, rotated.Current(sealed))
+
+		plaintext, err := rotated.Open(sealed, ad)
+		require.NoError(t, err, "values sealed with older keys stay readable")
+		assert.Equal(t, "sk_live_123", string(plaintext))
+
+		resealed, err := rotated.Reseal(sealed, ad)
+		require.NoError(t, err)
+		assert.True(t, rotated.Current(resealed))
+		id, _, err := KeyID(resealed)
+		require.NoError(t, err)
+		assert.Equal(t, "2026-10", id)
+
+		_, err = keyring.Open(resealed, ad)
+		assert.ErrorContains(t, err, `unknown encryption key "2026-10"`)
+	})
+
+	t.Run("legacy key", func(t *testing.T) {
+		// Sealed as the former secrets.Box did: nonce and ciphertext under SHA-256(SECRETS_KEY)
+		sum := sha256.Sum256([]byte("long random string"))
+		aead, err := newAEAD(sum[:])
+		require.NoError(t, err)
+		nonce := make([]byte, aead.NonceSize())
+		_, err = rand.Read(nonce)
+		require.NoError(t, err)
+		legacy := aead.Seal(nonce, nonce, []byte("sk_live_123"), nil)
+
+		_, err = keyring.Open(legacy, ad)
+		assert.Error(t, err)
+
+		withLegacy, err := ParseKeys(old)
+		require.NoError(t, err)
+		require.NoError(t, withLegacy.AddLegacyKey("long random string"))
+		plaintext, err := withLegacy.Open(legacy, ad)
+		require.NoError(t, err)
+		assert.Equal(t, "sk_live_123", string(plaintext))
+		assert.False(t, withLegacy.Current(legacy))
+
+		resealed, err := withLegacy.Reseal(legacy, ad)
+		require.NoError(t, err)
+		assert.True(t, withLegacy.Current(resealed))
+		plaintext, err = keyring.Open(resealed, ad)
+		require.NoError(t, err, "resealed values no longer need the legacy key")
+		assert.Equal(t, "sk_live_123", string(plaintext))
+
+		_, err = withLegacy.Open(sealed, []byte("tenant_skinport_credentials:3"))
+		assert.Error(t, err, "the legacy key does not lift the binding of current values")
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, spec := range []string{"nokey", "a:not-base64!", "a:c2hvcnQ=", old + "," + old, "bad id:" + old[len("2026-01:"):]} {
+			_, err := ParseKeys(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		none, err := ParseKeys("")
+		require.NoError(t, err)
+		assert.Nil(t, none)
+		_, err = none.Seal([]byte("x"), nil)
+		assert.ErrorIs(t, err, ErrNoKey)
+		_, err = none.Open(sealed, ad)
+		assert.ErrorIs(t, err, ErrNoKey)
+	})
+}
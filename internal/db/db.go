@@ -0,0 +1,41 @@
+// Package db defines the unit of work services run their transactions in, so that they
+// depend on the repositories' behaviour rather than on the database driver. Pgx runs it
+// on PostgreSQL, Memory backs in-memory fakes in unit tests.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UnitOfWork runs a group of repository calls atomically
+type UnitOfWork interface {
+	// RunAtomic runs fn so that the writes made with the ctx it is given are all kept
+	// when fn returns nil and all discarded otherwise. Called inside another RunAtomic,
+	// only fn's own writes are discarded and the enclosing call decides about the rest.
+	// fn may be run more than once and must therefore be safe to re-run.
+	RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// ErrRetriesExhausted is matched (via errors.Is) by RetryExhaustedError
+var ErrRetriesExhausted = errors.New("transaction retries exhausted")
+
+// RetryExhaustedError is returned by RunAtomic when every attempt
+// failed with a retryable error (serialization failure or deadlock)
+type RetryExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RetryExhaustedError) Is(target error) bool {
+	return target == ErrRetriesExhausted
+}
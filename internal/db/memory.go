@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is the UnitOfWork of in-memory fake repositories. Units run one at a time, and
+// a fake undoes a write when its unit fails by registering the undo with OnRollback.
+// The zero value is ready to use.
+type Memory struct {
+	mu sync.Mutex
+}
+
+type memoryTxKey struct{}
+
+// memoryTx collects the undos of the writes made in a unit
+type memoryTx struct {
+	undo []func()
+}
+
+func (m *Memory) RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !nested {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+
+	tx := &memoryTx{}
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, tx)); err != nil {
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+		return err
+	}
+	if nested {
+		// The enclosing unit may still fail and must then undo these writes too
+		parent.undo = append(parent.undo, tx.undo...)
+	}
+	return nil
+}
+
+// OnRollback registers undo to run if the Memory unit ctx belongs to fails. Outside
+// a unit the write is final and undo is dropped.
+func OnRollback(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_RollsBackFailedUnits(t *testing.T) {
+	var uow Memory
+	ctx := context.Background()
+	stock := 10
+	take := func(ctx context.Context, n int) {
+		stock -= n
+		OnRollback(ctx, func() { stock += n })
+	}
+
+	err := uow.RunAtomic(ctx, func(ctx context.Context) error {
+		take(ctx, 1)
+		err := uow.RunAtomic(ctx, func(ctx context.Context) error {
+			take(ctx, 2)
+			return errors.New("insufficient stock")
+		})
+		assert.EqualError(t, err, "insufficient stock")
+		assert.Equal(t, 9, stock, "only the inner unit is undone")
+
+		return uow.RunAtomic(ctx, func(ctx context.Context) error {
+			take(ctx, 3)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stock)
+
+	// A failed unit undoes the nested units it committed
+	err = uow.RunAtomic(ctx, func(ctx context.Context) error {
+		take(ctx, 1)
+		assert.NoError(t, uow.RunAtomic(ctx, func(ctx context.Context) error {
+			take(ctx, 2)
+			return nil
+		}))
+		return errors.New("outer failed")
+	})
+	assert.EqualError(t, err, "outer failed")
+	assert.Equal(t, 6, stock)
+
+	// Writes outside a unit are final
+	take(ctx, 1)
+	assert.Equal(t, 5, stock)
+}
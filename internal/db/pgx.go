@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pgx is the UnitOfWork of a pgx pool. Repositories run their queries in its
+// transaction by looking it up with TxFromContext.
+type Pgx struct {
+	pool *pgxpool.Pool
+
+	// statementTimeout is applied to every statement inside RunAtomic (0 disables)
+	statementTimeout time.Duration
+	// maxAttempts bounds how many times RunAtomic runs a transaction
+	// that failed with a serialization failure or deadlock
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+// PgxOption configures a Pgx unit of work
+type PgxOption func(*Pgx)
+
+// WithStatementTimeout sets SET LOCAL statement_timeout for transactions started by RunAtomic
+func WithStatementTimeout(d time.Duration) PgxOption {
+	return func(u *Pgx) {
+		u.statementTimeout = d
+	}
+}
+
+// WithRetry configures retries of transactions aborted by serialization failures/deadlocks
+func WithRetry(maxAttempts int, backoff time.Duration) PgxOption {
+	return func(u *Pgx) {
+		if maxAttempts < 1 {
+			maxAttempts = 1
+		}
+		u.maxAttempts = maxAttempts
+		u.retryBackoff = backoff
+	}
+}
+
+func NewPgx(pool *pgxpool.Pool, opts ...PgxOption) *Pgx {
+	u := &Pgx{
+		pool:         pool,
+		maxAttempts:  3,
+		retryBackoff: 20 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction RunAtomic runs ctx in, if any
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// isRetryable reports whether the transaction failed with
+// serialization_failure (40001) or deadlock_detected (40P01)
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
+
+// RunAtomic executes a function within a transaction.
+// Transactions aborted by a serialization failure or deadlock are retried
+// with jittered backoff up to maxAttempts times; fn must therefore be safe to re-run.
+// Called inside another RunAtomic, fn runs in a savepoint of the enclosing transaction
+// instead, see runSavepoint.
+func (u *Pgx) RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return runSavepoint(ctx, tx, fn)
+	}
+
+	var err error
+	for attempt := 1; attempt <= u.maxAttempts; attempt++ {
+		err = u.runTx(ctx, fn)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt == u.maxAttempts {
+			break
+		}
+
+		backoff := u.retryBackoff * time.Duration(attempt)
+		if backoff > 0 {
+			backoff += rand.N(backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return &RetryExhaustedError{Attempts: u.maxAttempts, Err: err}
+}
+
+func (u *Pgx) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := u.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Defer rollback in case of panic or error (if commit succeeds, rollback does nothing)
+	defer tx.Rollback(ctx)
+
+	if u.statementTimeout > 0 {
+		// SET does not accept bind parameters, the value is an integer we format ourselves
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", u.statementTimeout.Milliseconds())); err != nil {
+			return fmt.Errorf("failed to set statement timeout: %w", err)
+		}
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// runSavepoint runs fn in a savepoint of tx. An error rolls back only fn's own work and
+// is returned to the enclosing function, which may recover from it and still commit.
+// There is no retry here: a serialization failure or deadlock aborts the whole
+// transaction, so it is left to the outermost RunAtomic to retry it.
+func runSavepoint(ctx context.Context, tx pgx.Tx, fn func(ctx context.Context) error) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	defer sp.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, sp)); err != nil {
+		return err
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, isRetryable(fmt.Errorf("failed to commit transaction: %w", &pgconn.PgError{Code: "40P01"})))
+	assert.False(t, isRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, isRetryable(errors.New("insufficient stock")))
+}
+
+// savepointTx records the savepoints taken on it, like pgx's nested transactions
+type savepointTx struct {
+	pgx.Tx
+	log   *[]string
+	depth int
+}
+
+func (tx savepointTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	*tx.log = append(*tx.log, "savepoint")
+	return savepointTx{log: tx.log, depth: tx.depth + 1}, nil
+}
+
+func (tx savepointTx) Commit(ctx context.Context) error {
+	*tx.log = append(*tx.log, "release")
+	return nil
+}
+
+func (tx savepointTx) Rollback(ctx context.Context) error {
+	if last := (*tx.log)[len(*tx.log)-1]; last != "release" && last != "rollback" {
+		*tx.log = append(*tx.log, "rollback")
+	}
+	return nil
+}
+
+func TestPgx_NestedUsesSavepoint(t *testing.T) {
+	var log []string
+	outer := savepointTx{log: &log}
+	ctx := context.WithValue(context.Background(), txKey{}, pgx.Tx(outer))
+	// No pool: a nested call must not begin a transaction of its own
+	uow := NewPgx(nil)
+
+	err := uow.RunAtomic(ctx, func(ctx context.Context) error {
+		tx, ok := TxFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, 1, tx.(savepointTx).depth, "inner queries run in the savepoint")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = uow.RunAtomic(ctx, func(ctx context.Context) error {
+		return &pgconn.PgError{Code: "40001"}
+	})
+	var retryErr *RetryExhaustedError
+	assert.False(t, errors.As(err, &retryErr), "the outermost transaction retries")
+	assert.True(t, isRetryable(err))
+
+	assert.Equal(t, []string{"savepoint", "release", "savepoint", "rollback"}, log)
+}
@@ -0,0 +1,125 @@
+// Package errreport sends panics and unexpected errors (5xx responses, failed
+// transactions, Skinport upstream errors) to an error tracker such as Sentry, with the
+// request they happened in.
+package errreport
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/tenant"
+)
+
+// Levels of an Event
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Kinds of events, by origin
+const (
+	KindPanic       = "panic"
+	KindHTTP        = "http_5xx"
+	KindTransaction = "transaction"
+	KindUpstream    = "upstream"
+)
+
+// Event is a reported error
+type Event struct {
+	// Kind groups events of the same origin, see the Kind constants
+	Kind    string
+	Level   string
+	Err     error
+	Message string
+	// Stack is the goroutine stack of a panic
+	Stack      []byte
+	Tags       map[string]string
+	Request    *Request
+	RequestID  string
+	OccurredAt time.Time
+}
+
+// Request is the HTTP request an event happened in
+type Request struct {
+	Method string
+	URL    string
+	// Route is the matched route pattern, e.g. /v1/users/{id}
+	Route string
+}
+
+// Reporter sends events to an error tracker. Report must not block on the network.
+type Reporter interface {
+	Report(ctx context.Context, e Event)
+}
+
+// Config selects the error tracker; reporting is disabled while SentryDSN is empty
+type Config struct {
+	SentryDSN string
+	// Environment tags events, e.g. production or staging
+	Environment string
+	// QueueSize is how many events may wait to be sent before new ones are dropped
+	QueueSize int
+}
+
+// New returns the Sentry reporter of cfg, nil when reporting is disabled
+func New(cfg Config) (*Sentry, error) {
+	if cfg.SentryDSN == "" {
+		return nil, nil
+	}
+	return NewSentry(cfg)
+}
+
+type reporterKey struct{}
+type requestKey struct{}
+
+// WithReporter makes Capture in ctx report to r, so code deep in the services reports
+// without being handed the reporter
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// WithRequest attaches the HTTP request to the events captured in ctx
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, &req)
+}
+
+// Capture reports err to the reporter of ctx, if any, with the request of ctx
+func Capture(ctx context.Context, kind string, err error, tags map[string]string) {
+	CaptureEvent(ctx, Event{Kind: kind, Err: err, Tags: tags})
+}
+
+// CaptureEvent fills in the defaults and the context of e and reports it
+func CaptureEvent(ctx context.Context, e Event) {
+	r, ok := ctx.Value(reporterKey{}).(Reporter)
+	if !ok {
+		return
+	}
+	if e.Level == "" {
+		e.Level = LevelError
+	}
+	if e.Message == "" && e.Err != nil {
+		e.Message = e.Err.Error()
+	}
+	if e.Request == nil {
+		e.Request, _ = ctx.Value(requestKey{}).(*Request)
+	}
+	if e.RequestID == "" {
+		e.RequestID = audit.RequestIDFrom(ctx)
+	}
+	if id, ok := tenant.IDFrom(ctx); ok {
+		tags := make(map[string]string, len(e.Tags)+1)
+		maps.Copy(tags, e.Tags)
+		tags["tenant_id"] = strconv.Itoa(id)
+		e.Tags = tags
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+	r.Report(ctx, e)
+}
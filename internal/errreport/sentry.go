@@ -0,0 +1,285 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/buildinfo"
+	"fsanano/go-test/internal/metrics"
+)
+
+// DefaultQueueSize is how many events may wait to be sent by default
+const DefaultQueueSize = 100
+
+// sendTimeout bounds the delivery of one event
+const sendTimeout = 10 * time.Second
+
+// Sentry sends events to Sentry's store endpoint from a background goroutine, dropping
+// them when the queue is full so reporting never slows requests down. A nil *Sentry
+// reports nothing.
+type Sentry struct {
+	endpoint    string
+	publicKey   string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+
+	mu     sync.RWMutex
+	queue  chan Event
+	closed bool
+	done   chan struct{}
+}
+
+// NewSentry parses cfg.SentryDSN (https://<key>@<host>/<project>) and starts the sender
+func NewSentry(cfg Config) (*Sentry, error) {
+	dsn, err := url.Parse(cfg.SentryDSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry dsn: %w", err)
+	}
+	project := strings.Trim(dsn.Path, "/")
+	if dsn.User == nil || dsn.User.Username() == "" || dsn.Host == "" || project == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: expected <scheme>://<key>@<host>/<project>")
+	}
+	// Sentry installed under a path keeps the project as the last segment
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	hostname, _ := os.Hostname()
+	s := &Sentry{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", dsn.Scheme, dsn.Host, prefix, project),
+		publicKey:   dsn.User.Username(),
+		environment: cfg.Environment,
+		release:     buildinfo.Get().Version,
+		serverName:  hostname,
+		client:      &http.Client{Timeout: sendTimeout},
+		queue:       make(chan Event, queueSize),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Report queues e, or drops and counts it when the queue is full
+func (s *Sentry) Report(ctx context.Context, e Event) {
+	if s == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- e:
+	default:
+		metrics.ErrorReportsDropped.WithLabelValues(e.Kind).Inc()
+		slog.WarnContext(ctx, "error report dropped, queue full", "kind", e.Kind)
+	}
+}
+
+// Close sends the queued events and stops the sender
+func (s *Sentry) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *Sentry) run() {
+	defer close(s.done)
+	for e := range s.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		if err := s.send(ctx, e); err != nil {
+			slog.Error("failed to send error report", "kind", e.Kind, "error", err)
+		}
+		cancel()
+	}
+}
+
+func (s *Sentry) send(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(s.event(e))
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=go-test/%s, sentry_key=%s",
+		s.release, s.publicKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sentry request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sentryEvent is the JSON of Sentry's store endpoint
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Message     string            `json:"message,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   *sentryValues     `json:"exception,omitempty"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+}
+
+type sentryValues struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+func (s *Sentry) event(e Event) sentryEvent {
+	tags := map[string]string{"kind": e.Kind}
+	maps.Copy(tags, e.Tags)
+	if e.RequestID != "" {
+		tags["request_id"] = e.RequestID
+	}
+	out := sentryEvent{
+		EventID:     newEventID(),
+		Timestamp:   e.OccurredAt.UTC().Format(time.RFC3339),
+		Level:       e.Level,
+		Platform:    "go",
+		Logger:      e.Kind,
+		Message:     e.Message,
+		Release:     s.release,
+		Environment: s.environment,
+		ServerName:  s.serverName,
+		Tags:        tags,
+	}
+	if e.Err != nil {
+		exception := sentryException{Type: errorType(e.Err), Value: e.Err.Error()}
+		if frames := parseStack(e.Stack); len(frames) > 0 {
+			exception.Stacktrace = &sentryStacktrace{Frames: frames}
+		}
+		out.Exception = &sentryValues{Values: []sentryException{exception}}
+	}
+	if e.Request != nil {
+		out.Request = &sentryRequest{Method: e.Request.Method, URL: e.Request.URL}
+		out.Transaction = strings.TrimSpace(e.Request.Method + " " + e.Request.Route)
+	}
+	return out
+}
+
+func newEventID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// errorType names the innermost error of err's chain, which groups events better than
+// the wrappers around it
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+// modulePrefix marks the frames of this module as in_app
+const modulePrefix = "fsanano/go-test/"
+
+// parseStack turns a debug.Stack() dump into Sentry frames, outermost call first. The
+// dump lists a function line followed by a tab-indented "file:line +0x.." line per call,
+// innermost first.
+func parseStack(stack []byte) []sentryFrame {
+	lines := strings.Split(string(stack), "\n")
+	var frames []sentryFrame
+	for i := 1; i+1 < len(lines); i++ {
+		location, ok := strings.CutPrefix(lines[i+1], "\t")
+		if !ok || strings.HasPrefix(lines[i], "\t") {
+			continue
+		}
+		function := lines[i]
+		if paren := strings.LastIndex(function, "("); paren > 0 {
+			function = function[:paren]
+		}
+		if space := strings.LastIndex(location, " +0x"); space >= 0 {
+			location = location[:space]
+		}
+		colon := strings.LastIndex(location, ":")
+		if colon < 0 {
+			continue
+		}
+		line, err := strconv.Atoi(location[colon+1:])
+		if err != nil {
+			continue
+		}
+		frames = append(frames, sentryFrame{
+			Function: function,
+			Filename: location[:colon],
+			Lineno:   line,
+			InApp:    strings.HasPrefix(function, modulePrefix),
+		})
+		i++
+	}
+	slices.Reverse(frames)
+	return frames
+}
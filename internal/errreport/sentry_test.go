@@ -0,0 +1,101 @@
+package errreport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSentry_DSN(t *testing.T) {
+	s, err := NewSentry(Config{SentryDSN: "https://key@sentry.example.com/42"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/api/42/store/", s.endpoint)
+	assert.Equal(t, "key", s.publicKey)
+	s.Close()
+
+	s, err = NewSentry(Config{SentryDSN: "http://key@localhost:9000/sentry/7"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9000/sentry/api/7/store/", s.endpoint)
+	s.Close()
+
+	for _, dsn := range []string{"https://sentry.example.com/42", "https://key@sentry.example.com/", "::"} {
+		_, err := NewSentry(Config{SentryDSN: dsn})
+		assert.Error(t, err, dsn)
+	}
+
+	disabled, err := New(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, disabled)
+	disabled.Report(context.Background(), Event{})
+	disabled.Close()
+}
+
+func TestSentry_Report(t *testing.T) {
+	var mu sync.Mutex
+	var events []sentryEvent
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/1/store/", r.URL.Path)
+		var e sentryEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+		auth = r.Header.Get("X-Sentry-Auth")
+	}))
+	defer server.Close()
+
+	s, err := NewSentry(Config{SentryDSN: strings.Replace(server.URL, "http://", "http://public@", 1) + "/1", Environment: "test"})
+	require.NoError(t, err)
+
+	ctx := WithReporter(context.Background(), s)
+	ctx = WithRequest(ctx, Request{Method: "POST", URL: "/v1/buy"})
+	ctx = audit.WithRequestID(ctx, "req-1")
+	ctx = tenant.WithID(ctx, 3)
+	Capture(ctx, KindTransaction, fmt.Errorf("buy failed: %w", errors.New("deadlock")), map[string]string{"op": "buy"})
+	Capture(context.Background(), KindUpstream, errors.New("not reported, no reporter"), nil)
+	s.Close()
+	Capture(ctx, KindTransaction, errors.New("after close"), nil)
+
+	require.Len(t, events, 1)
+	e := events[0]
+	assert.Len(t, e.EventID, 32)
+	assert.Equal(t, "error", e.Level)
+	assert.Equal(t, "test", e.Environment)
+	assert.Equal(t, "buy failed: deadlock", e.Message)
+	assert.Equal(t, map[string]string{"kind": "transaction", "op": "buy", "request_id": "req-1", "tenant_id": "3"}, e.Tags)
+	assert.Equal(t, &sentryRequest{Method: "POST", URL: "/v1/buy"}, e.Request)
+	require.NotNil(t, e.Exception)
+	assert.Equal(t, "*errors.errorString", e.Exception.Values[0].Type)
+	assert.Contains(t, auth, "sentry_key=public")
+}
+
+func TestParseStack(t *testing.T) {
+	frames := parseStack(debug.Stack())
+	require.NotEmpty(t, frames)
+
+	last := frames[len(frames)-1]
+	assert.Equal(t, "runtime/debug.Stack", last.Function)
+	assert.False(t, last.InApp)
+
+	caller := frames[len(frames)-2]
+	assert.Equal(t, "fsanano/go-test/internal/errreport.TestParseStack", caller.Function)
+	assert.True(t, strings.HasSuffix(caller.Filename, "sentry_test.go"))
+	assert.Positive(t, caller.Lineno)
+	assert.True(t, caller.InApp)
+
+	assert.Empty(t, parseStack(nil))
+}
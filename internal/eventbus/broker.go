@@ -0,0 +1,100 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Broker publishes encoded events to an external message broker (a webhook endpoint,
+// Kafka, NATS, ...)
+type Broker interface {
+	Name() string
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// Envelope is the JSON form of events sent to brokers
+type Envelope struct {
+	Topic      string    `json:"topic"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// Forward sends every event published on the bus to the broker as a JSON Envelope
+// until the returned function is called. Failed sends are logged, not retried.
+func Forward(b *Bus, broker Broker) (unsubscribe func()) {
+	return b.SubscribeAll(func(ctx context.Context, topic string, event any) {
+		payload, err := json.Marshal(Envelope{Topic: topic, OccurredAt: time.Now().UTC(), Data: event})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode event", "topic", topic, "error", err)
+			return
+		}
+		if err := broker.Publish(ctx, topic, payload); err != nil {
+			slog.ErrorContext(ctx, "failed to forward event", "broker", broker.Name(), "topic", topic, "error", err)
+		}
+	})
+}
+
+// Config selects the broker events are forwarded to
+type Config struct {
+	// Broker is "none" or "http"
+	Broker string
+	// HTTPURL receives the events of the http broker
+	HTTPURL string
+	// QueueSize bounds each subscriber's queue (DefaultQueueSize when 0)
+	QueueSize int
+}
+
+// NewBroker builds the Broker for cfg.Broker; nil for "none"
+func NewBroker(cfg Config) (Broker, error) {
+	switch cfg.Broker {
+	case "", "none":
+		return nil, nil
+	case "http":
+		if cfg.HTTPURL == "" {
+			return nil, fmt.Errorf("event broker url is required")
+		}
+		return NewHTTPBroker(cfg.HTTPURL), nil
+	default:
+		return nil, fmt.Errorf("unknown event broker %q (want none or http)", cfg.Broker)
+	}
+}
+
+// HTTPBroker POSTs every event to URL, the topic in the X-Event-Topic header. It suits
+// webhook receivers and HTTP bridges of brokers (Kafka REST proxy, NATS, ...).
+type HTTPBroker struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPBroker(url string) *HTTPBroker {
+	return &HTTPBroker{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (b *HTTPBroker) Name() string { return "http" }
+
+func (b *HTTPBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Topic", topic)
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("event request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("event request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
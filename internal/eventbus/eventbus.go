@@ -0,0 +1,174 @@
+// Package eventbus publishes domain events (orders created, prices refreshed, users
+// updated) to in-process subscribers and, through a Broker, to external systems, so
+// features can react to events without the services knowing about them.
+package eventbus
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"fsanano/go-test/internal/metrics"
+)
+
+// Topic is a named stream of events of type E
+type Topic[E any] struct {
+	name string
+}
+
+// NewTopic declares a topic; names are unique per bus
+func NewTopic[E any](name string) Topic[E] {
+	return Topic[E]{name: name}
+}
+
+func (t Topic[E]) Name() string {
+	return t.name
+}
+
+// DefaultQueueSize is how many events a subscriber may lag behind before events are
+// dropped for it
+const DefaultQueueSize = 256
+
+// Bus delivers published events to the subscribers of their topic. Every subscriber has
+// its own queue and goroutine, so a slow subscriber neither blocks publishers nor other
+// subscribers; events that do not fit its queue are dropped and counted. A nil *Bus
+// publishes nothing.
+type Bus struct {
+	queueSize int
+
+	mu     sync.RWMutex
+	subs   map[string]map[*subscription]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type delivery struct {
+	ctx   context.Context
+	topic string
+	event any
+}
+
+type subscription struct {
+	// topic is empty for subscribers of every topic
+	topic   string
+	handler func(ctx context.Context, topic string, event any)
+	queue   chan delivery
+}
+
+// New creates a bus whose subscribers queue up to queueSize events (DefaultQueueSize
+// when 0)
+func New(queueSize int) *Bus {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Bus{queueSize: queueSize, subs: map[string]map[*subscription]struct{}{}}
+}
+
+// Subscribe calls handler with every event published on topic until the returned
+// function is called. Handlers run on the subscriber's goroutine, one event at a time.
+func Subscribe[E any](b *Bus, topic Topic[E], handler func(ctx context.Context, event E)) (unsubscribe func()) {
+	return b.subscribe(topic.name, func(ctx context.Context, _ string, event any) {
+		handler(ctx, event.(E))
+	})
+}
+
+// SubscribeAll calls handler with the events of every topic, e.g. to forward them
+func (b *Bus) SubscribeAll(handler func(ctx context.Context, topic string, event any)) (unsubscribe func()) {
+	return b.subscribe("", handler)
+}
+
+func (b *Bus) subscribe(topic string, handler func(ctx context.Context, topic string, event any)) func() {
+	sub := &subscription{topic: topic, handler: handler, queue: make(chan delivery, b.queueSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return func() {}
+	}
+	if b.subs[topic] == nil {
+		b.subs[topic] = map[*subscription]struct{}{}
+	}
+	b.subs[topic][sub] = struct{}{}
+	b.wg.Add(1)
+	go b.run(sub)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[topic][sub]; ok {
+				delete(b.subs[topic], sub)
+				close(sub.queue)
+			}
+		})
+	}
+}
+
+func (b *Bus) run(sub *subscription) {
+	defer b.wg.Done()
+	for d := range sub.queue {
+		deliver(sub, d)
+	}
+}
+
+// deliver calls the handler, recovering its panics so one bad event does not stop the
+// subscription
+func deliver(sub *subscription, d delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(d.ctx, "event handler panicked", "topic", d.topic, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	sub.handler(d.ctx, d.topic, d.event)
+}
+
+// Publish queues event for the subscribers of topic. It never blocks; ctx is passed to
+// the handlers without its cancellation, so they still run after the request ends.
+// Publish committed changes only: call it after the transaction, not inside it.
+func Publish[E any](ctx context.Context, b *Bus, topic Topic[E], event E) {
+	if b == nil {
+		return
+	}
+	b.publish(ctx, topic.name, event)
+}
+
+func (b *Bus) publish(ctx context.Context, topic string, event any) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	metrics.EventsPublished.WithLabelValues(topic).Inc()
+
+	d := delivery{ctx: context.WithoutCancel(ctx), topic: topic, event: event}
+	for _, subs := range []map[*subscription]struct{}{b.subs[topic], b.subs[""]} {
+		for sub := range subs {
+			select {
+			case sub.queue <- d:
+			default:
+				metrics.EventsDropped.WithLabelValues(topic).Inc()
+				slog.WarnContext(ctx, "event dropped, subscriber queue is full", "topic", topic)
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits until the subscribers handled the queued ones
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			close(sub.queue)
+		}
+	}
+	b.subs = nil
+	b.mu.Unlock()
+	b.wg.Wait()
+}
@@ -0,0 +1,104 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := New(0)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	var orders []int
+	var all []string
+	Subscribe(bus, OrderCreated, func(ctx context.Context, e OrderCreatedEvent) {
+		assert.NoError(t, ctx.Err(), "handlers outlive the publisher's context")
+		mu.Lock()
+		defer mu.Unlock()
+		orders = append(orders, e.Order.ID)
+	})
+	unsubscribe := bus.SubscribeAll(func(_ context.Context, topic string, _ any) {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, topic)
+	})
+	Subscribe(bus, UserUpdated, func(context.Context, UserUpdatedEvent) {
+		panic("boom")
+	})
+
+	cancel()
+	Publish(ctx, bus, OrderCreated, OrderCreatedEvent{Order: model.Order{ID: 1}})
+	Publish(ctx, bus, UserUpdated, UserUpdatedEvent{UserID: 1, Fields: []string{"email"}})
+	Publish(ctx, bus, OrderCreated, OrderCreatedEvent{Order: model.Order{ID: 2}})
+	bus.Close()
+
+	assert.Equal(t, []int{1, 2}, orders, "events are delivered in order, a panicking subscriber does not stop others")
+	assert.Equal(t, []string{"order.created", "user.updated", "order.created"}, all)
+
+	unsubscribe()
+	Publish(ctx, bus, OrderCreated, OrderCreatedEvent{})
+	Publish(ctx, (*Bus)(nil), OrderCreated, OrderCreatedEvent{})
+}
+
+func TestBus_DropsWhenQueueIsFull(t *testing.T) {
+	bus := New(1)
+	release := make(chan struct{})
+	var received []int
+	Subscribe(bus, OrderCreated, func(_ context.Context, e OrderCreatedEvent) {
+		<-release
+		received = append(received, e.Order.ID)
+	})
+
+	ctx := context.Background()
+	for id := 1; id <= 10; id++ {
+		Publish(ctx, bus, OrderCreated, OrderCreatedEvent{Order: model.Order{ID: id}})
+	}
+	close(release)
+	bus.Close()
+	assert.Less(t, len(received), 10, "publishing never blocks on a slow subscriber")
+	assert.Equal(t, 1, received[0])
+}
+
+func TestForward_HTTPBroker(t *testing.T) {
+	type request struct {
+		topic string
+		body  Envelope
+	}
+	requests := make(chan request, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var envelope Envelope
+		json.Unmarshal(data, &envelope)
+		requests <- request{topic: r.Header.Get("X-Event-Topic"), body: envelope}
+	}))
+	defer ts.Close()
+
+	broker, err := NewBroker(Config{Broker: "http", HTTPURL: ts.URL})
+	require.NoError(t, err)
+	bus := New(0)
+	Forward(bus, broker)
+	Publish(context.Background(), bus, UserUpdated, UserUpdatedEvent{UserID: 7, Fields: []string{"region"}})
+	bus.Close()
+
+	got := <-requests
+	assert.Equal(t, "user.updated", got.topic)
+	assert.Equal(t, "user.updated", got.body.Topic)
+	assert.Equal(t, map[string]any{"user_id": float64(7), "fields": []any{"region"}}, got.body.Data)
+
+	_, err = NewBroker(Config{Broker: "http"})
+	assert.Error(t, err)
+	broker, err = NewBroker(Config{Broker: "none"})
+	assert.NoError(t, err)
+	assert.Nil(t, broker)
+}
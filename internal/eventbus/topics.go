@@ -0,0 +1,60 @@
+package eventbus
+
+import (
+	"time"
+
+	"fsanano/go-test/internal/model"
+)
+
+// Topics published by the application
+var (
+	OrderCreated   = NewTopic[OrderCreatedEvent]("order.created")
+	PriceRefreshed = NewTopic[PriceRefreshedEvent]("price.refreshed")
+	UserUpdated    = NewTopic[UserUpdatedEvent]("user.updated")
+	UpstreamFailed = NewTopic[UpstreamFailedEvent]("upstream.failed")
+)
+
+// OrderCreatedEvent is published once a purchase is committed; replayed purchases
+// (same client_order_id) publish nothing
+type OrderCreatedEvent struct {
+	Order model.Order `json:"order"`
+}
+
+// Price refresh sources
+const (
+	// PriceSourceSkinport is a fetch of Skinport items into the cache
+	PriceSourceSkinport = "skinport"
+	// PriceSourcePriceSync is a sync run that changed shop item prices
+	PriceSourcePriceSync = "price_sync"
+)
+
+// PriceRefreshedEvent is published when prices were refreshed: Skinport items fetched
+// for AppID/Currency/View, or shop item prices changed by the price sync (ItemIDs)
+type PriceRefreshedEvent struct {
+	Source   string `json:"source"`
+	AppID    string `json:"app_id,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	View     string `json:"view,omitempty"`
+	// Items is the number of items refreshed
+	Items       int       `json:"items"`
+	ItemIDs     []int     `json:"item_ids,omitempty"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// UserUpdatedEvent is published after a change to a user; Fields names what changed
+// (balance, email, region)
+type UserUpdatedEvent struct {
+	UserID int      `json:"user_id"`
+	Fields []string `json:"fields"`
+}
+
+// UpstreamFailedEvent is published when a request to an upstream service failed, e.g. a
+// Skinport fetch for AppID/Currency/View. Requests cancelled by their caller publish nothing.
+type UpstreamFailedEvent struct {
+	Source   string    `json:"source"`
+	AppID    string    `json:"app_id,omitempty"`
+	Currency string    `json:"currency,omitempty"`
+	View     string    `json:"view,omitempty"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
@@ -0,0 +1,67 @@
+package fx
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const ecbDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// ECB serves the European Central Bank's daily euro reference rates, so its only base
+// currency is EUR
+type ECB struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewECB(url string) *ECB {
+	if url == "" {
+		url = ecbDailyURL
+	}
+	return &ECB{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *ECB) Name() string { return "ecb" }
+
+// ecbEnvelope is the feed, rates nested in Cube elements
+type ecbEnvelope struct {
+	Rates []struct {
+		Currency string  `xml:"currency,attr"`
+		Rate     float64 `xml:"rate,attr"`
+	} `xml:"Cube>Cube>Cube"`
+}
+
+func (p *ECB) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	if base != "EUR" {
+		return nil, fmt.Errorf("ecb rates are from EUR, not %s", base)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ecb request: %w", err)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ecb request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ecb request failed with status %d", resp.StatusCode)
+	}
+
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode ecb rates: %w", err)
+	}
+	if len(envelope.Rates) == 0 {
+		return nil, fmt.Errorf("ecb returned no rates")
+	}
+	rates := make(map[string]float64, len(envelope.Rates))
+	for _, r := range envelope.Rates {
+		rates[r.Currency] = r.Rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,156 @@
+// Package fx converts prices between currencies with exchange rates from a pluggable
+// provider, cached so conversions do not hit the provider on every request.
+package fx
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider returns exchange rates: how many units of each currency one unit of base buys
+type Provider interface {
+	Name() string
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+}
+
+// UnknownRateError is returned for currencies the provider has no rate for
+type UnknownRateError struct {
+	From, To string
+}
+
+func (e *UnknownRateError) Error() string {
+	return fmt.Sprintf("no exchange rate from %s to %s", e.From, e.To)
+}
+
+// Config selects and configures the rate provider
+type Config struct {
+	// Provider is "none", "static" or "ecb"
+	Provider string
+	// StaticRates are the rates of the static provider, "USD=1.08,GBP=0.85" per EUR
+	StaticRates string
+	// ECBURL overrides the ECB daily reference rates feed
+	ECBURL string
+	// CacheTTL is how long fetched rates are used (1h when 0)
+	CacheTTL time.Duration
+}
+
+// NewConverter builds the Converter for cfg.Provider; nil for "none"
+func NewConverter(cfg Config) (*Converter, error) {
+	var provider Provider
+	switch cfg.Provider {
+	case "", "none":
+		return nil, nil
+	case "static":
+		rates, err := ParseRates(cfg.StaticRates)
+		if err != nil {
+			return nil, err
+		}
+		provider = &Static{Base: "EUR", Fixed: rates}
+	case "ecb":
+		provider = NewECB(cfg.ECBURL)
+	default:
+		return nil, fmt.Errorf("unknown fx provider %q (want none, static or ecb)", cfg.Provider)
+	}
+	return NewCachedConverter(provider, cfg.CacheTTL), nil
+}
+
+// ParseRates parses "USD=1.08,GBP=0.85" into upper-case currency codes and rates
+func ParseRates(s string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fx rate %q, want CODE=rate", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid fx rate %q", pair)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates, nil
+}
+
+// Static serves fixed rates from Base
+type Static struct {
+	Base  string
+	Fixed map[string]float64
+}
+
+func (p *Static) Name() string { return "static" }
+
+func (p *Static) Rates(_ context.Context, base string) (map[string]float64, error) {
+	if base != p.Base {
+		return nil, fmt.Errorf("static rates are from %s, not %s", p.Base, base)
+	}
+	return p.Fixed, nil
+}
+
+// Converter converts amounts with the provider's rates, caching the rates of each base
+// currency for a TTL. When a refresh fails, the previous rates are used.
+type Converter struct {
+	provider Provider
+	ttl      time.Duration
+	// now is the cache clock (tests)
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedRates
+}
+
+type cachedRates struct {
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+func NewCachedConverter(provider Provider, ttl time.Duration) *Converter {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &Converter{provider: provider, ttl: ttl, now: time.Now, cache: map[string]cachedRates{}}
+}
+
+// Rate returns how many units of to one unit of from buys
+func (c *Converter) Rate(ctx context.Context, from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	rates, err := c.rates(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+	rate, ok := rates[to]
+	if !ok {
+		return 0, &UnknownRateError{From: from, To: to}
+	}
+	return rate, nil
+}
+
+// rates returns the cached rates of base, fetching them when missing or expired. The
+// lock is held while fetching, so concurrent requests share a single fetch.
+func (c *Converter) rates(ctx context.Context, base string) (map[string]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.cache[base]
+	if ok && c.now().Sub(cached.fetchedAt) < c.ttl {
+		return cached.rates, nil
+	}
+	rates, err := c.provider.Rates(ctx, base)
+	if err != nil {
+		if ok {
+			return cached.rates, nil
+		}
+		return nil, fmt.Errorf("failed to get %s exchange rates: %w", c.provider.Name(), err)
+	}
+	c.cache[base] = cachedRates{rates: rates, fetchedAt: c.now()}
+	return rates, nil
+}
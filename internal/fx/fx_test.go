@@ -0,0 +1,102 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) Rates(_ context.Context, base string) (map[string]float64, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return map[string]float64{"USD": 1.1}, nil
+}
+
+func TestConverter_CachesRates(t *testing.T) {
+	ctx := context.Background()
+	provider := &countingProvider{}
+	now := time.Now()
+	c := NewCachedConverter(provider, time.Hour)
+	c.now = func() time.Time { return now }
+
+	rate, err := c.Rate(ctx, "EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 1.1, rate)
+	_, err = c.Rate(ctx, "EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls, "rates are cached")
+
+	rate, err = c.Rate(ctx, "EUR", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	var unknown *UnknownRateError
+	_, err = c.Rate(ctx, "EUR", "GBP")
+	assert.True(t, errors.As(err, &unknown))
+
+	// Expired rates are refetched, and kept when the refetch fails
+	now = now.Add(2 * time.Hour)
+	provider.err = errors.New("down")
+	rate, err = c.Rate(ctx, "EUR", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 1.1, rate)
+	assert.Equal(t, 2, provider.calls)
+
+	_, err = NewCachedConverter(provider, 0).Rate(ctx, "EUR", "USD")
+	assert.Error(t, err, "nothing cached to fall back to")
+}
+
+func TestECB(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-10-15">
+			<Cube currency="USD" rate="1.0842"/>
+			<Cube currency="GBP" rate="0.8461"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`))
+	}))
+	defer ts.Close()
+
+	rates, err := NewECB(ts.URL).Rates(context.Background(), "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"USD": 1.0842, "GBP": 0.8461}, rates)
+
+	_, err = NewECB(ts.URL).Rates(context.Background(), "USD")
+	assert.Error(t, err)
+}
+
+func TestNewConverter(t *testing.T) {
+	c, err := NewConverter(Config{Provider: "none"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = NewConverter(Config{Provider: "static", StaticRates: "usd=1.08, GBP=0.85"})
+	require.NoError(t, err)
+	rate, err := c.Rate(context.Background(), "EUR", "GBP")
+	require.NoError(t, err)
+	assert.Equal(t, 0.85, rate)
+
+	_, err = NewConverter(Config{Provider: "static", StaticRates: "USD=-1"})
+	assert.Error(t, err)
+	_, err = NewConverter(Config{Provider: "oanda"})
+	assert.Error(t, err)
+}
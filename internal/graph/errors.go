@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// serviceError maps service errors to GraphQL errors with an extensions.code,
+// mirroring the status codes of the REST handlers. Unexpected errors are logged and hidden.
+func serviceError(ctx context.Context, err error) error {
+	code := ""
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidPromoCode),
+		errors.Is(err, repository.ErrInsufficientFunds), errors.Is(err, repository.ErrInsufficientStock):
+		code = "BAD_USER_INPUT"
+	case errors.Is(err, service.ErrPurchaseLimitExceeded):
+		code = "PURCHASE_LIMIT_EXCEEDED"
+	case errors.Is(err, service.ErrDropUnavailable):
+		code = "DROP_UNAVAILABLE"
+	case errors.Is(err, service.ErrPurchaseQueueFull):
+		code = "TOO_MANY_REQUESTS"
+	case errors.Is(err, repository.ErrUserInactive):
+		code = "FORBIDDEN"
+	case errors.Is(err, repository.ErrRetriesExhausted):
+		code = "CONFLICT"
+	default:
+		switch err.Error() {
+		case "item not found", "user not found":
+			code = "NOT_FOUND"
+		case "invalid cursor":
+			code = "BAD_USER_INPUT"
+		}
+	}
+
+	if code == "" {
+		// Mirrors the handlers' 499/504
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			return &gqlerror.Error{Message: "client closed request", Extensions: map[string]any{"code": "CANCELLED"}}
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return &gqlerror.Error{Message: "request timed out", Extensions: map[string]any{"code": "TIMEOUT"}}
+		case repository.IsQueryTimeout(err):
+			return &gqlerror.Error{Message: "database query timed out", Extensions: map[string]any{"code": "TIMEOUT", "retryable": true}}
+		case repository.IsTimeout(err):
+			return &gqlerror.Error{Message: "request timed out", Extensions: map[string]any{"code": "TIMEOUT"}}
+		}
+		slog.ErrorContext(ctx, "graphql resolver failed", "error", err)
+		return &gqlerror.Error{Message: "internal server error", Extensions: map[string]any{"code": "INTERNAL"}}
+	}
+
+	gqlErr := &gqlerror.Error{Message: err.Error(), Extensions: map[string]any{"code": code}}
+	var limitErr *service.PurchaseLimitError
+	if errors.As(err, &limitErr) {
+		gqlErr.Extensions["details"] = limitErr
+	}
+	var dropErr *service.DropError
+	if errors.As(err, &dropErr) {
+		gqlErr.Extensions["details"] = dropErr
+	}
+	var queueErr *service.PurchaseQueueError
+	if errors.As(err, &queueErr) {
+		gqlErr.Extensions["details"] = queueErr
+	}
+	return gqlErr
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"fsanano/go-test/internal/httpx"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// The GraphQL API follows the REST listing conventions (internal/httpx),
+// so cursors have the same format and limits
+var (
+	itemListSpec = httpx.ListSpec{
+		Sortable:     []string{"id", "name", "price", "stock"},
+		DefaultLimit: 100,
+		MaxLimit:     1000,
+	}
+	orderListSpec = httpx.ListSpec{
+		Sortable:     []string{"id", "price", "quantity", "created_at"},
+		DefaultLimit: 50,
+		MaxLimit:     500,
+	}
+)
+
+// listParams translates GraphQL paging arguments into list params; sort fields are camelCase
+func listParams(sort *string, first *int, after *string, spec httpx.ListSpec) (httpx.ListParams, error) {
+	q := make(map[string][]string)
+	if sort != nil {
+		q["sort"] = []string{snakeCase(*sort)}
+	}
+	if first != nil {
+		q["limit"] = []string{strconv.Itoa(*first)}
+	}
+	if after != nil {
+		q["cursor"] = []string{*after}
+	}
+
+	params, err := httpx.ParseListQuery(q, spec)
+	if err != nil {
+		return params, badInput(err.Error())
+	}
+	return params, nil
+}
+
+// nextCursor trims the extra row fetched to detect a following page and returns the cursor for it
+func nextCursor[T any](params httpx.ListParams, rows []T) ([]T, *string) {
+	if params.Limit <= 0 || len(rows) <= params.Limit {
+		return rows, nil
+	}
+	rows = rows[:params.Limit]
+	next, err := params.KeysetCursor(rows[len(rows)-1], "id")
+	if err != nil {
+		return rows, nil
+	}
+	return rows, &next
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func badInput(msg string) *gqlerror.Error {
+	return &gqlerror.Error{Message: msg, Extensions: map[string]any{"code": "BAD_USER_INPUT"}}
+}
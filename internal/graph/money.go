@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalMoney writes model.Money fields of the Float scalar as a number with the
+// currency's decimals, like the REST API (gqlgen.yml binds it to Float)
+func MarshalMoney(m model.Money) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, m.String())
+	})
+}
+
+// UnmarshalMoney reads a Float input into model.Money in the default currency
+func UnmarshalMoney(v any) (model.Money, error) {
+	var m model.Money
+	switch v := v.(type) {
+	case json.Number:
+		err := m.UnmarshalJSON([]byte(v))
+		return m, err
+	case string:
+		err := m.UnmarshalJSON([]byte(v))
+		return m, err
+	case int:
+		return model.NewMoney(float64(v), model.DefaultCurrency), nil
+	case int64:
+		return model.NewMoney(float64(v), model.DefaultCurrency), nil
+	case float64:
+		return model.NewMoney(v, model.DefaultCurrency), nil
+	default:
+		return m, fmt.Errorf("%T is not a valid amount", v)
+	}
+}
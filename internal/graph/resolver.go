@@ -0,0 +1,37 @@
+// Package graph serves the GraphQL API at /v1/graphql.
+// Schema changes: edit schema.graphqls and run `go generate ./internal/graph`.
+package graph
+
+//go:generate go tool gqlgen generate --config gqlgen.yml
+
+import (
+	"context"
+
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+type Resolver struct {
+	shop            *service.ShopService
+	skinportClients *skinport.Factory
+}
+
+func NewResolver(shop *service.ShopService, skinportClients *skinport.Factory) *Resolver {
+	return &Resolver{shop: shop, skinportClients: skinportClients}
+}
+
+func (r *Resolver) userOrders(ctx context.Context, userID int, sort *string, first *int, after *string) (*OrderConnection, error) {
+	params, err := listParams(sort, first, after, orderListSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := r.shop.ListUserOrders(ctx, userID, params.ListOptions)
+	if err != nil {
+		return nil, serviceError(ctx, err)
+	}
+
+	conn := &OrderConnection{}
+	conn.Nodes, conn.NextCursor = nextCursor(params, orders)
+	return conn, nil
+}
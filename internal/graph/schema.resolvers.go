@@ -0,0 +1,137 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"errors"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/service/skinport"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// BuyItem is the resolver for the buyItem field.
+func (r *mutationResolver) BuyItem(ctx context.Context, input BuyItemInput) (*model.Order, error) {
+	quantity := 1
+	if input.Quantity != nil {
+		quantity = *input.Quantity
+	}
+	if quantity <= 0 {
+		return nil, badInput("quantity must be positive")
+	}
+
+	p := service.BuyParams{UserID: input.UserID, ItemID: input.ItemID, Quantity: quantity}
+	if input.PromoCode != nil {
+		p.PromoCode = *input.PromoCode
+	}
+
+	order, err := r.shop.BuyItem(ctx, p)
+	if err != nil {
+		return nil, serviceError(ctx, err)
+	}
+	return order, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id int) (*model.User, error) {
+	user, err := r.shop.GetUser(ctx, id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, nil
+		}
+		return nil, serviceError(ctx, err)
+	}
+	return user, nil
+}
+
+// Items is the resolver for the items field.
+func (r *queryResolver) Items(ctx context.Context, category *string, tags []string, sort *string, first *int, after *string) (*ItemConnection, error) {
+	params, err := listParams(sort, first, after, itemListSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := model.ItemFilter{Tags: tags}
+	if category != nil {
+		filter.Category = *category
+	}
+
+	items, err := r.shop.ListItems(ctx, filter, params.ListOptions)
+	if err != nil {
+		return nil, serviceError(ctx, err)
+	}
+
+	conn := &ItemConnection{}
+	conn.Nodes, conn.NextCursor = nextCursor(params, items)
+	return conn, nil
+}
+
+// Orders is the resolver for the orders field.
+func (r *queryResolver) Orders(ctx context.Context, userID int, sort *string, first *int, after *string) (*OrderConnection, error) {
+	return r.userOrders(ctx, userID, sort, first, after)
+}
+
+// SkinportItems is the resolver for the skinportItems field.
+func (r *queryResolver) SkinportItems(ctx context.Context, appID *string, currency *string, first *int) ([]skinport.ResponseItem, error) {
+	var app, cur string
+	if appID != nil {
+		app = *appID
+	}
+	if currency != nil {
+		cur = *currency
+	}
+
+	client, err := r.skinportClients.Client(ctx)
+	var items []skinport.ResponseItem
+	if err == nil {
+		items, err = client.GetAllItems(ctx, app, cur)
+	}
+	if errors.Is(err, skinport.ErrUnsupportedApp) || errors.Is(err, skinport.ErrUnsupportedCurrency) {
+		return nil, badInput(err.Error())
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "graphql skinport items failed", "error", err)
+		return nil, &gqlerror.Error{Message: "failed to fetch skinport items", Extensions: map[string]any{"code": "UPSTREAM"}}
+	}
+
+	if first == nil {
+		return items, nil
+	}
+	if *first <= 0 {
+		return nil, badInput("first must be positive")
+	}
+	// A truncated list needs a stable order; the client returns a copy, sort it in place
+	sorted := items
+	slices.SortFunc(sorted, func(a, b skinport.ResponseItem) int {
+		return strings.Compare(a.MarketHashName, b.MarketHashName)
+	})
+	if len(sorted) > *first {
+		sorted = sorted[:*first]
+	}
+	return sorted, nil
+}
+
+// Orders is the resolver for the orders field.
+func (r *userResolver) Orders(ctx context.Context, obj *model.User, sort *string, first *int, after *string) (*OrderConnection, error) {
+	return r.userOrders(ctx, obj.ID, sort, first, after)
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
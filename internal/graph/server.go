@@ -0,0 +1,35 @@
+package graph
+
+import (
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// NewServer returns the GraphQL HTTP handler. complexityLimit bounds the number of
+// fields a single query may select (0 disables the limit).
+func NewServer(resolver *Resolver, complexityLimit int) http.Handler {
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: resolver}))
+
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](100)})
+	if complexityLimit > 0 {
+		srv.Use(extension.FixedComplexityLimit(complexityLimit))
+	}
+
+	return srv
+}
+
+// NewPlayground serves the GraphiQL playground for the endpoint at path
+func NewPlayground(path string) http.Handler {
+	return playground.Handler("GraphQL playground", path)
+}
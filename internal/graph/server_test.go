@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, srv http.Handler, q string) gqlResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": q})
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp gqlResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestServer_InvalidArgumentsAreRejectedBeforeServices(t *testing.T) {
+	// No services: every query below must fail validation first
+	srv := NewServer(NewResolver(nil, nil), 200)
+
+	resp := query(t, srv, `{ items(sort: "-secret") { nodes { id } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+
+	resp = query(t, srv, `{ orders(userId: 1, after: "bogus") { nodes { id } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+
+	resp = query(t, srv, `mutation { buyItem(input: {userId: 1, itemId: 1, quantity: 0}) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "quantity must be positive", resp.Errors[0].Message)
+
+	resp = query(t, srv, `{ items { nodes { secret } } }`)
+	require.NotEmpty(t, resp.Errors)
+}
+
+func TestServer_ComplexityLimit(t *testing.T) {
+	srv := NewServer(NewResolver(nil, nil), 2)
+
+	resp := query(t, srv, `{ items { nodes { id name price stock } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "complexity")
+}
+
+func TestListParams(t *testing.T) {
+	sort, first := "-createdAt", 2
+	params, err := listParams(&sort, &first, nil, orderListSpec)
+	require.NoError(t, err)
+	assert.Equal(t, []model.SortField{{Field: "created_at", Desc: true}}, params.Sort)
+	assert.Equal(t, 2, params.Limit)
+
+	rows := []model.Order{{ID: 3}, {ID: 2}, {ID: 1}}
+	page, next := nextCursor(params, rows)
+	assert.Len(t, page, 2)
+	require.NotNil(t, next)
+
+	// The cursor continues after the last returned row
+	params, err = listParams(&sort, &first, next, orderListSpec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001-01-01T00:00:00Z", "2"}, params.After)
+
+	_, next = nextCursor(params, rows[:2])
+	assert.Nil(t, next)
+}
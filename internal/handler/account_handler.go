@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeactivateUser blocks the user from buying, see service.ShopService.DeactivateUser
+func (h *ShopHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeAccount(w, r, h.svc.DeactivateUser)
+}
+
+// ReactivateUser lets a deactivated user buy again
+func (h *ShopHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeAccount(w, r, h.svc.ReactivateUser)
+}
+
+// DeleteUser anonymizes the user (GDPR erasure), see service.ShopService.DeleteUser
+func (h *ShopHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.changeAccount(w, r, h.svc.DeleteUser)
+}
+
+func (h *ShopHandler) changeAccount(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID int) error) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := change(r.Context(), userID); err != nil {
+		switch {
+		case err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrUserDeleted), errors.Is(err, service.ErrUserHasPendingPayouts):
+			writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	user, err := h.svc.GetUser(r.Context(), userID)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Directions of a BalanceAdjustmentRequest
+const (
+	AdjustmentCredit = "credit"
+	AdjustmentDebit  = "debit"
+)
+
+type BalanceAdjustmentRequest struct {
+	// Type is credit or debit
+	Type string `json:"type"`
+	// Amount is positive, Type gives its sign
+	Amount     model.Money `json:"amount"`
+	ReasonCode string      `json:"reason_code"`
+	Reason     string      `json:"reason"`
+}
+
+// AdjustUserBalance credits or debits the user's balance with a reason code and a
+// reason, see service.ShopService.AdjustBalance
+func (h *ShopHandler) AdjustUserBalance(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req BalanceAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Amount.Amount <= 0 {
+		writeError(w, r, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	delta := req.Amount
+	switch req.Type {
+	case AdjustmentCredit:
+	case AdjustmentDebit:
+		delta = delta.Neg()
+	default:
+		writeError(w, r, http.StatusBadRequest, "type must be credit or debit")
+		return
+	}
+
+	adj, err := h.svc.AdjustBalance(r.Context(), model.BalanceAdjustment{
+		UserID: userID, Delta: delta, ReasonCode: req.ReasonCode, Reason: req.Reason, Source: "admin_api",
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrInsufficientFunds):
+			writeError(w, r, http.StatusConflict, "debit exceeds the user's balance")
+		case errors.Is(err, repository.ErrRetriesExhausted):
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "adjustment conflicted with concurrent requests, please retry")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, adj)
+}
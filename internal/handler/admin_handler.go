@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+)
+
+// maxImportSize caps uploaded CSV files
+const maxImportSize = 32 << 20
+
+type AdminHandler struct {
+	statsSvc       *service.StatsService
+	importSvc      *service.BalanceImportService
+	orderImportSvc *service.OrderImportService
+	itemImportSvc  *service.ItemImportService
+	auditSvc       *service.AuditService
+}
+
+func NewAdminHandler(statsSvc *service.StatsService, importSvc *service.BalanceImportService, orderImportSvc *service.OrderImportService, itemImportSvc *service.ItemImportService, auditSvc *service.AuditService) *AdminHandler {
+	return &AdminHandler{statsSvc: statsSvc, importSvc: importSvc, orderImportSvc: orderImportSvc, itemImportSvc: itemImportSvc, auditSvc: auditSvc}
+}
+
+// RequireAdmin only lets through requests carrying "Authorization: Bearer <token>".
+// An empty token disables the admin API entirely.
+func RequireAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, r, http.StatusForbidden, "admin api disabled")
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeError(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			// Operators sharing the token identify themselves for the audit log
+			actor := "admin"
+			if name := strings.TrimSpace(r.Header.Get("X-Actor")); name != "" {
+				actor = "admin:" + name
+			}
+
+			next.ServeHTTP(w, r.WithContext(audit.WithActor(r.Context(), actor)))
+		})
+	}
+}
+
+// GetStats returns sales analytics: ?window=24h|7d|30d|all&top=10
+func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	window, err := service.ParseWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	top := 10
+	if v := r.URL.Query().Get("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top <= 0 || top > 100 {
+			writeError(w, r, http.StatusBadRequest, "top must be between 1 and 100")
+			return
+		}
+	}
+
+	stats, err := h.statsSvc.GetSalesStats(r.Context(), window, top)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ImportBalances applies a CSV of user_id,delta,reason balance adjustments.
+// The body is either the raw CSV or a multipart form with a "file" field.
+// The per-row report is returned as a CSV download, or as JSON with ?format=json;
+// ?dry_run=true only validates the file.
+func (h *AdminHandler) ImportBalances(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	body := r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "missing file field")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.importSvc.ImportAdjustments(r.Context(), body, dryRun)
+	if err != nil && report == nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// Processing stopped midway, report what happened so far
+		slog.ErrorContext(r.Context(), "balance import aborted", "error", err)
+	}
+	if reportURL, err := h.importSvc.StoreReport(r.Context(), report); err == nil {
+		report.ReportURL = reportURL
+		w.Header().Set("X-Import-Report-URL", reportURL)
+	} else {
+		slog.WarnContext(r.Context(), "failed to store balance import report", "error", err)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="balance-import-report.csv"`)
+	w.Header().Set("X-Import-Total", strconv.Itoa(report.Total))
+	w.Header().Set("X-Import-Applied", strconv.Itoa(report.Applied))
+	w.Header().Set("X-Import-Failed", strconv.Itoa(report.Failed))
+	w.WriteHeader(http.StatusOK)
+	report.WriteCSV(w)
+}
+
+// ImportOrders copies historical orders from another system, as CSV or NDJSON.
+// The body is either the raw file or a multipart form with a "file" field; NDJSON is
+// recognised by an application/x-ndjson or application/jsonl Content-Type (of the body or
+// the file part) or a .ndjson/.jsonl file name. The report is returned like ImportBalances'.
+func (h *AdminHandler) ImportOrders(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	body := r.Body
+	format := importFormat(r.Header.Get("Content-Type"), "")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, fh, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "missing file field")
+			return
+		}
+		defer file.Close()
+		body = file
+		format = importFormat(fh.Header.Get("Content-Type"), fh.Filename)
+	}
+
+	report, err := h.orderImportSvc.ImportOrders(r.Context(), body, format, dryRun)
+	if err != nil && report == nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// Processing stopped midway, report what happened so far
+		slog.WarnContext(r.Context(), "order import aborted", "error", err)
+	}
+	if reportURL, err := h.orderImportSvc.StoreReport(r.Context(), report); err == nil {
+		report.ReportURL = reportURL
+		w.Header().Set("X-Import-Report-URL", reportURL)
+	} else {
+		slog.WarnContext(r.Context(), "failed to store order import report", "error", err)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="order-import-report.csv"`)
+	w.Header().Set("X-Import-Total", strconv.Itoa(report.Total))
+	w.Header().Set("X-Import-Applied", strconv.Itoa(report.Applied))
+	w.Header().Set("X-Import-Failed", strconv.Itoa(report.Failed))
+	w.WriteHeader(http.StatusOK)
+	report.WriteCSV(w)
+}
+
+// ImportItems creates catalogue items from a CSV in the format of ExportItems, or with
+// ?upsert=true updates the items of the same name. The body and the report are handled
+// like ImportBalances'.
+func (h *AdminHandler) ImportItems(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	upsert, _ := strconv.ParseBool(r.URL.Query().Get("upsert"))
+
+	body := r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "missing file field")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.itemImportSvc.ImportItems(r.Context(), body, upsert, dryRun)
+	if err != nil && report == nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// Processing stopped midway, report what happened so far
+		slog.WarnContext(r.Context(), "item import aborted", "error", err)
+	}
+	if reportURL, err := h.itemImportSvc.StoreReport(r.Context(), report); err == nil {
+		report.ReportURL = reportURL
+		w.Header().Set("X-Import-Report-URL", reportURL)
+	} else {
+		slog.WarnContext(r.Context(), "failed to store item import report", "error", err)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="item-import-report.csv"`)
+	w.Header().Set("X-Import-Total", strconv.Itoa(report.Total))
+	w.Header().Set("X-Import-Applied", strconv.Itoa(report.Applied))
+	w.Header().Set("X-Import-Failed", strconv.Itoa(report.Failed))
+	w.WriteHeader(http.StatusOK)
+	report.WriteCSV(w)
+}
+
+// ExportItems streams the whole catalogue as CSV, ready to be edited and imported back
+func (h *AdminHandler) ExportItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="items-%s.csv"`, time.Now().UTC().Format("20060102")))
+
+	// Rows are streamed as the database produces them, so once the first bytes are out
+	// a failure can only cut the download short
+	ew := &exportWriter{w: w}
+	if err := h.itemImportSvc.ExportItems(r.Context(), ew); err != nil {
+		if !ew.written {
+			w.Header().Del("Content-Disposition")
+			writeInternalError(w, r, err)
+			return
+		}
+		slog.ErrorContext(r.Context(), "item export aborted", "error", err)
+	}
+}
+
+// exportWriter records whether a streamed download has started
+type exportWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.written = true
+	return e.w.Write(p)
+}
+
+// importFormat picks the input format of an upload from its media type or file name
+func importFormat(contentType, filename string) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"),
+		strings.HasSuffix(filename, ".ndjson"), strings.HasSuffix(filename, ".jsonl"):
+		return service.OrderImportNDJSON
+	default:
+		return service.OrderImportCSV
+	}
+}
+
+// ListAuditLog returns audit entries for compliance review, newest first.
+// Filters: actor, action, entity_type, entity_id, since/until (RFC 3339), limit, before_id.
+func (h *AdminHandler) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := model.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "until must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			writeError(w, r, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.BeforeID <= 0 {
+			writeError(w, r, http.StatusBadRequest, "before_id must be a positive integer")
+			return
+		}
+	}
+
+	entries, err := h.auditSvc.List(r.Context(), filter)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	resp := map[string]any{"entries": entries}
+	if len(entries) > 0 {
+		resp["next_before_id"] = entries[len(entries)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"fsanano/go-test/internal/service"
+)
+
+type CatalogHandler struct {
+	svc *service.CatalogSnapshotService
+}
+
+func NewCatalogHandler(svc *service.CatalogSnapshotService) *CatalogHandler {
+	return &CatalogHandler{svc: svc}
+}
+
+// GetSnapshotMeta describes the latest static catalogue of the request's shop published
+// to the CDN
+func (h *CatalogHandler) GetSnapshotMeta(w http.ResponseWriter, r *http.Request) {
+	if h.svc == nil {
+		writeError(w, r, http.StatusNotFound, "catalog snapshots disabled")
+		return
+	}
+
+	meta, err := h.svc.Meta(r.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrSnapshotNotPublished) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+meta.Version+`"`)
+	writeJSON(w, http.StatusOK, meta)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CategoryHandler struct {
+	svc *service.CategoryService
+}
+
+func NewCategoryHandler(svc *service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{svc: svc}
+}
+
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.svc.ListCategories(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.svc.ListTags(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var category model.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.svc.CreateCategory(r.Context(), &category); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err.Error() == "category already exists" {
+			writeError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, category)
+}
+
+type UpdateItemTaxonomyRequest struct {
+	Category *string  `json:"category"` // Optional, "" removes the category
+	Tags     []string `json:"tags"`     // Optional, [] removes all tags
+}
+
+func (h *CategoryHandler) UpdateItemTaxonomy(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req UpdateItemTaxonomyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.svc.UpdateItemTaxonomy(r.Context(), itemID, req.Category, req.Tags); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if msg := err.Error(); msg == "item not found" || msg == "item or category not found" {
+			writeError(w, r, http.StatusNotFound, msg)
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/payments"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxWebhookBytes bounds webhook payloads; Stripe events are a few kilobytes
+const maxWebhookBytes = 64 << 10
+
+type DepositHandler struct {
+	svc *service.DepositService
+}
+
+func NewDepositHandler(svc *service.DepositService) *DepositHandler {
+	return &DepositHandler{svc: svc}
+}
+
+type DepositRequest struct {
+	Amount model.Money `json:"amount"`
+}
+
+// CreateDeposit opens a Stripe Checkout session for a balance deposit; the user pays on
+// checkout_url and the balance is credited by the webhook
+func (h *DepositHandler) CreateDeposit(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req DepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	checkout, err := h.svc.CreateDeposit(r.Context(), userID, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, checkout)
+}
+
+// StripeWebhook receives Stripe events; only requests signed with the endpoint secret
+// are applied. Errors make Stripe redeliver the event.
+func (h *DepositHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.svc.HandleWebhook(r.Context(), payload, r.Header.Get("Stripe-Signature")); err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"received": true})
+}
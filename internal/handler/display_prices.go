@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/i18n"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+// displayLocale returns the locale to format display prices for: ?locale=, else the
+// Accept-Language of requests with ?display_prices=true. It returns "" when the request
+// asks for no display prices and answers a 400 (ok false) for unsupported locales.
+func displayLocale(w http.ResponseWriter, r *http.Request) (locale string, ok bool) {
+	q := r.URL.Query()
+	if v := q.Get("locale"); v != "" {
+		locale, supported := i18n.ResolveLocale(v)
+		if !supported {
+			writeErrorDetails(w, r, http.StatusBadRequest, "unsupported locale",
+				map[string]any{"locale": v, "allowed": i18n.Locales()})
+			return "", false
+		}
+		return locale, true
+	}
+	if v := q.Get("display_prices"); v != "" {
+		display, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "display_prices must be true or false")
+			return "", false
+		}
+		if display {
+			w.Header().Add("Vary", "Accept-Language")
+			return i18n.NegotiateLocale(r.Header.Get("Accept-Language")), true
+		}
+	}
+	return "", true
+}
+
+// itemView is a shop item with its price formatted for display
+type itemView struct {
+	model.Item
+	PriceDisplay string `json:"price_display,omitempty"`
+}
+
+func displayItems(items []model.Item, locale string) []itemView {
+	views := make([]itemView, len(items))
+	for i, item := range items {
+		views[i] = itemView{Item: item, PriceDisplay: i18n.FormatMoney(locale, item.Price)}
+	}
+	return views
+}
+
+// skinportItemView is a Skinport item with its prices formatted for display
+type skinportItemView struct {
+	skinport.ResponseItem
+	MinPriceTradableDisplay    string `json:"min_price_tradable_display,omitempty"`
+	MinPriceNonTradableDisplay string `json:"min_price_non_tradable_display,omitempty"`
+}
+
+func displaySkinportItem(item skinport.ResponseItem, locale string) skinportItemView {
+	view := skinportItemView{ResponseItem: item}
+	if locale == "" {
+		return view
+	}
+	format := func(price *float64) string {
+		if price == nil {
+			return ""
+		}
+		return i18n.FormatMoney(locale, model.NewMoney(*price, item.Currency))
+	}
+	view.MinPriceTradableDisplay = format(item.MinPriceTradable)
+	view.MinPriceNonTradableDisplay = format(item.MinPriceNonTradable)
+	return view
+}
+
+func displaySkinportItems(items []skinport.ResponseItem, locale string) []skinportItemView {
+	views := make([]skinportItemView, len(items))
+	for i, item := range items {
+		views[i] = displaySkinportItem(item, locale)
+	}
+	return views
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type DropHandler struct {
+	svc *service.DropService
+}
+
+func NewDropHandler(svc *service.DropService) *DropHandler {
+	return &DropHandler{svc: svc}
+}
+
+type CreateDropRequest struct {
+	ItemID       int        `json:"item_id"`
+	Name         string     `json:"name"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"` // Optional, the drop lasts until sold out
+	Stock        int        `json:"stock"`
+	PerUserLimit int        `json:"per_user_limit"`
+}
+
+// CreateDrop schedules a limited release of an item (admin)
+func (h *DropHandler) CreateDrop(w http.ResponseWriter, r *http.Request) {
+	var req CreateDropRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	drop := &model.Drop{
+		ItemID:       req.ItemID,
+		Name:         req.Name,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		Stock:        req.Stock,
+		PerUserLimit: req.PerUserLimit,
+	}
+	if err := h.svc.CreateDrop(r.Context(), drop); err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "item not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrDropConflict):
+			writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, drop)
+}
+
+// ListDrops returns the drops that have not ended, by start time
+func (h *DropHandler) ListDrops(w http.ResponseWriter, r *http.Request) {
+	drops, err := h.svc.ListDrops(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, drops)
+}
+
+// GetDrop returns the drop's state, remaining stock and the seconds until it starts and
+// ends, for countdowns. Clients poll it, so it is not cached.
+func (h *DropHandler) GetDrop(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid drop id")
+		return
+	}
+
+	drop, err := h.svc.GetDrop(r.Context(), id)
+	if err != nil {
+		if err.Error() == "drop not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, drop)
+}
+
+// dropErrorStatus answers a purchase refused by a drop: 409 once sold out, 403 before
+// the start or beyond the per-user cap
+func dropErrorStatus(err *service.DropError) int {
+	if err.Reason == service.DropReasonSoldOut {
+		return http.StatusConflict
+	}
+	return http.StatusForbidden
+}
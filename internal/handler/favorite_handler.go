@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type FavoriteHandler struct {
+	svc *service.FavoriteService
+}
+
+func NewFavoriteHandler(svc *service.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{svc: svc}
+}
+
+type FavoriteRequest struct {
+	MarketHashName string `json:"market_hash_name"`
+	// AlertBelow is optional, see model.Favorite
+	AlertBelow *float64 `json:"alert_below"`
+}
+
+// AddFavorite follows a Skinport item by its market_hash_name, optionally with a price alert
+func (h *FavoriteHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req FavoriteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	favorite, err := h.svc.AddFavorite(r.Context(), userID, req.MarketHashName, req.AlertBelow)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+// RemoveFavorite unfollows the item named by ?market_hash_name=, names may contain slashes
+func (h *FavoriteHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.svc.RemoveFavorite(r.Context(), userID, r.URL.Query().Get("market_hash_name")); err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "favorite not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFavorites returns the user's favorites with current Skinport prices
+// for ?app_id= and ?currency= (defaults as for /skinport/items)
+func (h *FavoriteHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	favorites, err := h.svc.ListFavorites(r.Context(), userID, r.URL.Query().Get("app_id"), r.URL.Query().Get("currency"))
+	if err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, favorites)
+}
@@ -1,8 +1,19 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
 
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/buildinfo"
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/fx"
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/repository"
 	"fsanano/go-test/internal/service/skinport"
 
 	"github.com/go-chi/chi/v5"
@@ -10,23 +21,144 @@ import (
 )
 
 type Handler struct {
-	router         *chi.Mux
-	skinportClient *skinport.Client
-	shopHandler    *ShopHandler
+	router           *chi.Mux
+	skinportClients  *skinport.Factory
+	fx               *fx.Converter
+	shopHandler      *ShopHandler
+	adminHandler     *AdminHandler
+	catalogHandler   *CatalogHandler
+	promoHandler     *PromoHandler
+	dropHandler      *DropHandler
+	categoryHandler  *CategoryHandler
+	inventoryHandler *InventoryHandler
+	leaderboard      *LeaderboardHandler
+	favoriteHandler  *FavoriteHandler
+	telegramHandler  *TelegramHandler
+	payoutHandler    *PayoutHandler
+	depositHandler   *DepositHandler
+	itemMedia        *ItemMediaHandler
+	receipts         *ReceiptHandler
+	priceSync        *PriceSyncHandler
+	steam            *SteamHandler
+	storage          http.Handler
+	orderEvents      *OrderEventsHandler
+	graphql          http.Handler
+	playground       http.Handler
+	tenants          *TenantHandler
+	tenantOptions    TenantOptions
+	adminToken       string
+	requestTimeout   time.Duration
+	queryTimeout     time.Duration
+	ready            func() error
+	observePurchase  func(ctx context.Context, failed bool)
+	debug            bool
 }
 
-func NewHandler(skinportClient *skinport.Client, shopHandler *ShopHandler) *Handler {
+// Dependencies groups everything the router needs to serve requests
+type Dependencies struct {
+	SkinportClients  *skinport.Factory
+	ShopHandler      *ShopHandler
+	AdminHandler     *AdminHandler
+	CatalogHandler   *CatalogHandler
+	PromoHandler     *PromoHandler
+	CategoryHandler  *CategoryHandler
+	InventoryHandler *InventoryHandler
+	Leaderboard      *LeaderboardHandler
+	FavoriteHandler  *FavoriteHandler
+	TelegramHandler  *TelegramHandler
+	PayoutHandler    *PayoutHandler
+	// FX converts Skinport prices for ?convert_to=; nil disables conversion
+	FX *fx.Converter
+	// DepositHandler serves Stripe deposits and their webhook; nil disables them
+	DepositHandler *DepositHandler
+	// DropHandler serves limited releases and their countdowns; nil disables them
+	DropHandler *DropHandler
+	// ItemMedia serves item metadata and images; nil disables them
+	ItemMedia *ItemMediaHandler
+	// Receipts serves PDF order receipts; nil disables them
+	Receipts *ReceiptHandler
+	// PriceSync manages Skinport price mappings and syncs; nil disables it
+	PriceSync *PriceSyncHandler
+	// Steam serves Steam login and account links; nil disables them
+	Steam *SteamHandler
+	// Storage serves signed URLs of the local blob storage under /storage; nil disables it
+	Storage http.Handler
+	// OrderEvents serves order event streams; nil disables them
+	OrderEvents *OrderEventsHandler
+	// GraphQL serves /v1/graphql; nil disables it
+	GraphQL http.Handler
+	// GraphQLPlayground serves /v1/graphql/playground; nil disables it
+	GraphQLPlayground http.Handler
+	// Tenants scopes each request to the shop it selects and serves /v1/admin/tenants;
+	// nil serves every request unscoped
+	Tenants *TenantHandler
+	// TenantOptions selects the shop of a request
+	TenantOptions TenantOptions
+	// AdminToken guards /v1/admin; empty disables the admin API
+	AdminToken string
+	// RequestTimeout is the deadline of each request except order event streams; 0 disables it
+	RequestTimeout time.Duration
+	// QueryTimeout bounds each database query of a request, which then fails with a
+	// retryable 504 instead of using up the whole RequestTimeout; 0 disables it
+	QueryTimeout time.Duration
+	// RequestLog configures the access log and its body sampling
+	RequestLog RequestLogOptions
+	// Debug serves pprof profiles and expvar variables under /debug, behind AdminToken
+	Debug bool
+	// Ready reports why the instance cannot serve requests, e.g. an unreachable database,
+	// failing /readyz; nil is always ready
+	Ready func() error
+	// ObservePurchase is told the outcome of every purchase, failed for 5xx responses,
+	// e.g. to alert on an error rate spike; nil observes nothing
+	ObservePurchase func(ctx context.Context, failed bool)
+	// ErrorReporter is sent panics, 500 responses and the errors the services capture
+	// while serving a request; nil reports nothing
+	ErrorReporter errreport.Reporter
+}
+
+func NewHandler(deps Dependencies) *Handler {
 	router := chi.NewRouter()
 
 	// Middleware
-	router.Use(middleware.Logger)
+	router.Use(requestID)
+	router.Use(RequestLogger(deps.RequestLog))
 	router.Use(middleware.Recoverer)
-	router.Use(middleware.RequestID)
+	if deps.ErrorReporter != nil {
+		router.Use(reportErrors(deps.ErrorReporter))
+	}
 
 	h := &Handler{
-		router:         router,
-		skinportClient: skinportClient,
-		shopHandler:    shopHandler,
+		router:           router,
+		skinportClients:  deps.SkinportClients,
+		fx:               deps.FX,
+		shopHandler:      deps.ShopHandler,
+		adminHandler:     deps.AdminHandler,
+		catalogHandler:   deps.CatalogHandler,
+		promoHandler:     deps.PromoHandler,
+		dropHandler:      deps.DropHandler,
+		categoryHandler:  deps.CategoryHandler,
+		inventoryHandler: deps.InventoryHandler,
+		leaderboard:      deps.Leaderboard,
+		favoriteHandler:  deps.FavoriteHandler,
+		telegramHandler:  deps.TelegramHandler,
+		payoutHandler:    deps.PayoutHandler,
+		depositHandler:   deps.DepositHandler,
+		itemMedia:        deps.ItemMedia,
+		receipts:         deps.Receipts,
+		priceSync:        deps.PriceSync,
+		steam:            deps.Steam,
+		storage:          deps.Storage,
+		orderEvents:      deps.OrderEvents,
+		graphql:          deps.GraphQL,
+		playground:       deps.GraphQLPlayground,
+		tenants:          deps.Tenants,
+		tenantOptions:    deps.TenantOptions,
+		adminToken:       deps.AdminToken,
+		requestTimeout:   deps.RequestTimeout,
+		queryTimeout:     deps.QueryTimeout,
+		ready:            deps.Ready,
+		observePurchase:  deps.ObservePurchase,
+		debug:            deps.Debug,
 	}
 
 	h.registerRoutes()
@@ -34,17 +166,306 @@ func NewHandler(skinportClient *skinport.Client, shopHandler *ShopHandler) *Hand
 }
 
 func (h *Handler) registerRoutes() {
-	h.router.Route("/v1", func(r chi.Router) {
-		r.Get("/health", h.HealthCheck)
+	h.router.Handle("/metrics", metrics.Handler())
+	// Probes for orchestrators: /healthz while the process runs, /readyz while it can serve
+	h.router.Get("/healthz", h.HealthCheck)
+	h.router.Get("/readyz", h.ReadyCheck)
+	// Profiles of production instances, e.g. /debug/pprof/heap during a large Skinport
+	// fetch, and expvar's /debug/vars. They stay outside the request deadline: CPU
+	// profiles and traces take ?seconds= to record.
+	if h.debug {
+		h.router.Route("/debug", func(r chi.Router) {
+			r.Use(RequireAdmin(h.adminToken))
+			r.Mount("/", middleware.Profiler())
+		})
+	}
+	if h.storage != nil {
+		h.router.Handle("/storage/*", http.StripPrefix("/storage", h.storage))
+	}
+
+	// Versions share handlers and services, handlers branch on apiVersion where shapes differ
+	h.router.Route("/v1", func(r chi.Router) { h.registerVersion(r, APIv1) })
+	h.router.Route("/v2", func(r chi.Router) { h.registerVersion(r, APIv2) })
+}
+
+func (h *Handler) registerVersion(r chi.Router, version APIVersion) {
+	r.Use(withAPIVersion(version))
+
+	// The deployed build is the same for every shop and needs no database
+	r.Get("/version", h.GetVersion)
+
+	// Stripe calls from no shop's host, deposits are found across shops
+	if h.depositHandler != nil {
+		r.With(requestTimeout(h.requestTimeout), queryTimeout(h.queryTimeout)).
+			Post("/payments/stripe/webhook", h.depositHandler.StripeWebhook)
+	}
+
+	r.Group(func(r chi.Router) {
+		if h.tenants != nil {
+			r.Use(tenantScope(h.tenants.svc.ResolveTenant, h.tenantOptions))
+		}
+
+		// Streams are long-lived, they stay outside the request deadline
+		if h.orderEvents != nil {
+			r.Get("/users/{id}/orders/stream", h.orderEvents.Stream)
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(requestTimeout(h.requestTimeout))
+			r.Use(queryTimeout(h.queryTimeout))
+			h.registerAPIRoutes(r, version)
+		})
+	})
+}
+
+func (h *Handler) registerAPIRoutes(r chi.Router, version APIVersion) {
+	r.Get("/health", h.HealthCheck)
+
+	r.Route("/skinport", func(r chi.Router) {
+		r.Get("/items", h.GetSkinportItems)
+		r.Post("/items/lookup", h.LookupSkinportItems)
+		r.Get("/items/by-name", h.GetSkinportItemByName)
+		r.Get("/items/{slug}", h.GetSkinportItem)
+		r.Get("/stats", h.GetSkinportStats)
+		r.Get("/snapshots", h.GetSkinportSnapshots)
+		r.Get("/diff", h.GetSkinportDiff)
+		r.Get("/apps", h.GetSkinportApps)
+	})
+
+	r.Get("/catalog/snapshot", h.catalogHandler.GetSnapshotMeta)
+
+	r.Get("/items", h.shopHandler.ListItems)
+	r.Get("/items/{id}/price-tiers", h.shopHandler.ListPriceTiers)
+	if h.itemMedia != nil {
+		r.Get("/items/{id}/image", h.itemMedia.GetItemImage)
+	}
+	r.Get("/categories", h.categoryHandler.ListCategories)
+	r.Get("/tags", h.categoryHandler.ListTags)
+	r.Get("/stats/leaderboard", h.leaderboard.GetLeaderboard)
+	if h.dropHandler != nil {
+		r.Get("/drops", h.dropHandler.ListDrops)
+		r.Get("/drops/{id}", h.dropHandler.GetDrop)
+	}
+	r.Get("/users/{id}", h.shopHandler.GetUser)
+	r.Get("/users/{id}/orders", h.shopHandler.ListUserOrders)
+	r.Post("/users/{id}/orders/{orderID}/refund", h.shopHandler.RefundOrder)
+	if h.receipts != nil {
+		r.Get("/orders/{id}/receipt", h.receipts.GetReceipt)
+	}
+	r.Post("/quotes", h.shopHandler.CreateQuote)
+	r.With(observeOutcome(h.observePurchase)).Post("/buy", h.shopHandler.BuyItem)
+	r.Get("/users/{id}/inventory", h.inventoryHandler.ListUserInventory)
+	r.Get("/users/{id}/portfolio", h.inventoryHandler.GetPortfolio)
+	r.Post("/inventory/transfer", h.inventoryHandler.Transfer)
+	r.Get("/users/{id}/favorites", h.favoriteHandler.ListFavorites)
+	r.Post("/users/{id}/favorites", h.favoriteHandler.AddFavorite)
+	r.Delete("/users/{id}/favorites", h.favoriteHandler.RemoveFavorite)
+	// Withdrawals pay balance out of the shop, only operators may request them
+	r.With(RequireAdmin(h.adminToken)).Post("/users/{id}/withdraw", h.payoutHandler.Withdraw)
+	r.Get("/users/{id}/waitlist", h.shopHandler.ListWaitlist)
+	r.Delete("/users/{id}/waitlist/{entryID}", h.shopHandler.LeaveWaitlist)
+	if h.steam != nil {
+		r.Get("/auth/steam/login", h.steam.Login)
+		r.Get("/auth/steam/callback", h.steam.Callback)
+		r.Get("/users/{id}/steam", h.steam.GetAccount)
+		r.Get("/users/{id}/steam-inventory", h.steam.GetInventory)
+	}
+	if h.depositHandler != nil {
+		r.Post("/users/{id}/deposits", h.depositHandler.CreateDeposit)
+	}
+
+	// GraphQL evolves through its schema, it is only served under /v1
+	if version == APIv1 {
+		if h.graphql != nil {
+			r.Handle("/graphql", h.graphql)
+		}
+		if h.playground != nil {
+			r.Get("/graphql/playground", h.playground.ServeHTTP)
+		}
+	}
+
+	if h.priceSync != nil {
+		r.With(RequireAdmin(h.adminToken)).Get("/tools/arbitrage", h.priceSync.FindArbitrage)
+	}
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(RequireAdmin(h.adminToken))
+
+		r.Get("/stats", h.adminHandler.GetStats)
+		r.Post("/balances/import", h.adminHandler.ImportBalances)
+		r.Post("/orders/import", h.adminHandler.ImportOrders)
+		r.Post("/items/import", h.adminHandler.ImportItems)
+		r.Get("/items/export", h.adminHandler.ExportItems)
+		r.Get("/audit", h.adminHandler.ListAuditLog)
+		if h.tenants != nil {
+			r.Get("/tenants", h.tenants.ListTenants)
+			r.Post("/tenants", h.tenants.CreateTenant)
+			r.Get("/skinport/credentials", h.tenants.GetSkinportCredentials)
+			r.Put("/skinport/credentials", h.tenants.SetSkinportCredentials)
+			r.Delete("/skinport/credentials", h.tenants.DeleteSkinportCredentials)
+		}
+
+		r.Post("/orders/{id}/status", h.shopHandler.TransitionOrder)
+		r.Post("/users/{id}/deactivate", h.shopHandler.DeactivateUser)
+		r.Post("/users/{id}/reactivate", h.shopHandler.ReactivateUser)
+		r.Delete("/users/{id}", h.shopHandler.DeleteUser)
+		r.Post("/users/{id}/balance-adjustment", h.shopHandler.AdjustUserBalance)
+		r.Post("/users/{id}/telegram/link-token", h.telegramHandler.IssueLinkToken)
 
-		r.Route("/skinport", func(r chi.Router) {
-			r.Get("/items", h.GetSkinportItems)
+		r.Get("/skinport/cache", h.ListSkinportCache)
+		r.Delete("/skinport/cache", h.InvalidateSkinportCache)
+		r.Delete("/skinport/cache/{key}", h.InvalidateSkinportCacheKey)
+		r.Post("/skinport/cache/refresh", h.RefreshSkinportCache)
+
+		r.Get("/promo-codes", h.promoHandler.ListPromoCodes)
+		r.Post("/promo-codes", h.promoHandler.CreatePromoCode)
+		if h.dropHandler != nil {
+			r.Post("/drops", h.dropHandler.CreateDrop)
+		}
+
+		r.Post("/categories", h.categoryHandler.CreateCategory)
+		r.Put("/items/{id}/taxonomy", h.categoryHandler.UpdateItemTaxonomy)
+		r.Put("/items/{id}/price-tiers", h.shopHandler.SetPriceTiers)
+		if h.itemMedia != nil {
+			r.Patch("/items/{id}/metadata", h.itemMedia.UpdateItemMetadata)
+			r.Put("/items/{id}/image", h.itemMedia.UploadItemImage)
+		}
+		if h.steam != nil {
+			r.Put("/users/{id}/steam", h.steam.Link)
+			r.Delete("/users/{id}/steam", h.steam.Unlink)
+		}
+		if h.priceSync != nil {
+			r.Get("/price-sync/mappings", h.priceSync.ListMappings)
+			r.Post("/price-sync/run", h.priceSync.Sync)
+			r.Put("/items/{id}/skinport-mapping", h.priceSync.SetMapping)
+			r.Delete("/items/{id}/skinport-mapping", h.priceSync.RemoveMapping)
+			r.Get("/items/{id}/price-changes", h.priceSync.ListPriceChanges)
+			r.Get("/price-changes", h.priceSync.ListPriceChanges)
+			r.Post("/price-changes/{id}/approve", h.priceSync.ApprovePriceChange)
+			r.Post("/price-changes/{id}/reject", h.priceSync.RejectPriceChange)
+		}
+	})
+}
+
+// requestTimeout puts a deadline on the request context. Unlike chi's Timeout it writes
+// nothing itself: handlers map the resulting context errors (see writeInternalError).
+func requestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// queryTimeout bounds every repository query run with the request's context
+func queryTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(repository.WithQueryTimeout(r.Context(), d)))
 		})
+	}
+}
 
-		r.Post("/buy", h.shopHandler.BuyItem)
+// observeOutcome reports whether each request failed with a server error; cancelled
+// requests (499) are not failures
+func observeOutcome(observe func(ctx context.Context, failed bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if observe == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			observe(r.Context(), ww.Status() >= http.StatusInternalServerError)
+		})
+	}
+}
+
+// reportErrors makes the request's errors reach reporter and reports panics, which are
+// then passed on to chi's Recoverer to answer 500
+func reportErrors(reporter errreport.Reporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := errreport.WithReporter(r.Context(), reporter)
+			ctx = errreport.WithRequest(ctx, errreport.Request{Method: r.Method, URL: r.URL.String()})
+			r = r.WithContext(ctx)
+			defer func() {
+				if rvr := recover(); rvr != nil {
+					if rvr != http.ErrAbortHandler {
+						errreport.CaptureEvent(r.Context(), errreport.Event{
+							Kind:    errreport.KindPanic,
+							Level:   errreport.LevelFatal,
+							Err:     fmt.Errorf("panic: %v", rvr),
+							Stack:   debug.Stack(),
+							Request: requestInfo(r),
+						})
+					}
+					panic(rvr)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestInfo describes r for error reports, with the route chi matched
+func requestInfo(r *http.Request) *errreport.Request {
+	req := &errreport.Request{Method: r.Method, URL: r.URL.String()}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		req.Route = rctx.RoutePattern()
+	}
+	return req
+}
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps incoming request IDs, longer ones are replaced
+const maxRequestIDLength = 128
+
+// requestID tags every request with an ID: the caller's X-Request-ID when it is well-formed,
+// so a request can be followed across services, otherwise one generated by chi.
+// The ID is echoed in the response header and exposed to the service layer for audit
+// entries and log lines.
+func requestID(next http.Handler) http.Handler {
+	tagged := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := middleware.GetReqID(r.Context())
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(audit.WithRequestID(r.Context(), id)))
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(RequestIDHeader); id != "" && !validRequestID(id) {
+			r.Header.Del(RequestIDHeader)
+		}
+		tagged.ServeHTTP(w, r)
 	})
 }
 
+// validRequestID accepts IDs of common generators (UUIDs, chi's host/random-counter, ...)
+// and rejects anything that could forge log lines or headers
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/+=", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.router.ServeHTTP(w, r)
 }
@@ -53,3 +474,22 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// GetVersion tells operators which build is deployed
+func (h *Handler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
+
+// ReadyCheck answers 503 while the instance cannot serve requests, so load balancers
+// route around it until the database is back
+func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
+	if h.ready != nil {
+		if err := h.ready(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready: " + err.Error()))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type InventoryHandler struct {
+	svc       *service.InventoryService
+	portfolio *service.PortfolioService
+}
+
+func NewInventoryHandler(svc *service.InventoryService, portfolio *service.PortfolioService) *InventoryHandler {
+	return &InventoryHandler{svc: svc, portfolio: portfolio}
+}
+
+type TransferRequest struct {
+	FromUserID int `json:"from_user_id"`
+	ToUserID   int `json:"to_user_id"`
+	ItemID     int `json:"item_id"`
+	Quantity   int `json:"quantity"`
+}
+
+// Transfer moves owned items from one user to another
+func (h *InventoryHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	transfer := &model.InventoryTransfer{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		ItemID:     req.ItemID,
+		Quantity:   req.Quantity,
+	}
+	if err := h.svc.Transfer(r.Context(), transfer); err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation), err.Error() == "insufficient quantity":
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "user not found", err.Error() == "item not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrRetriesExhausted):
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "transfer conflicted with concurrent requests, please retry")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+func (h *InventoryHandler) ListUserInventory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	items, err := h.svc.ListUserInventory(r.Context(), userID)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// GetPortfolio returns the user's items with their value and its 24h and 7d changes
+func (h *InventoryHandler) GetPortfolio(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	portfolio, err := h.portfolio.GetPortfolio(r.Context(), userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, portfolio)
+}
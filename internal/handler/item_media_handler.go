@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ItemMediaHandler struct {
+	svc *service.ItemMediaService
+}
+
+func NewItemMediaHandler(svc *service.ItemMediaService) *ItemMediaHandler {
+	return &ItemMediaHandler{svc: svc}
+}
+
+// UpdateItemMetadata sets the item's description, image URL and attributes (admin)
+func (h *ItemMediaHandler) UpdateItemMetadata(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req model.ItemMetadata
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	item, err := h.svc.UpdateMetadata(r.Context(), itemID, req)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// UploadItemImage stores the request body, or the "file" field of a multipart form,
+// as the item's image (admin)
+func (h *ItemMediaHandler) UploadItemImage(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	// Leave room for the multipart envelope, the service checks the image size itself
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxItemImageBytes+64<<10)
+	body := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "missing file field")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "image is too large")
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	item, err := h.svc.UploadImage(r.Context(), itemID, data)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// GetItemImage serves the item's uploaded image
+func (h *ItemMediaHandler) GetItemImage(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	obj, err := h.svc.OpenImage(r.Context(), itemID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+	// Uploads get a new ?v= URL, so a versioned image never changes
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, obj.Body)
+}
+
+func (h *ItemMediaHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrItemImageNotFound), err.Error() == "item not found":
+		writeError(w, r, http.StatusNotFound, err.Error())
+	default:
+		writeInternalError(w, r, err)
+	}
+}
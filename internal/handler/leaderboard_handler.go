@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/service"
+)
+
+type LeaderboardHandler struct {
+	svc *service.LeaderboardService
+}
+
+func NewLeaderboardHandler(svc *service.LeaderboardService) *LeaderboardHandler {
+	return &LeaderboardHandler{svc: svc}
+}
+
+// GetLeaderboard returns the top spenders and best-selling items:
+// ?period=daily|weekly|all_time&limit=10
+func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	board, err := h.svc.GetLeaderboard(r.Context(), r.URL.Query().Get("period"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, board)
+}
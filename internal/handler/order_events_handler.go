@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+type OrderEventsHandler struct {
+	svc *service.OrderEventService
+}
+
+func NewOrderEventsHandler(svc *service.OrderEventService) *OrderEventsHandler {
+	return &OrderEventsHandler{svc: svc}
+}
+
+// Stream pushes the user's order events as Server-Sent Events. Clients resuming
+// after a disconnect send Last-Event-ID (or ?last_event_id) and first receive
+// the events they missed.
+func (h *OrderEventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var lastID int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastID, err = strconv.ParseInt(v, 10, 64)
+	} else if v := r.URL.Query().Get("last_event_id"); v != "" {
+		lastID, err = strconv.ParseInt(v, 10, 64)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid last event id")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Subscribe before replaying so nothing committed in between is missed;
+	// duplicates are skipped by id
+	sub, unsubscribe := h.svc.Subscribe(userID)
+	defer unsubscribe()
+
+	var missed []model.OrderEvent
+	if lastID > 0 {
+		missed, err = h.svc.Replay(r.Context(), userID, lastID)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable proxy buffering (nginx)
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+
+	send := func(e model.OrderEvent) error {
+		if e.ID <= lastID {
+			return nil
+		}
+		lastID = e.ID
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Payload); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for _, e := range missed {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
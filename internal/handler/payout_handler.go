@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PayoutHandler struct {
+	svc *service.PayoutService
+}
+
+func NewPayoutHandler(svc *service.PayoutService) *PayoutHandler {
+	return &PayoutHandler{svc: svc}
+}
+
+type WithdrawRequest struct {
+	Amount      model.Money `json:"amount"`
+	Destination string      `json:"destination"`
+}
+
+// Withdraw debits the user's balance and queues a payout to the destination; the
+// payout is pending until the payout worker sends it
+func (h *PayoutHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req WithdrawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	payout, err := h.svc.Withdraw(r.Context(), service.WithdrawParams{
+		UserID:      userID,
+		Amount:      req.Amount,
+		Destination: req.Destination,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidation), errors.Is(err, repository.ErrInsufficientFunds):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrRetriesExhausted):
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "withdrawal conflicted with concurrent requests, please retry")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, payout)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PriceSyncHandler struct {
+	svc *service.PriceSyncService
+}
+
+func NewPriceSyncHandler(svc *service.PriceSyncService) *PriceSyncHandler {
+	return &PriceSyncHandler{svc: svc}
+}
+
+type PriceMappingRequest struct {
+	MarketHashName string `json:"market_hash_name"`
+	// MarkupPercent overrides the configured markup for the item, optional
+	MarkupPercent *float64 `json:"markup_percent"`
+}
+
+// ListMappings returns the items priced from Skinport (admin)
+func (h *PriceSyncHandler) ListMappings(w http.ResponseWriter, r *http.Request) {
+	mappings, err := h.svc.ListMappings(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mappings)
+}
+
+// SetMapping prices the item from a Skinport item (admin)
+func (h *PriceSyncHandler) SetMapping(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req PriceMappingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	mapping, err := h.svc.SetMapping(r.Context(), itemID, req.MarketHashName, req.MarkupPercent)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mapping)
+}
+
+// RemoveMapping stops pricing the item from Skinport (admin)
+func (h *PriceSyncHandler) RemoveMapping(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	if err := h.svc.RemoveMapping(r.Context(), itemID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPriceChanges returns the latest price changes, of the item under
+// /items/{id}/price-changes, filtered by ?status= and limited by ?limit= (admin)
+func (h *PriceSyncHandler) ListPriceChanges(w http.ResponseWriter, r *http.Request) {
+	var filter model.PriceChangeFilter
+	if id := chi.URLParam(r, "id"); id != "" {
+		itemID, err := strconv.Atoi(id)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid item id")
+			return
+		}
+		filter.ItemID = itemID
+	}
+	filter.Status = r.URL.Query().Get("status")
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeError(w, r, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	changes, err := h.svc.ListPriceChanges(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// ApprovePriceChange applies a pending price change (admin)
+func (h *PriceSyncHandler) ApprovePriceChange(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.svc.ApprovePriceChange)
+}
+
+// RejectPriceChange discards a pending price change (admin)
+func (h *PriceSyncHandler) RejectPriceChange(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.svc.RejectPriceChange)
+}
+
+func (h *PriceSyncHandler) decide(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, id int) (*model.PriceChange, error)) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid price change id")
+		return
+	}
+
+	change, err := decide(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, change)
+}
+
+// Sync runs a price sync now instead of waiting for the worker (admin)
+func (h *PriceSyncHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	result, err := h.svc.SyncNow(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// FindArbitrage lists the mapped items whose price deviates from their lowest Skinport
+// listing by more than ?threshold= percent, 10 by default (admin)
+func (h *PriceSyncHandler) FindArbitrage(w http.ResponseWriter, r *http.Request) {
+	threshold := service.DefaultArbitrageThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "threshold must be a number")
+			return
+		}
+	}
+
+	report, err := h.svc.FindArbitrage(r.Context(), threshold)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *PriceSyncHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrPriceChangeNotPending):
+		writeError(w, r, http.StatusConflict, err.Error())
+	case err.Error() == "item not found", err.Error() == "price mapping not found", err.Error() == "price change not found":
+		writeError(w, r, http.StatusNotFound, err.Error())
+	default:
+		writeInternalError(w, r, err)
+	}
+}
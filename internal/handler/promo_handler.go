@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+)
+
+type PromoHandler struct {
+	svc *service.PromoService
+}
+
+func NewPromoHandler(svc *service.PromoService) *PromoHandler {
+	return &PromoHandler{svc: svc}
+}
+
+type CreatePromoCodeRequest struct {
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue float64    `json:"discount_value"`
+	MaxUses       *int       `json:"max_uses"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	ItemIDs       []int      `json:"item_ids"`
+	Active        *bool      `json:"active"` // Optional, defaults to true
+}
+
+func (h *PromoHandler) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var req CreatePromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	promo := &model.PromoCode{
+		Code:          req.Code,
+		DiscountType:  req.DiscountType,
+		DiscountValue: req.DiscountValue,
+		MaxUses:       req.MaxUses,
+		ExpiresAt:     req.ExpiresAt,
+		ItemIDs:       req.ItemIDs,
+		Active:        req.Active == nil || *req.Active,
+	}
+	if err := h.svc.CreatePromoCode(r.Context(), promo); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err.Error() == "promo code already exists" {
+			writeError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, promo)
+}
+
+func (h *PromoHandler) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	codes, err := h.svc.ListPromoCodes(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, codes)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/receipt"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReceiptHandler struct {
+	svc *service.ReceiptService
+}
+
+func NewReceiptHandler(svc *service.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{svc: svc}
+}
+
+// GetReceipt serves the order's receipt as a PDF download
+func (h *ReceiptHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	doc, err := h.svc.GetReceipt(r.Context(), orderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrReceiptUnavailable):
+			writeError(w, r, http.StatusConflict, err.Error())
+		case err.Error() == "order not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", receipt.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(doc)))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="receipt-%d.pdf"`, orderID))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(doc)
+}
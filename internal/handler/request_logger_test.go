@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger_RedactsSampledBodies(t *testing.T) {
+	var logs bytes.Buffer
+	mw := RequestLogger(RequestLogOptions{
+		Logger:         slog.New(slog.NewJSONHandler(&logs, nil)),
+		BodySampleRate: 1,
+		RedactFields:   []string{"promo_code"},
+	})
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		writeJSON(w, http.StatusCreated, map[string]any{"id": 1, "user": map[string]string{"Token": "abc"}})
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/buy?api_key=k&item=2", strings.NewReader(`{"user_id":1,"promo_code":"SAVE10"}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/v1/buy", entry["path"])
+	assert.Equal(t, 201.0, entry["status"])
+	assert.Equal(t, "api_key=%5BREDACTED%5D&item=2", entry["query"])
+	assert.JSONEq(t, `{"user_id":1,"promo_code":"[REDACTED]"}`, entry["request_body"].(string))
+	assert.JSONEq(t, `{"id":1,"user":{"Token":"[REDACTED]"}}`, entry["response_body"].(string))
+}
+
+func TestRequestLogger_TruncatesAndSkipsUnsampled(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	body := `{"password":"hunter2","note":"` + strings.Repeat("x", 100) + `"}`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	RequestLogger(RequestLogOptions{Logger: logger, BodySampleRate: 1, MaxBodyBytes: 40})(handler).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body)))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, `{"password":"[REDACTED]","note":"xxxxxxxxxx...(truncated)`, entry["request_body"])
+
+	logs.Reset()
+	RequestLogger(RequestLogOptions{Logger: logger})(handler).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body)))
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.NotContains(t, logs.String(), "request_body", "bodies are only logged for sampled requests")
+}
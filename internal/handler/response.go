@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/httpx"
+	"fsanano/go-test/internal/i18n"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// StatusClientClosedRequest is the non-standard status (from nginx) recorded when the
+// client went away before the response was ready
+const StatusClientClosedRequest = 499
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the shape of the request's API version:
+// {"error": message, "request_id": ...} on /v1, the errorEnvelope on /v2
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeErrorDetails(w, r, status, message, nil)
+}
+
+// writeErrorDetails is writeError with structured details (limits, allowed transitions, ...)
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, status int, message string, details any) {
+	writeErrorCode(w, r, status, errorCode(status), message, details)
+}
+
+// writeErrorCode is writeErrorDetails with a v2 code more specific than the status's.
+// The message is translated into the language of the request's Accept-Language when
+// there is a translation for it; the code never is.
+func writeErrorCode(w http.ResponseWriter, r *http.Request, status int, code, message string, details any) {
+	w.Header().Add("Vary", "Accept-Language")
+	if lang := i18n.Negotiate(r.Header.Get("Accept-Language")); lang != "" {
+		if translated, ok := i18n.ErrorMessage(lang, code, message); ok {
+			message = translated
+			w.Header().Set("Content-Language", lang)
+		}
+	}
+
+	requestID := middleware.GetReqID(r.Context())
+	if apiVersion(r) >= APIv2 {
+		writeJSON(w, status, errorEnvelope{Error: errorBody{Code: code, Message: message, Details: details, RequestID: requestID}})
+		return
+	}
+	body := map[string]any{"error": message}
+	if details != nil {
+		body["details"] = details
+	}
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	writeJSON(w, status, body)
+}
+
+// failureStatus picks the status for an unexpected error: 499 when the client cancelled the
+// request, 504 when a deadline (the request's, a query's or an upstream's) ran out, else 500
+func failureStatus(r *http.Request, err error) (int, string) {
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		return StatusClientClosedRequest, "client closed request"
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded), repository.IsTimeout(err):
+		return http.StatusGatewayTimeout, "request timed out"
+	default:
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
+// queryTimeoutCode is the v2 code of a 504 caused by a slow query while the request still had
+// time left. Unlike gateway_timeout it is retryable: the response carries Retry-After.
+const queryTimeoutCode = "query_timeout"
+
+// queryTimeoutDetails tells v1 and v2 clients alike that a query timeout may be retried
+var queryTimeoutDetails = map[string]any{"retryable": true}
+
+// writeInternalError answers an unexpected error, distinguishing timeouts and cancellations
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := failureStatus(r, err)
+	switch {
+	case status == http.StatusInternalServerError:
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+		if !service.IsReported(err) {
+			errreport.CaptureEvent(r.Context(), errreport.Event{Kind: errreport.KindHTTP, Err: err, Request: requestInfo(r)})
+		}
+	case status == http.StatusGatewayTimeout && r.Context().Err() == nil && repository.IsQueryTimeout(err):
+		slog.WarnContext(r.Context(), "query timed out", "method", r.Method, "path", r.URL.Path, "error", err)
+		w.Header().Set("Retry-After", "1")
+		writeErrorCode(w, r, status, queryTimeoutCode, "database query timed out", queryTimeoutDetails)
+		return
+	}
+	writeError(w, r, status, message)
+}
+
+// writeList writes a page of a collection, applying the sparse fieldset and advertising the next cursor
+func writeList(w http.ResponseWriter, r *http.Request, params httpx.ListParams, items any, next string) {
+	body, err := httpx.SelectFields(items, params.Fields)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+
+	httpx.SetNextCursor(w, r, next)
+	writeJSON(w, http.StatusOK, body)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureStatus(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{"client went away", cancelled, &pgconn.PgError{Code: "57014"}, StatusClientClosedRequest},
+		{"request deadline", expired, errors.New("failed to list items"), http.StatusGatewayTimeout},
+		{"statement timeout", context.Background(), fmt.Errorf("failed to list items: %w", &pgconn.PgError{Code: "57014"}), http.StatusGatewayTimeout},
+		{"upstream deadline", context.Background(), fmt.Errorf("fetch: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"other", context.Background(), errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/items", nil).WithContext(tt.ctx)
+			status, _ := failureStatus(r, tt.err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
+
+func TestWriteInternalError_QueryTimeout(t *testing.T) {
+	err := fmt.Errorf("failed to list items: %w", fmt.Errorf("%w after 10s: %w", repository.ErrQueryTimeout, context.DeadlineExceeded))
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/items", nil)
+	r = r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, APIv2))
+	w := httptest.NewRecorder()
+	writeInternalError(w, r, err)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":{"code":"query_timeout","message":"database query timed out","details":{"retryable":true}}}`, w.Body.String())
+
+	// Once the request's own deadline ran out a retry would not help
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	w = httptest.NewRecorder()
+	writeInternalError(w, r.WithContext(expired), err)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "request timed out")
+}
+
+func TestRequestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+
+	requestTimeout(time.Minute)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	requestTimeout(0)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, ok, "0 disables the deadline")
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = audit.RequestIDFrom(r.Context())
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"uuid from upstream", "0b6f1c2e-8d4a-4f0e-9a57-3c1d2e4f5a6b", true},
+		{"chi style", "host/AbCdEf-000001", true},
+		{"forged log line", "id\nlevel=ERROR", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			requestID(next).ServeHTTP(w, r)
+
+			id := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, seen, "the service layer sees the echoed ID")
+			if tt.keep {
+				assert.Equal(t, tt.incoming, id)
+			} else {
+				assert.NotEqual(t, tt.incoming, id)
+			}
+		})
+	}
+}
@@ -2,8 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fsanano/go-test/internal/httpx"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
 	"fsanano/go-test/internal/service"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type ShopHandler struct {
@@ -15,34 +23,402 @@ func NewShopHandler(svc *service.ShopService) *ShopHandler {
 }
 
 type BuyRequest struct {
-	UserID int `json:"user_id"`
-	ItemID int `json:"item_id"`
-	Count  int `json:"count"` // Optional, defaults to 1 if 0
+	UserID    int    `json:"user_id"`
+	ItemID    int    `json:"item_id"`
+	Count     int    `json:"count"`      // Optional, defaults to 1 if 0
+	PromoCode string `json:"promo_code"` // Optional
+	// ClientOrderID optionally keys the purchase, a retry with the same key returns the
+	// order created the first time
+	ClientOrderID string `json:"client_order_id"`
+	// QuoteID optionally buys at the price of a quote from POST /quotes; item_id and
+	// count default to the quote's
+	QuoteID string `json:"quote_id"`
+	// Waitlist optionally joins the item's waitlist when it is out of stock, answered with
+	// 202 and the waitlist entry
+	Waitlist bool `json:"waitlist"`
 }
 
 func (h *ShopHandler) BuyItem(w http.ResponseWriter, r *http.Request) {
+	// v1 answers errors in plain text, v2 in the error envelope
+	fail := func(status int, message string) {
+		if apiVersion(r) >= APIv2 {
+			writeError(w, r, status, message)
+			return
+		}
+		http.Error(w, message, status)
+	}
+
 	var req BuyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		fail(http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	// Default count to 1 if not provided
+	// Default count to 1 if not provided (or to the quote's)
 	quantity := req.Count
-	if quantity <= 0 {
+	if quantity <= 0 && req.QuoteID == "" {
 		quantity = 1
 	}
 
-	if err := h.svc.BuyItem(r.Context(), req.UserID, req.ItemID, quantity); err != nil {
-		if err.Error() == "item not found" || err.Error() == "user not found" || err.Error() == "insufficient funds" || err.Error() == "insufficient stock" {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	order, err := h.svc.BuyItem(r.Context(), service.BuyParams{
+		UserID:        req.UserID,
+		ItemID:        req.ItemID,
+		Quantity:      quantity,
+		PromoCode:     req.PromoCode,
+		ClientOrderID: req.ClientOrderID,
+		QuoteID:       req.QuoteID,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) && req.Waitlist && req.QuoteID == "" {
+			if h.joinWaitlist(w, r, req.UserID, req.ItemID, quantity) {
+				return
+			}
+		}
+		if errors.Is(err, service.ErrInvalidPromoCode) || errors.Is(err, service.ErrValidation) ||
+			errors.Is(err, service.ErrInvalidQuote) || errors.Is(err, service.ErrQuotesDisabled) {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrQuoteExpired) {
+			fail(http.StatusGone, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrUserInactive) {
+			fail(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrClientOrderIDReused) || errors.Is(err, repository.ErrQuoteUsed) {
+			fail(http.StatusConflict, err.Error())
+			return
+		}
+		if err.Error() == "item not found" || err.Error() == "user not found" ||
+			errors.Is(err, repository.ErrInsufficientFunds) || errors.Is(err, repository.ErrInsufficientStock) {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		var limitErr *service.PurchaseLimitError
+		if errors.As(err, &limitErr) {
+			status := http.StatusForbidden
+			if limitErr.Rule == service.RuleOrdersPerMinute {
+				status = http.StatusTooManyRequests
+				w.Header().Set("Retry-After", "60")
+			}
+			writeErrorDetails(w, r, status, service.ErrPurchaseLimitExceeded.Error(), limitErr)
 			return
 		}
-		// Log error internally in production
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		var dropErr *service.DropError
+		if errors.As(err, &dropErr) {
+			writeErrorDetails(w, r, dropErrorStatus(dropErr), dropErr.Error(), dropErr)
+			return
+		}
+		var queueErr *service.PurchaseQueueError
+		if errors.As(err, &queueErr) {
+			w.Header().Set("Retry-After", "1")
+			writeErrorDetails(w, r, http.StatusTooManyRequests, service.ErrPurchaseQueueFull.Error(), queueErr)
+			return
+		}
+		if errors.Is(err, repository.ErrRetriesExhausted) {
+			w.Header().Set("Retry-After", "1")
+			fail(http.StatusServiceUnavailable, "purchase conflicted with concurrent requests, please retry")
+			return
+		}
+		fail(failureStatus(r, err))
 		return
 	}
 
+	if apiVersion(r) >= APIv2 {
+		status := http.StatusCreated
+		if order.Replayed {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, order)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status": "success"}`))
 }
+
+type QuoteRequest struct {
+	UserID int `json:"user_id"`
+	ItemID int `json:"item_id"`
+	Count  int `json:"count"` // Optional, defaults to 1 if 0
+}
+
+// CreateQuote locks in the item's price for a purchase, see service.ShopService.CreateQuote
+func (h *ShopHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	var req QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+
+	quote, err := h.svc.CreateQuote(r.Context(), req.UserID, req.ItemID, req.Count)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrQuotesDisabled), err.Error() == "item not found", err.Error() == "user not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrValidation), errors.Is(err, repository.ErrInsufficientStock):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repository.ErrUserInactive):
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, quote)
+}
+
+var itemListSpec = httpx.ListSpec{
+	Sortable:     []string{"id", "name", "price", "stock"},
+	DefaultSort:  "id",
+	Fields:       []string{"id", "name", "price", "price_display", "stock", "category", "tags", "description", "image_url", "attributes"},
+	DefaultLimit: 100,
+	MaxLimit:     1000,
+}
+
+func (h *ShopHandler) ListItems(w http.ResponseWriter, r *http.Request) {
+	params, err := httpx.ParseListParams(r, itemListSpec)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, ok := displayLocale(w, r)
+	if !ok {
+		return
+	}
+
+	// ?category=<slug>&tag=a&tag=b (or tag=a,b) returns items in the category carrying all tags
+	filter := model.ItemFilter{Category: r.URL.Query().Get("category")}
+	for _, v := range r.URL.Query()["tag"] {
+		filter.Tags = append(filter.Tags, strings.Split(v, ",")...)
+	}
+
+	items, err := h.svc.ListItems(r.Context(), filter, params.ListOptions)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	var next string
+	if params.Limit > 0 && len(items) > params.Limit {
+		items = items[:params.Limit]
+		next, _ = params.KeysetCursor(items[len(items)-1], "id")
+	}
+
+	if locale != "" {
+		writeList(w, r, params, displayItems(items, locale), next)
+		return
+	}
+	writeList(w, r, params, items, next)
+}
+
+var orderListSpec = httpx.ListSpec{
+	Sortable:    []string{"id", "price", "quantity", "created_at"},
+	DefaultSort: "-created_at",
+	Fields: []string{"id", "user_id", "item_id", "price", "unit_price", "quantity", "promo_code_id", "discount", "status",
+		"created_at", "paid_at", "fulfilled_at", "refunded_at", "cancelled_at"},
+	DefaultLimit: 50,
+	MaxLimit:     500,
+}
+
+func (h *ShopHandler) ListUserOrders(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	params, err := httpx.ParseListParams(r, orderListSpec)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	orders, err := h.svc.ListUserOrders(r.Context(), userID, params.ListOptions)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	var next string
+	if params.Limit > 0 && len(orders) > params.Limit {
+		orders = orders[:params.Limit]
+		next, _ = params.KeysetCursor(orders[len(orders)-1], "id")
+	}
+
+	writeList(w, r, params, orders, next)
+}
+
+type TransitionOrderRequest struct {
+	Status string `json:"status"`
+	// OverrideReason refunds an order the refund policy denies
+	OverrideReason string `json:"override_reason,omitempty"`
+}
+
+// TransitionOrder moves an order through its state machine (admin)
+func (h *ShopHandler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req TransitionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var order *model.Order
+	if req.Status == model.OrderStatusRefunded {
+		order, err = h.svc.RefundOrder(r.Context(), orderID, service.RefundRequest{OverrideReason: req.OverrideReason})
+	} else {
+		order, err = h.svc.TransitionOrder(r.Context(), orderID, req.Status)
+	}
+	if err != nil {
+		var transitionErr *service.TransitionError
+		var deniedErr *service.RefundDeniedError
+		switch {
+		case errors.As(err, &deniedErr):
+			writeErrorDetails(w, r, http.StatusForbidden, service.ErrRefundDenied.Error(), deniedErr)
+		case errors.As(err, &transitionErr):
+			writeErrorDetails(w, r, http.StatusConflict, service.ErrInvalidTransition.Error(), transitionErr)
+		case errors.Is(err, service.ErrValidation):
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		case err.Error() == "order not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrRetriesExhausted):
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "order update conflicted with concurrent requests, please retry")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+// RefundOrder refunds one of the user's orders if the refund policy allows it
+func (h *ShopHandler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	orderID, err := strconv.Atoi(chi.URLParam(r, "orderID"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	order, err := h.svc.RefundOrder(r.Context(), orderID, service.RefundRequest{UserID: userID})
+	if err != nil {
+		var transitionErr *service.TransitionError
+		var deniedErr *service.RefundDeniedError
+		switch {
+		case errors.As(err, &deniedErr):
+			writeErrorDetails(w, r, http.StatusForbidden, service.ErrRefundDenied.Error(), deniedErr)
+		case errors.As(err, &transitionErr):
+			writeErrorDetails(w, r, http.StatusConflict, service.ErrInvalidTransition.Error(), transitionErr)
+		case err.Error() == "order not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrRetriesExhausted):
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "order update conflicted with concurrent requests, please retry")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (h *ShopHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	user, err := h.svc.GetUser(r.Context(), userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ListPriceTiers returns the item's quantity discounts
+func (h *ShopHandler) ListPriceTiers(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	tiers, err := h.svc.ListPriceTiers(r.Context(), itemID)
+	if err != nil {
+		if err.Error() == "item not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tiers)
+}
+
+type SetPriceTiersRequest struct {
+	Tiers []model.PriceTier `json:"tiers"` // Replaces all tiers, [] removes them
+}
+
+// SetPriceTiers replaces the item's quantity discounts (admin)
+func (h *ShopHandler) SetPriceTiers(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	var req SetPriceTiersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Tiers == nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.svc.SetPriceTiers(r.Context(), itemID, req.Tiers); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err.Error() == "item not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, req.Tiers)
+}
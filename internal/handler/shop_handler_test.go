@@ -4,24 +4,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"fsanano/go-test/internal/handler"
+	"fsanano/go-test/internal/model"
 	"fsanano/go-test/internal/repository"
 	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/testdb"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
 )
 
-func setupTestDB(t *testing.T) *pgxpool.Pool {
-	_ = godotenv.Load("../../.env")
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
 
-	dbURL := os.Getenv("DATABASE_URL")
+func setupTestDB(t *testing.T) *pgxpool.Pool {
+	dbURL, err := testdb.URL()
+	if err != nil {
+		t.Fatalf("Unable to start test database: %v", err)
+	}
 	if dbURL == "" {
 		t.Fatalf("DATABASE_URL not set")
 	}
@@ -31,6 +36,7 @@ func setupTestDB(t *testing.T) *pgxpool.Pool {
 		t.Fatalf("Unable to parse database URL: %v", err)
 	}
 
+	config.AfterConnect = model.RegisterPgTypes
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		t.Fatalf("Unable to connect to database: %v", err)
@@ -42,13 +48,7 @@ func setupTestDB(t *testing.T) *pgxpool.Pool {
 	}
 
 	// Truncate tables to ensure clean state
-	tables := []string{"orders", "users", "items"} // Order matters due to FK
-	for _, table := range tables {
-		_, err := pool.Exec(context.Background(), fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", table))
-		if err != nil {
-			t.Fatalf("Failed to truncate table %s: %v", table, err)
-		}
-	}
+	testdb.Truncate(t, pool, "orders", "users", "items") // Order matters due to FK
 
 	return pool
 }
@@ -77,7 +77,7 @@ func TestBuyItem_Integration(t *testing.T) {
 
 	// 2. Setup Handler
 	repo := repository.NewShopRepository(pool)
-	svc := service.NewShopService(repo)
+	svc := service.NewShopService(repo, repo)
 	h := handler.NewShopHandler(svc)
 
 	// 3. Perform Request (Success Case)
@@ -144,7 +144,7 @@ func TestBuyItem_InsufficientFunds(t *testing.T) {
 	pool.Exec(ctx, "INSERT INTO items (id, name, price, stock) VALUES (1, 'Test Item', 10.0, 5)")
 
 	repo := repository.NewShopRepository(pool)
-	svc := service.NewShopService(repo)
+	svc := service.NewShopService(repo, repo)
 	h := handler.NewShopHandler(svc)
 
 	reqBody, _ := json.Marshal(map[string]interface{}{"user_id": 1, "item_id": 1, "count": 1})
@@ -167,7 +167,7 @@ func TestBuyItem_InsufficientStock(t *testing.T) {
 	pool.Exec(ctx, "INSERT INTO items (id, name, price, stock) VALUES (1, 'Test Item', 10.0, 1)")
 
 	repo := repository.NewShopRepository(pool)
-	svc := service.NewShopService(repo)
+	svc := service.NewShopService(repo, repo)
 	h := handler.NewShopHandler(svc)
 
 	// Buy 2 (Stock is 1)
@@ -197,7 +197,7 @@ func TestBuyItem_Concurrency(t *testing.T) {
 	pool.Exec(ctx, "INSERT INTO items (id, name, price, stock) VALUES (1, 'Test Item', $1, $2)", itemPrice, initialStock)
 
 	repo := repository.NewShopRepository(pool)
-	svc := service.NewShopService(repo)
+	svc := service.NewShopService(repo, repo)
 	h := handler.NewShopHandler(svc)
 
 	concurrentRequests := 50
@@ -251,3 +251,100 @@ func TestBuyItem_Concurrency(t *testing.T) {
 		t.Errorf("Expected balance %.2f, got %.2f", expectedBalance, newBalance)
 	}
 }
+
+func TestBuyItem_ClientOrderID(t *testing.T) {
+	pool := setupTestDB(t)
+	defer pool.Close()
+	ctx := context.Background()
+
+	pool.Exec(ctx, "INSERT INTO users (id, first_name, last_name, balance) VALUES (1, 'Retrying', 'User', 1000.0)")
+	pool.Exec(ctx, "INSERT INTO items (id, name, price, stock) VALUES (1, 'Test Item', 10.0, 100), (2, 'Other Item', 10.0, 100)")
+
+	repo := repository.NewShopRepository(pool)
+	svc := service.NewShopService(repo, repo)
+	h := handler.NewShopHandler(svc)
+
+	buy := func(itemID int) int {
+		reqBody, _ := json.Marshal(map[string]interface{}{"user_id": 1, "item_id": itemID, "count": 1, "client_order_id": "checkout-42"})
+		req := httptest.NewRequest(http.MethodPost, "/buy", bytes.NewBuffer(reqBody))
+		w := httptest.NewRecorder()
+		h.BuyItem(w, req)
+		return w.Code
+	}
+
+	// Concurrent retries of the same purchase buy once
+	results := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		go func() { results <- buy(1) }()
+	}
+	for i := 0; i < 10; i++ {
+		if code := <-results; code != http.StatusOK {
+			t.Errorf("Expected status 200 OK for a retry, got %d", code)
+		}
+	}
+
+	var orderCount int
+	pool.QueryRow(ctx, "SELECT COUNT(*) FROM orders WHERE user_id = 1 AND client_order_id = 'checkout-42'").Scan(&orderCount)
+	if orderCount != 1 {
+		t.Errorf("Expected 1 order, got %d", orderCount)
+	}
+	var newBalance float64
+	pool.QueryRow(ctx, "SELECT balance FROM users WHERE id = 1").Scan(&newBalance)
+	if newBalance != 990.0 {
+		t.Errorf("Expected balance 990.00, got %.2f", newBalance)
+	}
+
+	if code := buy(2); code != http.StatusConflict {
+		t.Errorf("Expected status 409 Conflict when the key is reused for another item, got %d", code)
+	}
+}
+
+func TestBuyItem_Quote(t *testing.T) {
+	pool := setupTestDB(t)
+	defer pool.Close()
+	ctx := context.Background()
+
+	pool.Exec(ctx, "INSERT INTO users (id, first_name, last_name, balance) VALUES (1, 'Quoted', 'User', 1000.0)")
+	pool.Exec(ctx, "INSERT INTO items (id, name, price, stock) VALUES (1, 'Test Item', 10.0, 100)")
+
+	repo := repository.NewShopRepository(pool)
+	svc := service.NewShopService(repo, repo, service.WithQuotes(service.QuoteOptions{SigningKey: "secret"}))
+	h := handler.NewShopHandler(svc)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"user_id": 1, "item_id": 1, "count": 2})
+	w := httptest.NewRecorder()
+	h.CreateQuote(w, httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBuffer(reqBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created for the quote, got %d: %s", w.Code, w.Body.String())
+	}
+	var quote struct {
+		ID    string  `json:"id"`
+		Total float64 `json:"total"`
+	}
+	json.NewDecoder(w.Body).Decode(&quote)
+	if quote.Total != 20.0 {
+		t.Errorf("Expected quote total 20.00, got %.2f", quote.Total)
+	}
+
+	// The price rises after the quote, buying with it still charges the quoted price
+	pool.Exec(ctx, "UPDATE items SET price = 15.0 WHERE id = 1")
+
+	buy := func() int {
+		reqBody, _ := json.Marshal(map[string]interface{}{"user_id": 1, "quote_id": quote.ID})
+		w := httptest.NewRecorder()
+		h.BuyItem(w, httptest.NewRequest(http.MethodPost, "/buy", bytes.NewBuffer(reqBody)))
+		return w.Code
+	}
+	if code := buy(); code != http.StatusOK {
+		t.Errorf("Expected status 200 OK, got %d", code)
+	}
+	var newBalance float64
+	pool.QueryRow(ctx, "SELECT balance FROM users WHERE id = 1").Scan(&newBalance)
+	if newBalance != 980.0 {
+		t.Errorf("Expected balance 980.00, got %.2f", newBalance)
+	}
+
+	if code := buy(); code != http.StatusConflict {
+		t.Errorf("Expected status 409 Conflict when the quote is used again, got %d", code)
+	}
+}
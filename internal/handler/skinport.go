@@ -1,37 +1,673 @@
 package handler
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"fsanano/go-test/internal/fx"
+	"fsanano/go-test/internal/httpx"
+	"fsanano/go-test/internal/model"
 	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func (h *Handler) GetSkinportItems(w http.ResponseWriter, r *http.Request) {
-	appID := r.URL.Query().Get("app_id")
-	currency := r.URL.Query().Get("currency")
+	params, err := httpx.ParseListParams(r, skinportListSpec)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	p, convertTo, ok := h.skinportItemsParams(w, r)
+	if !ok {
+		return
+	}
+	locale, ok := displayLocale(w, r)
+	if !ok {
+		return
+	}
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
 	// Pass the context from the request
-	items, err := h.skinportClient.GetAllItems(r.Context(), appID, currency)
+	items, err := client.GetItems(r.Context(), p)
 	if err != nil {
-		fmt.Printf("Error fetching items: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeSkinportError(w, r, err)
+		return
+	}
+	if !h.convertPrices(w, r, items, convertTo) {
+		return
+	}
+	// Filter after conversion, the minimums are in the response's currency
+	items, ok = filterSkinportItems(w, r, items)
+	if !ok {
+		return
+	}
 
-		var apiErr *skinport.ErrorResponse
-		if errors.As(err, &apiErr) {
-			json.NewEncoder(w).Encode(apiErr)
+	// Cache order changes on every refresh, pages need a deterministic order
+	if params.Limit > 0 && len(params.Sort) == 0 {
+		params.Sort = []model.SortField{{Field: "market_hash_name"}}
+	}
+
+	// The client returns a copy, it can be sorted in place
+	sorted := items
+	sortSkinportItems(sorted, params.Sort)
+
+	var next string
+	if params.Offset > len(sorted) {
+		params.Offset = len(sorted)
+	}
+	page := sorted[params.Offset:]
+	if params.Limit > 0 && len(page) > params.Limit {
+		page = page[:params.Limit]
+		next = params.OffsetCursor(params.Offset + params.Limit)
+	}
+
+	// Only the page is copied into views, the catalogue can be millions of items
+	if locale != "" {
+		writeList(w, r, params, displaySkinportItems(page, locale), next)
+		return
+	}
+	writeList(w, r, params, page, next)
+}
+
+// filterSkinportItems keeps the items whose spread is at least ?min_spread= and whose
+// spread_percent is at least ?min_spread_percent=, and which Skinport updated at or after
+// ?updated_since=. Items without a spread, or without updated_at, are dropped when the
+// corresponding filter is set. It filters in place, items must be the caller's copy.
+func filterSkinportItems(w http.ResponseWriter, r *http.Request, items []skinport.ResponseItem) ([]skinport.ResponseItem, bool) {
+	minimums := map[string]float64{}
+	for _, name := range []string{"spread", "spread_percent"} {
+		value := r.URL.Query().Get("min_" + name)
+		if value == "" {
+			continue
+		}
+		minimum, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(minimum) || math.IsInf(minimum, 0) {
+			writeError(w, r, http.StatusBadRequest, "min_"+name+" must be a number")
+			return nil, false
+		}
+		minimums[name] = minimum
+	}
+	var updatedSince time.Time
+	if v := r.URL.Query().Get("updated_since"); v != "" {
+		var err error
+		if updatedSince, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "updated_since must be an RFC 3339 timestamp")
+			return nil, false
+		}
+	}
+	if len(minimums) == 0 && updatedSince.IsZero() {
+		return items, true
+	}
+	return slices.DeleteFunc(items, func(item skinport.ResponseItem) bool {
+		if !updatedSince.IsZero() && (item.UpdatedAt.IsZero() || item.UpdatedAt.Before(updatedSince)) {
+			return true
+		}
+		for name, minimum := range minimums {
+			if p := skinportPrice(item, name); p == nil || *p < minimum {
+				return true
+			}
+		}
+		return false
+	}), true
+}
+
+// maxLookupNames bounds the market_hash_names of one lookup
+const maxLookupNames = 1000
+
+// LookupSkinportItems returns the cached items of the market_hash_names in the body,
+// keyed by name, for clients checking specific skins without downloading the catalogue.
+// It takes the query parameters of GetSkinportItems except paging and sorting; names not
+// on sale are listed in not_found.
+func (h *Handler) LookupSkinportItems(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MarketHashNames []string `json:"market_hash_names"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.MarketHashNames) == 0 {
+		writeError(w, r, http.StatusBadRequest, "market_hash_names must not be empty")
+		return
+	}
+	if len(req.MarketHashNames) > maxLookupNames {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d market_hash_names can be looked up at once", maxLookupNames))
+		return
+	}
+
+	p, convertTo, ok := h.skinportItemsParams(w, r)
+	if !ok {
+		return
+	}
+	locale, ok := displayLocale(w, r)
+	if !ok {
+		return
+	}
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	items, err := client.LookupItems(r.Context(), p, req.MarketHashNames)
+	if err != nil {
+		writeSkinportError(w, r, err)
+		return
+	}
+	if !h.convertPrices(w, r, items, convertTo) {
+		return
+	}
+
+	byName := make(map[string]skinportItemView, len(items))
+	for _, item := range items {
+		byName[item.MarketHashName] = displaySkinportItem(item, locale)
+	}
+	notFound := []string{}
+	seen := make(map[string]bool, len(req.MarketHashNames))
+	for _, name := range req.MarketHashNames {
+		if _, ok := byName[name]; !ok && !seen[name] {
+			notFound = append(notFound, name)
+		}
+		seen[name] = true
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": byName, "not_found": notFound})
+}
+
+// skinportItemResponse is one Skinport item with the age of the snapshot it came from
+type skinportItemResponse struct {
+	Item       skinportItemView `json:"item"`
+	FetchedAt  time.Time        `json:"fetched_at"`
+	ExpiresAt  time.Time        `json:"expires_at"`
+	AgeSeconds int64            `json:"age_seconds"`
+}
+
+// GetSkinportItem returns the cached item with the slug in the path. It takes the query
+// parameters of GetSkinportItems except paging and sorting.
+func (h *Handler) GetSkinportItem(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	h.getSkinportItem(w, r, func(client *skinport.Client, p skinport.ItemsParams) (skinport.CachedItem, error) {
+		return client.ItemBySlug(r.Context(), p, slug)
+	})
+}
+
+// GetSkinportItemByName is GetSkinportItem by ?market_hash_name=, for names whose slug
+// is not known
+func (h *Handler) GetSkinportItemByName(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("market_hash_name")
+	if name == "" {
+		writeError(w, r, http.StatusBadRequest, "market_hash_name is required")
+		return
+	}
+	h.getSkinportItem(w, r, func(client *skinport.Client, p skinport.ItemsParams) (skinport.CachedItem, error) {
+		return client.ItemByName(r.Context(), p, name)
+	})
+}
+
+// getSkinportItem answers the item found by get. Items missing from the snapshot are
+// answered 404 with code skinport_item_not_found and the key looked up as details.
+func (h *Handler) getSkinportItem(w http.ResponseWriter, r *http.Request, get func(*skinport.Client, skinport.ItemsParams) (skinport.CachedItem, error)) {
+	p, convertTo, ok := h.skinportItemsParams(w, r)
+	if !ok {
+		return
+	}
+	locale, ok := displayLocale(w, r)
+	if !ok {
+		return
+	}
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	found, err := get(client, p)
+	var notFound *skinport.ItemNotFoundError
+	if errors.As(err, &notFound) {
+		writeErrorCode(w, r, http.StatusNotFound, "skinport_item_not_found", err.Error(), notFound)
+		return
+	}
+	if err != nil {
+		writeSkinportError(w, r, err)
+		return
+	}
+	items := []skinport.ResponseItem{found.Item}
+	if !h.convertPrices(w, r, items, convertTo) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, skinportItemResponse{
+		Item:       displaySkinportItem(items[0], locale),
+		FetchedAt:  found.FetchedAt,
+		ExpiresAt:  found.ExpiresAt,
+		AgeSeconds: int64(time.Since(found.FetchedAt).Seconds()),
+	})
+}
+
+// GetSkinportStats summarizes the cached dataset selected by app_id, currency and view:
+// listings, mean and median price and the top price movers since the previous fetch.
+// With ?under=, it also counts the items priced below that threshold.
+func (h *Handler) GetSkinportStats(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.skinportSnapshotParams(w, r)
+	if !ok {
+		return
+	}
+	var under *float64
+	if v := r.URL.Query().Get("under"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(threshold > 0) || math.IsInf(threshold, 0) {
+			writeError(w, r, http.StatusBadRequest, "under must be a positive price")
 			return
 		}
+		under = &threshold
+	}
+
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	stats, err := client.Stats(r.Context(), p)
+	if err != nil {
+		writeSkinportError(w, r, err)
+		return
+	}
+
+	type priceThreshold struct {
+		Threshold float64 `json:"threshold"`
+		Items     int     `json:"items"`
+	}
+	resp := struct {
+		*skinport.MarketStats
+		UnderPrice *priceThreshold `json:"under_price,omitempty"`
+	}{MarketStats: stats}
+	if under != nil {
+		resp.UnderPrice = &priceThreshold{Threshold: *under, Items: stats.PricedUnder(*under)}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+// GetSkinportSnapshots lists the snapshots kept of the dataset selected by app_id,
+// currency and view, which GetSkinportDiff compares
+func (h *Handler) GetSkinportSnapshots(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.skinportSnapshotParams(w, r)
+	if !ok {
+		return
+	}
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	snapshots, err := client.Snapshots(r.Context(), p)
+	if err != nil {
+		writeSkinportError(w, r, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(items); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// GetSkinportDiff lists the items added, removed and changed between the snapshots
+// ?from= and ?to=, by default the previous and the current one. Snapshots no longer kept
+// are answered 404 with the available ones as details.
+func (h *Handler) GetSkinportDiff(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.skinportSnapshotParams(w, r)
+	if !ok {
+		return
+	}
+	var ids [2]int64
+	for i, name := range []string{"from", "to"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, r, http.StatusBadRequest, name+" must be a snapshot id")
+			return
+		}
+		ids[i] = id
 	}
+
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	diff, err := client.Diff(r.Context(), p, ids[0], ids[1])
+	var notFound *skinport.SnapshotNotFoundError
+	if errors.As(err, &notFound) {
+		writeErrorCode(w, r, http.StatusNotFound, "skinport_snapshot_not_found", err.Error(), notFound)
+		return
+	}
+	if err != nil {
+		writeSkinportError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// skinportSnapshotParams reads app_id, currency and view, selecting a cache entry
+func (h *Handler) skinportSnapshotParams(w http.ResponseWriter, r *http.Request) (skinport.ItemsParams, bool) {
+	appID, currency, ok := h.skinportParams(w, r)
+	if !ok {
+		return skinport.ItemsParams{}, false
+	}
+	view, err := skinport.ParseView(r.URL.Query().Get("view"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return skinport.ItemsParams{}, false
+	}
+	return skinport.ItemsParams{AppID: appID, Currency: currency, View: view}, true
+}
+
+// writeSkinportError answers a failed Skinport fetch: Skinport's own error when it sent
+// one, 504 when the request or the fetch deadline (SKINPORT_FETCH_TIMEOUT) ran out
+func writeSkinportError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "failed to fetch skinport items", "error", err)
+	status := skinportFailureStatus(r, err)
+
+	var apiErr *skinport.ErrorResponse
+	if apiVersion(r) >= APIv2 {
+		var details any
+		if errors.As(err, &apiErr) {
+			details = apiErr
+		}
+		writeErrorDetails(w, r, status, "failed to fetch skinport items", details)
+		return
+	}
+
+	if errors.As(err, &apiErr) {
+		writeJSON(w, status, apiErr)
+		return
+	}
+	writeError(w, r, status, err.Error())
+}
+
+// convertPrices converts the EUR prices of items to convertTo, if set. Unknown currencies
+// are answered with a 400, unavailable rates with a 502.
+func (h *Handler) convertPrices(w http.ResponseWriter, r *http.Request, items []skinport.ResponseItem, convertTo string) bool {
+	if convertTo == "" {
+		return true
+	}
+	rate, err := h.fx.Rate(r.Context(), fxBaseCurrency, convertTo)
+	if err != nil {
+		var unknown *fx.UnknownRateError
+		if errors.As(err, &unknown) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return false
+		}
+		writeError(w, r, http.StatusBadGateway, "failed to get exchange rates")
+		return false
+	}
+	skinport.ConvertPrices(items, convertTo, rate)
+	return true
+}
+
+// skinportClient returns the Skinport client of the request's tenant, see skinport.Factory
+func (h *Handler) skinportClient(w http.ResponseWriter, r *http.Request) (*skinport.Client, bool) {
+	client, err := h.skinportClients.Client(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// skinportFailureStatus is failureStatus for a failed Skinport fetch: 429 when the
+// tenant's Skinport rate limit left no request before the deadline
+func skinportFailureStatus(r *http.Request, err error) int {
+	if errors.Is(err, skinport.ErrRateLimited) {
+		return http.StatusTooManyRequests
+	}
+	status, _ := failureStatus(r, err)
+	return status
+}
+
+// Response headers naming the defaults applied to a request without app_id or currency
+const (
+	defaultAppIDHeader    = "X-Default-App-ID"
+	defaultCurrencyHeader = "X-Default-Currency"
+)
+
+// skinportParams reads ?app_id= and ?currency=, normalizing the currency (eur, €, ...).
+// Unsupported values are answered with a 400, with the allowed currencies as details.
+// Missing values get the deployment's defaults, named in the X-Default-* headers.
+func (h *Handler) skinportParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	appID := r.URL.Query().Get("app_id")
+	if err := skinport.ValidateAppID(appID); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	currency, err := skinport.NormalizeCurrency(r.URL.Query().Get("currency"))
+	if err != nil {
+		var currencyErr *skinport.UnsupportedCurrencyError
+		if errors.As(err, &currencyErr) {
+			writeErrorDetails(w, r, http.StatusBadRequest, err.Error(), currencyErr)
+			return "", "", false
+		}
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+
+	defaultAppID, defaultCurrency := h.skinportClients.Defaults()
+	if appID == "" {
+		appID = defaultAppID
+		w.Header().Set(defaultAppIDHeader, appID)
+	}
+	if currency == "" {
+		currency = defaultCurrency
+		w.Header().Set(defaultCurrencyHeader, currency)
+	}
+	return appID, currency, true
+}
+
+// skinportItemsParams reads the query parameters selecting a Skinport snapshot: app_id,
+// currency, view and convert_to. With convert_to, the EUR items are selected.
+func (h *Handler) skinportItemsParams(w http.ResponseWriter, r *http.Request) (skinport.ItemsParams, string, bool) {
+	appID, currency, ok := h.skinportParams(w, r)
+	if !ok {
+		return skinport.ItemsParams{}, "", false
+	}
+	// Single views need one upstream request instead of two
+	view, err := skinport.ParseView(r.URL.Query().Get("view"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return skinport.ItemsParams{}, "", false
+	}
+	// Converted prices come from the cached EUR items, not from a fetch per currency
+	convertTo, ok := h.convertToParam(w, r, r.URL.Query().Get("currency"))
+	if !ok {
+		return skinport.ItemsParams{}, "", false
+	}
+	if convertTo != "" {
+		currency = fxBaseCurrency
+		w.Header().Del(defaultCurrencyHeader)
+	}
+	return skinport.ItemsParams{AppID: appID, Currency: currency, View: view}, convertTo, true
+}
+
+// fxBaseCurrency is the currency items are fetched in for conversion
+const fxBaseCurrency = "EUR"
+
+// convertToParam reads ?convert_to=, a currency code or symbol. Any ISO code is
+// accepted, not only the currencies Skinport prices in; the rate provider decides.
+func (h *Handler) convertToParam(w http.ResponseWriter, r *http.Request, currency string) (string, bool) {
+	value := strings.TrimSpace(r.URL.Query().Get("convert_to"))
+	if value == "" {
+		return "", true
+	}
+	if h.fx == nil {
+		writeError(w, r, http.StatusBadRequest, "currency conversion is disabled")
+		return "", false
+	}
+	if currency != "" {
+		writeError(w, r, http.StatusBadRequest, "currency and convert_to cannot be combined")
+		return "", false
+	}
+	code, err := skinport.NormalizeCurrency(value)
+	if err != nil {
+		if !isCurrencyCode(value) {
+			writeError(w, r, http.StatusBadRequest, "convert_to must be a currency code")
+			return "", false
+		}
+		code = strings.ToUpper(value)
+	}
+	return code, true
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// GetSkinportApps lists the apps /skinport/items accepts as app_id
+func (h *Handler) GetSkinportApps(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, skinport.Apps())
+}
+
+// InvalidateSkinportCache drops the cached items for app_id/currency without refetching them (admin)
+func (h *Handler) InvalidateSkinportCache(w http.ResponseWriter, r *http.Request) {
+	appID, currency, ok := h.skinportParams(w, r)
+	if !ok {
+		return
+	}
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	client.InvalidateCache(appID, currency)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSkinportCache lists the cached app_id/currency combinations with their age,
+// expiry and size, and the cache's total size and memory budget (admin)
+func (h *Handler) ListSkinportCache(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	used, budget := client.CacheBytes()
+	writeJSON(w, http.StatusOK, map[string]any{"entries": client.CacheEntries(), "size_bytes": used, "max_bytes": budget})
+}
+
+// InvalidateSkinportCacheKey drops one cache entry by its key, as listed by
+// ListSkinportCache (admin)
+func (h *Handler) InvalidateSkinportCacheKey(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	if !client.InvalidateCacheKey(chi.URLParam(r, "key")) {
+		writeError(w, r, http.StatusNotFound, "cache entry not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RefreshSkinportCache drops the cached items for app_id/currency and fetches them again
+func (h *Handler) RefreshSkinportCache(w http.ResponseWriter, r *http.Request) {
+	appID, currency, ok := h.skinportParams(w, r)
+	if !ok {
+		return
+	}
+
+	client, ok := h.skinportClient(w, r)
+	if !ok {
+		return
+	}
+	client.InvalidateCache(appID, currency)
+
+	items, err := client.GetAllItems(r.Context(), appID, currency)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to refresh skinport items", "app_id", appID, "currency", currency, "error", err)
+		status := skinportFailureStatus(r, err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadGateway
+		}
+		writeError(w, r, status, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"items": len(items)})
+}
+
+var skinportListSpec = httpx.ListSpec{
+	Sortable: []string{"market_hash_name", "quantity", "min_price_tradable", "min_price_non_tradable", "spread", "spread_percent",
+		"created_at", "updated_at"},
+	Fields: []string{"market_hash_name", "currency", "slug", "min_price_tradable", "min_price_non_tradable", "quantity",
+		"suggested_price", "item_page", "market_page", "max_price_tradable", "max_price_non_tradable",
+		"mean_price_tradable", "mean_price_non_tradable", "median_price_tradable", "median_price_non_tradable",
+		"created_at", "updated_at", "spread", "spread_percent", "min_price_tradable_display", "min_price_non_tradable_display"},
+	// No default limit: the endpoint historically returns the whole catalogue
+	MaxLimit: 10000,
+}
+
+// skinportPrice returns the price or spread field of item, nil when it has none
+func skinportPrice(item skinport.ResponseItem, field string) *float64 {
+	switch field {
+	case "min_price_tradable":
+		return item.MinPriceTradable
+	case "min_price_non_tradable":
+		return item.MinPriceNonTradable
+	case "spread":
+		return item.Spread
+	case "spread_percent":
+		return item.SpreadPercent
+	}
+	return nil
+}
+
+// sortSkinportItems sorts in place; missing prices and spreads sort last regardless of
+// direction
+func sortSkinportItems(items []skinport.ResponseItem, fields []model.SortField) {
+	if len(fields) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(items, func(a, b skinport.ResponseItem) int {
+		for _, f := range fields {
+			var c int
+			switch f.Field {
+			case "market_hash_name":
+				c = strings.Compare(a.MarketHashName, b.MarketHashName)
+			case "quantity":
+				c = cmp.Compare(a.Quantity, b.Quantity)
+			case "created_at":
+				c = a.CreatedAt.Compare(b.CreatedAt)
+			case "updated_at":
+				c = a.UpdatedAt.Compare(b.UpdatedAt)
+			case "min_price_tradable", "min_price_non_tradable", "spread", "spread_percent":
+				pa, pb := skinportPrice(a, f.Field), skinportPrice(b, f.Field)
+				if (pa == nil) != (pb == nil) {
+					if pa == nil {
+						return 1
+					}
+					return -1
+				}
+				if pa != nil {
+					c = cmp.Compare(*pa, *pb)
+				}
+			}
+			if f.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
 }
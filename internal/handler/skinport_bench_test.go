@@ -0,0 +1,52 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fsanano/go-test/internal/handler"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+func BenchmarkGetSkinportItems(b *testing.B) {
+	items := make([]skinport.RawItem, 20000)
+	for i := range items {
+		price := float64(i%500) + 0.25
+		items[i] = skinport.RawItem{MarketHashName: fmt.Sprintf("Item-%05d", i), Currency: "EUR", MinPrice: &price, Quantity: i % 7}
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		b.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	h := handler.NewHandler(handler.Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	for _, query := range []string{
+		"",
+		"limit=100",
+		"limit=100&sort=-min_price_tradable,market_hash_name",
+		"limit=100&fields=market_hash_name,quantity",
+	} {
+		b.Run("query="+query, func(b *testing.B) {
+			// The first request fills the client cache
+			h.GetSkinportItems(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/skinport/items?"+query, nil))
+
+			b.ReportAllocs()
+			for b.Loop() {
+				w := httptest.NewRecorder()
+				h.GetSkinportItems(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items?"+query, nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
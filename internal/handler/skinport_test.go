@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/fx"
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkinportCacheAdmin(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1,"quantity":1}]`))
+	}))
+	defer upstream.Close()
+	clients := skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)
+	h := NewHandler(Dependencies{SkinportClients: clients, AdminToken: "secret"})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/skinport/items").Code)
+
+	w := do(http.MethodGet, "/v1/admin/skinport/cache")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Entries   []skinport.CacheEntry `json:"entries"`
+		SizeBytes int64                 `json:"size_bytes"`
+		MaxBytes  int64                 `json:"max_bytes"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	if assert.Len(t, body.Entries, 1) {
+		assert.Equal(t, "730:EUR", body.Entries[0].Key)
+		assert.Equal(t, 1, body.Entries[0].Items)
+		assert.Equal(t, body.Entries[0].SizeBytes, body.SizeBytes)
+		assert.False(t, body.Entries[0].LastUsedAt.IsZero())
+	}
+	assert.Zero(t, body.MaxBytes, "no budget")
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/admin/skinport/cache/730:EUR").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/v1/admin/skinport/cache/730:EUR").Code)
+	assert.Empty(t, clients.Shared().CacheEntries())
+
+	w = do(http.MethodPost, "/v1/admin/skinport/cache/refresh")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"items":1}`, w.Body.String())
+	assert.Len(t, clients.Shared().CacheEntries(), 1)
+
+	anonymous := httptest.NewRecorder()
+	h.ServeHTTP(anonymous, httptest.NewRequest(http.MethodPost, "/v1/admin/skinport/cache/refresh", nil))
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.NotEqual(t, http.StatusOK, do(http.MethodPost, "/v1/skinport/cache/refresh").Code, "only admins refresh the cache")
+}
+
+func TestSkinportApps(t *testing.T) {
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: "http://127.0.0.1:1"}, nil)})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/apps", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var apps []skinport.App
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apps))
+	assert.Contains(t, apps, skinport.App{ID: "252490", Name: "Rust"})
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items?app_id=123", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"unsupported app_id: 123","request_id":"`+w.Header().Get(RequestIDHeader)+`"}`, w.Body.String())
+}
+
+func TestSkinportItems_Currency(t *testing.T) {
+	var requested []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("currency"))
+		w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items?currency=%E2%82%AC", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"EUR", "EUR"}, requested)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/skinport/items?currency=xyz", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var body struct {
+		Error struct {
+			Message string                            `json:"message"`
+			Details skinport.UnsupportedCurrencyError `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, "unsupported currency: xyz", body.Error.Message)
+	assert.Equal(t, skinport.Currencies(), body.Error.Details.Allowed)
+	assert.Len(t, requested, 2, "invalid currencies are not forwarded")
+}
+
+func TestSkinportItems_Defaults(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Query().Get("app_id")+"/"+r.URL.Query().Get("currency"))
+		mu.Unlock()
+		w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	clients := skinport.NewFactory(skinport.Config{APIURL: upstream.URL, DefaultAppID: "570", DefaultCurrency: "usd"}, nil)
+	h := NewHandler(Dependencies{SkinportClients: clients})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "570", w.Header().Get("X-Default-App-ID"))
+	assert.Equal(t, "USD", w.Header().Get("X-Default-Currency"))
+	assert.Equal(t, []string{"570/USD", "570/USD"}, requested)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items?app_id=570&currency=EUR", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Default-App-ID"), "only applied defaults are named")
+	assert.Empty(t, w.Header().Get("X-Default-Currency"))
+
+	appID, currency := skinport.NewFactory(skinport.Config{}, nil).Defaults()
+	assert.Equal(t, "730", appID)
+	assert.Equal(t, "EUR", currency)
+}
+
+func TestSkinportItems_View(t *testing.T) {
+	var requested []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("tradable"))
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1.5,"quantity":2}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/items?view=tradable")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"true"}, requested, "one upstream request")
+	assert.JSONEq(t, `[{"market_hash_name":"Item A","currency":"EUR","slug":"","min_price_tradable":1.5,"min_price_non_tradable":null,"quantity":2}]`,
+		w.Body.String())
+
+	require.Equal(t, http.StatusOK, get("/v1/skinport/items?view=tradable").Code)
+	assert.Len(t, requested, 1, "served from its own cache entry")
+
+	require.Equal(t, http.StatusOK, get("/v1/skinport/items?view=nontradable").Code)
+	require.Equal(t, http.StatusOK, get("/v1/skinport/items").Code)
+	assert.Len(t, requested, 4, "nontradable and merged are cached apart")
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?view=both").Code)
+}
+
+func TestSkinportItemsLookup(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1.5,"quantity":2},
+			{"market_hash_name":"Item B","currency":"EUR","min_price":3,"quantity":1},
+			{"market_hash_name":"Item C","currency":"EUR","min_price":4,"quantity":1}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	lookup := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/skinport/items/lookup?view=tradable", strings.NewReader(body)))
+		return w
+	}
+
+	w := lookup(`{"market_hash_names":["Item C","Missing","Item A","Missing"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"items": {
+			"Item A": {"market_hash_name":"Item A","currency":"EUR","slug":"","min_price_tradable":1.5,"min_price_non_tradable":null,"quantity":2},
+			"Item C": {"market_hash_name":"Item C","currency":"EUR","slug":"","min_price_tradable":4,"min_price_non_tradable":null,"quantity":1}
+		},
+		"not_found": ["Missing"]
+	}`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, lookup(`{"market_hash_names":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, lookup(`[`).Code)
+	names, _ := json.Marshal(map[string]any{"market_hash_names": make([]string, maxLookupNames+1)})
+	assert.Equal(t, http.StatusBadRequest, lookup(string(names)).Code)
+}
+
+func TestSkinportItems_DisplayPrices(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1234.5,"quantity":2}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path, acceptLanguage string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Language", acceptLanguage)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/v1/skinport/items?view=tradable&locale=de-DE", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"Item A","currency":"EUR","slug":"","min_price_tradable":1234.5,
+		"min_price_non_tradable":null,"quantity":2,"min_price_tradable_display":"1.234,50 €"}]`, w.Body.String())
+
+	w = get("/v1/skinport/items?view=tradable&display_prices=true&fields=min_price_tradable_display", "fr-CA,fr;q=0.9")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"min_price_tradable_display":"1 234,50 €"}]`, w.Body.String())
+
+	w = get("/v1/skinport/items?view=tradable", "de")
+	assert.NotContains(t, w.Body.String(), "_display", "display prices are opt-in")
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?locale=xx", "").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?display_prices=maybe", "").Code)
+}
+
+func TestSkinportItems_Spread(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tradable") == "true" {
+			w.Write([]byte(`[{"market_hash_name":"A","currency":"EUR","min_price":10,"quantity":1},
+				{"market_hash_name":"B","currency":"EUR","min_price":20,"quantity":1},
+				{"market_hash_name":"C","currency":"EUR","min_price":5,"quantity":1}]`))
+			return
+		}
+		w.Write([]byte(`[{"market_hash_name":"A","currency":"EUR","min_price":8,"quantity":1},
+			{"market_hash_name":"B","currency":"EUR","min_price":19,"quantity":1}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/items?sort=-spread_percent&fields=market_hash_name,spread,spread_percent")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"A","spread":2,"spread_percent":20},{"market_hash_name":"B","spread":1,"spread_percent":5},
+		{"market_hash_name":"C"}]`, w.Body.String(), "items without a spread sort last")
+
+	w = get("/v1/skinport/items?min_spread_percent=10&fields=market_hash_name")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"A"}]`, w.Body.String())
+
+	w = get("/v1/skinport/items?min_spread=1&sort=spread&fields=market_hash_name")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"B"},{"market_hash_name":"A"}]`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?min_spread=cheap").Code)
+}
+
+func TestSkinportItems_UpdatedSince(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Old","currency":"EUR","min_price":1,"quantity":1,"created_at":1700000000,"updated_at":1750000000},
+			{"market_hash_name":"New","currency":"EUR","min_price":1,"quantity":1,"created_at":1700000000,"updated_at":1760000000},
+			{"market_hash_name":"Unknown","currency":"EUR","min_price":1,"quantity":1}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/items?view=tradable&updated_since=2025-10-01T00:00:00Z&fields=market_hash_name,updated_at")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"New","updated_at":"2025-10-09T08:53:20Z"}]`, w.Body.String())
+
+	w = get("/v1/skinport/items?view=tradable&sort=-updated_at&fields=market_hash_name")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"New"},{"market_hash_name":"Old"},{"market_hash_name":"Unknown"}]`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?updated_since=yesterday").Code)
+}
+
+func TestSkinportItem(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","slug":"item-a","min_price":1.5,"quantity":2}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/v1/skinport/items/item-a?view=tradable", "/v1/skinport/items/by-name?view=tradable&market_hash_name=Item+A"} {
+		w := get(path)
+		require.Equal(t, http.StatusOK, w.Code, path)
+		var body struct {
+			Item       skinport.ResponseItem `json:"item"`
+			FetchedAt  time.Time             `json:"fetched_at"`
+			ExpiresAt  time.Time             `json:"expires_at"`
+			AgeSeconds int64                 `json:"age_seconds"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "Item A", body.Item.MarketHashName)
+		assert.False(t, body.FetchedAt.IsZero())
+		assert.True(t, body.ExpiresAt.After(body.FetchedAt))
+	}
+
+	w := get("/v2/skinport/items/item-b?view=tradable")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var notFound struct {
+		Error struct {
+			Code    string                     `json:"code"`
+			Details skinport.ItemNotFoundError `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&notFound))
+	assert.Equal(t, "skinport_item_not_found", notFound.Error.Code)
+	assert.Equal(t, "item-b", notFound.Error.Details.Slug)
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items/by-name").Code)
+}
+
+func TestSkinportStats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1.5,"quantity":2},
+			{"market_hash_name":"Item B","currency":"EUR","min_price":3,"quantity":1}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/stats?view=tradable&under=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 2.0, body["listings"])
+	assert.Equal(t, 2.25, body["mean_price"])
+	assert.Equal(t, map[string]any{"threshold": 2.0, "items": 1.0}, body["under_price"])
+	assert.Equal(t, []any{}, body["top_movers"])
+
+	assert.NotContains(t, get("/v1/skinport/stats?view=tradable").Body.String(), "under_price")
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/stats?under=NaN").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/stats?under=-1").Code)
+}
+
+func TestSkinportDiff(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":1.5,"quantity":2}]`))
+	}))
+	defer upstream.Close()
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL, SnapshotHistory: 1}, nil)})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/snapshots?view=tradable")
+	require.Equal(t, http.StatusOK, w.Code)
+	var snapshots []skinport.SnapshotInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshots))
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, 1, snapshots[0].Items)
+
+	w = get("/v2/skinport/diff?view=tradable")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"skinport_snapshot_not_found"`)
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/diff?from=abc").Code)
+}
+
+func TestSkinportItems_ConvertTo(t *testing.T) {
+	var requested []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("currency"))
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","min_price":10,"quantity":2}]`))
+	}))
+	defer upstream.Close()
+	converter, err := fx.NewConverter(fx.Config{Provider: "static", StaticRates: "USD=1.0842,JPY=162.3"})
+	require.NoError(t, err)
+	h := NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil), FX: converter})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/skinport/items?view=tradable&convert_to=usd")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"market_hash_name":"Item A","currency":"USD","slug":"","min_price_tradable":10.84,"min_price_non_tradable":null,"quantity":2}]`,
+		w.Body.String())
+
+	// Currencies Skinport does not price in are converted too, all from the cached EUR items
+	w = get("/v1/skinport/items?view=tradable&convert_to=JPY")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"min_price_tradable":1623`)
+	w = get("/v1/skinport/items?view=tradable")
+	assert.Contains(t, w.Body.String(), `"min_price_tradable":10`, "the cached prices are not converted")
+	assert.Equal(t, []string{"EUR"}, requested)
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?convert_to=GBP").Code, "no GBP rate")
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?convert_to=dollars").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/v1/skinport/items?currency=EUR&convert_to=USD").Code)
+
+	h = NewHandler(Dependencies{SkinportClients: skinport.NewFactory(skinport.Config{APIURL: upstream.URL}, nil)})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/skinport/items?convert_to=USD", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "conversion is disabled without a provider")
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/steam"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SteamHandler struct {
+	svc *service.SteamService
+}
+
+func NewSteamHandler(svc *service.SteamService) *SteamHandler {
+	return &SteamHandler{svc: svc}
+}
+
+// Login redirects to Steam's sign-in page, which returns to Callback
+func (h *SteamHandler) Login(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, h.svc.LoginURL(), http.StatusFound)
+}
+
+// Callback completes a Steam login and returns the user, 201 when it was just created
+func (h *SteamHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	login, err := h.svc.Login(r.Context(), r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if login.Created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, login)
+}
+
+// GetAccount returns the Steam account linked to the user
+func (h *SteamHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	account, err := h.svc.GetAccount(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+type linkSteamRequest struct {
+	SteamID string `json:"steam_id"`
+}
+
+// Link links a Steam account to the user without a Steam login
+func (h *SteamHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	var req linkSteamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	account, err := h.svc.Link(r.Context(), userID, req.SteamID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// Unlink removes the user's Steam account
+func (h *SteamHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.svc.Unlink(r.Context(), userID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInventory returns the CS2 inventory of the user's linked Steam account valued at
+// current Skinport prices
+func (h *SteamHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	inventory, err := h.svc.Inventory(r.Context(), userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inventory)
+}
+
+func (h *SteamHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, steam.ErrInvalidAssertion):
+		writeError(w, r, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, steam.ErrPrivateInventory), errors.Is(err, repository.ErrUserInactive):
+		writeError(w, r, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrValidation):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrSteamAccountNotFound), err.Error() == "user not found":
+		writeError(w, r, http.StatusNotFound, err.Error())
+	case err.Error() == "steam account is linked to another user":
+		writeError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, steam.ErrInventoryUnavailable):
+		writeError(w, r, http.StatusBadGateway, steam.ErrInventoryUnavailable.Error())
+	default:
+		writeInternalError(w, r, err)
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TelegramHandler struct {
+	svc *service.TelegramService
+}
+
+func NewTelegramHandler(svc *service.TelegramService) *TelegramHandler {
+	return &TelegramHandler{svc: svc}
+}
+
+// IssueLinkToken creates a one-time token the user sends to the bot (/link <token>)
+// to link their Telegram chat
+func (h *TelegramHandler) IssueLinkToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	token, err := h.svc.IssueLinkToken(r.Context(), userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, token)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/tenant"
+)
+
+// TenantOptions selects the shop a request is served for
+type TenantOptions struct {
+	// Header names the request header carrying the shop's slug; empty ignores headers
+	Header string
+	// BaseDomain resolves the slug from the subdomain of hosts under it, e.g. acme for
+	// acme.shop.example with shop.example; empty ignores hosts
+	BaseDomain string
+	// Default is the slug of requests that select no shop
+	Default string
+}
+
+// tenantSlug returns the slug a request selects: the header, else the subdomain of
+// BaseDomain, else Default
+func tenantSlug(r *http.Request, opts TenantOptions) string {
+	if opts.Header != "" {
+		if slug := strings.TrimSpace(r.Header.Get(opts.Header)); slug != "" {
+			return strings.ToLower(slug)
+		}
+	}
+	if opts.BaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if sub, ok := strings.CutSuffix(host, "."+strings.ToLower(opts.BaseDomain)); ok && sub != "" {
+			return sub
+		}
+	}
+	return opts.Default
+}
+
+// tenantScope scopes the request's repository queries to the shop it selects (see
+// tenantSlug). Requests for an unknown shop are answered 404.
+func tenantScope(resolve func(ctx context.Context, slug string) (int, error), opts TenantOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := resolve(r.Context(), tenantSlug(r, opts))
+			if err != nil {
+				if err.Error() == "tenant not found" {
+					writeError(w, r, http.StatusNotFound, "unknown tenant")
+					return
+				}
+				writeInternalError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(tenant.WithID(r.Context(), id)))
+		})
+	}
+}
+
+type TenantHandler struct {
+	svc *service.TenantService
+}
+
+func NewTenantHandler(svc *service.TenantService) *TenantHandler {
+	return &TenantHandler{svc: svc}
+}
+
+func (h *TenantHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.svc.ListTenants(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tenants)
+}
+
+func (h *TenantHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var t model.Tenant
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.svc.CreateTenant(r.Context(), &t); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err.Error() == "tenant already exists" {
+			writeError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// GetSkinportCredentials describes the Skinport credentials of the request's tenant; the
+// API key is never returned
+func (h *TenantHandler) GetSkinportCredentials(w http.ResponseWriter, r *http.Request) {
+	id, _ := tenant.IDFrom(r.Context())
+	creds, err := h.svc.GetSkinportCredentials(r.Context(), id)
+	if err != nil {
+		if err.Error() == "skinport credentials not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// SetSkinportCredentials makes the request's tenant fetch Skinport prices with its own
+// client ID and API key
+func (h *TenantHandler) SetSkinportCredentials(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ClientID string `json:"client_id"`
+		APIKey   string `json:"api_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	id, _ := tenant.IDFrom(r.Context())
+	creds, err := h.svc.SetSkinportCredentials(r.Context(), id, req.ClientID, req.APIKey)
+	if err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// DeleteSkinportCredentials makes the request's tenant use the deployment's Skinport
+// credentials again
+func (h *TenantHandler) DeleteSkinportCredentials(w http.ResponseWriter, r *http.Request) {
+	id, _ := tenant.IDFrom(r.Context())
+	if err := h.svc.DeleteSkinportCredentials(r.Context(), id); err != nil {
+		if err.Error() == "skinport credentials not found" {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
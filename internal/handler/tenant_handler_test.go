@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantSlug(t *testing.T) {
+	opts := TenantOptions{Header: "X-Tenant", BaseDomain: "shop.example", Default: "default"}
+
+	tests := []struct {
+		name   string
+		host   string
+		header string
+		want   string
+	}{
+		{"header wins", "acme.shop.example", "Globex", "globex"},
+		{"subdomain", "acme.shop.example", "", "acme"},
+		{"subdomain with port", "Acme.Shop.Example:8080", "", "acme"},
+		{"base domain itself", "shop.example", "", "default"},
+		{"other host", "localhost:8080", "", "default"},
+		{"lookalike host", "acmeshop.example", "", "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/items", nil)
+			r.Host = tt.host
+			if tt.header != "" {
+				r.Header.Set("X-Tenant", tt.header)
+			}
+			assert.Equal(t, tt.want, tenantSlug(r, opts))
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/items", nil)
+	r.Host = "acme.shop.example"
+	r.Header.Set("X-Tenant", "globex")
+	assert.Equal(t, "default", tenantSlug(r, TenantOptions{Default: "default"}), "header and host ignored when not configured")
+}
+
+func TestTenantScope(t *testing.T) {
+	resolve := func(_ context.Context, slug string) (int, error) {
+		switch slug {
+		case "acme":
+			return 2, nil
+		case "broken":
+			return 0, errors.New("connection refused")
+		}
+		return 0, errors.New("tenant not found")
+	}
+	var scoped int
+	h := tenantScope(resolve, TenantOptions{Header: "X-Tenant", Default: "acme"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scoped, _ = tenant.IDFrom(r.Context())
+	}))
+
+	serve := func(slug string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/items", nil)
+		r.Header.Set("X-Tenant", slug)
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(""))
+	assert.Equal(t, 2, scoped)
+	assert.Equal(t, http.StatusNotFound, serve("globex"))
+	assert.Equal(t, http.StatusInternalServerError, serve("broken"))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIVersion is the major version of the REST API a request was routed through.
+// Every version is served by the same handlers and services; handlers branch on
+// apiVersion only where a response shape changed.
+type APIVersion int
+
+const (
+	// APIv1 keeps the legacy shapes: {"error": "..."} errors, plain-text /buy errors
+	// and a bare {"status": "success"} purchase response
+	APIv1 APIVersion = 1
+	// APIv2 wraps errors in errorEnvelope and returns the created order from /buy
+	APIv2 APIVersion = 2
+)
+
+type apiVersionKey struct{}
+
+// withAPIVersion tags requests with the version of the route prefix they came in on
+func withAPIVersion(v APIVersion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("API-Version", strconv.Itoa(int(v)))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, v)))
+		})
+	}
+}
+
+// apiVersion returns the request's API version, v1 for requests outside a versioned prefix
+func apiVersion(r *http.Request) APIVersion {
+	if v, ok := r.Context().Value(apiVersionKey{}).(APIVersion); ok {
+		return v
+	}
+	return APIv1
+}
+
+// errorEnvelope is the v2 error body
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	// Code is a stable, machine-readable identifier derived from the status
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	// RequestID is the X-Request-ID of the failed request, for support and log correlation
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// errorCode turns a status into a snake_case code: 404 -> not_found
+func errorCode(status int) string {
+	if status == StatusClientClosedRequest {
+		return "client_closed_request"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
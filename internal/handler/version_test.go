@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/buildinfo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersions_ErrorShapes(t *testing.T) {
+	h := NewHandler(Dependencies{ShopHandler: NewShopHandler(nil)})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantVersion string
+	}{
+		{"v1 json error", http.MethodGet, "/v1/admin/stats", "", http.StatusForbidden, `{"error":"admin api disabled","request_id":"req-1"}`, "1"},
+		{"v2 envelope", http.MethodGet, "/v2/admin/stats", "", http.StatusForbidden,
+			`{"error":{"code":"forbidden","message":"admin api disabled","request_id":"req-1"}}`, "2"},
+		{"v1 buy keeps plain text", http.MethodPost, "/v1/buy", "{", http.StatusBadRequest, "invalid request body", "1"},
+		{"v2 buy envelope", http.MethodPost, "/v2/buy", "{", http.StatusBadRequest,
+			`{"error":{"code":"bad_request","message":"invalid request body","request_id":"req-1"}}`, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set(RequestIDHeader, "req-1")
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantVersion, w.Header().Get("API-Version"))
+			assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+			if strings.HasPrefix(tt.wantBody, "{") {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			} else {
+				assert.Equal(t, tt.wantBody, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
+
+func TestErrorMessages_Localized(t *testing.T) {
+	h := NewHandler(Dependencies{ShopHandler: NewShopHandler(nil)})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v2/buy", strings.NewReader("{"))
+	r.Header.Set(RequestIDHeader, "req-1")
+	r.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+	assert.JSONEq(t, `{"error":{"code":"bad_request","message":"Ungültiger Anfrageinhalt","request_id":"req-1"}}`, w.Body.String())
+
+	// Without a translation of the message the code's stands in
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/v1/admin/stats", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	r.Header.Set("Accept-Language", "fr")
+	h.ServeHTTP(w, r)
+	assert.JSONEq(t, `{"error":"Accès refusé","request_id":"req-1"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/v2/admin/stats", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	r.Header.Set("Accept-Language", "ja")
+	h.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Language"))
+	assert.JSONEq(t, `{"error":{"code":"forbidden","message":"admin api disabled","request_id":"req-1"}}`, w.Body.String())
+}
+
+func TestAPIVersions_GraphQLOnlyOnV1(t *testing.T) {
+	graphql := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	h := NewHandler(Dependencies{GraphQL: graphql})
+
+	for path, want := range map[string]int{"/v1/graphql": http.StatusNoContent, "/v2/graphql": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		assert.Equal(t, want, w.Code, path)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "not_found", errorCode(http.StatusNotFound))
+	assert.Equal(t, "too_many_requests", errorCode(http.StatusTooManyRequests))
+	assert.Equal(t, "gateway_timeout", errorCode(http.StatusGatewayTimeout))
+	assert.Equal(t, "client_closed_request", errorCode(StatusClientClosedRequest))
+}
+
+func TestGetVersion(t *testing.T) {
+	h := NewHandler(Dependencies{})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var info buildinfo.Info
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, buildinfo.Get(), info)
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	get := func(h *Handler, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	h := NewHandler(Dependencies{AdminToken: "secret", Debug: true})
+	assert.Equal(t, http.StatusUnauthorized, get(h, "/debug/pprof/heap", "").Code)
+	assert.Equal(t, http.StatusOK, get(h, "/debug/pprof/heap", "secret").Code)
+	w := get(h, "/debug/vars", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"memstats"`)
+
+	h = NewHandler(Dependencies{AdminToken: "secret"})
+	assert.Equal(t, http.StatusNotFound, get(h, "/debug/pprof/heap", "secret").Code, "off by default")
+}
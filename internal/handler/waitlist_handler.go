@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// joinWaitlist answers a purchase refused for lack of stock by putting the user on the
+// item's waitlist. It returns false, having written nothing, when the waitlist is
+// disabled so the purchase's error is answered instead.
+func (h *ShopHandler) joinWaitlist(w http.ResponseWriter, r *http.Request, userID, itemID, quantity int) bool {
+	entry, err := h.svc.JoinWaitlist(r.Context(), userID, itemID, quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWaitlistDisabled):
+			return false
+		case errors.Is(err, repository.ErrAlreadyWaitlisted):
+			writeError(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrValidation), err.Error() == "user not found", err.Error() == "item not found":
+			writeError(w, r, http.StatusBadRequest, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return true
+	}
+
+	if apiVersion(r) >= APIv2 {
+		writeJSON(w, http.StatusAccepted, entry)
+		return true
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status": "waitlisted"}`))
+	return true
+}
+
+// ListWaitlist returns the user's waitlist entries, newest first
+func (h *ShopHandler) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	entries, err := h.svc.ListWaitlist(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrWaitlistDisabled) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// LeaveWaitlist cancels one of the user's waiting entries
+func (h *ShopHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	entryID, err := strconv.Atoi(chi.URLParam(r, "entryID"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid waitlist entry id")
+		return
+	}
+
+	entry, err := h.svc.LeaveWaitlist(r.Context(), userID, entryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWaitlistDisabled), err.Error() == "waitlist entry not found":
+			writeError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, repository.ErrWaitlistEntryClosed):
+			writeError(w, r, http.StatusConflict, err.Error())
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
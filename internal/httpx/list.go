@@ -0,0 +1,273 @@
+// Package httpx implements the listing conventions shared by collection endpoints:
+// opaque cursor pagination (?limit=&cursor=), sorting (?sort=-price,name)
+// and sparse fieldsets (?fields=id,name,price).
+package httpx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+)
+
+// ErrInvalidCursor is returned for malformed cursors or cursors issued for another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListSpec declares what a collection endpoint supports
+type ListSpec struct {
+	// Sortable lists field names accepted by ?sort
+	Sortable []string
+	// DefaultSort is used when ?sort is absent, e.g. "id" or "-created_at"
+	DefaultSort string
+	// Fields lists field names accepted by ?fields
+	Fields []string
+	// DefaultLimit applies when ?limit is absent; 0 returns everything
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// ListParams is the parsed listing query
+type ListParams struct {
+	model.ListOptions
+	// Fields is the sparse fieldset, empty means all fields
+	Fields []string
+	// sortKey is the canonical ?sort value, bound into cursors
+	sortKey string
+}
+
+// ParseListParams reads limit, cursor, sort and fields from the query string
+func ParseListParams(r *http.Request, spec ListSpec) (ListParams, error) {
+	return ParseListQuery(r.URL.Query(), spec)
+}
+
+// ParseListQuery is ParseListParams for callers without an *http.Request (e.g. GraphQL arguments)
+func ParseListQuery(q url.Values, spec ListSpec) (ListParams, error) {
+	var p ListParams
+
+	sortRaw := q.Get("sort")
+	if sortRaw == "" {
+		sortRaw = spec.DefaultSort
+	}
+	sort, err := ParseSort(sortRaw, spec.Sortable)
+	if err != nil {
+		return p, err
+	}
+	p.Sort = sort
+	p.sortKey = FormatSort(sort)
+
+	p.Fields, err = ParseFields(q.Get("fields"), spec.Fields)
+	if err != nil {
+		return p, err
+	}
+
+	p.Limit = spec.DefaultLimit
+	if v := q.Get("limit"); v != "" {
+		p.Limit, err = strconv.Atoi(v)
+		if err != nil || p.Limit <= 0 {
+			return p, errors.New("limit must be a positive integer")
+		}
+	}
+	if spec.MaxLimit > 0 && p.Limit > spec.MaxLimit {
+		p.Limit = spec.MaxLimit
+	}
+
+	if token := q.Get("cursor"); token != "" {
+		c, err := decodeCursor(token)
+		if err != nil || c.Sort != p.sortKey {
+			return p, ErrInvalidCursor
+		}
+		p.After = c.Values
+		p.Offset = c.Offset
+	}
+
+	return p, nil
+}
+
+// ParseSort parses "-price,name" into sort fields, rejecting fields not in allowed
+func ParseSort(raw string, allowed []string) ([]model.SortField, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []model.SortField
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+		if !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("cannot sort by %q (allowed: %s)", name, strings.Join(allowed, ", "))
+		}
+		if slices.ContainsFunc(fields, func(f model.SortField) bool { return f.Field == name }) {
+			return nil, fmt.Errorf("duplicate sort field %q", name)
+		}
+		fields = append(fields, model.SortField{Field: name, Desc: desc})
+	}
+	return fields, nil
+}
+
+// FormatSort is the inverse of ParseSort
+func FormatSort(fields []model.SortField) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseFields parses "id,name" into a sparse fieldset, rejecting fields not in allowed
+func ParseFields(raw string, allowed []string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("unknown field %q (allowed: %s)", name, strings.Join(allowed, ", "))
+		}
+		if !slices.Contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v,omitempty"`
+	Offset int      `json:"o,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// KeysetCursor builds the cursor continuing after last, a row of a keyset-paginated listing.
+// Values are taken from last's JSON representation for every sort field plus tiebreaker.
+func (p ListParams) KeysetCursor(last any, tiebreaker string) (string, error) {
+	row, err := jsonFields(last)
+	if err != nil {
+		return "", err
+	}
+
+	c := cursor{Sort: p.sortKey}
+	for _, f := range p.KeysetFields(tiebreaker) {
+		c.Values = append(c.Values, jsonScalar(row[f.Field]))
+	}
+	return encodeCursor(c), nil
+}
+
+// KeysetFields returns the sort fields with tiebreaker appended (ascending) unless already present,
+// giving a total order for keyset pagination
+func (p ListParams) KeysetFields(tiebreaker string) []model.SortField {
+	fields := slices.Clone(p.Sort)
+	if !slices.ContainsFunc(fields, func(f model.SortField) bool { return f.Field == tiebreaker }) {
+		fields = append(fields, model.SortField{Field: tiebreaker})
+	}
+	return fields
+}
+
+// OffsetCursor builds the cursor for in-memory listings paginated by position
+func (p ListParams) OffsetCursor(offset int) string {
+	return encodeCursor(cursor{Sort: p.sortKey, Offset: offset})
+}
+
+// SetNextCursor advertises the next page via X-Next-Cursor and a Link rel="next" header,
+// leaving response bodies unchanged for existing clients
+func SetNextCursor(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	u := url.URL{Path: r.URL.Path}
+	q := r.URL.Query()
+	q.Set("cursor", next)
+	u.RawQuery = q.Encode()
+
+	w.Header().Set("X-Next-Cursor", next)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+}
+
+// SelectFields applies a sparse fieldset to a value or slice of values,
+// returning it unchanged when fields is empty
+func SelectFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	pick := func(row map[string]json.RawMessage) map[string]json.RawMessage {
+		out := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if val, ok := row[f]; ok {
+				out[f] = val
+			}
+		}
+		return out
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		var rows []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+		out := make([]map[string]json.RawMessage, len(rows))
+		for i, row := range rows {
+			out[i] = pick(row)
+		}
+		return out, nil
+	}
+
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	return pick(row), nil
+}
+
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var row map[string]json.RawMessage
+	err = json.Unmarshal(data, &row)
+	return row, err
+}
+
+// jsonScalar renders a JSON scalar as a plain string (strings unquoted, null as "")
+func jsonScalar(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
@@ -0,0 +1,97 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSpec = ListSpec{
+	Sortable:     []string{"id", "name", "price"},
+	DefaultSort:  "id",
+	Fields:       []string{"id", "name", "price"},
+	DefaultLimit: 10,
+	MaxLimit:     50,
+}
+
+func TestParseSort(t *testing.T) {
+	fields, err := ParseSort("-price, name", testSpec.Sortable)
+	require.NoError(t, err)
+	assert.Equal(t, []model.SortField{{Field: "price", Desc: true}, {Field: "name"}}, fields)
+	assert.Equal(t, "-price,name", FormatSort(fields))
+
+	_, err = ParseSort("stock", testSpec.Sortable)
+	assert.Error(t, err)
+
+	_, err = ParseSort("id,-id", testSpec.Sortable)
+	assert.Error(t, err)
+}
+
+func TestParseListParams(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?limit=500&fields=name,id,name", nil)
+	p, err := ParseListParams(r, testSpec)
+	require.NoError(t, err)
+	assert.Equal(t, 50, p.Limit)
+	assert.Equal(t, []model.SortField{{Field: "id"}}, p.Sort)
+	assert.Equal(t, []string{"name", "id"}, p.Fields)
+
+	for _, query := range []string{"limit=0", "limit=x", "fields=secret", "sort=secret", "cursor=not-base64!"} {
+		_, err := ParseListParams(httptest.NewRequest("GET", "/items?"+query, nil), testSpec)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestKeysetCursorRoundTrip(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?sort=-price", nil)
+	p, err := ParseListParams(r, testSpec)
+	require.NoError(t, err)
+
+	next, err := p.KeysetCursor(map[string]any{"id": 7, "name": "AK", "price": 12.5}, "id")
+	require.NoError(t, err)
+
+	r = httptest.NewRequest("GET", "/items?sort=-price&cursor="+next, nil)
+	p, err = ParseListParams(r, testSpec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12.5", "7"}, p.After)
+
+	// A cursor is bound to the sort order it was issued for
+	r = httptest.NewRequest("GET", "/items?sort=name&cursor="+next, nil)
+	_, err = ParseListParams(r, testSpec)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestSetNextCursor(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/items?limit=2&sort=name", nil)
+	w := httptest.NewRecorder()
+
+	SetNextCursor(w, r, "abc")
+	assert.Equal(t, "abc", w.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, `</v1/items?cursor=abc&limit=2&sort=name>; rel="next"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	SetNextCursor(w, r, "")
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+}
+
+func TestSelectFields(t *testing.T) {
+	type row struct {
+		ID    int     `json:"id"`
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}
+
+	out, err := SelectFields([]row{{1, "AK", 10}}, []string{"name"})
+	require.NoError(t, err)
+	assert.Len(t, out, 1)
+	assert.Equal(t, `"AK"`, string(out.([]map[string]json.RawMessage)[0]["name"]))
+	assert.NotContains(t, out.([]map[string]json.RawMessage)[0], "id")
+
+	same, err := SelectFields(row{ID: 1}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, row{ID: 1}, same)
+}
@@ -0,0 +1,139 @@
+package i18n
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"fsanano/go-test/internal/model"
+)
+
+// DefaultLocale formats prices for requests that prefer no supported locale
+const DefaultLocale = "en"
+
+// numberFormat is how a locale writes amounts of money
+type numberFormat struct {
+	decimal, group string
+	// symbolAfter writes "10,50 €" rather than "€10.50"
+	symbolAfter bool
+	// spaced separates the symbol from the amount, as in "€ 10,50"
+	spaced bool
+}
+
+// numberFormats are the locales prices can be formatted for, by lower-case tag
+var numberFormats = map[string]numberFormat{
+	"en":    {decimal: ".", group: ","},
+	"en-gb": {decimal: ".", group: ","},
+	"de":    {decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"de-ch": {decimal: ".", group: "’", spaced: true},
+	"fr":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"es":    {decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"it":    {decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"nl":    {decimal: ",", group: ".", spaced: true},
+	"pt":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"pt-br": {decimal: ",", group: ".", spaced: true},
+	"pl":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"cs":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"da":    {decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"sv":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"nb":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"ru":    {decimal: ",", group: " ", symbolAfter: true, spaced: true},
+	"tr":    {decimal: ",", group: "."},
+	"ja":    {decimal: ".", group: ","},
+	"zh":    {decimal: ".", group: ","},
+}
+
+// currencySymbols are the symbols prices are shown with; other currencies show their code
+var currencySymbols = map[string]string{
+	"AUD": "A$", "BRL": "R$", "CAD": "C$", "CHF": "CHF", "CNY": "¥", "CZK": "Kč", "DKK": "kr", "EUR": "€",
+	"GBP": "£", "HRK": "kn", "JPY": "¥", "NOK": "kr", "PLN": "zł", "RUB": "₽", "SEK": "kr", "TRY": "₺", "USD": "$",
+}
+
+// Locales returns the locales prices can be formatted for
+func Locales() []string {
+	locales := make([]string, 0, len(numberFormats))
+	for tag := range numberFormats {
+		locales = append(locales, tag)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// ResolveLocale returns the supported locale of tag, by full tag or primary subtag
+// (de for de-AT); false when neither is supported
+func ResolveLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if _, ok := numberFormats[tag]; ok {
+		return tag, true
+	}
+	if primary, _, _ := strings.Cut(tag, "-"); primary != tag {
+		if _, ok := numberFormats[primary]; ok {
+			return primary, true
+		}
+	}
+	return "", false
+}
+
+// NegotiateLocale picks the locale of an Accept-Language header to format prices for,
+// DefaultLocale when it prefers no supported one
+func NegotiateLocale(acceptLanguage string) string {
+	if locale := negotiate(acceptLanguage, func(tag string) bool {
+		_, ok := numberFormats[tag]
+		return ok
+	}); locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// FormatMoney formats m for display in locale, with its currency's symbol and decimals:
+// "$1,234.50" in en, "1.234,50 €" in de. Unsupported locales format as DefaultLocale.
+func FormatMoney(locale string, m model.Money) string {
+	f, ok := numberFormats[locale]
+	if !ok {
+		f = numberFormats[DefaultLocale]
+	}
+	currency := strings.ToUpper(m.Currency)
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+
+	// Money.String has the currency's decimals and no float artifacts
+	amount := m.String()
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+	whole, frac, _ := strings.Cut(amount, ".")
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(digit)
+	}
+	if frac != "" {
+		b.WriteString(f.decimal)
+		b.WriteString(frac)
+	}
+	number := b.String()
+
+	sep := ""
+	if f.spaced || f.symbolAfter || endsWithLetter(symbol) {
+		sep = " "
+	}
+	if f.symbolAfter {
+		return sign + number + sep + symbol
+	}
+	return sign + symbol + sep + number
+}
+
+// endsWithLetter tells symbols such as CHF or kr, which need a space before the amount
+func endsWithLetter(symbol string) bool {
+	r := []rune(symbol)
+	return len(r) > 0 && unicode.IsLetter(r[len(r)-1])
+}
@@ -0,0 +1,135 @@
+// Package i18n localizes API responses. It translates error messages, keyed by the
+// error code of the response and loaded from the embedded locales/<language>.json files
+// (English is the language of the code base and needs no file), and formats prices for
+// display in the conventions of a locale.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Each locale file maps an error code to its translations: the "" key translates the
+// code itself, the other keys translate specific English messages sent with the code.
+//
+//	{"not_found": {"": "Nicht gefunden", "user not found": "Benutzer nicht gefunden"}}
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalog holds the translations by language, code and message
+var catalog = mustLoad()
+
+func mustLoad() map[string]map[string]map[string]string {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	locales := make(map[string]map[string]map[string]string, len(files))
+	for _, f := range files {
+		data, err := localeFS.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var codes map[string]map[string]string
+		if err := json.Unmarshal(data, &codes); err != nil {
+			panic(fmt.Sprintf("i18n: invalid locale file %s: %v", f.Name(), err))
+		}
+		locales[strings.TrimSuffix(f.Name(), ".json")] = codes
+	}
+	return locales
+}
+
+// Languages returns the languages with translations, English aside
+func Languages() []string {
+	langs := make([]string, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Negotiate picks the language of an Accept-Language header: the most preferred one
+// with translations, by full tag (pt-br) or primary subtag (de for de-AT). It returns
+// "" when English, or no language with translations, is preferred.
+func Negotiate(acceptLanguage string) string {
+	lang := negotiate(acceptLanguage, func(tag string) bool {
+		_, ok := catalog[tag]
+		return ok || tag == "en"
+	})
+	if lang == "en" {
+		return ""
+	}
+	return lang
+}
+
+// negotiate returns the most preferred tag of an Accept-Language header that supported
+// accepts, trying each full tag before its primary subtag; "" if there is none
+func negotiate(acceptLanguage string, supported func(tag string) bool) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, t := range tags {
+		if t.tag == "*" {
+			return ""
+		}
+		if supported(t.tag) {
+			return t.tag
+		}
+		if primary, _, _ := strings.Cut(t.tag, "-"); supported(primary) {
+			return primary
+		}
+	}
+	return ""
+}
+
+// ErrorMessage translates the message of an error with code into lang: the message's
+// own translation if there is one, else the code's. It reports false when lang has
+// neither, the English message then stands.
+func ErrorMessage(lang, code, message string) (string, bool) {
+	messages, ok := catalog[lang][code]
+	if !ok {
+		return "", false
+	}
+	if translated, ok := messages[message]; ok {
+		return translated, true
+	}
+	translated, ok := messages[""]
+	return translated, ok
+}
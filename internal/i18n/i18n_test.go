@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"de":                      "de",
+		"de-AT":                   "de",
+		"fr-CA, en;q=0.8":         "fr",
+		"en-US,en;q=0.9,de;q=0.8": "",
+		"ja, es;q=0.5":            "es",
+		"de;q=0.2, fr;q=0.9":      "fr",
+		"de;q=0, es;q=0.1":        "es",
+		"ja, zh":                  "",
+		"*":                       "",
+		"de;q=abc, fr":            "fr",
+		" ES ; q=1 ":              "es",
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, Negotiate(header), "%q", header)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	msg, ok := ErrorMessage("de", "not_found", "user not found")
+	assert.True(t, ok)
+	assert.Equal(t, "Benutzer nicht gefunden", msg)
+
+	msg, ok = ErrorMessage("de", "not_found", "steam account not found")
+	assert.True(t, ok)
+	assert.Equal(t, "Nicht gefunden", msg, "falls back to the code's translation")
+
+	_, ok = ErrorMessage("de", "teapot", "short and stout")
+	assert.False(t, ok)
+	_, ok = ErrorMessage("", "not_found", "user not found")
+	assert.False(t, ok, "English is not translated")
+}
+
+// TestLocales_Complete keeps the locale files in step: every language translates the
+// same codes and messages
+func TestLocales_Complete(t *testing.T) {
+	langs := Languages()
+	assert.Equal(t, []string{"de", "es", "fr"}, langs)
+	for _, lang := range langs[1:] {
+		assert.Equal(t, len(catalog[langs[0]]), len(catalog[lang]), lang)
+		for code, messages := range catalog[langs[0]] {
+			for message := range messages {
+				assert.Contains(t, catalog[lang][code], message, "%s: %s %q", lang, code, message)
+			}
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		locale string
+		amount float64
+		cur    string
+		want   string
+	}{
+		{"en", 10.5, "EUR", "€10.50"},
+		{"de", 10.5, "EUR", "10,50 €"},
+		{"en", 1234567.891, "USD", "$1,234,567.89"},
+		{"de", 1234.5, "EUR", "1.234,50 €"},
+		{"fr", 1234.5, "EUR", "1 234,50 €"},
+		{"nl", 10.5, "EUR", "€ 10,50"},
+		{"pt-br", 99.9, "BRL", "R$ 99,90"},
+		{"de-ch", 1234.5, "CHF", "CHF 1’234.50"},
+		{"en", 10.5, "CHF", "CHF 10.50"},
+		{"en", -0.05, "GBP", "-£0.05"},
+		{"en", 1500, "JPY", "¥1,500"},
+		{"en", 10, "XYZ", "XYZ 10.00"},
+		{"en", 999.995, "", "$1,000.00"},
+		{"xx", 10.5, "EUR", "€10.50"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatMoney(tt.locale, model.NewMoney(tt.amount, tt.cur)), "%s %v %s", tt.locale, tt.amount, tt.cur)
+	}
+}
+
+func TestLocaleSelection(t *testing.T) {
+	locale, ok := ResolveLocale("de_AT")
+	assert.True(t, ok)
+	assert.Equal(t, "de", locale)
+	locale, ok = ResolveLocale("pt-BR")
+	assert.True(t, ok)
+	assert.Equal(t, "pt-br", locale)
+	_, ok = ResolveLocale("xx")
+	assert.False(t, ok)
+	assert.Contains(t, Locales(), "en-gb")
+
+	assert.Equal(t, "de", NegotiateLocale("de-DE,de;q=0.9,en;q=0.8"))
+	assert.Equal(t, "en", NegotiateLocale("en-US"))
+	assert.Equal(t, "pt-br", NegotiateLocale("pt-BR"))
+	assert.Equal(t, DefaultLocale, NegotiateLocale("xx, yy"))
+}
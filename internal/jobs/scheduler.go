@@ -0,0 +1,192 @@
+// Package jobs runs recurring background tasks on a schedule, with panic recovery,
+// Prometheus metrics and optional cross-instance coordination through a Store.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+)
+
+// Schedule computes when a job runs next
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type every time.Duration
+
+// Every runs a job at a fixed interval
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// Job is a recurring task
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+	// Exclusive jobs run on a single instance per period, claimed through the Store.
+	// Jobs working on per-instance state (in-memory caches, local subscribers) must not be exclusive.
+	Exclusive bool
+	// Timeout bounds a single run (0 disables)
+	Timeout time.Duration
+}
+
+// Store persists job runs so exclusive jobs are coordinated across instances
+// and keep their schedule across restarts
+type Store interface {
+	// LastStarted returns when the job last started on any instance (zero if never)
+	LastStarted(ctx context.Context, name string) (time.Time, error)
+	// Claim records the start of a run unless one started after since, reporting whether the caller may run it
+	Claim(ctx context.Context, name string, since time.Time) (bool, error)
+	// Finish records the outcome of a claimed run
+	Finish(ctx context.Context, name string, runErr error) error
+}
+
+// PanicError is returned for a run that panicked
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
+// Run statuses reported in metrics
+const (
+	statusOK      = "ok"
+	statusError   = "error"
+	statusPanic   = "panic"
+	statusSkipped = "skipped"
+)
+
+type Scheduler struct {
+	store Store
+	jobs  []Job
+	wg    sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler; a nil store runs exclusive jobs on every instance
+func NewScheduler(store Store) *Scheduler {
+	return &Scheduler{store: store}
+}
+
+// Add registers a job; call it before Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job on its schedule until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+}
+
+// Wait blocks until all job loops have returned after ctx was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	next := time.Now()
+	// Resume the schedule of exclusive jobs instead of re-running them on every deploy
+	if job.Exclusive && s.store != nil {
+		last, err := s.store.LastStarted(ctx, job.Name)
+		if err != nil {
+			slog.Error("job: failed to read last run", "job", job.Name, "error", err)
+		} else if !last.IsZero() {
+			next = job.Schedule.Next(last)
+		}
+	}
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, job, next)
+		next = job.Schedule.Next(time.Now())
+		timer.Reset(time.Until(next))
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job, due time.Time) {
+	coordinated := job.Exclusive && s.store != nil
+	if coordinated {
+		// Another instance owns this period if it started the job within half a period of due
+		since := due.Add(-job.Schedule.Next(due).Sub(due) / 2)
+		claimed, err := s.store.Claim(ctx, job.Name, since)
+		if err != nil {
+			slog.Error("job: failed to claim run", "job", job.Name, "error", err)
+			return
+		}
+		if !claimed {
+			metrics.JobRuns.WithLabelValues(job.Name, statusSkipped).Inc()
+			return
+		}
+	}
+
+	start := time.Now()
+	err := run(ctx, job)
+	metrics.JobDuration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+
+	status := statusOK
+	var panicErr *PanicError
+	switch {
+	case err == nil:
+		metrics.JobLastSuccess.WithLabelValues(job.Name).SetToCurrentTime()
+	case errors.As(err, &panicErr):
+		status = statusPanic
+		slog.Error("job panicked", "job", job.Name, "panic", panicErr.Value, "stack", string(panicErr.Stack))
+	default:
+		status = statusError
+		if ctx.Err() == nil {
+			slog.Error("job failed", "job", job.Name, "error", err)
+		}
+	}
+	metrics.JobRuns.WithLabelValues(job.Name, status).Inc()
+
+	if coordinated {
+		// Record the outcome even when shutting down mid-run
+		if err := s.store.Finish(context.WithoutCancel(ctx), job.Name, err); err != nil {
+			slog.Error("job: failed to record run", "job", job.Name, "error", err)
+		}
+	}
+}
+
+// run executes one run of job, converting a panic into a *PanicError
+func run(ctx context.Context, job Job) (err error) {
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu       sync.Mutex
+	started  map[string]time.Time
+	finished map[string]error
+}
+
+func newMemStore() *memStore {
+	return &memStore{started: map[string]time.Time{}, finished: map[string]error{}}
+}
+
+func (m *memStore) LastStarted(_ context.Context, name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started[name], nil
+}
+
+func (m *memStore) Claim(_ context.Context, name string, since time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started[name].After(since) {
+		return false, nil
+	}
+	m.started[name] = time.Now()
+	return true, nil
+}
+
+func (m *memStore) Finish(_ context.Context, name string, runErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished[name] = runErr
+	return nil
+}
+
+func TestRun_RecoversPanic(t *testing.T) {
+	err := run(context.Background(), Job{Name: "boom", Run: func(context.Context) error {
+		panic("boom")
+	}})
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+}
+
+func TestRun_Timeout(t *testing.T) {
+	err := run(context.Background(), Job{Timeout: time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestScheduler_RunsJobsUntilCancelled(t *testing.T) {
+	var runs atomic.Int32
+	s := NewScheduler(nil)
+	s.Add(Job{Name: "tick", Schedule: Every(5 * time.Millisecond), Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	// A panicking job does not take the scheduler down
+	s.Add(Job{Name: "panics", Schedule: Every(5 * time.Millisecond), Run: func(context.Context) error {
+		panic("boom")
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+	cancel()
+	s.Wait()
+}
+
+func TestScheduler_ExclusiveJobRunsOncePerPeriod(t *testing.T) {
+	store := newMemStore()
+	var runs atomic.Int32
+	job := Job{Name: "exclusive", Schedule: Every(time.Hour), Exclusive: true, Run: func(context.Context) error {
+		runs.Add(1)
+		return errors.New("failed")
+	}}
+
+	// Two instances sharing the store
+	a, b := NewScheduler(store), NewScheduler(store)
+	a.Add(job)
+	b.Add(job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Start(ctx)
+	b.Start(ctx)
+	assert.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		_, ok := store.finished["exclusive"]
+		return ok
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	a.Wait()
+	b.Wait()
+
+	assert.Equal(t, int32(1), runs.Load())
+	assert.EqualError(t, store.finished["exclusive"], "failed")
+}
+
+func TestScheduler_ResumesExclusiveSchedule(t *testing.T) {
+	store := newMemStore()
+	store.started["recent"] = time.Now()
+
+	var runs atomic.Int32
+	s := NewScheduler(store)
+	s.Add(Job{Name: "recent", Schedule: Every(time.Hour), Exclusive: true, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	assert.Zero(t, runs.Load())
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector exported by the service.
+// A dedicated registry (instead of the global default one) keeps tests isolated
+// and makes the exported metric set explicit.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// DBQueryDuration tracks latency of every SQL statement executed through pgx.
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of SQL queries executed against PostgreSQL.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "status"})
+
+	// DBSlowQueries counts queries that exceeded the configured slow threshold.
+	DBSlowQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_slow_queries_total",
+		Help: "Number of SQL queries slower than the configured threshold.",
+	}, []string{"operation"})
+
+	// ServicePanics counts panics recovered inside service transactions, by operation.
+	ServicePanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "service_panics_total",
+		Help: "Number of panics recovered inside service transactions.",
+	}, []string{"op"})
+
+	// JobRuns counts background job runs by outcome (ok, error, panic, skipped).
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "Number of background job runs by outcome.",
+	}, []string{"job", "status"})
+
+	// JobDuration tracks how long background job runs take.
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_duration_seconds",
+		Help:    "Duration of background job runs.",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+
+	// JobLastSuccess records when each job last succeeded, for staleness alerts.
+	JobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "job_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful run of each background job.",
+	}, []string{"job"})
+
+	// SkinportRequests counts upstream Skinport requests by HTTP status ("error" when no response).
+	SkinportRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "skinport_requests_total",
+		Help: "Number of requests sent to the Skinport API by response status.",
+	}, []string{"status"})
+
+	// SkinportRateLimitRemaining is the request budget Skinport reported in its last response.
+	SkinportRateLimitRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "skinport_rate_limit_remaining",
+		Help: "Remaining Skinport requests in the current rate limit window, from the last response.",
+	})
+
+	// SkinportRequestsQueued is the number of Skinport requests waiting for a free slot of
+	// the concurrency limit.
+	SkinportRequestsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "skinport_requests_queued",
+		Help: "Number of Skinport requests waiting for the concurrency limit.",
+	})
+
+	// SkinportDecodeErrors counts the malformed items tolerant decoding skipped, by app.
+	SkinportDecodeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "skinport_decode_errors_total",
+		Help: "Number of malformed items skipped in Skinport responses by app_id.",
+	}, []string{"app_id"})
+
+	// SkinportCacheBytes is the estimated memory of each Skinport cache entry, snapshots
+	// included, summed over the clients caching the key.
+	SkinportCacheBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "skinport_cache_bytes",
+		Help: "Estimated memory held by Skinport cache entries by key.",
+	}, []string{"key"})
+
+	// SkinportCacheEvictions counts the Skinport cache entries evicted for the memory budget.
+	SkinportCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skinport_cache_evictions_total",
+		Help: "Number of Skinport cache entries evicted to stay within the memory budget.",
+	})
+
+	// PurchaseQueueWaiting is the number of purchases waiting for their item's turn.
+	PurchaseQueueWaiting = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "purchase_queue_waiting",
+		Help: "Number of purchases waiting in the per-item purchase queue.",
+	})
+
+	// PurchaseQueueRejected counts purchases shed by the per-item purchase queue, by reason
+	// (full or timeout).
+	PurchaseQueueRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "purchase_queue_rejected_total",
+		Help: "Purchases shed by the per-item purchase queue.",
+	}, []string{"reason"})
+
+	// SupplierRestocks counts the lines of supplier restock batches, by outcome (applied,
+	// duplicate or skipped).
+	SupplierRestocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supplier_restock_lines_total",
+		Help: "Lines of supplier restock batches, by outcome.",
+	}, []string{"outcome"})
+
+	// LedgerViolations is the number of broken ledger invariants found by the last ledger check, by type.
+	LedgerViolations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ledger_violations",
+		Help: "Broken ledger invariants found by the last ledger check.",
+	}, []string{"type"})
+
+	// EventsPublished counts events published on the in-process event bus, by topic.
+	EventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventbus_published_total",
+		Help: "Number of events published on the event bus by topic.",
+	}, []string{"topic"})
+
+	// EventsDropped counts events not delivered to a subscriber whose queue was full, by topic.
+	EventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventbus_dropped_total",
+		Help: "Number of events dropped because a subscriber's queue was full, by topic.",
+	}, []string{"topic"})
+
+	// ErrorReportsDropped counts error reports not sent because the reporter's queue was
+	// full, by kind.
+	ErrorReportsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "error_reports_dropped_total",
+		Help: "Number of error reports dropped because the reporter's queue was full, by kind.",
+	}, []string{"kind"})
+
+	// CacheRequests counts reads of the repository read cache by cache and result (hit, miss).
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "read_cache_requests_total",
+		Help: "Number of reads of the in-memory read cache by cache and result.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DBQueryDuration,
+		DBSlowQueries,
+		ServicePanics,
+		JobRuns,
+		JobDuration,
+		JobLastSuccess,
+		SkinportRequests,
+		SkinportRateLimitRemaining,
+		SkinportRequestsQueued,
+		SkinportDecodeErrors,
+		SkinportCacheBytes,
+		SkinportCacheEvictions,
+		PurchaseQueueWaiting,
+		PurchaseQueueRejected,
+		SupplierRestocks,
+		LedgerViolations,
+		EventsPublished,
+		EventsDropped,
+		ErrorReportsDropped,
+		CacheRequests,
+	)
+}
+
+// Handler exposes the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows an audit log query; zero values are ignored
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   string
+	Since      time.Time
+	Until      time.Time
+	// BeforeID returns entries older than this id (keyset pagination)
+	BeforeID int64
+	Limit    int
+}
@@ -0,0 +1,18 @@
+package model
+
+// SortField is one component of a sort order, e.g. "-price" is {Field: "price", Desc: true}
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions controls sorting and pagination of collection queries
+type ListOptions struct {
+	Sort []SortField
+	// After holds the keyset cursor values (sort fields then tiebreaker) of the last row seen
+	After []string
+	// Offset is used by in-memory listings instead of After
+	Offset int
+	// Limit is the page size, 0 means unlimited
+	Limit int
+}
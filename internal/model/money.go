@@ -0,0 +1,196 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DefaultCurrency is the currency of balances and shop prices
+const DefaultCurrency = "USD"
+
+// currencyDecimals lists the ISO 4217 currencies whose minor unit is not a cent
+var currencyDecimals = map[string]int{
+	"BHD": 3, "CLP": 0, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "OMR": 3, "TND": 3, "VND": 0,
+}
+
+// Money is an amount in minor units (cents for USD) of a currency. It is written to
+// JSON as a number with exactly the currency's decimals (10.5 USD is 10.50), so amounts
+// never show float artifacts such as 10.000000000001, and is read from and written to
+// NUMERIC columns without going through float64.
+type Money struct {
+	// Amount in minor units
+	Amount int64
+	// Currency is an ISO 4217 code, empty means DefaultCurrency
+	Currency string
+}
+
+// NewMoney converts amount in major units to Money, rounding to the currency's minor unit
+func NewMoney(amount float64, currency string) Money {
+	m := Money{Currency: currency}
+	m.Amount = int64(math.Round(amount * math.Pow10(m.decimals())))
+	return m
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func (m Money) decimals() int {
+	if d, ok := currencyDecimals[strings.ToUpper(m.currency())]; ok {
+		return d
+	}
+	return 2
+}
+
+// Float returns the amount in major units, for arithmetic that still works on float64
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow10(m.decimals())
+}
+
+// Mul returns the amount n times, such as the total of n units
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Scale returns the amount times f, such as a rate, rounded half away from zero to the
+// minor unit
+func (m Money) Scale(f float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * f)), Currency: m.Currency}
+}
+
+// Neg returns -m, such as the debit of an amount
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Add returns the sum of the amounts, in m's currency
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}
+}
+
+// Sub returns m minus o, in m's currency
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}
+}
+
+// String formats the amount in major units with the currency's decimals, e.g. "-0.05"
+func (m Money) String() string {
+	digits := strconv.FormatInt(m.Amount, 10)
+	sign := ""
+	if m.Amount < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	d := m.decimals()
+	if d == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d {
+		digits = strings.Repeat("0", d-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d] + "." + digits[len(digits)-d:]
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a number (or a string holding one) in major units, keeping the
+// currency already set. More decimals than the currency has are rejected.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	if s == "null" {
+		return nil
+	}
+	amount, err := m.parse(s)
+	if err != nil {
+		return err
+	}
+	m.Amount = amount
+	return nil
+}
+
+func (m Money) parse(s string) (int64, error) {
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+		return NewMoney(f, m.Currency).Amount, nil
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	d := m.decimals()
+	if len(frac) > d {
+		return 0, fmt.Errorf("amount %s has more than %d decimals", s, d)
+	}
+	amount, err := strconv.ParseInt(whole+frac+strings.Repeat("0", d-len(frac)), 10, 64)
+	if err != nil || whole == "" || whole == "-" || strings.HasPrefix(frac, "-") || strings.HasPrefix(frac, "+") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// ScanNumeric reads a NUMERIC column, rounding half away from zero to the minor unit
+func (m *Money) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		return errors.New("cannot scan NULL into Money")
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return errors.New("cannot scan a non-finite number into Money")
+	}
+
+	amount := new(big.Int).Set(v.Int)
+	if shift := int64(v.Exp) + int64(m.decimals()); shift >= 0 {
+		amount.Mul(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(shift), nil))
+	} else {
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(-shift), nil)
+		var rem big.Int
+		amount.QuoRem(amount, div, &rem)
+		if rem.Mul(rem.Abs(&rem), big.NewInt(2)).Cmp(div) >= 0 {
+			amount.Add(amount, big.NewInt(int64(v.Int.Sign())))
+		}
+	}
+	if !amount.IsInt64() {
+		return fmt.Errorf("amount %s is out of range", amount)
+	}
+	m.Amount = amount.Int64()
+	return nil
+}
+
+// NumericValue writes Money as a NUMERIC with the currency's scale
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(m.Amount), Exp: int32(-m.decimals()), Valid: true}, nil
+}
+
+// ScanFloat64 reads double precision results, such as SQL arithmetic on floats
+func (m *Money) ScanFloat64(v pgtype.Float8) error {
+	if !v.Valid {
+		return errors.New("cannot scan NULL into Money")
+	}
+	m.Amount = NewMoney(v.Float64, m.Currency).Amount
+	return nil
+}
+
+func (m Money) Float64Value() (pgtype.Float8, error) {
+	return pgtype.Float8{Float64: m.Float(), Valid: true}, nil
+}
+
+// RegisterPgTypes makes pgx encode Money as NUMERIC where a query does not fix the
+// parameter type. Pools run it on every connection: pgxpool.Config.AfterConnect.
+func RegisterPgTypes(_ context.Context, conn *pgx.Conn) error {
+	conn.TypeMap().RegisterDefaultPgType(Money{}, "numeric")
+	conn.TypeMap().RegisterDefaultPgType(&Money{}, "numeric")
+	return nil
+}
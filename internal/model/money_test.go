@@ -0,0 +1,54 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Price   Money `json:"price"`
+		Balance Money `json:"balance"`
+		Yen     Money `json:"yen"`
+	}{NewMoney(0.1+0.2, ""), Money{Amount: -5}, Money{Amount: 1500, Currency: "JPY"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price": 0.30, "balance": -0.05, "yen": 1500}`, string(data))
+	assert.Contains(t, string(data), `"price":0.30`)
+
+	for in, want := range map[string]int64{`10`: 1000, `10.5`: 1050, `"0.01"`: 1, `-1.25`: -125, `1e2`: 10000} {
+		var m Money
+		require.NoError(t, json.Unmarshal([]byte(in), &m), in)
+		assert.Equal(t, want, m.Amount, in)
+	}
+	for _, in := range []string{`10.005`, `"abc"`, `.5`, `1.-5`, `true`} {
+		var m Money
+		assert.Error(t, json.Unmarshal([]byte(in), &m), in)
+	}
+}
+
+func TestMoneyNumeric(t *testing.T) {
+	var m Money
+	require.NoError(t, m.ScanNumeric(pgtype.Numeric{Int: big.NewInt(1190), Exp: -2, Valid: true}))
+	assert.Equal(t, int64(1190), m.Amount)
+
+	// Values with more decimals round half away from zero
+	require.NoError(t, m.ScanNumeric(pgtype.Numeric{Int: big.NewInt(-12345), Exp: -3, Valid: true}))
+	assert.Equal(t, int64(-1235), m.Amount)
+	require.NoError(t, m.ScanNumeric(pgtype.Numeric{Int: big.NewInt(7), Exp: 1, Valid: true}))
+	assert.Equal(t, int64(7000), m.Amount)
+	assert.Error(t, m.ScanNumeric(pgtype.Numeric{}))
+
+	n, err := Money{Amount: 1190}.NumericValue()
+	require.NoError(t, err)
+	assert.Equal(t, pgtype.Numeric{Int: big.NewInt(1190), Exp: -2, Valid: true}, n)
+
+	assert.Equal(t, "11.90", Money{Amount: 1190}.String())
+	assert.Equal(t, 11.9, Money{Amount: 1190}.Float())
+	assert.Equal(t, Money{Amount: 3570}, Money{Amount: 1190}.Mul(3))
+	assert.Equal(t, Money{Amount: 226}, Money{Amount: 1190}.Scale(0.19), "scaled amounts are rounded to the minor unit")
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Order event types; status transitions are published as "order.<status>"
+const (
+	OrderEventCreated   = "order.created"
+	OrderEventPaid      = "order." + OrderStatusPaid
+	OrderEventFulfilled = "order." + OrderStatusFulfilled
+	OrderEventRefunded  = "order." + OrderStatusRefunded
+	OrderEventCancelled = "order." + OrderStatusCancelled
+)
+
+// OrderEvent is an order lifecycle change recorded in the order_events outbox
+type OrderEvent struct {
+	ID      int64  `json:"id"`
+	OrderID int    `json:"order_id"`
+	UserID  int    `json:"user_id"`
+	Type    string `json:"type"`
+	// Payload is the order as of the event
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
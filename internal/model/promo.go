@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+const (
+	DiscountPercentage = "percentage"
+	DiscountFixed      = "fixed"
+)
+
+type PromoCode struct {
+	ID            int        `json:"id"`
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue float64    `json:"discount_value"`
+	MaxUses       *int       `json:"max_uses,omitempty"`
+	UsedCount     int        `json:"used_count"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Active        bool       `json:"active"`
+	// ItemIDs restricts the code to these items; empty means all items
+	ItemIDs   []int     `json:"item_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
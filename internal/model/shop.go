@@ -3,24 +3,457 @@ package model
 import "time"
 
 type User struct {
-	ID        int     `json:"id"`
-	FirstName string  `json:"first_name"`
-	LastName  string  `json:"last_name"`
-	Balance   float64 `json:"balance"`
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Balance   Money  `json:"balance"`
+	// Email receives notifications; empty when the user has none
+	Email string `json:"email,omitempty"`
+	// Region is the user's tax region (DE, US-CA, ...); empty when unknown
+	Region string `json:"region,omitempty"`
+	// Status is active, deactivated (cannot buy) or deleted (anonymized)
+	Status string `json:"status"`
 }
 
+// User statuses
+const (
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+	UserStatusDeleted     = "deleted"
+)
+
 type Item struct {
-	ID    int     `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
-	Stock int     `json:"stock"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Price       Money    `json:"price"`
+	Stock       int      `json:"stock"`
+	Category    string   `json:"category,omitempty"` // Category slug
+	Tags        []string `json:"tags"`
+	Description string   `json:"description,omitempty"`
+	ImageURL    string   `json:"image_url,omitempty"`
+	// Attributes are free-form details such as rarity or exterior
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// ItemImport is a row of a catalogue import. When it updates an existing item, nil
+// details (and nil Tags) are left unchanged and "" clears them.
+type ItemImport struct {
+	Name        string
+	Price       Money
+	Stock       int
+	Description *string
+	// Category is a category slug
+	Category *string
+	ImageURL *string
+	Tags     []string
+}
+
+// ItemMetadata updates an item's details; nil fields are left unchanged
+type ItemMetadata struct {
+	Description *string `json:"description"`
+	// ImageURL points at an externally hosted image, "" removes the image
+	ImageURL   *string        `json:"image_url"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// PriceTier charges UnitPrice per unit for purchases of at least MinQty of the item
+type PriceTier struct {
+	ItemID    int   `json:"item_id"`
+	MinQty    int   `json:"min_qty"`
+	UnitPrice Money `json:"unit_price"`
+}
+
+// SkinportPriceMapping prices an item from the lowest Skinport listing of MarketHashName
+type SkinportPriceMapping struct {
+	ItemID         int    `json:"item_id"`
+	ItemName       string `json:"item_name"`
+	MarketHashName string `json:"market_hash_name"`
+	// MarkupPercent overrides the configured markup for this item when set
+	MarkupPercent *float64  `json:"markup_percent,omitempty"`
+	ItemPrice     float64   `json:"item_price"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
+// Price change sources
+const (
+	PriceChangeSkinportSync = "skinport_sync"
+)
+
+// Price change statuses: pending changes wait for an admin to approve (applied) or
+// reject them, a newer applied change supersedes them
+const (
+	PriceChangeApplied    = "applied"
+	PriceChangePending    = "pending"
+	PriceChangeRejected   = "rejected"
+	PriceChangeSuperseded = "superseded"
+)
+
+// PriceChange records a price set on, or proposed for, an item; ReferencePrice is the
+// market price it was derived from
+type PriceChange struct {
+	ID             int      `json:"id"`
+	ItemID         int      `json:"item_id"`
+	OldPrice       Money    `json:"old_price"`
+	NewPrice       Money    `json:"new_price"`
+	Source         string   `json:"source"`
+	ReferencePrice *float64 `json:"reference_price,omitempty"`
+	Status         string   `json:"status"`
+	// DecidedAt is when a pending change was approved, rejected or superseded
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PriceChangeFilter narrows price change listings; zero values are ignored
+type PriceChangeFilter struct {
+	ItemID int
+	Status string
+	Limit  int
+}
+
+// ItemFilter narrows item listings; zero values are ignored
+type ItemFilter struct {
+	// Category is a category slug
+	Category string
+	// Tags lists tag names an item must all carry
+	Tags []string
+}
+
+type Category struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Order statuses, see service.orderTransitions for the allowed moves
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusFulfilled = "fulfilled"
+	OrderStatusRefunded  = "refunded"
+	OrderStatusCancelled = "cancelled"
+)
+
 type Order struct {
-	ID        int       `json:"id"`
+	ID     int   `json:"id"`
+	UserID int   `json:"user_id"`
+	ItemID int   `json:"item_id"`
+	Price  Money `json:"price"`
+	// UnitPrice is the per-unit price charged before discounts, after quantity tiers
+	UnitPrice   Money `json:"unit_price"`
+	Quantity    int   `json:"quantity"`
+	PromoCodeID *int  `json:"promo_code_id,omitempty"`
+	Discount    Money `json:"discount"`
+	// TaxRate and TaxAmount are the tax charged on the discounted amount, included in Price
+	TaxRate   float64 `json:"tax_rate"`
+	TaxAmount Money   `json:"tax_amount"`
+	Status    string  `json:"status"`
+	// ClientOrderID is the client's key of the purchase, unique per user
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	// QuoteID is the quote whose price the order was bought at, each quote buys once
+	QuoteID string `json:"quote_id,omitempty"`
+	// DropID is the drop the order was bought in
+	DropID *int `json:"drop_id,omitempty"`
+	// RefundDecision is the refund policy's verdict on the last refund request
+	RefundDecision *RefundDecision `json:"refund_decision,omitempty"`
+	// Replayed marks an order returned again for a repeated ClientOrderID, it is not stored
+	Replayed    bool       `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	FulfilledAt *time.Time `json:"fulfilled_at,omitempty"`
+	RefundedAt  *time.Time `json:"refunded_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// Refund policy decisions
+const (
+	RefundAllowed    = "allowed"
+	RefundDenied     = "denied"
+	RefundOverridden = "overridden"
+)
+
+// RefundDecision is the refund policy's verdict on a refund request. Overridden refunds
+// were denied by the policy and made anyway by an admin.
+type RefundDecision struct {
+	Decision string `json:"decision"`
+	// Rule and Reason tell which policy rule denied the refund and why
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// RequestedBy is "customer" or "admin"
+	RequestedBy    string    `json:"requested_by"`
+	OverrideReason string    `json:"override_reason,omitempty"`
+	DecidedAt      time.Time `json:"decided_at"`
+}
+
+// Drop states, see Drop.State
+const (
+	DropScheduled = "scheduled"
+	DropLive      = "live"
+	DropSoldOut   = "sold_out"
+	DropEnded     = "ended"
+)
+
+// Drop is a limited release of an item: from StartsAt until EndsAt (or for good without
+// one) purchases of the item come out of a pool of Stock units, at most PerUserLimit
+// per user. Purchases before StartsAt are refused.
+type Drop struct {
+	ID           int        `json:"id"`
+	ItemID       int        `json:"item_id"`
+	Name         string     `json:"name"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Stock        int        `json:"stock"`
+	PerUserLimit int        `json:"per_user_limit"`
+	// Sold is the quantity of the drop's paid orders, cancelled and refunded ones
+	// return to the pool
+	Sold      int       `json:"sold"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// State is the drop's state at now
+func (d *Drop) State(now time.Time) string {
+	switch {
+	case d.EndsAt != nil && !now.Before(*d.EndsAt):
+		return DropEnded
+	case now.Before(d.StartsAt):
+		return DropScheduled
+	case d.Sold >= d.Stock:
+		return DropSoldOut
+	default:
+		return DropLive
+	}
+}
+
+// StockMovementRestock is the reason of restocks from a supplier feed
+const StockMovementRestock = "supplier_restock"
+
+// StockMovement is a change of an item's stock, with where it came from
+type StockMovement struct {
+	ID       int    `json:"id"`
+	ItemID   int    `json:"item_id"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+	// Source and Reference identify the change at its origin, e.g. a feed and its line
+	Source     string    `json:"source"`
+	Reference  string    `json:"reference"`
+	StockAfter int       `json:"stock_after"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistFulfilled = "fulfilled"
+	WaitlistFailed    = "failed"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry is a user waiting for an out-of-stock item. Once restocked the item is
+// bought for its entries in arrival order.
+type WaitlistEntry struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	ItemID   int    `json:"item_id"`
+	Quantity int    `json:"quantity"`
+	Status   string `json:"status"`
+	// Position is the entry's place in the item's waitlist, 1 first, while it is waiting
+	Position int `json:"position,omitempty"`
+	// OrderID is the order of a fulfilled entry
+	OrderID *int `json:"order_id,omitempty"`
+	// FailureReason is why a failed entry's purchase was refused
+	FailureReason string     `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+}
+
+// WaitingEntry is the next entry of a restocked item's waitlist, with where its user is
+// notified
+type WaitingEntry struct {
+	Entry WaitlistEntry
+	// TenantID is the shop of the item, the purchase is made in it
+	TenantID int
+	// Email and TelegramChatID are empty for users without one
+	Email          string
+	TelegramChatID int64
+	FirstName      string
+	ItemName       string
+}
+
+// Quote locks the price of a purchase until ExpiresAt. ID is the signed quote itself,
+// it is passed back as quote_id when buying.
+type Quote struct {
+	ID        string `json:"id"`
+	UserID    int    `json:"user_id"`
+	ItemID    int    `json:"item_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unit_price"`
+	// Total is UnitPrice times Quantity, before promo codes and tax
+	Total     Money     `json:"total"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Reason codes classify manual balance adjustments, the free-text reason explains them
+const (
+	AdjustmentReasonGoodwill   = "goodwill"
+	AdjustmentReasonRefund     = "refund"
+	AdjustmentReasonChargeback = "chargeback"
+	AdjustmentReasonCorrection = "correction"
+	AdjustmentReasonPromotion  = "promotion"
+	AdjustmentReasonFraud      = "fraud"
+	AdjustmentReasonOther      = "other"
+)
+
+// AdjustmentReasonCodes lists the accepted reason codes
+var AdjustmentReasonCodes = []string{
+	AdjustmentReasonGoodwill, AdjustmentReasonRefund, AdjustmentReasonChargeback, AdjustmentReasonCorrection,
+	AdjustmentReasonPromotion, AdjustmentReasonFraud, AdjustmentReasonOther,
+}
+
+type BalanceAdjustment struct {
+	ID     int   `json:"id"`
+	UserID int   `json:"user_id"`
+	Delta  Money `json:"delta"`
+	// ReasonCode is one of AdjustmentReasonCodes, empty for automatic adjustments
+	// (deposits, refunds, CSV imports)
+	ReasonCode string `json:"reason_code,omitempty"`
+	Reason     string `json:"reason"`
+	Source     string `json:"source"`
+	// Balance is the user's balance once the adjustment was applied
+	Balance   Money     `json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InventoryItem is the quantity of an item a user owns
+type InventoryItem struct {
 	UserID    int       `json:"user_id"`
 	ItemID    int       `json:"item_id"`
-	Price     float64   `json:"price"`
 	Quantity  int       `json:"quantity"`
-	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PortfolioHolding is an item a user owns with its current price, its Skinport mapping
+// and its price 24 hours and 7 days ago from item_price_snapshots (nil without a snapshot)
+type PortfolioHolding struct {
+	ItemID         int
+	Name           string
+	Quantity       int
+	Price          float64
+	MarketHashName *string
+	Price24h       *float64
+	Price7d        *float64
+}
+
+// InventoryTransfer moves owned quantity of an item from one user to another
+type InventoryTransfer struct {
+	FromUserID int `json:"from_user_id"`
+	ToUserID   int `json:"to_user_id"`
+	ItemID     int `json:"item_id"`
+	Quantity   int `json:"quantity"`
+	// FromQuantity and ToQuantity are what each user owns after the transfer
+	FromQuantity int `json:"from_quantity"`
+	ToQuantity   int `json:"to_quantity"`
+}
+
+// Favorite is a Skinport item a user follows
+type Favorite struct {
+	UserID         int    `json:"user_id"`
+	MarketHashName string `json:"market_hash_name"`
+	// AlertBelow triggers a price alert once the item is listed below it; nil disables alerts
+	AlertBelow *float64  `json:"alert_below,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PendingReceipt is an order whose receipt has not been sent yet, with what the receipt shows
+type PendingReceipt struct {
+	Order Order
+	// Email and TelegramChatID are empty for users without one
+	Email          string
+	TelegramChatID int64
+	FirstName      string
+	ItemName       string
+	// Stale orders are too old for a receipt to be useful
+	Stale bool
+}
+
+// DuePriceAlert is a favorite with an alert that may fire, with where its user is notified
+type DuePriceAlert struct {
+	UserID         int
+	Email          string
+	TelegramChatID int64
+	FirstName      string
+	MarketHashName string
+	AlertBelow     float64
+}
+
+// Payout statuses. A sending payout was claimed by the worker and handed to the provider.
+const (
+	PayoutStatusPending   = "pending"
+	PayoutStatusSending   = "sending"
+	PayoutStatusCompleted = "completed"
+	PayoutStatusFailed    = "failed"
+)
+
+// Payout is a withdrawal of balance, sent to Destination by a payout provider
+type Payout struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	Amount      Money  `json:"amount"`
+	Destination string `json:"destination"`
+	Status      string `json:"status"`
+	Provider    string `json:"provider,omitempty"`
+	// Reference is the provider's id of the transfer
+	Reference   string     `json:"reference,omitempty"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// Deposit statuses. A deposit needs review when its payment does not match it.
+const (
+	DepositStatusPending     = "pending"
+	DepositStatusSucceeded   = "succeeded"
+	DepositStatusExpired     = "expired"
+	DepositStatusFailed      = "failed"
+	DepositStatusNeedsReview = "needs_review"
+)
+
+// Deposit is a balance top-up paid through a Stripe Checkout session
+type Deposit struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Amount          Money      `json:"amount"`
+	Currency        string     `json:"currency"`
+	Status          string     `json:"status"`
+	SessionID       string     `json:"session_id,omitempty"`
+	PaymentIntentID string     `json:"payment_intent_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	CreditedAt      *time.Time `json:"credited_at,omitempty"`
+}
+
+// Ledger transaction kinds. Each moves money between a user's account and the system
+// account of its kind.
+const (
+	LedgerKindOpening          = "opening"
+	LedgerKindPurchase         = "purchase"
+	LedgerKindRefund           = "refund"
+	LedgerKindDeposit          = "deposit"
+	LedgerKindWithdrawal       = "withdrawal"
+	LedgerKindWithdrawalRefund = "withdrawal_refund"
+	LedgerKindAdjustment       = "adjustment"
+)
+
+// LedgerViolation is a broken ledger invariant: a transaction whose entries do not sum
+// to zero, or a user balance differing from the sum of the user's entries
+type LedgerViolation struct {
+	// Type is "unbalanced_transaction" or "balance_mismatch"
+	Type string `json:"type"`
+	// ID is the transaction id or the user id
+	ID       int64   `json:"id"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
 }
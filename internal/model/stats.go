@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+type SalesStats struct {
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Revenue     float64        `json:"revenue"`
+	OrderCount  int            `json:"order_count"`
+	ItemsSold   int            `json:"items_sold"`
+	ActiveUsers int            `json:"active_users"`
+	TopItems    []TopItemStats `json:"top_items"`
+}
+
+type TopItemStats struct {
+	ItemID     int     `json:"item_id"`
+	Name       string  `json:"name"`
+	OrderCount int     `json:"order_count"`
+	Quantity   int     `json:"quantity"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// Leaderboard periods
+const (
+	LeaderboardDaily   = "daily"
+	LeaderboardWeekly  = "weekly"
+	LeaderboardAllTime = "all_time"
+)
+
+// Leaderboard is the top buyers and best-selling items of a period as of its last rebuild
+type Leaderboard struct {
+	Period string `json:"period"`
+	// Since is the start of the period, nil for all time
+	Since *time.Time `json:"since"`
+	// RefreshedAt is nil until the leaderboard job first rebuilt the period
+	RefreshedAt *time.Time        `json:"refreshed_at"`
+	TopBuyers   []LeaderboardUser `json:"top_buyers"`
+	TopItems    []LeaderboardItem `json:"top_items"`
+}
+
+type LeaderboardUser struct {
+	Rank   int `json:"rank"`
+	UserID int `json:"user_id"`
+	// Name is the first name and last initial
+	Name        string  `json:"name"`
+	OrderCount  int     `json:"order_count"`
+	ItemsBought int     `json:"items_bought"`
+	Spent       float64 `json:"spent"`
+}
+
+type LeaderboardItem struct {
+	Rank       int     `json:"rank"`
+	ItemID     int     `json:"item_id"`
+	Name       string  `json:"name"`
+	OrderCount int     `json:"order_count"`
+	Quantity   int     `json:"quantity"`
+	Revenue    float64 `json:"revenue"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// SteamAccount is the Steam account a user signs in with, its profile as of UpdatedAt
+type SteamAccount struct {
+	UserID int `json:"user_id"`
+	// SteamID is the SteamID64
+	SteamID     string    `json:"steam_id"`
+	PersonaName string    `json:"persona_name,omitempty"`
+	ProfileURL  string    `json:"profile_url,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	LinkedAt    time.Time `json:"linked_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
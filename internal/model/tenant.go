@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Tenant is a shop served by the deployment, selected by its slug
+type Tenant struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantSkinportCredentials describes the Skinport credentials a tenant configured. The API
+// key is write-only, it is never returned.
+type TenantSkinportCredentials struct {
+	TenantID  int       `json:"tenant_id"`
+	ClientID  string    `json:"client_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,55 @@
+package notifications
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// EmailConfig selects and configures the email backend
+type EmailConfig struct {
+	// Provider is "smtp", "sendgrid" or "ses"
+	Provider string
+	From     string
+	// SMTP relay; SES uses Username and Password as its SMTP credentials
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SendGridAPIKey authenticates against the SendGrid API
+	SendGridAPIKey string
+	// SESRegion is the AWS region of the SES SMTP endpoint
+	SESRegion string
+}
+
+// NewEmailSender builds the Sender for cfg.Provider
+func NewEmailSender(cfg EmailConfig) (Sender, error) {
+	if cfg.From == "" {
+		return nil, fmt.Errorf("email sender address is required")
+	}
+
+	switch cfg.Provider {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("smtp host is required")
+		}
+		return &SMTPSender{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}, nil
+	case "sendgrid":
+		if cfg.SendGridAPIKey == "" {
+			return nil, fmt.Errorf("sendgrid api key is required")
+		}
+		return &SendGridSender{APIKey: cfg.SendGridAPIKey, From: cfg.From}, nil
+	case "ses":
+		if cfg.SESRegion == "" || cfg.SMTPUsername == "" {
+			return nil, fmt.Errorf("ses region and smtp credentials are required")
+		}
+		return NewSESSender(cfg.SESRegion, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown email provider %q (want smtp, sendgrid or ses)", cfg.Provider)
+	}
+}
@@ -0,0 +1,162 @@
+// Package notifications renders and sends user notifications (purchase receipts,
+// price alerts, waitlist updates) through pluggable email backends and a chat channel (Telegram).
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message is a rendered email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	// HTML is optional, messages carrying it are sent as multipart/alternative
+	HTML string
+}
+
+// Sender delivers messages through an email backend
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ChatSender delivers plain-text messages to a chat
+type ChatSender interface {
+	SendText(ctx context.Context, chatID int64, text string) error
+}
+
+// ErrDisabled is returned for notifications whose kind or channel is turned off
+var ErrDisabled = errors.New("notification disabled")
+
+// Options enables notification kinds; each needs at least one channel
+type Options struct {
+	Receipts    bool
+	PriceAlerts bool
+	Waitlist    bool
+}
+
+// Channels are the backends notifications are sent through; a nil one is disabled
+type Channels struct {
+	Email Sender
+	Chat  ChatSender
+}
+
+// Recipient is where a user is notified; empty addresses are skipped
+type Recipient struct {
+	Email  string
+	ChatID int64
+}
+
+// Notifier renders notifications and sends them through every channel the recipient
+// has an address on. A nil Notifier, or one without channels, sends nothing.
+type Notifier struct {
+	channels Channels
+	opts     Options
+}
+
+// New creates a Notifier
+func New(channels Channels, opts Options) *Notifier {
+	return &Notifier{channels: channels, opts: opts}
+}
+
+func (n *Notifier) hasChannel() bool {
+	return n != nil && (n.channels.Email != nil || n.channels.Chat != nil)
+}
+
+// ReceiptsEnabled reports whether purchase receipts are sent
+func (n *Notifier) ReceiptsEnabled() bool {
+	return n.hasChannel() && n.opts.Receipts
+}
+
+// PriceAlertsEnabled reports whether price alerts are sent
+func (n *Notifier) PriceAlertsEnabled() bool {
+	return n.hasChannel() && n.opts.PriceAlerts
+}
+
+// WaitlistEnabled reports whether users are told how their waitlist entries ended
+func (n *Notifier) WaitlistEnabled() bool {
+	return n.hasChannel() && n.opts.Waitlist
+}
+
+// Receipt is the data of a purchase receipt
+type Receipt struct {
+	FirstName string
+	OrderID   int
+	ItemName  string
+	Quantity  int
+	Price     float64
+	Discount  float64
+	CreatedAt time.Time
+}
+
+// PriceAlert is the data of a price alert for a favorite Skinport item
+type PriceAlert struct {
+	FirstName      string
+	MarketHashName string
+	Currency       string
+	Price          float64
+	AlertBelow     float64
+}
+
+// WaitlistUpdate is the data of a waitlist entry that was fulfilled, or that failed
+// with Reason
+type WaitlistUpdate struct {
+	FirstName string
+	ItemName  string
+	Quantity  int
+	Fulfilled bool
+	OrderID   int
+	Price     float64
+	Reason    string
+}
+
+// SendReceipt sends a purchase receipt to the recipient
+func (n *Notifier) SendReceipt(ctx context.Context, to Recipient, r Receipt) error {
+	if !n.ReceiptsEnabled() {
+		return ErrDisabled
+	}
+	return n.send(ctx, to, "receipt", r)
+}
+
+// SendPriceAlert sends a price alert to the recipient
+func (n *Notifier) SendPriceAlert(ctx context.Context, to Recipient, a PriceAlert) error {
+	if !n.PriceAlertsEnabled() {
+		return ErrDisabled
+	}
+	return n.send(ctx, to, "price_alert", a)
+}
+
+// SendWaitlistUpdate tells the recipient how their waitlist entry ended
+func (n *Notifier) SendWaitlistUpdate(ctx context.Context, to Recipient, u WaitlistUpdate) error {
+	if !n.WaitlistEnabled() {
+		return ErrDisabled
+	}
+	return n.send(ctx, to, "waitlist", u)
+}
+
+// send delivers the notification on every channel; a failure on one channel does not
+// prevent delivery on the others
+func (n *Notifier) send(ctx context.Context, to Recipient, template string, data any) error {
+	msg, err := render(template, data)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if n.channels.Email != nil && to.Email != "" {
+		msg.To = to.Email
+		if err := n.channels.Email.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if n.channels.Chat != nil && to.ChatID != 0 {
+		// Chats get the plain-text rendering, headed by the subject
+		if err := n.channels.Chat.SendText(ctx, to.ChatID, msg.Subject+"\n\n"+msg.Text); err != nil {
+			errs = append(errs, fmt.Errorf("chat: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,203 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	sent []Message
+}
+
+func (f *fakeSender) Send(_ context.Context, msg Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+type fakeChat struct {
+	sent map[int64]string
+	err  error
+}
+
+func (f *fakeChat) SendText(_ context.Context, chatID int64, text string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent[chatID] = text
+	return nil
+}
+
+func TestNotifier_SendReceipt(t *testing.T) {
+	sender := &fakeSender{}
+	n := New(Channels{Email: sender}, Options{Receipts: true})
+
+	err := n.SendReceipt(context.Background(), Recipient{Email: "buyer@example.com"}, Receipt{
+		FirstName: "Ada", OrderID: 42, ItemName: "Sword", Quantity: 2, Price: 18, Discount: 2,
+		CreatedAt: time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+
+	msg := sender.sent[0]
+	assert.Equal(t, "buyer@example.com", msg.To)
+	assert.Equal(t, "Your receipt for order #42", msg.Subject)
+	assert.Contains(t, msg.Text, "Hi Ada,")
+	assert.Contains(t, msg.Text, "Discount: 2.00")
+	assert.Contains(t, msg.Text, "Total:    18.00")
+	assert.Contains(t, msg.HTML, "<strong>18.00</strong>")
+
+	assert.ErrorIs(t, n.SendPriceAlert(context.Background(), Recipient{Email: "buyer@example.com"}, PriceAlert{}), ErrDisabled)
+	assert.ErrorIs(t, New(Channels{}, Options{Receipts: true}).SendReceipt(context.Background(), Recipient{Email: "x@example.com"}, Receipt{}), ErrDisabled,
+		"no channel")
+}
+
+func TestNotifier_Channels(t *testing.T) {
+	email := &fakeSender{}
+	chat := &fakeChat{sent: map[int64]string{}}
+	n := New(Channels{Email: email, Chat: chat}, Options{PriceAlerts: true})
+	alert := PriceAlert{MarketHashName: "AK-47 | Redline", Currency: "EUR", Price: 9.5, AlertBelow: 10}
+
+	require.NoError(t, n.SendPriceAlert(context.Background(), Recipient{ChatID: 7}, alert))
+	assert.Empty(t, email.sent, "recipient without an email")
+	assert.True(t, strings.HasPrefix(chat.sent[7], "Price alert: AK-47 | Redline is now 9.50 EUR\n\n"), chat.sent[7])
+
+	chat.err = errors.New("chat not found")
+	err := n.SendPriceAlert(context.Background(), Recipient{Email: "a@example.com", ChatID: 7}, alert)
+	assert.ErrorContains(t, err, "chat: chat not found")
+	assert.Len(t, email.sent, 1, "email still sent when the chat fails")
+}
+
+func TestRender_EscapesHTML(t *testing.T) {
+	msg, err := render("price_alert", PriceAlert{MarketHashName: "<b>AK</b>", Currency: "EUR", Price: 9.5, AlertBelow: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "Price alert: <b>AK</b> is now 9.50 EUR", msg.Subject)
+	assert.Contains(t, msg.HTML, "&lt;b&gt;AK&lt;/b&gt;")
+}
+
+func TestNotifier_SendWaitlistUpdate(t *testing.T) {
+	sender := &fakeSender{}
+	n := New(Channels{Email: sender}, Options{Waitlist: true})
+	to := Recipient{Email: "buyer@example.com"}
+
+	require.NoError(t, n.SendWaitlistUpdate(context.Background(), to, WaitlistUpdate{
+		FirstName: "Ada", ItemName: "Sword", Quantity: 2, Fulfilled: true, OrderID: 42, Price: 18,
+	}))
+	require.NoError(t, n.SendWaitlistUpdate(context.Background(), to, WaitlistUpdate{
+		FirstName: "Ada", ItemName: "Sword", Quantity: 2, Reason: "insufficient funds",
+	}))
+	require.Len(t, sender.sent, 2)
+
+	assert.Equal(t, "Sword is back in stock: order #42 placed", sender.sent[0].Subject)
+	assert.Contains(t, sender.sent[0].Text, "Total:    18.00")
+	assert.Equal(t, "We could not fill your waitlist order for Sword", sender.sent[1].Subject)
+	assert.Contains(t, sender.sent[1].Text, "could not place your waitlist order for 2: insufficient funds.")
+	assert.NotContains(t, sender.sent[1].HTML, "Order")
+
+	assert.ErrorIs(t, n.SendReceipt(context.Background(), to, Receipt{}), ErrDisabled)
+}
+
+func TestSendGridSender(t *testing.T) {
+	var got sendGridRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := &SendGridSender{APIKey: "key", From: "shop@example.com", URL: server.URL}
+	require.NoError(t, s.Send(context.Background(), Message{To: "a@example.com", Subject: "Hi", Text: "text", HTML: "<p>html</p>"}))
+	assert.Equal(t, "a@example.com", got.Personalizations[0].To[0].Email)
+	assert.Equal(t, []sendGridContent{{"text/plain", "text"}, {"text/html", "<p>html</p>"}}, got.Content)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":[{"message":"bad key"}]}`, http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	s.URL = failing.URL
+	assert.ErrorContains(t, s.Send(context.Background(), Message{To: "a@example.com"}), "bad key")
+}
+
+// serveSMTP answers one SMTP session with a minimal, extension-less server and returns the DATA it received
+func serveSMTP(t *testing.T, ln net.Listener) <-chan string {
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 test")
+		var body strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					body.WriteString(line)
+				}
+				data <- body.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return data
+}
+
+func TestSMTPSender(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	data := serveSMTP(t, ln)
+
+	s := &SMTPSender{Addr: ln.Addr().String(), From: "shop@example.com", Timeout: 5 * time.Second}
+	err = s.Send(context.Background(), Message{To: "a@example.com", Subject: "Grüße", Text: "plain", HTML: "<p>rich</p>"})
+	require.NoError(t, err)
+
+	msg := <-data
+	assert.Contains(t, msg, "To: a@example.com\r\n")
+	assert.Contains(t, msg, "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n")
+	assert.Contains(t, msg, "Content-Type: multipart/alternative; boundary=")
+	assert.Contains(t, msg, "plain")
+	assert.Contains(t, msg, "<p>rich</p>")
+}
+
+func TestNewEmailSender(t *testing.T) {
+	s, err := NewEmailSender(EmailConfig{Provider: "ses", From: "shop@example.com", SESRegion: "eu-west-1", SMTPUsername: "u"})
+	require.NoError(t, err)
+	assert.Equal(t, "email-smtp.eu-west-1.amazonaws.com:587", s.(*SMTPSender).Addr)
+
+	_, err = NewEmailSender(EmailConfig{Provider: "pigeon", From: "shop@example.com"})
+	assert.Error(t, err)
+	_, err = NewEmailSender(EmailConfig{Provider: "smtp", SMTPHost: "localhost"})
+	assert.EqualError(t, err, "email sender address is required")
+}
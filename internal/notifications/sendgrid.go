@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridSender sends messages through the SendGrid v3 mail API
+type SendGridSender struct {
+	APIKey string
+	From   string
+	// URL overrides the API endpoint (tests)
+	URL    string
+	Client *http.Client
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (s *SendGridSender) Send(ctx context.Context, msg Message) error {
+	req := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: s.From},
+		Subject:          msg.Subject,
+	}
+	// SendGrid requires text/plain to come before text/html
+	req.Content = append(req.Content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	if msg.HTML != "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode sendgrid request: %w", err)
+	}
+	url := s.URL
+	if url == "" {
+		url = sendGridURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create sendgrid request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("sendgrid request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sendgrid returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
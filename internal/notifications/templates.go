@@ -0,0 +1,51 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Each notification has <name>.txt, defining a "<name>.subject" block followed by the
+// plain text body, and <name>.html with the HTML body
+//
+//go:embed templates/*
+var templateFS embed.FS
+
+var funcs = map[string]any{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.html"))
+)
+
+// render builds the message for the named notification, without a recipient
+func render(name string, data any) (Message, error) {
+	text := textTemplates.Lookup(name + ".txt")
+	html := htmlTemplates.Lookup(name + ".html")
+	if text == nil || html == nil {
+		return Message{}, fmt.Errorf("unknown notification template %q", name)
+	}
+
+	var subject, body, htmlBody bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
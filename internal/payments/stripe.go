@@ -0,0 +1,226 @@
+// Package payments takes deposits through Stripe Checkout: it creates checkout sessions,
+// verifies webhook signatures and looks sessions up for reconciliation.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/model"
+)
+
+const (
+	stripeAPIURL = "https://api.stripe.com"
+	// webhookTolerance bounds the age of a signed webhook, against replays
+	webhookTolerance = 5 * time.Minute
+)
+
+// ErrInvalidSignature is returned for webhooks that are not signed by Stripe with the
+// endpoint secret, or too old
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// StripeConfig configures the Stripe client
+type StripeConfig struct {
+	// SecretKey authenticates API requests (sk_...)
+	SecretKey string
+	// WebhookSecret verifies webhook signatures (whsec_...)
+	WebhookSecret string
+	// Currency of deposits, as a lowercase ISO code
+	Currency string
+	// SuccessURL and CancelURL are where Checkout sends the user back
+	SuccessURL string
+	CancelURL  string
+}
+
+// Stripe is a minimal Stripe API client for Checkout deposits
+type Stripe struct {
+	config StripeConfig
+	// URL overrides the API endpoint (tests)
+	URL    string
+	Client *http.Client
+}
+
+func NewStripe(cfg StripeConfig) *Stripe {
+	return &Stripe{config: cfg, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Currency is the currency deposits are taken in
+func (s *Stripe) Currency() string {
+	return s.config.Currency
+}
+
+// CheckoutSession is the part of a Stripe Checkout Session deposits use
+type CheckoutSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Status is "open", "complete" or "expired"
+	Status string `json:"status"`
+	// PaymentStatus is "paid" once the payment succeeded
+	PaymentStatus string `json:"payment_status"`
+	// PaymentIntent is the id of the session's payment intent, empty until it is created
+	PaymentIntent string            `json:"payment_intent"`
+	AmountTotal   int64             `json:"amount_total"`
+	Currency      string            `json:"currency"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// PaymentIntent is the part of a Stripe PaymentIntent deposits use
+type PaymentIntent struct {
+	ID             string            `json:"id"`
+	AmountReceived int64             `json:"amount_received"`
+	Currency       string            `json:"currency"`
+	Status         string            `json:"status"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// Event is a verified webhook event; Object is decoded according to Type
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// CreateCheckoutSession starts a Checkout payment of amount for the deposit. The deposit
+// id is stored in the metadata of both the session and its payment intent, so webhooks
+// and reconciliation can find the deposit.
+func (s *Stripe) CreateCheckoutSession(ctx context.Context, depositID, userID int, amount model.Money) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", s.config.SuccessURL)
+	form.Set("cancel_url", s.config.CancelURL)
+	form.Set("client_reference_id", strconv.Itoa(depositID))
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", s.config.Currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(amount.Amount, 10))
+	form.Set("line_items[0][price_data][product_data][name]", "Balance deposit")
+	for _, prefix := range []string{"metadata", "payment_intent_data[metadata]"} {
+		form.Set(prefix+"[deposit_id]", strconv.Itoa(depositID))
+		form.Set(prefix+"[user_id]", strconv.Itoa(userID))
+	}
+
+	var session CheckoutSession
+	// A retried creation returns the same session instead of opening a second one
+	err := s.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, fmt.Sprintf("deposit-%d", depositID), &session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetCheckoutSession looks a session up, for deposits whose webhook was missed
+func (s *Stripe) GetCheckoutSession(ctx context.Context, sessionID string) (*CheckoutSession, error) {
+	var session CheckoutSession
+	if err := s.do(ctx, http.MethodGet, "/v1/checkout/sessions/"+url.PathEscape(sessionID), nil, "", &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out any) error {
+	base := s.URL
+	if base == "" {
+		base = stripeAPIURL
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create stripe request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.SecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read stripe response: %w", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Message
+		}
+		return fmt.Errorf("stripe returned %s: %s", resp.Status, msg)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid stripe response: %w", err)
+	}
+	return nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header of a webhook payload and decodes its
+// event. Events signed more than 5 minutes before now are rejected.
+func (s *Stripe) ParseWebhook(payload []byte, signature string, now time.Time) (*Event, error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(signedAt, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	// Stripe sends several v1 signatures while the endpoint secret is being rolled
+	valid := false
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
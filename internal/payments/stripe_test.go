@@ -0,0 +1,88 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(secret string, t time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", t.Unix(), payload)
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestParseWebhook(t *testing.T) {
+	s := NewStripe(StripeConfig{WebhookSecret: "whsec_test"})
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1","amount_received":1050}}}`)
+	now := time.Now()
+
+	event, err := s.ParseWebhook(payload, sign("whsec_test", now, payload), now)
+	require.NoError(t, err)
+	assert.Equal(t, "evt_1", event.ID)
+	assert.Equal(t, "payment_intent.succeeded", event.Type)
+	assert.JSONEq(t, `{"id":"pi_1","amount_received":1050}`, string(event.Data.Object))
+
+	// While the secret is rolled, one of the signatures matches
+	rolled := sign("whsec_old", now, payload) + ",v1=" + sign("whsec_test", now, payload)[len(fmt.Sprintf("t=%d,v1=", now.Unix())):]
+	_, err = s.ParseWebhook(payload, rolled, now)
+	assert.NoError(t, err)
+
+	invalid := map[string]string{
+		"wrong secret": sign("whsec_other", now, payload),
+		"too old":      sign("whsec_test", now.Add(-10*time.Minute), payload),
+		"no signature": fmt.Sprintf("t=%d", now.Unix()),
+		"empty":        "",
+	}
+	for name, signature := range invalid {
+		_, err := s.ParseWebhook(payload, signature, now)
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+	}
+
+	_, err = s.ParseWebhook([]byte(`{"id":"evt_2"}`), sign("whsec_test", now, payload), now)
+	assert.ErrorIs(t, err, ErrInvalidSignature, "the payload is signed")
+}
+
+func TestCreateCheckoutSession(t *testing.T) {
+	var got *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"cs_1","status":"complete","payment_status":"paid","payment_intent":"pi_1","amount_total":1050,"currency":"usd"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"cs_1","url":"https://checkout.stripe.com/c/cs_1","status":"open","payment_status":"unpaid"}`))
+	}))
+	defer ts.Close()
+	s := NewStripe(StripeConfig{SecretKey: "sk_test", Currency: "usd", SuccessURL: "https://shop/ok", CancelURL: "https://shop/cancel"})
+	s.URL = ts.URL
+
+	session, err := s.CreateCheckoutSession(context.Background(), 7, 3, model.Money{Amount: 1050})
+	require.NoError(t, err)
+	assert.Equal(t, "https://checkout.stripe.com/c/cs_1", session.URL)
+	assert.Equal(t, "/v1/checkout/sessions", got.URL.Path)
+	assert.Equal(t, "Bearer sk_test", got.Header.Get("Authorization"))
+	assert.Equal(t, "deposit-7", got.Header.Get("Idempotency-Key"))
+	assert.Equal(t, "1050", got.PostForm.Get("line_items[0][price_data][unit_amount]"))
+	assert.Equal(t, "usd", got.PostForm.Get("line_items[0][price_data][currency]"))
+	assert.Equal(t, "7", got.PostForm.Get("payment_intent_data[metadata][deposit_id]"), "webhooks find the deposit")
+
+	session, err = s.GetCheckoutSession(context.Background(), "cs_1")
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/checkout/sessions/cs_1", got.URL.Path)
+	assert.Equal(t, "paid", session.PaymentStatus)
+	assert.Equal(t, "pi_1", session.PaymentIntent)
+	assert.Equal(t, int64(1050), session.AmountTotal)
+}
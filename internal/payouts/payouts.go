@@ -0,0 +1,70 @@
+// Package payouts sends withdrawn balance to users through pluggable payout providers.
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+)
+
+// Request is a payout to send. PayoutID is unique per payout, providers use it as the
+// idempotency key so a retried payout is not paid twice.
+type Request struct {
+	PayoutID    int
+	UserID      int
+	Amount      model.Money
+	Destination string
+}
+
+// Provider pays out requests and returns the provider's reference of the transfer.
+// Errors matching ErrRejected are final, any other error is retried.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, req Request) (string, error)
+}
+
+// ErrRejected marks payouts the provider refused for good (invalid destination, ...)
+var ErrRejected = errors.New("payout rejected")
+
+// RejectedError is a final refusal, with the provider's reason
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "payout rejected: " + e.Reason
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Config selects and configures the payout provider
+type Config struct {
+	// Provider is "stub" or "stripe"
+	Provider string
+	// StripeAPIKey is the secret key of the Stripe platform account
+	StripeAPIKey string
+	// Currency of the payouts, as a lowercase ISO code
+	Currency string
+}
+
+// NewProvider builds the Provider for cfg.Provider
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "stub":
+		return &StubProvider{}, nil
+	case "stripe":
+		if cfg.StripeAPIKey == "" {
+			return nil, fmt.Errorf("stripe api key is required")
+		}
+		if cfg.Currency == "" {
+			return nil, fmt.Errorf("payout currency is required")
+		}
+		return &StripeProvider{APIKey: cfg.StripeAPIKey, Currency: cfg.Currency}, nil
+	default:
+		return nil, fmt.Errorf("unknown payout provider %q (want stub or stripe)", cfg.Provider)
+	}
+}
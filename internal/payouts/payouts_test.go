@@ -0,0 +1,73 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(Config{Provider: "stub"})
+	require.NoError(t, err)
+	assert.Equal(t, "stub", p.Name())
+
+	_, err = NewProvider(Config{Provider: "stripe", Currency: "usd"})
+	assert.Error(t, err, "stripe needs an api key")
+	_, err = NewProvider(Config{Provider: "paypal"})
+	assert.Error(t, err)
+}
+
+func TestStubProvider(t *testing.T) {
+	p := &StubProvider{}
+	ref, err := p.Send(context.Background(), Request{PayoutID: 7, Amount: model.Money{Amount: 500}, Destination: "anything"})
+	require.NoError(t, err)
+	assert.Equal(t, "stub-7", ref)
+
+	_, err = p.Send(context.Background(), Request{PayoutID: 8, Amount: model.Money{Amount: 500}, Destination: "reject:closed account"})
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.EqualError(t, err, "payout rejected: closed account")
+}
+
+func TestStripeProvider(t *testing.T) {
+	status, body := http.StatusOK, `{"id":"tr_123","object":"transfer"}`
+	var got *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	p := &StripeProvider{APIKey: "sk_test", Currency: "usd", URL: ts.URL}
+	req := Request{PayoutID: 42, UserID: 3, Amount: model.Money{Amount: 1235}, Destination: "acct_abc"}
+
+	ref, err := p.Send(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "tr_123", ref)
+	assert.Equal(t, "Bearer sk_test", got.Header.Get("Authorization"))
+	assert.Equal(t, "payout-42", got.Header.Get("Idempotency-Key"))
+	assert.Equal(t, "1235", got.PostForm.Get("amount"), "amounts are in cents")
+	assert.Equal(t, "usd", got.PostForm.Get("currency"))
+	assert.Equal(t, "acct_abc", got.PostForm.Get("destination"))
+	assert.Equal(t, "42", got.PostForm.Get("metadata[payout_id]"))
+
+	status, body = http.StatusBadRequest, `{"error":{"type":"invalid_request_error","message":"No such destination"}}`
+	_, err = p.Send(context.Background(), req)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.Contains(t, err.Error(), "No such destination")
+
+	status, body = http.StatusTooManyRequests, `{"error":{"type":"rate_limit_error","message":"Too many requests"}}`
+	_, err = p.Send(context.Background(), req)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrRejected), "rate limits are retried")
+
+	_, err = p.Send(context.Background(), Request{PayoutID: 43, Amount: model.Money{Amount: 100}, Destination: "someone@example.com"})
+	assert.ErrorIs(t, err, ErrRejected, "only connected accounts are valid destinations")
+}
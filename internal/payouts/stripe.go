@@ -0,0 +1,105 @@
+package payouts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeURL = "https://api.stripe.com/v1/transfers"
+
+// StripeProvider pays out as Stripe Connect transfers from the platform balance to the
+// connected account (acct_...) given as destination
+type StripeProvider struct {
+	APIKey   string
+	Currency string
+	// URL overrides the API endpoint (tests)
+	URL    string
+	Client *http.Client
+}
+
+type stripeTransfer struct {
+	ID string `json:"id"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripeProvider) Send(ctx context.Context, req Request) (string, error) {
+	if !strings.HasPrefix(req.Destination, "acct_") {
+		return "", &RejectedError{Reason: "destination must be a Stripe connected account (acct_...)"}
+	}
+
+	form := url.Values{}
+	// Stripe amounts are in the currency's smallest unit
+	form.Set("amount", strconv.FormatInt(req.Amount.Amount, 10))
+	form.Set("currency", p.Currency)
+	form.Set("destination", req.Destination)
+	form.Set("transfer_group", fmt.Sprintf("payout_%d", req.PayoutID))
+	form.Set("metadata[payout_id]", strconv.Itoa(req.PayoutID))
+	form.Set("metadata[user_id]", strconv.Itoa(req.UserID))
+
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = stripeURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create stripe request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Retries of the same payout return the original transfer instead of paying twice
+	httpReq.Header.Set("Idempotency-Key", fmt.Sprintf("payout-%d", req.PayoutID))
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read stripe response: %w", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var apiErr stripeError
+		_ = json.Unmarshal(body, &apiErr)
+		msg := apiErr.Error.Message
+		if msg == "" {
+			msg = strings.TrimSpace(string(body))
+		}
+		// Invalid requests and card/account errors will not succeed on retry; rate
+		// limits, idempotency conflicts and server errors may
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusPaymentRequired, http.StatusNotFound:
+			return "", &RejectedError{Reason: msg}
+		}
+		return "", fmt.Errorf("stripe returned %s: %s", resp.Status, msg)
+	}
+
+	var transfer stripeTransfer
+	if err := json.Unmarshal(body, &transfer); err != nil || transfer.ID == "" {
+		return "", fmt.Errorf("invalid stripe response: %s", body)
+	}
+	return transfer.ID, nil
+}
@@ -0,0 +1,25 @@
+package payouts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// StubProvider pays nothing out: it logs the payout and accepts it, for development.
+// Destinations starting with "reject:" are rejected, to exercise the refund path.
+type StubProvider struct{}
+
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubProvider) Send(ctx context.Context, req Request) (string, error) {
+	if reason, ok := strings.CutPrefix(req.Destination, "reject:"); ok {
+		return "", &RejectedError{Reason: reason}
+	}
+	slog.InfoContext(ctx, "stub payout", "payout_id", req.PayoutID, "user_id", req.UserID,
+		"amount", req.Amount, "destination", req.Destination)
+	return fmt.Sprintf("stub-%d", req.PayoutID), nil
+}
@@ -0,0 +1,75 @@
+// Package pricing derives shop prices from market reference prices.
+package pricing
+
+import (
+	"fmt"
+	"math"
+)
+
+// Rounding modes of Markup.RoundingMode
+const (
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// Markup turns a reference price into a shop price: the reference plus Percent, rounded
+// to a multiple of Rounding, then clamped between Floor and Ceiling
+type Markup struct {
+	// Percent is added to the reference price, 15 is +15%; negative values undercut it
+	Percent float64
+	// Floor and Ceiling bound the resulting price, 0 disables a bound
+	Floor   float64
+	Ceiling float64
+	// Rounding is the price step, 0.05 rounds to 5 cents (0 rounds to cents)
+	Rounding float64
+	// RoundingMode is nearest, up or down (empty means nearest)
+	RoundingMode string
+}
+
+// Validate checks the rule is usable
+func (m Markup) Validate() error {
+	switch {
+	case m.Percent <= -100:
+		return fmt.Errorf("markup percent must be greater than -100")
+	case m.Floor < 0 || m.Ceiling < 0 || m.Rounding < 0:
+		return fmt.Errorf("markup floor, ceiling and rounding must not be negative")
+	case m.Ceiling > 0 && m.Floor > m.Ceiling:
+		return fmt.Errorf("markup floor must not exceed the ceiling")
+	}
+	switch m.RoundingMode {
+	case "", RoundNearest, RoundUp, RoundDown:
+		return nil
+	}
+	return fmt.Errorf("unknown rounding mode %q (want %s, %s or %s)", m.RoundingMode, RoundNearest, RoundUp, RoundDown)
+}
+
+// Apply returns the shop price for the reference price
+func (m Markup) Apply(reference float64) float64 {
+	price := round(reference*(1+m.Percent/100), m.Rounding, m.RoundingMode)
+	if m.Floor > 0 {
+		price = max(price, m.Floor)
+	}
+	if m.Ceiling > 0 {
+		price = min(price, m.Ceiling)
+	}
+	// Prices are stored with two decimals
+	return math.Round(price*100) / 100
+}
+
+func round(price, step float64, mode string) float64 {
+	if step <= 0 {
+		step = 0.01
+	}
+	// Guard against float noise such as 1.1/0.05 = 22.000000000000004
+	n := math.Round(price/step*1e6) / 1e6
+	switch mode {
+	case RoundUp:
+		n = math.Ceil(n)
+	case RoundDown:
+		n = math.Floor(n)
+	default:
+		n = math.Round(n)
+	}
+	return n * step
+}
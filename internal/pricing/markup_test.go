@@ -0,0 +1,39 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkup_Apply(t *testing.T) {
+	tests := []struct {
+		name      string
+		markup    Markup
+		reference float64
+		want      float64
+	}{
+		{"plain reference", Markup{}, 12.345, 12.35},
+		{"percent", Markup{Percent: 10}, 20, 22},
+		{"discount", Markup{Percent: -5}, 20, 19},
+		{"nearest step", Markup{Percent: 10, Rounding: 0.05}, 1.01, 1.1},
+		{"up", Markup{Rounding: 0.5, RoundingMode: RoundUp}, 10.01, 10.5},
+		{"up on a step", Markup{Rounding: 0.05, RoundingMode: RoundUp}, 1.1, 1.1},
+		{"down", Markup{Rounding: 1, RoundingMode: RoundDown}, 10.99, 10},
+		{"floor", Markup{Percent: 10, Floor: 0.5}, 0.1, 0.5},
+		{"ceiling", Markup{Percent: 10, Ceiling: 100}, 1000, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.markup.Apply(tt.reference), 1e-9)
+		})
+	}
+}
+
+func TestMarkup_Validate(t *testing.T) {
+	assert.NoError(t, Markup{Percent: 15, Floor: 1, Ceiling: 100, Rounding: 0.05, RoundingMode: RoundUp}.Validate())
+	assert.Error(t, Markup{Percent: -100}.Validate())
+	assert.Error(t, Markup{Floor: 10, Ceiling: 5}.Validate())
+	assert.Error(t, Markup{Rounding: -1}.Validate())
+	assert.Error(t, Markup{RoundingMode: "bankers"}.Validate())
+}
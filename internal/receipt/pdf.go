@@ -0,0 +1,117 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Page layout, in PDF points: A4 with 2cm margins and 10pt Courier, whose glyphs are
+// all 6pt wide
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 56
+	fontSize     = 10
+	leading      = 14
+	charsPerLine = (pageWidth - 2*margin) / 6
+	linesPerPage = (pageHeight - 2*margin) / leading
+)
+
+// writePDF lays lines out on as many pages as they need, wrapping the long ones. The
+// output only depends on its input, so the same receipt always gives the same bytes.
+func writePDF(title string, lines []string) []byte {
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapLine(line, charsPerLine)...)
+	}
+	var pages [][]string
+	for len(wrapped) > linesPerPage {
+		pages = append(pages, wrapped[:linesPerPage])
+		wrapped = wrapped[linesPerPage:]
+	}
+	pages = append(pages, wrapped)
+
+	// Objects 1-4 are the catalog, the page tree, the font and the document info; each
+	// page then takes two, itself and its content stream
+	objects := make([]string, 4, 4+2*len(pages))
+	kids := make([]string, len(pages))
+	for i, page := range pages {
+		pageID := 5 + 2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageID)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, pageID+1),
+			contentStream(page))
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	objects[2] = "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"
+	objects[3] = fmt.Sprintf("<< /Title (%s) /Producer (fsanano/go-test) >>", escapeText(title))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// contentStream draws the page's lines from the top margin down
+func contentStream(lines []string) string {
+	var ops strings.Builder
+	fmt.Fprintf(&ops, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, leading, margin, pageHeight-margin)
+	for _, line := range lines {
+		fmt.Fprintf(&ops, "(%s) '\n", escapeText(line))
+	}
+	ops.WriteString("ET")
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", ops.Len(), ops.String())
+}
+
+// wrapLine splits line into pieces of at most width characters, at spaces when it can
+func wrapLine(line string, width int) []string {
+	var out []string
+	for utf8.RuneCountInString(line) > width {
+		runes := []rune(line)
+		cut := width
+		if i := strings.LastIndexByte(string(runes[:width+1]), ' '); i > 0 {
+			cut = utf8.RuneCountInString(string(runes[:width+1])[:i])
+		}
+		out = append(out, strings.TrimRight(string(runes[:cut]), " "))
+		line = strings.TrimLeft(string(runes[cut:]), " ")
+	}
+	return append(out, line)
+}
+
+// escapeText encodes s as a PDF string literal in WinAnsiEncoding; characters the
+// encoding lacks are replaced with '?'
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case r == '€':
+			b.WriteByte(0x80)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+// Package receipt renders order receipts as PDF documents: the text comes from an
+// embedded template and is laid out on A4 pages in a monospaced font, so no external
+// PDF toolkit is needed.
+package receipt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ContentType is the content type of rendered receipts
+const ContentType = "application/pdf"
+
+// Data is what a receipt shows
+type Data struct {
+	OrderID    int
+	CreatedAt  time.Time
+	BuyerName  string
+	BuyerEmail string
+	ItemName   string
+	Quantity   int
+	UnitPrice  float64
+	Discount   float64
+	// TaxRate and TaxAmount are the tax included in Total
+	TaxRate   float64
+	TaxAmount float64
+	Total     float64
+}
+
+//go:embed templates/receipt.txt
+var templateFS embed.FS
+
+var receiptTemplate = template.Must(template.New("receipt.txt").Funcs(map[string]any{
+	"money":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"percent": func(rate float64) string { return strconv.FormatFloat(rate*100, 'f', -1, 64) + "%" },
+	"rule":    func() string { return strings.Repeat("-", 48) },
+}).ParseFS(templateFS, "templates/receipt.txt"))
+
+// Render returns the receipt as a PDF document
+func Render(d Data) ([]byte, error) {
+	var text bytes.Buffer
+	if err := receiptTemplate.Execute(&text, d); err != nil {
+		return nil, fmt.Errorf("failed to render receipt: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(text.String(), "\n"), "\n")
+	return writePDF(fmt.Sprintf("Receipt for order #%d", d.OrderID), lines), nil
+}
@@ -0,0 +1,88 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	d := Data{
+		OrderID: 42, CreatedAt: time.Date(2026, 1, 18, 10, 30, 0, 0, time.UTC),
+		BuyerName: "Ada Lovelace", BuyerEmail: "ada@example.com", ItemName: "AK-47 (Redline)",
+		Quantity: 2, UnitPrice: 10, Discount: 2, TaxRate: 0.19, TaxAmount: 3.42, Total: 21.42,
+	}
+	doc, err := Render(d)
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+	assert.Contains(t, string(doc), "/Title (Receipt for order #42)")
+	assert.Contains(t, string(doc), "(Item:       AK-47 \\(Redline\\)) '")
+	assert.Contains(t, string(doc), "(Tax:        3.42 \\(19%\\)) '")
+	assert.Contains(t, string(doc), "(Total:      21.42) '")
+	assertValidXref(t, doc)
+
+	again, err := Render(d)
+	require.NoError(t, err)
+	assert.Equal(t, doc, again, "rendering is deterministic")
+
+	d.Discount, d.TaxAmount, d.BuyerEmail = 0, 0, ""
+	doc, err = Render(d)
+	require.NoError(t, err)
+	assert.NotContains(t, string(doc), "Discount")
+	assert.NotContains(t, string(doc), "Tax:")
+	assert.NotContains(t, string(doc), "Email:")
+}
+
+// assertValidXref checks that the cross-reference table points at every object and
+// startxref at the table, and that stream lengths are right
+func assertValidXref(t *testing.T, doc []byte) {
+	t.Helper()
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	require.NotNil(t, start)
+	xref, _ := strconv.Atoi(string(start[1]))
+	require.True(t, bytes.HasPrefix(doc[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(doc[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	for _, m := range regexp.MustCompile(`(?s)/Length (\d+) >>\nstream\n(.*?)\nendstream`).FindAllSubmatch(doc, -1) {
+		n, _ := strconv.Atoi(string(m[1]))
+		assert.Equal(t, n, len(m[2]))
+	}
+}
+
+func TestWritePDF_Pages(t *testing.T) {
+	lines := make([]string, linesPerPage+1)
+	for i := range lines {
+		lines[i] = "line " + strconv.Itoa(i)
+	}
+	doc := writePDF("t", lines)
+	assert.Contains(t, string(doc), "/Count 2")
+	assertValidXref(t, doc)
+}
+
+func TestWrapLine(t *testing.T) {
+	assert.Equal(t, []string{"short"}, wrapLine("short", 10))
+	assert.Equal(t, []string{"one two", "three"}, wrapLine("one two three", 10))
+	assert.Equal(t, []string{"abcdefghij", "klm"}, wrapLine("abcdefghijklm", 10))
+	assert.Equal(t, []string{"Grüße aus", "Köln"}, wrapLine("Grüße aus Köln", 10))
+}
+
+func TestEscapeText(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\c`, escapeText(`a(b)\c`))
+	assert.Equal(t, "Gr\xfc\xdfe \x80 ?", escapeText("Grüße € 星"))
+	assert.False(t, strings.ContainsRune(escapeText("a\tb"), '\t'))
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record inserts an audit entry. When called inside RunAtomic it is written
+// in the same transaction as the change it describes.
+func (r *AuditRepository) Record(ctx context.Context, entry model.AuditEntry) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))`,
+		entry.Actor, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// List returns audit entries matching the filter, newest first
+func (r *AuditRepository) List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		add("entity_id = $%d", filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		add("id < $%d", filter.BeforeID)
+	}
+
+	sql := "SELECT id, actor, action, entity_type, entity_id, before, after, COALESCE(request_id, ''), created_at FROM audit_log"
+	if len(conds) > 0 {
+		sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	sql += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var e model.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// nullJSON maps an empty snapshot to SQL NULL instead of invalid JSON
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return []byte(raw)
+}
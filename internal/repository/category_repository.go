@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CategoryRepository struct {
+	db *pgxpool.Pool
+	// cache is the read cache of the ShopRepository, which category and tag assignments
+	// invalidate
+	cache *readCache
+}
+
+// NewCategoryRepository drops the items it assigns categories and tags to from shop's
+// read cache; shop may be nil
+func NewCategoryRepository(db *pgxpool.Pool, shop *ShopRepository) *CategoryRepository {
+	return &CategoryRepository{db: db, cache: cacheOf(shop)}
+}
+
+func (r *CategoryRepository) ListCategories(ctx context.Context) ([]model.Category, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, "SELECT id, slug, name FROM categories ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []model.Category{}
+	for rows.Next() {
+		var c model.Category
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	return categories, nil
+}
+
+func (r *CategoryRepository) CreateCategory(ctx context.Context, c *model.Category) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"INSERT INTO categories (slug, name) VALUES ($1, $2) RETURNING id", c.Slug, c.Name).Scan(&c.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return errors.New("category already exists")
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+// ListTags returns all tags that are attached to at least one item
+func (r *CategoryRepository) ListTags(ctx context.Context) ([]model.Tag, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx,
+		"SELECT id, name FROM tags WHERE EXISTS (SELECT 1 FROM item_tags WHERE tag_id = tags.id) ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		var t model.Tag
+		if err := rows.Scan(&t.ID, &t.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// SetItemCategory assigns the item to the category with the given slug ("" clears it)
+func (r *CategoryRepository) SetItemCategory(ctx context.Context, itemID int, slug string) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		UPDATE items SET category_id = (SELECT id FROM categories WHERE slug = $2)
+		WHERE id = $1 AND ($2 = '' OR EXISTS (SELECT 1 FROM categories WHERE slug = $2))`, itemID, slug)
+	if err != nil {
+		return fmt.Errorf("failed to set item category: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("item or category not found")
+	}
+	r.cache.itemChanged(ctx, itemID)
+	return nil
+}
+
+// SetItemTags replaces the item's tags, creating missing tags on the fly
+func (r *CategoryRepository) SetItemTags(ctx context.Context, itemID int, tags []string) error {
+	exec := executorFromContext(ctx, r.db)
+
+	if _, err := exec.Exec(ctx, "DELETE FROM item_tags WHERE item_id = $1", itemID); err != nil {
+		return fmt.Errorf("failed to clear item tags: %w", err)
+	}
+	r.cache.itemChanged(ctx, itemID)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := exec.Exec(ctx, "INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (tenant_id, name) DO NOTHING", tags); err != nil {
+		return fmt.Errorf("failed to create tags: %w", err)
+	}
+	_, err := exec.Exec(ctx, `
+		INSERT INTO item_tags (item_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2::text[])`, itemID, tags)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("item not found")
+		}
+		return fmt.Errorf("failed to set item tags: %w", err)
+	}
+	return nil
+}
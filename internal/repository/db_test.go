@@ -0,0 +1,1561 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/tenant"
+	"fsanano/go-test/internal/testdb"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests below need Postgres: DATABASE_URL, or go test -tags embeddedpg
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+func money(amount float64) model.Money {
+	return model.NewMoney(amount, model.DefaultCurrency)
+}
+
+func TestShopRepository_ListItemsKeyset(t *testing.T) {
+	pool := testdb.New(t, "orders", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	for _, item := range []model.Item{
+		{Name: "c", Price: money(10), Stock: 1},
+		{Name: "a", Price: money(5), Stock: 1},
+		{Name: "b", Price: money(10), Stock: 1},
+	} {
+		require.NoError(t, repo.CreateItem(ctx, &item))
+	}
+
+	opts := model.ListOptions{Sort: []model.SortField{{Field: "price", Desc: true}}, Limit: 2}
+	page, err := repo.ListItems(ctx, model.ItemFilter{}, opts)
+	require.NoError(t, err)
+	require.Len(t, page, 3, "limit+1 rows signal a next page")
+
+	last := page[opts.Limit-1]
+	opts.After = []string{last.Price.String(), strconv.Itoa(last.ID)}
+	rest, err := repo.ListItems(ctx, model.ItemFilter{}, opts)
+	require.NoError(t, err)
+
+	var names []string
+	for _, item := range append(page[:opts.Limit], rest...) {
+		names = append(names, item.Name)
+	}
+	// Equal prices fall back to ascending id
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+}
+
+func TestShopRepository_UpdateOrderStatus(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(10), Quantity: 1}
+	_, err := repo.CreateOrder(ctx, &order)
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusPaid, order.Status)
+	assert.NotNil(t, order.PaidAt)
+
+	updated, err := repo.UpdateOrderStatus(ctx, order.ID, model.OrderStatusRefunded)
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusRefunded, updated.Status)
+	assert.NotNil(t, updated.RefundedAt)
+
+	_, err = repo.UpdateOrderStatus(ctx, order.ID+1, model.OrderStatusRefunded)
+	assert.EqualError(t, err, "order not found")
+}
+
+func TestLeaderboardRepository(t *testing.T) {
+	pool := testdb.New(t, "leaderboard_buyers", "leaderboard_items", "leaderboard_refreshes", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	repo := NewLeaderboardRepository(pool)
+	ctx := context.Background()
+
+	board, err := repo.GetLeaderboard(ctx, model.LeaderboardDaily, 10)
+	require.NoError(t, err)
+	assert.Nil(t, board.RefreshedAt, "not rebuilt yet")
+	assert.Empty(t, board.TopBuyers)
+
+	ada := model.User{FirstName: "Ada", LastName: "Lovelace"}
+	require.NoError(t, shop.CreateUser(ctx, &ada))
+	bob := model.User{FirstName: "Bob", LastName: "Builder"}
+	require.NoError(t, shop.CreateUser(ctx, &bob))
+	cheap := model.Item{Name: "Cheap", Price: money(1), Stock: 100}
+	require.NoError(t, shop.CreateItem(ctx, &cheap))
+	pricey := model.Item{Name: "Pricey", Price: money(50), Stock: 100}
+	require.NoError(t, shop.CreateItem(ctx, &pricey))
+
+	for _, o := range []model.Order{
+		{UserID: ada.ID, ItemID: cheap.ID, Price: money(10), Quantity: 10},
+		{UserID: bob.ID, ItemID: pricey.ID, Price: money(50), Quantity: 1},
+		{UserID: ada.ID, ItemID: pricey.ID, Price: money(100), Quantity: 2},
+	} {
+		_, err := shop.CreateOrder(ctx, &o)
+		require.NoError(t, err)
+	}
+	old := model.Order{UserID: bob.ID, ItemID: pricey.ID, Price: money(500), Quantity: 10}
+	_, err = shop.CreateOrder(ctx, &old)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "UPDATE orders SET created_at = NOW() - INTERVAL '3 days' WHERE id = $1", old.ID)
+	require.NoError(t, err)
+	refunded := model.Order{UserID: bob.ID, ItemID: cheap.ID, Price: money(1000), Quantity: 1}
+	_, err = shop.CreateOrder(ctx, &refunded)
+	require.NoError(t, err)
+	_, err = shop.UpdateOrderStatus(ctx, refunded.ID, model.OrderStatusRefunded)
+	require.NoError(t, err)
+
+	since := time.Now().UTC().Add(-24 * time.Hour)
+	require.NoError(t, repo.RebuildLeaderboard(ctx, model.LeaderboardDaily, &since, 10))
+	require.NoError(t, repo.RebuildLeaderboard(ctx, model.LeaderboardAllTime, nil, 10))
+
+	board, err = repo.GetLeaderboard(ctx, model.LeaderboardDaily, 10)
+	require.NoError(t, err)
+	require.NotNil(t, board.RefreshedAt)
+	require.Len(t, board.TopBuyers, 2)
+	assert.Equal(t, model.LeaderboardUser{Rank: 1, UserID: ada.ID, Name: "Ada L.", OrderCount: 2, ItemsBought: 12, Spent: 110},
+		board.TopBuyers[0])
+	assert.Equal(t, bob.ID, board.TopBuyers[1].UserID, "refunded orders do not count")
+	require.Len(t, board.TopItems, 2)
+	assert.Equal(t, model.LeaderboardItem{Rank: 1, ItemID: cheap.ID, Name: "Cheap", OrderCount: 1, Quantity: 10, Revenue: 10},
+		board.TopItems[0])
+
+	board, err = repo.GetLeaderboard(ctx, model.LeaderboardAllTime, 1)
+	require.NoError(t, err)
+	assert.Nil(t, board.Since)
+	require.Len(t, board.TopBuyers, 1)
+	assert.Equal(t, bob.ID, board.TopBuyers[0].UserID)
+	assert.Equal(t, 550.0, board.TopBuyers[0].Spent)
+	assert.Equal(t, pricey.ID, board.TopItems[0].ItemID)
+
+	// Rebuilding replaces the period's rows
+	require.NoError(t, shop.SetUserStatus(ctx, bob.ID, model.UserStatusDeleted))
+	require.NoError(t, repo.RebuildLeaderboard(ctx, model.LeaderboardAllTime, nil, 10))
+	board, err = repo.GetLeaderboard(ctx, model.LeaderboardAllTime, 10)
+	require.NoError(t, err)
+	require.Len(t, board.TopBuyers, 1, "deleted users are left out")
+	assert.Equal(t, ada.ID, board.TopBuyers[0].UserID)
+}
+
+func TestShopRepository_OrderTax(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	require.NoError(t, repo.SetUserRegion(ctx, user.ID, "DE"))
+	assert.EqualError(t, repo.SetUserRegion(ctx, user.ID+1, "DE"), "user not found")
+	got, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "DE", got.Region)
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(11.9), UnitPrice: money(10), Quantity: 1, TaxRate: 0.19, TaxAmount: money(1.9)}
+	_, err = repo.CreateOrder(ctx, &order)
+	require.NoError(t, err)
+
+	stored, err := repo.GetOrderForUpdate(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(11.9), stored.Price)
+	assert.Equal(t, 0.19, stored.TaxRate)
+	assert.Equal(t, money(1.9), stored.TaxAmount)
+}
+
+func TestShopRepository_RefundDecision(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items", "categories")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	category, err := repo.GetItemCategory(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, category)
+	categories := NewCategoryRepository(pool, nil)
+	require.NoError(t, categories.CreateCategory(ctx, &model.Category{Slug: "keys", Name: "Keys"}))
+	require.NoError(t, categories.SetItemCategory(ctx, item.ID, "keys"))
+	category, err = repo.GetItemCategory(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "keys", category)
+	_, err = repo.GetItemCategory(ctx, item.ID+1)
+	assert.EqualError(t, err, "item not found")
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(10), UnitPrice: money(10), Quantity: 1}
+	_, err = repo.CreateOrder(ctx, &order)
+	require.NoError(t, err)
+	assert.Nil(t, order.RefundDecision)
+
+	decision := &model.RefundDecision{Decision: model.RefundDenied, Rule: "refund_window", Reason: "too late",
+		RequestedBy: "customer", DecidedAt: time.Now().UTC().Truncate(time.Second)}
+	require.NoError(t, repo.SetRefundDecision(ctx, order.ID, decision))
+	assert.EqualError(t, repo.SetRefundDecision(ctx, order.ID+1, decision), "order not found")
+
+	stored, err := repo.GetOrderForUpdate(ctx, order.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.RefundDecision)
+	assert.Equal(t, decision.Reason, stored.RefundDecision.Reason)
+	assert.True(t, decision.DecidedAt.Equal(stored.RefundDecision.DecidedAt))
+}
+
+func TestShopRepository_ItemMetadata(t *testing.T) {
+	pool := testdb.New(t, "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	description := "Factory new"
+	_, err := repo.UpdateItemMetadata(ctx, item.ID, model.ItemMetadata{
+		Description: &description,
+		Attributes:  map[string]any{"rarity": "covert"},
+	})
+	require.NoError(t, err)
+
+	previous, err := repo.SetItemImage(ctx, item.ID, "items/1/image-a", "/v1/items/1/image?v=a")
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+	previous, err = repo.SetItemImage(ctx, item.ID, "items/1/image-b", "/v1/items/1/image?v=b")
+	require.NoError(t, err)
+	assert.Equal(t, "items/1/image-a", previous)
+
+	got, err := repo.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Factory new", got.Description)
+	assert.Equal(t, "/v1/items/1/image?v=b", got.ImageURL)
+	assert.Equal(t, map[string]any{"rarity": "covert"}, got.Attributes)
+
+	// An external URL replaces the uploaded image, whose key is returned for cleanup
+	external := "https://cdn.example.com/ak.png"
+	previous, err = repo.UpdateItemMetadata(ctx, item.ID, model.ItemMetadata{ImageURL: &external})
+	require.NoError(t, err)
+	assert.Equal(t, "items/1/image-b", previous)
+	key, err := repo.GetItemImageKey(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, key)
+
+	_, err = repo.UpdateItemMetadata(ctx, item.ID+1, model.ItemMetadata{Description: &description})
+	assert.EqualError(t, err, "item not found")
+}
+
+func TestJobRepository_Claim(t *testing.T) {
+	pool := testdb.New(t, "job_runs")
+	repo := NewJobRepository(pool)
+	ctx := context.Background()
+
+	claimed, err := repo.Claim(ctx, "test_job", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// A run already started after since, another instance must not claim it
+	claimed, err = repo.Claim(ctx, "test_job", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	require.NoError(t, repo.Finish(ctx, "test_job", nil))
+	started, err := repo.LastStarted(ctx, "test_job")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), started, time.Minute)
+}
+
+func TestShopRepository_LockAndApplyPurchase(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	_, _, _, err := repo.LockPurchaseRows(ctx, item.ID+1, user.ID+1, 1)
+	assert.EqualError(t, err, "item not found", "a missing item is reported before a missing user")
+	_, _, _, err = repo.LockPurchaseRows(ctx, item.ID, user.ID+1, 1)
+	assert.EqualError(t, err, "user not found")
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(20), Quantity: 2}
+	err = repo.RunAtomic(ctx, func(ctx context.Context) error {
+		price, stock, balance, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []any{money(10), 5, money(100)}, []any{price, stock, balance})
+		return repo.ApplyPurchase(ctx, &order)
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, order.ID)
+	assert.Equal(t, model.OrderStatusPaid, order.Status)
+
+	_, stock, balance, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stock)
+	assert.Equal(t, money(80), balance)
+}
+
+func TestShopRepository_PurchaseSingleStatement(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(25)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 3}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	tests := []struct {
+		name    string
+		order   model.Order
+		wantErr string
+	}{
+		{"missing item", model.Order{UserID: user.ID, ItemID: item.ID + 1, Quantity: 1}, "item not found"},
+		{"missing user", model.Order{UserID: user.ID + 1, ItemID: item.ID, Quantity: 1}, "user not found"},
+		{"insufficient stock", model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 4}, "insufficient stock"},
+		{"insufficient funds", model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 3}, "insufficient funds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := repo.PurchaseSingleStatement(ctx, &tt.order)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 2}
+	balance, stock, err := repo.PurchaseSingleStatement(ctx, &order)
+	require.NoError(t, err)
+	assert.Equal(t, money(25), balance)
+	assert.Equal(t, 3, stock)
+	assert.NotZero(t, order.ID)
+	assert.Equal(t, money(20), order.Price)
+	assert.NotNil(t, order.PaidAt)
+
+	// Failed attempts must not have changed anything
+	_, stock, locked, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stock)
+	assert.Equal(t, money(5), locked)
+}
+
+func TestDropRepository(t *testing.T) {
+	pool := testdb.New(t, "drops", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	repo := NewDropRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 10}
+	require.NoError(t, shop.CreateItem(ctx, &item))
+
+	open, err := repo.GetOpenDrop(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, open)
+
+	assert.EqualError(t, repo.CreateDrop(ctx, &model.Drop{ItemID: item.ID + 1, Name: "x", StartsAt: time.Now(), Stock: 1, PerUserLimit: 1}), "item not found")
+	drop := model.Drop{ItemID: item.ID, Name: "Launch", StartsAt: time.Now().Add(-time.Minute), Stock: 5, PerUserLimit: 2}
+	require.NoError(t, repo.CreateDrop(ctx, &drop))
+
+	_, _, err = shop.PurchaseSingleStatement(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1})
+	assert.ErrorIs(t, err, ErrItemInDrop)
+
+	for _, status := range []string{model.OrderStatusPaid, model.OrderStatusRefunded} {
+		order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(20), Quantity: 2, DropID: &drop.ID, Status: status}
+		_, err := shop.CreateOrder(ctx, &order)
+		require.NoError(t, err)
+	}
+
+	open, err = repo.GetOpenDrop(ctx, item.ID)
+	require.NoError(t, err)
+	require.NotNil(t, open)
+	assert.Equal(t, drop.ID, open.ID)
+	assert.Equal(t, 2, open.Sold, "refunded orders return to the pool")
+
+	bought, err := repo.CountUserDropQuantity(ctx, drop.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bought)
+
+	drops, err := repo.ListOpenDrops(ctx)
+	require.NoError(t, err)
+	require.Len(t, drops, 1)
+	assert.Equal(t, "Launch", drops[0].Name)
+
+	_, err = pool.Exec(ctx, "UPDATE drops SET ends_at = NOW() - interval '1 second' WHERE id = $1", drop.ID)
+	require.NoError(t, err)
+	open, err = repo.GetOpenDrop(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, open, "ended drops no longer apply")
+	_, _, err = shop.PurchaseSingleStatement(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1})
+	assert.NoError(t, err)
+}
+
+func TestWaitlistRepository(t *testing.T) {
+	pool := testdb.New(t, "waitlist_entries", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	repo := NewWaitlistRepository(pool)
+	ctx := context.Background()
+
+	ada := model.User{FirstName: "Ada", LastName: "User", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(ctx, &ada))
+	bob := model.User{FirstName: "Bob", LastName: "User", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(ctx, &bob))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 0}
+	require.NoError(t, shop.CreateItem(ctx, &item))
+
+	first := model.WaitlistEntry{UserID: ada.ID, ItemID: item.ID, Quantity: 2}
+	require.NoError(t, repo.CreateEntry(ctx, &first))
+	assert.Equal(t, model.WaitlistWaiting, first.Status)
+	assert.Equal(t, 1, first.Position)
+	second := model.WaitlistEntry{UserID: bob.ID, ItemID: item.ID, Quantity: 1}
+	require.NoError(t, repo.CreateEntry(ctx, &second))
+	assert.Equal(t, 2, second.Position)
+
+	assert.ErrorIs(t, repo.CreateEntry(ctx, &model.WaitlistEntry{UserID: ada.ID, ItemID: item.ID, Quantity: 1}), ErrAlreadyWaitlisted)
+	assert.EqualError(t, repo.CreateEntry(ctx, &model.WaitlistEntry{UserID: ada.ID, ItemID: item.ID + 1, Quantity: 1}), "item not found")
+	assert.EqualError(t, repo.CreateEntry(ctx, &model.WaitlistEntry{UserID: bob.ID + 1, ItemID: item.ID, Quantity: 1}), "user not found")
+
+	restocked, err := repo.ListRestockedItems(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, restocked, "out of stock")
+	_, err = pool.Exec(ctx, "UPDATE items SET stock = 5 WHERE id = $1", item.ID)
+	require.NoError(t, err)
+	restocked, err = repo.ListRestockedItems(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{item.ID}, restocked)
+
+	next, err := repo.NextWaitingEntry(ctx, item.ID)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, first.ID, next.Entry.ID)
+	assert.Equal(t, "Ada", next.FirstName)
+	assert.Equal(t, "Test Item", next.ItemName)
+
+	closed, err := repo.CloseEntry(ctx, first.ID, model.WaitlistFailed, nil, "insufficient funds")
+	require.NoError(t, err)
+	assert.True(t, closed)
+	closed, err = repo.CloseEntry(ctx, first.ID, model.WaitlistFulfilled, nil, "")
+	require.NoError(t, err)
+	assert.False(t, closed, "closed once")
+	entries, err := repo.ListUserEntries(ctx, bob.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].Position, "moved up once the first entry closed")
+
+	_, err = repo.CancelEntry(ctx, ada.ID, first.ID)
+	assert.ErrorIs(t, err, ErrWaitlistEntryClosed)
+	_, err = repo.CancelEntry(ctx, ada.ID, second.ID)
+	assert.EqualError(t, err, "waitlist entry not found", "another user's entry")
+	cancelled, err := repo.CancelEntry(ctx, bob.ID, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.WaitlistCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.ClosedAt)
+
+	next, err = repo.NextWaitingEntry(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestShopRepository_RestockItem(t *testing.T) {
+	pool := testdb.New(t, "stock_movements", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	item := model.Item{Name: "Restocked Item", Price: money(10), Stock: 1}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+	id, err := repo.FindItemIDByName(ctx, "Restocked Item")
+	require.NoError(t, err)
+	assert.Equal(t, item.ID, id)
+	_, err = repo.FindItemIDByName(ctx, "Unknown")
+	assert.EqualError(t, err, "item not found")
+	twin := model.Item{Name: "Restocked Item", Price: money(10)}
+	require.NoError(t, repo.CreateItem(ctx, &twin))
+	_, err = repo.FindItemIDByName(ctx, "Restocked Item")
+	assert.ErrorIs(t, err, ErrAmbiguousItemName)
+
+	m := model.StockMovement{ItemID: item.ID, Quantity: 5, Reason: model.StockMovementRestock, Source: "supplier:file", Reference: "PO-1"}
+	applied, err := repo.RestockItem(ctx, &m)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 6, m.StockAfter)
+
+	again := m
+	applied, err = repo.RestockItem(ctx, &again)
+	require.NoError(t, err)
+	assert.False(t, applied, "a reference is applied once")
+	got, err := repo.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, got.Stock)
+
+	_, err = repo.RestockItem(ctx, &model.StockMovement{ItemID: twin.ID + 1, Quantity: 1, Reason: model.StockMovementRestock, Source: "supplier:file", Reference: "PO-2"})
+	assert.EqualError(t, err, "item not found")
+}
+
+func TestShopRepository_PriceTiers(t *testing.T) {
+	pool := testdb.New(t, "price_tiers", "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(200)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 50}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	assert.EqualError(t, repo.ReplacePriceTiers(ctx, item.ID+1, []model.PriceTier{{MinQty: 5, UnitPrice: money(9)}}), "item not found")
+	require.NoError(t, repo.ReplacePriceTiers(ctx, item.ID, []model.PriceTier{{MinQty: 10, UnitPrice: money(8)}, {MinQty: 5, UnitPrice: money(9)}}))
+
+	tiers, err := repo.ListPriceTiers(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.PriceTier{{ItemID: item.ID, MinQty: 5, UnitPrice: money(9)}, {ItemID: item.ID, MinQty: 10, UnitPrice: money(8)}}, tiers)
+
+	for quantity, want := range map[int]float64{1: 10, 4: 10, 5: 9, 9: 9, 10: 8, 30: 8} {
+		price, _, _, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, quantity)
+		require.NoError(t, err)
+		assert.Equal(t, money(want), price, "quantity %d", quantity)
+	}
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 5}
+	_, _, err = repo.PurchaseSingleStatement(ctx, &order)
+	require.NoError(t, err)
+	assert.Equal(t, money(45), order.Price)
+	assert.Equal(t, money(9), order.UnitPrice)
+
+	orders, err := repo.ListUserOrders(ctx, user.ID, model.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, money(9), orders[0].UnitPrice)
+
+	require.NoError(t, repo.ReplacePriceTiers(ctx, item.ID, nil))
+	tiers, err = repo.ListPriceTiers(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, tiers)
+}
+
+func TestInventoryRepository_Transfer(t *testing.T) {
+	pool := testdb.New(t, "inventories", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	repo := NewInventoryRepository(pool)
+	ctx := context.Background()
+
+	from := model.User{FirstName: "From", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &from))
+	to := model.User{FirstName: "To", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &to))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, shop.CreateItem(ctx, &item))
+	require.NoError(t, shop.GrantInventory(ctx, from.ID, item.ID, 3))
+
+	_, err := repo.LockInventories(ctx, item.ID, from.ID, to.ID+1)
+	assert.EqualError(t, err, "user not found")
+	_, err = repo.LockInventories(ctx, item.ID+1, from.ID)
+	assert.EqualError(t, err, "item not found")
+
+	err = shop.RunAtomic(ctx, func(ctx context.Context) error {
+		owned, err := repo.LockInventories(ctx, item.ID, to.ID, from.ID)
+		require.NoError(t, err)
+		assert.Equal(t, map[int]int{from.ID: 3, to.ID: 0}, owned, "the recipient's row is created empty")
+
+		_, err = repo.AddInventory(ctx, from.ID, item.ID, -4)
+		return err
+	})
+	assert.EqualError(t, err, "insufficient quantity")
+
+	// The failed transaction rolled back the recipient's row as well
+	_, err = repo.AddInventory(ctx, to.ID, item.ID, 2)
+	assert.EqualError(t, err, "inventory not found")
+
+	_, err = repo.LockInventories(ctx, item.ID, from.ID, to.ID)
+	require.NoError(t, err)
+	_, err = repo.AddInventory(ctx, from.ID, item.ID, -2)
+	require.NoError(t, err)
+	_, err = repo.AddInventory(ctx, to.ID, item.ID, 2)
+	require.NoError(t, err)
+
+	inventory, err := repo.ListUserInventory(ctx, to.ID)
+	require.NoError(t, err)
+	require.Len(t, inventory, 1)
+	assert.Equal(t, 2, inventory[0].Quantity)
+
+	require.NoError(t, shop.RevokeInventory(ctx, from.ID, item.ID, 5))
+	inventory, err = repo.ListUserInventory(ctx, from.ID)
+	require.NoError(t, err)
+	assert.Empty(t, inventory, "revoking never goes below zero")
+}
+
+func TestInventoryRepository_ListPortfolio(t *testing.T) {
+	pool := testdb.New(t, "inventories", "item_price_snapshots", "skinport_price_mappings", "users", "items")
+	shop := NewShopRepository(pool)
+	repo := NewInventoryRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &user))
+	mapped := model.Item{Name: "AK-47", Price: money(12), Stock: 5}
+	require.NoError(t, shop.CreateItem(ctx, &mapped))
+	plain := model.Item{Name: "Sticker", Price: money(5), Stock: 5}
+	require.NoError(t, shop.CreateItem(ctx, &plain))
+	require.NoError(t, shop.GrantInventory(ctx, user.ID, mapped.ID, 2))
+	require.NoError(t, shop.GrantInventory(ctx, user.ID, plain.ID, 3))
+	require.NoError(t, NewPriceSyncRepository(pool, nil).UpsertPriceMapping(ctx,
+		&model.SkinportPriceMapping{ItemID: mapped.ID, MarketHashName: "AK-47 | Redline (Field-Tested)"}))
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO item_price_snapshots (item_id, price, stock, taken_at) VALUES
+			($1, 8, 5, NOW() - INTERVAL '8 days'),
+			($1, 9, 5, NOW() - INTERVAL '7 days 1 hour'),
+			($1, 10, 5, NOW() - INTERVAL '2 days'),
+			($1, 11, 5, NOW() - INTERVAL '1 hour'),
+			($2, 4, 5, NOW() - INTERVAL '25 hours')`, mapped.ID, plain.ID)
+	require.NoError(t, err)
+
+	holdings, err := repo.ListPortfolio(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, holdings, 2)
+	assert.Equal(t, "AK-47 | Redline (Field-Tested)", *holdings[0].MarketHashName)
+	assert.Equal(t, 2, holdings[0].Quantity)
+	assert.Equal(t, 10.0, *holdings[0].Price24h, "the latest snapshot at least a day old")
+	assert.Equal(t, 9.0, *holdings[0].Price7d)
+	assert.Nil(t, holdings[1].MarketHashName)
+	assert.Equal(t, 4.0, *holdings[1].Price24h)
+	assert.Nil(t, holdings[1].Price7d)
+}
+
+func TestFavoriteRepository(t *testing.T) {
+	pool := testdb.New(t, "favorites", "users")
+	repo := NewFavoriteRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User"}
+	require.NoError(t, NewShopRepository(pool).CreateUser(ctx, &user))
+
+	first := model.Favorite{UserID: user.ID, MarketHashName: "AK-47 | Redline (Field-Tested)"}
+	require.NoError(t, repo.AddFavorite(ctx, &first))
+	again := first
+	require.NoError(t, repo.AddFavorite(ctx, &again), "adding twice is a no-op")
+	assert.Equal(t, first.CreatedAt, again.CreatedAt)
+
+	assert.EqualError(t, repo.AddFavorite(ctx, &model.Favorite{UserID: user.ID + 1, MarketHashName: "x"}), "user not found")
+
+	favorites, err := repo.ListFavorites(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, favorites, 1)
+	assert.Equal(t, first.MarketHashName, favorites[0].MarketHashName)
+
+	require.NoError(t, repo.RemoveFavorite(ctx, user.ID, first.MarketHashName))
+	assert.EqualError(t, repo.RemoveFavorite(ctx, user.ID, first.MarketHashName), "favorite not found")
+}
+
+func TestShopRepository_PendingReceipts(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "User", Balance: money(100), Email: "ada@example.com"}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Sword", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(10), Quantity: 1}
+	_, err := repo.CreateOrder(ctx, &order)
+	require.NoError(t, err)
+
+	err = repo.RunAtomic(ctx, func(ctx context.Context) error {
+		receipts, err := repo.ClaimPendingReceipts(ctx, time.Hour, 10)
+		require.NoError(t, err)
+		require.Len(t, receipts, 1)
+		assert.Equal(t, "ada@example.com", receipts[0].Email)
+		assert.Equal(t, "Sword", receipts[0].ItemName)
+		assert.Equal(t, order.ID, receipts[0].Order.ID)
+
+		// Another instance skips the locked order
+		others, err := repo.ClaimPendingReceipts(context.Background(), time.Hour, 10)
+		require.NoError(t, err)
+		assert.Empty(t, others)
+
+		return repo.MarkReceiptsSent(ctx, []int{order.ID})
+	})
+	require.NoError(t, err)
+
+	receipts, err := repo.ClaimPendingReceipts(ctx, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Empty(t, receipts)
+}
+
+func TestFavoriteRepository_DueAlerts(t *testing.T) {
+	pool := testdb.New(t, "favorites", "users")
+	shop := NewShopRepository(pool)
+	repo := NewFavoriteRepository(pool)
+	ctx := context.Background()
+
+	withEmail := model.User{FirstName: "Ada", LastName: "User", Email: "ada@example.com"}
+	require.NoError(t, shop.CreateUser(ctx, &withEmail))
+	withoutEmail := model.User{FirstName: "Bob", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &withoutEmail))
+	withTelegram := model.User{FirstName: "Cy", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &withTelegram))
+	require.NoError(t, NewTelegramRepository(pool).Link(ctx, withTelegram.ID, 42))
+
+	below := 10.0
+	require.NoError(t, repo.AddFavorite(ctx, &model.Favorite{UserID: withEmail.ID, MarketHashName: "AK", AlertBelow: &below}))
+	require.NoError(t, repo.AddFavorite(ctx, &model.Favorite{UserID: withEmail.ID, MarketHashName: "no alert"}))
+	require.NoError(t, repo.AddFavorite(ctx, &model.Favorite{UserID: withoutEmail.ID, MarketHashName: "AK", AlertBelow: &below}))
+	require.NoError(t, repo.AddFavorite(ctx, &model.Favorite{UserID: withTelegram.ID, MarketHashName: "AK", AlertBelow: &below}))
+
+	alerts, err := repo.ListDueAlerts(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []model.DuePriceAlert{
+		{UserID: withEmail.ID, Email: "ada@example.com", FirstName: "Ada", MarketHashName: "AK", AlertBelow: 10},
+		{UserID: withTelegram.ID, TelegramChatID: 42, FirstName: "Cy", MarketHashName: "AK", AlertBelow: 10},
+	}, alerts)
+
+	require.NoError(t, repo.MarkAlerted(ctx, withEmail.ID, "AK"))
+	require.NoError(t, repo.MarkAlerted(ctx, withTelegram.ID, "AK"))
+	alerts, err = repo.ListDueAlerts(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, alerts, "alerts cool down after firing")
+}
+
+func TestPriceSyncRepository(t *testing.T) {
+	pool := testdb.New(t, "items")
+	shop := NewShopRepository(pool)
+	repo := NewPriceSyncRepository(pool, nil)
+	ctx := context.Background()
+
+	item := model.Item{Name: "AK-47", Price: money(10), Stock: 5}
+	require.NoError(t, shop.CreateItem(ctx, &item))
+
+	markup := 25.0
+	m := model.SkinportPriceMapping{ItemID: item.ID, MarketHashName: "AK-47 | Redline (Field-Tested)", MarkupPercent: &markup}
+	require.NoError(t, repo.UpsertPriceMapping(ctx, &m))
+	assert.Equal(t, "AK-47", m.ItemName)
+	assert.Equal(t, 10.0, m.ItemPrice)
+	assert.EqualError(t, repo.UpsertPriceMapping(ctx, &model.SkinportPriceMapping{ItemID: item.ID + 1, MarketHashName: "x"}), "item not found")
+
+	mappings, err := repo.ListPriceMappings(ctx)
+	require.NoError(t, err)
+	require.Len(t, mappings, 1)
+	assert.Equal(t, m.MarketHashName, mappings[0].MarketHashName)
+	assert.Equal(t, 25.0, *mappings[0].MarkupPercent)
+
+	reference := 9.5
+	change := model.PriceChange{ItemID: item.ID, OldPrice: money(10), NewPrice: money(11.9), Source: model.PriceChangeSkinportSync, ReferencePrice: &reference}
+	applied, err := repo.ApplyPriceChange(ctx, &change)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	// The price is no longer 10, a stale change is skipped
+	applied, err = repo.ApplyPriceChange(ctx, &change)
+	require.NoError(t, err)
+	assert.False(t, applied)
+
+	got, err := shop.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(11.9), got.Price)
+
+	changes, err := repo.ListPriceChanges(ctx, model.PriceChangeFilter{ItemID: item.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, money(10), changes[0].OldPrice)
+	assert.Equal(t, money(11.9), changes[0].NewPrice)
+	assert.Equal(t, 9.5, *changes[0].ReferencePrice)
+	assert.Equal(t, model.PriceChangeApplied, changes[0].Status)
+
+	// A later proposal replaces the pending one
+	pending := model.PriceChange{ItemID: item.ID, OldPrice: money(11.9), NewPrice: money(20), Source: model.PriceChangeSkinportSync}
+	require.NoError(t, repo.ProposePriceChange(ctx, &pending))
+	assert.Equal(t, model.PriceChangePending, pending.Status)
+	again := model.PriceChange{ItemID: item.ID, OldPrice: money(11.9), NewPrice: money(21), Source: model.PriceChangeSkinportSync}
+	require.NoError(t, repo.ProposePriceChange(ctx, &again))
+	assert.Equal(t, pending.ID, again.ID)
+
+	locked, err := repo.GetPriceChangeForUpdate(ctx, pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(21), locked.NewPrice)
+	require.NoError(t, repo.DecidePriceChange(ctx, locked, model.PriceChangeRejected, money(11.9)))
+	assert.NotNil(t, locked.DecidedAt)
+
+	// An applied change supersedes a pending one
+	require.NoError(t, repo.ProposePriceChange(ctx, &model.PriceChange{ItemID: item.ID, OldPrice: money(11.9), NewPrice: money(30), Source: model.PriceChangeSkinportSync}))
+	applied, err = repo.ApplyPriceChange(ctx, &model.PriceChange{ItemID: item.ID, OldPrice: money(11.9), NewPrice: money(12), Source: model.PriceChangeSkinportSync})
+	require.NoError(t, err)
+	assert.True(t, applied)
+	superseded, err := repo.ListPriceChanges(ctx, model.PriceChangeFilter{Status: model.PriceChangeSuperseded, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, superseded, 1)
+	assert.Equal(t, money(30), superseded[0].NewPrice)
+
+	_, err = repo.GetPriceChangeForUpdate(ctx, 1<<30)
+	assert.EqualError(t, err, "price change not found")
+
+	require.NoError(t, repo.DeletePriceMapping(ctx, item.ID))
+	assert.EqualError(t, repo.DeletePriceMapping(ctx, item.ID), "price mapping not found")
+}
+
+func TestTelegramRepository(t *testing.T) {
+	pool := testdb.New(t, "users")
+	shop := NewShopRepository(pool)
+	repo := NewTelegramRepository(pool)
+	ctx := context.Background()
+
+	ada := model.User{FirstName: "Ada", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &ada))
+	bob := model.User{FirstName: "Bob", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &bob))
+
+	_, err := repo.CreateLinkToken(ctx, 999, "missing", time.Minute)
+	assert.EqualError(t, err, "user not found")
+
+	expiresAt, err := repo.CreateLinkToken(ctx, ada.ID, "hash", time.Minute)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Hour, "expiry is set by the database")
+
+	userID, err := repo.ConsumeLinkToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, ada.ID, userID)
+	_, err = repo.ConsumeLinkToken(ctx, "hash")
+	assert.EqualError(t, err, "invalid or expired link token", "tokens are single use")
+
+	_, err = repo.CreateLinkToken(ctx, ada.ID, "expired", -time.Minute)
+	require.NoError(t, err)
+	_, err = repo.ConsumeLinkToken(ctx, "expired")
+	assert.EqualError(t, err, "invalid or expired link token")
+
+	require.NoError(t, repo.Link(ctx, ada.ID, 42))
+	// Relinking the chat to another user moves it
+	require.NoError(t, repo.Link(ctx, bob.ID, 42))
+	userID, err = repo.UserIDForChat(ctx, 42)
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, userID)
+
+	userID, err = repo.Unlink(ctx, 42)
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, userID)
+	_, err = repo.UserIDForChat(ctx, 42)
+	assert.EqualError(t, err, "telegram chat not linked")
+}
+
+func TestPayoutRepository(t *testing.T) {
+	pool := testdb.New(t, "payouts", "users")
+	shop := NewShopRepository(pool)
+	repo := NewPayoutRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "User", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(ctx, &user))
+
+	first := model.Payout{UserID: user.ID, Amount: money(10), Destination: "acct_1"}
+	require.NoError(t, repo.CreatePayout(ctx, &first))
+	assert.Equal(t, model.PayoutStatusPending, first.Status)
+	second := model.Payout{UserID: user.ID, Amount: money(20), Destination: "acct_2"}
+	require.NoError(t, repo.CreatePayout(ctx, &second))
+
+	claimed, err := repo.ClaimPayouts(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, first.ID, claimed[0].ID, "oldest first")
+	assert.Equal(t, model.PayoutStatusSending, claimed[0].Status)
+
+	// Payouts being sent are skipped by other instances until they are stale
+	other, err := repo.ClaimPayouts(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+	stale, err := repo.ClaimPayouts(ctx, 10, -time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, stale, 2)
+
+	require.NoError(t, repo.CompletePayout(ctx, first.ID, "stub", "ref-1"))
+	require.NoError(t, repo.RecordPayoutAttempt(ctx, second.ID, "stub", "timeout"))
+	assert.ErrorIs(t, repo.CompletePayout(ctx, first.ID, "stub", "ref-1"), ErrPayoutNotSending,
+		"the outcome is recorded once")
+
+	completed, err := repo.GetPayout(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PayoutStatusCompleted, completed.Status)
+	assert.Equal(t, "ref-1", completed.Reference)
+	assert.Equal(t, 1, completed.Attempts)
+	assert.NotNil(t, completed.ProcessedAt)
+
+	retried, err := repo.GetPayout(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PayoutStatusPending, retried.Status)
+	assert.Equal(t, "timeout", retried.LastError)
+
+	assert.ErrorIs(t, repo.FailPayout(ctx, second.ID, "stub", "invalid account"), ErrPayoutNotSending)
+	_, err = repo.ClaimPayouts(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, repo.FailPayout(ctx, second.ID, "stub", "invalid account"))
+	failed, err := repo.GetPayout(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PayoutStatusFailed, failed.Status)
+	assert.Equal(t, 2, failed.Attempts)
+
+	_, err = repo.GetPayout(ctx, 999)
+	assert.EqualError(t, err, "payout not found")
+}
+
+func TestUserLifecycle(t *testing.T) {
+	pool := testdb.New(t, "orders", "payouts", "favorites", "telegram_links", "audit_log", "users", "items")
+	repo := NewShopRepository(pool)
+	payouts := NewPayoutRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "Lovelace", Balance: money(100), Email: "ada@example.com"}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	assert.Equal(t, model.UserStatusActive, user.Status)
+	item := model.Item{Name: "AK-47", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	// Deactivated users cannot buy on either purchase path
+	require.NoError(t, repo.SetUserStatus(ctx, user.ID, model.UserStatusDeactivated))
+	_, _, _, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+	assert.ErrorIs(t, err, ErrUserInactive)
+	_, _, err = repo.PurchaseSingleStatement(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1})
+	assert.ErrorIs(t, err, ErrUserInactive)
+
+	require.NoError(t, repo.SetUserStatus(ctx, user.ID, model.UserStatusActive))
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1, ClientOrderID: "ada-checkout"}
+	_, _, err = repo.PurchaseSingleStatement(ctx, &order)
+	require.NoError(t, err)
+
+	payout := model.Payout{UserID: user.ID, Amount: money(10), Destination: "acct_ada"}
+	require.NoError(t, payouts.CreatePayout(ctx, &payout))
+	pending, err := repo.HasPendingPayouts(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, pending)
+
+	require.NoError(t, repo.AnonymizeUser(ctx, user.ID))
+	got, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.User{ID: user.ID, FirstName: "Deleted", LastName: "User", Balance: money(90), Status: model.UserStatusDeleted}, *got)
+
+	// Financial records stay, without their personal details
+	kept, err := repo.GetOrderForUpdate(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(10), kept.Price)
+	assert.Empty(t, kept.ClientOrderID)
+	var destination string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT destination FROM payouts WHERE id = $1", payout.ID).Scan(&destination))
+	assert.Equal(t, "[deleted]", destination)
+
+	assert.EqualError(t, repo.AnonymizeUser(ctx, user.ID+1), "user not found")
+}
+
+func TestSteamRepository(t *testing.T) {
+	pool := testdb.New(t, "steam_accounts", "users")
+	shop := NewShopRepository(pool)
+	repo := NewSteamRepository(pool)
+	ctx := context.Background()
+
+	ada := model.User{FirstName: "Ada", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &ada))
+	bob := model.User{FirstName: "Bob", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &bob))
+
+	account := model.SteamAccount{UserID: ada.ID, SteamID: "76561197960287930", PersonaName: "ada"}
+	require.NoError(t, repo.SaveAccount(ctx, &account))
+	assert.False(t, account.LinkedAt.IsZero())
+
+	// A refresh without a profile keeps the stored one
+	require.NoError(t, repo.SaveAccount(ctx, &model.SteamAccount{UserID: ada.ID, SteamID: account.SteamID}))
+	got, err := repo.GetAccountBySteamID(ctx, account.SteamID)
+	require.NoError(t, err)
+	assert.Equal(t, ada.ID, got.UserID)
+	assert.Equal(t, "ada", got.PersonaName)
+
+	assert.EqualError(t, repo.SaveAccount(ctx, &model.SteamAccount{UserID: bob.ID, SteamID: account.SteamID}),
+		"steam account is linked to another user")
+	assert.EqualError(t, repo.SaveAccount(ctx, &model.SteamAccount{UserID: bob.ID + 1, SteamID: "76561197960287931"}), "user not found")
+
+	require.NoError(t, repo.DeleteAccount(ctx, ada.ID))
+	_, err = repo.GetAccount(ctx, ada.ID)
+	assert.ErrorIs(t, err, ErrSteamAccountNotFound)
+	assert.ErrorIs(t, repo.DeleteAccount(ctx, ada.ID), ErrSteamAccountNotFound)
+}
+
+func TestDepositRepository(t *testing.T) {
+	pool := testdb.New(t, "deposits", "users")
+	shop := NewShopRepository(pool)
+	repo := NewDepositRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "User"}
+	require.NoError(t, shop.CreateUser(ctx, &user))
+
+	assert.EqualError(t, repo.CreateDeposit(ctx, &model.Deposit{UserID: 999, Amount: money(10), Currency: "usd"}), "user not found")
+
+	paid := model.Deposit{UserID: user.ID, Amount: money(10), Currency: "usd"}
+	require.NoError(t, repo.CreateDeposit(ctx, &paid))
+	assert.Equal(t, model.DepositStatusPending, paid.Status)
+	require.NoError(t, repo.SetDepositSession(ctx, paid.ID, "cs_1"))
+	abandoned := model.Deposit{UserID: user.ID, Amount: money(20), Currency: "usd"}
+	require.NoError(t, repo.CreateDeposit(ctx, &abandoned))
+	require.NoError(t, repo.SetDepositSession(ctx, abandoned.ID, "cs_2"))
+
+	pending, err := repo.ListPendingDeposits(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "cs_1", pending[0].SessionID)
+	pending, err = repo.ListPendingDeposits(ctx, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "recent deposits wait for their webhook")
+
+	require.NoError(t, shop.RunAtomic(ctx, func(ctx context.Context) error {
+		d, err := repo.GetDepositForUpdate(ctx, paid.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "cs_1", d.SessionID)
+		return repo.MarkDepositSucceeded(ctx, paid.ID, "pi_1")
+	}))
+	d, err := repo.GetDepositForUpdate(ctx, paid.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DepositStatusSucceeded, d.Status)
+	assert.Equal(t, "pi_1", d.PaymentIntentID)
+	assert.NotNil(t, d.CreditedAt)
+
+	closed, err := repo.CloseDeposit(ctx, paid.ID, model.DepositStatusExpired)
+	require.NoError(t, err)
+	assert.False(t, closed, "succeeded deposits stay succeeded")
+	closed, err = repo.CloseDeposit(ctx, abandoned.ID, model.DepositStatusExpired)
+	require.NoError(t, err)
+	assert.True(t, closed)
+
+	mismatched := model.Deposit{UserID: user.ID, Amount: money(30), Currency: "usd"}
+	require.NoError(t, repo.CreateDeposit(ctx, &mismatched))
+	require.NoError(t, repo.SetDepositSession(ctx, mismatched.ID, "cs_3"))
+	require.NoError(t, repo.FlagDepositForReview(ctx, mismatched.ID, "pi_3"))
+	d, err = repo.GetDepositForUpdate(ctx, mismatched.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DepositStatusNeedsReview, d.Status)
+	assert.Equal(t, "pi_3", d.PaymentIntentID)
+	assert.Nil(t, d.CreditedAt)
+	pending, err = repo.ListPendingDeposits(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "deposits under review are not reconciled")
+
+	_, err = repo.GetDepositForUpdate(ctx, 999)
+	assert.EqualError(t, err, "deposit not found")
+}
+
+func TestLedger(t *testing.T) {
+	pool := testdb.New(t, "ledger_transactions", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	ledger := NewLedgerRepository(pool)
+	ctx := context.Background()
+
+	entries := func(reference string) []float64 {
+		rows, err := pool.Query(ctx, `
+			SELECT e.amount::float8 FROM ledger_entries e JOIN ledger_transactions t ON t.id = e.transaction_id
+			WHERE t.reference = $1 ORDER BY e.id`, reference)
+		require.NoError(t, err)
+		amounts, err := pgx.CollectRows(rows, pgx.RowTo[float64])
+		require.NoError(t, err)
+		return amounts
+	}
+
+	user := model.User{FirstName: "Ada", LastName: "User", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(ctx, &user))
+	assert.Equal(t, []float64{100, -100}, entries(fmt.Sprintf("user:%d", user.ID)), "the initial balance is opened")
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, shop.CreateItem(ctx, &item))
+
+	order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(20), Quantity: 2}
+	require.NoError(t, shop.RunAtomic(ctx, func(ctx context.Context) error {
+		if _, _, _, err := shop.LockPurchaseRows(ctx, item.ID, user.ID, 1); err != nil {
+			return err
+		}
+		return shop.ApplyPurchase(ctx, &order)
+	}))
+	assert.Equal(t, []float64{-20, 20}, entries(fmt.Sprintf("order:%d", order.ID)))
+
+	single := model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1}
+	_, _, err := shop.PurchaseSingleStatement(ctx, &single)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{-10, 10}, entries(fmt.Sprintf("order:%d", single.ID)))
+
+	balance, err := shop.AdjustUserBalance(ctx, user.ID, money(20), model.LedgerKindRefund, fmt.Sprintf("order:%d", order.ID))
+	require.NoError(t, err)
+	assert.Equal(t, 90.0, balance.Float())
+	_, err = shop.AdjustUserBalance(ctx, user.ID, money(-91), model.LedgerKindWithdrawal, "payout:1")
+	assert.EqualError(t, err, "insufficient funds")
+	assert.Empty(t, entries("payout:1"))
+	_, err = shop.AdjustUserBalance(ctx, user.ID+1, money(10), model.LedgerKindDeposit, "")
+	assert.EqualError(t, err, "user not found")
+
+	got, err := shop.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(90), got.Balance)
+	violations, err := ledger.CheckLedger(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	_, err = pool.Exec(ctx, "UPDATE users SET balance = 1000 WHERE id = $1", user.ID)
+	assert.ErrorContains(t, err, "maintained by the ledger")
+	_, err = pool.Exec(ctx, "DELETE FROM ledger_entries")
+	assert.ErrorContains(t, err, "append-only")
+
+	// A one-sided entry still moves the balance, the check reports the transaction
+	var txID int64
+	require.NoError(t, pool.QueryRow(ctx, "INSERT INTO ledger_transactions (kind) VALUES ('adjustment') RETURNING id").Scan(&txID))
+	_, err = pool.Exec(ctx, `
+		INSERT INTO ledger_entries (transaction_id, account_id, amount)
+		SELECT $1, id, 5 FROM ledger_accounts WHERE user_id = $2`, txID, user.ID)
+	require.NoError(t, err)
+	violations, err = ledger.CheckLedger(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []model.LedgerViolation{{Type: "unbalanced_transaction", ID: txID, Expected: 0, Actual: 5}}, violations)
+}
+
+// The purchases below skip the application's checks, as if they had regressed: the
+// CHECK constraints alone must keep stock and balance from going negative.
+func TestShopRepository_ConstraintsUnderConcurrentPurchases(t *testing.T) {
+	tests := []struct {
+		name          string
+		balance       float64
+		stock         int
+		wantErr       error
+		wantSucceeded int
+	}{
+		{"stock runs out", 1000, 3, ErrInsufficientStock, 3},
+		{"balance runs out", 50, 100, ErrInsufficientFunds, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := testdb.New(t, "orders", "users", "items")
+			repo := NewShopRepository(pool, WithRetry(10, time.Millisecond))
+			ctx := context.Background()
+
+			user := model.User{FirstName: "Test", LastName: "User", Balance: money(tt.balance)}
+			require.NoError(t, repo.CreateUser(ctx, &user))
+			item := model.Item{Name: "Test Item", Price: money(10), Stock: tt.stock}
+			require.NoError(t, repo.CreateItem(ctx, &item))
+
+			const buyers = 20
+			errs := make(chan error, buyers)
+			var wg sync.WaitGroup
+			for i := 0; i < buyers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					order := model.Order{UserID: user.ID, ItemID: item.ID, Price: money(10), Quantity: 1}
+					if i%2 == 0 {
+						errs <- repo.RunAtomic(ctx, func(ctx context.Context) error { return repo.ApplyPurchase(ctx, &order) })
+						return
+					}
+					errs <- repo.RunAtomic(ctx, func(ctx context.Context) error {
+						if err := repo.UpdateItemStock(ctx, item.ID, 1); err != nil {
+							return err
+						}
+						return repo.UpdateUserBalance(ctx, user.ID, money(10))
+					})
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+
+			succeeded := 0
+			for err := range errs {
+				if err == nil {
+					succeeded++
+					continue
+				}
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantSucceeded, succeeded)
+
+			got, err := repo.GetUser(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, money(tt.balance-10*float64(succeeded)), got.Balance)
+			assert.GreaterOrEqual(t, got.Balance.Amount, int64(0))
+			gotItem, err := repo.GetItem(ctx, item.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.stock-succeeded, gotItem.Stock)
+			assert.GreaterOrEqual(t, gotItem.Stock, 0)
+		})
+	}
+}
+
+func TestShopRepository_CopyOrders(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Ada", LastName: "Lovelace", Balance: money(5)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Knife", Price: money(10), Stock: 3}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	users, err := repo.ExistingUserIDs(ctx, []int{user.ID, user.ID + 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{user.ID: true}, users)
+
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	n, err := repo.CopyOrders(ctx, []model.Order{
+		{UserID: user.ID, ItemID: item.ID, Price: money(20), UnitPrice: money(10), Quantity: 2, Status: model.OrderStatusPaid, ClientOrderID: "legacy-1", CreatedAt: at, PaidAt: &at},
+		{UserID: user.ID, ItemID: item.ID, Price: money(10), UnitPrice: money(10), Quantity: 1, Status: model.OrderStatusCancelled, CreatedAt: at, CancelledAt: &at},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	taken, err := repo.ExistingClientOrderIDs(ctx, []ClientOrderKey{{user.ID, "legacy-1"}, {user.ID, "legacy-2"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[ClientOrderKey]bool{{user.ID, "legacy-1"}: true}, taken)
+
+	order, err := repo.GetOrderByClientOrderID(ctx, user.ID, "legacy-1")
+	require.NoError(t, err)
+	assert.Equal(t, money(20), order.Price)
+	assert.True(t, order.CreatedAt.Equal(at))
+
+	// History only: balance and stock are untouched, no receipts are due
+	got, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(5), got.Balance)
+	receipts, err := repo.ClaimPendingReceipts(ctx, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Empty(t, receipts)
+}
+
+func TestShopRepository_CopyItems(t *testing.T) {
+	pool := testdb.New(t, "item_tags", "tags", "items", "categories")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	require.NoError(t, NewCategoryRepository(pool, nil).CreateCategory(ctx, &model.Category{Slug: "knives", Name: "Knives"}))
+	knife := model.Item{Name: "Knife", Price: money(10), Stock: 3, Description: "old"}
+	require.NoError(t, repo.CreateItem(ctx, &knife))
+
+	counts, err := repo.CountItemsByName(ctx, []string{"Knife", "Gloves"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Knife": 1}, counts)
+
+	category, empty := "knives", ""
+	var created, updated map[string]int
+	err = repo.RunAtomic(ctx, func(ctx context.Context) error {
+		created, updated, err = repo.CopyItems(ctx, []model.ItemImport{
+			{Name: "Knife", Price: money(12.5), Stock: 4, Category: &category, Tags: []string{"rare", "steel"}},
+			{Name: "Gloves", Price: money(3), Stock: 1, Description: &empty},
+		}, true)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Knife": knife.ID}, updated)
+	require.Contains(t, created, "Gloves")
+
+	got, err := repo.GetItem(ctx, knife.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money(12.5), got.Price)
+	assert.Equal(t, "old", got.Description, "a missing description is left unchanged")
+
+	var buf strings.Builder
+	require.NoError(t, repo.ExportItemsCSV(ctx, &buf))
+	assert.Equal(t, "id,name,price,stock,category,tags,description,image_url\n"+
+		fmt.Sprintf("%d,Knife,12.50,4,knives,rare|steel,old,\"\"\n", knife.ID)+
+		fmt.Sprintf("%d,Gloves,3.00,1,\"\",\"\",\"\",\"\"\n", created["Gloves"]), buf.String())
+}
+
+func TestShopRepository_RunAtomicNested(t *testing.T) {
+	pool := testdb.New(t, "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	item := model.Item{Name: "Knife", Price: money(10), Stock: 10}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+	stock := func() int {
+		got, err := repo.GetItem(ctx, item.ID)
+		require.NoError(t, err)
+		return got.Stock
+	}
+
+	// A failed inner operation rolls back only its own work, even after a failed
+	// statement, and the outer transaction still commits
+	err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.UpdateItemStock(ctx, item.ID, 1))
+		err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+			require.NoError(t, repo.UpdateItemStock(ctx, item.ID, 2))
+			return repo.UpdateItemStock(ctx, item.ID, 100)
+		})
+		assert.EqualError(t, err, "insufficient stock")
+
+		require.NoError(t, repo.RunAtomic(ctx, func(ctx context.Context) error {
+			require.NoError(t, repo.UpdateItemStock(ctx, item.ID, 3))
+			return repo.RunAtomic(ctx, func(ctx context.Context) error {
+				return repo.UpdateItemStock(ctx, item.ID, 4)
+			})
+		}))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stock())
+
+	// A failed outer transaction rolls back its committed inner operations too
+	err = repo.RunAtomic(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.RunAtomic(ctx, func(ctx context.Context) error {
+			return repo.UpdateItemStock(ctx, item.ID, 1)
+		}))
+		return fmt.Errorf("outer failed")
+	})
+	assert.EqualError(t, err, "outer failed")
+	assert.Equal(t, 2, stock())
+}
+
+func TestTenantIsolation(t *testing.T) {
+	pool := testdb.NewWith(t, PoolConfig{}.Apply, "leaderboard_buyers", "leaderboard_items", "leaderboard_refreshes", "payouts", "drops", "waitlist_entries", "stock_movements", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	leaderboards := NewLeaderboardRepository(pool)
+	tenants := NewTenantRepository(pool)
+	ctx := context.Background()
+
+	// tenants is not truncated, rows of the other tables reference the default tenant
+	acme, err := tenants.GetTenantBySlug(ctx, "acme-test")
+	if err != nil {
+		acme = &model.Tenant{Slug: "acme-test", Name: "Acme"}
+		require.NoError(t, tenants.CreateTenant(ctx, acme))
+	}
+	defaultCtx := tenant.WithID(ctx, tenant.DefaultID)
+	acmeCtx := tenant.WithID(ctx, acme.ID)
+
+	ada := model.User{FirstName: "Ada", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(defaultCtx, &ada))
+	bob := model.User{FirstName: "Bob", Balance: money(100)}
+	require.NoError(t, shop.CreateUser(acmeCtx, &bob))
+	widget := model.Item{Name: "Widget", Price: money(10), Stock: 10}
+	require.NoError(t, shop.CreateItem(acmeCtx, &widget))
+
+	_, err = shop.GetUser(acmeCtx, ada.ID)
+	assert.EqualError(t, err, "user not found")
+	_, err = shop.GetUser(defaultCtx, bob.ID)
+	assert.EqualError(t, err, "user not found")
+	_, err = shop.GetUser(acmeCtx, bob.ID)
+	assert.NoError(t, err)
+	_, err = shop.GetUser(ctx, ada.ID)
+	assert.NoError(t, err, "unscoped connections see every tenant")
+
+	// Transactions are scoped too, and rows cannot be moved to another tenant
+	err = shop.RunAtomic(defaultCtx, func(ctx context.Context) error {
+		_, _, err := shop.GetItemForUpdate(ctx, widget.ID)
+		return err
+	})
+	assert.EqualError(t, err, "item not found")
+	_, err = pool.Exec(acmeCtx, "UPDATE users SET tenant_id = $1 WHERE id = $2", tenant.DefaultID, bob.ID)
+	assert.Error(t, err)
+
+	require.NoError(t, shop.RunAtomic(acmeCtx, func(ctx context.Context) error {
+		_, err := shop.CreateOrder(ctx, &model.Order{UserID: bob.ID, ItemID: widget.ID, Price: money(10), Quantity: 1})
+		return err
+	}))
+
+	// Rows created outside any tenant belong to the tenant of the row they belong to
+	payouts := NewPayoutRepository(pool)
+	payout := model.Payout{UserID: bob.ID, Amount: money(5), Destination: "acct_bob"}
+	require.NoError(t, payouts.CreatePayout(ctx, &payout))
+	_, err = payouts.GetPayout(defaultCtx, payout.ID)
+	assert.EqualError(t, err, "payout not found")
+	_, err = payouts.GetPayout(acmeCtx, payout.ID)
+	assert.NoError(t, err)
+	balance, err := shop.AdjustUserBalance(ctx, bob.ID, money(1), model.LedgerKindAdjustment, "test")
+	require.NoError(t, err)
+	var ledgerTenant int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT tenant_id FROM ledger_transactions WHERE reference = 'test'").Scan(&ledgerTenant))
+	assert.Equal(t, acme.ID, ledgerTenant)
+	got, err := shop.GetUser(acmeCtx, bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Amount, got.Balance.Amount)
+
+	drops := NewDropRepository(pool)
+	drop := model.Drop{ItemID: widget.ID, Name: "Launch", StartsAt: time.Now(), Stock: 5, PerUserLimit: 1}
+	require.NoError(t, drops.CreateDrop(ctx, &drop))
+	open, err := drops.ListOpenDrops(defaultCtx)
+	require.NoError(t, err)
+	assert.Empty(t, open)
+	open, err = drops.ListOpenDrops(acmeCtx)
+	require.NoError(t, err)
+	assert.Len(t, open, 1)
+
+	waitlist := NewWaitlistRepository(pool)
+	require.NoError(t, waitlist.CreateEntry(ctx, &model.WaitlistEntry{UserID: bob.ID, ItemID: widget.ID, Quantity: 1}))
+	entries, err := waitlist.ListUserEntries(defaultCtx, bob.ID)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	entries, err = waitlist.ListUserEntries(acmeCtx, bob.ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	applied, err := shop.RestockItem(ctx, &model.StockMovement{ItemID: widget.ID, Quantity: 5,
+		Reason: model.StockMovementRestock, Source: "test", Reference: "line-1"})
+	require.NoError(t, err)
+	require.True(t, applied)
+	for scope, want := range map[context.Context]int{defaultCtx: 0, acmeCtx: 1} {
+		var movements int
+		require.NoError(t, pool.QueryRow(scope, "SELECT COUNT(*) FROM stock_movements").Scan(&movements))
+		assert.Equal(t, want, movements)
+	}
+
+	// Leaderboards are rebuilt for every tenant and read per tenant
+	require.NoError(t, leaderboards.RebuildLeaderboard(ctx, model.LeaderboardAllTime, nil, 10))
+	board, err := leaderboards.GetLeaderboard(acmeCtx, model.LeaderboardAllTime, 10)
+	require.NoError(t, err)
+	require.Len(t, board.TopBuyers, 1)
+	assert.Equal(t, bob.ID, board.TopBuyers[0].UserID)
+	board, err = leaderboards.GetLeaderboard(defaultCtx, model.LeaderboardAllTime, 10)
+	require.NoError(t, err)
+	assert.Empty(t, board.TopBuyers)
+	assert.Empty(t, board.TopItems)
+}
+
+func TestTenantIsolation_References(t *testing.T) {
+	pool := testdb.NewWith(t, PoolConfig{}.Apply, "inventories", "favorites", "orders", "users", "items")
+	shop := NewShopRepository(pool)
+	inventories := NewInventoryRepository(pool)
+	tenants := NewTenantRepository(pool)
+	ctx := context.Background()
+
+	acme, err := tenants.GetTenantBySlug(ctx, "acme-test")
+	if err != nil {
+		acme = &model.Tenant{Slug: "acme-test", Name: "Acme"}
+		require.NoError(t, tenants.CreateTenant(ctx, acme))
+	}
+	defaultCtx := tenant.WithID(ctx, tenant.DefaultID)
+	acmeCtx := tenant.WithID(ctx, acme.ID)
+
+	ada := model.User{FirstName: "Ada"}
+	require.NoError(t, shop.CreateUser(defaultCtx, &ada))
+	bob := model.User{FirstName: "Bob"}
+	require.NoError(t, shop.CreateUser(acmeCtx, &bob))
+	widget := model.Item{Name: "Widget", Price: money(10), Stock: 10}
+	require.NoError(t, shop.CreateItem(acmeCtx, &widget))
+	require.NoError(t, shop.GrantInventory(acmeCtx, bob.ID, widget.ID, 2))
+
+	// Foreign keys ignore row level security, the rows referenced are checked for the tenant
+	err = shop.RunAtomic(acmeCtx, func(ctx context.Context) error {
+		_, err := inventories.LockInventories(ctx, widget.ID, bob.ID, ada.ID)
+		return err
+	})
+	assert.EqualError(t, err, "user not found", "a transfer cannot reach another tenant's user")
+	_, err = inventories.LockInventories(ctx, widget.ID, ada.ID)
+	assert.EqualError(t, err, "item not found", "outside any tenant, the user and item must share one")
+
+	favorites := NewFavoriteRepository(pool)
+	assert.EqualError(t, favorites.AddFavorite(acmeCtx, &model.Favorite{UserID: ada.ID, MarketHashName: "x"}), "user not found")
+	require.NoError(t, favorites.AddFavorite(ctx, &model.Favorite{UserID: ada.ID, MarketHashName: "x"}))
+	var favoriteTenant int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT tenant_id FROM favorites WHERE user_id = $1", ada.ID).Scan(&favoriteTenant))
+	assert.Equal(t, tenant.DefaultID, favoriteTenant)
+
+	owned, err := inventories.ListUserInventory(ctx, ada.ID)
+	require.NoError(t, err)
+	assert.Empty(t, owned)
+}
+
+// globalTables are shared by the tenants, every other table must be isolated
+var globalTables = []string{"goose_db_version", "job_runs", "leaderboard_refreshes", "tenants"}
+
+func TestTenantIsolation_EveryTable(t *testing.T) {
+	pool := testdb.New(t)
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT c.relname
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = 'public' AND c.relkind IN ('r', 'p') AND c.relname <> ALL($1)
+			AND NOT (c.relrowsecurity AND c.relforcerowsecurity
+				AND EXISTS (SELECT 1 FROM pg_policy p WHERE p.polrelid = c.oid AND p.polname = 'tenant_isolation'))
+		ORDER BY 1`, globalTables)
+	require.NoError(t, err)
+	unisolated, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	require.NoError(t, err)
+	assert.Empty(t, unisolated, "tables without the tenant_isolation policy; add tenant_id and the policy, or list them in globalTables")
+}
+
+func TestTenantSkinportCredentials(t *testing.T) {
+	pool := testdb.NewWith(t, PoolConfig{}.Apply, "tenant_skinport_credentials")
+	tenants := NewTenantRepository(pool)
+	ctx := tenant.WithID(context.Background(), tenant.DefaultID)
+
+	_, err := tenants.GetSkinportCredentials(ctx, tenant.DefaultID)
+	assert.EqualError(t, err, "skinport credentials not found")
+
+	sealed := &SealedSkinportCredentials{ClientID: []byte("id"), APIKey: []byte("key")}
+	require.NoError(t, tenants.SetSkinportCredentials(ctx, tenant.DefaultID, sealed))
+	assert.False(t, sealed.UpdatedAt.IsZero())
+	require.NoError(t, tenants.SetSkinportCredentials(ctx, tenant.DefaultID, &SealedSkinportCredentials{ClientID: []byte("id2"), APIKey: []byte("key2")}))
+	got, err := tenants.GetSkinportCredentials(ctx, tenant.DefaultID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("id2"), got.ClientID)
+	assert.Equal(t, []byte("key2"), got.APIKey)
+
+	assert.EqualError(t, tenants.SetSkinportCredentials(ctx, 999999, sealed), "tenant not found")
+	list, err := tenants.ListSkinportCredentials(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, tenant.DefaultID, list[0].TenantID)
+	assert.Equal(t, []byte("key2"), list[0].APIKey)
+
+	require.NoError(t, tenants.DeleteSkinportCredentials(ctx, tenant.DefaultID))
+	assert.EqualError(t, tenants.DeleteSkinportCredentials(ctx, tenant.DefaultID), "skinport credentials not found")
+}
+
+func TestShopRepository_ReadCache(t *testing.T) {
+	pool := testdb.New(t, "orders", "users", "items")
+	repo := NewShopRepository(pool, WithReadCache(10, time.Minute))
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Test", LastName: "User", Balance: money(100)}
+	require.NoError(t, repo.CreateUser(ctx, &user))
+	item := model.Item{Name: "Test Item", Price: money(10), Stock: 5}
+	require.NoError(t, repo.CreateItem(ctx, &item))
+
+	got, err := repo.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	require.Equal(t, 5, got.Stock)
+	list, err := repo.ListItems(ctx, model.ItemFilter{}, model.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// Changes made behind the repository's back are not seen until the entries expire
+	_, err = pool.Exec(ctx, "UPDATE items SET stock = 7 WHERE id = $1", item.ID)
+	require.NoError(t, err)
+	got, err = repo.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 5, got.Stock, "served from the cache")
+
+	// The repository's own writes drop the item and every listing
+	require.NoError(t, repo.UpdateItemStock(ctx, item.ID, 1))
+	got, err = repo.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, got.Stock)
+	list, err = repo.ListItems(ctx, model.ItemFilter{}, model.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, list[0].Stock)
+
+	// Reads inside a transaction see its writes and do not publish them before the commit
+	_, err = repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	err = repo.RunAtomic(ctx, func(ctx context.Context) error {
+		if _, err := repo.AdjustUserBalance(ctx, user.ID, money(-30), model.LedgerKindAdjustment, ""); err != nil {
+			return err
+		}
+		inTx, err := repo.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 70.0, inTx.Balance.Float())
+		return nil
+	})
+	require.NoError(t, err)
+	gotUser, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 70.0, gotUser.Balance.Float())
+}
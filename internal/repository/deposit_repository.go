@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DepositRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDepositRepository(db *pgxpool.Pool) *DepositRepository {
+	return &DepositRepository{db: db}
+}
+
+const depositColumns = `id, user_id, amount, currency, status, COALESCE(session_id, ''), COALESCE(payment_intent_id, ''),
+	created_at, credited_at`
+
+func scanDeposit(row pgx.Row) (*model.Deposit, error) {
+	var d model.Deposit
+	err := row.Scan(&d.ID, &d.UserID, &d.Amount, &d.Currency, &d.Status, &d.SessionID, &d.PaymentIntentID,
+		&d.CreatedAt, &d.CreditedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDeposit inserts a pending deposit, filling in its id, status and creation time
+func (r *DepositRepository) CreateDeposit(ctx context.Context, d *model.Deposit) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"INSERT INTO deposits (user_id, amount, currency) VALUES ($1, $2, $3) RETURNING id, status, created_at",
+		d.UserID, d.Amount, d.Currency).Scan(&d.ID, &d.Status, &d.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("failed to create deposit: %w", err)
+	}
+	return nil
+}
+
+// SetDepositSession stores the Checkout session of a deposit
+func (r *DepositRepository) SetDepositSession(ctx context.Context, depositID int, sessionID string) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx,
+		"UPDATE deposits SET session_id = $2 WHERE id = $1", depositID, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to set deposit session: %w", err)
+	}
+	return nil
+}
+
+// GetDepositForUpdate locks a deposit, so a webhook and the reconciliation job cannot
+// credit it twice
+func (r *DepositRepository) GetDepositForUpdate(ctx context.Context, depositID int) (*model.Deposit, error) {
+	d, err := scanDeposit(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+depositColumns+" FROM deposits WHERE id = $1 FOR UPDATE", depositID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("deposit not found")
+		}
+		return nil, fmt.Errorf("failed to get deposit: %w", err)
+	}
+	return d, nil
+}
+
+// MarkDepositSucceeded records the payment of a deposit, once its amount is credited
+func (r *DepositRepository) MarkDepositSucceeded(ctx context.Context, depositID int, paymentIntentID string) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		UPDATE deposits SET status = 'succeeded', payment_intent_id = NULLIF($2, ''), credited_at = NOW()
+		WHERE id = $1`, depositID, paymentIntentID)
+	if err != nil {
+		return fmt.Errorf("failed to mark deposit succeeded: %w", err)
+	}
+	return nil
+}
+
+// FlagDepositForReview marks a deposit whose payment does not match it, keeping the
+// payment intent for whoever reviews it
+func (r *DepositRepository) FlagDepositForReview(ctx context.Context, depositID int, paymentIntentID string) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		UPDATE deposits SET status = 'needs_review', payment_intent_id = NULLIF($2, '')
+		WHERE id = $1`, depositID, paymentIntentID)
+	if err != nil {
+		return fmt.Errorf("failed to flag deposit for review: %w", err)
+	}
+	return nil
+}
+
+// CloseDeposit moves a pending deposit to status (expired or failed), reporting whether
+// it was still pending
+func (r *DepositRepository) CloseDeposit(ctx context.Context, depositID int, status string) (bool, error) {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx,
+		"UPDATE deposits SET status = $2 WHERE id = $1 AND status = 'pending'", depositID, status)
+	if err != nil {
+		return false, fmt.Errorf("failed to close deposit: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListPendingDeposits returns up to limit pending deposits with a Checkout session,
+// created more than minAge ago, oldest first
+func (r *DepositRepository) ListPendingDeposits(ctx context.Context, minAge time.Duration, limit int) ([]model.Deposit, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT `+depositColumns+`
+		FROM deposits
+		WHERE status = 'pending' AND session_id IS NOT NULL AND created_at < NOW() - $1::interval
+		ORDER BY id
+		LIMIT $2`, minAge, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending deposits: %w", err)
+	}
+	defer rows.Close()
+
+	deposits := []model.Deposit{}
+	for rows.Next() {
+		d, err := scanDeposit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deposit: %w", err)
+		}
+		deposits = append(deposits, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pending deposits: %w", err)
+	}
+	return deposits, nil
+}
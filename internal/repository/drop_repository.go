@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DropRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDropRepository(db *pgxpool.Pool) *DropRepository {
+	return &DropRepository{db: db}
+}
+
+// dropSoldSQL sums the quantity of the drop's orders, cancelled and refunded orders
+// return to the pool
+const dropSoldSQL = `
+	SELECT COALESCE(SUM(o.quantity), 0) FROM orders o
+	WHERE o.drop_id = d.id AND o.status NOT IN ('cancelled', 'refunded')`
+
+const dropColumns = "d.id, d.item_id, d.name, d.starts_at, d.ends_at, d.stock, d.per_user_limit, (" + dropSoldSQL + "), d.created_at"
+
+// dropOpenSQL matches the drops that have not ended
+const dropOpenSQL = "(d.ends_at IS NULL OR d.ends_at > NOW())"
+
+func scanDrop(row pgx.Row) (*model.Drop, error) {
+	var d model.Drop
+	err := row.Scan(&d.ID, &d.ItemID, &d.Name, &d.StartsAt, &d.EndsAt, &d.Stock, &d.PerUserLimit, &d.Sold, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDrop inserts a drop and sets its id
+func (r *DropRepository) CreateDrop(ctx context.Context, d *model.Drop) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO drops (item_id, name, starts_at, ends_at, stock, per_user_limit)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		d.ItemID, d.Name, d.StartsAt, d.EndsAt, d.Stock, d.PerUserLimit).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("item not found")
+		}
+		return fmt.Errorf("failed to create drop: %w", err)
+	}
+	return nil
+}
+
+// GetDrop returns the drop with its sold quantity
+func (r *DropRepository) GetDrop(ctx context.Context, id int) (*model.Drop, error) {
+	d, err := scanDrop(executorFromContext(ctx, r.db).QueryRow(ctx, "SELECT "+dropColumns+" FROM drops d WHERE d.id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("drop not found")
+		}
+		return nil, fmt.Errorf("failed to get drop: %w", err)
+	}
+	return d, nil
+}
+
+// ListOpenDrops returns the drops that have not ended, by start time
+func (r *DropRepository) ListOpenDrops(ctx context.Context) ([]model.Drop, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx,
+		"SELECT "+dropColumns+" FROM drops d WHERE "+dropOpenSQL+" ORDER BY d.starts_at, d.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drops: %w", err)
+	}
+	defer rows.Close()
+
+	drops := []model.Drop{}
+	for rows.Next() {
+		d, err := scanDrop(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan drop: %w", err)
+		}
+		drops = append(drops, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list drops: %w", err)
+	}
+	return drops, nil
+}
+
+// GetOpenDrop returns the item's drop that has not ended, nil if there is none
+func (r *DropRepository) GetOpenDrop(ctx context.Context, itemID int) (*model.Drop, error) {
+	d, err := scanDrop(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+dropColumns+" FROM drops d WHERE d.item_id = $1 AND "+dropOpenSQL+" ORDER BY d.starts_at LIMIT 1", itemID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get drop: %w", err)
+	}
+	return d, nil
+}
+
+// CountUserDropQuantity returns the quantity the user bought in the drop, not counting
+// cancelled and refunded orders
+func (r *DropRepository) CountUserDropQuantity(ctx context.Context, dropID, userID int) (int, error) {
+	var quantity int
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM orders
+		WHERE drop_id = $1 AND user_id = $2 AND status NOT IN ('cancelled', 'refunded')`, dropID, userID).Scan(&quantity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count drop purchases: %w", err)
+	}
+	return quantity, nil
+}
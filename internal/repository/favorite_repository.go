@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type FavoriteRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFavoriteRepository(db *pgxpool.Pool) *FavoriteRepository {
+	return &FavoriteRepository{db: db}
+}
+
+// AddFavorite stores the favorite and fills in created_at. If the user already follows
+// the item, its created_at is kept and its alert replaced (and re-armed).
+func (r *FavoriteRepository) AddFavorite(ctx context.Context, f *model.Favorite) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO favorites (user_id, market_hash_name, alert_below) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, market_hash_name) DO UPDATE SET alert_below = EXCLUDED.alert_below, alerted_at = NULL
+		RETURNING created_at`, f.UserID, f.MarketHashName, f.AlertBelow).Scan(&f.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("failed to add favorite: %w", err)
+	}
+	return nil
+}
+
+// RemoveFavorite deletes the favorite
+func (r *FavoriteRepository) RemoveFavorite(ctx context.Context, userID int, marketHashName string) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx,
+		"DELETE FROM favorites WHERE user_id = $1 AND market_hash_name = $2", userID, marketHashName)
+	if err != nil {
+		return fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("favorite not found")
+	}
+	return nil
+}
+
+// ListFavorites returns the user's favorites, most recent first
+func (r *FavoriteRepository) ListFavorites(ctx context.Context, userID int) ([]model.Favorite, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT user_id, market_hash_name, alert_below, created_at FROM favorites
+		WHERE user_id = $1
+		ORDER BY created_at DESC, market_hash_name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	defer rows.Close()
+
+	favorites := []model.Favorite{}
+	for rows.Next() {
+		var f model.Favorite
+		if err := rows.Scan(&f.UserID, &f.MarketHashName, &f.AlertBelow, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite: %w", err)
+		}
+		favorites = append(favorites, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	return favorites, nil
+}
+
+// ListDueAlerts returns the alerts of users with an email or a linked Telegram chat that
+// did not fire within the cooldown
+func (r *FavoriteRepository) ListDueAlerts(ctx context.Context, cooldown time.Duration) ([]model.DuePriceAlert, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT f.user_id, COALESCE(u.email, ''), COALESCE(t.chat_id, 0), u.first_name, f.market_hash_name, f.alert_below
+		FROM favorites f
+		JOIN users u ON u.id = f.user_id
+		LEFT JOIN telegram_links t ON t.user_id = f.user_id
+		WHERE f.alert_below IS NOT NULL AND (u.email IS NOT NULL OR t.chat_id IS NOT NULL)
+			AND (f.alerted_at IS NULL OR f.alerted_at < NOW() - $1::interval)
+		ORDER BY f.user_id, f.market_hash_name`, cooldown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []model.DuePriceAlert{}
+	for rows.Next() {
+		var a model.DuePriceAlert
+		if err := rows.Scan(&a.UserID, &a.Email, &a.TelegramChatID, &a.FirstName, &a.MarketHashName, &a.AlertBelow); err != nil {
+			return nil, fmt.Errorf("failed to scan price alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list price alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// MarkAlerted records that the favorite's alert fired
+func (r *FavoriteRepository) MarkAlerted(ctx context.Context, userID int, marketHashName string) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx,
+		"UPDATE favorites SET alerted_at = NOW() WHERE user_id = $1 AND market_hash_name = $2", userID, marketHashName)
+	if err != nil {
+		return fmt.Errorf("failed to mark price alert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// pinger is the part of *pgxpool.Pool the health checks use
+type pinger interface {
+	Ping(ctx context.Context) error
+	Reset()
+}
+
+// maxConnectBackoff caps the wait between startup connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// WaitForDatabase pings pool until it answers, making up to attempts attempts and
+// doubling the wait between them from backoff, e.g. while Postgres is still starting
+// next to us in docker-compose. It returns the last error once attempts run out.
+func WaitForDatabase(ctx context.Context, pool pinger, attempts int, backoff time.Duration) error {
+	attempts = max(attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = pool.Ping(ctx); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+		}
+		slog.WarnContext(ctx, "database not reachable, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// DatabaseHealth tracks whether the database answers, for readiness probes. pgx
+// replaces broken connections by itself; after an outage the health check also drops
+// the idle ones opened before it, so requests do not fail on them one by one.
+type DatabaseHealth struct {
+	pool pinger
+
+	mu      sync.RWMutex
+	checked bool
+	err     error
+}
+
+// NewDatabaseHealth returns the health of pool, unhealthy until the first Check
+func NewDatabaseHealth(pool pinger) *DatabaseHealth {
+	return &DatabaseHealth{pool: pool, err: fmt.Errorf("database not checked yet")}
+}
+
+// Check pings the database and records the outcome; run it periodically
+func (h *DatabaseHealth) Check(ctx context.Context) error {
+	err := h.pool.Ping(ctx)
+
+	h.mu.Lock()
+	wasDown := h.checked && h.err != nil
+	h.checked, h.err = true, err
+	h.mu.Unlock()
+
+	switch {
+	case err != nil && !wasDown:
+		slog.ErrorContext(ctx, "database connection lost", "error", err)
+	case err == nil && wasDown:
+		h.pool.Reset()
+		slog.InfoContext(ctx, "database connection restored")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// Ready returns the error of the last Check, nil while the database answers
+func (h *DatabaseHealth) Ready() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.err
+}
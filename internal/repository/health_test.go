@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePinger refuses its first pings, as many as failures
+type fakePinger struct {
+	failures int
+	pings    int
+	resets   int
+}
+
+func (p *fakePinger) Ping(context.Context) error {
+	p.pings++
+	if p.pings <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (p *fakePinger) Reset() {
+	p.resets++
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	t.Run("retries until the database answers", func(t *testing.T) {
+		pool := &fakePinger{failures: 2}
+		require.NoError(t, WaitForDatabase(context.Background(), pool, 5, time.Millisecond))
+		assert.Equal(t, 3, pool.pings)
+	})
+
+	t.Run("gives up after the attempts", func(t *testing.T) {
+		pool := &fakePinger{failures: 10}
+		err := WaitForDatabase(context.Background(), pool, 3, time.Millisecond)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connection refused")
+		assert.Equal(t, 3, pool.pings)
+	})
+
+	t.Run("stops when the context ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		pool := &fakePinger{failures: 10}
+		assert.ErrorIs(t, WaitForDatabase(ctx, pool, 5, time.Hour), context.Canceled)
+		assert.Equal(t, 1, pool.pings)
+	})
+}
+
+func TestDatabaseHealth(t *testing.T) {
+	pool := &fakePinger{failures: 2}
+	health := NewDatabaseHealth(pool)
+	assert.Error(t, health.Ready(), "not ready before the first check")
+
+	assert.Error(t, health.Check(context.Background()))
+	assert.Error(t, health.Check(context.Background()))
+	assert.Error(t, health.Ready())
+	assert.Zero(t, pool.resets)
+
+	require.NoError(t, health.Check(context.Background()))
+	assert.NoError(t, health.Ready())
+	assert.Equal(t, 1, pool.resets, "connections from before the outage are dropped")
+
+	require.NoError(t, health.Check(context.Background()))
+	assert.Equal(t, 1, pool.resets)
+
+	healthy := NewDatabaseHealth(&fakePinger{})
+	require.NoError(t, healthy.Check(context.Background()))
+	assert.NoError(t, healthy.Ready())
+}
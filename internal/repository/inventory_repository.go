@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type InventoryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewInventoryRepository(db *pgxpool.Pool) *InventoryRepository {
+	return &InventoryRepository{db: db}
+}
+
+// LockInventories locks the inventory rows of itemID for every user, in ascending user id
+// order so concurrent transfers between the same users cannot deadlock, and returns the
+// quantity each user owns. Missing rows are created empty first, in the same order; users
+// and items of another tenant are reported as not found.
+func (r *InventoryRepository) LockInventories(ctx context.Context, itemID int, userIDs ...int) (map[int]int, error) {
+	ids := slices.Clone(userIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	exec := executorFromContext(ctx, r.db)
+	_, err := exec.Exec(ctx, `
+		INSERT INTO inventories (user_id, item_id)
+		SELECT id, $1 FROM unnest($2::int[]) WITH ORDINALITY AS u(id, n) ORDER BY n
+		ON CONFLICT (user_id, item_id) DO NOTHING`, itemID, ids)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "inventories_item_id_fkey" {
+				return nil, errors.New("item not found")
+			}
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to create inventories: %w", err)
+	}
+
+	rows, err := exec.Query(ctx, `
+		SELECT user_id, quantity FROM inventories
+		WHERE item_id = $1 AND user_id = ANY($2)
+		ORDER BY user_id
+		FOR UPDATE`, itemID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock inventories: %w", err)
+	}
+	defer rows.Close()
+
+	quantities := make(map[int]int, len(ids))
+	for rows.Next() {
+		var userID, quantity int
+		if err := rows.Scan(&userID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan inventory: %w", err)
+		}
+		quantities[userID] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to lock inventories: %w", err)
+	}
+	return quantities, nil
+}
+
+// AddInventory adds delta (which may be negative) to the quantity of the item the user
+// owns and returns the new quantity. The row must exist, see LockInventories.
+func (r *InventoryRepository) AddInventory(ctx context.Context, userID, itemID, delta int) (int, error) {
+	var quantity int
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"UPDATE inventories SET quantity = quantity + $3, updated_at = NOW() WHERE user_id = $1 AND item_id = $2 RETURNING quantity",
+		userID, itemID, delta).Scan(&quantity)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("inventory not found")
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+			return 0, errors.New("insufficient quantity")
+		}
+		return 0, fmt.Errorf("failed to update inventory: %w", err)
+	}
+	return quantity, nil
+}
+
+// ListUserInventory returns the items the user owns, by item id
+func (r *InventoryRepository) ListUserInventory(ctx context.Context, userID int) ([]model.InventoryItem, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT user_id, item_id, quantity, updated_at FROM inventories
+		WHERE user_id = $1 AND quantity > 0
+		ORDER BY item_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.InventoryItem{}
+	for rows.Next() {
+		var item model.InventoryItem
+		if err := rows.Scan(&item.UserID, &item.ItemID, &item.Quantity, &item.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan inventory: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list inventory: %w", err)
+	}
+	return items, nil
+}
+
+// ListPortfolio returns the items the user owns with their Skinport mapping and the
+// latest price snapshots taken at least 24 hours and 7 days ago, by item id
+func (r *InventoryRepository) ListPortfolio(ctx context.Context, userID int) ([]model.PortfolioHolding, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT i.id, i.name, inv.quantity, i.price, m.market_hash_name, d.price, w.price
+		FROM inventories inv
+		JOIN items i ON i.id = inv.item_id
+		LEFT JOIN skinport_price_mappings m ON m.item_id = i.id
+		LEFT JOIN LATERAL (
+			SELECT price FROM item_price_snapshots
+			WHERE item_id = i.id AND taken_at <= NOW() - INTERVAL '24 hours'
+			ORDER BY taken_at DESC LIMIT 1
+		) d ON true
+		LEFT JOIN LATERAL (
+			SELECT price FROM item_price_snapshots
+			WHERE item_id = i.id AND taken_at <= NOW() - INTERVAL '7 days'
+			ORDER BY taken_at DESC LIMIT 1
+		) w ON true
+		WHERE inv.user_id = $1 AND inv.quantity > 0
+		ORDER BY i.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list portfolio: %w", err)
+	}
+	defer rows.Close()
+
+	holdings := []model.PortfolioHolding{}
+	for rows.Next() {
+		var h model.PortfolioHolding
+		if err := rows.Scan(&h.ItemID, &h.Name, &h.Quantity, &h.Price, &h.MarketHashName, &h.Price24h, &h.Price7d); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio: %w", err)
+		}
+		holdings = append(holdings, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list portfolio: %w", err)
+	}
+	return holdings, nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobRepository persists background job runs (implements jobs.Store)
+type JobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewJobRepository(db *pgxpool.Pool) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func (r *JobRepository) LastStarted(ctx context.Context, name string) (time.Time, error) {
+	var startedAt time.Time
+	err := r.db.QueryRow(ctx, "SELECT last_started_at FROM job_runs WHERE name = $1", name).Scan(&startedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get job run: %w", err)
+	}
+	return startedAt, nil
+}
+
+// Claim atomically records a new run unless another instance started one after since
+func (r *JobRepository) Claim(ctx context.Context, name string, since time.Time) (bool, error) {
+	var claimed string
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO job_runs (name, last_started_at) VALUES ($1, NOW())
+		ON CONFLICT (name) DO UPDATE SET last_started_at = NOW(), last_finished_at = NULL, last_error = NULL
+		WHERE job_runs.last_started_at < $2
+		RETURNING name`, name, since).Scan(&claimed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim job run: %w", err)
+	}
+	return true, nil
+}
+
+func (r *JobRepository) Finish(ctx context.Context, name string, runErr error) error {
+	var lastError *string
+	if runErr != nil {
+		msg := runErr.Error()
+		lastError = &msg
+	}
+	_, err := r.db.Exec(ctx, "UPDATE job_runs SET last_finished_at = NOW(), last_error = $2 WHERE name = $1", name, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to finish job run: %w", err)
+	}
+	return nil
+}
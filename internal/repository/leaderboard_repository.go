@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LeaderboardRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLeaderboardRepository(db *pgxpool.Pool) *LeaderboardRepository {
+	return &LeaderboardRepository{db: db}
+}
+
+// RebuildLeaderboard replaces the period's leaderboards with the size top buyers and items
+// of the orders created since (nil for all time), ranked per tenant. Refunded and
+// cancelled orders do not count, deleted users are left out. Run unscoped, it rebuilds
+// every tenant's leaderboards. Readers see the old leaderboards until the rebuild
+// commits; like RefreshSalesStats it is exempt from statement_timeout.
+func (r *LeaderboardRepository) RebuildLeaderboard(ctx context.Context, period string, since *time.Time, size int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to disable statement timeout: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue("DELETE FROM leaderboard_buyers WHERE period = $1", period)
+	batch.Queue("DELETE FROM leaderboard_items WHERE period = $1", period)
+	batch.Queue(`
+		INSERT INTO leaderboard_buyers (tenant_id, period, rank, user_id, order_count, items_bought, spent)
+		SELECT tenant_id, $1, rank, user_id, order_count, items_bought, spent
+		FROM (
+			SELECT u.tenant_id, ROW_NUMBER() OVER (PARTITION BY u.tenant_id ORDER BY SUM(o.price) DESC, o.user_id) AS rank,
+				o.user_id, COUNT(*) AS order_count, SUM(o.quantity) AS items_bought, SUM(o.price) AS spent
+			FROM orders o
+			JOIN users u ON u.id = o.user_id
+			WHERE ($2::timestamp IS NULL OR o.created_at >= $2) AND u.status <> 'deleted' AND `+countedOrder("o.status")+`
+			GROUP BY u.tenant_id, o.user_id
+		) ranked
+		WHERE rank <= $3`, period, since, size)
+	batch.Queue(`
+		INSERT INTO leaderboard_items (tenant_id, period, rank, item_id, order_count, quantity, revenue)
+		SELECT tenant_id, $1, rank, item_id, order_count, quantity, revenue
+		FROM (
+			SELECT o.tenant_id, ROW_NUMBER() OVER (PARTITION BY o.tenant_id ORDER BY SUM(o.quantity) DESC, SUM(o.price) DESC, o.item_id) AS rank,
+				o.item_id, COUNT(*) AS order_count, SUM(o.quantity) AS quantity, SUM(o.price) AS revenue
+			FROM orders o
+			WHERE o.item_id IS NOT NULL AND ($2::timestamp IS NULL OR o.created_at >= $2) AND `+countedOrder("o.status")+`
+			GROUP BY o.tenant_id, o.item_id
+		) ranked
+		WHERE rank <= $3`, period, since, size)
+	batch.Queue(`
+		INSERT INTO leaderboard_refreshes (period, since, refreshed_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (period) DO UPDATE SET since = EXCLUDED.since, refreshed_at = EXCLUDED.refreshed_at`, period, since)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to rebuild %s leaderboard: %w", period, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLeaderboard returns the first limit entries of the period's leaderboards
+func (r *LeaderboardRepository) GetLeaderboard(ctx context.Context, period string, limit int) (*model.Leaderboard, error) {
+	board := &model.Leaderboard{Period: period, TopBuyers: []model.LeaderboardUser{}, TopItems: []model.LeaderboardItem{}}
+	err := r.db.QueryRow(ctx, "SELECT since, refreshed_at FROM leaderboard_refreshes WHERE period = $1", period).
+		Scan(&board.Since, &board.RefreshedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get leaderboard refresh: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT b.rank, b.user_id, u.first_name || COALESCE(' ' || NULLIF(LEFT(u.last_name, 1), '') || '.', ''),
+			b.order_count, b.items_bought, b.spent
+		FROM leaderboard_buyers b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.period = $1 AND b.rank <= $2
+		ORDER BY b.rank`, period, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top buyers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u model.LeaderboardUser
+		if err := rows.Scan(&u.Rank, &u.UserID, &u.Name, &u.OrderCount, &u.ItemsBought, &u.Spent); err != nil {
+			return nil, fmt.Errorf("failed to scan top buyer: %w", err)
+		}
+		board.TopBuyers = append(board.TopBuyers, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query top buyers: %w", err)
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT l.rank, l.item_id, i.name, l.order_count, l.quantity, l.revenue
+		FROM leaderboard_items l
+		JOIN items i ON i.id = l.item_id
+		WHERE l.period = $1 AND l.rank <= $2
+		ORDER BY l.rank`, period, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top items: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.LeaderboardItem
+		if err := rows.Scan(&item.Rank, &item.ItemID, &item.Name, &item.OrderCount, &item.Quantity, &item.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan top item: %w", err)
+		}
+		board.TopItems = append(board.TopItems, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query top items: %w", err)
+	}
+	return board, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ledgerAccounts maps a transaction kind to the system account on the other side of
+// the user's entry
+var ledgerAccounts = map[string]string{
+	model.LedgerKindOpening:          "opening",
+	model.LedgerKindPurchase:         "sales",
+	model.LedgerKindRefund:           "sales",
+	model.LedgerKindDeposit:          "deposits",
+	model.LedgerKindWithdrawal:       "payouts",
+	model.LedgerKindWithdrawalRefund: "payouts",
+	model.LedgerKindAdjustment:       "adjustments",
+}
+
+// ledgerPurchaseSQL posts the order inserted by a "created" CTE returning its id,
+// user_id, tenant_id and price: the buyer is debited and the sales account credited. The
+// entries update users.balance when the statement ends.
+const ledgerPurchaseSQL = `
+	purchase_tx AS (
+		INSERT INTO ledger_transactions (kind, reference, tenant_id)
+		SELECT 'purchase', 'order:' || id, tenant_id FROM created
+		RETURNING id
+	), purchase_entries AS (
+		INSERT INTO ledger_entries (transaction_id, account_id, amount)
+		SELECT t.id, a.id, -o.price
+		FROM purchase_tx t CROSS JOIN created o JOIN ledger_accounts a ON a.user_id = o.user_id
+		UNION ALL
+		SELECT t.id, ledger_account('sales'), o.price
+		FROM purchase_tx t CROSS JOIN created o
+	)`
+
+type LedgerRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLedgerRepository(db *pgxpool.Pool) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// CheckLedger returns the transactions whose entries do not sum to zero and the users
+// whose balance differs from the sum of their account's entries
+func (r *LedgerRepository) CheckLedger(ctx context.Context) ([]model.LedgerViolation, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT 'unbalanced_transaction', transaction_id, 0::float8, SUM(amount)::float8
+		FROM ledger_entries
+		GROUP BY transaction_id
+		HAVING SUM(amount) <> 0
+		UNION ALL
+		SELECT 'balance_mismatch', u.id, COALESCE(SUM(e.amount), 0)::float8, u.balance::float8
+		FROM users u
+		LEFT JOIN ledger_accounts a ON a.user_id = u.id
+		LEFT JOIN ledger_entries e ON e.account_id = a.id
+		GROUP BY u.id
+		HAVING u.balance <> COALESCE(SUM(e.amount), 0)
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ledger: %w", err)
+	}
+	defer rows.Close()
+
+	violations := []model.LedgerViolation{}
+	for rows.Next() {
+		var v model.LedgerViolation
+		if err := rows.Scan(&v.Type, &v.ID, &v.Expected, &v.Actual); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger violation: %w", err)
+		}
+		violations = append(violations, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check ledger: %w", err)
+	}
+	return violations, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+)
+
+// sortColumn maps an API sort field to its SQL expression and type
+type sortColumn struct {
+	expr    string
+	sqlType string
+}
+
+// keysetClause builds the keyset pagination predicate and ORDER BY for opts.
+// The tiebreaker column is appended to the sort order so rows are totally ordered.
+// Placeholders start at $firstArg; the predicate is empty on the first page.
+func keysetClause(opts model.ListOptions, cols map[string]sortColumn, tiebreaker string, firstArg int) (string, string, []any, error) {
+	fields := append([]model.SortField(nil), opts.Sort...)
+	hasTiebreaker := false
+	for _, f := range fields {
+		if _, ok := cols[f.Field]; !ok {
+			return "", "", nil, fmt.Errorf("unsupported sort field %q", f.Field)
+		}
+		hasTiebreaker = hasTiebreaker || f.Field == tiebreaker
+	}
+	if !hasTiebreaker {
+		fields = append(fields, model.SortField{Field: tiebreaker})
+	}
+
+	order := make([]string, len(fields))
+	for i, f := range fields {
+		order[i] = cols[f.Field].expr
+		if f.Desc {
+			order[i] += " DESC"
+		}
+	}
+	orderBy := " ORDER BY " + strings.Join(order, ", ")
+
+	if len(opts.After) == 0 {
+		return "", orderBy, nil, nil
+	}
+	if len(opts.After) != len(fields) {
+		return "", "", nil, errors.New("invalid cursor")
+	}
+
+	// (a > x) OR (a = x AND b > y) OR (a = x AND b = y AND id > z), flipping < for DESC fields
+	var args []any
+	placeholder := func(i int) string {
+		args = append(args, opts.After[i])
+		return fmt.Sprintf("$%d::text::%s", firstArg+len(args)-1, cols[fields[i].Field].sqlType)
+	}
+
+	var disjuncts []string
+	for i, f := range fields {
+		var conj []string
+		for j := 0; j < i; j++ {
+			conj = append(conj, fmt.Sprintf("%s = %s", cols[fields[j].Field].expr, placeholder(j)))
+		}
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+		conj = append(conj, fmt.Sprintf("%s %s %s", cols[f.Field].expr, op, placeholder(i)))
+		disjuncts = append(disjuncts, "("+strings.Join(conj, " AND ")+")")
+	}
+
+	return "(" + strings.Join(disjuncts, " OR ") + ")", orderBy, args, nil
+}
+
+// limitClause fetches one row more than the page size so callers can tell whether a next page exists
+func limitClause(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", limit+1)
+}
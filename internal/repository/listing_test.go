@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSortColumns = map[string]sortColumn{
+	"id":    {"i.id", "int"},
+	"price": {"i.price", "numeric"},
+}
+
+func TestKeysetClause_FirstPage(t *testing.T) {
+	where, orderBy, args, err := keysetClause(model.ListOptions{
+		Sort: []model.SortField{{Field: "price", Desc: true}},
+	}, testSortColumns, "id", 1)
+
+	assert.NoError(t, err)
+	assert.Empty(t, where)
+	assert.Equal(t, " ORDER BY i.price DESC, i.id", orderBy)
+	assert.Empty(t, args)
+}
+
+func TestKeysetClause_NextPage(t *testing.T) {
+	where, _, args, err := keysetClause(model.ListOptions{
+		Sort:  []model.SortField{{Field: "price", Desc: true}},
+		After: []string{"10.5", "7"},
+	}, testSortColumns, "id", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "((i.price < $3::text::numeric) OR (i.price = $4::text::numeric AND i.id > $5::text::int))", where)
+	assert.Equal(t, []any{"10.5", "10.5", "7"}, args)
+}
+
+func TestKeysetClause_Invalid(t *testing.T) {
+	_, _, _, err := keysetClause(model.ListOptions{After: []string{"1", "2"}}, testSortColumns, "id", 1)
+	assert.Error(t, err)
+
+	_, _, _, err = keysetClause(model.ListOptions{Sort: []model.SortField{{Field: "name"}}}, testSortColumns, "id", 1)
+	assert.Error(t, err)
+}
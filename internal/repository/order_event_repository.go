@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OrderEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOrderEventRepository(db *pgxpool.Pool) *OrderEventRepository {
+	return &OrderEventRepository{db: db}
+}
+
+// Append writes an event to the outbox. When called inside RunAtomic it commits
+// or rolls back together with the order change it describes.
+func (r *OrderEventRepository) Append(ctx context.Context, event model.OrderEvent) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		INSERT INTO order_events (order_id, user_id, type, payload)
+		VALUES ($1, $2, $3, $4)`,
+		event.OrderID, event.UserID, event.Type, []byte(event.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to append order event: %w", err)
+	}
+	return nil
+}
+
+// ListAfter returns up to limit events with an id greater than afterID, oldest first.
+// userID > 0 restricts the result to one user's events.
+func (r *OrderEventRepository) ListAfter(ctx context.Context, afterID int64, userID, limit int) ([]model.OrderEvent, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT id, order_id, user_id, type, payload, created_at
+		FROM order_events
+		WHERE id > $1 AND ($2 = 0 OR user_id = $2)
+		ORDER BY id
+		LIMIT $3`, afterID, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []model.OrderEvent{}
+	for rows.Next() {
+		var e model.OrderEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.UserID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list order events: %w", err)
+	}
+	return events, nil
+}
+
+// LatestID returns the id of the newest event, 0 when there are none
+func (r *OrderEventRepository) LatestID(ctx context.Context) (int64, error) {
+	var id int64
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM order_events`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest order event: %w", err)
+	}
+	return id, nil
+}
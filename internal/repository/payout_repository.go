@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PayoutRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPayoutRepository(db *pgxpool.Pool) *PayoutRepository {
+	return &PayoutRepository{db: db}
+}
+
+const payoutColumns = `id, user_id, amount, destination, status, COALESCE(provider, ''), COALESCE(reference, ''),
+	attempts, COALESCE(last_error, ''), created_at, processed_at`
+
+func scanPayout(row pgx.Row) (*model.Payout, error) {
+	var p model.Payout
+	err := row.Scan(&p.ID, &p.UserID, &p.Amount, &p.Destination, &p.Status, &p.Provider, &p.Reference,
+		&p.Attempts, &p.LastError, &p.CreatedAt, &p.ProcessedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePayout inserts a pending payout, filling in its id, status and creation time.
+// The balance must be debited in the same transaction.
+func (r *PayoutRepository) CreatePayout(ctx context.Context, p *model.Payout) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"INSERT INTO payouts (user_id, amount, destination) VALUES ($1, $2, $3) RETURNING id, status, created_at",
+		p.UserID, p.Amount, p.Destination).Scan(&p.ID, &p.Status, &p.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("failed to create payout: %w", err)
+	}
+	return nil
+}
+
+// GetPayout returns a payout by id
+func (r *PayoutRepository) GetPayout(ctx context.Context, payoutID int) (*model.Payout, error) {
+	p, err := scanPayout(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+payoutColumns+" FROM payouts WHERE id = $1", payoutID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("payout not found")
+		}
+		return nil, fmt.Errorf("failed to get payout: %w", err)
+	}
+	return p, nil
+}
+
+// ErrPayoutNotSending is returned when recording the outcome of a payout that is not
+// being sent anymore, because another instance already recorded it
+var ErrPayoutNotSending = errors.New("payout is not being sent")
+
+// ClaimPayouts marks up to limit payouts as sending, oldest first, and returns them. It
+// claims pending payouts and payouts left sending for longer than staleAfter by an
+// instance that stopped before recording the outcome; rows locked by another instance
+// are skipped. Commit the claim before sending the payouts.
+func (r *PayoutRepository) ClaimPayouts(ctx context.Context, limit int, staleAfter time.Duration) ([]model.Payout, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		UPDATE payouts
+		SET status = 'sending', sending_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM payouts
+			WHERE status = 'pending' OR (status = 'sending' AND sending_at < NOW() - $2::interval)
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+payoutColumns, limit, staleAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := []model.Payout{}
+	for rows.Next() {
+		p, err := scanPayout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payout: %w", err)
+		}
+		payouts = append(payouts, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim payouts: %w", err)
+	}
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(payouts, func(a, b model.Payout) int { return cmp.Compare(a.ID, b.ID) })
+	return payouts, nil
+}
+
+// recordOutcome runs an update of a payout being sent
+func (r *PayoutRepository) recordOutcome(ctx context.Context, op, query string, args ...any) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPayoutNotSending
+	}
+	return nil
+}
+
+// CompletePayout records that the provider accepted the payout
+func (r *PayoutRepository) CompletePayout(ctx context.Context, payoutID int, provider, reference string) error {
+	return r.recordOutcome(ctx, "complete payout", `
+		UPDATE payouts
+		SET status = 'completed', provider = $2, reference = $3, attempts = attempts + 1, last_error = NULL,
+			sending_at = NULL, processed_at = NOW()
+		WHERE id = $1 AND status = 'sending'`, payoutID, provider, reference)
+}
+
+// FailPayout records that the provider rejected the payout for good
+func (r *PayoutRepository) FailPayout(ctx context.Context, payoutID int, provider, reason string) error {
+	return r.recordOutcome(ctx, "fail payout", `
+		UPDATE payouts
+		SET status = 'failed', provider = $2, attempts = attempts + 1, last_error = $3,
+			sending_at = NULL, processed_at = NOW()
+		WHERE id = $1 AND status = 'sending'`, payoutID, provider, reason)
+}
+
+// RecordPayoutAttempt records a failed attempt; the payout is pending again and retried
+func (r *PayoutRepository) RecordPayoutAttempt(ctx context.Context, payoutID int, provider, lastError string) error {
+	return r.recordOutcome(ctx, "record payout attempt", `
+		UPDATE payouts
+		SET status = 'pending', provider = $2, attempts = attempts + 1, last_error = $3, sending_at = NULL
+		WHERE id = $1 AND status = 'sending'`, payoutID, provider, lastError)
+}
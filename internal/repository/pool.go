@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig tunes how pgx runs statements on a pool's connections
+type PoolConfig struct {
+	// QueryExecMode is pgx's default_query_exec_mode: cache_statement (pgx's default,
+	// prepares and caches every statement), cache_describe, describe_exec, exec or
+	// simple_protocol. The last two suit poolers such as PgBouncer in transaction mode.
+	QueryExecMode string
+	// StatementCacheCapacity bounds the statements cached per connection by cache_statement
+	// (0 keeps pgx's 512)
+	StatementCacheCapacity int
+	// DescriptionCacheCapacity bounds the descriptions cached per connection by
+	// cache_describe (0 keeps pgx's 512)
+	DescriptionCacheCapacity int
+	// PreparePurchases prepares the purchase statements on every new connection, see
+	// PreparePurchaseStatements
+	PreparePurchases bool
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// Apply sets the exec mode and cache sizes on config, chains the statement
+// preparation after its AfterConnect and scopes acquired connections to the tenant
+// of the acquiring context (see ScopeConnToTenant)
+func (c PoolConfig) Apply(config *pgxpool.Config) error {
+	config.PrepareConn = ScopeConnToTenant
+
+	if c.QueryExecMode != "" {
+		mode, ok := queryExecModes[c.QueryExecMode]
+		if !ok {
+			return fmt.Errorf("unknown query exec mode %q (want cache_statement, cache_describe, describe_exec, exec or simple_protocol)", c.QueryExecMode)
+		}
+		config.ConnConfig.DefaultQueryExecMode = mode
+	}
+	if c.StatementCacheCapacity < 0 || c.DescriptionCacheCapacity < 0 {
+		return fmt.Errorf("statement and description cache capacities must not be negative")
+	}
+	if c.StatementCacheCapacity > 0 {
+		config.ConnConfig.StatementCacheCapacity = c.StatementCacheCapacity
+	}
+	if c.DescriptionCacheCapacity > 0 {
+		config.ConnConfig.DescriptionCacheCapacity = c.DescriptionCacheCapacity
+	}
+
+	if c.PreparePurchases {
+		afterConnect := config.AfterConnect
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if afterConnect != nil {
+				if err := afterConnect(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return PreparePurchaseStatements(ctx, conn)
+		}
+	}
+	return nil
+}
+
+// DatabaseCredentials hands new connections the user and password of a rotated
+// DATABASE_URL. Open connections keep theirs: Postgres does not end sessions when a
+// password changes.
+type DatabaseCredentials struct {
+	mu             sync.RWMutex
+	user, password string
+}
+
+// Rotate makes connections opened from now on use databaseURL's user and password. Hosts
+// and options are not rotated, they are read at startup only.
+func (c *DatabaseCredentials) Rotate(databaseURL string) error {
+	config, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse rotated database URL: %w", err)
+	}
+	c.mu.Lock()
+	c.user, c.password = config.User, config.Password
+	c.mu.Unlock()
+	return nil
+}
+
+// BeforeConnect is a pgxpool BeforeConnect hook applying the rotated credentials
+func (c *DatabaseCredentials) BeforeConnect(_ context.Context, config *pgx.ConnConfig) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.user != "" {
+		config.User, config.Password = c.user, c.password
+	}
+	return nil
+}
+
+// tenantRole is the role scoped connections run as. Unlike the application's own user,
+// which may be a superuser, it is subject to the tenant_isolation row level security.
+const tenantRole = "shop_tenant"
+
+// connTenantKey holds in a connection's CustomData the tenant its session is scoped to
+const connTenantKey = "tenant_id"
+
+// ScopeConnToTenant is the pool's PrepareConn hook. A connection acquired with a tenant in
+// ctx (tenant.WithID) is scoped to it for as long as it is held, including transactions
+// begun on it: its session sets app.tenant_id and switches to tenantRole. A connection
+// acquired without a tenant sees every tenant. The session is only changed when the
+// connection was last scoped differently.
+func ScopeConnToTenant(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	id, _ := tenant.IDFrom(ctx)
+	data := conn.PgConn().CustomData()
+	if scoped, _ := data[connTenantKey].(int); scoped == id {
+		return true, nil
+	}
+
+	if err := conn.PgConn().Exec(ctx, tenantScopeSQL(id)).Close(); err != nil {
+		// The session is in an unknown state, the connection is replaced
+		return false, fmt.Errorf("failed to scope connection to tenant: %w", err)
+	}
+	data[connTenantKey] = id
+	return true, nil
+}
+
+// tenantScopeSQL scopes a session to the tenant, or unscopes it for 0. SET does not accept
+// bind parameters, the id is an integer we format ourselves.
+func tenantScopeSQL(id int) string {
+	if id == 0 {
+		return "RESET ROLE; RESET app.tenant_id"
+	}
+	return fmt.Sprintf("SET ROLE %s; SET app.tenant_id = '%d'", tenantRole, id)
+}
+
+// purchaseStatements are the statements every purchase runs: the batched path
+// (LockPurchaseRows, ApplyPurchase) and the single-statement one
+var purchaseStatements = []string{
+	lockPurchaseItemSQL, lockPurchaseUserSQL, takeStockSQL, insertPurchaseSQL, grantInventorySQL, purchaseSQL,
+}
+
+// PreparePurchaseStatements prepares the purchase statements on conn. They are keyed by
+// their SQL, so the repository runs them prepared under any exec mode, sparing exec and
+// describe_exec a parse and plan per purchase and cache_statement the first-use prepare
+// and evictions (batches under simple_protocol still send them as text).
+func PreparePurchaseStatements(ctx context.Context, conn *pgx.Conn) error {
+	for _, sql := range purchaseStatements {
+		if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+			return fmt.Errorf("failed to prepare purchase statement: %w", err)
+		}
+	}
+	return nil
+}
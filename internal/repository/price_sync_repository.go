@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PriceSyncRepository stores which items are priced from Skinport and the price changes made
+type PriceSyncRepository struct {
+	db *pgxpool.Pool
+	// cache is the read cache of the ShopRepository, which price changes invalidate
+	cache *readCache
+}
+
+// NewPriceSyncRepository drops the items whose price it changes from shop's read cache;
+// shop may be nil
+func NewPriceSyncRepository(db *pgxpool.Pool, shop *ShopRepository) *PriceSyncRepository {
+	return &PriceSyncRepository{db: db, cache: cacheOf(shop)}
+}
+
+// UpsertPriceMapping maps the item to a Skinport item, replacing its previous mapping,
+// and fills in the item's name, price and created_at
+func (r *PriceSyncRepository) UpsertPriceMapping(ctx context.Context, m *model.SkinportPriceMapping) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		WITH mapping AS (
+			INSERT INTO skinport_price_mappings (item_id, market_hash_name, markup_percent) VALUES ($1, $2, $3)
+			ON CONFLICT (item_id) DO UPDATE SET market_hash_name = EXCLUDED.market_hash_name, markup_percent = EXCLUDED.markup_percent
+			RETURNING item_id, created_at
+		)
+		SELECT i.name, i.price, m.created_at FROM mapping m JOIN items i ON i.id = m.item_id`,
+		m.ItemID, m.MarketHashName, m.MarkupPercent).Scan(&m.ItemName, &m.ItemPrice, &m.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("item not found")
+		}
+		return fmt.Errorf("failed to save price mapping: %w", err)
+	}
+	return nil
+}
+
+// DeletePriceMapping stops pricing the item from Skinport
+func (r *PriceSyncRepository) DeletePriceMapping(ctx context.Context, itemID int) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, "DELETE FROM skinport_price_mappings WHERE item_id = $1", itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete price mapping: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("price mapping not found")
+	}
+	return nil
+}
+
+// ListPriceMappings returns every mapped item with its current price, by item id
+func (r *PriceSyncRepository) ListPriceMappings(ctx context.Context) ([]model.SkinportPriceMapping, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT m.item_id, i.name, m.market_hash_name, m.markup_percent, i.price, m.created_at
+		FROM skinport_price_mappings m
+		JOIN items i ON i.id = m.item_id
+		ORDER BY m.item_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := []model.SkinportPriceMapping{}
+	for rows.Next() {
+		var m model.SkinportPriceMapping
+		if err := rows.Scan(&m.ItemID, &m.ItemName, &m.MarketHashName, &m.MarkupPercent, &m.ItemPrice, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan price mapping: %w", err)
+		}
+		mappings = append(mappings, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list price mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+// ApplyPriceChange sets the item's price to c.NewPrice and records the change, unless
+// the price is no longer c.OldPrice (changed concurrently); it reports whether it did.
+// A pending change of the item is superseded.
+func (r *PriceSyncRepository) ApplyPriceChange(ctx context.Context, c *model.PriceChange) (bool, error) {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		WITH updated AS (
+			UPDATE items SET price = $3 WHERE id = $1 AND price = $2 RETURNING id
+		), superseded AS (
+			UPDATE price_changes SET status = 'superseded', decided_at = NOW()
+			WHERE item_id IN (SELECT id FROM updated) AND status = 'pending'
+		)
+		INSERT INTO price_changes (item_id, old_price, new_price, source, reference_price)
+		SELECT id, $2, $3, $4, $5 FROM updated`,
+		c.ItemID, c.OldPrice, c.NewPrice, c.Source, c.ReferencePrice)
+	if err != nil {
+		return false, fmt.Errorf("failed to apply price change: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	r.cache.itemChanged(ctx, c.ItemID)
+	return true, nil
+}
+
+// ProposePriceChange records c as the item's pending change, replacing the proposal of
+// an already pending one, and fills in its id, status and created_at
+func (r *PriceSyncRepository) ProposePriceChange(ctx context.Context, c *model.PriceChange) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO price_changes (item_id, old_price, new_price, source, reference_price, status)
+		VALUES ($1, $2, $3, $4, $5, 'pending')
+		ON CONFLICT (item_id) WHERE status = 'pending' DO UPDATE SET
+			old_price = EXCLUDED.old_price, new_price = EXCLUDED.new_price,
+			reference_price = EXCLUDED.reference_price, created_at = NOW()
+		RETURNING id, status, created_at`,
+		c.ItemID, c.OldPrice, c.NewPrice, c.Source, c.ReferencePrice).Scan(&c.ID, &c.Status, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to propose price change: %w", err)
+	}
+	return nil
+}
+
+const priceChangeColumns = "id, item_id, old_price, new_price, source, reference_price, status, decided_at, created_at"
+
+func scanPriceChange(row pgx.Row) (*model.PriceChange, error) {
+	var c model.PriceChange
+	err := row.Scan(&c.ID, &c.ItemID, &c.OldPrice, &c.NewPrice, &c.Source, &c.ReferencePrice, &c.Status, &c.DecidedAt, &c.CreatedAt)
+	return &c, err
+}
+
+// GetPriceChangeForUpdate locks and returns the price change
+func (r *PriceSyncRepository) GetPriceChangeForUpdate(ctx context.Context, id int) (*model.PriceChange, error) {
+	c, err := scanPriceChange(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+priceChangeColumns+" FROM price_changes WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("price change not found")
+		}
+		return nil, fmt.Errorf("failed to get price change: %w", err)
+	}
+	return c, nil
+}
+
+// DecidePriceChange sets the status of a pending change, recording the item's price
+// at decision time as its old price
+func (r *PriceSyncRepository) DecidePriceChange(ctx context.Context, c *model.PriceChange, status string, oldPrice model.Money) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		UPDATE price_changes SET status = $2, old_price = $3, decided_at = NOW()
+		WHERE id = $1
+		RETURNING status, old_price, decided_at`, c.ID, status, oldPrice).Scan(&c.Status, &c.OldPrice, &c.DecidedAt)
+	if err != nil {
+		return fmt.Errorf("failed to decide price change: %w", err)
+	}
+	return nil
+}
+
+// SetItemPrice sets the item's price
+func (r *PriceSyncRepository) SetItemPrice(ctx context.Context, itemID int, price model.Money) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, "UPDATE items SET price = $2 WHERE id = $1", itemID, price)
+	if err != nil {
+		return fmt.Errorf("failed to set item price: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("item not found")
+	}
+	r.cache.itemChanged(ctx, itemID)
+	return nil
+}
+
+// ListPriceChanges returns the price changes matching the filter, most recent first
+func (r *PriceSyncRepository) ListPriceChanges(ctx context.Context, filter model.PriceChangeFilter) ([]model.PriceChange, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.ItemID > 0 {
+		add("item_id = $%d", filter.ItemID)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+
+	sql := "SELECT " + priceChangeColumns + " FROM price_changes"
+	if len(conds) > 0 {
+		sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	sql += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []model.PriceChange{}
+	for rows.Next() {
+		c, err := scanPriceChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price change: %w", err)
+		}
+		changes = append(changes, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list price changes: %w", err)
+	}
+	return changes, nil
+}
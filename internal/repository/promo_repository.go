@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PromoRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPromoRepository(db *pgxpool.Pool) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+const promoColumns = `p.id, p.code, p.discount_type, p.discount_value, p.max_uses, p.used_count, p.expires_at, p.active, p.created_at,
+	COALESCE((SELECT array_agg(item_id ORDER BY item_id) FROM promo_code_items WHERE promo_code_id = p.id), '{}')`
+
+func scanPromoCode(row pgx.Row) (*model.PromoCode, error) {
+	var p model.PromoCode
+	err := row.Scan(&p.ID, &p.Code, &p.DiscountType, &p.DiscountValue, &p.MaxUses, &p.UsedCount, &p.ExpiresAt, &p.Active, &p.CreatedAt, &p.ItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPromoCodeForUpdate locks the promo code row so usage limits hold under concurrent purchases
+func (r *PromoRepository) GetPromoCodeForUpdate(ctx context.Context, code string) (*model.PromoCode, error) {
+	row := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+promoColumns+" FROM promo_codes p WHERE upper(p.code) = upper($1) FOR UPDATE OF p", code)
+	p, err := scanPromoCode(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("promo code not found")
+		}
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	return p, nil
+}
+
+// IncrementPromoCodeUsage counts one more use of the promo code
+func (r *PromoRepository) IncrementPromoCodeUsage(ctx context.Context, promoCodeID int) error {
+	_, err := executorFromContext(ctx, r.db).Exec(ctx, "UPDATE promo_codes SET used_count = used_count + 1 WHERE id = $1", promoCodeID)
+	if err != nil {
+		return fmt.Errorf("failed to update promo code usage: %w", err)
+	}
+	return nil
+}
+
+// CreatePromoCode inserts a promo code together with its item restrictions
+func (r *PromoRepository) CreatePromoCode(ctx context.Context, p *model.PromoCode) error {
+	exec := executorFromContext(ctx, r.db)
+	err := exec.QueryRow(ctx, `
+		INSERT INTO promo_codes (code, discount_type, discount_value, max_uses, expires_at, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		p.Code, p.DiscountType, p.DiscountValue, p.MaxUses, p.ExpiresAt, p.Active).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return errors.New("promo code already exists")
+		}
+		return fmt.Errorf("failed to create promo code: %w", err)
+	}
+
+	for _, itemID := range p.ItemIDs {
+		if _, err := exec.Exec(ctx, "INSERT INTO promo_code_items (promo_code_id, item_id) VALUES ($1, $2)", p.ID, itemID); err != nil {
+			return fmt.Errorf("failed to restrict promo code to item %d: %w", itemID, err)
+		}
+	}
+	return nil
+}
+
+// ListPromoCodes returns all promo codes, newest first
+func (r *PromoRepository) ListPromoCodes(ctx context.Context) ([]model.PromoCode, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, "SELECT "+promoColumns+" FROM promo_codes p ORDER BY p.id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promo codes: %w", err)
+	}
+	defer rows.Close()
+
+	codes := []model.PromoCode{}
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promo code: %w", err)
+		}
+		codes = append(codes, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list promo codes: %w", err)
+	}
+	return codes, nil
+}
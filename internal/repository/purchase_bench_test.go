@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/testdb"
+)
+
+// BenchmarkPurchaseWrites compares the purchase transaction issued statement by
+// statement (six round-trips) with the batched path BuyItem uses (two round-trips)
+// and the single-statement purchase.
+// The gap grows with network latency to the database.
+func BenchmarkPurchaseWrites(b *testing.B) {
+	pool := testdb.New(b, "orders", "users", "items")
+	repo := NewShopRepository(pool)
+	ctx := context.Background()
+
+	user := model.User{FirstName: "Bench", LastName: "User", Balance: money(1e9)}
+	if err := repo.CreateUser(ctx, &user); err != nil {
+		b.Fatal(err)
+	}
+	item := model.Item{Name: "Bench Item", Price: money(1), Stock: 1e9}
+	if err := repo.CreateItem(ctx, &item); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for b.Loop() {
+			err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+				price, _, err := repo.GetItemForUpdate(ctx, item.ID)
+				if err != nil {
+					return err
+				}
+				if _, err := repo.GetUserForUpdate(ctx, user.ID); err != nil {
+					return err
+				}
+				if err := repo.UpdateUserBalance(ctx, user.ID, price); err != nil {
+					return err
+				}
+				if err := repo.UpdateItemStock(ctx, item.ID, 1); err != nil {
+					return err
+				}
+				if _, err := repo.CreateOrder(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Price: price, Quantity: 1}); err != nil {
+					return err
+				}
+				return repo.GrantInventory(ctx, user.ID, item.ID, 1)
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for b.Loop() {
+			err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+				price, _, _, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+				if err != nil {
+					return err
+				}
+				return repo.ApplyPurchase(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Price: price, Quantity: 1})
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("single_statement", func(b *testing.B) {
+		for b.Loop() {
+			err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+				_, _, err := repo.PurchaseSingleStatement(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Quantity: 1})
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPurchaseExecModes runs concurrent batched purchases, as in the handler's
+// TestBuyItem_Concurrency, under each pgx query exec mode with and without the purchase
+// statements prepared on connect (DB_QUERY_EXEC_MODE, DB_PREPARE_PURCHASE_STATEMENTS).
+// Compare the ns/op of the sub-benchmarks: with -cpu 8 it is the time per purchase of 8
+// buyers contending for the same rows.
+func BenchmarkPurchaseExecModes(b *testing.B) {
+	for _, mode := range []string{"cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"} {
+		for _, prepare := range []bool{false, true} {
+			name := mode
+			if prepare {
+				name += "/prepared"
+			}
+			b.Run(name, func(b *testing.B) {
+				cfg := PoolConfig{QueryExecMode: mode, PreparePurchases: prepare}
+				pool := testdb.NewWith(b, cfg.Apply, "orders", "users", "items")
+				repo := NewShopRepository(pool)
+				ctx := context.Background()
+
+				user := model.User{FirstName: "Bench", LastName: "User", Balance: money(1e9)}
+				if err := repo.CreateUser(ctx, &user); err != nil {
+					b.Fatal(err)
+				}
+				item := model.Item{Name: "Bench Item", Price: money(1), Stock: 1e9}
+				if err := repo.CreateItem(ctx, &item); err != nil {
+					b.Fatal(err)
+				}
+
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						err := repo.RunAtomic(ctx, func(ctx context.Context) error {
+							price, _, _, err := repo.LockPurchaseRows(ctx, item.ID, user.ID, 1)
+							if err != nil {
+								return err
+							}
+							return repo.ApplyPurchase(ctx, &model.Order{UserID: user.ID, ItemID: item.ID, Price: price, Quantity: 1})
+						})
+						if err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,219 @@
+-- name: GetItemForUpdate :one
+SELECT price, stock FROM items WHERE id = $1 FOR UPDATE;
+
+-- name: GetUserForUpdate :one
+SELECT balance FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateItemStock :exec
+UPDATE items SET stock = stock - sqlc.arg(quantity)::int WHERE id = sqlc.arg(id);
+
+-- name: CreateUser :one
+INSERT INTO users (first_name, last_name, balance, email)
+VALUES (sqlc.arg(first_name), sqlc.arg(last_name), sqlc.arg(balance), NULLIF(sqlc.arg(email)::text, ''))
+RETURNING id, status;
+
+-- name: CreateItem :one
+INSERT INTO items (name, price, stock) VALUES ($1, $2, $3) RETURNING id;
+
+-- name: ListPriceTiers :many
+SELECT item_id, min_qty, unit_price FROM price_tiers WHERE item_id = $1 ORDER BY min_qty;
+
+-- name: SnapshotItemPrices :execrows
+INSERT INTO item_price_snapshots (item_id, price, stock)
+SELECT id, price, stock FROM items;
+
+-- name: SetItemImage :one
+WITH previous AS (
+    SELECT id, image_key FROM items WHERE id = sqlc.arg(id) FOR UPDATE
+)
+UPDATE items i SET image_key = sqlc.arg(image_key)::text, image_url = sqlc.arg(image_url)::text
+FROM previous p
+WHERE i.id = p.id
+RETURNING COALESCE(p.image_key, '')::text AS previous_key;
+
+-- name: GetItemImageKey :one
+SELECT COALESCE(image_key, '')::text AS image_key FROM items WHERE id = $1;
+
+-- name: GetUser :one
+SELECT id, first_name, last_name, balance, COALESCE(email, '')::text AS email,
+    COALESCE(region, '')::text AS region, status
+FROM users WHERE id = $1;
+
+-- name: SetUserEmail :execrows
+UPDATE users SET email = NULLIF(sqlc.arg(email)::text, '') WHERE id = sqlc.arg(id);
+
+-- name: SetUserRegion :execrows
+UPDATE users SET region = NULLIF(sqlc.arg(region)::text, '') WHERE id = sqlc.arg(id);
+
+-- name: LockUserStatus :one
+SELECT status FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: SetUserStatus :execrows
+UPDATE users SET status = sqlc.arg(status)::text,
+    deactivated_at = CASE WHEN sqlc.arg(status)::text = 'deactivated' THEN NOW() END
+WHERE id = sqlc.arg(id);
+
+-- name: HasPendingPayouts :one
+SELECT EXISTS (SELECT 1 FROM payouts WHERE user_id = $1 AND status IN ('pending', 'sending')) AS pending;
+
+-- name: CreateBalanceAdjustment :exec
+INSERT INTO balance_adjustments (user_id, delta, reason, source) VALUES ($1, $2, $3, $4);
+
+-- name: CountItemsByName :many
+SELECT name, count(*) AS count FROM items WHERE name = ANY(sqlc.arg(names)::text[]) GROUP BY name;
+
+-- name: RestockItem :one
+WITH i AS (
+    UPDATE items SET stock = stock + sqlc.arg(quantity)::int
+    WHERE id = sqlc.arg(item_id)::int
+        AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE source = sqlc.arg(source)::text AND reference = sqlc.arg(reference)::text)
+    RETURNING id, stock
+)
+INSERT INTO stock_movements (item_id, quantity, reason, source, reference, stock_after)
+SELECT i.id, sqlc.arg(quantity)::int, sqlc.arg(reason)::text, sqlc.arg(source)::text, sqlc.arg(reference)::text, i.stock FROM i
+RETURNING id, stock_after, created_at;
+
+-- name: StockMovementApplied :one
+SELECT EXISTS (SELECT 1 FROM stock_movements WHERE source = $1 AND reference = $2) AS applied;
+
+-- name: FindItemIDsByName :many
+SELECT id FROM items WHERE name = $1 LIMIT 2;
+
+-- name: CreateOrder :one
+INSERT INTO orders (user_id, item_id, price, quantity, promo_code_id, discount, status, paid_at, client_order_id, unit_price,
+    tax_rate, tax_amount, quote_id, drop_id)
+VALUES (sqlc.arg(user_id)::int, sqlc.arg(item_id)::int, sqlc.arg(price), sqlc.arg(quantity), sqlc.arg(promo_code_id), sqlc.arg(discount),
+    sqlc.arg(status)::text, CASE WHEN sqlc.arg(status)::text = 'paid' THEN NOW() END, NULLIF(sqlc.arg(client_order_id)::text, ''),
+    sqlc.arg(unit_price)::numeric, sqlc.arg(tax_rate), sqlc.arg(tax_amount), NULLIF(sqlc.arg(quote_id)::text, ''), sqlc.arg(drop_id))
+RETURNING id, created_at, paid_at;
+
+-- name: RevokeInventory :exec
+UPDATE inventories SET quantity = GREATEST(quantity - sqlc.arg(quantity)::int, 0), updated_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND item_id = sqlc.arg(item_id);
+
+-- name: GetUnitPrice :one
+SELECT item_unit_price(id, price, sqlc.arg(quantity)::int)::numeric AS unit_price, stock FROM items WHERE id = sqlc.arg(id);
+
+-- name: DeletePriceTiers :exec
+DELETE FROM price_tiers WHERE item_id = $1;
+
+-- name: InsertPriceTiers :exec
+INSERT INTO price_tiers (item_id, min_qty, unit_price)
+SELECT sqlc.arg(item_id)::int, unnest(sqlc.arg(min_qtys)::int[]), unnest(sqlc.arg(unit_prices)::numeric[]);
+
+-- name: GetOrder :one
+SELECT id, user_id::int AS user_id, item_id::int AS item_id, price, COALESCE(unit_price, 0)::numeric AS unit_price, quantity,
+    promo_code_id, discount, tax_rate, tax_amount, status, COALESCE(client_order_id, '')::text AS client_order_id,
+    COALESCE(quote_id, '')::text AS quote_id, drop_id, refund_decision, created_at, paid_at, fulfilled_at, refunded_at, cancelled_at
+FROM orders WHERE id = $1;
+
+-- name: GetOrderForUpdate :one
+SELECT id, user_id::int AS user_id, item_id::int AS item_id, price, COALESCE(unit_price, 0)::numeric AS unit_price, quantity,
+    promo_code_id, discount, tax_rate, tax_amount, status, COALESCE(client_order_id, '')::text AS client_order_id,
+    COALESCE(quote_id, '')::text AS quote_id, drop_id, refund_decision, created_at, paid_at, fulfilled_at, refunded_at, cancelled_at
+FROM orders WHERE id = $1 FOR UPDATE;
+
+-- name: GetOrderByClientOrderID :one
+SELECT id, user_id::int AS user_id, item_id::int AS item_id, price, COALESCE(unit_price, 0)::numeric AS unit_price, quantity,
+    promo_code_id, discount, tax_rate, tax_amount, status, COALESCE(client_order_id, '')::text AS client_order_id,
+    COALESCE(quote_id, '')::text AS quote_id, drop_id, refund_decision, created_at, paid_at, fulfilled_at, refunded_at, cancelled_at
+FROM orders WHERE user_id = sqlc.arg(user_id)::int AND client_order_id = sqlc.arg(client_order_id)::text;
+
+-- name: UpdateOrderStatus :one
+UPDATE orders SET status = sqlc.arg(status)::text,
+    paid_at = CASE WHEN sqlc.arg(status)::text = 'paid' THEN NOW() ELSE paid_at END,
+    fulfilled_at = CASE WHEN sqlc.arg(status)::text = 'fulfilled' THEN NOW() ELSE fulfilled_at END,
+    refunded_at = CASE WHEN sqlc.arg(status)::text = 'refunded' THEN NOW() ELSE refunded_at END,
+    cancelled_at = CASE WHEN sqlc.arg(status)::text = 'cancelled' THEN NOW() ELSE cancelled_at END
+WHERE id = sqlc.arg(id)
+RETURNING id, user_id::int AS user_id, item_id::int AS item_id, price, COALESCE(unit_price, 0)::numeric AS unit_price, quantity,
+    promo_code_id, discount, tax_rate, tax_amount, status, COALESCE(client_order_id, '')::text AS client_order_id,
+    COALESCE(quote_id, '')::text AS quote_id, drop_id, refund_decision, created_at, paid_at, fulfilled_at, refunded_at, cancelled_at;
+
+-- name: SetRefundDecision :execrows
+UPDATE orders SET refund_decision = sqlc.arg(refund_decision) WHERE id = sqlc.arg(id);
+
+-- name: GetItemCategory :one
+SELECT COALESCE(c.slug, '')::text AS slug FROM items i
+LEFT JOIN categories c ON c.id = i.category_id
+WHERE i.id = $1;
+
+-- name: GetItem :one
+SELECT id, name, price, stock, description, COALESCE(image_url, '')::text AS image_url, attributes
+FROM items WHERE id = $1;
+
+-- name: UpdateItemMetadata :one
+WITH previous AS (
+    SELECT id, image_key FROM items WHERE id = sqlc.arg(id) FOR UPDATE
+)
+UPDATE items i SET
+    description = COALESCE(sqlc.narg(description)::text, i.description),
+    image_url = CASE WHEN sqlc.narg(image_url)::text IS NULL THEN i.image_url ELSE NULLIF(sqlc.narg(image_url)::text, '') END,
+    image_key = CASE WHEN sqlc.narg(image_url)::text IS NULL THEN i.image_key END,
+    attributes = COALESCE(sqlc.arg(attributes)::jsonb, i.attributes)
+FROM previous p
+WHERE i.id = p.id
+RETURNING (CASE WHEN sqlc.narg(image_url)::text IS NULL THEN '' ELSE COALESCE(p.image_key, '') END)::text AS previous_key;
+
+-- name: AdjustUserBalance :one
+WITH account AS (
+    SELECT u.balance, u.tenant_id, a.id AS account_id
+    FROM users u JOIN ledger_accounts a ON a.user_id = u.id
+    WHERE u.id = sqlc.arg(user_id)::int
+    FOR UPDATE OF u
+), tx AS (
+    INSERT INTO ledger_transactions (kind, reference, tenant_id)
+    SELECT sqlc.arg(kind)::text, sqlc.arg(reference)::text, account.tenant_id FROM account
+    WHERE account.balance + sqlc.arg(delta)::numeric >= 0
+    RETURNING id
+), entries AS (
+    INSERT INTO ledger_entries (transaction_id, account_id, amount)
+    SELECT tx.id, account.account_id, sqlc.arg(delta)::numeric FROM tx CROSS JOIN account
+    UNION ALL
+    SELECT tx.id, ledger_account(sqlc.arg(account)::text), -sqlc.arg(delta)::numeric FROM tx
+)
+SELECT (balance + sqlc.arg(delta)::numeric)::numeric AS balance FROM account;
+
+-- name: RecordBalanceAdjustment :one
+INSERT INTO balance_adjustments (user_id, delta, reason_code, reason, source)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at;
+
+-- name: GetPurchaseActivity :one
+SELECT
+    (COUNT(*) FILTER (WHERE created_at >= NOW() - INTERVAL '1 minute'))::int AS orders_last_minute,
+    COALESCE(SUM(price) FILTER (WHERE created_at >= NOW() - INTERVAL '1 day'), 0)::numeric AS spend_last_day,
+    COALESCE(SUM(quantity) FILTER (WHERE item_id = sqlc.arg(item_id)::int), 0)::int AS item_quantity
+FROM orders
+WHERE user_id = sqlc.arg(user_id)::int;
+
+-- name: ClaimPendingReceipts :many
+SELECT o.id, o.user_id::int AS user_id, o.item_id::int AS item_id, o.price, o.quantity, o.discount, o.status, o.created_at,
+    COALESCE(u.email, '')::text AS email, COALESCE(t.chat_id, 0)::bigint AS telegram_chat_id, u.first_name,
+    i.name AS item_name, o.created_at < NOW() - sqlc.arg(max_age)::interval AS stale
+FROM orders o
+JOIN users u ON u.id = o.user_id
+JOIN items i ON i.id = o.item_id
+LEFT JOIN telegram_links t ON t.user_id = o.user_id
+WHERE o.receipt_sent_at IS NULL
+ORDER BY o.id
+LIMIT sqlc.arg(max_receipts)::int
+FOR UPDATE OF o SKIP LOCKED;
+
+-- name: MarkReceiptsSent :exec
+UPDATE orders SET receipt_sent_at = NOW() WHERE id = ANY(sqlc.arg(ids)::int[]);
+
+-- name: ListUserOrderIDs :many
+SELECT id FROM orders WHERE user_id = sqlc.arg(user_id)::int ORDER BY id;
+
+-- name: ExistingUserIDs :many
+SELECT id FROM users WHERE id = ANY(sqlc.arg(ids)::int[]);
+
+-- name: ExistingItemIDs :many
+SELECT id FROM items WHERE id = ANY(sqlc.arg(ids)::int[]);
+
+-- name: ExistingClientOrderIDs :many
+SELECT user_id::int AS user_id, client_order_id::text AS client_order_id FROM orders
+WHERE (user_id, client_order_id) IN (
+    SELECT unnest(sqlc.arg(user_ids)::int[]), unnest(sqlc.arg(client_order_ids)::text[])
+);
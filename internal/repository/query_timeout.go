@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrQueryTimeout is returned when a single query outlived the timeout set by WithQueryTimeout.
+// Unlike an expired request it leaves the caller time to retry.
+var ErrQueryTimeout = errors.New("query timed out")
+
+// IsQueryTimeout reports whether a single query, rather than the whole operation, ran out of
+// time: ErrQueryTimeout or the server's statement_timeout (query_canceled, 57014)
+func IsQueryTimeout(err error) bool {
+	if errors.Is(err, ErrQueryTimeout) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+type queryTimeoutKey struct{}
+
+// WithQueryTimeout bounds every query run with ctx, including the wait for a pooled
+// connection that statement_timeout does not cover (0 disables)
+func WithQueryTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, d)
+}
+
+func queryTimeoutFrom(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(queryTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// timeoutExecutor runs each query under its own deadline. The deadline ends with the
+// query: Exec and CopyFrom returning, QueryRow's Scan, or closing Query's rows and
+// SendBatch's results.
+type timeoutExecutor struct {
+	PgxExecutor
+	timeout time.Duration
+}
+
+func (e timeoutExecutor) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+// wrap marks err as ErrQueryTimeout when the query's own deadline, not the caller's, ran out
+func (e timeoutExecutor) wrap(parent, ctx context.Context, err error) error {
+	if err == nil || parent.Err() != nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrQueryTimeout, e.timeout, err)
+}
+
+func (e timeoutExecutor) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	qctx, cancel := e.queryContext(ctx)
+	defer cancel()
+	tag, err := e.PgxExecutor.Exec(qctx, sql, args...)
+	return tag, e.wrap(ctx, qctx, err)
+}
+
+func (e timeoutExecutor) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	qctx, cancel := e.queryContext(ctx)
+	rows, err := e.PgxExecutor.Query(qctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, e.wrap(ctx, qctx, err)
+	}
+	return &timeoutRows{Rows: rows, exec: e, parent: ctx, ctx: qctx, cancel: cancel}, nil
+}
+
+func (e timeoutExecutor) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	qctx, cancel := e.queryContext(ctx)
+	return &timeoutRow{row: e.PgxExecutor.QueryRow(qctx, sql, args...), exec: e, parent: ctx, ctx: qctx, cancel: cancel}
+}
+
+func (e timeoutExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	qctx, cancel := e.queryContext(ctx)
+	return &timeoutBatch{BatchResults: e.PgxExecutor.SendBatch(qctx, b), exec: e, parent: ctx, ctx: qctx, cancel: cancel}
+}
+
+func (e timeoutExecutor) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	qctx, cancel := e.queryContext(ctx)
+	defer cancel()
+	n, err := e.PgxExecutor.CopyFrom(qctx, table, columns, src)
+	return n, e.wrap(ctx, qctx, err)
+}
+
+type timeoutRows struct {
+	pgx.Rows
+	exec   timeoutExecutor
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+func (r *timeoutRows) Err() error {
+	return r.exec.wrap(r.parent, r.ctx, r.Rows.Err())
+}
+
+type timeoutRow struct {
+	row    pgx.Row
+	exec   timeoutExecutor
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.exec.wrap(r.parent, r.ctx, r.row.Scan(dest...))
+}
+
+type timeoutBatch struct {
+	pgx.BatchResults
+	exec   timeoutExecutor
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (b *timeoutBatch) Exec() (pgconn.CommandTag, error) {
+	tag, err := b.BatchResults.Exec()
+	return tag, b.exec.wrap(b.parent, b.ctx, err)
+}
+
+func (b *timeoutBatch) Close() error {
+	defer b.cancel()
+	return b.exec.wrap(b.parent, b.ctx, b.BatchResults.Close())
+}
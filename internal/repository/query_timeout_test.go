@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowExecutor blocks every statement until its context is done, like a stalled database
+type slowExecutor struct{ PgxExecutor }
+
+func (slowExecutor) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	<-ctx.Done()
+	return pgconn.CommandTag{}, fmt.Errorf("timeout: %w", ctx.Err())
+}
+
+func (slowExecutor) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return slowRow{ctx}
+}
+
+type slowRow struct{ ctx context.Context }
+
+func (r slowRow) Scan(dest ...any) error {
+	<-r.ctx.Done()
+	return r.ctx.Err()
+}
+
+func TestTimeoutExecutor(t *testing.T) {
+	exec := timeoutExecutor{PgxExecutor: slowExecutor{}, timeout: 10 * time.Millisecond}
+
+	_, err := exec.Exec(context.Background(), "SELECT pg_sleep(1)")
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var n int
+	err = exec.QueryRow(context.Background(), "SELECT pg_sleep(1)").Scan(&n)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+
+	// The caller's own deadline is not the query's timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	exec.timeout = time.Minute
+	_, err = exec.Exec(ctx, "SELECT pg_sleep(1)")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrQueryTimeout)
+}
+
+func TestExecutorFromContext_QueryTimeout(t *testing.T) {
+	_, ok := executorFromContext(context.Background(), nil).(timeoutExecutor)
+	assert.False(t, ok)
+
+	exec, ok := executorFromContext(WithQueryTimeout(context.Background(), time.Second), nil).(timeoutExecutor)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, exec.timeout)
+
+	_, ok = executorFromContext(WithQueryTimeout(context.Background(), 0), nil).(timeoutExecutor)
+	assert.False(t, ok, "0 disables the timeout")
+}
+
+func TestIsQueryTimeout(t *testing.T) {
+	assert.True(t, IsQueryTimeout(fmt.Errorf("failed to list items: %w", fmt.Errorf("%w after 1s: %w", ErrQueryTimeout, context.DeadlineExceeded))))
+	assert.True(t, IsQueryTimeout(&pgconn.PgError{Code: "57014"}))
+	assert.True(t, IsTimeout(ErrQueryTimeout))
+	assert.False(t, IsQueryTimeout(context.DeadlineExceeded), "a request or upstream deadline")
+	assert.False(t, IsQueryTimeout(errors.New("item not found")))
+}
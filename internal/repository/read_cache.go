@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/cache"
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/tenant"
+)
+
+// readCache keeps items, item listings and users read through a ShopRepository. Writes
+// drop what they change, again once their transaction committed: the ShopRepository's
+// own and those of the repositories sharing its cache (price sync, category
+// assignments). Changes made by other instances show within the TTL.
+type readCache struct {
+	items *cache.LRU[cacheKey, model.Item]
+	lists *cache.LRU[listKey, []model.Item]
+	users *cache.LRU[cacheKey, model.User]
+
+	mu sync.Mutex
+	// tenants holds the tenants rows were cached for, see keysOf
+	tenants map[int]struct{}
+}
+
+// cacheOf returns the read cache of shop, nil when there is none
+func cacheOf(shop *ShopRepository) *readCache {
+	if shop == nil {
+		return nil
+	}
+	return shop.cache
+}
+
+// cacheKey keys a row by the tenant it was read for, which row level security scopes
+// reads to
+type cacheKey struct {
+	tenant, id int
+}
+
+type listKey struct {
+	tenant int
+	query  string
+}
+
+func newReadCache(size int, ttl time.Duration) *readCache {
+	if size < 1 {
+		return nil
+	}
+	return &readCache{
+		items:   cache.New[cacheKey, model.Item](size, ttl),
+		lists:   cache.New[listKey, []model.Item](size, ttl),
+		users:   cache.New[cacheKey, model.User](size, ttl),
+		tenants: map[int]struct{}{},
+	}
+}
+
+// usable reports whether ctx's reads may be served from and stored in the cache: reads
+// inside a transaction must see its own writes and must not publish uncommitted rows
+func (c *readCache) usable(ctx context.Context) bool {
+	if c == nil {
+		return false
+	}
+	_, inTx := db.TxFromContext(ctx)
+	return !inTx
+}
+
+func rowKey(ctx context.Context, id int) cacheKey {
+	tenantID, _ := tenant.IDFrom(ctx)
+	return cacheKey{tenant: tenantID, id: id}
+}
+
+// rowKeyFor returns the key of a row stored for ctx, remembering its tenant
+func (c *readCache) rowKeyFor(ctx context.Context, id int) cacheKey {
+	key := rowKey(ctx, id)
+	c.mu.Lock()
+	c.tenants[key.tenant] = struct{}{}
+	c.mu.Unlock()
+	return key
+}
+
+// keysOf returns the keys of the row under every tenant it may be cached for. Writers
+// without a tenant, such as webhooks and background jobs, change rows that requests
+// cached under their shop, and ids are unique across shops.
+func (c *readCache) keysOf(id int) []cacheKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]cacheKey, 0, len(c.tenants))
+	for tenantID := range c.tenants {
+		keys = append(keys, cacheKey{tenant: tenantID, id: id})
+	}
+	return keys
+}
+
+func itemListKey(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) listKey {
+	tenantID, _ := tenant.IDFrom(ctx)
+	return listKey{tenant: tenantID, query: fmt.Sprintf("%q|%q|%v|%q|%d|%d",
+		filter.Category, strings.Join(filter.Tags, ","), opts.Sort, opts.After, opts.Offset, opts.Limit)}
+}
+
+// lookup returns a cached value and counts the hit or miss
+func lookup[K comparable, V any](c *cache.LRU[K, V], name string, key K) (V, bool) {
+	v, ok := c.Get(key)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	metrics.CacheRequests.WithLabelValues(name, result).Inc()
+	return v, ok
+}
+
+func (c *readCache) getItem(ctx context.Context, id int) (model.Item, bool) {
+	if !c.usable(ctx) {
+		return model.Item{}, false
+	}
+	return lookup(c.items, "items", rowKey(ctx, id))
+}
+
+func (c *readCache) setItem(ctx context.Context, item model.Item) {
+	if c.usable(ctx) {
+		c.items.Set(c.rowKeyFor(ctx, item.ID), item)
+	}
+}
+
+// getList returns a copy of a cached listing, which callers may reslice
+func (c *readCache) getList(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) ([]model.Item, bool) {
+	if !c.usable(ctx) {
+		return nil, false
+	}
+	items, ok := lookup(c.lists, "item_lists", itemListKey(ctx, filter, opts))
+	return slices.Clone(items), ok
+}
+
+func (c *readCache) setList(ctx context.Context, filter model.ItemFilter, opts model.ListOptions, items []model.Item) {
+	if c.usable(ctx) {
+		c.lists.Set(itemListKey(ctx, filter, opts), slices.Clone(items))
+	}
+}
+
+func (c *readCache) getUser(ctx context.Context, id int) (model.User, bool) {
+	if !c.usable(ctx) {
+		return model.User{}, false
+	}
+	return lookup(c.users, "users", rowKey(ctx, id))
+}
+
+func (c *readCache) setUser(ctx context.Context, user model.User) {
+	if c.usable(ctx) {
+		c.users.Set(c.rowKeyFor(ctx, user.ID), user)
+	}
+}
+
+// itemChanged drops the item, under every tenant, and every listing, which may show it.
+// An id of 0 drops every item, for bulk changes.
+func (c *readCache) itemChanged(ctx context.Context, id int) {
+	if c == nil {
+		return
+	}
+	drop := func() {
+		if id == 0 {
+			c.items.Purge()
+		} else {
+			for _, key := range c.keysOf(id) {
+				c.items.Delete(key)
+			}
+		}
+		c.lists.Purge()
+	}
+	drop()
+	staleFrom(ctx).add(drop)
+}
+
+func (c *readCache) userChanged(ctx context.Context, id int) {
+	if c == nil {
+		return
+	}
+	drop := func() {
+		for _, key := range c.keysOf(id) {
+			c.users.Delete(key)
+		}
+	}
+	drop()
+	staleFrom(ctx).add(drop)
+}
+
+// staleEntries collects the invalidations of a transaction, repeated once it ended: a
+// concurrent read may have cached the old row again before the commit
+type staleEntries struct {
+	mu    sync.Mutex
+	drops []func()
+}
+
+type staleEntriesKey struct{}
+
+func withStaleEntries(ctx context.Context) (context.Context, *staleEntries) {
+	s := &staleEntries{}
+	return context.WithValue(ctx, staleEntriesKey{}, s), s
+}
+
+// staleFrom returns the transaction's invalidations, nil outside RunAtomic
+func staleFrom(ctx context.Context) *staleEntries {
+	s, _ := ctx.Value(staleEntriesKey{}).(*staleEntries)
+	return s
+}
+
+func (s *staleEntries) add(drop func()) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.drops = append(s.drops, drop)
+	s.mu.Unlock()
+}
+
+// drop repeats the invalidations outside the transaction
+func (s *staleEntries) drop() {
+	s.mu.Lock()
+	drops := s.drops
+	s.drops = nil
+	s.mu.Unlock()
+	for _, drop := range drops {
+		drop()
+	}
+}
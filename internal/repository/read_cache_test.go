@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCache(t *testing.T) {
+	assert.Nil(t, newReadCache(0, time.Minute), "a size of 0 disables the cache")
+
+	c := newReadCache(10, time.Minute)
+	acme := tenant.WithID(context.Background(), 1)
+	other := tenant.WithID(context.Background(), 2)
+
+	c.setItem(acme, model.Item{ID: 7, Name: "AK-47"})
+	_, ok := c.getItem(other, 7)
+	assert.False(t, ok, "entries are kept per tenant")
+	item, ok := c.getItem(acme, 7)
+	assert.True(t, ok)
+	assert.Equal(t, "AK-47", item.Name)
+
+	items := []model.Item{{ID: 7}, {ID: 8}}
+	c.setList(acme, model.ItemFilter{Category: "rifles"}, model.ListOptions{Limit: 1}, items)
+	_, ok = c.getList(acme, model.ItemFilter{Category: "knives"}, model.ListOptions{Limit: 1})
+	assert.False(t, ok)
+	list, ok := c.getList(acme, model.ItemFilter{Category: "rifles"}, model.ListOptions{Limit: 1})
+	assert.True(t, ok)
+	assert.Equal(t, items, list)
+
+	c.itemChanged(acme, 7)
+	_, ok = c.getItem(acme, 7)
+	assert.False(t, ok)
+	_, ok = c.getList(acme, model.ItemFilter{Category: "rifles"}, model.ListOptions{Limit: 1})
+	assert.False(t, ok, "listings are dropped with any item")
+
+	// Webhooks and background jobs write without a tenant
+	c.setUser(acme, model.User{ID: 3, Balance: money(100)})
+	c.setItem(other, model.Item{ID: 8, Stock: 5})
+	c.userChanged(context.Background(), 3)
+	c.itemChanged(context.Background(), 8)
+	_, ok = c.getUser(acme, 3)
+	assert.False(t, ok, "unscoped writes drop the row under every tenant")
+	_, ok = c.getItem(other, 8)
+	assert.False(t, ok)
+}
+
+func TestReadCache_StaleEntries(t *testing.T) {
+	c := newReadCache(10, time.Minute)
+	ctx, stale := withStaleEntries(context.Background())
+
+	c.setUser(context.Background(), model.User{ID: 3, Balance: money(100)})
+	c.userChanged(ctx, 3)
+
+	// A read racing the transaction caches the balance from before its commit
+	c.setUser(context.Background(), model.User{ID: 3, Balance: money(100)})
+	stale.drop()
+	_, ok := c.getUser(context.Background(), 3)
+	assert.False(t, ok, "the invalidation is repeated once the transaction ended")
+}
@@ -2,8 +2,15 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository/shopdb"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -12,70 +19,141 @@ import (
 
 type ShopRepository struct {
 	db *pgxpool.Pool
+	// uow runs RunAtomic
+	uow *db.Pgx
+	// cache serves item and user reads, nil reads everything from the database
+	cache *readCache
 }
 
-func NewShopRepository(db *pgxpool.Pool) *ShopRepository {
-	return &ShopRepository{db: db}
+// Option configures a ShopRepository
+type Option func(*shopOptions)
+
+type shopOptions struct {
+	uow       []db.PgxOption
+	cacheSize int
+	cacheTTL  time.Duration
 }
 
-// RunAtomic executes a function within a transaction
-func (r *ShopRepository) RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error {
-	tx, err := r.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// WithStatementTimeout sets SET LOCAL statement_timeout for transactions started by RunAtomic
+func WithStatementTimeout(d time.Duration) Option {
+	return func(o *shopOptions) {
+		o.uow = append(o.uow, db.WithStatementTimeout(d))
 	}
-	// Defer rollback in case of panic or error (if commit succeeds, rollback does nothing)
-	defer tx.Rollback(ctx)
-
-	// Inject tx into context
-	// NOTE: meaningful context key or pass tx explicitly?
-	// For simplicity in this project (as `db *pgxpool.Pool` is in struct),
-	// we assume we just pass context. BUT `pgx` handles tx via `BeginTx` or using logic on connection.
-	// However, `pgxpool` methods usually take context. To run queries INSIDE tx, we need the `pgx.Tx` object.
-	//
-	// A common pattern is interface for Querier (Exec, QueryRow, etc).
-	// To keep it simple and Clean Architecture compliant without robust `context.WithValue` magic complications:
-	// We will pass `ctx` hoping the methods use `db`? NO.
-	//
-	// `RunAtomic` usually needs to provide a way for called methods to use the TX.
-	//
-	// APPROACH:
-	// The methods `GetItemForUpdate` etc. currently use `r.db.QueryRow`.
-	// We need them to be able to use EITHER `r.db` OR a `tx`.
-	//
-	// Let's use a context key for the transaction, or a closure based approach where we pass an interface?
-	//
-	// Simplest approach for now: Store Tx in context?
-	// `pgx` doesn't automatically pick it up.
-	//
-	// Let's implement `db` extraction helper or just pass `tx`?
-	// But `ShopService` shouldn't know about `pgx.Tx`.
-	//
-	// Refined Plan (Standard Go Pattern):
-	// Define a `Querier` interface? Or use Context.
-	//
-	// context approach:
+}
 
-	ctx = context.WithValue(ctx, txKey{}, tx)
+// WithRetry configures retries of transactions aborted by serialization failures/deadlocks
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(o *shopOptions) {
+		o.uow = append(o.uow, db.WithRetry(maxAttempts, backoff))
+	}
+}
 
-	if err := fn(ctx); err != nil {
-		return err
+// WithReadCache keeps up to size items, item listings and users each in memory for ttl,
+// sparing the database the hot GET /v1/items and user reads. The repository's own
+// writes drop what they change; other changes show within ttl. A size of 0 disables it.
+func WithReadCache(size int, ttl time.Duration) Option {
+	return func(o *shopOptions) {
+		o.cacheSize, o.cacheTTL = size, ttl
 	}
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+func NewShopRepository(pool *pgxpool.Pool, opts ...Option) *ShopRepository {
+	var o shopOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
+	return &ShopRepository{db: pool, uow: db.NewPgx(pool, o.uow...), cache: newReadCache(o.cacheSize, o.cacheTTL)}
+}
+
+// ErrRetriesExhausted is matched (via errors.Is) by RetryExhaustedError
+var ErrRetriesExhausted = db.ErrRetriesExhausted
+
+// Domain errors of purchases and balance changes, returned by the application's checks
+// and by the constraints backing them
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrDuplicateClientOrderID is returned when the user already has an order with the
+	// client_order_id, created by a concurrent request
+	ErrDuplicateClientOrderID = errors.New("duplicate client order id")
+	// ErrQuoteUsed is returned when an order was already bought with the quote
+	ErrQuoteUsed = errors.New("quote already used")
+	// ErrItemInDrop is returned by PurchaseSingleStatement for items in a drop, whose
+	// purchases go through the drop's checks
+	ErrItemInDrop = errors.New("item is in a drop")
+	// ErrUserInactive is returned when a deactivated or deleted user tries to buy
+	ErrUserInactive = errors.New("user account is not active")
+	// ErrAmbiguousItemName is returned when looking up an item by a name several items have
+	ErrAmbiguousItemName = errors.New("several items are named")
+)
+
+// constraintErrors maps CHECK (23514) and unique (23505) constraint violations to the
+// domain errors they guard against
+var constraintErrors = map[[2]string]error{
+	{"23514", "users_balance_nonnegative"}:       ErrInsufficientFunds,
+	{"23514", "items_stock_nonnegative"}:         ErrInsufficientStock,
+	{"23505", "orders_user_client_order_id_key"}: ErrDuplicateClientOrderID,
+	{"23505", "orders_quote_id_key"}:             ErrQuoteUsed,
+}
 
+// constraintError returns the domain error of a violated constraint, nil for other
+// errors. A regression in the application's checks thus still surfaces as, e.g.,
+// ErrInsufficientFunds rather than an internal error.
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return constraintErrors[[2]string{pgErr.Code, pgErr.ConstraintName}]
+	}
 	return nil
 }
 
-type txKey struct{}
+// RetryExhaustedError is returned by RunAtomic when every attempt
+// failed with a retryable error (serialization failure or deadlock)
+type RetryExhaustedError = db.RetryExhaustedError
+
+// IsTimeout reports whether a query was cut short by a deadline: the context's, its own
+// (ErrQueryTimeout), or the server's statement_timeout (query_canceled, 57014, which a
+// cancelled context also triggers)
+func IsTimeout(err error) bool {
+	if errors.Is(err, ErrQueryTimeout) || errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// RunAtomic executes a function within a transaction, see db.Pgx. Every repository
+// sharing the pool joins it through executorFromContext.
+func (r *ShopRepository) RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, inTx := db.TxFromContext(ctx); inTx || r.cache == nil {
+		return r.uow.RunAtomic(ctx, fn)
+	}
+	ctx, stale := withStaleEntries(ctx)
+	defer stale.drop()
+	return r.uow.RunAtomic(ctx, fn)
+}
 
 func (r *ShopRepository) getExecutor(ctx context.Context) PgxExecutor {
-	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
-		return tx
+	return executorFromContext(ctx, r.db)
+}
+
+// queries runs the typed queries generated from queries/shop.sql on the executor of ctx
+func (r *ShopRepository) queries(ctx context.Context) *shopdb.Queries {
+	return shopdb.New(r.getExecutor(ctx))
+}
+
+// executorFromContext returns the transaction started by RunAtomic if ctx carries one,
+// so any repository sharing the pool joins the same transaction. Queries are bounded
+// by the ctx's WithQueryTimeout.
+func executorFromContext(ctx context.Context, pool *pgxpool.Pool) PgxExecutor {
+	var exec PgxExecutor = pool
+	if tx, ok := db.TxFromContext(ctx); ok {
+		exec = tx
 	}
-	return r.db
+	if d := queryTimeoutFrom(ctx); d > 0 {
+		return timeoutExecutor{PgxExecutor: exec, timeout: d}
+	}
+	return exec
 }
 
 // PgxExecutor is an interface that matches both *pgx.Conn/Pool and pgx.Tx
@@ -83,58 +161,1125 @@ type PgxExecutor interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (commandTag pgconn.CommandTag, err error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 }
 
 // GetItemForUpdate locks the item row and returns item data
-func (r *ShopRepository) GetItemForUpdate(ctx context.Context, itemID int) (float64, int, error) {
-	var price float64
-	var stock int
-	err := r.getExecutor(ctx).QueryRow(ctx, "SELECT price, stock FROM items WHERE id = $1 FOR UPDATE", itemID).Scan(&price, &stock)
+func (r *ShopRepository) GetItemForUpdate(ctx context.Context, itemID int) (model.Money, int, error) {
+	item, err := r.queries(ctx).GetItemForUpdate(ctx, itemID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, 0, errors.New("item not found")
+			return model.Money{}, 0, errors.New("item not found")
 		}
-		return 0, 0, fmt.Errorf("failed to get item: %w", err)
+		return model.Money{}, 0, fmt.Errorf("failed to get item: %w", err)
 	}
-	return price, stock, nil
+	return item.Price, item.Stock, nil
 }
 
 // GetUserForUpdate locks the user row and returns balance
-func (r *ShopRepository) GetUserForUpdate(ctx context.Context, userID int) (float64, error) {
-	var balance float64
-	err := r.getExecutor(ctx).QueryRow(ctx, "SELECT balance FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&balance)
+func (r *ShopRepository) GetUserForUpdate(ctx context.Context, userID int) (model.Money, error) {
+	balance, err := r.queries(ctx).GetUserForUpdate(ctx, userID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, errors.New("user not found")
+			return model.Money{}, errors.New("user not found")
 		}
-		return 0, fmt.Errorf("failed to get user balance: %w", err)
+		return model.Money{}, fmt.Errorf("failed to get user balance: %w", err)
 	}
 	return balance, nil
 }
 
 // UpdateItemStock updates the stock of an item
 func (r *ShopRepository) UpdateItemStock(ctx context.Context, itemID int, quantity int) error {
-	_, err := r.getExecutor(ctx).Exec(ctx, "UPDATE items SET stock = stock - $1 WHERE id = $2", quantity, itemID)
+	err := r.queries(ctx).UpdateItemStock(ctx, shopdb.UpdateItemStockParams{Quantity: quantity, ID: itemID})
 	if err != nil {
+		if domainErr := constraintError(err); domainErr != nil {
+			return domainErr
+		}
 		return fmt.Errorf("failed to update item stock: %w", err)
 	}
+	r.cache.itemChanged(ctx, itemID)
 	return nil
 }
 
-// UpdateUserBalance updates the balance of a user
-func (r *ShopRepository) UpdateUserBalance(ctx context.Context, userID int, amount float64) error {
-	_, err := r.getExecutor(ctx).Exec(ctx, "UPDATE users SET balance = balance - $1 WHERE id = $2", amount, userID)
+// RestockItem applies a stock movement to its item and records it, setting its id and
+// the stock after it. It reports false, changing nothing, when the movement's reference
+// was already applied.
+func (r *ShopRepository) RestockItem(ctx context.Context, m *model.StockMovement) (bool, error) {
+	q := r.queries(ctx)
+	row, err := q.RestockItem(ctx, shopdb.RestockItemParams{
+		Quantity: m.Quantity, ItemID: m.ItemID, Source: m.Source, Reference: m.Reference, Reason: m.Reason,
+	})
+	if err == nil {
+		m.ID, m.StockAfter, m.CreatedAt = row.ID, row.StockAfter, row.CreatedAt
+		r.cache.itemChanged(ctx, m.ItemID)
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("failed to restock item: %w", err)
+	}
+
+	applied, err := q.StockMovementApplied(ctx, shopdb.StockMovementAppliedParams{Source: m.Source, Reference: m.Reference})
+	if err != nil {
+		return false, fmt.Errorf("failed to restock item: %w", err)
+	}
+	if !applied {
+		return false, errors.New("item not found")
+	}
+	return false, nil
+}
+
+// FindItemIDByName returns the id of the item with the name
+func (r *ShopRepository) FindItemIDByName(ctx context.Context, name string) (int, error) {
+	ids, err := r.queries(ctx).FindItemIDsByName(ctx, name)
 	if err != nil {
+		return 0, fmt.Errorf("failed to find item: %w", err)
+	}
+	switch len(ids) {
+	case 0:
+		return 0, errors.New("item not found")
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%w %q", ErrAmbiguousItemName, name)
+	}
+}
+
+// UpdateUserBalance debits a purchase of amount from the user's balance through the ledger
+func (r *ShopRepository) UpdateUserBalance(ctx context.Context, userID int, amount model.Money) error {
+	if _, err := r.AdjustUserBalance(ctx, userID, amount.Neg(), model.LedgerKindPurchase, ""); err != nil {
 		return fmt.Errorf("failed to update user balance: %w", err)
 	}
 	return nil
 }
 
-// CreateOrder inserts a new order
-func (r *ShopRepository) CreateOrder(ctx context.Context, userID, itemID int, price float64, quantity int) error {
-	_, err := r.getExecutor(ctx).Exec(ctx, "INSERT INTO orders (user_id, item_id, price, quantity) VALUES ($1, $2, $3, $4)", userID, itemID, price, quantity)
+// insertOrderValuesSQL is CreateOrder's insert, for the batched purchase
+const insertOrderValuesSQL = `
+	INSERT INTO orders (user_id, item_id, price, quantity, promo_code_id, discount, status, paid_at, client_order_id, unit_price,
+		tax_rate, tax_amount, quote_id, drop_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $7 = 'paid' THEN NOW() END, NULLIF($8, ''), $9, $10, $11, NULLIF($12, ''), $13)`
+
+// insertPurchaseSQL inserts an order and posts its price to the ledger
+const insertPurchaseSQL = `
+	WITH created AS (` + insertOrderValuesSQL + `
+		RETURNING id, user_id, tenant_id, price, created_at, paid_at
+	), ` + ledgerPurchaseSQL + `
+	SELECT id, created_at, paid_at FROM created`
+
+func insertOrderArgs(order *model.Order) []any {
+	if order.Status == "" {
+		order.Status = model.OrderStatusPaid
+	}
+	return []any{order.UserID, order.ItemID, order.Price, order.Quantity, order.PromoCodeID, order.Discount, order.Status, order.ClientOrderID, order.UnitPrice,
+		order.TaxRate, order.TaxAmount, order.QuoteID, order.DropID}
+}
+
+// CreateOrder inserts a new order and returns its id
+func (r *ShopRepository) CreateOrder(ctx context.Context, order *model.Order) (int, error) {
+	if order.Status == "" {
+		order.Status = model.OrderStatusPaid
+	}
+	row, err := r.queries(ctx).CreateOrder(ctx, shopdb.CreateOrderParams{
+		UserID: order.UserID, ItemID: order.ItemID, Price: order.Price, Quantity: order.Quantity,
+		PromoCodeID: order.PromoCodeID, Discount: order.Discount, Status: order.Status, ClientOrderID: order.ClientOrderID,
+		UnitPrice: order.UnitPrice, TaxRate: order.TaxRate, TaxAmount: order.TaxAmount, QuoteID: order.QuoteID, DropID: order.DropID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create order: %w", err)
+	}
+	order.ID, order.CreatedAt, order.PaidAt = row.ID, row.CreatedAt, row.PaidAt
+	return order.ID, nil
+}
+
+const grantInventorySQL = `
+	INSERT INTO inventories (user_id, item_id, quantity) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, item_id) DO UPDATE SET quantity = inventories.quantity + EXCLUDED.quantity, updated_at = NOW()`
+
+// GrantInventory adds quantity of the item to the user's inventory
+func (r *ShopRepository) GrantInventory(ctx context.Context, userID, itemID, quantity int) error {
+	if _, err := r.getExecutor(ctx).Exec(ctx, grantInventorySQL, userID, itemID, quantity); err != nil {
+		return fmt.Errorf("failed to grant inventory: %w", err)
+	}
+	return nil
+}
+
+// RevokeInventory takes up to quantity of the item back from the user's inventory.
+// Whatever the user gave away in the meantime stays with its recipients.
+func (r *ShopRepository) RevokeInventory(ctx context.Context, userID, itemID, quantity int) error {
+	err := r.queries(ctx).RevokeInventory(ctx, shopdb.RevokeInventoryParams{Quantity: quantity, UserID: userID, ItemID: itemID})
+	if err != nil {
+		return fmt.Errorf("failed to revoke inventory: %w", err)
+	}
+	return nil
+}
+
+// Statements of the batched purchase, see also PreparePurchaseStatements
+const (
+	lockPurchaseItemSQL = "SELECT item_unit_price(id, price, $2), stock FROM items WHERE id = $1 FOR UPDATE"
+	lockPurchaseUserSQL = "SELECT balance, status FROM users WHERE id = $1 FOR UPDATE"
+	takeStockSQL        = "UPDATE items SET stock = stock - $1 WHERE id = $2"
+)
+
+// LockPurchaseRows locks the item row, then the user row, in a single round-trip and
+// returns the unit price of quantity items after price tiers, the item stock and the
+// user balance. The lock order matches GetItemForUpdate followed by GetUserForUpdate.
+func (r *ShopRepository) LockPurchaseRows(ctx context.Context, itemID, userID, quantity int) (model.Money, int, model.Money, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(lockPurchaseItemSQL, itemID, quantity)
+	batch.Queue(lockPurchaseUserSQL, userID)
+
+	results := r.getExecutor(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	var price, balance model.Money
+	var stock int
+	var status string
+	if err := results.QueryRow().Scan(&price, &stock); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Money{}, 0, model.Money{}, errors.New("item not found")
+		}
+		return model.Money{}, 0, model.Money{}, fmt.Errorf("failed to get item: %w", err)
+	}
+	if err := results.QueryRow().Scan(&balance, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Money{}, 0, model.Money{}, errors.New("user not found")
+		}
+		return model.Money{}, 0, model.Money{}, fmt.Errorf("failed to get user balance: %w", err)
+	}
+	if status != model.UserStatusActive {
+		return model.Money{}, 0, model.Money{}, ErrUserInactive
+	}
+	return price, stock, balance, nil
+}
+
+// GetUnitPrice returns the per-unit price of quantity units of the item, after quantity
+// tiers, and its stock, without locking the row
+func (r *ShopRepository) GetUnitPrice(ctx context.Context, itemID, quantity int) (model.Money, int, error) {
+	row, err := r.queries(ctx).GetUnitPrice(ctx, shopdb.GetUnitPriceParams{Quantity: quantity, ID: itemID})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Money{}, 0, errors.New("item not found")
+		}
+		return model.Money{}, 0, fmt.Errorf("failed to get item price: %w", err)
+	}
+	return row.UnitPrice, row.Stock, nil
+}
+
+// ApplyPurchase takes order.Quantity from stock, inserts the order, debits the user by
+// order.Price through the ledger and adds the quantity to the user's inventory in a
+// single round-trip. The rows must be locked by the caller.
+func (r *ShopRepository) ApplyPurchase(ctx context.Context, order *model.Order) error {
+	defer r.cache.itemChanged(ctx, order.ItemID)
+	defer r.cache.userChanged(ctx, order.UserID)
+
+	batch := &pgx.Batch{}
+	batch.Queue(takeStockSQL, order.Quantity, order.ItemID)
+	batch.Queue(insertPurchaseSQL, insertOrderArgs(order)...)
+	batch.Queue(grantInventorySQL, order.UserID, order.ItemID, order.Quantity)
+
+	results := r.getExecutor(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	if _, err := results.Exec(); err != nil {
+		if domainErr := constraintError(err); domainErr != nil {
+			return domainErr
+		}
+		return fmt.Errorf("failed to update item stock: %w", err)
+	}
+	if err := results.QueryRow().Scan(&order.ID, &order.CreatedAt, &order.PaidAt); err != nil {
+		if domainErr := constraintError(err); domainErr != nil {
+			return domainErr
+		}
 		return fmt.Errorf("failed to create order: %w", err)
 	}
+	if _, err := results.Exec(); err != nil {
+		return fmt.Errorf("failed to grant inventory: %w", err)
+	}
+	return results.Close()
+}
+
+// purchaseSQL locks, validates, decrements, inserts, grants and debits through the ledger
+// in one statement. The modifying CTEs only run when the checks pass and the item is in
+// no drop; the final row reports which check failed and the balance and stock read
+// before the purchase. The
+// planner decides which row is locked first, deadlocks with concurrent purchases are
+// retried by RunAtomic.
+const purchaseSQL = `
+	WITH item AS (
+		SELECT id, item_unit_price(id, price, $3::int) AS unit_price, stock,
+			EXISTS (SELECT 1 FROM drops d WHERE d.item_id = items.id AND (d.ends_at IS NULL OR d.ends_at > NOW())) AS in_drop
+		FROM items WHERE id = $2 FOR UPDATE
+	), buyer AS (
+		SELECT id, balance, status = 'active' AS active FROM users WHERE id = $1 FOR UPDATE
+	), checked AS (
+		SELECT item.id AS item_id, buyer.id AS user_id, item.unit_price, item.unit_price * $3::int AS total, buyer.active,
+			item.stock >= $3::int AS in_stock, buyer.balance >= item.unit_price * $3::int AS funded, item.in_drop
+		FROM item CROSS JOIN buyer
+	), take AS (
+		UPDATE items i SET stock = i.stock - $3::int
+		FROM checked c WHERE i.id = c.item_id AND c.active AND c.in_stock AND c.funded AND NOT c.in_drop
+	), created AS (
+		INSERT INTO orders (user_id, item_id, price, quantity, status, paid_at, client_order_id, unit_price)
+		SELECT c.user_id, c.item_id, c.total, $3::int, 'paid', NOW(), NULLIF($4, ''), c.unit_price
+		FROM checked c WHERE c.active AND c.in_stock AND c.funded AND NOT c.in_drop
+		RETURNING id, user_id, tenant_id, price, unit_price, created_at, paid_at
+	), granted AS (
+		INSERT INTO inventories (user_id, item_id, quantity)
+		SELECT c.user_id, c.item_id, $3::int
+		FROM checked c WHERE c.active AND c.in_stock AND c.funded AND NOT c.in_drop
+		ON CONFLICT (user_id, item_id) DO UPDATE SET quantity = inventories.quantity + EXCLUDED.quantity, updated_at = NOW()
+	), ` + ledgerPurchaseSQL + `
+	SELECT EXISTS (SELECT 1 FROM item), EXISTS (SELECT 1 FROM buyer), COALESCE((SELECT active FROM buyer), false),
+		COALESCE((SELECT in_stock FROM checked), false), COALESCE((SELECT funded FROM checked), false),
+		COALESCE((SELECT in_drop FROM item), false),
+		COALESCE((SELECT balance FROM buyer), 0), COALESCE((SELECT stock FROM item), 0),
+		(SELECT id FROM created), (SELECT price FROM created), (SELECT unit_price FROM created),
+		(SELECT created_at FROM created), (SELECT paid_at FROM created)`
+
+// PurchaseSingleStatement executes a whole purchase of order.Quantity of order.ItemID by
+// order.UserID as one SQL statement, an alternative to locking the rows and applying the
+// purchase in separate steps that holds the row locks for a single round-trip.
+// It fills in the order's id, prices, status and timestamps and returns the user balance
+// and item stock read before the purchase. Promo codes and purchase limits are not
+// supported, nor are drops: items in a drop fail with ErrItemInDrop.
+func (r *ShopRepository) PurchaseSingleStatement(ctx context.Context, order *model.Order) (model.Money, int, error) {
+	var itemFound, userFound, active, inStock, funded, inDrop bool
+	var balance model.Money
+	var stock int
+	var orderID *int
+	var price *model.Money
+	var unitPrice *model.Money
+	var createdAt *time.Time
+	defer r.cache.itemChanged(ctx, order.ItemID)
+	defer r.cache.userChanged(ctx, order.UserID)
+	err := r.getExecutor(ctx).QueryRow(ctx, purchaseSQL, order.UserID, order.ItemID, order.Quantity, order.ClientOrderID).
+		Scan(&itemFound, &userFound, &active, &inStock, &funded, &inDrop, &balance, &stock, &orderID, &price, &unitPrice, &createdAt, &order.PaidAt)
+	if err != nil {
+		if domainErr := constraintError(err); domainErr != nil {
+			return model.Money{}, 0, domainErr
+		}
+		return model.Money{}, 0, fmt.Errorf("failed to purchase item: %w", err)
+	}
+
+	switch {
+	case !itemFound:
+		return model.Money{}, 0, errors.New("item not found")
+	case !userFound:
+		return model.Money{}, 0, errors.New("user not found")
+	case inDrop:
+		return model.Money{}, 0, ErrItemInDrop
+	case !active:
+		return model.Money{}, 0, ErrUserInactive
+	case !inStock:
+		return model.Money{}, 0, ErrInsufficientStock
+	case !funded:
+		return model.Money{}, 0, ErrInsufficientFunds
+	case orderID == nil:
+		return model.Money{}, 0, errors.New("failed to purchase item: order was not created")
+	}
+
+	order.ID = *orderID
+	order.Price = *price
+	order.UnitPrice = *unitPrice
+	order.CreatedAt = *createdAt
+	order.Status = model.OrderStatusPaid
+	return balance, stock, nil
+}
+
+// CreateUser inserts a user and sets its id
+func (r *ShopRepository) CreateUser(ctx context.Context, user *model.User) error {
+	row, err := r.queries(ctx).CreateUser(ctx, shopdb.CreateUserParams{
+		FirstName: user.FirstName, LastName: user.LastName, Balance: user.Balance, Email: user.Email,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	user.ID, user.Status = row.ID, row.Status
+	return nil
+}
+
+// CreateItem inserts an item and sets its id
+func (r *ShopRepository) CreateItem(ctx context.Context, item *model.Item) error {
+	var err error
+	item.ID, err = r.queries(ctx).CreateItem(ctx, shopdb.CreateItemParams{Name: item.Name, Price: item.Price, Stock: item.Stock})
+	if err != nil {
+		return fmt.Errorf("failed to create item: %w", err)
+	}
+	r.cache.itemChanged(ctx, item.ID)
+	return nil
+}
+
+// ListPriceTiers returns the item's price tiers by ascending min_qty
+func (r *ShopRepository) ListPriceTiers(ctx context.Context, itemID int) ([]model.PriceTier, error) {
+	rows, err := r.queries(ctx).ListPriceTiers(ctx, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price tiers: %w", err)
+	}
+
+	tiers := make([]model.PriceTier, len(rows))
+	for i, row := range rows {
+		tiers[i] = model.PriceTier{ItemID: row.ItemID, MinQty: row.MinQty, UnitPrice: row.UnitPrice}
+	}
+	return tiers, nil
+}
+
+// ReplacePriceTiers replaces all price tiers of the item. Run it inside RunAtomic so
+// purchases never see the item without tiers.
+func (r *ShopRepository) ReplacePriceTiers(ctx context.Context, itemID int, tiers []model.PriceTier) error {
+	q := r.queries(ctx)
+
+	if err := q.DeletePriceTiers(ctx, itemID); err != nil {
+		return fmt.Errorf("failed to clear price tiers: %w", err)
+	}
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	minQty := make([]int, len(tiers))
+	unitPrice := make([]model.Money, len(tiers))
+	for i, t := range tiers {
+		minQty[i], unitPrice[i] = t.MinQty, t.UnitPrice
+	}
+	err := q.InsertPriceTiers(ctx, shopdb.InsertPriceTiersParams{ItemID: itemID, MinQtys: minQty, UnitPrices: unitPrice})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("item not found")
+		}
+		return fmt.Errorf("failed to set price tiers: %w", err)
+	}
+	return nil
+}
+
+// SnapshotItemPrices copies the current price and stock of every item into item_price_snapshots
+func (r *ShopRepository) SnapshotItemPrices(ctx context.Context) (int64, error) {
+	n, err := r.queries(ctx).SnapshotItemPrices(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot item prices: %w", err)
+	}
+	return n, nil
+}
+
+const orderColumns = "id, user_id, item_id, price, COALESCE(unit_price, 0), quantity, promo_code_id, discount, tax_rate, tax_amount, status, COALESCE(client_order_id, ''), COALESCE(quote_id, ''), drop_id, refund_decision, created_at, paid_at, fulfilled_at, refunded_at, cancelled_at"
+
+func scanOrder(row pgx.Row) (*model.Order, error) {
+	var o model.Order
+	err := row.Scan(&o.ID, &o.UserID, &o.ItemID, &o.Price, &o.UnitPrice, &o.Quantity, &o.PromoCodeID, &o.Discount,
+		&o.TaxRate, &o.TaxAmount, &o.Status, &o.ClientOrderID, &o.QuoteID, &o.DropID, &o.RefundDecision, &o.CreatedAt, &o.PaidAt, &o.FulfilledAt, &o.RefundedAt, &o.CancelledAt)
+	return &o, err
+}
+
+// orderFromRow converts an order read by the typed queries, whose rows share GetOrderRow's columns
+func orderFromRow(row shopdb.GetOrderRow) *model.Order {
+	return &model.Order{
+		ID: row.ID, UserID: row.UserID, ItemID: row.ItemID, Price: row.Price, UnitPrice: row.UnitPrice, Quantity: row.Quantity,
+		PromoCodeID: row.PromoCodeID, Discount: row.Discount, TaxRate: row.TaxRate, TaxAmount: row.TaxAmount, Status: row.Status,
+		ClientOrderID: row.ClientOrderID, QuoteID: row.QuoteID, DropID: row.DropID, RefundDecision: row.RefundDecision,
+		CreatedAt: row.CreatedAt, PaidAt: row.PaidAt, FulfilledAt: row.FulfilledAt, RefundedAt: row.RefundedAt, CancelledAt: row.CancelledAt,
+	}
+}
+
+// GetOrderByClientOrderID returns the user's order created with clientOrderID
+func (r *ShopRepository) GetOrderByClientOrderID(ctx context.Context, userID int, clientOrderID string) (*model.Order, error) {
+	row, err := r.queries(ctx).GetOrderByClientOrderID(ctx, shopdb.GetOrderByClientOrderIDParams{UserID: userID, ClientOrderID: clientOrderID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return orderFromRow(shopdb.GetOrderRow(row)), nil
+}
+
+// GetOrder returns the order
+func (r *ShopRepository) GetOrder(ctx context.Context, orderID int) (*model.Order, error) {
+	row, err := r.queries(ctx).GetOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return orderFromRow(row), nil
+}
+
+// GetOrderForUpdate locks the order row and returns it
+func (r *ShopRepository) GetOrderForUpdate(ctx context.Context, orderID int) (*model.Order, error) {
+	row, err := r.queries(ctx).GetOrderForUpdate(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return orderFromRow(shopdb.GetOrderRow(row)), nil
+}
+
+// SetRefundDecision records the refund policy's verdict on the order
+func (r *ShopRepository) SetRefundDecision(ctx context.Context, orderID int, d *model.RefundDecision) error {
+	n, err := r.queries(ctx).SetRefundDecision(ctx, shopdb.SetRefundDecisionParams{RefundDecision: d, ID: orderID})
+	if err != nil {
+		return fmt.Errorf("failed to record refund decision: %w", err)
+	}
+	if n == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+// GetItemCategory returns the slug of the item's category, "" when it has none
+func (r *ShopRepository) GetItemCategory(ctx context.Context, itemID int) (string, error) {
+	slug, err := r.queries(ctx).GetItemCategory(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("item not found")
+		}
+		return "", fmt.Errorf("failed to get item category: %w", err)
+	}
+	return slug, nil
+}
+
+// UpdateOrderStatus sets the order status and its transition timestamp (paid_at for paid,
+// and so on) and returns the updated order. Transition rules are enforced by the service layer.
+func (r *ShopRepository) UpdateOrderStatus(ctx context.Context, orderID int, status string) (*model.Order, error) {
+	row, err := r.queries(ctx).UpdateOrderStatus(ctx, shopdb.UpdateOrderStatusParams{Status: status, ID: orderID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	return orderFromRow(shopdb.GetOrderRow(row)), nil
+}
+
+var itemSortColumns = map[string]sortColumn{
+	"id":    {"i.id", "int"},
+	"name":  {"i.name", "text"},
+	"price": {"i.price", "numeric"},
+	"stock": {"i.stock", "int"},
+}
+
+// ListItems returns shop items matching the filter, sorted and keyset-paginated by opts.
+// With a limit it returns up to opts.Limit+1 rows, the extra row signalling a next page.
+func (r *ShopRepository) ListItems(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) ([]model.Item, error) {
+	if items, ok := r.cache.getList(ctx, filter, opts); ok {
+		return items, nil
+	}
+
+	tags := filter.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	keyset, orderBy, keysetArgs, err := keysetClause(opts, itemSortColumns, "id", 3)
+	if err != nil {
+		return nil, err
+	}
+	if keyset != "" {
+		keyset = " AND " + keyset
+	}
+
+	rows, err := r.getExecutor(ctx).Query(ctx, `
+		SELECT i.id, i.name, i.price, i.stock, COALESCE(c.slug, ''),
+			COALESCE(array_agg(t.name ORDER BY t.name) FILTER (WHERE t.name IS NOT NULL), '{}'),
+			i.description, COALESCE(i.image_url, ''), i.attributes
+		FROM items i
+		LEFT JOIN categories c ON c.id = i.category_id
+		LEFT JOIN item_tags it ON it.item_id = i.id
+		LEFT JOIN tags t ON t.id = it.tag_id
+		WHERE ($1 = '' OR c.slug = $1)`+keyset+`
+		GROUP BY i.id, c.slug
+		HAVING cardinality($2::text[]) = 0 OR array_agg(t.name) @> $2::text[]`+orderBy+limitClause(opts.Limit),
+		append([]any{filter.Category, tags}, keysetArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.Item{}
+	for rows.Next() {
+		var item model.Item
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Stock, &item.Category, &item.Tags,
+			&item.Description, &item.ImageURL, &item.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	r.cache.setList(ctx, filter, opts, items)
+	return items, nil
+}
+
+// GetItem returns an item by id with its details, without its category and tags
+func (r *ShopRepository) GetItem(ctx context.Context, itemID int) (*model.Item, error) {
+	if item, ok := r.cache.getItem(ctx, itemID); ok {
+		return &item, nil
+	}
+
+	row, err := r.queries(ctx).GetItem(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("item not found")
+		}
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	item := model.Item{
+		ID: row.ID, Name: row.Name, Price: row.Price, Stock: row.Stock, Description: row.Description, ImageURL: row.ImageUrl,
+	}
+	if err := json.Unmarshal(row.Attributes, &item.Attributes); err != nil {
+		return nil, fmt.Errorf("failed to decode item attributes: %w", err)
+	}
+	r.cache.setItem(ctx, item)
+	return &item, nil
+}
+
+// UpdateItemMetadata updates the item's details, leaving nil fields unchanged. Setting
+// the image URL drops an uploaded image; its storage key is returned for cleanup.
+func (r *ShopRepository) UpdateItemMetadata(ctx context.Context, itemID int, m model.ItemMetadata) (string, error) {
+	var attributes json.RawMessage
+	if m.Attributes != nil {
+		var err error
+		if attributes, err = json.Marshal(m.Attributes); err != nil {
+			return "", fmt.Errorf("failed to encode item attributes: %w", err)
+		}
+	}
+	previousKey, err := r.queries(ctx).UpdateItemMetadata(ctx, shopdb.UpdateItemMetadataParams{
+		ID: itemID, Description: m.Description, ImageUrl: m.ImageURL, Attributes: attributes,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("item not found")
+		}
+		return "", fmt.Errorf("failed to update item metadata: %w", err)
+	}
+	r.cache.itemChanged(ctx, itemID)
+	return previousKey, nil
+}
+
+// SetItemImage points the item at an uploaded image stored under key, served from url.
+// It returns the storage key of the image it replaces, "" if there is none.
+func (r *ShopRepository) SetItemImage(ctx context.Context, itemID int, key, url string) (string, error) {
+	previousKey, err := r.queries(ctx).SetItemImage(ctx, shopdb.SetItemImageParams{ID: itemID, ImageKey: key, ImageUrl: url})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("item not found")
+		}
+		return "", fmt.Errorf("failed to set item image: %w", err)
+	}
+	r.cache.itemChanged(ctx, itemID)
+	return previousKey, nil
+}
+
+// GetItemImageKey returns the storage key of the item's uploaded image, "" if it has none
+func (r *ShopRepository) GetItemImageKey(ctx context.Context, itemID int) (string, error) {
+	key, err := r.queries(ctx).GetItemImageKey(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("item not found")
+		}
+		return "", fmt.Errorf("failed to get item image: %w", err)
+	}
+	return key, nil
+}
+
+// GetUser returns a user by id
+func (r *ShopRepository) GetUser(ctx context.Context, userID int) (*model.User, error) {
+	if user, ok := r.cache.getUser(ctx, userID); ok {
+		return &user, nil
+	}
+
+	row, err := r.queries(ctx).GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user := model.User{
+		ID: row.ID, FirstName: row.FirstName, LastName: row.LastName, Balance: row.Balance,
+		Email: row.Email, Region: row.Region, Status: row.Status,
+	}
+	r.cache.setUser(ctx, user)
+	return &user, nil
+}
+
+// SetUserEmail sets the user's notification address, an empty email removes it
+func (r *ShopRepository) SetUserEmail(ctx context.Context, userID int, email string) error {
+	n, err := r.queries(ctx).SetUserEmail(ctx, shopdb.SetUserEmailParams{Email: email, ID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to set user email: %w", err)
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	r.cache.userChanged(ctx, userID)
+	return nil
+}
+
+// SetUserRegion sets the user's tax region, an empty region removes it
+func (r *ShopRepository) SetUserRegion(ctx context.Context, userID int, region string) error {
+	n, err := r.queries(ctx).SetUserRegion(ctx, shopdb.SetUserRegionParams{Region: region, ID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to set user region: %w", err)
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	r.cache.userChanged(ctx, userID)
+	return nil
+}
+
+// LockUserStatus locks the user row and returns the user's status
+func (r *ShopRepository) LockUserStatus(ctx context.Context, userID int) (string, error) {
+	status, err := r.queries(ctx).LockUserStatus(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("user not found")
+		}
+		return "", fmt.Errorf("failed to get user status: %w", err)
+	}
+	return status, nil
+}
+
+// SetUserStatus activates or deactivates the user
+func (r *ShopRepository) SetUserStatus(ctx context.Context, userID int, status string) error {
+	n, err := r.queries(ctx).SetUserStatus(ctx, shopdb.SetUserStatusParams{Status: status, ID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to set user status: %w", err)
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	r.cache.userChanged(ctx, userID)
+	return nil
+}
+
+// HasPendingPayouts reports whether a payout of the user is still waiting to be sent or being sent
+func (r *ShopRepository) HasPendingPayouts(ctx context.Context, userID int) (bool, error) {
+	pending, err := r.queries(ctx).HasPendingPayouts(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check pending payouts: %w", err)
+	}
+	return pending, nil
+}
+
+// anonymizeUserSQL erases the personal data of a user. Orders, payouts, deposits, ledger
+// entries and inventories are financial records and stay, minus the client-chosen order
+// keys and payout destinations. Audit snapshots of the user's profile and of the Steam
+// accounts and Telegram chats linked to it are cleared.
+var anonymizeUserSQL = []string{
+	`UPDATE users SET first_name = 'Deleted', last_name = 'User', email = NULL, region = NULL,
+		status = 'deleted', deleted_at = NOW() WHERE id = $1`,
+	"UPDATE orders SET client_order_id = NULL WHERE user_id = $1",
+	"UPDATE payouts SET destination = '[deleted]' WHERE user_id = $1",
+	"DELETE FROM favorites WHERE user_id = $1",
+	"DELETE FROM telegram_links WHERE user_id = $1",
+	"DELETE FROM telegram_link_tokens WHERE user_id = $1",
+	"DELETE FROM steam_accounts WHERE user_id = $1",
+	"DELETE FROM leaderboard_buyers WHERE user_id = $1",
+	"UPDATE waitlist_entries SET status = 'cancelled', closed_at = NOW() WHERE user_id = $1 AND status = 'waiting'",
+	`UPDATE audit_log SET before = NULL, after = NULL
+		WHERE entity_type = 'user' AND entity_id = $1::text AND action IN ('user.create', 'user.email', 'user.region',
+			'steam.link', 'steam.unlink', 'telegram.link', 'telegram.unlink')`,
+}
+
+// AnonymizeUser erases the user's personal data in one round-trip and marks the user
+// deleted, see anonymizeUserSQL
+func (r *ShopRepository) AnonymizeUser(ctx context.Context, userID int) error {
+	batch := &pgx.Batch{}
+	for _, sql := range anonymizeUserSQL {
+		batch.Queue(sql, userID)
+	}
+
+	results := r.getExecutor(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+
+	tag, err := results.Exec()
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+	for range anonymizeUserSQL[1:] {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+	}
+	r.cache.userChanged(ctx, userID)
+	return results.Close()
+}
+
+// AdjustUserBalance posts delta (which may be negative) to the user's balance as a
+// ledger transaction of kind, with the kind's system account as counterpart, refusing
+// to take the balance below zero. Returns the new balance.
+func (r *ShopRepository) AdjustUserBalance(ctx context.Context, userID int, delta model.Money, kind, reference string) (model.Money, error) {
+	account, ok := ledgerAccounts[kind]
+	if !ok {
+		return model.Money{}, fmt.Errorf("unknown ledger transaction kind %q", kind)
+	}
+
+	after, err := r.queries(ctx).AdjustUserBalance(ctx, shopdb.AdjustUserBalanceParams{
+		UserID: userID, Kind: kind, Reference: reference, Delta: delta, Account: account,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Money{}, errors.New("user not found")
+		}
+		if domainErr := constraintError(err); domainErr != nil {
+			return model.Money{}, domainErr
+		}
+		return model.Money{}, fmt.Errorf("failed to adjust user balance: %w", err)
+	}
+	if after.Amount < 0 {
+		return model.Money{}, ErrInsufficientFunds
+	}
+	r.cache.userChanged(ctx, userID)
+	return after, nil
+}
+
+// CreateBalanceAdjustment records a balance change and its reason
+func (r *ShopRepository) CreateBalanceAdjustment(ctx context.Context, userID int, delta model.Money, reason, source string) error {
+	err := r.queries(ctx).CreateBalanceAdjustment(ctx, shopdb.CreateBalanceAdjustmentParams{
+		UserID: userID, Delta: delta, Reason: reason, Source: source,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create balance adjustment: %w", err)
+	}
+	return nil
+}
+
+// RecordBalanceAdjustment records a manual balance change with its reason code,
+// filling in adj's ID and CreatedAt
+func (r *ShopRepository) RecordBalanceAdjustment(ctx context.Context, adj *model.BalanceAdjustment) error {
+	row, err := r.queries(ctx).RecordBalanceAdjustment(ctx, shopdb.RecordBalanceAdjustmentParams{
+		UserID: adj.UserID, Delta: adj.Delta, ReasonCode: adj.ReasonCode, Reason: adj.Reason, Source: adj.Source,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record balance adjustment: %w", err)
+	}
+	adj.ID, adj.CreatedAt = row.ID, row.CreatedAt
+	return nil
+}
+
+// PurchaseActivity summarises a user's recent orders for limit checks
+type PurchaseActivity struct {
+	OrdersLastMinute int
+	SpendLastDay     model.Money
+	ItemQuantity     int
+}
+
+// GetPurchaseActivity returns the user's order count in the last minute, spend in the
+// last 24 hours and total quantity bought of itemID. Call it after GetUserForUpdate
+// so concurrent purchases by the same user are serialized.
+func (r *ShopRepository) GetPurchaseActivity(ctx context.Context, userID, itemID int) (PurchaseActivity, error) {
+	row, err := r.queries(ctx).GetPurchaseActivity(ctx, shopdb.GetPurchaseActivityParams{ItemID: itemID, UserID: userID})
+	if err != nil {
+		return PurchaseActivity{}, fmt.Errorf("failed to get purchase activity: %w", err)
+	}
+	return PurchaseActivity{
+		OrdersLastMinute: row.OrdersLastMinute, SpendLastDay: row.SpendLastDay, ItemQuantity: row.ItemQuantity,
+	}, nil
+}
+
+var orderSortColumns = map[string]sortColumn{
+	"id":         {"id", "int"},
+	"price":      {"price", "numeric"},
+	"quantity":   {"quantity", "int"},
+	"created_at": {"created_at", "timestamp"},
+}
+
+// ListUserOrders returns the user's orders, sorted and keyset-paginated by opts
+// (up to opts.Limit+1 rows, see ListItems)
+func (r *ShopRepository) ListUserOrders(ctx context.Context, userID int, opts model.ListOptions) ([]model.Order, error) {
+	keyset, orderBy, keysetArgs, err := keysetClause(opts, orderSortColumns, "id", 2)
+	if err != nil {
+		return nil, err
+	}
+	if keyset != "" {
+		keyset = " AND " + keyset
+	}
+
+	rows, err := r.getExecutor(ctx).Query(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE user_id = $1`+keyset+orderBy+limitClause(opts.Limit),
+		append([]any{userID}, keysetArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []model.Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return orders, nil
+}
+
+// ClaimPendingReceipts locks up to limit orders whose receipt was not sent yet, oldest
+// first, skipping orders locked by another instance. Call it inside RunAtomic and mark
+// the receipts sent in the same transaction. Email and TelegramChatID are empty for
+// users without one, Stale is set for orders placed more than maxAge ago.
+func (r *ShopRepository) ClaimPendingReceipts(ctx context.Context, maxAge time.Duration, limit int) ([]model.PendingReceipt, error) {
+	rows, err := r.queries(ctx).ClaimPendingReceipts(ctx, shopdb.ClaimPendingReceiptsParams{MaxAge: maxAge, MaxReceipts: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim receipts: %w", err)
+	}
+
+	receipts := make([]model.PendingReceipt, len(rows))
+	for i, row := range rows {
+		receipts[i] = model.PendingReceipt{
+			Order: model.Order{
+				ID: row.ID, UserID: row.UserID, ItemID: row.ItemID, Price: row.Price, Quantity: row.Quantity,
+				Discount: row.Discount, Status: row.Status, CreatedAt: row.CreatedAt,
+			},
+			Email: row.Email, TelegramChatID: row.TelegramChatID, FirstName: row.FirstName, ItemName: row.ItemName, Stale: row.Stale,
+		}
+	}
+	return receipts, nil
+}
+
+// MarkReceiptsSent records that the orders' receipts were handled
+func (r *ShopRepository) MarkReceiptsSent(ctx context.Context, orderIDs []int) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	if err := r.queries(ctx).MarkReceiptsSent(ctx, orderIDs); err != nil {
+		return fmt.Errorf("failed to mark receipts sent: %w", err)
+	}
+	return nil
+}
+
+// ListUserOrderIDs returns the ids of all the user's orders, oldest first
+func (r *ShopRepository) ListUserOrderIDs(ctx context.Context, userID int) ([]int, error) {
+	ids, err := r.queries(ctx).ListUserOrderIDs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user orders: %w", err)
+	}
+	return ids, nil
+}
+
+// ExistingUserIDs returns which of the ids are users
+func (r *ShopRepository) ExistingUserIDs(ctx context.Context, ids []int) (map[int]bool, error) {
+	return existingIDs(ctx, r.queries(ctx).ExistingUserIDs, ids)
+}
+
+// ExistingItemIDs returns which of the ids are items
+func (r *ShopRepository) ExistingItemIDs(ctx context.Context, ids []int) (map[int]bool, error) {
+	return existingIDs(ctx, r.queries(ctx).ExistingItemIDs, ids)
+}
+
+func existingIDs(ctx context.Context, query func(context.Context, []int) ([]int, error), ids []int) (map[int]bool, error) {
+	existing := make(map[int]bool, len(ids))
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	found, err := query(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up ids: %w", err)
+	}
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// ClientOrderKey identifies an order by its buyer and client order id
+type ClientOrderKey struct {
+	UserID        int
+	ClientOrderID string
+}
+
+// ExistingClientOrderIDs returns which of the keys already belong to an order
+func (r *ShopRepository) ExistingClientOrderIDs(ctx context.Context, keys []ClientOrderKey) (map[ClientOrderKey]bool, error) {
+	existing := make(map[ClientOrderKey]bool, len(keys))
+	if len(keys) == 0 {
+		return existing, nil
+	}
+	userIDs := make([]int, len(keys))
+	clientOrderIDs := make([]string, len(keys))
+	for i, k := range keys {
+		userIDs[i], clientOrderIDs[i] = k.UserID, k.ClientOrderID
+	}
+
+	rows, err := r.queries(ctx).ExistingClientOrderIDs(ctx, shopdb.ExistingClientOrderIDsParams{
+		UserIds: userIDs, ClientOrderIds: clientOrderIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up client order ids: %w", err)
+	}
+	for _, row := range rows {
+		existing[ClientOrderKey{UserID: row.UserID, ClientOrderID: row.ClientOrderID}] = true
+	}
+	return existing, nil
+}
+
+// importOrderColumns are the columns CopyOrders fills, the rest keep their defaults
+var importOrderColumns = []string{
+	"user_id", "item_id", "price", "unit_price", "quantity", "status", "client_order_id", "created_at",
+	"paid_at", "fulfilled_at", "refunded_at", "cancelled_at", "receipt_sent_at",
+}
+
+// CopyOrders bulk-inserts historical orders with COPY. They are recorded as they are:
+// balances, stock, inventories and the ledger are left alone and no receipts are sent.
+func (r *ShopRepository) CopyOrders(ctx context.Context, orders []model.Order) (int64, error) {
+	n, err := r.getExecutor(ctx).CopyFrom(ctx, pgx.Identifier{"orders"}, importOrderColumns,
+		pgx.CopyFromSlice(len(orders), func(i int) ([]any, error) {
+			o := &orders[i]
+			var clientOrderID *string
+			if o.ClientOrderID != "" {
+				clientOrderID = &o.ClientOrderID
+			}
+			return []any{o.UserID, o.ItemID, o.Price, o.UnitPrice, o.Quantity, o.Status, clientOrderID, o.CreatedAt,
+				o.PaidAt, o.FulfilledAt, o.RefundedAt, o.CancelledAt, o.CreatedAt}, nil
+		}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy orders: %w", err)
+	}
+	return n, nil
+}
+
+// CountItemsByName returns how many items carry each of the names
+func (r *ShopRepository) CountItemsByName(ctx context.Context, names []string) (map[string]int, error) {
+	rows, err := r.queries(ctx).CountItemsByName(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count items: %w", err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Name] = int(row.Count)
+	}
+	return counts, nil
+}
+
+// CopyItems bulk-loads catalogue rows with COPY into a staging table and inserts them as
+// new items. With upsert, rows whose name matches an item update that item instead. The
+// names must be distinct, and with upsert match at most one item each. It must run inside
+// RunAtomic and returns the ids of the created and updated items by name.
+func (r *ShopRepository) CopyItems(ctx context.Context, items []model.ItemImport, upsert bool) (map[string]int, map[string]int, error) {
+	exec := r.getExecutor(ctx)
+	if _, err := exec.Exec(ctx, `
+		CREATE TEMP TABLE item_import (
+			name TEXT NOT NULL, price DECIMAL(10, 2) NOT NULL, stock INT NOT NULL,
+			description TEXT, category TEXT, image_url TEXT, tags TEXT[]
+		) ON COMMIT DROP`); err != nil {
+		return nil, nil, fmt.Errorf("failed to create item staging table: %w", err)
+	}
+	_, err := exec.CopyFrom(ctx, pgx.Identifier{"item_import"},
+		[]string{"name", "price", "stock", "description", "category", "image_url", "tags"},
+		pgx.CopyFromSlice(len(items), func(i int) ([]any, error) {
+			it := &items[i]
+			return []any{it.Name, it.Price, it.Stock, it.Description, it.Category, it.ImageURL, it.Tags}, nil
+		}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to copy items: %w", err)
+	}
+
+	updated := map[string]int{}
+	if upsert {
+		updated, err = collectItemIDs(exec.Query(ctx, `
+			UPDATE items i SET
+				price = s.price,
+				stock = s.stock,
+				description = COALESCE(s.description, i.description),
+				category_id = CASE WHEN s.category IS NULL THEN i.category_id ELSE c.id END,
+				image_url = CASE WHEN s.image_url IS NULL THEN i.image_url ELSE NULLIF(s.image_url, '') END,
+				image_key = CASE WHEN s.image_url IS NULL THEN i.image_key END
+			FROM item_import s
+			LEFT JOIN categories c ON c.slug = s.category
+			WHERE i.name = s.name
+			RETURNING i.id, i.name`))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update items: %w", err)
+		}
+	}
+
+	created, err := collectItemIDs(exec.Query(ctx, `
+		INSERT INTO items (name, price, stock, description, category_id, image_url)
+		SELECT s.name, s.price, s.stock, COALESCE(s.description, ''), c.id, NULLIF(s.image_url, '')
+		FROM item_import s
+		LEFT JOIN categories c ON c.slug = s.category
+		WHERE NOT $1 OR NOT EXISTS (SELECT 1 FROM items i WHERE i.name = s.name)
+		RETURNING id, name`, upsert))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to insert items: %w", err)
+	}
+
+	// Rows listing tags replace the item's tags
+	ids := make([]int, 0, len(created)+len(updated))
+	names := make([]string, 0, len(created)+len(updated))
+	for _, m := range []map[string]int{created, updated} {
+		for name, id := range m {
+			ids, names = append(ids, id), append(names, name)
+		}
+	}
+	batch := &pgx.Batch{}
+	batch.Queue("INSERT INTO tags (name) SELECT DISTINCT unnest(tags) FROM item_import ON CONFLICT (tenant_id, name) DO NOTHING")
+	batch.Queue(`
+		DELETE FROM item_tags it
+		USING unnest($1::int[], $2::text[]) AS m(id, name), item_import s
+		WHERE it.item_id = m.id AND s.name = m.name AND s.tags IS NOT NULL`, ids, names)
+	batch.Queue(`
+		INSERT INTO item_tags (item_id, tag_id)
+		SELECT DISTINCT m.id, t.id
+		FROM unnest($1::int[], $2::text[]) AS m(id, name)
+		JOIN item_import s ON s.name = m.name
+		JOIN tags t ON t.name = ANY(s.tags)`, ids, names)
+	if err := exec.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to set item tags: %w", err)
+	}
+	r.cache.itemChanged(ctx, 0)
+	return created, updated, nil
+}
+
+func collectItemIDs(rows pgx.Rows, err error) (map[string]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// ExportItemsCSV streams the catalogue as CSV with COPY, ordered by id. Tags are joined by
+// "|", the columns match what CopyItems loads.
+func (r *ShopRepository) ExportItemsCSV(ctx context.Context, w io.Writer) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Conn().PgConn().CopyTo(ctx, w, `
+		COPY (
+			SELECT i.id, i.name, i.price, i.stock, COALESCE(c.slug, '') AS category,
+				COALESCE((SELECT string_agg(t.name, '|' ORDER BY t.name) FROM item_tags it JOIN tags t ON t.id = it.tag_id
+					WHERE it.item_id = i.id), '') AS tags,
+				i.description, COALESCE(i.image_url, '') AS image_url
+			FROM items i
+			LEFT JOIN categories c ON c.id = i.category_id
+			ORDER BY i.id
+		) TO STDOUT WITH (FORMAT csv, HEADER true)`)
+	if err != nil {
+		return fmt.Errorf("failed to export items: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryExhaustedError(t *testing.T) {
+	cause := &pgconn.PgError{Code: "40P01"}
+	err := fmt.Errorf("buy: %w", &RetryExhaustedError{Attempts: 3, Err: cause})
+
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "40P01", pgErr.Code)
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.True(t, IsTimeout(fmt.Errorf("failed to list items: %w", context.DeadlineExceeded)))
+	assert.True(t, IsTimeout(&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}))
+	assert.False(t, IsTimeout(context.Canceled))
+	assert.False(t, IsTimeout(&pgconn.PgError{Code: "40001"}))
+	assert.False(t, IsTimeout(errors.New("item not found")))
+}
+
+func TestConstraintError(t *testing.T) {
+	err := fmt.Errorf("batch: %w", &pgconn.PgError{Code: "23514", ConstraintName: "users_balance_nonnegative"})
+	assert.Equal(t, ErrInsufficientFunds, constraintError(err))
+	assert.Equal(t, ErrInsufficientStock, constraintError(&pgconn.PgError{Code: "23514", ConstraintName: "items_stock_nonnegative"}))
+	assert.Equal(t, ErrDuplicateClientOrderID, constraintError(&pgconn.PgError{Code: "23505", ConstraintName: "orders_user_client_order_id_key"}))
+	assert.Nil(t, constraintError(&pgconn.PgError{Code: "23514", ConstraintName: "orders_status_check"}))
+	assert.Nil(t, constraintError(&pgconn.PgError{Code: "23505", ConstraintName: "users_balance_nonnegative"}))
+	assert.Nil(t, constraintError(errors.New("insufficient funds")))
+}
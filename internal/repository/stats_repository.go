@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StatsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewStatsRepository(db *pgxpool.Pool) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// GetSalesStats aggregates orders created in [from, to) directly from the orders table
+func (r *StatsRepository) GetSalesStats(ctx context.Context, from, to time.Time, topLimit int) (*model.SalesStats, error) {
+	stats := &model.SalesStats{From: from, To: to}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(price), 0), COUNT(*), COALESCE(SUM(quantity), 0), COUNT(DISTINCT user_id)
+		FROM orders
+		WHERE created_at >= $1 AND created_at < $2 AND `+countedOrder("status"), from, to).
+		Scan(&stats.Revenue, &stats.OrderCount, &stats.ItemsSold, &stats.ActiveUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate orders: %w", err)
+	}
+
+	stats.TopItems, err = r.queryTopItems(ctx, `
+		SELECT o.item_id, i.name, COUNT(*), SUM(o.quantity), SUM(o.price)
+		FROM orders o
+		JOIN items i ON i.id = o.item_id
+		WHERE o.created_at >= $1 AND o.created_at < $2 AND `+countedOrder("o.status")+`
+		GROUP BY o.item_id, i.name
+		ORDER BY SUM(o.price) DESC
+		LIMIT $3`, from, to, topLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetSalesStatsDaily aggregates whole days from the order_stats_daily materialized view.
+// Data is as fresh as the last RefreshSalesStats call; active users are still counted live
+// since distinct counts cannot be summed across days. The view has no row level security,
+// its rows are filtered to the connection's tenant explicitly.
+func (r *StatsRepository) GetSalesStatsDaily(ctx context.Context, from, to time.Time, topLimit int) (*model.SalesStats, error) {
+	stats := &model.SalesStats{From: from, To: to}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(revenue), 0), COALESCE(SUM(order_count), 0), COALESCE(SUM(quantity), 0)
+		FROM order_stats_daily
+		WHERE day >= date_trunc('day', $1::timestamp) AND day < $2 AND tenant_visible(tenant_id)`, from, to).
+		Scan(&stats.Revenue, &stats.OrderCount, &stats.ItemsSold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate daily stats: %w", err)
+	}
+
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT user_id) FROM orders WHERE created_at >= $1 AND created_at < $2 AND `+countedOrder("status"), from, to).
+		Scan(&stats.ActiveUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active users: %w", err)
+	}
+
+	stats.TopItems, err = r.queryTopItems(ctx, `
+		SELECT s.item_id, i.name, SUM(s.order_count), SUM(s.quantity), SUM(s.revenue)
+		FROM order_stats_daily s
+		JOIN items i ON i.id = s.item_id
+		WHERE s.day >= date_trunc('day', $1::timestamp) AND s.day < $2 AND tenant_visible(s.tenant_id)
+		GROUP BY s.item_id, i.name
+		ORDER BY SUM(s.revenue) DESC
+		LIMIT $3`, from, to, topLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (r *StatsRepository) queryTopItems(ctx context.Context, sql string, args ...any) ([]model.TopItemStats, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.TopItemStats{}
+	for rows.Next() {
+		var item model.TopItemStats
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.OrderCount, &item.Quantity, &item.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan top item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query top items: %w", err)
+	}
+	return items, nil
+}
+
+// RefreshSalesStats rebuilds the order_stats_daily materialized view without blocking readers.
+// The refresh is exempt from the connection's statement_timeout, the caller's context bounds it.
+func (r *StatsRepository) RefreshSalesStats(ctx context.Context) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to disable statement timeout: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY order_stats_daily"); err != nil {
+		return fmt.Errorf("failed to refresh order_stats_daily: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// countedOrder filters out refunded and cancelled orders, which no longer count as sales
+// (order_stats_daily applies the same filter)
+func countedOrder(statusColumn string) string {
+	return statusColumn + " NOT IN ('refunded', 'cancelled')"
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SteamRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSteamRepository(db *pgxpool.Pool) *SteamRepository {
+	return &SteamRepository{db: db}
+}
+
+// ErrSteamAccountNotFound is returned for users and SteamIDs without a linked Steam account
+var ErrSteamAccountNotFound = errors.New("steam account not found")
+
+const steamAccountColumns = "user_id, steam_id, COALESCE(persona_name, ''), COALESCE(profile_url, ''), COALESCE(avatar_url, ''), linked_at, updated_at"
+
+func scanSteamAccount(row pgx.Row) (*model.SteamAccount, error) {
+	var a model.SteamAccount
+	err := row.Scan(&a.UserID, &a.SteamID, &a.PersonaName, &a.ProfileURL, &a.AvatarURL, &a.LinkedAt, &a.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSteamAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get steam account: %w", err)
+	}
+	return &a, nil
+}
+
+// GetAccount returns the Steam account linked to the user
+func (r *SteamRepository) GetAccount(ctx context.Context, userID int) (*model.SteamAccount, error) {
+	return scanSteamAccount(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+steamAccountColumns+" FROM steam_accounts WHERE user_id = $1", userID))
+}
+
+// GetAccountBySteamID returns the account with the SteamID64, locking it
+func (r *SteamRepository) GetAccountBySteamID(ctx context.Context, steamID string) (*model.SteamAccount, error) {
+	return scanSteamAccount(executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT "+steamAccountColumns+" FROM steam_accounts WHERE steam_id = $1 FOR UPDATE", steamID))
+}
+
+// SaveAccount links the Steam account to account.UserID, replacing the user's previous
+// account, or refreshes its profile. Empty profile fields keep the stored ones.
+func (r *SteamRepository) SaveAccount(ctx context.Context, account *model.SteamAccount) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO steam_accounts (user_id, steam_id, persona_name, profile_url, avatar_url)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))
+		ON CONFLICT (user_id) DO UPDATE SET
+			steam_id = EXCLUDED.steam_id,
+			persona_name = COALESCE(EXCLUDED.persona_name, steam_accounts.persona_name),
+			profile_url = COALESCE(EXCLUDED.profile_url, steam_accounts.profile_url),
+			avatar_url = COALESCE(EXCLUDED.avatar_url, steam_accounts.avatar_url),
+			linked_at = CASE WHEN steam_accounts.steam_id = EXCLUDED.steam_id THEN steam_accounts.linked_at ELSE NOW() END,
+			updated_at = NOW()
+		RETURNING `+steamAccountColumns,
+		account.UserID, account.SteamID, account.PersonaName, account.ProfileURL, account.AvatarURL).
+		Scan(&account.UserID, &account.SteamID, &account.PersonaName, &account.ProfileURL, &account.AvatarURL,
+			&account.LinkedAt, &account.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch {
+			case pgErr.Code == "23505" && pgErr.ConstraintName == "steam_accounts_steam_id_key":
+				return errors.New("steam account is linked to another user")
+			case pgErr.Code == "23503":
+				return errors.New("user not found")
+			}
+		}
+		return fmt.Errorf("failed to save steam account: %w", err)
+	}
+	return nil
+}
+
+// DeleteAccount unlinks the user's Steam account
+func (r *SteamRepository) DeleteAccount(ctx context.Context, userID int) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, "DELETE FROM steam_accounts WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete steam account: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSteamAccountNotFound
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TelegramRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTelegramRepository(db *pgxpool.Pool) *TelegramRepository {
+	return &TelegramRepository{db: db}
+}
+
+// CreateLinkToken stores a link token hash for the user, valid for ttl, and drops the
+// user's expired tokens
+func (r *TelegramRepository) CreateLinkToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (time.Time, error) {
+	exec := executorFromContext(ctx, r.db)
+	if _, err := exec.Exec(ctx, "DELETE FROM telegram_link_tokens WHERE user_id = $1 AND expires_at < NOW()", userID); err != nil {
+		return time.Time{}, fmt.Errorf("failed to delete expired link tokens: %w", err)
+	}
+
+	var expiresAt time.Time
+	err := exec.QueryRow(ctx,
+		"INSERT INTO telegram_link_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, NOW() + $3::interval) RETURNING expires_at",
+		tokenHash, userID, ttl).Scan(&expiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return time.Time{}, errors.New("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to create link token: %w", err)
+	}
+	return expiresAt, nil
+}
+
+// ConsumeLinkToken deletes the token and returns its user, if it has not expired
+func (r *TelegramRepository) ConsumeLinkToken(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"DELETE FROM telegram_link_tokens WHERE token_hash = $1 AND expires_at > NOW() RETURNING user_id", tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("invalid or expired link token")
+		}
+		return 0, fmt.Errorf("failed to consume link token: %w", err)
+	}
+	return userID, nil
+}
+
+// Link links the chat to the user, replacing any previous link of either
+func (r *TelegramRepository) Link(ctx context.Context, userID int, chatID int64) error {
+	exec := executorFromContext(ctx, r.db)
+	if _, err := exec.Exec(ctx, "DELETE FROM telegram_links WHERE user_id = $1 OR chat_id = $2", userID, chatID); err != nil {
+		return fmt.Errorf("failed to replace telegram link: %w", err)
+	}
+	if _, err := exec.Exec(ctx, "INSERT INTO telegram_links (user_id, chat_id) VALUES ($1, $2)", userID, chatID); err != nil {
+		return fmt.Errorf("failed to link telegram chat: %w", err)
+	}
+	return nil
+}
+
+// Unlink removes the chat's link and returns the user it was linked to
+func (r *TelegramRepository) Unlink(ctx context.Context, chatID int64) (int, error) {
+	var userID int
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"DELETE FROM telegram_links WHERE chat_id = $1 RETURNING user_id", chatID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("telegram chat not linked")
+		}
+		return 0, fmt.Errorf("failed to unlink telegram chat: %w", err)
+	}
+	return userID, nil
+}
+
+// UserIDForChat returns the user linked to the chat
+func (r *TelegramRepository) UserIDForChat(ctx context.Context, chatID int64) (int, error) {
+	var userID int
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT user_id FROM telegram_links WHERE chat_id = $1", chatID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("telegram chat not linked")
+		}
+		return 0, fmt.Errorf("failed to get telegram link: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TenantRepository stores the shops. The tenants table itself is not scoped: the tenant
+// of a request is looked up before its connections are.
+type TenantRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTenantRepository(db *pgxpool.Pool) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+func (r *TenantRepository) GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error) {
+	var t model.Tenant
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT id, slug, name, created_at FROM tenants WHERE slug = $1", slug).
+		Scan(&t.ID, &t.Slug, &t.Name, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("tenant not found")
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *TenantRepository) ListTenants(ctx context.Context) ([]model.Tenant, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, "SELECT id, slug, name, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := []model.Tenant{}
+	for rows.Next() {
+		var t model.Tenant
+		if err := rows.Scan(&t.ID, &t.Slug, &t.Name, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func (r *TenantRepository) CreateTenant(ctx context.Context, t *model.Tenant) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"INSERT INTO tenants (slug, name) VALUES ($1, $2) RETURNING id, created_at", t.Slug, t.Name).
+		Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return errors.New("tenant already exists")
+		}
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return nil
+}
+
+// SealedSkinportCredentials are a tenant's Skinport credentials as stored, encrypted
+type SealedSkinportCredentials struct {
+	TenantID  int
+	ClientID  []byte
+	APIKey    []byte
+	UpdatedAt time.Time
+}
+
+func (r *TenantRepository) GetSkinportCredentials(ctx context.Context, tenantID int) (*SealedSkinportCredentials, error) {
+	c := SealedSkinportCredentials{TenantID: tenantID}
+	err := executorFromContext(ctx, r.db).QueryRow(ctx,
+		"SELECT client_id, api_key, updated_at FROM tenant_skinport_credentials WHERE tenant_id = $1", tenantID).
+		Scan(&c.ClientID, &c.APIKey, &c.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("skinport credentials not found")
+		}
+		return nil, fmt.Errorf("failed to get skinport credentials: %w", err)
+	}
+	return &c, nil
+}
+
+// ListSkinportCredentials returns the credentials of every tenant that configured some
+func (r *TenantRepository) ListSkinportCredentials(ctx context.Context) ([]SealedSkinportCredentials, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx,
+		"SELECT tenant_id, client_id, api_key, updated_at FROM tenant_skinport_credentials ORDER BY tenant_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list skinport credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var list []SealedSkinportCredentials
+	for rows.Next() {
+		var c SealedSkinportCredentials
+		if err := rows.Scan(&c.TenantID, &c.ClientID, &c.APIKey, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan skinport credentials: %w", err)
+		}
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list skinport credentials: %w", err)
+	}
+	return list, nil
+}
+
+// SetSkinportCredentials replaces the tenant's Skinport credentials
+func (r *TenantRepository) SetSkinportCredentials(ctx context.Context, tenantID int, c *SealedSkinportCredentials) error {
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		INSERT INTO tenant_skinport_credentials (tenant_id, client_id, api_key, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE SET client_id = EXCLUDED.client_id, api_key = EXCLUDED.api_key, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`, tenantID, c.ClientID, c.APIKey).
+		Scan(&c.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return errors.New("tenant not found")
+		}
+		return fmt.Errorf("failed to set skinport credentials: %w", err)
+	}
+	return nil
+}
+
+// DeleteSkinportCredentials makes the tenant use the deployment's Skinport credentials again
+func (r *TenantRepository) DeleteSkinportCredentials(ctx context.Context, tenantID int) error {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, "DELETE FROM tenant_skinport_credentials WHERE tenant_id = $1", tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete skinport credentials: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("skinport credentials not found")
+	}
+	return nil
+}
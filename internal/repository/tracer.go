@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"fsanano/go-test/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryTracer implements pgx.QueryTracer and pgx.BatchTracer.
+// It logs every query with its duration and redacted arguments,
+// flags queries slower than SlowThreshold and records latency metrics.
+type QueryTracer struct {
+	Logger        *slog.Logger
+	SlowThreshold time.Duration
+	// LogAll logs every query; slow queries and failures are always logged
+	LogAll bool
+}
+
+var (
+	_ pgx.QueryTracer = (*QueryTracer)(nil)
+	_ pgx.BatchTracer = (*QueryTracer)(nil)
+)
+
+func NewQueryTracer(logger *slog.Logger, slowThreshold time.Duration, logAll bool) *QueryTracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &QueryTracer{
+		Logger:        logger,
+		SlowThreshold: slowThreshold,
+		LogAll:        logAll,
+	}
+}
+
+type queryTraceKey struct{}
+
+type queryTraceData struct {
+	sql   string
+	args  []any
+	start time.Time
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTraceData{
+		sql:   data.SQL,
+		args:  data.Args,
+		start: time.Now(),
+	})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTraceData)
+	if !ok {
+		return
+	}
+	t.observe(ctx, trace.sql, trace.args, time.Since(trace.start), data.CommandTag, data.Err)
+}
+
+type batchTraceKey struct{}
+
+// batchTraceData tracks when the previous batched result was read; the queries are
+// pipelined, so each one is timed from the previous result
+type batchTraceData struct {
+	last time.Time
+}
+
+func (t *QueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return context.WithValue(ctx, batchTraceKey{}, &batchTraceData{last: time.Now()})
+}
+
+func (t *QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	trace, ok := ctx.Value(batchTraceKey{}).(*batchTraceData)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	t.observe(ctx, data.SQL, data.Args, now.Sub(trace.last), data.CommandTag, data.Err)
+	trace.last = now
+}
+
+func (t *QueryTracer) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+func (t *QueryTracer) observe(ctx context.Context, sql string, args []any, duration time.Duration, tag pgconn.CommandTag, err error) {
+	operation := queryOperation(sql)
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	metrics.DBQueryDuration.WithLabelValues(operation, status).Observe(duration.Seconds())
+
+	attrs := []any{
+		slog.String("sql", compactSQL(sql)),
+		slog.Any("args", redactArgs(args)),
+		slog.Duration("duration", duration),
+		slog.Int64("rows", tag.RowsAffected()),
+	}
+
+	switch {
+	case err != nil:
+		t.Logger.ErrorContext(ctx, "query failed", append(attrs, slog.String("error", err.Error()))...)
+	case t.SlowThreshold > 0 && duration >= t.SlowThreshold:
+		metrics.DBSlowQueries.WithLabelValues(operation).Inc()
+		t.Logger.WarnContext(ctx, "slow query", append(attrs, slog.Duration("threshold", t.SlowThreshold))...)
+	case t.LogAll:
+		t.Logger.DebugContext(ctx, "query", attrs...)
+	}
+}
+
+// queryOperation returns the lower-cased leading SQL keyword (select, insert, ...)
+// so metric label cardinality stays bounded regardless of query text. Leading
+// comments, such as the "-- name: X :one" line of sqlc queries, are skipped.
+func queryOperation(sql string) string {
+	fields := strings.Fields(skipLeadingComments(sql))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(fields[0])
+}
+
+// skipLeadingComments drops the whitespace, "--" line comments and "/* */" block
+// comments before the first statement keyword
+func skipLeadingComments(sql string) string {
+	for {
+		sql = strings.TrimLeftFunc(sql, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(sql, "--"):
+			end := strings.IndexByte(sql, '\n')
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+1:]
+		case strings.HasPrefix(sql, "/*"):
+			end := strings.Index(sql, "*/")
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+2:]
+		default:
+			return sql
+		}
+	}
+}
+
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// redactArgs keeps numeric and boolean arguments (ids, quantities, amounts)
+// and masks everything else, since free-form values may carry PII or secrets
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			out[i] = "NULL"
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+			out[i] = fmt.Sprint(v)
+		case string:
+			out[i] = fmt.Sprintf("<redacted len=%d>", len(v))
+		case []byte:
+			out[i] = fmt.Sprintf("<redacted bytes=%d>", len(v))
+		default:
+			out[i] = fmt.Sprintf("<redacted %T>", v)
+		}
+	}
+	return out
+}
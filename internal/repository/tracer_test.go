@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactArgs(t *testing.T) {
+	args := []any{1, 10.5, true, nil, "john@example.com", []byte("secret")}
+
+	assert.Equal(t, []string{
+		"1",
+		"10.5",
+		"true",
+		"NULL",
+		"<redacted len=16>",
+		"<redacted bytes=6>",
+	}, redactArgs(args))
+}
+
+func TestQueryOperation(t *testing.T) {
+	assert.Equal(t, "select", queryOperation("  SELECT price FROM items"))
+	assert.Equal(t, "update", queryOperation("\n\tUPDATE users SET balance = 0"))
+	assert.Equal(t, "unknown", queryOperation(""))
+	assert.Equal(t, "select", queryOperation("-- name: GetUser :one\nSELECT id FROM users WHERE id = $1\n"))
+	assert.Equal(t, "update", queryOperation("/* refund */ -- name: SetRefundDecision :execrows\n  UPDATE orders SET refund_decision = $1"))
+	assert.Equal(t, "unknown", queryOperation("-- only a comment"))
+}
+
+func TestQueryTracer_BatchQueries(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewQueryTracer(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), 0, true)
+
+	ctx := tracer.TraceBatchStart(context.Background(), nil, pgx.TraceBatchStartData{})
+	tracer.TraceBatchQuery(ctx, nil, pgx.TraceBatchQueryData{SQL: "UPDATE users SET balance = balance - $1 WHERE id = $2", Args: []any{10.5, 1}})
+	tracer.TraceBatchQuery(ctx, nil, pgx.TraceBatchQueryData{SQL: "UPDATE items SET stock = stock - $1 WHERE id = $2", Err: errors.New("boom")})
+	tracer.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{})
+
+	out := buf.String()
+	assert.Contains(t, out, `level=DEBUG msg=query sql="UPDATE users SET balance = balance - $1 WHERE id = $2" args="[10.5 1]"`)
+	assert.Contains(t, out, `msg="query failed" sql="UPDATE items SET stock = stock - $1 WHERE id = $2"`)
+	assert.Contains(t, out, "error=boom")
+}
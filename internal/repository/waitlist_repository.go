@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrAlreadyWaitlisted is returned when the user already waits for the item
+	ErrAlreadyWaitlisted = errors.New("already on the item's waitlist")
+	// ErrWaitlistEntryClosed is returned when cancelling an entry that no longer waits
+	ErrWaitlistEntryClosed = errors.New("waitlist entry is no longer waiting")
+)
+
+type WaitlistRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWaitlistRepository(db *pgxpool.Pool) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+// waitlistPositionSQL counts the waiting entries of the item up to w, 0 for closed entries
+const waitlistPositionSQL = `
+	CASE WHEN w.status = 'waiting' THEN (
+		SELECT COUNT(*) FROM waitlist_entries p
+		WHERE p.item_id = w.item_id AND p.status = 'waiting' AND p.id <= w.id
+	) ELSE 0 END`
+
+const waitlistColumns = "w.id, w.user_id, w.item_id, w.quantity, w.status, (" + waitlistPositionSQL + "), w.order_id, w.failure_reason, w.created_at, w.closed_at"
+
+func scanWaitlistEntry(row pgx.Row) (*model.WaitlistEntry, error) {
+	var e model.WaitlistEntry
+	err := row.Scan(&e.ID, &e.UserID, &e.ItemID, &e.Quantity, &e.Status, &e.Position, &e.OrderID, &e.FailureReason,
+		&e.CreatedAt, &e.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateEntry puts the user at the end of the item's waitlist and sets the entry's id,
+// status and position
+func (r *WaitlistRepository) CreateEntry(ctx context.Context, e *model.WaitlistEntry) error {
+	// The count runs on the snapshot before the insert, the new entry comes after it
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		WITH e AS (
+			INSERT INTO waitlist_entries (user_id, item_id, quantity)
+			VALUES ($1, $2, $3)
+			RETURNING id, status, created_at
+		)
+		SELECT e.id, e.status, e.created_at,
+			(SELECT COUNT(*) FROM waitlist_entries w WHERE w.item_id = $2 AND w.status = 'waiting') + 1
+		FROM e`,
+		e.UserID, e.ItemID, e.Quantity).Scan(&e.ID, &e.Status, &e.CreatedAt, &e.Position)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch {
+			case pgErr.Code == "23505":
+				return ErrAlreadyWaitlisted
+			case pgErr.Code == "23503" && pgErr.ConstraintName == "waitlist_entries_user_id_fkey":
+				return errors.New("user not found")
+			case pgErr.Code == "23503":
+				return errors.New("item not found")
+			}
+		}
+		return fmt.Errorf("failed to create waitlist entry: %w", err)
+	}
+	return nil
+}
+
+// ListUserEntries returns the user's waitlist entries, newest first
+func (r *WaitlistRepository) ListUserEntries(ctx context.Context, userID int) ([]model.WaitlistEntry, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx,
+		"SELECT "+waitlistColumns+" FROM waitlist_entries w WHERE w.user_id = $1 ORDER BY w.id DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waitlist entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.WaitlistEntry{}
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist entry: %w", err)
+		}
+		entries = append(entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list waitlist entries: %w", err)
+	}
+	return entries, nil
+}
+
+// CancelEntry takes the user's entry off the waitlist
+func (r *WaitlistRepository) CancelEntry(ctx context.Context, userID, id int) (*model.WaitlistEntry, error) {
+	exec := executorFromContext(ctx, r.db)
+	e, err := scanWaitlistEntry(exec.QueryRow(ctx, `
+		UPDATE waitlist_entries w SET status = 'cancelled', closed_at = NOW()
+		WHERE w.id = $1 AND w.user_id = $2 AND w.status = 'waiting'
+		RETURNING `+waitlistColumns, id, userID))
+	if err == nil {
+		return e, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+
+	var exists bool
+	err = exec.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM waitlist_entries WHERE id = $1 AND user_id = $2)", id, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+	if !exists {
+		return nil, errors.New("waitlist entry not found")
+	}
+	return nil, ErrWaitlistEntryClosed
+}
+
+// ListRestockedItems returns the items in stock that users wait for
+func (r *WaitlistRepository) ListRestockedItems(ctx context.Context) ([]int, error) {
+	rows, err := executorFromContext(ctx, r.db).Query(ctx, `
+		SELECT DISTINCT w.item_id FROM waitlist_entries w
+		JOIN items i ON i.id = w.item_id
+		WHERE w.status = 'waiting' AND i.stock > 0
+		ORDER BY w.item_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list restocked items: %w", err)
+	}
+	itemIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list restocked items: %w", err)
+	}
+	return itemIDs, nil
+}
+
+// NextWaitingEntry returns the oldest waiting entry of the item, nil if there is none
+func (r *WaitlistRepository) NextWaitingEntry(ctx context.Context, itemID int) (*model.WaitingEntry, error) {
+	var w model.WaitingEntry
+	e := &w.Entry
+	err := executorFromContext(ctx, r.db).QueryRow(ctx, `
+		SELECT w.id, w.user_id, w.item_id, w.quantity, w.status, w.created_at,
+			i.tenant_id, COALESCE(u.email, ''), COALESCE(t.chat_id, 0), u.first_name, i.name
+		FROM waitlist_entries w
+		JOIN users u ON u.id = w.user_id
+		JOIN items i ON i.id = w.item_id
+		LEFT JOIN telegram_links t ON t.user_id = w.user_id
+		WHERE w.item_id = $1 AND w.status = 'waiting'
+		ORDER BY w.id
+		LIMIT 1`, itemID).Scan(&e.ID, &e.UserID, &e.ItemID, &e.Quantity, &e.Status, &e.CreatedAt,
+		&w.TenantID, &w.Email, &w.TelegramChatID, &w.FirstName, &w.ItemName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	e.Position = 1
+	return &w, nil
+}
+
+// CloseEntry records how a waiting entry ended: fulfilled with orderID, or failed with
+// reason. It reports false when the entry was no longer waiting.
+func (r *WaitlistRepository) CloseEntry(ctx context.Context, id int, status string, orderID *int, reason string) (bool, error) {
+	tag, err := executorFromContext(ctx, r.db).Exec(ctx, `
+		UPDATE waitlist_entries SET status = $2, order_id = $3, failure_reason = $4, closed_at = NOW()
+		WHERE id = $1 AND status = 'waiting'`, id, status, orderID, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to close waitlist entry: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,103 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/awsv4"
+)
+
+// AWSConfig selects a secret of AWS Secrets Manager
+type AWSConfig struct {
+	Region string
+	// SecretID is the secret's name or ARN; its value is a JSON object of settings
+	SecretID string
+	// Endpoint defaults to https://secretsmanager.<region>.amazonaws.com
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials
+	SessionToken string
+}
+
+// AWSSecretsManager reads the current version of a secret through the Secrets Manager
+// API, signing requests with AWS Signature Version 4
+type AWSSecretsManager struct {
+	config AWSConfig
+	Client *http.Client
+	// now is the signing clock (tests)
+	now func() time.Time
+}
+
+func NewAWSSecretsManager(cfg AWSConfig) *AWSSecretsManager {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &AWSSecretsManager{config: cfg, Client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+}
+
+func (a *AWSSecretsManager) Name() string {
+	return "aws"
+}
+
+// String keeps the access keys out of printed configurations
+func (a *AWSSecretsManager) String() string {
+	return a.Name()
+}
+
+func (a *AWSSecretsManager) Fetch(ctx context.Context) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": a.config.SecretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build secrets manager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signer := awsv4.Signer{
+		AccessKeyID:     a.config.AccessKeyID,
+		SecretAccessKey: a.config.SecretAccessKey,
+		SessionToken:    a.config.SessionToken,
+		Region:          a.config.Region,
+		Service:         "secretsmanager",
+	}
+	signer.Sign(req, awsv4.PayloadHash(payload), a.now())
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets manager response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &awsErr)
+		return nil, fmt.Errorf("secrets manager read of %s failed with status %d: %s %s", a.config.SecretID, resp.StatusCode, awsErr.Type, awsErr.Message)
+	}
+
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+	if secret.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no string value", a.config.SecretID)
+	}
+	return decodeValues([]byte(*secret.SecretString))
+}
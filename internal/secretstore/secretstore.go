@@ -0,0 +1,97 @@
+// Package secretstore reads settings kept in a secret manager, HashiCorp Vault or AWS
+// Secrets Manager, instead of the environment, and re-fetches them for rotations.
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+)
+
+// Source fetches a secret holding settings named like the environment variables they
+// replace, e.g. {"DATABASE_URL": "...", "SKINPORT_API_KEY": "..."}
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// Watcher re-fetches a source to pick up rotated secrets; run Refresh periodically
+type Watcher struct {
+	src   Source
+	apply func(map[string]string)
+
+	mu      sync.Mutex
+	current map[string]string
+}
+
+// NewWatcher returns a watcher calling apply whenever a refresh returns values that
+// differ from the previous ones, starting from current
+func NewWatcher(src Source, current map[string]string, apply func(map[string]string)) *Watcher {
+	return &Watcher{src: src, apply: apply, current: current}
+}
+
+// Refresh fetches the source once, applying changed values. A failed fetch keeps the
+// previous values.
+func (w *Watcher) Refresh(ctx context.Context) error {
+	values, err := w.src.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh secrets from %s: %w", w.src.Name(), err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if maps.Equal(values, w.current) {
+		return nil
+	}
+	slog.InfoContext(ctx, "secrets rotated", "source", w.src.Name(), "changed", changedKeys(w.current, values))
+	w.current = values
+	w.apply(values)
+	return nil
+}
+
+// changedKeys names the settings that changed, never their values
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for key, value := range after {
+		if old, ok := before[key]; !ok || old != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// decodeValues reads a JSON object of settings; numbers and booleans are kept as their
+// JSON text, other values are rejected
+func decodeValues(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			values[key] = s
+			continue
+		}
+		var scalar any
+		if err := json.Unmarshal(value, &scalar); err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		switch scalar.(type) {
+		case float64, bool:
+			values[key] = string(value)
+		default:
+			return nil, fmt.Errorf("value of %s must be a string", key)
+		}
+	}
+	return values, nil
+}
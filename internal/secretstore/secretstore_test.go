@@ -0,0 +1,123 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/shop":
+			w.Write([]byte(`{"data":{"data":{"DATABASE_URL":"postgres://app:pw@db/shop","SKINPORT_RATE_LIMIT":8},"metadata":{"version":3}}}`))
+		case "/v1/kv/shop":
+			w.Write([]byte(`{"data":{"SKINPORT_API_KEY":"key"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	values, err := NewVault(VaultConfig{Addr: ts.URL + "/", Token: "s.token", Path: "/secret/data/shop"}).Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DATABASE_URL": "postgres://app:pw@db/shop", "SKINPORT_RATE_LIMIT": "8"}, values, "KV version 2")
+
+	values, err = NewVault(VaultConfig{Addr: ts.URL, Token: "s.token", Path: "kv/shop"}).Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SKINPORT_API_KEY": "key"}, values, "KV version 1")
+
+	_, err = NewVault(VaultConfig{Addr: ts.URL, Token: "wrong", Path: "kv/shop"}).Fetch(ctx)
+	assert.ErrorContains(t, err, "status 403: permission denied")
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/20260101/eu-west-1/secretsmanager/aws4_request")
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		var body struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body.SecretId != "shop/prod" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Name": "shop/prod", "SecretString": `{"SKINPORT_CLIENT_ID":"id","SKINPORT_API_KEY":"key"}`})
+	}))
+	defer ts.Close()
+
+	source := NewAWSSecretsManager(AWSConfig{Region: "eu-west-1", SecretID: "shop/prod", Endpoint: ts.URL,
+		AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"})
+	source.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	values, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SKINPORT_CLIENT_ID": "id", "SKINPORT_API_KEY": "key"}, values)
+
+	source.config.SecretID = "missing"
+	_, err = source.Fetch(context.Background())
+	assert.ErrorContains(t, err, "ResourceNotFoundException")
+}
+
+type fakeSource struct {
+	fetches []map[string]string
+	err     error
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) Fetch(ctx context.Context) (map[string]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	values := s.fetches[0]
+	if len(s.fetches) > 1 {
+		s.fetches = s.fetches[1:]
+	}
+	return values, nil
+}
+
+func TestWatcher(t *testing.T) {
+	initial := map[string]string{"SKINPORT_API_KEY": "old"}
+	rotated := map[string]string{"SKINPORT_API_KEY": "new"}
+	src := &fakeSource{fetches: []map[string]string{initial, rotated}}
+	var applied []map[string]string
+	w := NewWatcher(src, initial, func(values map[string]string) { applied = append(applied, values) })
+	ctx := context.Background()
+
+	require.NoError(t, w.Refresh(ctx))
+	assert.Empty(t, applied, "unchanged values apply nothing")
+	require.NoError(t, w.Refresh(ctx))
+	assert.Equal(t, []map[string]string{rotated}, applied)
+	require.NoError(t, w.Refresh(ctx))
+	assert.Len(t, applied, 1)
+
+	src.err = errors.New("unavailable")
+	assert.ErrorContains(t, w.Refresh(ctx), "unavailable")
+	assert.Len(t, applied, 1, "failed fetches keep the previous values")
+}
+
+func TestDecodeValues(t *testing.T) {
+	_, err := decodeValues([]byte(`{"A":{"nested":true}}`))
+	assert.Error(t, err)
+	_, err = decodeValues([]byte(`"plain"`))
+	assert.Error(t, err)
+	values, err := decodeValues([]byte(`{"A":"x","B":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "x", "B": "true"}, values)
+}
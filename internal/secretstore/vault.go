@@ -0,0 +1,88 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultConfig selects a secret of HashiCorp Vault's KV secrets engine
+type VaultConfig struct {
+	// Addr is the server's URL, e.g. https://vault.example:8200
+	Addr  string
+	Token string
+	// Namespace is sent for Vault Enterprise namespaces (empty for none)
+	Namespace string
+	// Path is the secret's API path below /v1, e.g. secret/data/shop for the shop
+	// secret of a KV version 2 engine mounted at secret/
+	Path string
+}
+
+// Vault reads a secret from Vault's KV engine, version 1 or 2
+type Vault struct {
+	config VaultConfig
+	Client *http.Client
+}
+
+func NewVault(cfg VaultConfig) *Vault {
+	cfg.Addr = strings.TrimRight(cfg.Addr, "/")
+	cfg.Path = strings.Trim(cfg.Path, "/")
+	return &Vault{config: cfg, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (v *Vault) Name() string {
+	return "vault"
+}
+
+// String keeps the token out of printed configurations
+func (v *Vault) String() string {
+	return v.Name()
+}
+
+func (v *Vault) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.Addr+"/v1/"+v.config.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.config.Token)
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(body, &vaultErr)
+		return nil, fmt.Errorf("vault read of %s failed with status %d: %s", v.config.Path, resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+	}
+
+	var secret struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	// KV version 2 nests the secret's data next to its metadata
+	var versioned struct {
+		Data     json.RawMessage `json:"data"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(secret.Data, &versioned); err == nil && versioned.Data != nil && versioned.Metadata != nil {
+		return decodeValues(versioned.Data)
+	}
+	return decodeValues(secret.Data)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/pricing"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+// DefaultArbitrageThreshold is the deviation, in percent, reported when none is asked for
+const DefaultArbitrageThreshold = 10.0
+
+// ArbitrageItem is a mapped item whose shop price deviates from its lowest Skinport
+// listing by more than the threshold
+type ArbitrageItem struct {
+	ItemID         int     `json:"item_id"`
+	Name           string  `json:"name"`
+	MarketHashName string  `json:"market_hash_name"`
+	ShopPrice      float64 `json:"shop_price"`
+	MarketPrice    float64 `json:"market_price"`
+	// DeviationPercent is positive when the shop is more expensive than the market
+	DeviationPercent float64 `json:"deviation_percent"`
+	// SyncedPrice is the price a price sync would set, markup included
+	SyncedPrice float64 `json:"synced_price"`
+}
+
+// ArbitrageReport lists the mapped items deviating from Skinport, largest deviation first
+type ArbitrageReport struct {
+	ThresholdPercent float64         `json:"threshold_percent"`
+	Currency         string          `json:"currency,omitempty"`
+	Items            []ArbitrageItem `json:"items"`
+	// Mapped counts the items mapped to Skinport, Unlisted those without a listing
+	Mapped     int       `json:"mapped"`
+	Unlisted   int       `json:"unlisted"`
+	ComparedAt time.Time `json:"compared_at"`
+}
+
+// FindArbitrage compares the price of every item mapped to Skinport with its lowest
+// cached listing, reporting those deviating by more than thresholdPercent, to help admins
+// reprice items that are not synced automatically or whose sync is pending approval.
+func (s *PriceSyncService) FindArbitrage(ctx context.Context, thresholdPercent float64) (*ArbitrageReport, error) {
+	if thresholdPercent < 0 || math.IsNaN(thresholdPercent) || math.IsInf(thresholdPercent, 0) {
+		return nil, invalid("threshold must be a non-negative percent")
+	}
+	mappings, err := s.repo.ListPriceMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []skinport.ResponseItem
+	if len(mappings) > 0 {
+		names := make([]string, len(mappings))
+		for i, m := range mappings {
+			names[i] = m.MarketHashName
+		}
+		listings, err = s.skinport.LookupItems(ctx, skinport.ItemsParams{Currency: s.opts.Currency}, names)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get skinport prices: %w", err)
+		}
+	}
+	return findArbitrage(s.opts.Markup, s.opts.Currency, mappings, listings, thresholdPercent), nil
+}
+
+func findArbitrage(markup pricing.Markup, currency string, mappings []model.SkinportPriceMapping,
+	listings []skinport.ResponseItem, thresholdPercent float64) *ArbitrageReport {
+	prices := make(map[string]float64, len(listings))
+	for _, listing := range listings {
+		if price, ok := lowestPrice(listing); ok {
+			prices[listing.MarketHashName] = price
+		}
+		if currency == "" {
+			currency = listing.Currency
+		}
+	}
+
+	report := &ArbitrageReport{
+		ThresholdPercent: thresholdPercent,
+		Currency:         currency,
+		Items:            []ArbitrageItem{},
+		Mapped:           len(mappings),
+		ComparedAt:       time.Now(),
+	}
+	for _, m := range mappings {
+		market, ok := prices[m.MarketHashName]
+		if !ok || market == 0 {
+			report.Unlisted++
+			continue
+		}
+		deviation := math.Round((m.ItemPrice-market)/market*10000) / 100
+		if math.Abs(deviation) <= thresholdPercent {
+			continue
+		}
+		report.Items = append(report.Items, ArbitrageItem{
+			ItemID:           m.ItemID,
+			Name:             m.ItemName,
+			MarketHashName:   m.MarketHashName,
+			ShopPrice:        m.ItemPrice,
+			MarketPrice:      market,
+			DeviationPercent: deviation,
+			SyncedPrice:      syncedPrice(markup, m, market),
+		})
+	}
+	slices.SortFunc(report.Items, func(a, b ArbitrageItem) int {
+		return cmp.Or(cmp.Compare(math.Abs(b.DeviationPercent), math.Abs(a.DeviationPercent)), cmp.Compare(a.ItemID, b.ItemID))
+	})
+	return report
+}
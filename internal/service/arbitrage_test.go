@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/pricing"
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindArbitrage(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	mappings := []model.SkinportPriceMapping{
+		{ItemID: 1, ItemName: "Redline", MarketHashName: "AK-47 | Redline", ItemPrice: 12},
+		{ItemID: 2, ItemName: "Asiimov", MarketHashName: "AWP | Asiimov", ItemPrice: 50},
+		{ItemID: 3, ItemName: "Fade", MarketHashName: "Karambit | Fade", ItemPrice: 700},
+		{ItemID: 4, ItemName: "Vulcan", MarketHashName: "AK-47 | Vulcan", ItemPrice: 30},
+	}
+	listings := []skinport.ResponseItem{
+		{MarketHashName: "AK-47 | Redline", Currency: "EUR", MinPriceTradable: price(10), MinPriceNonTradable: price(11)},
+		{MarketHashName: "AWP | Asiimov", Currency: "EUR", MinPriceTradable: price(100)},
+		{MarketHashName: "Karambit | Fade", Currency: "EUR", MinPriceNonTradable: price(690)},
+	}
+
+	report := findArbitrage(pricing.Markup{Percent: 10}, "", mappings, listings, 10)
+	assert.Equal(t, "EUR", report.Currency)
+	assert.Equal(t, 4, report.Mapped)
+	assert.Equal(t, 1, report.Unlisted)
+	assert.Equal(t, []ArbitrageItem{
+		{ItemID: 2, Name: "Asiimov", MarketHashName: "AWP | Asiimov", ShopPrice: 50, MarketPrice: 100, DeviationPercent: -50, SyncedPrice: 110},
+		{ItemID: 1, Name: "Redline", MarketHashName: "AK-47 | Redline", ShopPrice: 12, MarketPrice: 10, DeviationPercent: 20, SyncedPrice: 11},
+	}, report.Items, "largest deviation first, within the threshold left out")
+}
+
+func TestFindArbitrage_Validation(t *testing.T) {
+	svc := NewPriceSyncService(nil, nil, nil, nil, PriceSyncOptions{}, nil)
+	_, err := svc.FindArbitrage(context.Background(), -1)
+	assert.True(t, errors.Is(err, ErrValidation))
+}
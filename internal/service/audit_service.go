@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/model"
+)
+
+const maxAuditPageSize = 500
+
+// AuditStore keeps the audit log, implemented by repository.AuditRepository
+type AuditStore interface {
+	Record(ctx context.Context, entry model.AuditEntry) error
+	List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type AuditService struct {
+	repo AuditStore
+}
+
+func NewAuditService(repo AuditStore) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record writes an audit entry attributed to the actor and request ID carried by ctx
+// (defaultActor is used when none is set). A nil AuditService records nothing,
+// so auditing stays optional for callers.
+func (s *AuditService) Record(ctx context.Context, defaultActor, action, entityType, entityID string, before, after any) error {
+	if s == nil {
+		return nil
+	}
+
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Record(ctx, model.AuditEntry{
+		Actor:      audit.ActorFrom(ctx, defaultActor),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+		RequestID:  audit.RequestIDFrom(ctx),
+	})
+}
+
+func (s *AuditService) List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > maxAuditPageSize {
+		filter.Limit = 100
+	}
+	return s.repo.List(ctx, filter)
+}
+
+func marshalSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	return data, nil
+}
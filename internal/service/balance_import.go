@@ -0,0 +1,332 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/storage"
+)
+
+const (
+	importChunkSize    = 500
+	maxReasonLength    = 500
+	maxAdjustmentDelta = 1_000_000
+)
+
+// Row statuses in an import report
+const (
+	ImportRowApplied   = "applied"
+	ImportRowValid     = "valid" // dry run only
+	ImportRowInvalid   = "invalid"
+	ImportRowFailed    = "failed"
+	ImportRowCancelled = "cancelled"
+)
+
+type ImportRowResult struct {
+	Line   int         `json:"line"`
+	UserID int         `json:"user_id"`
+	Delta  model.Money `json:"delta"`
+	Reason string      `json:"reason"`
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func (row ImportRowResult) status() string { return row.Status }
+
+func (ImportRowResult) csvHeader() []string {
+	return []string{"line", "user_id", "delta", "reason", "status", "error"}
+}
+
+func (row ImportRowResult) csvRecord() []string {
+	return []string{strconv.Itoa(row.Line), strconv.Itoa(row.UserID), row.Delta.String(),
+		row.Reason, row.Status, row.Error}
+}
+
+// ImportReport is the report of a balance import
+type ImportReport = importReport[ImportRowResult]
+
+// importRow is a row of an import report, the result of a line of the file
+type importRow interface {
+	status() string
+	// csvHeader names the columns of csvRecord
+	csvHeader() []string
+	csvRecord() []string
+}
+
+// importReport holds the per-row results of an import and counts them
+type importReport[R importRow] struct {
+	DryRun  bool `json:"dry_run"`
+	Total   int  `json:"total"`
+	Applied int  `json:"applied"`
+	Failed  int  `json:"failed"`
+	Rows    []R  `json:"rows"`
+	// ReportURL downloads the CSV report kept in blob storage, when it could be stored
+	ReportURL string `json:"report_url,omitempty"`
+}
+
+func (r *importReport[R]) add(row R) {
+	if s := row.status(); s == ImportRowApplied || s == ImportRowValid {
+		r.Applied++
+	} else {
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// WriteCSV writes the per-row results as CSV
+func (r *importReport[R]) WriteCSV(w io.Writer) error {
+	var zero R
+	cw := csv.NewWriter(w)
+	cw.Write(zero.csvHeader())
+	for _, row := range r.Rows {
+		cw.Write(row.csvRecord())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// BalanceStore moves user balances, implemented by repository.ShopRepository
+type BalanceStore interface {
+	// AdjustUserBalance posts delta to the balance as a ledger transaction of kind,
+	// refusing to take it below zero, and returns the new balance
+	AdjustUserBalance(ctx context.Context, userID int, delta model.Money, kind, reference string) (model.Money, error)
+	CreateBalanceAdjustment(ctx context.Context, userID int, delta model.Money, reason, source string) error
+}
+
+type BalanceImportService struct {
+	repo  BalanceStore
+	uow   db.UnitOfWork
+	audit *AuditService
+	importReports
+}
+
+func NewBalanceImportService(repo BalanceStore, uow db.UnitOfWork, audit *AuditService, reports storage.Blob) *BalanceImportService {
+	return &BalanceImportService{repo: repo, uow: uow, audit: audit, importReports: importReports{reports, "balance-imports"}}
+}
+
+// reportURLTTL is how long the download link of a stored import report is valid
+const reportURLTTL = 24 * time.Hour
+
+// importReports keeps the CSV reports of an importer's runs under exports/<dir>/
+type importReports struct {
+	// blob is nil when reports are not kept, StoreReport then fails
+	blob storage.Blob
+	dir  string
+}
+
+// StoreReport keeps the report as CSV in blob storage and returns a signed download URL
+func (s importReports) StoreReport(ctx context.Context, report interface{ WriteCSV(io.Writer) error }) (string, error) {
+	if s.blob == nil {
+		return "", errors.New("import report storage is not configured")
+	}
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		return "", fmt.Errorf("failed to render import report: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	key := fmt.Sprintf("exports/%s/%s-%s.csv", s.dir, time.Now().UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix))
+	if err := s.blob.Put(ctx, key, buf.Bytes(), "text/csv"); err != nil {
+		return "", err
+	}
+	return s.blob.SignedURL(ctx, key, reportURLTTL)
+}
+
+// ImportAdjustments streams a CSV with a user_id,delta,reason header and applies
+// each valid row in chunked transactions. Rows failing validation or hitting
+// a missing user / negative balance are reported and skipped; an unexpected
+// database error rolls back the whole chunk it happened in.
+func (s *BalanceImportService) ImportAdjustments(ctx context.Context, in io.Reader, dryRun bool) (*ImportReport, error) {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	cols, err := importColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun}
+	chunk := make([]ImportRowResult, 0, importChunkSize)
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if !dryRun {
+			if err := s.applyChunk(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		for _, row := range chunk {
+			report.add(row)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		report.Total++
+
+		if err != nil {
+			report.add(ImportRowResult{Line: line, Status: ImportRowInvalid, Error: err.Error()})
+			continue
+		}
+
+		row, err := parseImportRow(record, cols)
+		row.Line = line
+		if err != nil {
+			row.Status = ImportRowInvalid
+			row.Error = err.Error()
+			report.add(row)
+			continue
+		}
+
+		row.Status = ImportRowValid
+		chunk = append(chunk, row)
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// applyChunk applies all rows in one transaction, updating each row's status in place
+func (s *BalanceImportService) applyChunk(ctx context.Context, chunk []ImportRowResult) error {
+	err := runAtomic(ctx, s.uow, "import_balances", func(ctx context.Context) error {
+		for i := range chunk {
+			row := &chunk[i]
+			row.Status, row.Error = ImportRowValid, ""
+
+			balance, err := s.repo.AdjustUserBalance(ctx, row.UserID, row.Delta, model.LedgerKindAdjustment, "")
+			if err != nil {
+				if msg := err.Error(); msg == "user not found" || errors.Is(err, repository.ErrInsufficientFunds) {
+					row.Status, row.Error = ImportRowFailed, msg
+					continue
+				}
+				return err
+			}
+			if err := s.repo.CreateBalanceAdjustment(ctx, row.UserID, row.Delta, row.Reason, "csv_import"); err != nil {
+				return err
+			}
+			if err := s.audit.Record(ctx, "admin", "balance.adjust", "user", strconv.Itoa(row.UserID),
+				map[string]any{"balance": balance.Sub(row.Delta)},
+				map[string]any{"balance": balance, "delta": row.Delta, "reason": row.Reason, "source": "csv_import"},
+			); err != nil {
+				return err
+			}
+			row.Status = ImportRowApplied
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			for i := range chunk {
+				chunk[i].Status, chunk[i].Error = ImportRowCancelled, ctx.Err().Error()
+			}
+			return err
+		}
+		// The chunk was rolled back, nothing in it has been applied
+		for i := range chunk {
+			chunk[i].Status, chunk[i].Error = ImportRowFailed, "chunk rolled back: "+err.Error()
+		}
+	}
+	return nil
+}
+
+type importColumnIndex struct {
+	userID, delta, reason int
+}
+
+func importColumns(header []string) (importColumnIndex, error) {
+	cols := importColumnIndex{-1, -1, -1}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "user_id":
+			cols.userID = i
+		case "delta":
+			cols.delta = i
+		case "reason":
+			cols.reason = i
+		}
+	}
+	if cols.userID < 0 || cols.delta < 0 || cols.reason < 0 {
+		return cols, errors.New("csv header must contain user_id, delta and reason columns")
+	}
+	return cols, nil
+}
+
+func parseImportRow(record []string, cols importColumnIndex) (ImportRowResult, error) {
+	var row ImportRowResult
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row.Reason = field(cols.reason)
+
+	userID, err := strconv.Atoi(field(cols.userID))
+	if err != nil || userID <= 0 {
+		return row, fmt.Errorf("invalid user_id %q", field(cols.userID))
+	}
+	row.UserID = userID
+
+	if err := row.Delta.UnmarshalJSON([]byte(field(cols.delta))); err != nil {
+		return row, fmt.Errorf("invalid delta %q: %w", field(cols.delta), err)
+	}
+
+	return row, validateAdjustment(row.Delta, row.Reason)
+}
+
+// validateAdjustment checks the rules shared by every manual balance adjustment
+func validateAdjustment(delta model.Money, reason string) error {
+	if delta.Amount == 0 {
+		return invalid("delta must not be zero")
+	}
+	if exceedsMaxAdjustment(delta) {
+		return invalid(fmt.Sprintf("delta exceeds %d", maxAdjustmentDelta))
+	}
+	if reason == "" {
+		return invalid("reason is required")
+	}
+	if len(reason) > maxReasonLength {
+		return invalid(fmt.Sprintf("reason exceeds %d characters", maxReasonLength))
+	}
+	return nil
+}
+
+// exceedsMaxAdjustment reports whether amount is more than maxAdjustmentDelta either way
+func exceedsMaxAdjustment(amount model.Money) bool {
+	limit := model.NewMoney(maxAdjustmentDelta, amount.Currency).Amount
+	return amount.Amount > limit || amount.Amount < -limit
+}
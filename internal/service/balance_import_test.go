@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportAdjustments_DryRunValidation(t *testing.T) {
+	csvData := `user_id,delta,reason
+1,10.50,promo credit
+2,-1.15,incident correction
+x,5,bad user
+3,0,zero delta
+4,1.005,too precise
+5,7,
+`
+	svc := NewBalanceImportService(nil, nil, nil, nil)
+
+	report, err := svc.ImportAdjustments(context.Background(), strings.NewReader(csvData), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, report.Total)
+	assert.Equal(t, 2, report.Applied)
+	assert.Equal(t, 4, report.Failed)
+
+	statuses := map[int]string{}
+	for _, row := range report.Rows {
+		statuses[row.Line] = row.Status
+	}
+	assert.Equal(t, ImportRowValid, statuses[2])
+	assert.Equal(t, ImportRowValid, statuses[3])
+	assert.Equal(t, ImportRowInvalid, statuses[4])
+	assert.Equal(t, ImportRowInvalid, statuses[5])
+	assert.Equal(t, ImportRowInvalid, statuses[6])
+	assert.Equal(t, ImportRowInvalid, statuses[7])
+
+	var out bytes.Buffer
+	assert.NoError(t, report.WriteCSV(&out))
+	assert.True(t, strings.HasPrefix(out.String(), "line,user_id,delta,reason,status,error\n"))
+}
+
+func TestImportAdjustments_MissingColumns(t *testing.T) {
+	svc := NewBalanceImportService(nil, nil, nil, nil)
+
+	_, err := svc.ImportAdjustments(context.Background(), strings.NewReader("user_id,amount\n1,5\n"), true)
+	assert.Error(t, err)
+}
+
+func TestStoreReport(t *testing.T) {
+	ctx := context.Background()
+	blobs := &storage.Local{Dir: t.TempDir(), PublicURL: "https://shop.example.com/storage", SigningKey: "secret"}
+	svc := NewBalanceImportService(nil, nil, nil, blobs)
+	report := &ImportReport{Total: 1, Applied: 1, Rows: []ImportRowResult{{Line: 2, UserID: 1, Delta: model.Money{Amount: 500}, Status: ImportRowApplied}}}
+
+	reportURL, err := svc.StoreReport(ctx, report)
+	require.NoError(t, err)
+	u, err := url.Parse(reportURL)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u.Path, "/storage/exports/balance-imports/"), u.Path)
+	assert.NotEmpty(t, u.Query().Get("signature"))
+
+	obj, err := blobs.Get(ctx, strings.TrimPrefix(u.Path, "/storage/"))
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	data, _ := io.ReadAll(obj.Body)
+	assert.Equal(t, "line,user_id,delta,reason,status,error\n2,1,5.00,,applied,\n", string(data))
+	assert.Equal(t, "text/csv", obj.ContentType)
+
+	_, err = NewBalanceImportService(nil, nil, nil, nil).StoreReport(ctx, report)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/testdb"
+)
+
+// The purchase benchmarks need Postgres: DATABASE_URL, or go test -tags embeddedpg
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// setupBuyBench seeds users and items that never run out of balance or stock
+func setupBuyBench(b *testing.B, users, items int) (*ShopService, []int, []int) {
+	pool := testdb.New(b, "orders", "users", "items")
+	repo := repository.NewShopRepository(pool)
+	ctx := context.Background()
+
+	userIDs := make([]int, users)
+	for i := range userIDs {
+		user := model.User{FirstName: "Bench", LastName: "User", Balance: model.NewMoney(1e9, model.DefaultCurrency)}
+		if err := repo.CreateUser(ctx, &user); err != nil {
+			b.Fatal(err)
+		}
+		userIDs[i] = user.ID
+	}
+	itemIDs := make([]int, items)
+	for i := range itemIDs {
+		item := model.Item{Name: "Bench Item", Price: model.NewMoney(1, model.DefaultCurrency), Stock: 1e9}
+		if err := repo.CreateItem(ctx, &item); err != nil {
+			b.Fatal(err)
+		}
+		itemIDs[i] = item.ID
+	}
+	return NewShopService(repo, repo), userIDs, itemIDs
+}
+
+func BenchmarkBuyItem(b *testing.B) {
+	svc, users, items := setupBuyBench(b, 1, 1)
+	ctx := context.Background()
+
+	for b.Loop() {
+		if _, err := svc.BuyItem(ctx, BuyParams{UserID: users[0], ItemID: items[0], Quantity: 1}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBuyItem_Parallel spreads purchases over distinct users and items, measuring
+// throughput without row lock contention
+func BenchmarkBuyItem_Parallel(b *testing.B) {
+	svc, users, items := setupBuyBench(b, 64, 64)
+	ctx := context.Background()
+	var next atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1))
+		for pb.Next() {
+			p := BuyParams{UserID: users[i%len(users)], ItemID: items[i%len(items)], Quantity: 1}
+			if _, err := svc.BuyItem(ctx, p); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkBuyItem_Contended has every goroutine buy the same item, serializing on its row lock
+func BenchmarkBuyItem_Contended(b *testing.B) {
+	svc, users, items := setupBuyBench(b, 64, 1)
+	ctx := context.Background()
+	var next atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1))
+		for pb.Next() {
+			p := BuyParams{UserID: users[i%len(users)], ItemID: items[0], Quantity: 1}
+			if _, err := svc.BuyItem(ctx, p); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
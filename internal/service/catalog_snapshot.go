@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/storage"
+	"fsanano/go-test/internal/tenant"
+)
+
+// ErrSnapshotNotPublished is returned while no snapshot has been published yet
+var ErrSnapshotNotPublished = errors.New("catalog snapshot not published yet")
+
+// SnapshotPublisher uploads a rendered snapshot and returns its public URL
+type SnapshotPublisher interface {
+	Publish(ctx context.Context, name string, data []byte, contentType string) (string, error)
+}
+
+// DirPublisher writes snapshots to a local directory served by a CDN origin or static file server
+type DirPublisher struct {
+	Dir     string
+	BaseURL string
+}
+
+var _ SnapshotPublisher = (*DirPublisher)(nil)
+
+func (p *DirPublisher) Publish(_ context.Context, name string, data []byte, _ string) (string, error) {
+	path := filepath.Join(p.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never observe a partial snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to publish snapshot: %w", err)
+	}
+
+	return strings.TrimRight(p.BaseURL, "/") + "/" + name, nil
+}
+
+// BlobPublisher stores snapshots in blob storage under Prefix; BaseURL is where the
+// bucket (or a CDN in front of it) serves that prefix publicly
+type BlobPublisher struct {
+	Blob    storage.Blob
+	Prefix  string
+	BaseURL string
+}
+
+var _ SnapshotPublisher = (*BlobPublisher)(nil)
+
+func (p *BlobPublisher) Publish(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	if err := p.Blob.Put(ctx, path.Join(p.Prefix, name), data, contentType); err != nil {
+		return "", fmt.Errorf("failed to publish snapshot: %w", err)
+	}
+	return strings.TrimRight(p.BaseURL, "/") + "/" + name, nil
+}
+
+type CatalogItem struct {
+	ID    int         `json:"id"`
+	Name  string      `json:"name"`
+	Price model.Money `json:"price"`
+	Stock int         `json:"stock"`
+}
+
+type CatalogSnapshot struct {
+	Items       []CatalogItem           `json:"items"`
+	SkinportTop []skinport.ResponseItem `json:"skinport_top,omitempty"`
+}
+
+// SnapshotMeta describes the latest published snapshot
+type SnapshotMeta struct {
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	Size        int       `json:"size"`
+	ItemCount   int       `json:"item_count"`
+	GeneratedAt time.Time `json:"generated_at"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// ItemLister lists the catalogue, implemented by repository.ShopRepository
+type ItemLister interface {
+	ListItems(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) ([]model.Item, error)
+}
+
+// TenantLister lists the shops, implemented by repository.TenantRepository
+type TenantLister interface {
+	ListTenants(ctx context.Context) ([]model.Tenant, error)
+}
+
+// CatalogSnapshotService publishes a snapshot of every shop's catalogue, under the shop's
+// slug: <slug>/catalog.json
+type CatalogSnapshotService struct {
+	repo           ItemLister
+	tenants        TenantLister
+	skinportClient *skinport.Client
+	publisher      SnapshotPublisher
+	// skinportTop is how many Skinport items (by listing quantity) to embed, 0 disables
+	skinportTop int
+
+	mu sync.RWMutex
+	// meta holds the latest published snapshot of each tenant
+	meta map[int]*SnapshotMeta
+
+	trigger chan struct{}
+}
+
+func NewCatalogSnapshotService(repo ItemLister, tenants TenantLister,
+	skinportClient *skinport.Client, publisher SnapshotPublisher, skinportTop int) *CatalogSnapshotService {
+	return &CatalogSnapshotService{
+		repo:           repo,
+		tenants:        tenants,
+		skinportClient: skinportClient,
+		publisher:      publisher,
+		skinportTop:    skinportTop,
+		meta:           map[int]*SnapshotMeta{},
+		trigger:        make(chan struct{}, 1),
+	}
+}
+
+// Meta returns metadata of the latest published snapshot of ctx's tenant
+func (s *CatalogSnapshotService) Meta(ctx context.Context) (SnapshotMeta, error) {
+	id, _ := tenant.IDFrom(ctx)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta, ok := s.meta[id]
+	if !ok {
+		return SnapshotMeta{}, ErrSnapshotNotPublished
+	}
+	return *meta, nil
+}
+
+// Trigger asks the publisher loop to re-render as soon as possible (e.g. after a catalogue change)
+func (s *CatalogSnapshotService) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run re-renders the catalogue every interval (or on Trigger) and publishes it
+// whenever its content changed, until ctx is cancelled
+func (s *CatalogSnapshotService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Publish(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("catalog snapshot failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+	}
+}
+
+// Publish renders the catalogue of every tenant and uploads those that differ from their
+// last published version. It reports whether a new snapshot was uploaded.
+func (s *CatalogSnapshotService) Publish(ctx context.Context) (bool, error) {
+	tenants, err := s.tenants.ListTenants(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	published := false
+	var errs []error
+	for _, t := range tenants {
+		ok, err := s.publishTenant(tenant.WithID(ctx, t.ID), t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", t.Slug, err))
+		}
+		published = published || ok
+	}
+	return published, errors.Join(errs...)
+}
+
+// publishTenant publishes the catalogue of t, which ctx is scoped to
+func (s *CatalogSnapshotService) publishTenant(ctx context.Context, t model.Tenant) (bool, error) {
+	snapshot, err := s.render(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+
+	s.mu.RLock()
+	last := s.meta[t.ID]
+	s.mu.RUnlock()
+	unchanged := last != nil && last.Version == version
+	if unchanged {
+		return false, nil
+	}
+
+	generatedAt := time.Now().UTC()
+	// The versioned file is immutable and can be cached forever; catalog.json always points to the latest
+	envelope, err := json.Marshal(struct {
+		Version     string    `json:"version"`
+		GeneratedAt time.Time `json:"generated_at"`
+		*CatalogSnapshot
+	}{version, generatedAt, snapshot})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if _, err := s.publisher.Publish(ctx, t.Slug+"/catalog-"+version+".json", envelope, "application/json"); err != nil {
+		return false, err
+	}
+	url, err := s.publisher.Publish(ctx, t.Slug+"/catalog.json", envelope, "application/json")
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.meta[t.ID] = &SnapshotMeta{
+		Version:     version,
+		URL:         url,
+		Size:        len(envelope),
+		ItemCount:   len(snapshot.Items),
+		GeneratedAt: generatedAt,
+		PublishedAt: time.Now().UTC(),
+	}
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+func (s *CatalogSnapshotService) render(ctx context.Context) (*CatalogSnapshot, error) {
+	items, err := s.repo.ListItems(ctx, model.ItemFilter{}, model.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &CatalogSnapshot{Items: make([]CatalogItem, 0, len(items))}
+	for _, item := range items {
+		snapshot.Items = append(snapshot.Items, CatalogItem{
+			ID:    item.ID,
+			Name:  item.Name,
+			Price: item.Price,
+			Stock: item.Stock,
+		})
+	}
+
+	if s.skinportTop > 0 && s.skinportClient != nil {
+		skinportItems, err := s.skinportClient.GetAllItems(ctx, "", "")
+		if err != nil {
+			// The shop catalogue is still worth publishing without market data
+			slog.Warn("catalog snapshot without skinport items", "error", err)
+		} else {
+			snapshot.SkinportTop = topSkinportItems(skinportItems, s.skinportTop)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// topSkinportItems returns the n most listed items, with a stable order so unchanged data hashes the same
+func topSkinportItems(items []skinport.ResponseItem, n int) []skinport.ResponseItem {
+	top := make([]skinport.ResponseItem, len(items))
+	copy(top, items)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Quantity != top[j].Quantity {
+			return top[i].Quantity > top[j].Quantity
+		}
+		return top[i].MarketHashName < top[j].MarketHashName
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
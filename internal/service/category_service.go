@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+const maxTagsPerItem = 20
+
+// CategoryStore keeps categories and tags and what items have,
+// implemented by repository.CategoryRepository
+type CategoryStore interface {
+	CreateCategory(ctx context.Context, c *model.Category) error
+	ListCategories(ctx context.Context) ([]model.Category, error)
+	ListTags(ctx context.Context) ([]model.Tag, error)
+	SetItemCategory(ctx context.Context, itemID int, slug string) error
+	// SetItemTags replaces the item's tags, creating the ones that don't exist yet
+	SetItemTags(ctx context.Context, itemID int, tags []string) error
+}
+
+type CategoryService struct {
+	repo  CategoryStore
+	uow   db.UnitOfWork
+	audit *AuditService
+}
+
+func NewCategoryService(repo CategoryStore, uow db.UnitOfWork, audit *AuditService) *CategoryService {
+	return &CategoryService{repo: repo, uow: uow, audit: audit}
+}
+
+func (s *CategoryService) ListCategories(ctx context.Context) ([]model.Category, error) {
+	return s.repo.ListCategories(ctx)
+}
+
+func (s *CategoryService) ListTags(ctx context.Context) ([]model.Tag, error) {
+	return s.repo.ListTags(ctx)
+}
+
+func (s *CategoryService) CreateCategory(ctx context.Context, c *model.Category) error {
+	c.Slug = strings.TrimSpace(c.Slug)
+	c.Name = strings.TrimSpace(c.Name)
+	if !slugPattern.MatchString(c.Slug) {
+		return invalid("slug must be lowercase letters, digits and dashes")
+	}
+	if c.Name == "" {
+		return invalid("name is required")
+	}
+
+	return runAtomic(ctx, s.uow, "create_category", func(ctx context.Context) error {
+		if err := s.repo.CreateCategory(ctx, c); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "category.create", "category", strconv.Itoa(c.ID), nil, c)
+	})
+}
+
+// UpdateItemTaxonomy sets the item's category (nil leaves it unchanged, "" clears it)
+// and replaces its tags (nil leaves them unchanged)
+func (s *CategoryService) UpdateItemTaxonomy(ctx context.Context, itemID int, category *string, tags []string) error {
+	tags, err := validateTags(tags)
+	if err != nil {
+		return err
+	}
+
+	return runAtomic(ctx, s.uow, "update_item_taxonomy", func(ctx context.Context) error {
+		after := map[string]any{}
+		if category != nil {
+			if err := s.repo.SetItemCategory(ctx, itemID, *category); err != nil {
+				return err
+			}
+			after["category"] = *category
+		}
+		if tags != nil {
+			if err := s.repo.SetItemTags(ctx, itemID, tags); err != nil {
+				return err
+			}
+			after["tags"] = tags
+		}
+		return s.audit.Record(ctx, "admin", "item.update_taxonomy", "item", strconv.Itoa(itemID), nil, after)
+	})
+}
+
+// validateTags normalizes an item's tags and checks their number and length
+func validateTags(tags []string) ([]string, error) {
+	tags = normalizeTags(tags)
+	if len(tags) > maxTagsPerItem {
+		return nil, invalid("too many tags")
+	}
+	for _, tag := range tags {
+		if len(tag) > 50 {
+			return nil, invalid("tags must be at most 50 characters")
+		}
+	}
+	return tags, nil
+}
+
+// normalizeTags lower-cases, trims and de-duplicates tag names
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/payments"
+)
+
+const (
+	// minDepositAmount stays above Stripe's minimum charge in every currency we take
+	minDepositAmount = 1
+	// depositReconcileMinAge leaves time for the webhook before a deposit is looked up
+	depositReconcileMinAge = 5 * time.Minute
+	depositReconcileBatch  = 100
+)
+
+// DepositStore keeps deposits, implemented by repository.DepositRepository
+type DepositStore interface {
+	CreateDeposit(ctx context.Context, d *model.Deposit) error
+	SetDepositSession(ctx context.Context, depositID int, sessionID string) error
+	GetDepositForUpdate(ctx context.Context, depositID int) (*model.Deposit, error)
+	MarkDepositSucceeded(ctx context.Context, depositID int, paymentIntentID string) error
+	FlagDepositForReview(ctx context.Context, depositID int, paymentIntentID string) error
+	// CloseDeposit moves a pending deposit to status, reporting whether it was still pending
+	CloseDeposit(ctx context.Context, depositID int, status string) (bool, error)
+	ListPendingDeposits(ctx context.Context, minAge time.Duration, limit int) ([]model.Deposit, error)
+}
+
+// DepositService takes balance deposits through Stripe Checkout. Deposits are credited
+// by the payment_intent.succeeded webhook; Reconcile credits those whose webhook was
+// missed and is meant to be run periodically as a background job.
+type DepositService struct {
+	repo     DepositStore
+	balances BalanceStore
+	uow      db.UnitOfWork
+	stripe   *payments.Stripe
+	audit    *AuditService
+}
+
+func NewDepositService(repo DepositStore, balances BalanceStore, uow db.UnitOfWork,
+	stripe *payments.Stripe, audit *AuditService) *DepositService {
+	return &DepositService{repo: repo, balances: balances, uow: uow, stripe: stripe, audit: audit}
+}
+
+// DepositCheckout is a created deposit and the Checkout page the user pays it on
+type DepositCheckout struct {
+	Deposit     *model.Deposit `json:"deposit"`
+	CheckoutURL string         `json:"checkout_url"`
+}
+
+func validateDeposit(userID int, amount model.Money) error {
+	switch {
+	case userID <= 0:
+		return invalid("user_id is required")
+	case amount.Amount < model.NewMoney(minDepositAmount, amount.Currency).Amount:
+		return invalid(fmt.Sprintf("amount must be at least %d", minDepositAmount))
+	case exceedsMaxAdjustment(amount):
+		return invalid(fmt.Sprintf("amount exceeds %d", maxAdjustmentDelta))
+	}
+	return nil
+}
+
+// CreateDeposit records a pending deposit and opens its Checkout session. The balance
+// is credited once Stripe reports the payment.
+func (s *DepositService) CreateDeposit(ctx context.Context, userID int, amount model.Money) (*DepositCheckout, error) {
+	if err := validateDeposit(userID, amount); err != nil {
+		return nil, err
+	}
+
+	deposit := &model.Deposit{UserID: userID, Amount: amount, Currency: s.stripe.Currency()}
+	if err := s.repo.CreateDeposit(ctx, deposit); err != nil {
+		return nil, err
+	}
+
+	session, err := s.stripe.CreateCheckoutSession(ctx, deposit.ID, userID, amount)
+	if err != nil {
+		if _, closeErr := s.repo.CloseDeposit(context.WithoutCancel(ctx), deposit.ID, model.DepositStatusFailed); closeErr != nil {
+			slog.ErrorContext(ctx, "failed to close deposit", "deposit_id", deposit.ID, "error", closeErr)
+		}
+		return nil, fmt.Errorf("failed to create checkout session: %w", err)
+	}
+	if err := s.repo.SetDepositSession(ctx, deposit.ID, session.ID); err != nil {
+		return nil, err
+	}
+	deposit.SessionID = session.ID
+
+	return &DepositCheckout{Deposit: deposit, CheckoutURL: session.URL}, nil
+}
+
+// HandleWebhook verifies and applies a Stripe webhook: payment_intent.succeeded credits
+// the deposit, checkout.session.expired closes it. Redelivered events are no-ops, as are
+// events of payments that are not deposits. Bad signatures fail with
+// payments.ErrInvalidSignature.
+func (s *DepositService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.stripe.ParseWebhook(payload, signature, time.Now())
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case "payment_intent.succeeded":
+		var intent payments.PaymentIntent
+		if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+			return fmt.Errorf("invalid payment intent in event %s: %w", event.ID, err)
+		}
+		depositID, ok := depositIDFrom(intent.Metadata)
+		if !ok {
+			return nil
+		}
+		return s.credit(ctx, depositID, intent.ID, intent.AmountReceived, intent.Currency)
+	case "checkout.session.expired":
+		var session payments.CheckoutSession
+		if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+			return fmt.Errorf("invalid checkout session in event %s: %w", event.ID, err)
+		}
+		depositID, ok := depositIDFrom(session.Metadata)
+		if !ok {
+			return nil
+		}
+		_, err := s.repo.CloseDeposit(ctx, depositID, model.DepositStatusExpired)
+		return err
+	}
+	return nil
+}
+
+func depositIDFrom(metadata map[string]string) (int, bool) {
+	id, err := strconv.Atoi(metadata["deposit_id"])
+	return id, err == nil && id > 0
+}
+
+// Reconcile looks up the Checkout sessions of deposits still pending a few minutes after
+// they were created, crediting the paid ones and closing the expired ones
+func (s *DepositService) Reconcile(ctx context.Context) error {
+	pending, err := s.repo.ListPendingDeposits(ctx, depositReconcileMinAge, depositReconcileBatch)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, d := range pending {
+		session, err := s.stripe.GetCheckoutSession(ctx, d.SessionID)
+		if err == nil {
+			switch {
+			case session.PaymentStatus == "paid":
+				slog.InfoContext(ctx, "crediting deposit missed by webhooks", "deposit_id", d.ID)
+				err = s.credit(ctx, d.ID, session.PaymentIntent, session.AmountTotal, session.Currency)
+			case session.Status == "expired":
+				_, err = s.repo.CloseDeposit(ctx, d.ID, model.DepositStatusExpired)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deposit %d: %w", d.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// credit adds a paid deposit to the user's balance, once. A payment that does not match
+// the deposit is not credited: the deposit is marked needs_review and audited, and the
+// event is acknowledged so Stripe does not redeliver it.
+func (s *DepositService) credit(ctx context.Context, depositID int, paymentIntentID string, cents int64, currency string) error {
+	return runAtomic(ctx, s.uow, "credit_deposit", func(ctx context.Context) error {
+		d, err := s.repo.GetDepositForUpdate(ctx, depositID)
+		if err != nil {
+			return err
+		}
+		if d.Status == model.DepositStatusSucceeded || d.Status == model.DepositStatusNeedsReview {
+			return nil
+		}
+		// Stripe amounts are in the currency's smallest unit, like Money
+		if cents != d.Amount.Amount || currency != d.Currency {
+			slog.WarnContext(ctx, "deposit payment does not match, flagged for review", "deposit_id", d.ID,
+				"paid_cents", cents, "paid_currency", currency, "expected_cents", d.Amount.Amount,
+				"expected_currency", d.Currency)
+			if err := s.repo.FlagDepositForReview(ctx, d.ID, paymentIntentID); err != nil {
+				return err
+			}
+			return s.audit.Record(ctx, "stripe", "deposit.needs_review", "deposit", strconv.Itoa(d.ID),
+				map[string]any{"status": d.Status},
+				map[string]any{"status": model.DepositStatusNeedsReview, "paid_cents": cents, "paid_currency": currency,
+					"payment_intent_id": paymentIntentID})
+		}
+
+		balance, err := s.balances.AdjustUserBalance(ctx, d.UserID, d.Amount,
+			model.LedgerKindDeposit, fmt.Sprintf("deposit:%d", d.ID))
+		if err != nil {
+			return err
+		}
+		if err := s.balances.CreateBalanceAdjustment(ctx, d.UserID, d.Amount, fmt.Sprintf("deposit #%d", d.ID), "deposit"); err != nil {
+			return err
+		}
+		if err := s.repo.MarkDepositSucceeded(ctx, d.ID, paymentIntentID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "stripe", "balance.deposit", "deposit", strconv.Itoa(d.ID),
+			map[string]any{"balance": balance.Sub(d.Amount)},
+			map[string]any{"balance": balance, "amount": d.Amount, "payment_intent_id": paymentIntentID})
+	})
+}
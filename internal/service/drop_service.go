@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+)
+
+// ErrDropUnavailable is matched (via errors.Is) by DropError
+var ErrDropUnavailable = errors.New("drop unavailable")
+
+// ErrDropConflict is returned when creating a drop for an item whose drop has not ended
+var ErrDropConflict = errors.New("item already has a drop that has not ended")
+
+// Reasons reported in DropError.Reason
+const (
+	DropReasonNotStarted   = "not_started"
+	DropReasonSoldOut      = "sold_out"
+	DropReasonPerUserLimit = "per_user_limit"
+)
+
+// DropError describes why a drop refused a purchase
+type DropError struct {
+	DropID int    `json:"drop_id"`
+	Reason string `json:"reason"`
+	// StartsAt is set when the drop has not started
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	// Limit, Current and Requested are the pool or the per-user cap, what was sold from
+	// it and the purchase's quantity
+	Limit     int `json:"limit,omitempty"`
+	Current   int `json:"current,omitempty"`
+	Requested int `json:"requested,omitempty"`
+}
+
+func (e *DropError) Error() string {
+	switch e.Reason {
+	case DropReasonNotStarted:
+		return fmt.Sprintf("drop %d has not started, it starts at %s", e.DropID, e.StartsAt.UTC().Format(time.RFC3339))
+	case DropReasonSoldOut:
+		return fmt.Sprintf("drop %d is sold out (%d of %d sold, requested %d)", e.DropID, e.Current, e.Limit, e.Requested)
+	default:
+		return fmt.Sprintf("drop %d allows %d per user (bought %d, requested %d)", e.DropID, e.Limit, e.Current, e.Requested)
+	}
+}
+
+func (e *DropError) Is(target error) bool {
+	return target == ErrDropUnavailable
+}
+
+// DropStatus is a drop as clients see it, with what they need for a countdown
+type DropStatus struct {
+	model.Drop
+	State     string `json:"state"`
+	Remaining int    `json:"remaining"`
+	// StartsIn and EndsIn are the seconds until the drop starts and ends, while it has not
+	StartsIn   *int64    `json:"starts_in,omitempty"`
+	EndsIn     *int64    `json:"ends_in,omitempty"`
+	ServerTime time.Time `json:"server_time"`
+}
+
+func newDropStatus(d model.Drop, now time.Time) DropStatus {
+	status := DropStatus{Drop: d, State: d.State(now), Remaining: max(d.Stock-d.Sold, 0), ServerTime: now}
+	if now.Before(d.StartsAt) {
+		seconds := int64(d.StartsAt.Sub(now).Seconds())
+		status.StartsIn = &seconds
+	}
+	if d.EndsAt != nil && now.Before(*d.EndsAt) {
+		seconds := int64(d.EndsAt.Sub(now).Seconds())
+		status.EndsIn = &seconds
+	}
+	return status
+}
+
+// DropStore keeps drops, implemented by repository.DropRepository
+type DropStore interface {
+	CreateDrop(ctx context.Context, d *model.Drop) error
+	GetDrop(ctx context.Context, id int) (*model.Drop, error)
+	// GetOpenDrop returns the item's drop that has not ended, nil if there is none
+	GetOpenDrop(ctx context.Context, itemID int) (*model.Drop, error)
+	ListOpenDrops(ctx context.Context) ([]model.Drop, error)
+	CountUserDropQuantity(ctx context.Context, dropID, userID int) (int, error)
+}
+
+// ItemLocker locks an item row, implemented by repository.ShopRepository
+type ItemLocker interface {
+	// GetItemForUpdate locks the item and returns its price and stock
+	GetItemForUpdate(ctx context.Context, itemID int) (model.Money, int, error)
+}
+
+type DropService struct {
+	repo  DropStore
+	items ItemLocker
+	uow   db.UnitOfWork
+	audit *AuditService
+	now   func() time.Time
+}
+
+func NewDropService(repo DropStore, items ItemLocker, uow db.UnitOfWork, audit *AuditService) *DropService {
+	return &DropService{repo: repo, items: items, uow: uow, audit: audit, now: time.Now}
+}
+
+// WithDrops makes purchases of items in a drop honour its window, pool and per-user cap
+func WithDrops(drops *DropService) ShopServiceOption {
+	return func(s *ShopService) {
+		s.drops = drops
+	}
+}
+
+// CreateDrop schedules a drop; an item has at most one drop that has not ended
+func (s *DropService) CreateDrop(ctx context.Context, d *model.Drop) error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" || len(d.Name) > 200 {
+		return invalid("name must be 1-200 characters")
+	}
+	if d.StartsAt.IsZero() {
+		return invalid("starts_at is required")
+	}
+	if d.EndsAt != nil && !d.EndsAt.After(d.StartsAt) {
+		return invalid("ends_at must be after starts_at")
+	}
+	if d.Stock <= 0 {
+		return invalid("stock must be positive")
+	}
+	if d.PerUserLimit <= 0 {
+		return invalid("per_user_limit must be positive")
+	}
+	d.StartsAt = d.StartsAt.UTC()
+	if d.EndsAt != nil {
+		endsAt := d.EndsAt.UTC()
+		d.EndsAt = &endsAt
+	}
+
+	return runAtomic(ctx, s.uow, "create_drop", func(ctx context.Context) error {
+		// The item's lock serializes this check with concurrent creations and purchases
+		if _, _, err := s.items.GetItemForUpdate(ctx, d.ItemID); err != nil {
+			return err
+		}
+		open, err := s.repo.GetOpenDrop(ctx, d.ItemID)
+		if err != nil {
+			return err
+		}
+		if open != nil {
+			return ErrDropConflict
+		}
+		if err := s.repo.CreateDrop(ctx, d); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "drop.create", "drop", strconv.Itoa(d.ID), nil, d)
+	})
+}
+
+// GetDrop returns the drop's status
+func (s *DropService) GetDrop(ctx context.Context, id int) (*DropStatus, error) {
+	d, err := s.repo.GetDrop(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	status := newDropStatus(*d, s.now().UTC())
+	return &status, nil
+}
+
+// ListDrops returns the status of the drops that have not ended, by start time
+func (s *DropService) ListDrops(ctx context.Context) ([]DropStatus, error) {
+	drops, err := s.repo.ListOpenDrops(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	statuses := make([]DropStatus, len(drops))
+	for i, d := range drops {
+		statuses[i] = newDropStatus(d, now)
+	}
+	return statuses, nil
+}
+
+// Reserve checks a purchase of quantity of the item by the user against the item's drop
+// and returns the drop's id, nil when the item is in no drop. It must run inside the
+// purchase transaction after the item row is locked, which serializes the drop's
+// purchases; the order then counts towards the drop.
+func (s *DropService) Reserve(ctx context.Context, userID, itemID, quantity int) (*int, error) {
+	d, err := s.repo.GetOpenDrop(ctx, itemID)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	bought, err := s.repo.CountUserDropQuantity(ctx, d.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDrop(d, bought, quantity, s.now()); err != nil {
+		return nil, err
+	}
+	return &d.ID, nil
+}
+
+// checkDrop evaluates a purchase of quantity by a user who bought bought in the drop
+func checkDrop(d *model.Drop, bought, quantity int, now time.Time) error {
+	if now.Before(d.StartsAt) {
+		startsAt := d.StartsAt
+		return &DropError{DropID: d.ID, Reason: DropReasonNotStarted, StartsAt: &startsAt}
+	}
+	if d.Sold+quantity > d.Stock {
+		return &DropError{DropID: d.ID, Reason: DropReasonSoldOut, Limit: d.Stock, Current: d.Sold, Requested: quantity}
+	}
+	if bought+quantity > d.PerUserLimit {
+		return &DropError{DropID: d.ID, Reason: DropReasonPerUserLimit, Limit: d.PerUserLimit, Current: bought, Requested: quantity}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDrop(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	drop := &model.Drop{ID: 7, StartsAt: now, Stock: 10, Sold: 8, PerUserLimit: 3}
+
+	err := checkDrop(drop, 0, 1, now.Add(-time.Second))
+	var dropErr *DropError
+	require.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, DropReasonNotStarted, dropErr.Reason)
+	assert.Equal(t, now, *dropErr.StartsAt)
+	assert.True(t, errors.Is(err, ErrDropUnavailable))
+
+	require.NoError(t, checkDrop(drop, 1, 2, now))
+
+	err = checkDrop(drop, 0, 3, now)
+	require.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, DropError{DropID: 7, Reason: DropReasonSoldOut, Limit: 10, Current: 8, Requested: 3}, *dropErr)
+
+	err = checkDrop(drop, 2, 2, now)
+	require.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, DropError{DropID: 7, Reason: DropReasonPerUserLimit, Limit: 3, Current: 2, Requested: 2}, *dropErr)
+}
+
+func TestDropStatus(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := now.Add(time.Hour)
+	drop := model.Drop{StartsAt: now.Add(90 * time.Second), EndsAt: &endsAt, Stock: 10, Sold: 4}
+
+	status := newDropStatus(drop, now)
+	assert.Equal(t, model.DropScheduled, status.State)
+	assert.Equal(t, 6, status.Remaining)
+	assert.Equal(t, int64(90), *status.StartsIn)
+	assert.Equal(t, int64(3600), *status.EndsIn)
+
+	status = newDropStatus(drop, now.Add(2*time.Minute))
+	assert.Equal(t, model.DropLive, status.State)
+	assert.Nil(t, status.StartsIn)
+
+	drop.Sold = 10
+	assert.Equal(t, model.DropSoldOut, newDropStatus(drop, now.Add(2*time.Minute)).State)
+	status = newDropStatus(drop, endsAt)
+	assert.Equal(t, model.DropEnded, status.State)
+	assert.Nil(t, status.EndsIn)
+}
+
+func TestCreateDrop_Validation(t *testing.T) {
+	s := NewDropService(nil, nil, nil, nil)
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	for _, d := range []model.Drop{
+		{ItemID: 1, StartsAt: now, Stock: 1, PerUserLimit: 1},
+		{ItemID: 1, Name: "no start", Stock: 1, PerUserLimit: 1},
+		{ItemID: 1, Name: "ends first", StartsAt: now, EndsAt: &before, Stock: 1, PerUserLimit: 1},
+		{ItemID: 1, Name: "no stock", StartsAt: now, PerUserLimit: 1},
+		{ItemID: 1, Name: "no cap", StartsAt: now, Stock: 1},
+	} {
+		err := s.CreateDrop(context.Background(), &d)
+		assert.True(t, errors.Is(err, ErrValidation), d.Name)
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "errors"
+
+// ErrValidation is matched (via errors.Is) by every input validation error returned by services
+var ErrValidation = errors.New("validation failed")
+
+type validationError struct {
+	msg string
+}
+
+func (e *validationError) Error() string {
+	return e.msg
+}
+
+func (e *validationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// invalid returns a validation error carrying msg as its message
+func invalid(msg string) error {
+	return &validationError{msg: msg}
+}
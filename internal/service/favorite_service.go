@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+const maxMarketHashNameLength = 255
+
+// FavoriteItem is a favorite with the item's current Skinport listing
+type FavoriteItem struct {
+	MarketHashName string    `json:"market_hash_name"`
+	AlertBelow     *float64  `json:"alert_below,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	// Listed is false when Skinport has no listing for the item or prices are unavailable
+	Listed              bool     `json:"listed"`
+	Currency            string   `json:"currency,omitempty"`
+	MinPriceTradable    *float64 `json:"min_price_tradable"`
+	MinPriceNonTradable *float64 `json:"min_price_non_tradable"`
+	Quantity            int      `json:"quantity"`
+}
+
+// FavoriteStore keeps users' favorite items, implemented by repository.FavoriteRepository
+type FavoriteStore interface {
+	AddFavorite(ctx context.Context, f *model.Favorite) error
+	ListFavorites(ctx context.Context, userID int) ([]model.Favorite, error)
+	RemoveFavorite(ctx context.Context, userID int, marketHashName string) error
+}
+
+type FavoriteService struct {
+	repo     FavoriteStore
+	skinport *skinport.Factory
+}
+
+func NewFavoriteService(repo FavoriteStore, skinportClients *skinport.Factory) *FavoriteService {
+	return &FavoriteService{repo: repo, skinport: skinportClients}
+}
+
+func normalizeMarketHashName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", invalid("market_hash_name is required")
+	}
+	if len(name) > maxMarketHashNameLength {
+		return "", invalid("market_hash_name is too long")
+	}
+	return name, nil
+}
+
+// AddFavorite makes the user follow a Skinport item, with a price alert when alertBelow
+// is set. Adding it again only replaces the alert.
+func (s *FavoriteService) AddFavorite(ctx context.Context, userID int, marketHashName string, alertBelow *float64) (*model.Favorite, error) {
+	name, err := normalizeMarketHashName(marketHashName)
+	if err != nil {
+		return nil, err
+	}
+	if alertBelow != nil && *alertBelow <= 0 {
+		return nil, invalid("alert_below must be greater than 0")
+	}
+	f := &model.Favorite{UserID: userID, MarketHashName: name, AlertBelow: alertBelow}
+	if err := s.repo.AddFavorite(ctx, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FavoriteService) RemoveFavorite(ctx context.Context, userID int, marketHashName string) error {
+	name, err := normalizeMarketHashName(marketHashName)
+	if err != nil {
+		return err
+	}
+	return s.repo.RemoveFavorite(ctx, userID, name)
+}
+
+// ListFavorites returns the user's favorites with their current Skinport prices for
+// appID/currency, served from the client's cache when warm. Favorites are still
+// returned, unlisted, when Skinport cannot be reached.
+func (s *FavoriteService) ListFavorites(ctx context.Context, userID int, appID, currency string) ([]FavoriteItem, error) {
+	if err := skinport.ValidateAppID(appID); err != nil {
+		return nil, invalid(err.Error())
+	}
+	if _, err := skinport.NormalizeCurrency(currency); err != nil {
+		return nil, invalid(fmt.Sprintf("%v (allowed: %s)", err, strings.Join(skinport.Currencies(), ", ")))
+	}
+
+	favorites, err := s.repo.ListFavorites(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]FavoriteItem, len(favorites))
+	names := make([]string, len(favorites))
+	for i, f := range favorites {
+		result[i] = FavoriteItem{MarketHashName: f.MarketHashName, AlertBelow: f.AlertBelow, CreatedAt: f.CreatedAt}
+		names[i] = f.MarketHashName
+	}
+	if len(favorites) == 0 {
+		return result, nil
+	}
+
+	client, err := s.skinport.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := client.LookupItems(ctx, skinport.ItemsParams{AppID: appID, Currency: currency}, names)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "favorites served without skinport prices", "user_id", userID, "error", err)
+		return result, nil
+	}
+	enrichFavorites(result, items)
+	return result, nil
+}
+
+// enrichFavorites fills in the Skinport listing of every favorite found in items
+func enrichFavorites(favorites []FavoriteItem, items []skinport.ResponseItem) {
+	index := make(map[string]int, len(favorites))
+	for i, f := range favorites {
+		index[f.MarketHashName] = i
+	}
+	for _, item := range items {
+		i, ok := index[item.MarketHashName]
+		if !ok {
+			continue
+		}
+		f := &favorites[i]
+		f.Listed = true
+		f.Currency = item.Currency
+		f.MinPriceTradable = item.MinPriceTradable
+		f.MinPriceNonTradable = item.MinPriceNonTradable
+		f.Quantity = item.Quantity
+	}
+}
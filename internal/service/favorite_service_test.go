@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrichFavorites(t *testing.T) {
+	price := 12.5
+	favorites := []FavoriteItem{{MarketHashName: "AK-47 | Redline (Field-Tested)"}, {MarketHashName: "Delisted"}}
+	enrichFavorites(favorites, []skinport.ResponseItem{
+		{MarketHashName: "AWP | Asiimov (Field-Tested)", Currency: "EUR", Quantity: 3},
+		{MarketHashName: "AK-47 | Redline (Field-Tested)", Currency: "EUR", MinPriceTradable: &price, Quantity: 7},
+	})
+
+	assert.True(t, favorites[0].Listed)
+	assert.Equal(t, "EUR", favorites[0].Currency)
+	assert.Equal(t, &price, favorites[0].MinPriceTradable)
+	assert.Equal(t, 7, favorites[0].Quantity)
+	assert.False(t, favorites[1].Listed)
+}
+
+func TestNormalizeMarketHashName(t *testing.T) {
+	name, err := normalizeMarketHashName("  AK-47 | Redline (Field-Tested) ")
+	assert.NoError(t, err)
+	assert.Equal(t, "AK-47 | Redline (Field-Tested)", name)
+
+	for _, bad := range []string{"", "   ", strings.Repeat("x", maxMarketHashNameLength+1)} {
+		_, err := normalizeMarketHashName(bad)
+		assert.True(t, errors.Is(err, ErrValidation), err)
+	}
+}
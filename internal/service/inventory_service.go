@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+)
+
+// InventoryStore keeps what users own, implemented by repository.InventoryRepository
+type InventoryStore interface {
+	ListUserInventory(ctx context.Context, userID int) ([]model.InventoryItem, error)
+	// LockInventories locks the users' rows of the item, creating missing ones empty,
+	// and returns what each owns
+	LockInventories(ctx context.Context, itemID int, userIDs ...int) (map[int]int, error)
+	// AddInventory adds delta to a locked row and returns the new quantity
+	AddInventory(ctx context.Context, userID, itemID, delta int) (int, error)
+}
+
+type InventoryService struct {
+	repo  InventoryStore
+	uow   db.UnitOfWork
+	audit *AuditService
+}
+
+func NewInventoryService(repo InventoryStore, uow db.UnitOfWork, audit *AuditService) *InventoryService {
+	return &InventoryService{repo: repo, uow: uow, audit: audit}
+}
+
+// ListUserInventory returns the items the user owns
+func (s *InventoryService) ListUserInventory(ctx context.Context, userID int) ([]model.InventoryItem, error) {
+	return s.repo.ListUserInventory(ctx, userID)
+}
+
+func validateTransfer(t *model.InventoryTransfer) error {
+	switch {
+	case t.FromUserID <= 0 || t.ToUserID <= 0 || t.ItemID <= 0:
+		return invalid("from_user_id, to_user_id and item_id are required")
+	case t.FromUserID == t.ToUserID:
+		return invalid("cannot transfer to the same user")
+	case t.Quantity <= 0:
+		return invalid("quantity must be greater than 0")
+	}
+	return nil
+}
+
+// Transfer moves t.Quantity of t.ItemID from t.FromUserID's inventory to t.ToUserID's,
+// filling in what each owns afterwards. Both inventories are locked for the transfer,
+// which is audited.
+func (s *InventoryService) Transfer(ctx context.Context, t *model.InventoryTransfer) error {
+	if err := validateTransfer(t); err != nil {
+		return err
+	}
+
+	return runAtomic(ctx, s.uow, "transfer_inventory", func(ctx context.Context) error {
+		owned, err := s.repo.LockInventories(ctx, t.ItemID, t.FromUserID, t.ToUserID)
+		if err != nil {
+			return err
+		}
+		if owned[t.FromUserID] < t.Quantity {
+			return errors.New("insufficient quantity")
+		}
+
+		if t.FromQuantity, err = s.repo.AddInventory(ctx, t.FromUserID, t.ItemID, -t.Quantity); err != nil {
+			return err
+		}
+		if t.ToQuantity, err = s.repo.AddInventory(ctx, t.ToUserID, t.ItemID, t.Quantity); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, fmt.Sprintf("user:%d", t.FromUserID), "inventory.transfer", "item", strconv.Itoa(t.ItemID),
+			map[string]any{"from_quantity": owned[t.FromUserID], "to_quantity": owned[t.ToUserID]},
+			t)
+	})
+}
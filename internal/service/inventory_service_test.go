@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryInventory is an in-memory InventoryStore whose writes are undone with their db.Memory unit
+type memoryInventory struct {
+	owned map[[2]int]int // by user id, item id
+	// failAdd fails AddInventory for this user
+	failAdd int
+}
+
+func (m *memoryInventory) ListUserInventory(ctx context.Context, userID int) ([]model.InventoryItem, error) {
+	var items []model.InventoryItem
+	for key, quantity := range m.owned {
+		if key[0] == userID && quantity > 0 {
+			items = append(items, model.InventoryItem{UserID: userID, ItemID: key[1], Quantity: quantity})
+		}
+	}
+	return items, nil
+}
+
+func (m *memoryInventory) LockInventories(ctx context.Context, itemID int, userIDs ...int) (map[int]int, error) {
+	owned := map[int]int{}
+	for _, userID := range userIDs {
+		owned[userID] = m.owned[[2]int{userID, itemID}]
+	}
+	return owned, nil
+}
+
+func (m *memoryInventory) AddInventory(ctx context.Context, userID, itemID, delta int) (int, error) {
+	if userID == m.failAdd {
+		return 0, errors.New("connection reset")
+	}
+	key := [2]int{userID, itemID}
+	m.owned[key] += delta
+	db.OnRollback(ctx, func() { m.owned[key] -= delta })
+	return m.owned[key], nil
+}
+
+func TestValidateTransfer(t *testing.T) {
+	assert.NoError(t, validateTransfer(&model.InventoryTransfer{FromUserID: 1, ToUserID: 2, ItemID: 3, Quantity: 1}))
+
+	invalid := []model.InventoryTransfer{
+		{ToUserID: 2, ItemID: 3, Quantity: 1},
+		{FromUserID: 1, ItemID: 3, Quantity: 1},
+		{FromUserID: 1, ToUserID: 2, Quantity: 1},
+		{FromUserID: 1, ToUserID: 1, ItemID: 3, Quantity: 1},
+		{FromUserID: 1, ToUserID: 2, ItemID: 3},
+		{FromUserID: 1, ToUserID: 2, ItemID: 3, Quantity: -1},
+	}
+	for _, transfer := range invalid {
+		err := validateTransfer(&transfer)
+		assert.True(t, errors.Is(err, ErrValidation), "%+v: %v", transfer, err)
+	}
+}
+
+func TestInventoryService_Transfer(t *testing.T) {
+	inventory := &memoryInventory{owned: map[[2]int]int{{1, 7}: 5}}
+	svc := NewInventoryService(inventory, &db.Memory{}, nil)
+	ctx := context.Background()
+
+	transfer := model.InventoryTransfer{FromUserID: 1, ToUserID: 2, ItemID: 7, Quantity: 2}
+	require.NoError(t, svc.Transfer(ctx, &transfer))
+	assert.Equal(t, 3, transfer.FromQuantity)
+	assert.Equal(t, 2, transfer.ToQuantity)
+
+	transfer = model.InventoryTransfer{FromUserID: 1, ToUserID: 2, ItemID: 7, Quantity: 4}
+	assert.EqualError(t, svc.Transfer(ctx, &transfer), "insufficient quantity")
+
+	// A failure after the debit rolls the whole transfer back
+	inventory.failAdd = 2
+	transfer = model.InventoryTransfer{FromUserID: 1, ToUserID: 2, ItemID: 7, Quantity: 1}
+	assert.EqualError(t, svc.Transfer(ctx, &transfer), "connection reset")
+	assert.Equal(t, map[[2]int]int{{1, 7}: 3, {2, 7}: 2}, inventory.owned)
+}
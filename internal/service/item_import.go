@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/storage"
+)
+
+// itemImportChunkSize is the number of rows copied per transaction
+const itemImportChunkSize = 5000
+
+// Actions of an applied item import row
+const (
+	ItemImportCreated = "created"
+	ItemImportUpdated = "updated"
+)
+
+type ItemImportRowResult struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name"`
+	ItemID int    `json:"item_id,omitempty"`
+	// Action is created or updated for applied rows
+	Action string `json:"action,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	item model.ItemImport
+}
+
+func (row ItemImportRowResult) status() string { return row.Status }
+
+func (ItemImportRowResult) csvHeader() []string {
+	return []string{"line", "name", "item_id", "action", "status", "error"}
+}
+
+func (row ItemImportRowResult) csvRecord() []string {
+	itemID := ""
+	if row.ItemID != 0 {
+		itemID = strconv.Itoa(row.ItemID)
+	}
+	return []string{strconv.Itoa(row.Line), row.Name, itemID, row.Action, row.Status, row.Error}
+}
+
+type ItemImportReport struct {
+	importReport[ItemImportRowResult]
+	Upsert bool `json:"upsert"`
+}
+
+// ItemImportStore bulk loads and exports items, implemented by repository.ShopRepository
+type ItemImportStore interface {
+	// CopyItems inserts the items, updating existing ones when upsert is set, and
+	// returns the ids of the created and updated items by name
+	CopyItems(ctx context.Context, items []model.ItemImport, upsert bool) (map[string]int, map[string]int, error)
+	CountItemsByName(ctx context.Context, names []string) (map[string]int, error)
+	ExportItemsCSV(ctx context.Context, w io.Writer) error
+}
+
+// CategoryLister lists categories, implemented by repository.CategoryRepository
+type CategoryLister interface {
+	ListCategories(ctx context.Context) ([]model.Category, error)
+}
+
+// ItemImportService manages the catalogue from spreadsheets: CSV imports and exports
+type ItemImportService struct {
+	repo       ItemImportStore
+	categories CategoryLister
+	uow        db.UnitOfWork
+	audit      *AuditService
+	importReports
+}
+
+func NewItemImportService(repo ItemImportStore, categories CategoryLister, uow db.UnitOfWork, audit *AuditService, reports storage.Blob) *ItemImportService {
+	return &ItemImportService{repo: repo, categories: categories, uow: uow, audit: audit,
+		importReports: importReports{reports, "item-imports"}}
+}
+
+// ExportItems writes the catalogue as CSV, in the format ImportItems reads
+func (s *ItemImportService) ExportItems(ctx context.Context, w io.Writer) error {
+	return s.repo.ExportItemsCSV(ctx, w)
+}
+
+// ImportItems streams a CSV with name, price and stock columns and optionally category
+// (a slug), tags ("|"-separated), description and image_url; other columns such as the
+// export's id are ignored. Rows are copied in chunked transactions as new items, or with
+// upsert update the item of the same name. An optional column left out keeps an updated
+// item's value, an empty cell clears it. Invalid rows, names repeated in the file or
+// matching several items, and unknown categories are reported and skipped; an unexpected
+// database error rolls back the whole chunk it happened in.
+func (s *ItemImportService) ImportItems(ctx context.Context, in io.Reader, upsert, dryRun bool) (*ItemImportReport, error) {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "price", "stock"} {
+		if _, ok := cols[name]; !ok {
+			return nil, errors.New("csv header must contain name, price and stock columns")
+		}
+	}
+
+	report := &ItemImportReport{importReport: importReport[ItemImportRowResult]{DryRun: dryRun}, Upsert: upsert}
+	chunk := make([]ItemImportRowResult, 0, itemImportChunkSize)
+	seen := map[string]int{}
+	var categories map[string]bool
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if !dryRun {
+			if categories == nil {
+				if categories, err = s.categorySlugs(ctx); err != nil {
+					return err
+				}
+			}
+			if err := s.applyChunk(ctx, chunk, categories, upsert); err != nil {
+				return err
+			}
+		}
+		for _, row := range chunk {
+			report.add(row)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		report.Total++
+
+		row := ItemImportRowResult{Line: line}
+		if err == nil {
+			row.item, err = parseItemImportRow(record, cols)
+			row.Name = row.item.Name
+		}
+		if err == nil {
+			if first, ok := seen[row.Name]; ok {
+				err = invalid(fmt.Sprintf("name repeats line %d", first))
+			} else {
+				seen[row.Name] = line
+			}
+		}
+		if err != nil {
+			row.Status, row.Error = ImportRowInvalid, err.Error()
+			report.add(row)
+			continue
+		}
+
+		row.Status = ImportRowValid
+		chunk = append(chunk, row)
+		if len(chunk) == itemImportChunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (s *ItemImportService) categorySlugs(ctx context.Context) (map[string]bool, error) {
+	list, err := s.categories.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slugs := make(map[string]bool, len(list))
+	for _, c := range list {
+		slugs[c.Slug] = true
+	}
+	return slugs, nil
+}
+
+// applyChunk copies the chunk's rows in one transaction, updating each row's status in place
+func (s *ItemImportService) applyChunk(ctx context.Context, chunk []ItemImportRowResult, categories map[string]bool, upsert bool) error {
+	err := runAtomic(ctx, s.uow, "import_items", func(ctx context.Context) error {
+		var counts map[string]int
+		if upsert {
+			names := make([]string, len(chunk))
+			for i, row := range chunk {
+				names[i] = row.Name
+			}
+			var err error
+			if counts, err = s.repo.CountItemsByName(ctx, names); err != nil {
+				return err
+			}
+		}
+
+		items := make([]model.ItemImport, 0, len(chunk))
+		for i := range chunk {
+			row := &chunk[i]
+			row.Status, row.Error, row.ItemID, row.Action = ImportRowApplied, "", 0, ""
+			switch {
+			case row.item.Category != nil && *row.item.Category != "" && !categories[*row.item.Category]:
+				row.Status, row.Error = ImportRowFailed, "category not found"
+			case counts[row.Name] > 1:
+				row.Status, row.Error = ImportRowFailed, fmt.Sprintf("name matches %d items", counts[row.Name])
+			default:
+				items = append(items, row.item)
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		created, updated, err := s.repo.CopyItems(ctx, items, upsert)
+		if err != nil {
+			return err
+		}
+		for i := range chunk {
+			row := &chunk[i]
+			if id, ok := updated[row.Name]; ok {
+				row.ItemID, row.Action = id, ItemImportUpdated
+			} else if id, ok := created[row.Name]; ok {
+				row.ItemID, row.Action = id, ItemImportCreated
+			}
+		}
+		return s.audit.Record(ctx, "admin", "items.import", "item", "", nil,
+			map[string]any{"created": len(created), "updated": len(updated), "first_line": chunk[0].Line, "last_line": chunk[len(chunk)-1].Line})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			for i := range chunk {
+				chunk[i].Status, chunk[i].Error = ImportRowCancelled, ctx.Err().Error()
+			}
+			return err
+		}
+		// The chunk was rolled back, nothing in it has been imported
+		for i := range chunk {
+			chunk[i].Status, chunk[i].Error, chunk[i].ItemID, chunk[i].Action = ImportRowFailed, "chunk rolled back: "+err.Error(), 0, ""
+		}
+	}
+	return nil
+}
+
+func parseItemImportRow(record []string, cols map[string]int) (model.ItemImport, error) {
+	var item model.ItemImport
+	// optional returns nil for a column missing from the header
+	optional := func(name string) *string {
+		i, ok := cols[name]
+		if !ok {
+			return nil
+		}
+		v := ""
+		if i < len(record) {
+			v = strings.TrimSpace(record[i])
+		}
+		return &v
+	}
+	field := func(name string) string {
+		return *optional(name)
+	}
+
+	item.Name = field("name")
+	if item.Name == "" {
+		return item, invalid("name is required")
+	}
+	if len(item.Name) > 255 {
+		return item, invalid("name must be at most 255 characters")
+	}
+	if field("price") == "" {
+		return item, invalid("price is required")
+	}
+	if err := item.Price.UnmarshalJSON([]byte(field("price"))); err != nil {
+		return item, invalid(fmt.Sprintf("invalid price: %v", err))
+	}
+	if item.Price.Amount < 0 {
+		return item, invalid("price must not be negative")
+	}
+	stock, err := strconv.Atoi(field("stock"))
+	if err != nil || stock < 0 {
+		return item, invalid(fmt.Sprintf("invalid stock %q", field("stock")))
+	}
+	item.Stock = stock
+
+	item.Description = optional("description")
+	item.ImageURL = optional("image_url")
+	if err := validateItemMetadata(model.ItemMetadata{Description: item.Description, ImageURL: item.ImageURL}); err != nil {
+		return item, err
+	}
+	if item.Category = optional("category"); item.Category != nil {
+		*item.Category = strings.ToLower(*item.Category)
+	}
+	if tags := optional("tags"); tags != nil {
+		list := []string{}
+		if *tags != "" {
+			list = strings.Split(*tags, "|")
+		}
+		if item.Tags, err = validateTags(list); err != nil {
+			return item, err
+		}
+	}
+	return item, nil
+}
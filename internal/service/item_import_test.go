@@ -0,0 +1,64 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportItems_DryRun(t *testing.T) {
+	csvData := `id,name,price,stock,category,tags,description
+7,Knife,12.50,3,Knives,Rare|steel|rare,Sharp
+,Gloves,3,0,,,
+,Knife,1,1,,,
+,,1,1,,,
+,Case,1.999,1,,,
+,Case,1,-1,,,
+,Sticker,,1,,,
+`
+	svc := NewItemImportService(nil, nil, nil, nil, nil)
+
+	report, err := svc.ImportItems(context.Background(), strings.NewReader(csvData), true, true)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Total)
+	assert.Equal(t, 2, report.Applied)
+	assert.Equal(t, 5, report.Failed)
+
+	rows := map[int]ItemImportRowResult{}
+	for _, row := range report.Rows {
+		rows[row.Line] = row
+	}
+	assert.Equal(t, ImportRowValid, rows[2].Status)
+	assert.Equal(t, "knives", *rows[2].item.Category)
+	assert.Equal(t, []string{"rare", "steel"}, rows[2].item.Tags)
+	assert.Equal(t, int64(1250), rows[2].item.Price.Amount)
+	assert.Equal(t, []string{}, rows[3].item.Tags, "an empty cell clears the tags")
+	assert.Nil(t, rows[3].item.ImageURL, "a missing column leaves the image unchanged")
+	assert.Equal(t, "name repeats line 2", rows[4].Error)
+	assert.Equal(t, "name is required", rows[5].Error)
+	assert.Contains(t, rows[6].Error, "more than 2 decimals")
+	assert.Contains(t, rows[7].Error, "invalid stock")
+	assert.Equal(t, "price is required", rows[8].Error)
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteCSV(&out))
+	assert.True(t, strings.HasPrefix(out.String(), "line,name,item_id,action,status,error\n"))
+
+	var body map[string]any
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, true, body["upsert"])
+	assert.Equal(t, float64(7), body["total"])
+}
+
+func TestImportItems_Header(t *testing.T) {
+	svc := NewItemImportService(nil, nil, nil, nil, nil)
+	_, err := svc.ImportItems(context.Background(), strings.NewReader("name,price\nKnife,1\n"), false, true)
+	assert.ErrorContains(t, err, "name, price and stock")
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/storage"
+)
+
+const (
+	// MaxItemImageBytes bounds uploaded item images
+	MaxItemImageBytes = 5 << 20
+
+	maxItemDescriptionLength = 5000
+	maxItemAttributesBytes   = 16 << 10
+)
+
+// itemImageTypes are the accepted image formats, by sniffed content type
+var itemImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ErrItemImageNotFound is returned for items without an uploaded image
+var ErrItemImageNotFound = errors.New("item image not found")
+
+// ItemMediaStore keeps item details and images, implemented by repository.ShopRepository
+type ItemMediaStore interface {
+	GetItem(ctx context.Context, itemID int) (*model.Item, error)
+	// UpdateItemMetadata applies the non-nil fields and returns the storage key of an
+	// uploaded image that setting the image URL dropped
+	UpdateItemMetadata(ctx context.Context, itemID int, m model.ItemMetadata) (string, error)
+	GetItemImageKey(ctx context.Context, itemID int) (string, error)
+	// SetItemImage stores the image's key and URL and returns the key it replaced
+	SetItemImage(ctx context.Context, itemID int, key, url string) (string, error)
+}
+
+// ItemMediaService manages item descriptions, attributes and images. Uploaded images
+// are kept in blob storage and served through the API.
+type ItemMediaService struct {
+	repo  ItemMediaStore
+	uow   db.UnitOfWork
+	blobs storage.Blob
+	audit *AuditService
+}
+
+func NewItemMediaService(repo ItemMediaStore, uow db.UnitOfWork, blobs storage.Blob, audit *AuditService) *ItemMediaService {
+	return &ItemMediaService{repo: repo, uow: uow, blobs: blobs, audit: audit}
+}
+
+// UpdateMetadata updates the item's details, nil fields are left unchanged
+func (s *ItemMediaService) UpdateMetadata(ctx context.Context, itemID int, m model.ItemMetadata) (*model.Item, error) {
+	if err := validateItemMetadata(m); err != nil {
+		return nil, err
+	}
+
+	var item *model.Item
+	var previousKey string
+	err := runAtomic(ctx, s.uow, "update_item_metadata", func(ctx context.Context) error {
+		var err error
+		if previousKey, err = s.repo.UpdateItemMetadata(ctx, itemID, m); err != nil {
+			return err
+		}
+		if item, err = s.repo.GetItem(ctx, itemID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "item.update_metadata", "item", strconv.Itoa(itemID), nil, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.deleteBlob(ctx, previousKey)
+	return item, nil
+}
+
+func validateItemMetadata(m model.ItemMetadata) error {
+	if m.Description != nil && len(*m.Description) > maxItemDescriptionLength {
+		return invalid(fmt.Sprintf("description must be at most %d characters", maxItemDescriptionLength))
+	}
+	if m.ImageURL != nil && *m.ImageURL != "" {
+		u, err := url.Parse(*m.ImageURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(*m.ImageURL) > 1024 {
+			return invalid("image_url must be an absolute http(s) URL")
+		}
+	}
+	if m.Attributes != nil {
+		data, err := json.Marshal(m.Attributes)
+		if err != nil {
+			return invalid("invalid attributes")
+		}
+		if len(data) > maxItemAttributesBytes {
+			return invalid(fmt.Sprintf("attributes must be at most %d bytes of JSON", maxItemAttributesBytes))
+		}
+	}
+	return nil
+}
+
+// UploadImage stores data as the item's image, replacing its previous image
+func (s *ItemMediaService) UploadImage(ctx context.Context, itemID int, data []byte) (*model.Item, error) {
+	if len(data) == 0 {
+		return nil, invalid("image is empty")
+	}
+	if len(data) > MaxItemImageBytes {
+		return nil, invalid(fmt.Sprintf("image must be at most %d bytes", MaxItemImageBytes))
+	}
+	contentType := http.DetectContentType(data)
+	if !itemImageTypes[contentType] {
+		return nil, invalid("image must be a PNG, JPEG, GIF or WebP")
+	}
+	if _, err := s.repo.GetItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+
+	// A fresh key per upload lets clients cache image URLs forever
+	version := make([]byte, 8)
+	rand.Read(version)
+	key := fmt.Sprintf("items/%d/image-%s", itemID, hex.EncodeToString(version))
+	if err := s.blobs.Put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+
+	var item *model.Item
+	var previousKey string
+	err := runAtomic(ctx, s.uow, "upload_item_image", func(ctx context.Context) error {
+		var err error
+		imageURL := fmt.Sprintf("/v1/items/%d/image?v=%s", itemID, hex.EncodeToString(version))
+		if previousKey, err = s.repo.SetItemImage(ctx, itemID, key, imageURL); err != nil {
+			return err
+		}
+		if item, err = s.repo.GetItem(ctx, itemID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "item.upload_image", "item", strconv.Itoa(itemID), nil,
+			map[string]any{"key": key, "content_type": contentType, "size": len(data)})
+	})
+	if err != nil {
+		s.deleteBlob(ctx, key)
+		return nil, err
+	}
+	s.deleteBlob(ctx, previousKey)
+	return item, nil
+}
+
+// OpenImage opens the item's uploaded image. Close its body when done.
+func (s *ItemMediaService) OpenImage(ctx context.Context, itemID int) (*storage.Object, error) {
+	key, err := s.repo.GetItemImageKey(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, ErrItemImageNotFound
+	}
+	obj, err := s.blobs.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrItemImageNotFound
+	}
+	return obj, err
+}
+
+// deleteBlob removes a replaced image; a failure only leaves an orphaned object behind
+func (s *ItemMediaService) deleteBlob(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := s.blobs.Delete(context.WithoutCancel(ctx), key); err != nil {
+		slog.WarnContext(ctx, "failed to delete item image", "key", key, "backend", s.blobs.Name(), "error", err)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateItemMetadata(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	assert.NoError(t, validateItemMetadata(model.ItemMetadata{}))
+	assert.NoError(t, validateItemMetadata(model.ItemMetadata{
+		Description: ptr("Factory new"),
+		ImageURL:    ptr("https://cdn.example.com/ak.png"),
+		Attributes:  map[string]any{"rarity": "covert", "float": 0.01},
+	}))
+	assert.NoError(t, validateItemMetadata(model.ItemMetadata{ImageURL: ptr("")}), "an empty URL removes the image")
+
+	cases := map[string]model.ItemMetadata{
+		"long description": {Description: ptr(strings.Repeat("a", maxItemDescriptionLength+1))},
+		"relative url":     {ImageURL: ptr("/images/ak.png")},
+		"javascript url":   {ImageURL: ptr("javascript:alert(1)")},
+		"large attributes": {Attributes: map[string]any{"blob": strings.Repeat("a", maxItemAttributesBytes)}},
+	}
+	for name, m := range cases {
+		assert.True(t, errors.Is(validateItemMetadata(m), ErrValidation), name)
+	}
+}
+
+func TestUploadImage_Validation(t *testing.T) {
+	s := NewItemMediaService(nil, nil, nil, nil)
+	ctx := context.Background()
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not image": []byte("<html><body>hi</body></html>"),
+		"too large": append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, MaxItemImageBytes)...),
+	} {
+		_, err := s.UploadImage(ctx, 1, data)
+		assert.True(t, errors.Is(err, ErrValidation), name)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+)
+
+// DefaultLeaderboardSize is how many buyers and items are kept per period when
+// NewLeaderboardService is given 0
+const DefaultLeaderboardSize = 100
+
+// leaderboardWindows are the leaderboard periods and their windows, 0 for all time
+var leaderboardWindows = []struct {
+	period string
+	window time.Duration
+}{
+	{model.LeaderboardDaily, 24 * time.Hour},
+	{model.LeaderboardWeekly, 7 * 24 * time.Hour},
+	{model.LeaderboardAllTime, 0},
+}
+
+// LeaderboardStore keeps the precomputed leaderboards, implemented by
+// repository.LeaderboardRepository
+type LeaderboardStore interface {
+	GetLeaderboard(ctx context.Context, period string, limit int) (*model.Leaderboard, error)
+	// RebuildLeaderboard ranks the top size buyers of the orders created since, nil for all time
+	RebuildLeaderboard(ctx context.Context, period string, since *time.Time, size int) error
+}
+
+type LeaderboardService struct {
+	repo LeaderboardStore
+	size int
+}
+
+func NewLeaderboardService(repo LeaderboardStore, size int) *LeaderboardService {
+	if size <= 0 {
+		size = DefaultLeaderboardSize
+	}
+	return &LeaderboardService{repo: repo, size: size}
+}
+
+// Refresh rebuilds the leaderboards of every period, run by the leaderboard job
+func (s *LeaderboardService) Refresh(ctx context.Context) error {
+	now := time.Now().UTC()
+	var errs []error
+	for _, w := range leaderboardWindows {
+		var since *time.Time
+		if w.window > 0 {
+			t := now.Add(-w.window)
+			since = &t
+		}
+		if err := s.repo.RebuildLeaderboard(ctx, w.period, since, s.size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetLeaderboard returns the first limit top buyers and items of the period (daily when
+// empty), as of the last Refresh
+func (s *LeaderboardService) GetLeaderboard(ctx context.Context, period string, limit int) (*model.Leaderboard, error) {
+	if period == "" {
+		period = model.LeaderboardDaily
+	}
+	known := false
+	for _, w := range leaderboardWindows {
+		known = known || w.period == period
+	}
+	if !known {
+		return nil, invalid(fmt.Sprintf("period must be one of %s, %s, %s",
+			model.LeaderboardDaily, model.LeaderboardWeekly, model.LeaderboardAllTime))
+	}
+	if limit <= 0 || limit > s.size {
+		return nil, invalid(fmt.Sprintf("limit must be between 1 and %d", s.size))
+	}
+	return s.repo.GetLeaderboard(ctx, period, limit)
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLeaderboard_Validation(t *testing.T) {
+	svc := NewLeaderboardService(nil, 0)
+
+	for _, c := range []struct {
+		period string
+		limit  int
+	}{
+		{"monthly", 10},
+		{"daily", 0},
+		{"weekly", DefaultLeaderboardSize + 1},
+	} {
+		_, err := svc.GetLeaderboard(context.Background(), c.period, c.limit)
+		assert.True(t, errors.Is(err, ErrValidation), "%s %d: %v", c.period, c.limit, err)
+	}
+}
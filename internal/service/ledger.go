@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/model"
+)
+
+// LedgerChecker finds ledger inconsistencies, implemented by repository.LedgerRepository
+type LedgerChecker interface {
+	CheckLedger(ctx context.Context) ([]model.LedgerViolation, error)
+}
+
+type LedgerService struct {
+	repo LedgerChecker
+}
+
+func NewLedgerService(repo LedgerChecker) *LedgerService {
+	return &LedgerService{repo: repo}
+}
+
+// Check verifies the ledger invariants: the entries of every transaction sum to zero and
+// every user balance equals the sum of the user's entries. Violations are logged,
+// exported as the ledger_violations metric and fail the run.
+func (s *LedgerService) Check(ctx context.Context) error {
+	violations, err := s.repo.CheckLedger(ctx)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{"unbalanced_transaction": 0, "balance_mismatch": 0}
+	for _, v := range violations {
+		counts[v.Type]++
+		slog.ErrorContext(ctx, "ledger invariant violated", "type", v.Type, "id", v.ID, "expected", v.Expected, "actual", v.Actual)
+	}
+	for kind, n := range counts {
+		metrics.LedgerViolations.WithLabelValues(kind).Set(float64(n))
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("ledger check found %d violations", len(violations))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/notifications"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+const (
+	receiptBatchSize = 50
+	// receiptMaxAge skips receipts of older orders, so enabling receipts does not
+	// mail every order placed while they were off
+	receiptMaxAge = 24 * time.Hour
+)
+
+// ReceiptQueue hands out the orders whose receipt is due, implemented by
+// repository.ShopRepository
+type ReceiptQueue interface {
+	// ClaimPendingReceipts locks up to limit orders whose receipt was not sent yet,
+	// oldest first; mark them sent in the same transaction
+	ClaimPendingReceipts(ctx context.Context, maxAge time.Duration, limit int) ([]model.PendingReceipt, error)
+	MarkReceiptsSent(ctx context.Context, orderIDs []int) error
+}
+
+// PriceAlertStore tracks the price alerts of favorites, implemented by
+// repository.FavoriteRepository
+type PriceAlertStore interface {
+	// ListDueAlerts returns the alerts that did not fire within the cooldown
+	ListDueAlerts(ctx context.Context, cooldown time.Duration) ([]model.DuePriceAlert, error)
+	MarkAlerted(ctx context.Context, userID int, marketHashName string) error
+}
+
+// NotificationService sends purchase receipts and price alerts; its methods are meant
+// to be run periodically as background jobs
+type NotificationService struct {
+	receipts  ReceiptQueue
+	favorites PriceAlertStore
+	uow       db.UnitOfWork
+	skinport  *skinport.Client
+	notifier  *notifications.Notifier
+	// alertCooldown is the minimum time between two alerts for the same favorite
+	alertCooldown time.Duration
+}
+
+func NewNotificationService(receipts ReceiptQueue, favorites PriceAlertStore, uow db.UnitOfWork,
+	skinportClient *skinport.Client, notifier *notifications.Notifier, alertCooldown time.Duration) *NotificationService {
+	return &NotificationService{
+		receipts:      receipts,
+		favorites:     favorites,
+		uow:           uow,
+		skinport:      skinportClient,
+		notifier:      notifier,
+		alertCooldown: alertCooldown,
+	}
+}
+
+// SendReceipts sends the receipts of orders placed since the previous run. Orders are
+// claimed with SKIP LOCKED, so instances can run it concurrently. Receipts that failed
+// to send are retried on the next run.
+func (s *NotificationService) SendReceipts(ctx context.Context) error {
+	if !s.notifier.ReceiptsEnabled() {
+		return nil
+	}
+
+	for {
+		var claimed int
+		var errs []error
+		err := runAtomic(ctx, s.uow, "send_receipts", func(ctx context.Context) error {
+			receipts, err := s.receipts.ClaimPendingReceipts(ctx, receiptMaxAge, receiptBatchSize)
+			if err != nil {
+				return err
+			}
+			claimed, errs = len(receipts), nil
+
+			handled := make([]int, 0, len(receipts))
+			for _, p := range receipts {
+				// Stale orders are marked without sending, as are the receipts of users
+				// the notifier has no address for
+				if !p.Stale {
+					to := notifications.Recipient{Email: p.Email, ChatID: p.TelegramChatID}
+					err := s.notifier.SendReceipt(ctx, to, notifications.Receipt{
+						FirstName: p.FirstName,
+						OrderID:   p.Order.ID,
+						ItemName:  p.ItemName,
+						Quantity:  p.Order.Quantity,
+						Price:     p.Order.Price.Float(),
+						Discount:  p.Order.Discount.Float(),
+						CreatedAt: p.Order.CreatedAt,
+					})
+					if err != nil {
+						errs = append(errs, fmt.Errorf("order %d: %w", p.Order.ID, err))
+						continue
+					}
+				}
+				handled = append(handled, p.Order.ID)
+			}
+			return s.receipts.MarkReceiptsSent(ctx, handled)
+		})
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to send receipts: %w", errors.Join(errs...))
+		}
+		if claimed < receiptBatchSize {
+			return nil
+		}
+	}
+}
+
+// SendPriceAlerts notifies users whose favorite Skinport items are listed below their
+// alert price (in the default app and currency), at most once per cooldown per favorite
+func (s *NotificationService) SendPriceAlerts(ctx context.Context) error {
+	if !s.notifier.PriceAlertsEnabled() {
+		return nil
+	}
+
+	alerts, err := s.favorites.ListDueAlerts(ctx, s.alertCooldown)
+	if err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	items, err := s.skinport.GetAllItems(ctx, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to get skinport prices: %w", err)
+	}
+	listings := make(map[string]skinport.ResponseItem, len(items))
+	for _, item := range items {
+		listings[item.MarketHashName] = item
+	}
+
+	var errs []error
+	sent := 0
+	for _, a := range alerts {
+		item, ok := listings[a.MarketHashName]
+		if !ok {
+			continue
+		}
+		price, ok := lowestPrice(item)
+		if !ok || price > a.AlertBelow {
+			continue
+		}
+
+		to := notifications.Recipient{Email: a.Email, ChatID: a.TelegramChatID}
+		err := s.notifier.SendPriceAlert(ctx, to, notifications.PriceAlert{
+			FirstName:      a.FirstName,
+			MarketHashName: a.MarketHashName,
+			Currency:       item.Currency,
+			Price:          price,
+			AlertBelow:     a.AlertBelow,
+		})
+		if err == nil {
+			err = s.favorites.MarkAlerted(ctx, a.UserID, a.MarketHashName)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d, %s: %w", a.UserID, a.MarketHashName, err))
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		slog.Info("price alerts sent", "count", sent)
+	}
+	return errors.Join(errs...)
+}
+
+// lowestPrice is the cheapest listing of the item, tradable or not
+func lowestPrice(item skinport.ResponseItem) (float64, bool) {
+	switch {
+	case item.MinPriceTradable == nil && item.MinPriceNonTradable == nil:
+		return 0, false
+	case item.MinPriceTradable == nil:
+		return *item.MinPriceNonTradable, true
+	case item.MinPriceNonTradable == nil:
+		return *item.MinPriceTradable, true
+	default:
+		return min(*item.MinPriceTradable, *item.MinPriceNonTradable), true
+	}
+}
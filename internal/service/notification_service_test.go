@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowestPrice(t *testing.T) {
+	p := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		item   skinport.ResponseItem
+		want   float64
+		wantOK bool
+	}{
+		{"unlisted", skinport.ResponseItem{}, 0, false},
+		{"tradable only", skinport.ResponseItem{MinPriceTradable: p(5)}, 5, true},
+		{"non-tradable only", skinport.ResponseItem{MinPriceNonTradable: p(4)}, 4, true},
+		{"cheapest of both", skinport.ResponseItem{MinPriceTradable: p(5), MinPriceNonTradable: p(3.5)}, 3.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lowestPrice(tt.item)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
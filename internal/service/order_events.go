@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"fsanano/go-test/internal/model"
+)
+
+const (
+	orderEventBatchSize = 500
+	// subscriberBuffer is how many undelivered events a subscriber may lag behind
+	// before it is disconnected (it can resume from its last event id)
+	subscriberBuffer = 64
+)
+
+// OrderEventStore keeps the order event log, implemented by repository.OrderEventRepository
+type OrderEventStore interface {
+	Append(ctx context.Context, event model.OrderEvent) error
+	LatestID(ctx context.Context) (int64, error)
+	// ListAfter returns up to limit events with an id greater than afterID, oldest first;
+	// userID > 0 restricts them to one user's
+	ListAfter(ctx context.Context, afterID int64, userID, limit int) ([]model.OrderEvent, error)
+}
+
+// OrderEventService records order lifecycle events in the order_events outbox
+// and fans committed events out to in-process subscribers.
+//
+// Events are read back from the outbox rather than published directly, so
+// subscribers only ever see committed changes, including those made by other
+// instances of the service.
+type OrderEventService struct {
+	repo OrderEventStore
+
+	mu          sync.Mutex
+	subscribers map[int]map[*OrderSubscription]struct{}
+	// stopped is set by Close; later subscriptions are closed immediately
+	stopped bool
+
+	// relayPos is the id of the last dispatched event, relayStarted whether it was initialised
+	relayPos     int64
+	relayStarted bool
+}
+
+// OrderSubscription receives one user's order events
+type OrderSubscription struct {
+	userID int
+	events chan model.OrderEvent
+}
+
+// Events delivers events in outbox order. It is closed on unsubscribe or when the
+// subscriber fell too far behind.
+func (s *OrderSubscription) Events() <-chan model.OrderEvent {
+	return s.events
+}
+
+func NewOrderEventService(repo OrderEventStore) *OrderEventService {
+	return &OrderEventService{
+		repo:        repo,
+		subscribers: make(map[int]map[*OrderSubscription]struct{}),
+	}
+}
+
+// Record appends an event for order to the outbox. Call it inside the transaction
+// that changes the order. A nil OrderEventService records nothing.
+func (s *OrderEventService) Record(ctx context.Context, eventType string, order *model.Order) error {
+	if s == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to encode order event: %w", err)
+	}
+	return s.repo.Append(ctx, model.OrderEvent{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Type:    eventType,
+		Payload: payload,
+	})
+}
+
+// Replay returns the user's events after afterID, for clients resuming a stream
+func (s *OrderEventService) Replay(ctx context.Context, userID int, afterID int64) ([]model.OrderEvent, error) {
+	return s.repo.ListAfter(ctx, afterID, userID, orderEventBatchSize)
+}
+
+// Subscribe registers for the user's events; call the returned func to unsubscribe
+func (s *OrderEventService) Subscribe(userID int) (*OrderSubscription, func()) {
+	sub := &OrderSubscription{
+		userID: userID,
+		events: make(chan model.OrderEvent, subscriberBuffer),
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		close(sub.events)
+		return sub, func() {}
+	}
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[*OrderSubscription]struct{})
+	}
+	s.subscribers[userID][sub] = struct{}{}
+	s.mu.Unlock()
+
+	return sub, func() { s.remove(sub) }
+}
+
+func (s *OrderEventService) remove(sub *OrderSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.subscribers[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(s.subscribers, sub.userID)
+	}
+	close(sub.events)
+}
+
+// Relay dispatches events committed since the previous call; it is meant to be run
+// periodically by a single job. The first call only records the outbox position:
+// older events are available via Replay.
+func (s *OrderEventService) Relay(ctx context.Context) error {
+	if !s.relayStarted {
+		lastID, err := s.repo.LatestID(ctx)
+		if err != nil {
+			return err
+		}
+		s.relayPos, s.relayStarted = lastID, true
+		return nil
+	}
+
+	for {
+		events, err := s.repo.ListAfter(ctx, s.relayPos, 0, orderEventBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			s.dispatch(event)
+			s.relayPos = event.ID
+		}
+		if len(events) < orderEventBatchSize {
+			return nil
+		}
+	}
+}
+
+// Close ends all subscriptions so open streams do not hold up server shutdown
+func (s *OrderEventService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	for userID, subs := range s.subscribers {
+		for sub := range subs {
+			close(sub.events)
+		}
+		delete(s.subscribers, userID)
+	}
+}
+
+func (s *OrderEventService) dispatch(event model.OrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			// Never block the dispatcher on a slow client
+			delete(s.subscribers[event.UserID], sub)
+			close(sub.events)
+		}
+	}
+	if len(s.subscribers[event.UserID]) == 0 {
+		delete(s.subscribers, event.UserID)
+	}
+}
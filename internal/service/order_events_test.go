@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderEventService_DispatchToUserSubscribers(t *testing.T) {
+	s := NewOrderEventService(nil)
+	sub1, unsubscribe1 := s.Subscribe(1)
+	sub2, unsubscribe2 := s.Subscribe(2)
+	defer unsubscribe2()
+
+	s.dispatch(model.OrderEvent{ID: 1, UserID: 1, Type: model.OrderEventCreated})
+
+	assert.Equal(t, int64(1), (<-sub1.Events()).ID)
+	assert.Empty(t, sub2.Events())
+
+	unsubscribe1()
+	_, open := <-sub1.Events()
+	assert.False(t, open)
+	// Unsubscribing twice is harmless
+	unsubscribe1()
+}
+
+func TestOrderEventService_DropsSlowSubscriber(t *testing.T) {
+	s := NewOrderEventService(nil)
+	sub, unsubscribe := s.Subscribe(1)
+	defer unsubscribe()
+
+	for i := range subscriberBuffer + 1 {
+		s.dispatch(model.OrderEvent{ID: int64(i + 1), UserID: 1})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received)
+	assert.Empty(t, s.subscribers)
+}
+
+func TestOrderEventService_CloseEndsSubscriptions(t *testing.T) {
+	s := NewOrderEventService(nil)
+	sub, _ := s.Subscribe(1)
+
+	s.Close()
+	_, open := <-sub.Events()
+	assert.False(t, open)
+
+	late, _ := s.Subscribe(1)
+	_, open = <-late.Events()
+	assert.False(t, open)
+}
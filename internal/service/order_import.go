@@ -0,0 +1,405 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/storage"
+)
+
+// Input formats of an order import
+const (
+	OrderImportCSV    = "csv"
+	OrderImportNDJSON = "ndjson"
+)
+
+const (
+	// orderImportChunkSize is the number of rows copied per transaction
+	orderImportChunkSize = 5000
+	// maxNDJSONLineSize caps a single NDJSON record
+	maxNDJSONLineSize = 64 << 10
+)
+
+type OrderImportRowResult struct {
+	Line          int    `json:"line"`
+	UserID        int    `json:"user_id"`
+	ItemID        int    `json:"item_id"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+
+	order model.Order
+}
+
+func (row OrderImportRowResult) status() string { return row.Status }
+
+func (OrderImportRowResult) csvHeader() []string {
+	return []string{"line", "user_id", "item_id", "client_order_id", "status", "error"}
+}
+
+func (row OrderImportRowResult) csvRecord() []string {
+	return []string{strconv.Itoa(row.Line), strconv.Itoa(row.UserID), strconv.Itoa(row.ItemID), row.ClientOrderID,
+		row.Status, row.Error}
+}
+
+type OrderImportReport = importReport[OrderImportRowResult]
+
+// OrderImportStore bulk loads historical orders, implemented by repository.ShopRepository
+type OrderImportStore interface {
+	ExistingUserIDs(ctx context.Context, ids []int) (map[int]bool, error)
+	ExistingItemIDs(ctx context.Context, ids []int) (map[int]bool, error)
+	ExistingClientOrderIDs(ctx context.Context, keys []repository.ClientOrderKey) (map[repository.ClientOrderKey]bool, error)
+	// CopyOrders inserts the orders as they are, leaving balances, stock and the ledger alone
+	CopyOrders(ctx context.Context, orders []model.Order) (int64, error)
+}
+
+// OrderImportService brings in the order history of another system
+type OrderImportService struct {
+	repo  OrderImportStore
+	uow   db.UnitOfWork
+	audit *AuditService
+	importReports
+}
+
+func NewOrderImportService(repo OrderImportStore, uow db.UnitOfWork, audit *AuditService, reports storage.Blob) *OrderImportService {
+	return &OrderImportService{repo: repo, uow: uow, audit: audit, importReports: importReports{reports, "order-imports"}}
+}
+
+// orderImportRecord is a row of either input format
+type orderImportRecord struct {
+	UserID        int          `json:"user_id"`
+	ItemID        int          `json:"item_id"`
+	Quantity      int          `json:"quantity"`
+	Price         *model.Money `json:"price"`
+	UnitPrice     *model.Money `json:"unit_price"`
+	Status        string       `json:"status"`
+	ClientOrderID string       `json:"client_order_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// ImportOrders streams historical orders as CSV (header with user_id, item_id, quantity,
+// price, created_at and optionally unit_price, status, client_order_id) or NDJSON (one
+// object per line with the same fields) and copies the valid ones in chunked
+// transactions. Orders are recorded as they were: balances, stock, inventories and the
+// ledger are left alone. Rows that are invalid, reference a missing user or item, or
+// repeat a client_order_id are reported and skipped; an unexpected database error rolls
+// back the whole chunk it happened in.
+func (s *OrderImportService) ImportOrders(ctx context.Context, in io.Reader, format string, dryRun bool) (*OrderImportReport, error) {
+	var next func() (orderImportRecord, int, error)
+	switch format {
+	case OrderImportCSV, "":
+		var err error
+		if next, err = csvOrderRecords(in); err != nil {
+			return nil, err
+		}
+	case OrderImportNDJSON:
+		next = ndjsonOrderRecords(in)
+	default:
+		return nil, invalid(fmt.Sprintf("unknown import format %q (want csv or ndjson)", format))
+	}
+
+	report := &OrderImportReport{DryRun: dryRun}
+	chunk := make([]OrderImportRowResult, 0, orderImportChunkSize)
+	// seen catches client order ids repeated within the file, the database the earlier ones
+	seen := map[repository.ClientOrderKey]int{}
+	now := time.Now()
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if !dryRun {
+			if err := s.applyChunk(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		for _, row := range chunk {
+			report.add(row)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for {
+		record, line, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		report.Total++
+
+		row := OrderImportRowResult{Line: line, UserID: record.UserID, ItemID: record.ItemID, ClientOrderID: record.ClientOrderID}
+		if err == nil {
+			row.order, err = validateImportedOrder(record, now)
+		}
+		if err == nil && record.ClientOrderID != "" {
+			key := repository.ClientOrderKey{UserID: record.UserID, ClientOrderID: record.ClientOrderID}
+			if first, ok := seen[key]; ok {
+				err = invalid(fmt.Sprintf("client_order_id repeats line %d", first))
+			} else {
+				seen[key] = line
+			}
+		}
+		if err != nil {
+			row.Status, row.Error = ImportRowInvalid, err.Error()
+			report.add(row)
+			continue
+		}
+
+		row.Status = ImportRowValid
+		chunk = append(chunk, row)
+		if len(chunk) == orderImportChunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// applyChunk copies the chunk's rows whose user and item exist and whose client order id is
+// new in one transaction, updating each row's status in place
+func (s *OrderImportService) applyChunk(ctx context.Context, chunk []OrderImportRowResult) error {
+	err := runAtomic(ctx, s.uow, "import_orders", func(ctx context.Context) error {
+		userIDs := make([]int, 0, len(chunk))
+		itemIDs := make([]int, 0, len(chunk))
+		var keys []repository.ClientOrderKey
+		for _, row := range chunk {
+			userIDs = append(userIDs, row.UserID)
+			itemIDs = append(itemIDs, row.ItemID)
+			if row.ClientOrderID != "" {
+				keys = append(keys, repository.ClientOrderKey{UserID: row.UserID, ClientOrderID: row.ClientOrderID})
+			}
+		}
+		users, err := s.repo.ExistingUserIDs(ctx, userIDs)
+		if err != nil {
+			return err
+		}
+		items, err := s.repo.ExistingItemIDs(ctx, itemIDs)
+		if err != nil {
+			return err
+		}
+		taken, err := s.repo.ExistingClientOrderIDs(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		orders := make([]model.Order, 0, len(chunk))
+		for i := range chunk {
+			row := &chunk[i]
+			row.Status, row.Error = ImportRowApplied, ""
+			switch {
+			case !users[row.UserID]:
+				row.Status, row.Error = ImportRowFailed, "user not found"
+			case !items[row.ItemID]:
+				row.Status, row.Error = ImportRowFailed, "item not found"
+			case taken[repository.ClientOrderKey{UserID: row.UserID, ClientOrderID: row.ClientOrderID}]:
+				row.Status, row.Error = ImportRowFailed, "client_order_id already exists"
+			default:
+				orders = append(orders, row.order)
+			}
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		copied, err := s.repo.CopyOrders(ctx, orders)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "orders.import", "order", "", nil,
+			map[string]any{"orders": copied, "first_line": chunk[0].Line, "last_line": chunk[len(chunk)-1].Line})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			for i := range chunk {
+				chunk[i].Status, chunk[i].Error = ImportRowCancelled, ctx.Err().Error()
+			}
+			return err
+		}
+		// The chunk was rolled back, nothing in it has been imported
+		for i := range chunk {
+			chunk[i].Status, chunk[i].Error = ImportRowFailed, "chunk rolled back: "+err.Error()
+		}
+	}
+	return nil
+}
+
+// validateImportedOrder checks a record and turns it into the order to store. The order
+// took effect at created_at: its status timestamps are set to it.
+func validateImportedOrder(rec orderImportRecord, now time.Time) (model.Order, error) {
+	order := model.Order{UserID: rec.UserID, ItemID: rec.ItemID, Quantity: rec.Quantity, Status: rec.Status,
+		ClientOrderID: rec.ClientOrderID, CreatedAt: rec.CreatedAt}
+	switch {
+	case rec.UserID <= 0:
+		return order, invalid("user_id must be positive")
+	case rec.ItemID <= 0:
+		return order, invalid("item_id must be positive")
+	case rec.Quantity <= 0:
+		return order, invalid("quantity must be positive")
+	case rec.Price == nil:
+		return order, invalid("price is required")
+	case rec.Price.Amount < 0:
+		return order, invalid("price must not be negative")
+	case rec.UnitPrice != nil && rec.UnitPrice.Amount < 0:
+		return order, invalid("unit_price must not be negative")
+	case rec.CreatedAt.IsZero():
+		return order, invalid("created_at is required")
+	case rec.CreatedAt.After(now):
+		return order, invalid("created_at is in the future")
+	case len(rec.ClientOrderID) > maxClientOrderIDLength:
+		return order, invalid(fmt.Sprintf("client_order_id exceeds %d characters", maxClientOrderIDLength))
+	}
+
+	order.Price = *rec.Price
+	if rec.UnitPrice != nil {
+		order.UnitPrice = *rec.UnitPrice
+	} else {
+		order.UnitPrice = model.NewMoney(order.Price.Float()/float64(rec.Quantity), order.Price.Currency)
+	}
+
+	at := rec.CreatedAt
+	switch order.Status {
+	case "":
+		order.Status = model.OrderStatusPaid
+		order.PaidAt = &at
+	case model.OrderStatusPending:
+	case model.OrderStatusPaid:
+		order.PaidAt = &at
+	case model.OrderStatusFulfilled:
+		order.PaidAt, order.FulfilledAt = &at, &at
+	case model.OrderStatusRefunded:
+		order.PaidAt, order.RefundedAt = &at, &at
+	case model.OrderStatusCancelled:
+		order.CancelledAt = &at
+	default:
+		return order, invalid(fmt.Sprintf("unknown status %q", rec.Status))
+	}
+	return order, nil
+}
+
+// csvOrderRecords reads the header and returns a reader of the following rows
+func csvOrderRecords(in io.Reader) (func() (orderImportRecord, int, error), error) {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"user_id", "item_id", "quantity", "price", "created_at"} {
+		if _, ok := cols[name]; !ok {
+			return nil, errors.New("csv header must contain user_id, item_id, quantity, price and created_at columns")
+		}
+	}
+
+	return func() (orderImportRecord, int, error) {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return orderImportRecord{}, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return orderImportRecord{}, line, err
+		}
+		rec, err := parseOrderImportCSV(record, cols)
+		return rec, line, err
+	}, nil
+}
+
+func parseOrderImportCSV(record []string, cols map[string]int) (orderImportRecord, error) {
+	var rec orderImportRecord
+	field := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	intField := func(name string) (int, error) {
+		n, err := strconv.Atoi(field(name))
+		if err != nil {
+			return 0, invalid(fmt.Sprintf("invalid %s %q", name, field(name)))
+		}
+		return n, nil
+	}
+	moneyField := func(name string) (*model.Money, error) {
+		var m model.Money
+		if err := m.UnmarshalJSON([]byte(field(name))); err != nil {
+			return nil, invalid(fmt.Sprintf("invalid %s: %v", name, err))
+		}
+		return &m, nil
+	}
+
+	rec.Status = field("status")
+	rec.ClientOrderID = field("client_order_id")
+	var err error
+	if rec.UserID, err = intField("user_id"); err != nil {
+		return rec, err
+	}
+	if rec.ItemID, err = intField("item_id"); err != nil {
+		return rec, err
+	}
+	if rec.Quantity, err = intField("quantity"); err != nil {
+		return rec, err
+	}
+	if rec.Price, err = moneyField("price"); err != nil {
+		return rec, err
+	}
+	if field("unit_price") != "" {
+		if rec.UnitPrice, err = moneyField("unit_price"); err != nil {
+			return rec, err
+		}
+	}
+	if rec.CreatedAt, err = time.Parse(time.RFC3339, field("created_at")); err != nil {
+		return rec, invalid(fmt.Sprintf("invalid created_at %q, want RFC 3339", field("created_at")))
+	}
+	return rec, nil
+}
+
+// ndjsonOrderRecords returns a reader of the non-blank lines of in as JSON objects
+func ndjsonOrderRecords(in io.Reader) func() (orderImportRecord, int, error) {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 4096), maxNDJSONLineSize)
+	line := 0
+	done := false
+	return func() (orderImportRecord, int, error) {
+		for !done && sc.Scan() {
+			line++
+			data := bytes.TrimSpace(sc.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var rec orderImportRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return rec, line, invalid(fmt.Sprintf("invalid json: %v", err))
+			}
+			return rec, line, nil
+		}
+		if err := sc.Err(); err != nil && !done {
+			// The scanner cannot go past a line that is too long, it ends the import
+			done = true
+			return orderImportRecord{}, line + 1, err
+		}
+		return orderImportRecord{}, line, io.EOF
+	}
+}
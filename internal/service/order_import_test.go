@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportOrders_DryRunCSV(t *testing.T) {
+	csvData := `user_id,item_id,quantity,price,created_at,status,client_order_id
+1,2,3,30.00,2024-03-01T12:00:00Z,,legacy-1
+1,2,1,9.99,2024-03-02T12:00:00Z,refunded,legacy-2
+1,2,1,9.99,2024-03-02T12:00:00Z,paid,legacy-1
+x,2,1,5,2024-03-01T12:00:00Z,,
+1,2,0,5,2024-03-01T12:00:00Z,,
+1,2,1,5.001,2024-03-01T12:00:00Z,,
+1,2,1,5,yesterday,,
+1,2,1,5,2999-01-01T00:00:00Z,,
+1,2,1,5,2024-03-01T12:00:00Z,lost,
+`
+	svc := NewOrderImportService(nil, nil, nil, nil)
+
+	report, err := svc.ImportOrders(context.Background(), strings.NewReader(csvData), OrderImportCSV, true)
+	require.NoError(t, err)
+	assert.Equal(t, 9, report.Total)
+	assert.Equal(t, 2, report.Applied)
+	assert.Equal(t, 7, report.Failed)
+
+	errs := map[int]string{}
+	for _, row := range report.Rows {
+		errs[row.Line] = row.Error
+	}
+	assert.Empty(t, errs[2])
+	assert.Empty(t, errs[3])
+	assert.Equal(t, "client_order_id repeats line 2", errs[4])
+	assert.Contains(t, errs[5], "invalid user_id")
+	assert.Equal(t, "quantity must be positive", errs[6])
+	assert.Contains(t, errs[7], "more than 2 decimals")
+	assert.Contains(t, errs[8], "RFC 3339")
+	assert.Equal(t, "created_at is in the future", errs[9])
+	assert.Contains(t, errs[10], "unknown status")
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteCSV(&out))
+	assert.True(t, strings.HasPrefix(out.String(), "line,user_id,item_id,client_order_id,status,error\n"))
+}
+
+func TestImportOrders_NDJSON(t *testing.T) {
+	data := `{"user_id": 1, "item_id": 2, "quantity": 3, "price": 29.99, "created_at": "2024-03-01T12:00:00Z", "status": "fulfilled"}
+
+{"user_id": 1, "item_id": 2, "quantity": 1, "created_at": "2024-03-01T12:00:00Z"}
+not json
+`
+	svc := NewOrderImportService(nil, nil, nil, nil)
+
+	report, err := svc.ImportOrders(context.Background(), strings.NewReader(data), OrderImportNDJSON, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Total)
+	rows := map[int]OrderImportRowResult{}
+	for _, row := range report.Rows {
+		rows[row.Line] = row
+	}
+	assert.Equal(t, ImportRowValid, rows[1].Status)
+	assert.Equal(t, "price is required", rows[3].Error, "blank lines count")
+	assert.Contains(t, rows[4].Error, "invalid json")
+
+	order := rows[1].order
+	assert.Equal(t, model.NewMoney(29.99, ""), order.Price)
+	assert.Equal(t, model.NewMoney(10, ""), order.UnitPrice, "price split per unit, rounded to cents")
+	require.NotNil(t, order.FulfilledAt)
+	assert.Equal(t, order.CreatedAt, *order.PaidAt)
+}
+
+func TestImportOrders_Errors(t *testing.T) {
+	svc := NewOrderImportService(nil, nil, nil, nil)
+
+	_, err := svc.ImportOrders(context.Background(), strings.NewReader("user_id,item_id,price\n"), OrderImportCSV, true)
+	assert.Error(t, err)
+
+	_, err = svc.ImportOrders(context.Background(), strings.NewReader(""), "xml", true)
+	assert.ErrorIs(t, err, ErrValidation)
+}
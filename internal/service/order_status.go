@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+)
+
+// ErrInvalidTransition is matched (via errors.Is) by *TransitionError
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// TransitionError reports a move the order state machine does not allow
+type TransitionError struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *TransitionError) Error() string {
+	allowed := "none"
+	if len(e.Allowed) > 0 {
+		allowed = strings.Join(e.Allowed, ", ")
+	}
+	return fmt.Sprintf("cannot move order from %s to %s (allowed: %s)", e.From, e.To, allowed)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// orderTransitions is the order state machine; refunded and cancelled are final
+var orderTransitions = map[string][]string{
+	model.OrderStatusPending:   {model.OrderStatusPaid, model.OrderStatusCancelled},
+	model.OrderStatusPaid:      {model.OrderStatusFulfilled, model.OrderStatusRefunded, model.OrderStatusCancelled},
+	model.OrderStatusFulfilled: {model.OrderStatusRefunded},
+	model.OrderStatusRefunded:  nil,
+	model.OrderStatusCancelled: nil,
+}
+
+func checkTransition(from, to string) error {
+	if _, ok := orderTransitions[to]; !ok {
+		return invalid(fmt.Sprintf("unknown order status %q", to))
+	}
+	if !slices.Contains(orderTransitions[from], to) {
+		return &TransitionError{From: from, To: to, Allowed: orderTransitions[from]}
+	}
+	return nil
+}
+
+// TransitionOrder moves the order to status. Leaving a charged state (paid or fulfilled)
+// for refunded or cancelled credits the price back to the user and takes the items back
+// from their inventory; cancelling also returns the quantity to stock.
+// The change is audited and published as "order.<status>".
+// Refunds are subject to the refund policy, see RefundOrder.
+func (s *ShopService) TransitionOrder(ctx context.Context, orderID int, status string) (*model.Order, error) {
+	if status == model.OrderStatusRefunded {
+		return s.RefundOrder(ctx, orderID, RefundRequest{})
+	}
+	return s.transitionOrder(ctx, orderID, status, nil)
+}
+
+// transitionOrder evaluates the refund policy for refund when it is set
+func (s *ShopService) transitionOrder(ctx context.Context, orderID int, status string, refund *RefundRequest) (*model.Order, error) {
+	var order *model.Order
+	var denied *RefundDeniedError
+	err := runAtomic(ctx, s.uow, "transition_order", func(ctx context.Context) error {
+		current, err := s.repo.GetOrderForUpdate(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if refund != nil && refund.UserID != 0 && current.UserID != refund.UserID {
+			return errors.New("order not found")
+		}
+		if err := checkTransition(current.Status, status); err != nil {
+			return err
+		}
+		if refund != nil {
+			// A denial is committed with the decision recorded, and returned after
+			if denied, err = s.decideRefund(ctx, current, *refund); err != nil || denied != nil {
+				return err
+			}
+		}
+
+		charged := current.Status == model.OrderStatusPaid || current.Status == model.OrderStatusFulfilled
+		if charged && (status == model.OrderStatusRefunded || status == model.OrderStatusCancelled) {
+			if _, err := s.repo.AdjustUserBalance(ctx, current.UserID, current.Price,
+				model.LedgerKindRefund, fmt.Sprintf("order:%d", current.ID)); err != nil {
+				return err
+			}
+			if err := s.repo.CreateBalanceAdjustment(ctx, current.UserID, current.Price,
+				fmt.Sprintf("order %d %s", current.ID, status), "order_"+status); err != nil {
+				return err
+			}
+			// The buyer no longer owns what the order granted
+			if err := s.repo.RevokeInventory(ctx, current.UserID, current.ItemID, current.Quantity); err != nil {
+				return err
+			}
+		}
+		if status == model.OrderStatusCancelled {
+			// Stock was taken when the order was created
+			if err := s.repo.UpdateItemStock(ctx, current.ItemID, -current.Quantity); err != nil {
+				return err
+			}
+		}
+
+		order, err = s.repo.UpdateOrderStatus(ctx, orderID, status)
+		if err != nil {
+			return err
+		}
+
+		if err := s.events.Record(ctx, "order."+status, order); err != nil {
+			return err
+		}
+		actor := "admin"
+		if refund != nil && refund.UserID != 0 {
+			actor = "user:" + strconv.Itoa(refund.UserID)
+		}
+		return s.audit.Record(ctx, actor, "order."+status, "order", strconv.Itoa(order.ID),
+			map[string]any{"status": current.Status},
+			map[string]any{"status": order.Status})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if denied != nil {
+		return nil, denied
+	}
+	return order, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTransition(t *testing.T) {
+	allowed := [][2]string{
+		{model.OrderStatusPending, model.OrderStatusPaid},
+		{model.OrderStatusPending, model.OrderStatusCancelled},
+		{model.OrderStatusPaid, model.OrderStatusFulfilled},
+		{model.OrderStatusPaid, model.OrderStatusRefunded},
+		{model.OrderStatusPaid, model.OrderStatusCancelled},
+		{model.OrderStatusFulfilled, model.OrderStatusRefunded},
+	}
+	for _, tr := range allowed {
+		assert.NoError(t, checkTransition(tr[0], tr[1]), "%s -> %s", tr[0], tr[1])
+	}
+
+	rejected := [][2]string{
+		{model.OrderStatusPaid, model.OrderStatusPending},
+		{model.OrderStatusPaid, model.OrderStatusPaid},
+		{model.OrderStatusFulfilled, model.OrderStatusCancelled},
+		{model.OrderStatusRefunded, model.OrderStatusPaid},
+		{model.OrderStatusCancelled, model.OrderStatusRefunded},
+	}
+	for _, tr := range rejected {
+		err := checkTransition(tr[0], tr[1])
+		assert.ErrorIs(t, err, ErrInvalidTransition, "%s -> %s", tr[0], tr[1])
+	}
+
+	var transitionErr *TransitionError
+	if assert.True(t, errors.As(checkTransition(model.OrderStatusFulfilled, model.OrderStatusPaid), &transitionErr)) {
+		assert.Equal(t, []string{model.OrderStatusRefunded}, transitionErr.Allowed)
+	}
+
+	assert.ErrorIs(t, checkTransition(model.OrderStatusPaid, "shipped"), ErrValidation)
+}
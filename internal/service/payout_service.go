@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/payouts"
+	"fsanano/go-test/internal/repository"
+)
+
+const (
+	payoutBatchSize         = 20
+	maxPayoutDestinationLen = 255
+	// payoutSendingTimeout is how long a payout may stay sending before it is claimed
+	// again, when the instance sending it stopped before recording the outcome
+	payoutSendingTimeout = 10 * time.Minute
+)
+
+// PayoutStore keeps withdrawals, implemented by repository.PayoutRepository
+type PayoutStore interface {
+	CreatePayout(ctx context.Context, p *model.Payout) error
+	// ClaimPayouts marks up to limit pending payouts, and those left sending for longer
+	// than staleAfter, as sending and returns them
+	ClaimPayouts(ctx context.Context, limit int, staleAfter time.Duration) ([]model.Payout, error)
+	CompletePayout(ctx context.Context, payoutID int, provider, reference string) error
+	FailPayout(ctx context.Context, payoutID int, provider, reason string) error
+	// RecordPayoutAttempt records a failed attempt, leaving the payout pending
+	RecordPayoutAttempt(ctx context.Context, payoutID int, provider, lastError string) error
+}
+
+// PayoutService withdraws balance into payouts and sends them through the payout
+// provider. ProcessPayouts is meant to be run periodically as a background job.
+type PayoutService struct {
+	repo     PayoutStore
+	balances BalanceStore
+	uow      db.UnitOfWork
+	provider payouts.Provider
+	audit    *AuditService
+}
+
+func NewPayoutService(repo PayoutStore, balances BalanceStore, uow db.UnitOfWork,
+	provider payouts.Provider, audit *AuditService) *PayoutService {
+	return &PayoutService{repo: repo, balances: balances, uow: uow, provider: provider, audit: audit}
+}
+
+// WithdrawParams describes a withdrawal; Destination is provider specific (a Stripe
+// connected account for Stripe)
+type WithdrawParams struct {
+	UserID      int
+	Amount      model.Money
+	Destination string
+}
+
+func validateWithdrawal(p WithdrawParams) error {
+	switch {
+	case p.UserID <= 0:
+		return invalid("user_id is required")
+	case p.Amount.Amount <= 0:
+		return invalid("amount must be greater than 0")
+	case p.Destination == "":
+		return invalid("destination is required")
+	case len(p.Destination) > maxPayoutDestinationLen:
+		return invalid(fmt.Sprintf("destination exceeds %d characters", maxPayoutDestinationLen))
+	case exceedsMaxAdjustment(p.Amount):
+		return invalid(fmt.Sprintf("amount exceeds %d", maxAdjustmentDelta))
+	}
+	return nil
+}
+
+// Withdraw debits the amount from the user's balance and creates a pending payout, in
+// one audited transaction. The payout is sent later by ProcessPayouts.
+func (s *PayoutService) Withdraw(ctx context.Context, p WithdrawParams) (*model.Payout, error) {
+	if err := validateWithdrawal(p); err != nil {
+		return nil, err
+	}
+
+	var payout *model.Payout
+	err := runAtomic(ctx, s.uow, "withdraw", func(ctx context.Context) error {
+		payout = &model.Payout{UserID: p.UserID, Amount: p.Amount, Destination: p.Destination}
+		if err := s.repo.CreatePayout(ctx, payout); err != nil {
+			return err
+		}
+		balance, err := s.balances.AdjustUserBalance(ctx, p.UserID, p.Amount.Neg(),
+			model.LedgerKindWithdrawal, fmt.Sprintf("payout:%d", payout.ID))
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("payout #%d", payout.ID)
+		if err := s.balances.CreateBalanceAdjustment(ctx, p.UserID, p.Amount.Neg(), reason, "withdrawal"); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, fmt.Sprintf("user:%d", p.UserID), "balance.withdraw", "payout", strconv.Itoa(payout.ID),
+			map[string]any{"balance": balance.Add(p.Amount)},
+			map[string]any{"balance": balance, "payout": payout})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// ProcessPayouts sends the pending payouts. Each batch is claimed and marked sending in a
+// transaction of its own, with SKIP LOCKED so instances can run it concurrently. The
+// provider is called outside any transaction, then the outcome of each payout is recorded
+// in a second one: rejected payouts fail and are refunded to the balance, other errors
+// leave them pending, to be retried on the next run. A payout whose outcome could not be
+// recorded stays sending and is sent again after payoutSendingTimeout. Providers
+// deduplicate by payout id, so it is not paid twice.
+func (s *PayoutService) ProcessPayouts(ctx context.Context) error {
+	for {
+		var claimed []model.Payout
+		err := runAtomic(ctx, s.uow, "claim_payouts", func(ctx context.Context) error {
+			var err error
+			claimed, err = s.repo.ClaimPayouts(ctx, payoutBatchSize, payoutSendingTimeout)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, p := range claimed {
+			reference, sendErr := s.provider.Send(ctx, payouts.Request{
+				PayoutID:    p.ID,
+				UserID:      p.UserID,
+				Amount:      p.Amount,
+				Destination: p.Destination,
+			})
+			err := runAtomic(ctx, s.uow, "record_payout", func(ctx context.Context) error {
+				return s.record(ctx, p, reference, sendErr)
+			})
+			switch {
+			case errors.Is(err, repository.ErrPayoutNotSending):
+				slog.WarnContext(ctx, "payout outcome already recorded by another instance", "payout_id", p.ID)
+			case err != nil:
+				errs = append(errs, fmt.Errorf("payout %d: failed to record outcome: %w", p.ID, err))
+			case sendErr != nil && !errors.Is(sendErr, payouts.ErrRejected):
+				errs = append(errs, fmt.Errorf("payout %d: %w", p.ID, sendErr))
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to send payouts: %w", errors.Join(errs...))
+		}
+		if len(claimed) < payoutBatchSize {
+			return nil
+		}
+	}
+}
+
+// record stores the outcome of sending a claimed payout: completed, failed and refunded
+// when rejected, or pending again after any other error
+func (s *PayoutService) record(ctx context.Context, p model.Payout, reference string, sendErr error) error {
+	provider := s.provider.Name()
+	switch {
+	case sendErr == nil:
+		if err := s.repo.CompletePayout(ctx, p.ID, provider, reference); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "payout completed", "payout_id", p.ID, "provider", provider, "reference", reference)
+		return s.audit.Record(ctx, "system", "payout.complete", "payout", strconv.Itoa(p.ID),
+			nil, map[string]any{"provider": provider, "reference": reference})
+	case errors.Is(sendErr, payouts.ErrRejected):
+		return s.refund(ctx, p, provider, sendErr.Error())
+	default:
+		return s.repo.RecordPayoutAttempt(ctx, p.ID, provider, sendErr.Error())
+	}
+}
+
+// refund fails a rejected payout and credits its amount back
+func (s *PayoutService) refund(ctx context.Context, p model.Payout, provider, reason string) error {
+	if err := s.repo.FailPayout(ctx, p.ID, provider, reason); err != nil {
+		return err
+	}
+	balance, err := s.balances.AdjustUserBalance(ctx, p.UserID, p.Amount,
+		model.LedgerKindWithdrawalRefund, fmt.Sprintf("payout:%d", p.ID))
+	if err != nil {
+		return err
+	}
+	if err := s.balances.CreateBalanceAdjustment(ctx, p.UserID, p.Amount,
+		fmt.Sprintf("payout #%d failed: %s", p.ID, reason), "withdrawal_refund"); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "payout rejected, balance refunded", "payout_id", p.ID, "provider", provider, "reason", reason)
+	return s.audit.Record(ctx, "system", "payout.fail", "payout", strconv.Itoa(p.ID),
+		map[string]any{"balance": balance.Sub(p.Amount)},
+		map[string]any{"balance": balance, "reason": reason})
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWithdrawal(t *testing.T) {
+	assert.NoError(t, validateWithdrawal(WithdrawParams{UserID: 1, Amount: model.Money{Amount: 1234}, Destination: "acct_1"}))
+	assert.NoError(t, validateWithdrawal(WithdrawParams{UserID: 1, Amount: model.Money{Amount: 115}, Destination: "acct_1"}))
+
+	invalid := []WithdrawParams{
+		{Amount: model.Money{Amount: 1000}, Destination: "acct_1"},
+		{UserID: 1, Destination: "acct_1"},
+		{UserID: 1, Amount: model.Money{Amount: -500}, Destination: "acct_1"},
+		{UserID: 1, Amount: model.NewMoney(maxAdjustmentDelta+1, ""), Destination: "acct_1"},
+		{UserID: 1, Amount: model.Money{Amount: 1000}},
+		{UserID: 1, Amount: model.Money{Amount: 1000}, Destination: strings.Repeat("a", maxPayoutDestinationLen+1)},
+	}
+	for _, p := range invalid {
+		err := validateWithdrawal(p)
+		assert.True(t, errors.Is(err, ErrValidation), "%+v: %v", p, err)
+	}
+}
+
+func TestValidateDeposit(t *testing.T) {
+	assert.NoError(t, validateDeposit(1, model.Money{Amount: 1000}))
+	assert.NoError(t, validateDeposit(1, model.Money{Amount: 2555}))
+
+	for _, amount := range []float64{0, 0.5, -10, maxAdjustmentDelta + 1} {
+		err := validateDeposit(1, model.NewMoney(amount, ""))
+		assert.True(t, errors.Is(err, ErrValidation), "%v: %v", amount, err)
+	}
+	assert.True(t, errors.Is(validateDeposit(0, model.Money{Amount: 1000}), ErrValidation))
+}
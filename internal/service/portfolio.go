@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+// Price sources of portfolio items
+const (
+	PortfolioPriceSkinport = "skinport"
+	PortfolioPriceShop     = "shop"
+)
+
+// PortfolioItem is an owned item valued at its lowest Skinport listing when the item is
+// mapped to a listed Skinport item, at its shop price otherwise
+type PortfolioItem struct {
+	ItemID         int     `json:"item_id"`
+	Name           string  `json:"name"`
+	MarketHashName *string `json:"market_hash_name,omitempty"`
+	Quantity       int     `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	PriceSource    string  `json:"price_source"`
+	Value          float64 `json:"value"`
+	// Change24h and Change7d are the value changes over the period, nil when the item's
+	// price history does not reach back that far
+	Change24h *float64 `json:"change_24h"`
+	Change7d  *float64 `json:"change_7d"`
+}
+
+// Portfolio is everything a user owns with its total value
+type Portfolio struct {
+	UserID     int             `json:"user_id"`
+	Currency   string          `json:"currency,omitempty"`
+	Items      []PortfolioItem `json:"items"`
+	TotalValue float64         `json:"total_value"`
+	// Change24h and Change7d sum the changes of the items with price history
+	Change24h *float64  `json:"change_24h"`
+	Change7d  *float64  `json:"change_7d"`
+	ValuedAt  time.Time `json:"valued_at"`
+}
+
+// PortfolioLister lists what users own with its price history, implemented by
+// repository.InventoryRepository
+type PortfolioLister interface {
+	ListPortfolio(ctx context.Context, userID int) ([]model.PortfolioHolding, error)
+}
+
+// UserGetter looks users up, implemented by repository.ShopRepository
+type UserGetter interface {
+	GetUser(ctx context.Context, userID int) (*model.User, error)
+}
+
+type PortfolioService struct {
+	repo     PortfolioLister
+	users    UserGetter
+	skinport *skinport.Factory
+	// currency of the Skinport prices, the one shop prices are synced in
+	currency string
+}
+
+func NewPortfolioService(repo PortfolioLister, users UserGetter,
+	skinportClients *skinport.Factory, currency string) *PortfolioService {
+	return &PortfolioService{repo: repo, users: users, skinport: skinportClients, currency: currency}
+}
+
+// GetPortfolio values the items the user owns with the cached Skinport prices. Deltas
+// follow the items' shop price history (item_price_snapshots), scaled to the current
+// unit price, so Skinport-valued items move with the prices synced from Skinport. Items
+// fall back to their shop price when Skinport cannot be reached.
+func (s *PortfolioService) GetPortfolio(ctx context.Context, userID int) (*Portfolio, error) {
+	if _, err := s.users.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	holdings, err := s.repo.ListPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []skinport.ResponseItem
+	for _, h := range holdings {
+		if h.MarketHashName == nil {
+			continue
+		}
+		client, err := s.skinport.Client(ctx)
+		if err != nil {
+			return nil, err
+		}
+		listings, err = client.GetAllItems(ctx, "", s.currency)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			slog.WarnContext(ctx, "portfolio valued without skinport prices", "user_id", userID, "error", err)
+			listings = nil
+		}
+		break
+	}
+	return valuePortfolio(userID, s.currency, holdings, listings), nil
+}
+
+func valuePortfolio(userID int, currency string, holdings []model.PortfolioHolding, listings []skinport.ResponseItem) *Portfolio {
+	prices := make(map[string]float64, len(listings))
+	for _, listing := range listings {
+		if price, ok := lowestPrice(listing); ok {
+			prices[listing.MarketHashName] = price
+		}
+		if currency == "" {
+			currency = listing.Currency
+		}
+	}
+
+	portfolio := &Portfolio{UserID: userID, Currency: currency, Items: make([]PortfolioItem, 0, len(holdings)), ValuedAt: time.Now()}
+	var total float64
+	for _, h := range holdings {
+		item := PortfolioItem{ItemID: h.ItemID, Name: h.Name, MarketHashName: h.MarketHashName, Quantity: h.Quantity,
+			UnitPrice: h.Price, PriceSource: PortfolioPriceShop}
+		if h.MarketHashName != nil {
+			if price, ok := prices[*h.MarketHashName]; ok {
+				item.UnitPrice, item.PriceSource = price, PortfolioPriceSkinport
+			}
+		}
+		item.Value = roundCents(item.UnitPrice * float64(h.Quantity))
+		item.Change24h = valueChange(item, h.Price, h.Price24h)
+		item.Change7d = valueChange(item, h.Price, h.Price7d)
+
+		total += item.Value
+		portfolio.Change24h = addChange(portfolio.Change24h, item.Change24h)
+		portfolio.Change7d = addChange(portfolio.Change7d, item.Change7d)
+		portfolio.Items = append(portfolio.Items, item)
+	}
+	portfolio.TotalValue = roundCents(total)
+	return portfolio
+}
+
+// valueChange is the change of the item's value since its shop price was past
+func valueChange(item PortfolioItem, price float64, past *float64) *float64 {
+	if past == nil {
+		return nil
+	}
+	pastValue := *past * float64(item.Quantity)
+	if item.PriceSource == PortfolioPriceSkinport {
+		if price <= 0 {
+			return nil
+		}
+		pastValue = item.Value * *past / price
+	}
+	change := roundCents(item.Value - pastValue)
+	return &change
+}
+
+func addChange(sum, change *float64) *float64 {
+	if change == nil {
+		return sum
+	}
+	total := *change
+	if sum != nil {
+		total = roundCents(*sum + total)
+	}
+	return &total
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuePortfolio(t *testing.T) {
+	name, delisted := "AK-47 | Redline (Field-Tested)", "Delisted"
+	listed, past24h, past7d, old := 11.0, 10.0, 8.0, 4.0
+	holdings := []model.PortfolioHolding{
+		{ItemID: 1, Name: "Redline", Quantity: 2, Price: 12, MarketHashName: &name, Price24h: &past24h, Price7d: &past7d},
+		{ItemID: 2, Name: "Sticker", Quantity: 3, Price: 5, Price24h: &old},
+		{ItemID: 3, Name: "Case", Quantity: 1, Price: 2.5, MarketHashName: &delisted},
+	}
+
+	portfolio := valuePortfolio(7, "", holdings, []skinport.ResponseItem{
+		{MarketHashName: name, Currency: "EUR", MinPriceTradable: &listed},
+		{MarketHashName: delisted, Currency: "EUR"},
+	})
+
+	assert.Equal(t, 7, portfolio.UserID)
+	assert.Equal(t, "EUR", portfolio.Currency)
+	require.Len(t, portfolio.Items, 3)
+
+	redline := portfolio.Items[0]
+	assert.Equal(t, PortfolioPriceSkinport, redline.PriceSource)
+	assert.Equal(t, 22.0, redline.Value)
+	// The shop price rose from 10 to 12 (20%), so the Skinport value rose from 18.33
+	assert.Equal(t, 3.67, *redline.Change24h)
+	assert.Equal(t, 7.33, *redline.Change7d)
+
+	sticker := portfolio.Items[1]
+	assert.Equal(t, PortfolioPriceShop, sticker.PriceSource)
+	assert.Equal(t, 15.0, sticker.Value)
+	assert.Equal(t, 3.0, *sticker.Change24h)
+	assert.Nil(t, sticker.Change7d)
+
+	assert.Equal(t, PortfolioPriceShop, portfolio.Items[2].PriceSource, "unlisted items keep their shop price")
+	assert.Nil(t, portfolio.Items[2].Change24h)
+
+	assert.Equal(t, 39.5, portfolio.TotalValue)
+	assert.Equal(t, 6.67, *portfolio.Change24h)
+	assert.Equal(t, 7.33, *portfolio.Change7d)
+}
+
+func TestValuePortfolio_WithoutSkinport(t *testing.T) {
+	name := "AK-47 | Redline (Field-Tested)"
+	portfolio := valuePortfolio(7, "USD", []model.PortfolioHolding{{ItemID: 1, Quantity: 1, Price: 12, MarketHashName: &name}}, nil)
+
+	assert.Equal(t, "USD", portfolio.Currency)
+	assert.Equal(t, PortfolioPriceShop, portfolio.Items[0].PriceSource)
+	assert.Equal(t, 12.0, portfolio.TotalValue)
+	assert.Nil(t, portfolio.Change24h)
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/pricing"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+const (
+	defaultPriceChangesLimit = 50
+	maxPriceChangesLimit     = 500
+)
+
+// ErrPriceChangeNotPending is returned when deciding a price change that was already
+// applied, rejected or superseded
+var ErrPriceChangeNotPending = errors.New("price change is not pending")
+
+// PriceSyncOptions configures how synced prices are derived and applied
+type PriceSyncOptions struct {
+	Markup pricing.Markup
+	// Currency of the Skinport prices, empty for the client's default
+	Currency string
+	// ApprovalThreshold queues changes moving a price by more than this percent for
+	// admin approval instead of applying them (0 applies every change)
+	ApprovalThreshold float64
+	// Events receives a PriceRefreshed event when prices changed; nil publishes nothing
+	Events *eventbus.Bus
+}
+
+// PriceSyncStore keeps Skinport price mappings and the price changes proposed from them,
+// implemented by repository.PriceSyncRepository
+type PriceSyncStore interface {
+	ListPriceMappings(ctx context.Context) ([]model.SkinportPriceMapping, error)
+	UpsertPriceMapping(ctx context.Context, m *model.SkinportPriceMapping) error
+	DeletePriceMapping(ctx context.Context, itemID int) error
+	ProposePriceChange(ctx context.Context, c *model.PriceChange) error
+	ListPriceChanges(ctx context.Context, filter model.PriceChangeFilter) ([]model.PriceChange, error)
+	GetPriceChangeForUpdate(ctx context.Context, id int) (*model.PriceChange, error)
+	// DecidePriceChange sets the status of a pending change, with the item's price at
+	// decision time as its old price
+	DecidePriceChange(ctx context.Context, c *model.PriceChange, status string, oldPrice model.Money) error
+	// ApplyPriceChange sets the item's price to c.NewPrice unless it is no longer
+	// c.OldPrice, reporting whether it did
+	ApplyPriceChange(ctx context.Context, c *model.PriceChange) (bool, error)
+	SetItemPrice(ctx context.Context, itemID int, price model.Money) error
+}
+
+// ItemStore reads items, implemented by repository.ShopRepository
+type ItemStore interface {
+	GetItem(ctx context.Context, itemID int) (*model.Item, error)
+	// GetItemForUpdate locks the item and returns its price and stock
+	GetItemForUpdate(ctx context.Context, itemID int) (model.Money, int, error)
+}
+
+// PriceSyncService prices shop items from Skinport: each mapped item gets the lowest
+// Skinport listing of its market_hash_name plus the markup. Sync is meant to be run
+// periodically as a background job.
+type PriceSyncService struct {
+	repo     PriceSyncStore
+	items    ItemStore
+	uow      db.UnitOfWork
+	skinport *skinport.Client
+	opts     PriceSyncOptions
+	audit    *AuditService
+}
+
+func NewPriceSyncService(repo PriceSyncStore, items ItemStore, uow db.UnitOfWork,
+	skinportClient *skinport.Client, opts PriceSyncOptions, audit *AuditService) *PriceSyncService {
+	return &PriceSyncService{
+		repo:     repo,
+		items:    items,
+		uow:      uow,
+		skinport: skinportClient,
+		opts:     opts,
+		audit:    audit,
+	}
+}
+
+// SetMapping prices the item from the Skinport item marketHashName, with markupPercent
+// overriding the configured markup when set. The price changes on the next sync.
+func (s *PriceSyncService) SetMapping(ctx context.Context, itemID int, marketHashName string, markupPercent *float64) (*model.SkinportPriceMapping, error) {
+	name, err := normalizeMarketHashName(marketHashName)
+	if err != nil {
+		return nil, err
+	}
+	if markupPercent != nil && (math.IsNaN(*markupPercent) || *markupPercent <= -100 || *markupPercent >= 10000) {
+		return nil, invalid("markup_percent must be greater than -100 and less than 10000")
+	}
+
+	m := &model.SkinportPriceMapping{ItemID: itemID, MarketHashName: name, MarkupPercent: markupPercent}
+	err = runAtomic(ctx, s.uow, "set_price_mapping", func(ctx context.Context) error {
+		if err := s.repo.UpsertPriceMapping(ctx, m); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "item.set_price_mapping", "item", strconv.Itoa(itemID), nil, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RemoveMapping stops pricing the item from Skinport, its current price is kept
+func (s *PriceSyncService) RemoveMapping(ctx context.Context, itemID int) error {
+	return runAtomic(ctx, s.uow, "remove_price_mapping", func(ctx context.Context) error {
+		if err := s.repo.DeletePriceMapping(ctx, itemID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "item.remove_price_mapping", "item", strconv.Itoa(itemID), nil, nil)
+	})
+}
+
+func (s *PriceSyncService) ListMappings(ctx context.Context) ([]model.SkinportPriceMapping, error) {
+	return s.repo.ListPriceMappings(ctx)
+}
+
+// ListPriceChanges returns the latest price changes matching the filter, a zero limit
+// meaning the default
+func (s *PriceSyncService) ListPriceChanges(ctx context.Context, filter model.PriceChangeFilter) ([]model.PriceChange, error) {
+	switch filter.Status {
+	case "", model.PriceChangeApplied, model.PriceChangePending, model.PriceChangeRejected, model.PriceChangeSuperseded:
+	default:
+		return nil, invalid("status must be applied, pending, rejected or superseded")
+	}
+	switch {
+	case filter.Limit < 0 || filter.Limit > maxPriceChangesLimit:
+		return nil, invalid(fmt.Sprintf("limit must be between 1 and %d", maxPriceChangesLimit))
+	case filter.Limit == 0:
+		filter.Limit = defaultPriceChangesLimit
+	}
+	if filter.ItemID > 0 {
+		if _, err := s.items.GetItem(ctx, filter.ItemID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListPriceChanges(ctx, filter)
+}
+
+// ApprovePriceChange applies a pending price change
+func (s *PriceSyncService) ApprovePriceChange(ctx context.Context, id int) (*model.PriceChange, error) {
+	return s.decide(ctx, id, model.PriceChangeApplied, "approve_price_change", "price_change.approve")
+}
+
+// RejectPriceChange discards a pending price change, the item keeps its price
+func (s *PriceSyncService) RejectPriceChange(ctx context.Context, id int) (*model.PriceChange, error) {
+	return s.decide(ctx, id, model.PriceChangeRejected, "reject_price_change", "price_change.reject")
+}
+
+func (s *PriceSyncService) decide(ctx context.Context, id int, status, op, action string) (*model.PriceChange, error) {
+	var change *model.PriceChange
+	err := runAtomic(ctx, s.uow, op, func(ctx context.Context) error {
+		var err error
+		if change, err = s.repo.GetPriceChangeForUpdate(ctx, id); err != nil {
+			return err
+		}
+		if change.Status != model.PriceChangePending {
+			return ErrPriceChangeNotPending
+		}
+		before := *change
+
+		// The price may have moved since the proposal, record the one actually replaced
+		oldPrice := change.OldPrice
+		if status == model.PriceChangeApplied {
+			if oldPrice, _, err = s.items.GetItemForUpdate(ctx, change.ItemID); err != nil {
+				return err
+			}
+			if err := s.repo.SetItemPrice(ctx, change.ItemID, change.NewPrice); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.DecidePriceChange(ctx, change, status, oldPrice); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", action, "price_change", strconv.Itoa(id), before, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status == model.PriceChangeApplied {
+		s.publishChanges(ctx, []int{change.ItemID})
+	}
+	return change, nil
+}
+
+// publishChanges announces the items whose price changed
+func (s *PriceSyncService) publishChanges(ctx context.Context, itemIDs []int) {
+	eventbus.Publish(ctx, s.opts.Events, eventbus.PriceRefreshed, eventbus.PriceRefreshedEvent{
+		Source:      eventbus.PriceSourcePriceSync,
+		Items:       len(itemIDs),
+		ItemIDs:     itemIDs,
+		RefreshedAt: time.Now().UTC(),
+	})
+}
+
+// SyncResult summarizes a sync run
+type SyncResult struct {
+	Mapped  int `json:"mapped"`
+	Changed int `json:"changed"`
+	// Pending counts changes queued for approval
+	Pending int `json:"pending"`
+	// Unlisted counts mapped items without a Skinport price
+	Unlisted int `json:"unlisted"`
+}
+
+// Sync sets the price of every mapped item from the current Skinport listings and
+// records each change in price_changes. Changes beyond the approval threshold are
+// queued as pending instead. Items whose price was changed concurrently are left for
+// the next run.
+func (s *PriceSyncService) Sync(ctx context.Context) error {
+	_, err := s.SyncNow(ctx)
+	return err
+}
+
+// SyncNow runs a sync and reports what it did
+func (s *PriceSyncService) SyncNow(ctx context.Context) (SyncResult, error) {
+	mappings, err := s.repo.ListPriceMappings(ctx)
+	if err != nil || len(mappings) == 0 {
+		return SyncResult{}, err
+	}
+
+	items, err := s.skinport.GetAllItems(ctx, "", s.opts.Currency)
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to get skinport prices: %w", err)
+	}
+	listings := make(map[string]skinport.ResponseItem, len(items))
+	for _, item := range items {
+		listings[item.MarketHashName] = item
+	}
+
+	result := SyncResult{Mapped: len(mappings)}
+	var errs []error
+	var changedIDs []int
+	for _, m := range mappings {
+		reference, ok := lowestPrice(listings[m.MarketHashName])
+		if !ok {
+			result.Unlisted++
+			continue
+		}
+		price := syncedPrice(s.opts.Markup, m, reference)
+		if price == m.ItemPrice {
+			continue
+		}
+
+		change := &model.PriceChange{
+			ItemID:         m.ItemID,
+			OldPrice:       model.NewMoney(m.ItemPrice, model.DefaultCurrency),
+			NewPrice:       model.NewMoney(price, model.DefaultCurrency),
+			Source:         model.PriceChangeSkinportSync,
+			ReferencePrice: &reference,
+		}
+		if needsApproval(s.opts.ApprovalThreshold, m.ItemPrice, price) {
+			if err := s.repo.ProposePriceChange(ctx, change); err != nil {
+				errs = append(errs, fmt.Errorf("item %d: %w", m.ItemID, err))
+				continue
+			}
+			result.Pending++
+			continue
+		}
+		changed, err := s.repo.ApplyPriceChange(ctx, change)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", m.ItemID, err))
+			continue
+		}
+		if changed {
+			result.Changed++
+			changedIDs = append(changedIDs, m.ItemID)
+		}
+	}
+	if len(changedIDs) > 0 {
+		s.publishChanges(ctx, changedIDs)
+	}
+	if result.Changed > 0 || result.Pending > 0 {
+		slog.Info("item prices synced from skinport", "changed", result.Changed, "pending", result.Pending,
+			"mapped", result.Mapped, "unlisted", result.Unlisted)
+	}
+	return result, errors.Join(errs...)
+}
+
+// syncedPrice is the shop price of the mapped item for the Skinport reference price
+func syncedPrice(markup pricing.Markup, m model.SkinportPriceMapping, reference float64) float64 {
+	if m.MarkupPercent != nil {
+		markup.Percent = *m.MarkupPercent
+	}
+	return markup.Apply(reference)
+}
+
+// needsApproval reports whether moving the price from old to price exceeds the
+// threshold percent; a threshold of 0 never does
+func needsApproval(threshold, old, price float64) bool {
+	if threshold <= 0 {
+		return false
+	}
+	if old == 0 {
+		return true
+	}
+	return math.Abs(price-old)/old*100 > threshold
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/pricing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncedPrice(t *testing.T) {
+	markup := pricing.Markup{Percent: 10, Rounding: 0.05, RoundingMode: pricing.RoundUp, Floor: 1}
+
+	assert.InDelta(t, 11.05, syncedPrice(markup, model.SkinportPriceMapping{}, 10.01), 1e-9)
+	assert.InDelta(t, 1, syncedPrice(markup, model.SkinportPriceMapping{}, 0.2), 1e-9, "floor")
+
+	override := -10.0
+	assert.InDelta(t, 9, syncedPrice(markup, model.SkinportPriceMapping{MarkupPercent: &override}, 10), 1e-9,
+		"the item's markup replaces the configured percent, rounding and bounds still apply")
+}
+
+func TestPriceSyncService_Validation(t *testing.T) {
+	svc := NewPriceSyncService(nil, nil, nil, nil, PriceSyncOptions{}, nil)
+	ctx := context.Background()
+
+	_, err := svc.SetMapping(ctx, 1, "  ", nil)
+	assert.True(t, errors.Is(err, ErrValidation))
+	markup := -100.0
+	_, err = svc.SetMapping(ctx, 1, "AK-47 | Redline (Field-Tested)", &markup)
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	_, err = svc.ListPriceChanges(ctx, model.PriceChangeFilter{Limit: maxPriceChangesLimit + 1})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = svc.ListPriceChanges(ctx, model.PriceChangeFilter{Status: "approved"})
+	assert.True(t, errors.Is(err, ErrValidation))
+}
+
+func TestNeedsApproval(t *testing.T) {
+	assert.False(t, needsApproval(0, 10, 100), "no threshold")
+	assert.False(t, needsApproval(20, 10, 12))
+	assert.False(t, needsApproval(20, 10, 8))
+	assert.True(t, needsApproval(20, 10, 12.01))
+	assert.True(t, needsApproval(20, 10, 7.99))
+	assert.True(t, needsApproval(20, 0, 1), "free items always need approval")
+}
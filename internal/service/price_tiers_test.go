@@ -0,0 +1,30 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePriceTiers(t *testing.T) {
+	assert.NoError(t, validatePriceTiers(nil))
+	usd := func(amount float64) model.Money { return model.NewMoney(amount, model.DefaultCurrency) }
+	assert.NoError(t, validatePriceTiers([]model.PriceTier{{MinQty: 10, UnitPrice: usd(8.5)}, {MinQty: 5, UnitPrice: usd(9)}}))
+
+	cases := map[string][]model.PriceTier{
+		"zero min_qty":      {{MinQty: 0, UnitPrice: usd(1)}},
+		"negative price":    {{MinQty: 2, UnitPrice: usd(-1)}},
+		"duplicate min_qty": {{MinQty: 2, UnitPrice: usd(1)}, {MinQty: 2, UnitPrice: usd(0.5)}},
+		"too many tiers":    make([]model.PriceTier, maxPriceTiersPerItem+1),
+	}
+	for name, tiers := range cases {
+		assert.True(t, errors.Is(validatePriceTiers(tiers), ErrValidation), name)
+	}
+
+	var tiers []model.PriceTier
+	assert.Error(t, json.Unmarshal([]byte(`[{"min_qty":2,"unit_price":1.005}]`), &tiers), "sub-cent price")
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+)
+
+// ErrInvalidPromoCode wraps every reason a promo code cannot be applied to a purchase
+var ErrInvalidPromoCode = errors.New("invalid promo code")
+
+// PromoStore keeps promo codes, implemented by repository.PromoRepository
+type PromoStore interface {
+	CreatePromoCode(ctx context.Context, p *model.PromoCode) error
+	ListPromoCodes(ctx context.Context) ([]model.PromoCode, error)
+	GetPromoCodeForUpdate(ctx context.Context, code string) (*model.PromoCode, error)
+	IncrementPromoCodeUsage(ctx context.Context, promoCodeID int) error
+}
+
+type PromoService struct {
+	repo  PromoStore
+	uow   db.UnitOfWork
+	audit *AuditService
+}
+
+func NewPromoService(repo PromoStore, uow db.UnitOfWork, audit *AuditService) *PromoService {
+	return &PromoService{repo: repo, uow: uow, audit: audit}
+}
+
+func (s *PromoService) CreatePromoCode(ctx context.Context, p *model.PromoCode) error {
+	p.Code = strings.TrimSpace(p.Code)
+	if p.Code == "" || len(p.Code) > 64 {
+		return invalid("code must be 1-64 characters")
+	}
+	switch p.DiscountType {
+	case model.DiscountPercentage:
+		if p.DiscountValue <= 0 || p.DiscountValue > 100 {
+			return invalid("percentage discount must be in (0, 100]")
+		}
+	case model.DiscountFixed:
+		if p.DiscountValue <= 0 {
+			return invalid("fixed discount must be positive")
+		}
+	default:
+		return invalid(fmt.Sprintf("discount_type must be %q or %q", model.DiscountPercentage, model.DiscountFixed))
+	}
+	if p.MaxUses != nil && *p.MaxUses <= 0 {
+		return invalid("max_uses must be positive")
+	}
+
+	return runAtomic(ctx, s.uow, "create_promo_code", func(ctx context.Context) error {
+		if err := s.repo.CreatePromoCode(ctx, p); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "promo_code.create", "promo_code", strconv.Itoa(p.ID), nil, p)
+	})
+}
+
+func (s *PromoService) ListPromoCodes(ctx context.Context) ([]model.PromoCode, error) {
+	return s.repo.ListPromoCodes(ctx)
+}
+
+// Redeem locks the promo code, validates it for the purchase and counts the use.
+// Must run inside the purchase transaction so a rolled back purchase does not consume the code.
+func (s *PromoService) Redeem(ctx context.Context, code string, itemID int, total model.Money) (*model.PromoCode, model.Money, error) {
+	promo, err := s.repo.GetPromoCodeForUpdate(ctx, code)
+	if err != nil {
+		if err.Error() == "promo code not found" {
+			return nil, model.Money{}, fmt.Errorf("%w: not found", ErrInvalidPromoCode)
+		}
+		return nil, model.Money{}, err
+	}
+
+	discount, err := promoDiscount(promo, itemID, total, time.Now())
+	if err != nil {
+		return nil, model.Money{}, err
+	}
+
+	if err := s.repo.IncrementPromoCodeUsage(ctx, promo.ID); err != nil {
+		return nil, model.Money{}, err
+	}
+	return promo, discount, nil
+}
+
+// promoDiscount validates the promo code and returns the discount for a purchase of total,
+// in total's currency
+func promoDiscount(promo *model.PromoCode, itemID int, total model.Money, now time.Time) (model.Money, error) {
+	if !promo.Active {
+		return model.Money{}, fmt.Errorf("%w: inactive", ErrInvalidPromoCode)
+	}
+	if promo.ExpiresAt != nil && !now.Before(*promo.ExpiresAt) {
+		return model.Money{}, fmt.Errorf("%w: expired", ErrInvalidPromoCode)
+	}
+	if promo.MaxUses != nil && promo.UsedCount >= *promo.MaxUses {
+		return model.Money{}, fmt.Errorf("%w: usage limit reached", ErrInvalidPromoCode)
+	}
+	if len(promo.ItemIDs) > 0 && !slices.Contains(promo.ItemIDs, itemID) {
+		return model.Money{}, fmt.Errorf("%w: not applicable to this item", ErrInvalidPromoCode)
+	}
+
+	discount := model.Money{Currency: total.Currency}
+	switch promo.DiscountType {
+	case model.DiscountPercentage:
+		discount = total.Scale(promo.DiscountValue / 100)
+	case model.DiscountFixed:
+		discount = model.NewMoney(promo.DiscountValue, total.Currency)
+	}
+	if discount.Amount > total.Amount {
+		return total, nil
+	}
+	return discount, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromoDiscount(t *testing.T) {
+	now := time.Now()
+	maxUses := 2
+
+	percentage := &model.PromoCode{DiscountType: model.DiscountPercentage, DiscountValue: 15, Active: true}
+	d, err := promoDiscount(percentage, 1, model.Money{Amount: 3333}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money{Amount: 500}, d)
+
+	fixed := &model.PromoCode{DiscountType: model.DiscountFixed, DiscountValue: 50, Active: true}
+	d, err = promoDiscount(fixed, 1, model.Money{Amount: 2000}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money{Amount: 2000}, d, "fixed discount is capped at the order total")
+
+	expired := now.Add(-time.Minute)
+	invalid := []*model.PromoCode{
+		{DiscountType: model.DiscountFixed, DiscountValue: 1, Active: false},
+		{DiscountType: model.DiscountFixed, DiscountValue: 1, Active: true, ExpiresAt: &expired},
+		{DiscountType: model.DiscountFixed, DiscountValue: 1, Active: true, MaxUses: &maxUses, UsedCount: 2},
+		{DiscountType: model.DiscountFixed, DiscountValue: 1, Active: true, ItemIDs: []int{2, 3}},
+	}
+	for _, promo := range invalid {
+		_, err := promoDiscount(promo, 1, model.Money{Amount: 1000}, now)
+		assert.True(t, errors.Is(err, ErrInvalidPromoCode), err)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+)
+
+// ErrPurchaseLimitExceeded is matched (via errors.Is) by PurchaseLimitError
+var ErrPurchaseLimitExceeded = errors.New("purchase limit exceeded")
+
+// Limit rule names reported in PurchaseLimitError.Rule
+const (
+	RuleOrdersPerMinute = "max_orders_per_minute"
+	RuleSpendPerDay     = "max_spend_per_day"
+	RuleQuantityPerItem = "max_quantity_per_item"
+)
+
+// PurchaseLimitError describes which rule rejected a purchase
+type PurchaseLimitError struct {
+	Rule      string  `json:"rule"`
+	Limit     float64 `json:"limit"`
+	Current   float64 `json:"current"`
+	Requested float64 `json:"requested"`
+}
+
+func (e *PurchaseLimitError) Error() string {
+	return fmt.Sprintf("purchase limit exceeded: %s (limit %g, current %g, requested %g)", e.Rule, e.Limit, e.Current, e.Requested)
+}
+
+func (e *PurchaseLimitError) Is(target error) bool {
+	return target == ErrPurchaseLimitExceeded
+}
+
+// PurchaseLimits are per-user anti-fraud rules; zero disables a rule
+type PurchaseLimits struct {
+	MaxOrdersPerMinute int
+	MaxSpendPerDay     model.Money
+	MaxQuantityPerItem int
+}
+
+func (l PurchaseLimits) enabled() bool {
+	return l.MaxOrdersPerMinute > 0 || l.MaxSpendPerDay.Amount > 0 || l.MaxQuantityPerItem > 0
+}
+
+// check evaluates the rules against the user's activity plus the requested purchase
+func (l PurchaseLimits) check(activity repository.PurchaseActivity, quantity int, totalPrice model.Money) error {
+	if l.MaxOrdersPerMinute > 0 && activity.OrdersLastMinute+1 > l.MaxOrdersPerMinute {
+		return &PurchaseLimitError{
+			Rule:      RuleOrdersPerMinute,
+			Limit:     float64(l.MaxOrdersPerMinute),
+			Current:   float64(activity.OrdersLastMinute),
+			Requested: 1,
+		}
+	}
+	if l.MaxSpendPerDay.Amount > 0 && activity.SpendLastDay.Add(totalPrice).Amount > l.MaxSpendPerDay.Amount {
+		return &PurchaseLimitError{
+			Rule:      RuleSpendPerDay,
+			Limit:     l.MaxSpendPerDay.Float(),
+			Current:   activity.SpendLastDay.Float(),
+			Requested: totalPrice.Float(),
+		}
+	}
+	if l.MaxQuantityPerItem > 0 && activity.ItemQuantity+quantity > l.MaxQuantityPerItem {
+		return &PurchaseLimitError{
+			Rule:      RuleQuantityPerItem,
+			Limit:     float64(l.MaxQuantityPerItem),
+			Current:   float64(activity.ItemQuantity),
+			Requested: float64(quantity),
+		}
+	}
+	return nil
+}
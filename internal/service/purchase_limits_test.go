@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func usd(cents int64) model.Money {
+	return model.Money{Amount: cents}
+}
+
+func TestPurchaseLimits_Check(t *testing.T) {
+	limits := PurchaseLimits{MaxOrdersPerMinute: 3, MaxSpendPerDay: usd(10000), MaxQuantityPerItem: 5}
+
+	assert.NoError(t, limits.check(repository.PurchaseActivity{OrdersLastMinute: 2, SpendLastDay: usd(5000), ItemQuantity: 3}, 2, usd(5000)))
+
+	cases := []struct {
+		activity repository.PurchaseActivity
+		quantity int
+		total    model.Money
+		rule     string
+	}{
+		{repository.PurchaseActivity{OrdersLastMinute: 3}, 1, usd(1000), RuleOrdersPerMinute},
+		{repository.PurchaseActivity{SpendLastDay: usd(9500)}, 1, usd(1000), RuleSpendPerDay},
+		{repository.PurchaseActivity{ItemQuantity: 4}, 2, usd(1000), RuleQuantityPerItem},
+	}
+	for _, c := range cases {
+		err := limits.check(c.activity, c.quantity, c.total)
+		assert.True(t, errors.Is(err, ErrPurchaseLimitExceeded), c.rule)
+
+		var limitErr *PurchaseLimitError
+		if assert.ErrorAs(t, err, &limitErr) {
+			assert.Equal(t, c.rule, limitErr.Rule)
+		}
+	}
+}
+
+func TestPurchaseLimits_SpendExactlyAtLimit(t *testing.T) {
+	// 0.1 + 0.2 > 0.3 in float64; in cents the purchase lands exactly on the limit
+	limits := PurchaseLimits{MaxSpendPerDay: usd(30)}
+	assert.NoError(t, limits.check(repository.PurchaseActivity{SpendLastDay: usd(10)}, 1, usd(20)))
+	assert.Error(t, limits.check(repository.PurchaseActivity{SpendLastDay: usd(10)}, 1, usd(21)))
+}
+
+func TestPurchaseLimits_Disabled(t *testing.T) {
+	var limits PurchaseLimits
+	assert.False(t, limits.enabled())
+	assert.NoError(t, limits.check(repository.PurchaseActivity{OrdersLastMinute: 1000, SpendLastDay: usd(1e11), ItemQuantity: 1000}, 10, usd(1e8)))
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/tenant"
+)
+
+// ErrPurchaseQueueFull is matched (via errors.Is) by PurchaseQueueError
+var ErrPurchaseQueueFull = errors.New("too many concurrent purchases of this item")
+
+// PurchaseQueueError is returned when a purchase is shed instead of queued behind the
+// other purchases of its item
+type PurchaseQueueError struct {
+	ItemID int `json:"item_id"`
+	// Waiting is how many purchases of the item were queued
+	Waiting    int `json:"waiting"`
+	MaxWaiting int `json:"max_waiting"`
+	// TimedOut is set when the purchase gave up after PurchaseQueueOptions.MaxWait
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+func (e *PurchaseQueueError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("too many concurrent purchases of item %d: timed out in the queue", e.ItemID)
+	}
+	return fmt.Sprintf("too many concurrent purchases of item %d: %d queued (max %d)", e.ItemID, e.Waiting, e.MaxWaiting)
+}
+
+func (e *PurchaseQueueError) Is(target error) bool {
+	return target == ErrPurchaseQueueFull
+}
+
+// PurchaseQueueOptions bound the per-item purchase queue
+type PurchaseQueueOptions struct {
+	// MaxWaiting is how many purchases of one item may wait for the one in progress;
+	// 0 disables the queue
+	MaxWaiting int
+	// MaxWait is how long a purchase waits for its turn before it is shed, 0 waits until
+	// the request's deadline
+	MaxWait time.Duration
+}
+
+// WithPurchaseQueue runs the purchases of an item one at a time, in arrival order, so
+// bursts on a hot item wait in the process rather than on the item's row lock. Purchases
+// beyond opts.MaxWaiting fail with a PurchaseQueueError. The queue is per process,
+// instances still meet on the row lock.
+func WithPurchaseQueue(opts PurchaseQueueOptions) ShopServiceOption {
+	return func(s *ShopService) {
+		s.queue = newPurchaseQueue(opts)
+	}
+}
+
+// purchaseQueue serializes the purchases of each item. A nil *purchaseQueue lets every
+// purchase through.
+type purchaseQueue struct {
+	opts PurchaseQueueOptions
+
+	mu    sync.Mutex
+	items map[purchaseQueueKey]*itemQueue
+}
+
+// purchaseQueueKey tells apart the items of different shops sharing an id
+type purchaseQueueKey struct {
+	tenantID int
+	itemID   int
+}
+
+type itemQueue struct {
+	// turn is held by the purchase in progress; blocked senders are served in order
+	turn chan struct{}
+	// users counts the purchase in progress and the waiting ones
+	users int
+}
+
+func newPurchaseQueue(opts PurchaseQueueOptions) *purchaseQueue {
+	if opts.MaxWaiting <= 0 {
+		return nil
+	}
+	return &purchaseQueue{opts: opts, items: make(map[purchaseQueueKey]*itemQueue)}
+}
+
+// acquire waits for the item's turn; the caller must call release once the purchase
+// transaction has ended
+func (q *purchaseQueue) acquire(ctx context.Context, itemID int) (release func(), err error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	tenantID, _ := tenant.IDFrom(ctx)
+	key := purchaseQueueKey{tenantID: tenantID, itemID: itemID}
+
+	q.mu.Lock()
+	iq := q.items[key]
+	if iq == nil {
+		iq = &itemQueue{turn: make(chan struct{}, 1)}
+		q.items[key] = iq
+	}
+	if iq.users > q.opts.MaxWaiting {
+		waiting := iq.users - 1
+		q.mu.Unlock()
+		metrics.PurchaseQueueRejected.WithLabelValues("full").Inc()
+		return nil, &PurchaseQueueError{ItemID: itemID, Waiting: waiting, MaxWaiting: q.opts.MaxWaiting}
+	}
+	iq.users++
+	q.mu.Unlock()
+
+	leave := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if iq.users--; iq.users == 0 {
+			delete(q.items, key)
+		}
+	}
+
+	select {
+	case iq.turn <- struct{}{}:
+	default:
+		metrics.PurchaseQueueWaiting.Inc()
+		defer metrics.PurchaseQueueWaiting.Dec()
+
+		var timeout <-chan time.Time
+		if q.opts.MaxWait > 0 {
+			timer := time.NewTimer(q.opts.MaxWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case iq.turn <- struct{}{}:
+		case <-timeout:
+			leave()
+			metrics.PurchaseQueueRejected.WithLabelValues("timeout").Inc()
+			return nil, &PurchaseQueueError{ItemID: itemID, MaxWaiting: q.opts.MaxWaiting, TimedOut: true}
+		case <-ctx.Done():
+			leave()
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		<-iq.turn
+		leave()
+	}, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurchaseQueue_Disabled(t *testing.T) {
+	q := newPurchaseQueue(PurchaseQueueOptions{})
+	assert.Nil(t, q)
+	release, err := q.acquire(context.Background(), 1)
+	require.NoError(t, err)
+	release()
+}
+
+func TestPurchaseQueue_Order(t *testing.T) {
+	q := newPurchaseQueue(PurchaseQueueOptions{MaxWaiting: 10})
+	ctx := context.Background()
+
+	release, err := q.acquire(ctx, 1)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := q.acquire(ctx, 1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		}()
+		// Join the queue one after the other
+		require.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.items[purchaseQueueKey{itemID: 1}].users == i+2
+		}, time.Second, time.Millisecond)
+	}
+
+	other, err := q.acquire(ctx, 2)
+	require.NoError(t, err, "other items do not wait")
+	other()
+
+	release()
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2}, order)
+	assert.Empty(t, q.items, "idle items are forgotten")
+}
+
+func TestPurchaseQueue_Full(t *testing.T) {
+	q := newPurchaseQueue(PurchaseQueueOptions{MaxWaiting: 1, MaxWait: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	release, err := q.acquire(ctx, 1)
+	require.NoError(t, err)
+
+	waited := make(chan error)
+	go func() {
+		_, err := q.acquire(ctx, 1)
+		waited <- err
+	}()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.items[purchaseQueueKey{itemID: 1}].users == 2
+	}, time.Second, time.Millisecond)
+
+	_, err = q.acquire(ctx, 1)
+	var queueErr *PurchaseQueueError
+	require.ErrorAs(t, err, &queueErr)
+	assert.Equal(t, PurchaseQueueError{ItemID: 1, Waiting: 1, MaxWaiting: 1}, *queueErr)
+	assert.True(t, errors.Is(err, ErrPurchaseQueueFull))
+
+	_, err = q.acquire(tenant.WithID(ctx, 2), 1)
+	assert.NoError(t, err, "the same item id of another shop has a queue of its own")
+
+	err = <-waited
+	require.ErrorAs(t, err, &queueErr)
+	assert.True(t, queueErr.TimedOut)
+
+	release()
+}
+
+func TestPurchaseQueue_Cancel(t *testing.T) {
+	q := newPurchaseQueue(PurchaseQueueOptions{MaxWaiting: 1})
+	release, err := q.acquire(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.acquire(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	release()
+	assert.Empty(t, q.items)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+)
+
+// DefaultQuoteTTL is how long quotes hold their price when QuoteOptions.TTL is 0
+const DefaultQuoteTTL = 5 * time.Minute
+
+// QuoteOptions configures price lock-in quotes
+type QuoteOptions struct {
+	// SigningKey signs quotes; empty disables them
+	SigningKey string
+	// TTL is how long a quote holds its price
+	TTL time.Duration
+}
+
+// Quote errors
+var (
+	ErrQuotesDisabled = errors.New("quotes are disabled")
+	// ErrInvalidQuote is returned for quotes that were not issued by us or that do not
+	// match the purchase
+	ErrInvalidQuote = errors.New("invalid quote")
+	ErrQuoteExpired = errors.New("quote expired")
+)
+
+// WithQuotes lets clients lock in an item's price with CreateQuote and buy at it
+func WithQuotes(opts QuoteOptions) ShopServiceOption {
+	return func(s *ShopService) {
+		if opts.TTL <= 0 {
+			opts.TTL = DefaultQuoteTTL
+		}
+		s.quotes = opts
+	}
+}
+
+// CreateQuote prices quantity units of the item for the user and signs the price, so
+// buying with the quote before it expires charges it even if the item price changed.
+// Stock is not reserved.
+func (s *ShopService) CreateQuote(ctx context.Context, userID, itemID, quantity int) (*model.Quote, error) {
+	if s.quotes.SigningKey == "" {
+		return nil, ErrQuotesDisabled
+	}
+	if quantity <= 0 {
+		return nil, invalid("quantity must be greater than 0")
+	}
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != model.UserStatusActive {
+		return nil, repository.ErrUserInactive
+	}
+	price, stock, err := s.repo.GetUnitPrice(ctx, itemID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if stock < quantity {
+		return nil, repository.ErrInsufficientStock
+	}
+
+	q := &model.Quote{
+		UserID:    userID,
+		ItemID:    itemID,
+		Quantity:  quantity,
+		UnitPrice: price,
+		Total:     price.Mul(quantity),
+		ExpiresAt: time.Now().Add(s.quotes.TTL).UTC().Truncate(time.Second),
+	}
+	if err := s.sealQuote(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// sealQuote sets the quote's ID: its encoded terms with a nonce making it unique, and
+// their signature
+func (s *ShopService) sealQuote(q *model.Quote) error {
+	nonce := make([]byte, 9)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate quote id: %w", err)
+	}
+	payload := strings.Join([]string{
+		strconv.Itoa(q.UserID), strconv.Itoa(q.ItemID), strconv.Itoa(q.Quantity),
+		q.UnitPrice.String(), strconv.FormatInt(q.ExpiresAt.Unix(), 10),
+		base64.RawURLEncoding.EncodeToString(nonce),
+	}, "|")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	q.ID = encoded + "." + s.signQuote(encoded)
+	return nil
+}
+
+// verifyQuote checks the quote's signature and expiry and returns its terms
+func (s *ShopService) verifyQuote(id string, now time.Time) (*model.Quote, error) {
+	if s.quotes.SigningKey == "" {
+		return nil, ErrQuotesDisabled
+	}
+	i := strings.LastIndexByte(id, '.')
+	if i < 0 || !hmac.Equal([]byte(id[i+1:]), []byte(s.signQuote(id[:i]))) {
+		return nil, ErrInvalidQuote
+	}
+
+	// The signature vouches for the payload, parse errors mean a different format
+	payload, err := base64.RawURLEncoding.DecodeString(id[:i])
+	if err != nil {
+		return nil, ErrInvalidQuote
+	}
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 6 {
+		return nil, ErrInvalidQuote
+	}
+	userID, err1 := strconv.Atoi(fields[0])
+	itemID, err2 := strconv.Atoi(fields[1])
+	quantity, err3 := strconv.Atoi(fields[2])
+	var price model.Money
+	err4 := price.UnmarshalJSON([]byte(fields[3]))
+	expires, err5 := strconv.ParseInt(fields[4], 10, 64)
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, ErrInvalidQuote
+	}
+
+	q := &model.Quote{
+		ID:        id,
+		UserID:    userID,
+		ItemID:    itemID,
+		Quantity:  quantity,
+		UnitPrice: price,
+		Total:     price.Mul(quantity),
+		ExpiresAt: time.Unix(expires, 0).UTC(),
+	}
+	if now.After(q.ExpiresAt) {
+		return nil, ErrQuoteExpired
+	}
+	return q, nil
+}
+
+func (s *ShopService) signQuote(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.quotes.SigningKey))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// applyQuote verifies p.QuoteID and fills the item and quantity the request left out.
+// The quote must be the buyer's and cover exactly the requested item and quantity.
+func (s *ShopService) applyQuote(p *BuyParams) (*model.Quote, error) {
+	q, err := s.verifyQuote(p.QuoteID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if p.ItemID == 0 {
+		p.ItemID = q.ItemID
+	}
+	if p.Quantity == 0 {
+		p.Quantity = q.Quantity
+	}
+	if q.UserID != p.UserID || q.ItemID != p.ItemID || q.Quantity != p.Quantity {
+		return nil, fmt.Errorf("%w: it was issued for another user, item or quantity", ErrInvalidQuote)
+	}
+	return q, nil
+}
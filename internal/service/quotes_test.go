@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotes_VerifyAndApply(t *testing.T) {
+	svc := NewShopService(nil, nil, WithQuotes(QuoteOptions{SigningKey: "secret"}))
+	now := time.Now().UTC().Truncate(time.Second)
+	quote := &model.Quote{UserID: 1, ItemID: 2, Quantity: 3, UnitPrice: model.Money{Amount: 999}, ExpiresAt: now.Add(time.Minute)}
+	require.NoError(t, svc.sealQuote(quote))
+
+	verified, err := svc.verifyQuote(quote.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money{Amount: 999}, verified.UnitPrice)
+	assert.Equal(t, model.Money{Amount: 2997}, verified.Total)
+	assert.Equal(t, quote.ExpiresAt, verified.ExpiresAt)
+
+	_, err = svc.verifyQuote(quote.ID, now.Add(2*time.Minute))
+	assert.True(t, errors.Is(err, ErrQuoteExpired), err)
+
+	payload, signature, _ := strings.Cut(quote.ID, ".")
+	decoded, _ := base64.RawURLEncoding.DecodeString(payload)
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(decoded), "9.99", "0.01", 1)))
+	_, err = svc.verifyQuote(tampered+"."+signature, now)
+	assert.True(t, errors.Is(err, ErrInvalidQuote), err)
+
+	other := NewShopService(nil, nil, WithQuotes(QuoteOptions{SigningKey: "other"}))
+	_, err = other.verifyQuote(quote.ID, now)
+	assert.True(t, errors.Is(err, ErrInvalidQuote), err)
+
+	_, err = NewShopService(nil, nil).verifyQuote(quote.ID, now)
+	assert.True(t, errors.Is(err, ErrQuotesDisabled), err)
+
+	// The purchase defaults to the quote's item and quantity but must not differ from them
+	p := BuyParams{UserID: 1, QuoteID: quote.ID}
+	_, err = svc.applyQuote(&p)
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.ItemID)
+	assert.Equal(t, 3, p.Quantity)
+
+	for _, p := range []BuyParams{
+		{UserID: 2, QuoteID: quote.ID},
+		{UserID: 1, ItemID: 5, QuoteID: quote.ID},
+		{UserID: 1, Quantity: 1, QuoteID: quote.ID},
+	} {
+		_, err := svc.applyQuote(&p)
+		assert.True(t, errors.Is(err, ErrInvalidQuote), "%+v: %v", p, err)
+	}
+}
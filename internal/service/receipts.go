@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/cache"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/receipt"
+	"fsanano/go-test/internal/storage"
+)
+
+// ErrReceiptUnavailable is returned for orders that were not charged yet
+var ErrReceiptUnavailable = errors.New("order has no receipt until it is paid")
+
+// ReceiptStore reads what a receipt shows, implemented by repository.ShopRepository
+type ReceiptStore interface {
+	GetOrder(ctx context.Context, orderID int) (*model.Order, error)
+	GetUser(ctx context.Context, userID int) (*model.User, error)
+	GetItem(ctx context.Context, itemID int) (*model.Item, error)
+}
+
+// ReceiptService renders order receipts as PDF. A receipt is rendered on first request
+// and then stored in blob storage and kept in memory under the order's id, showing the
+// item and buyer as they were then. Forget deletes stored receipts.
+type ReceiptService struct {
+	repo  ReceiptStore
+	blobs storage.Blob
+	cache *cache.LRU[int, []byte]
+}
+
+// NewReceiptService keeps up to cacheSize receipts in memory for cacheTTL, 0 keeps none
+func NewReceiptService(repo ReceiptStore, blobs storage.Blob, cacheSize int, cacheTTL time.Duration) *ReceiptService {
+	return &ReceiptService{repo: repo, blobs: blobs, cache: cache.New[int, []byte](cacheSize, cacheTTL)}
+}
+
+// WithReceipts deletes the stored receipts of a user's orders when the user is erased,
+// see DeleteUser
+func WithReceipts(receipts *ReceiptService) ShopServiceOption {
+	return func(s *ShopService) {
+		s.receipts = receipts
+	}
+}
+
+// GetReceipt returns the order's receipt as a PDF document
+func (s *ReceiptService) GetReceipt(ctx context.Context, orderID int) ([]byte, error) {
+	order, err := s.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == model.OrderStatusPending {
+		return nil, ErrReceiptUnavailable
+	}
+	if doc, ok := s.cache.Get(order.ID); ok {
+		return doc, nil
+	}
+	key := receiptKey(order.ID)
+	doc, err := s.readBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		if doc, err = s.render(ctx, order); err != nil {
+			return nil, err
+		}
+		// A failed store only costs rendering the receipt again next time
+		if err := s.blobs.Put(ctx, key, doc, receipt.ContentType); err != nil {
+			slog.WarnContext(ctx, "failed to store receipt", "key", key, "backend", s.blobs.Name(), "error", err)
+		}
+	}
+	s.cache.Set(order.ID, doc)
+	return doc, nil
+}
+
+// render renders the receipt of the order with its current item and buyer
+func (s *ReceiptService) render(ctx context.Context, order *model.Order) ([]byte, error) {
+	item, err := s.repo.GetItem(ctx, order.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUser(ctx, order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := receipt.Data{
+		OrderID:    order.ID,
+		CreatedAt:  order.CreatedAt.UTC(),
+		BuyerName:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+		BuyerEmail: user.Email,
+		ItemName:   item.Name,
+		Quantity:   order.Quantity,
+		UnitPrice:  order.UnitPrice.Float(),
+		Discount:   order.Discount.Float(),
+		TaxRate:    order.TaxRate,
+		TaxAmount:  order.TaxAmount.Float(),
+		Total:      order.Price.Float(),
+	}
+	return receipt.Render(data)
+}
+
+// Forget deletes the stored receipts of the orders, from blob storage and from this
+// instance's memory; other instances drop theirs once the cache TTL has passed. The
+// receipts are rendered again when next requested.
+func (s *ReceiptService) Forget(ctx context.Context, orderIDs []int) error {
+	var errs []error
+	for _, id := range orderIDs {
+		s.cache.Delete(id)
+		if err := s.blobs.Delete(ctx, receiptKey(id)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete receipt of order %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// receiptKey is the storage key of the order's receipt
+func receiptKey(orderID int) string {
+	return fmt.Sprintf("receipts/%d.pdf", orderID)
+}
+
+// readBlob returns the stored receipt, nil if there is none
+func (s *ReceiptService) readBlob(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.blobs.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	return io.ReadAll(obj.Body)
+}
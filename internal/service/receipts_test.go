@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"fsanano/go-test/internal/receipt"
+	"fsanano/go-test/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptKey(t *testing.T) {
+	assert.Equal(t, "receipts/42.pdf", receiptKey(42))
+}
+
+func TestReceiptService_Forget(t *testing.T) {
+	ctx := context.Background()
+	blobs := &storage.Local{Dir: t.TempDir()}
+	s := NewReceiptService(nil, blobs, 10, 0)
+
+	require.NoError(t, blobs.Put(ctx, receiptKey(42), []byte("%PDF"), receipt.ContentType))
+	s.cache.Set(42, []byte("%PDF"))
+
+	// Order 43 never had its receipt rendered
+	require.NoError(t, s.Forget(ctx, []int{42, 43}))
+
+	_, err := blobs.Get(ctx, receiptKey(42))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, ok := s.cache.Get(42)
+	assert.False(t, ok, "the in-memory copy is dropped too")
+}
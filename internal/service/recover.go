@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/metrics"
+)
+
+// PanicError is returned by an operation whose transaction callback panicked.
+// The transaction was rolled back.
+type PanicError struct {
+	Op    string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Op, e.Value)
+}
+
+// runAtomic runs fn in a transaction, converting a panic inside fn into a *PanicError.
+// Returning the error (rather than unwinding through RunAtomic) rolls the transaction
+// back and keeps it from being retried; the stack is logged, counted per op and reported
+// with transactions that ran out of retries.
+func runAtomic(ctx context.Context, uow db.UnitOfWork, op string, fn func(ctx context.Context) error) error {
+	err := uow.RunAtomic(ctx, func(ctx context.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr := &PanicError{Op: op, Value: r, Stack: debug.Stack()}
+				metrics.ServicePanics.WithLabelValues(op).Inc()
+				slog.ErrorContext(ctx, "panic in transaction", "op", op, "panic", r, "stack", string(panicErr.Stack))
+				errreport.CaptureEvent(ctx, errreport.Event{
+					Kind:  errreport.KindPanic,
+					Level: errreport.LevelFatal,
+					Err:   panicErr,
+					Stack: panicErr.Stack,
+					Tags:  map[string]string{"op": op},
+				})
+				err = panicErr
+			}
+		}()
+		return fn(ctx)
+	})
+	if errors.Is(err, db.ErrRetriesExhausted) {
+		errreport.Capture(ctx, errreport.KindTransaction, err, map[string]string{"op": op})
+	}
+	return err
+}
+
+// IsReported reports whether err was sent to the error reporter where it happened,
+// so callers answering it do not report it again
+func IsReported(err error) bool {
+	var panicErr *PanicError
+	return errors.As(err, &panicErr) || errors.Is(err, db.ErrRetriesExhausted)
+}
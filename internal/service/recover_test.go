@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/errreport"
+	"fsanano/go-test/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransactor stands in for RunAtomic: it "commits" only when fn succeeds
+type fakeTransactor struct {
+	committed bool
+}
+
+func (f *fakeTransactor) RunAtomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	f.committed = true
+	return nil
+}
+
+func TestRunAtomic_RecoversPanic(t *testing.T) {
+	tx := &fakeTransactor{}
+	before := testutil.ToFloat64(metrics.ServicePanics.WithLabelValues("test_op"))
+	reporter := &recordingReporter{}
+
+	err := runAtomic(errreport.WithReporter(context.Background(), reporter), tx, "test_op", func(ctx context.Context) error {
+		var m map[string]int
+		m["boom"]++ // nil map write
+		return nil
+	})
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "test_op", panicErr.Op)
+	assert.Contains(t, string(panicErr.Stack), "recover_test.go")
+	assert.False(t, tx.committed, "a panicking callback must not commit")
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.ServicePanics.WithLabelValues("test_op")))
+
+	require.Len(t, reporter.events, 1)
+	assert.Equal(t, errreport.KindPanic, reporter.events[0].Kind)
+	assert.Equal(t, map[string]string{"op": "test_op"}, reporter.events[0].Tags)
+	assert.True(t, IsReported(err), "the handler answering it does not report it again")
+}
+
+type recordingReporter struct {
+	events []errreport.Event
+}
+
+func (r *recordingReporter) Report(_ context.Context, e errreport.Event) {
+	r.events = append(r.events, e)
+}
+
+func TestRunAtomic_PassesThroughErrors(t *testing.T) {
+	tx := &fakeTransactor{}
+	reporter := &recordingReporter{}
+	err := runAtomic(errreport.WithReporter(context.Background(), reporter), tx, "test_op", func(ctx context.Context) error {
+		return errors.New("insufficient funds")
+	})
+	assert.EqualError(t, err, "insufficient funds")
+	assert.Empty(t, reporter.events, "business errors are not reported")
+
+	require.NoError(t, runAtomic(context.Background(), tx, "test_op", func(ctx context.Context) error { return nil }))
+	assert.True(t, tx.committed)
+}
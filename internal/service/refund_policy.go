@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/model"
+)
+
+// ErrRefundDenied is matched (via errors.Is) by RefundDeniedError
+var ErrRefundDenied = errors.New("refund denied by policy")
+
+// Refund policy rule names reported in RefundDeniedError.Rule
+const (
+	RefundRuleWindow   = "refund_window"
+	RefundRuleCategory = "non_refundable_category"
+)
+
+// RefundDeniedError describes which policy rule denied a refund
+type RefundDeniedError struct {
+	OrderID int    `json:"order_id"`
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+}
+
+func (e *RefundDeniedError) Error() string {
+	return fmt.Sprintf("refund denied by policy: %s", e.Reason)
+}
+
+func (e *RefundDeniedError) Is(target error) bool {
+	return target == ErrRefundDenied
+}
+
+// RefundPolicy decides which orders may be refunded; the zero policy allows every
+// refund the order state machine does
+type RefundPolicy struct {
+	// Window is how long after it was placed an order may be refunded, zero for no limit
+	Window time.Duration
+	// NonRefundableCategories are the slugs of categories whose items are never refunded
+	NonRefundableCategories []string
+}
+
+func (p RefundPolicy) enabled() bool {
+	return p.Window > 0 || len(p.NonRefundableCategories) > 0
+}
+
+// evaluate returns the rule denying the refund of order, an item of category, at now;
+// nil when the refund is allowed
+func (p RefundPolicy) evaluate(order *model.Order, category string, now time.Time) *RefundDeniedError {
+	if p.Window > 0 && now.Sub(order.CreatedAt) > p.Window {
+		return &RefundDeniedError{
+			OrderID: order.ID,
+			Rule:    RefundRuleWindow,
+			Reason:  fmt.Sprintf("orders can only be refunded within %s of purchase", p.Window),
+		}
+	}
+	if category != "" && slices.Contains(p.NonRefundableCategories, category) {
+		return &RefundDeniedError{
+			OrderID: order.ID,
+			Rule:    RefundRuleCategory,
+			Reason:  fmt.Sprintf("items in category %q are not refundable", category),
+		}
+	}
+	return nil
+}
+
+// WithRefundPolicy evaluates refunds against policy, see RefundOrder
+func WithRefundPolicy(policy RefundPolicy) ShopServiceOption {
+	return func(s *ShopService) {
+		s.refunds = policy
+	}
+}
+
+// RefundRequest describes who asks for a refund
+type RefundRequest struct {
+	// UserID is set when the buyer asks for the refund, zero for admins. Buyers can only
+	// refund their own orders.
+	UserID int
+	// OverrideReason lets an admin refund an order the policy denies; buyers cannot
+	// override the policy
+	OverrideReason string
+}
+
+// maxOverrideReasonLength bounds the reason stored with an override
+const maxOverrideReasonLength = 500
+
+// RefundOrder refunds the order if the refund policy allows it, see TransitionOrder.
+// The policy's decision is recorded on the order even when the refund is denied, in
+// which case a *RefundDeniedError is returned. An admin override refunds the order
+// anyway and is audited with its reason.
+func (s *ShopService) RefundOrder(ctx context.Context, orderID int, req RefundRequest) (*model.Order, error) {
+	req.OverrideReason = strings.TrimSpace(req.OverrideReason)
+	if req.OverrideReason != "" && req.UserID != 0 {
+		return nil, invalid("only admins can override the refund policy")
+	}
+	if len(req.OverrideReason) > maxOverrideReasonLength {
+		return nil, invalid(fmt.Sprintf("override_reason must be at most %d characters", maxOverrideReasonLength))
+	}
+	return s.transitionOrder(ctx, orderID, model.OrderStatusRefunded, &req)
+}
+
+// decideRefund evaluates the policy for the locked order and records the decision on it;
+// denied is set when the refund must not go ahead
+func (s *ShopService) decideRefund(ctx context.Context, order *model.Order, req RefundRequest) (denied *RefundDeniedError, err error) {
+	if !s.refunds.enabled() && req.OverrideReason == "" {
+		return nil, nil
+	}
+
+	var category string
+	if len(s.refunds.NonRefundableCategories) > 0 {
+		if category, err = s.repo.GetItemCategory(ctx, order.ItemID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	decision := &model.RefundDecision{Decision: model.RefundAllowed, RequestedBy: "admin", DecidedAt: now}
+	if req.UserID != 0 {
+		decision.RequestedBy = "customer"
+	}
+	if denied = s.refunds.evaluate(order, category, now); denied != nil {
+		decision.Decision, decision.Rule, decision.Reason = model.RefundDenied, denied.Rule, denied.Reason
+		if req.OverrideReason != "" {
+			decision.Decision, decision.OverrideReason = model.RefundOverridden, req.OverrideReason
+		}
+	}
+	if err := s.repo.SetRefundDecision(ctx, order.ID, decision); err != nil {
+		return nil, err
+	}
+	order.RefundDecision = decision
+
+	if decision.Decision == model.RefundOverridden {
+		if err := s.audit.Record(ctx, "admin", "order.refund_override", "order", strconv.Itoa(order.ID),
+			map[string]any{"rule": denied.Rule, "reason": denied.Reason},
+			map[string]any{"override_reason": req.OverrideReason}); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return denied, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundPolicy_Evaluate(t *testing.T) {
+	now := time.Date(2026, 1, 17, 12, 0, 0, 0, time.UTC)
+	order := &model.Order{ID: 7, CreatedAt: now.Add(-2 * time.Hour)}
+
+	assert.False(t, RefundPolicy{}.enabled())
+	assert.Nil(t, RefundPolicy{}.evaluate(order, "keys", now), "the zero policy allows everything")
+
+	policy := RefundPolicy{Window: 24 * time.Hour, NonRefundableCategories: []string{"keys"}}
+	assert.Nil(t, policy.evaluate(order, "knives", now))
+	assert.Nil(t, policy.evaluate(order, "", now))
+
+	denied := policy.evaluate(order, "keys", now)
+	require.NotNil(t, denied)
+	assert.Equal(t, RefundRuleCategory, denied.Rule)
+	assert.ErrorIs(t, denied, ErrRefundDenied)
+
+	late := &model.Order{ID: 8, CreatedAt: now.Add(-25 * time.Hour)}
+	denied = policy.evaluate(late, "knives", now)
+	require.NotNil(t, denied)
+	assert.Equal(t, &RefundDeniedError{OrderID: 8, Rule: RefundRuleWindow, Reason: "orders can only be refunded within 24h0m0s of purchase"}, denied)
+}
+
+func TestShopService_RefundOrder_Validation(t *testing.T) {
+	s := NewShopService(nil, nil)
+
+	_, err := s.RefundOrder(context.Background(), 1, RefundRequest{UserID: 3, OverrideReason: "goodwill"})
+	assert.ErrorIs(t, err, ErrValidation, "customers cannot override")
+
+	_, err = s.RefundOrder(context.Background(), 1, RefundRequest{OverrideReason: strings.Repeat("a", maxOverrideReasonLength+1)})
+	assert.ErrorIs(t, err, ErrValidation)
+}
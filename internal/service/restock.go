@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/supplier"
+)
+
+// RestockStore applies supplier stock movements, implemented by repository.ShopRepository
+type RestockStore interface {
+	FindItemIDByName(ctx context.Context, name string) (int, error)
+	// RestockItem applies a stock movement and records it, reporting false when its
+	// reference was already applied
+	RestockItem(ctx context.Context, m *model.StockMovement) (bool, error)
+}
+
+// RestockService ingests the batches of a supplier feed; IngestRestocks is meant to be
+// run periodically as an exclusive background job
+type RestockService struct {
+	repo RestockStore
+	uow  db.UnitOfWork
+	feed supplier.Feed
+	// waitlist, when set, is served right after a restock
+	waitlist *WaitlistService
+}
+
+func NewRestockService(repo RestockStore, uow db.UnitOfWork, feed supplier.Feed, waitlist *WaitlistService) *RestockService {
+	return &RestockService{repo: repo, uow: uow, feed: feed, waitlist: waitlist}
+}
+
+// restockCounts are the outcomes of a batch's lines
+type restockCounts struct {
+	applied, duplicate, skipped int
+}
+
+// IngestRestocks applies the feed's pending batches and acknowledges them. Each batch is
+// applied in one transaction: every line adds to its item's stock and is recorded as a
+// stock movement keyed by the line's reference, so a batch delivered again is not
+// counted twice. Lines for unknown items or without a positive quantity are skipped and
+// logged. A batch that fails is not acknowledged and is retried on the next run.
+func (s *RestockService) IngestRestocks(ctx context.Context) error {
+	batches, err := s.feed.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch supplier batches: %w", err)
+	}
+
+	var errs []error
+	restocked := false
+	for _, batch := range batches {
+		counts, err := s.applyBatch(ctx, batch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("batch %s: %w", batch.ID, err))
+			continue
+		}
+		metrics.SupplierRestocks.WithLabelValues("applied").Add(float64(counts.applied))
+		metrics.SupplierRestocks.WithLabelValues("duplicate").Add(float64(counts.duplicate))
+		metrics.SupplierRestocks.WithLabelValues("skipped").Add(float64(counts.skipped))
+		slog.InfoContext(ctx, "supplier batch applied", "feed", s.feed.Name(), "batch", batch.ID,
+			"applied", counts.applied, "duplicate", counts.duplicate, "skipped", counts.skipped)
+		restocked = restocked || counts.applied > 0
+
+		if err := s.feed.Ack(ctx, batch.ID); err != nil {
+			errs = append(errs, fmt.Errorf("batch %s: %w", batch.ID, err))
+		}
+	}
+
+	if restocked && s.waitlist != nil {
+		if err := s.waitlist.ProcessWaitlist(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("waitlist: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *RestockService) applyBatch(ctx context.Context, batch supplier.Batch) (restockCounts, error) {
+	var counts restockCounts
+	var skipped []string
+	err := runAtomic(ctx, s.uow, "supplier_restock", func(ctx context.Context) error {
+		counts, skipped = restockCounts{}, nil
+		for _, r := range batch.Restocks {
+			reason, err := s.applyRestock(ctx, r)
+			if err != nil {
+				return err
+			}
+			switch reason {
+			case "":
+				counts.applied++
+			case "duplicate":
+				counts.duplicate++
+			default:
+				counts.skipped++
+				skipped = append(skipped, r.Reference+": "+reason)
+			}
+		}
+		return nil
+	})
+	for _, line := range skipped {
+		slog.WarnContext(ctx, "supplier restock line skipped", "feed", s.feed.Name(), "batch", batch.ID, "line", line)
+	}
+	return counts, err
+}
+
+// applyRestock applies one line, returning why it was not applied ("duplicate" or why
+// it was skipped)
+func (s *RestockService) applyRestock(ctx context.Context, r supplier.Restock) (string, error) {
+	if r.Quantity <= 0 {
+		return "quantity must be positive", nil
+	}
+	itemID := r.ItemID
+	if itemID == 0 {
+		if r.ItemName == "" {
+			return "no item_id or name", nil
+		}
+		id, err := s.repo.FindItemIDByName(ctx, r.ItemName)
+		if err != nil {
+			if err.Error() == "item not found" || errors.Is(err, repository.ErrAmbiguousItemName) {
+				return err.Error(), nil
+			}
+			return "", err
+		}
+		itemID = id
+	}
+
+	m := &model.StockMovement{
+		ItemID:    itemID,
+		Quantity:  r.Quantity,
+		Reason:    model.StockMovementRestock,
+		Source:    "supplier:" + s.feed.Name(),
+		Reference: r.Reference,
+	}
+	applied, err := s.repo.RestockItem(ctx, m)
+	switch {
+	case err != nil && err.Error() == "item not found":
+		return err.Error(), nil
+	case err != nil:
+		return "", err
+	case !applied:
+		return "duplicate", nil
+	}
+	return "", nil
+}
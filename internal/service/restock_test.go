@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"fsanano/go-test/internal/supplier"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRestock_SkipsInvalidLines(t *testing.T) {
+	s := NewRestockService(nil, nil, &supplier.FileFeed{}, nil)
+
+	reason, err := s.applyRestock(context.Background(), supplier.Restock{Reference: "a", ItemID: 1, Quantity: 0})
+	require.NoError(t, err)
+	assert.Equal(t, "quantity must be positive", reason)
+
+	reason, err = s.applyRestock(context.Background(), supplier.Restock{Reference: "b", Quantity: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "no item_id or name", reason)
+}
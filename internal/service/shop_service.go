@@ -3,62 +3,453 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/model"
 	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/tax"
+	"log/slog"
+	"slices"
+	"strconv"
 )
 
+// ShopStore keeps users, items and orders, implemented by repository.ShopRepository.
+// The methods that lock rows must run inside the ShopService's unit of work.
+type ShopStore interface {
+	BalanceStore
+
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUser(ctx context.Context, userID int) (*model.User, error)
+	// LockUserStatus locks the user row and returns the user's status
+	LockUserStatus(ctx context.Context, userID int) (string, error)
+	SetUserEmail(ctx context.Context, userID int, email string) error
+	SetUserRegion(ctx context.Context, userID int, region string) error
+	SetUserStatus(ctx context.Context, userID int, status string) error
+	// AnonymizeUser erases the user's personal data and marks the user deleted
+	AnonymizeUser(ctx context.Context, userID int) error
+	HasPendingPayouts(ctx context.Context, userID int) (bool, error)
+	RecordBalanceAdjustment(ctx context.Context, adj *model.BalanceAdjustment) error
+
+	ListItems(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) ([]model.Item, error)
+	GetItem(ctx context.Context, itemID int) (*model.Item, error)
+	GetItemCategory(ctx context.Context, itemID int) (string, error)
+	UpdateItemStock(ctx context.Context, itemID int, quantity int) error
+	ListPriceTiers(ctx context.Context, itemID int) ([]model.PriceTier, error)
+	ReplacePriceTiers(ctx context.Context, itemID int, tiers []model.PriceTier) error
+	// GetUnitPrice returns the per-unit price of quantity units after price tiers and the
+	// item's stock, without locking the row
+	GetUnitPrice(ctx context.Context, itemID, quantity int) (model.Money, int, error)
+	SnapshotItemPrices(ctx context.Context) (int64, error)
+
+	// LockPurchaseRows locks the item, then the user, and returns the unit price of
+	// quantity items, the stock and the balance
+	LockPurchaseRows(ctx context.Context, itemID, userID, quantity int) (model.Money, int, model.Money, error)
+	// GetPurchaseActivity returns what the rate and spend limits count; call it with the
+	// user row locked
+	GetPurchaseActivity(ctx context.Context, userID, itemID int) (repository.PurchaseActivity, error)
+	// ApplyPurchase takes the stock, inserts the order, debits the user and fills the
+	// inventory, with the rows locked by the caller
+	ApplyPurchase(ctx context.Context, order *model.Order) error
+	// PurchaseSingleStatement executes a whole purchase as one statement and returns the
+	// balance and stock read before it
+	PurchaseSingleStatement(ctx context.Context, order *model.Order) (model.Money, int, error)
+
+	ListUserOrders(ctx context.Context, userID int, opts model.ListOptions) ([]model.Order, error)
+	ListUserOrderIDs(ctx context.Context, userID int) ([]int, error)
+	GetOrderByClientOrderID(ctx context.Context, userID int, clientOrderID string) (*model.Order, error)
+	GetOrderForUpdate(ctx context.Context, orderID int) (*model.Order, error)
+	// UpdateOrderStatus sets the status and its transition timestamp and returns the order
+	UpdateOrderStatus(ctx context.Context, orderID int, status string) (*model.Order, error)
+	SetRefundDecision(ctx context.Context, orderID int, d *model.RefundDecision) error
+	// RevokeInventory takes up to quantity of the item back from the user's inventory
+	RevokeInventory(ctx context.Context, userID, itemID, quantity int) error
+}
+
 type ShopService struct {
-	repo *repository.ShopRepository
+	repo   ShopStore
+	uow    db.UnitOfWork
+	audit  *AuditService
+	promo  *PromoService
+	limits PurchaseLimits
+	events *OrderEventService
+	bus    *eventbus.Bus
+	tax    tax.Calculator
+	quotes QuoteOptions
+	queue  *purchaseQueue
+	drops  *DropService
+	// waitlist holds the users waiting for out-of-stock items, nil disables it
+	waitlist WaitlistEntryStore
+	refunds  RefundPolicy
+	// receipts, when set, has the receipts of erased users deleted
+	receipts *ReceiptService
+	// singleStatement buys through one SQL statement when no promo code or limits apply
+	singleStatement bool
+}
+
+// ShopServiceOption configures a ShopService
+type ShopServiceOption func(*ShopService)
+
+// WithAuditLog records every purchase in the audit log, inside the purchase transaction
+func WithAuditLog(audit *AuditService) ShopServiceOption {
+	return func(s *ShopService) {
+		s.audit = audit
+	}
+}
+
+// WithPurchaseLimits enforces per-user anti-fraud rules inside the purchase transaction
+func WithPurchaseLimits(limits PurchaseLimits) ShopServiceOption {
+	return func(s *ShopService) {
+		s.limits = limits
+	}
+}
+
+// WithPromoCodes lets purchases redeem promo codes
+func WithPromoCodes(promo *PromoService) ShopServiceOption {
+	return func(s *ShopService) {
+		s.promo = promo
+	}
+}
+
+// WithOrderEvents records order lifecycle events in the outbox, inside the purchase transaction
+func WithOrderEvents(events *OrderEventService) ShopServiceOption {
+	return func(s *ShopService) {
+		s.events = events
+	}
+}
+
+// WithEventBus publishes OrderCreated and UserUpdated events once changes are committed
+func WithEventBus(bus *eventbus.Bus) ShopServiceOption {
+	return func(s *ShopService) {
+		s.bus = bus
+	}
 }
 
-func NewShopService(repo *repository.ShopRepository) *ShopService {
-	return &ShopService{repo: repo}
+// WithTaxCalculator taxes purchases, the tax is added to the order price. A nil
+// calculator leaves purchases untaxed.
+func WithTaxCalculator(calculator tax.Calculator) ShopServiceOption {
+	return func(s *ShopService) {
+		s.tax = calculator
+	}
 }
 
-func (s *ShopService) BuyItem(ctx context.Context, userID, itemID, quantity int) error {
+// WithSingleStatementPurchase executes purchases without a promo code as a single SQL
+// statement, holding the row locks for one round-trip. Purchase limits and taxes need
+// the multi-step path, so the option has no effect while they are enabled.
+func WithSingleStatementPurchase(enabled bool) ShopServiceOption {
+	return func(s *ShopService) {
+		s.singleStatement = enabled
+	}
+}
+
+func NewShopService(repo ShopStore, uow db.UnitOfWork, opts ...ShopServiceOption) *ShopService {
+	s := &ShopService{repo: repo, uow: uow}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// BuyParams describes a purchase request
+type BuyParams struct {
+	UserID   int
+	ItemID   int
+	Quantity int
+	// PromoCode is optional
+	PromoCode string
+	// ClientOrderID optionally keys the purchase: buying again with the same key returns
+	// the order created the first time
+	ClientOrderID string
+	// QuoteID optionally buys at a quoted price (see CreateQuote); ItemID and Quantity
+	// default to the quote's
+	QuoteID string
+}
+
+// maxClientOrderIDLength matches orders.client_order_id
+const maxClientOrderIDLength = 64
+
+// ErrClientOrderIDReused is returned when a client_order_id is sent again for a
+// different item or quantity
+var ErrClientOrderIDReused = errors.New("client_order_id was already used for a different purchase")
+
+func (s *ShopService) BuyItem(ctx context.Context, p BuyParams) (*model.Order, error) {
+	var quote *model.Quote
+	if p.QuoteID != "" {
+		var err error
+		if quote, err = s.applyQuote(&p); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate quantity
-	if quantity <= 0 {
-		return errors.New("quantity must be greater than 0")
+	if p.Quantity <= 0 {
+		return nil, errors.New("quantity must be greater than 0")
+	}
+	if p.PromoCode != "" && s.promo == nil {
+		return nil, fmt.Errorf("%w: promo codes are disabled", ErrInvalidPromoCode)
+	}
+	if len(p.ClientOrderID) > maxClientOrderIDLength {
+		return nil, invalid(fmt.Sprintf("client_order_id must be at most %d characters", maxClientOrderIDLength))
 	}
 
-	return s.repo.RunAtomic(ctx, func(ctx context.Context) error {
-		// 1. Get Item Price and Stock with Lock
-		price, stock, err := s.repo.GetItemForUpdate(ctx, itemID)
+	if p.ClientOrderID == "" {
+		return s.buyItem(ctx, p, quote)
+	}
+	if order, err := s.replayOrder(ctx, p); order != nil || err != nil {
+		return order, err
+	}
+	order, err := s.buyItem(ctx, p, quote)
+	if errors.Is(err, repository.ErrDuplicateClientOrderID) {
+		// A concurrent request with the same key bought first
+		if order, replayErr := s.replayOrder(ctx, p); order != nil || replayErr != nil {
+			return order, replayErr
+		}
+	}
+	return order, err
+}
+
+// replayOrder returns the order already created for p.ClientOrderID, nil if there is none
+func (s *ShopService) replayOrder(ctx context.Context, p BuyParams) (*model.Order, error) {
+	order, err := s.repo.GetOrderByClientOrderID(ctx, p.UserID, p.ClientOrderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if order.ItemID != p.ItemID || order.Quantity != p.Quantity {
+		return nil, ErrClientOrderIDReused
+	}
+	order.Replayed = true
+	return order, nil
+}
+
+// buyItem charges the quote's unit price when quote is set, the current one otherwise
+func (s *ShopService) buyItem(ctx context.Context, p BuyParams, quote *model.Quote) (*model.Order, error) {
+	release, err := s.queue.acquire(ctx, p.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if s.singleStatement && p.PromoCode == "" && !s.limits.enabled() && s.tax == nil && quote == nil {
+		order, err := s.buyItemSingleStatement(ctx, p)
+		if !errors.Is(err, repository.ErrItemInDrop) {
+			return order, err
+		}
+		// The drop's checks need the multi-step path
+	}
+
+	var order *model.Order
+	err = runAtomic(ctx, s.uow, "buy_item", func(ctx context.Context) error {
+		// 1. Lock the item and user rows, reading the tier price, stock and balance
+		price, stock, balance, err := s.repo.LockPurchaseRows(ctx, p.ItemID, p.UserID, p.Quantity)
 		if err != nil {
 			return err
 		}
 
 		// 2. Check Stock
-		if stock < quantity {
-			return errors.New("insufficient stock")
+		if stock < p.Quantity {
+			return repository.ErrInsufficientStock
 		}
 
-		// 3. Lock User Row and Get Balance
-		balance, err := s.repo.GetUserForUpdate(ctx, userID)
-		if err != nil {
-			return err
+		// 2a. A quote holds the price it was issued at
+		if quote != nil {
+			price = quote.UnitPrice
+		}
+
+		// Paying from balance charges immediately, so orders start out paid
+		order = &model.Order{UserID: p.UserID, ItemID: p.ItemID, Quantity: p.Quantity, UnitPrice: price,
+			Status: model.OrderStatusPaid, ClientOrderID: p.ClientOrderID, QuoteID: p.QuoteID}
+
+		// 2b. A drop limits when, how many and how many per user can be bought
+		if s.drops != nil {
+			if order.DropID, err = s.drops.Reserve(ctx, p.UserID, p.ItemID, p.Quantity); err != nil {
+				return err
+			}
+		}
+
+		// 3. Apply Promo Code
+		// Totals are summed in minor units, so they carry no float error
+		totalPrice := price.Mul(p.Quantity)
+		if p.PromoCode != "" {
+			promo, discount, err := s.promo.Redeem(ctx, p.PromoCode, p.ItemID, totalPrice)
+			if err != nil {
+				return err
+			}
+			order.PromoCodeID = &promo.ID
+			order.Discount = discount
+			totalPrice = totalPrice.Sub(discount)
+		}
+
+		// 3a. Tax the discounted amount
+		if s.tax != nil {
+			t, err := s.calculateTax(ctx, p, totalPrice)
+			if err != nil {
+				return err
+			}
+			order.TaxRate, order.TaxAmount = t.Rate, t.Amount
+			totalPrice = totalPrice.Add(t.Amount)
 		}
+		order.Price = totalPrice
 
 		// 4. Check Balance
-		totalPrice := price * float64(quantity)
-		if balance < totalPrice {
-			return errors.New("insufficient funds")
+		if balance.Amount < totalPrice.Amount {
+			return repository.ErrInsufficientFunds
 		}
 
-		// 5. Update Balance
-		if err := s.repo.UpdateUserBalance(ctx, userID, totalPrice); err != nil {
-			return err
+		// 4a. Check per-user purchase limits (the user row lock serializes this per user)
+		if s.limits.enabled() {
+			activity, err := s.repo.GetPurchaseActivity(ctx, p.UserID, p.ItemID)
+			if err != nil {
+				return err
+			}
+			if err := s.limits.check(activity, p.Quantity, totalPrice); err != nil {
+				return err
+			}
 		}
 
-		// 6. Update Stock
-		if err := s.repo.UpdateItemStock(ctx, itemID, quantity); err != nil {
+		// 5. Update balance and stock and create the order
+		if err := s.repo.ApplyPurchase(ctx, order); err != nil {
+			return err
+		}
+		if err := s.events.Record(ctx, model.OrderEventCreated, order); err != nil {
 			return err
 		}
 
-		// 7. Create Order
-		if err := s.repo.CreateOrder(ctx, userID, itemID, totalPrice, quantity); err != nil {
+		// 6. Audit
+		return s.audit.Record(ctx, fmt.Sprintf("user:%d", p.UserID), "buy", "order", strconv.Itoa(order.ID),
+			map[string]any{"user_balance": balance, "item_stock": stock},
+			map[string]any{
+				"user_balance": balance.Sub(totalPrice),
+				"item_stock":   stock - p.Quantity,
+				"order":        order,
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.OrderCreated, eventbus.OrderCreatedEvent{Order: *order})
+	return order, nil
+}
+
+// calculateTax taxes amount for the buyer's region
+func (s *ShopService) calculateTax(ctx context.Context, p BuyParams, amount model.Money) (tax.Tax, error) {
+	user, err := s.repo.GetUser(ctx, p.UserID)
+	if err != nil {
+		return tax.Tax{}, err
+	}
+	t, err := s.tax.Calculate(ctx, tax.Request{UserID: p.UserID, ItemID: p.ItemID, Quantity: p.Quantity, Amount: amount, Region: user.Region})
+	if err != nil {
+		return tax.Tax{}, fmt.Errorf("failed to calculate tax (%s): %w", s.tax.Name(), err)
+	}
+	return t, nil
+}
+
+// buyItemSingleStatement is BuyItem without promo codes and purchase limits, the
+// purchase itself is one statement; the event and audit entry join its transaction
+func (s *ShopService) buyItemSingleStatement(ctx context.Context, p BuyParams) (*model.Order, error) {
+	var order *model.Order
+	err := runAtomic(ctx, s.uow, "buy_item", func(ctx context.Context) error {
+		order = &model.Order{UserID: p.UserID, ItemID: p.ItemID, Quantity: p.Quantity, ClientOrderID: p.ClientOrderID}
+		balance, stock, err := s.repo.PurchaseSingleStatement(ctx, order)
+		if err != nil {
+			return err
+		}
+		if err := s.events.Record(ctx, model.OrderEventCreated, order); err != nil {
 			return err
 		}
+		return s.audit.Record(ctx, fmt.Sprintf("user:%d", p.UserID), "buy", "order", strconv.Itoa(order.ID),
+			map[string]any{"user_balance": balance, "item_stock": stock},
+			map[string]any{
+				"user_balance": balance.Sub(order.Price),
+				"item_stock":   stock - p.Quantity,
+				"order":        order,
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.OrderCreated, eventbus.OrderCreatedEvent{Order: *order})
+	return order, nil
+}
 
-		return nil
+func (s *ShopService) ListItems(ctx context.Context, filter model.ItemFilter, opts model.ListOptions) ([]model.Item, error) {
+	filter.Tags = normalizeTags(filter.Tags)
+	return s.repo.ListItems(ctx, filter, opts)
+}
+
+func (s *ShopService) ListUserOrders(ctx context.Context, userID int, opts model.ListOptions) ([]model.Order, error) {
+	return s.repo.ListUserOrders(ctx, userID, opts)
+}
+
+// SnapshotItemPrices records the current price of every item for price history
+func (s *ShopService) SnapshotItemPrices(ctx context.Context) error {
+	n, err := s.repo.SnapshotItemPrices(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("item prices snapshotted", "items", n)
+	return nil
+}
+
+// ListPriceTiers returns the item's quantity discounts
+func (s *ShopService) ListPriceTiers(ctx context.Context, itemID int) ([]model.PriceTier, error) {
+	if _, err := s.repo.GetItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPriceTiers(ctx, itemID)
+}
+
+// SetPriceTiers replaces the item's quantity discounts, an empty list removes them
+func (s *ShopService) SetPriceTiers(ctx context.Context, itemID int, tiers []model.PriceTier) error {
+	if err := validatePriceTiers(tiers); err != nil {
+		return err
+	}
+	for i := range tiers {
+		tiers[i].ItemID = itemID
+	}
+	slices.SortFunc(tiers, func(a, b model.PriceTier) int { return a.MinQty - b.MinQty })
+
+	return runAtomic(ctx, s.uow, "set_price_tiers", func(ctx context.Context) error {
+		before, err := s.repo.ListPriceTiers(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.ReplacePriceTiers(ctx, itemID, tiers); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "item.set_price_tiers", "item", strconv.Itoa(itemID), before, tiers)
 	})
 }
+
+const maxPriceTiersPerItem = 20
+
+func validatePriceTiers(tiers []model.PriceTier) error {
+	if len(tiers) > maxPriceTiersPerItem {
+		return invalid("too many price tiers")
+	}
+	seen := make(map[int]bool, len(tiers))
+	for _, t := range tiers {
+		if t.MinQty < 1 {
+			return invalid("min_qty must be at least 1")
+		}
+		// Money already rejects more decimals than the currency has
+		if t.UnitPrice.Amount < 0 {
+			return invalid("unit_price must not be negative")
+		}
+		if seen[t.MinQty] {
+			return invalid("min_qty must be unique")
+		}
+		seen[t.MinQty] = true
+	}
+	return nil
+}
+
+func (s *ShopService) GetItem(ctx context.Context, itemID int) (*model.Item, error) {
+	return s.repo.GetItem(ctx, itemID)
+}
+
+func (s *ShopService) GetUser(ctx context.Context, userID int) (*model.User, error) {
+	return s.repo.GetUser(ctx, userID)
+}
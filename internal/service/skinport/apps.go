@@ -0,0 +1,44 @@
+package skinport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// App is a Steam game Skinport lists items for
+type App struct {
+	ID   string `json:"app_id"`
+	Name string `json:"name"`
+}
+
+// DefaultAppID is used when no app_id is given (Counter-Strike 2), unless
+// Config.DefaultAppID is set
+const DefaultAppID = "730"
+
+// supportedApps is the app registry; every app gets its own cache partition
+var supportedApps = []App{
+	{ID: "730", Name: "Counter-Strike 2"},
+	{ID: "570", Name: "Dota 2"},
+	{ID: "440", Name: "Team Fortress 2"},
+	{ID: "252490", Name: "Rust"},
+}
+
+// ErrUnsupportedApp is returned for app IDs missing from the registry
+var ErrUnsupportedApp = errors.New("unsupported app_id")
+
+// Apps lists the supported apps
+func Apps() []App {
+	return slices.Clone(supportedApps)
+}
+
+// ValidateAppID checks that appID is supported; empty means the client's default
+func ValidateAppID(appID string) error {
+	if appID == "" {
+		return nil
+	}
+	if !slices.ContainsFunc(supportedApps, func(a App) bool { return a.ID == appID }) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedApp, appID)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package skinport
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+)
+
+// totalSize is the estimated memory held by the entry, previous snapshots included
+func (r cachedResponse) totalSize() int64 {
+	return r.size + r.historySize()
+}
+
+// touch marks the entry as used now, for the eviction of least recently used entries
+func (r cachedResponse) touch() {
+	if r.lastUsed != nil {
+		r.lastUsed.Store(time.Now().UnixNano())
+	}
+}
+
+// store caches entry under key, replacing the previous one. A released client caches
+// nothing. The caller holds cache.mu.
+func (c *Client) store(appID string, cache *appCache, key cacheKey, entry cachedResponse) {
+	if c.released.Load() {
+		return
+	}
+	if old, ok := cache.entries[key]; ok {
+		c.account(appID, key, -old.totalSize())
+	}
+	entry.lastUsed = new(atomic.Int64)
+	entry.touch()
+	cache.entries[key] = entry
+	c.account(appID, key, entry.totalSize())
+}
+
+// remove drops the entry under key, reporting whether there was one. The caller holds cache.mu.
+func (c *Client) remove(appID string, cache *appCache, key cacheKey) bool {
+	old, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+	delete(cache.entries, key)
+	c.account(appID, key, -old.totalSize())
+	return true
+}
+
+// releaseCache drops every entry of a client that is no longer handed out, subtracting
+// them from skinport_cache_bytes. Fetches still running are not cached when they finish.
+func (c *Client) releaseCache() {
+	c.released.Store(true)
+	for appID, cache := range c.caches {
+		cache.mu.Lock()
+		for key := range cache.entries {
+			c.remove(appID, cache, key)
+		}
+		cache.mu.Unlock()
+	}
+}
+
+// account adds delta bytes to the cache's size. The gauge of an entry sums the clients
+// caching its key, so clients only ever add their own changes to it.
+func (c *Client) account(appID string, key cacheKey, delta int64) {
+	c.cacheBytes.Add(delta)
+	metrics.SkinportCacheBytes.WithLabelValues(appID + ":" + key.String()).Add(float64(delta))
+}
+
+// CacheBytes returns the estimated memory held by the cache and Config.CacheMaxBytes,
+// 0 when unlimited
+func (c *Client) CacheBytes() (used, budget int64) {
+	return c.cacheBytes.Load(), c.config.CacheMaxBytes
+}
+
+// enforceBudget evicts the least recently used entries until the cache fits
+// Config.CacheMaxBytes again. The entry just stored under appID/key is kept, even when it
+// exceeds the budget on its own. The caller must not hold any partition's lock.
+func (c *Client) enforceBudget(appID string, key cacheKey) {
+	budget := c.config.CacheMaxBytes
+	if budget <= 0 || c.cacheBytes.Load() <= budget {
+		return
+	}
+
+	type candidate struct {
+		appID    string
+		key      cacheKey
+		lastUsed int64
+	}
+	var candidates []candidate
+	for id, cache := range c.caches {
+		cache.mu.RLock()
+		for k, entry := range cache.entries {
+			if id != appID || k != key {
+				candidates = append(candidates, candidate{id, k, entry.lastUsed.Load()})
+			}
+		}
+		cache.mu.RUnlock()
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int { return cmp.Compare(a.lastUsed, b.lastUsed) })
+
+	for _, victim := range candidates {
+		if c.cacheBytes.Load() <= budget {
+			return
+		}
+		cache := c.caches[victim.appID]
+		cache.mu.Lock()
+		// Entries used or refreshed since they were listed are not the least recently used anymore
+		if entry, ok := cache.entries[victim.key]; ok && entry.lastUsed.Load() == victim.lastUsed {
+			c.remove(victim.appID, cache, victim.key)
+			metrics.SkinportCacheEvictions.Inc()
+			slog.Debug("evicted skinport cache entry", "key", victim.appID+":"+victim.key.String(),
+				"size_bytes", entry.totalSize(), "budget_bytes", budget)
+		}
+		cache.mu.Unlock()
+	}
+	if used := c.cacheBytes.Load(); used > budget {
+		slog.Warn("skinport cache exceeds its memory budget", "key", appID+":"+key.String(),
+			"size_bytes", used, "budget_bytes", budget)
+	}
+}
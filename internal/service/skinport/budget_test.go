@@ -0,0 +1,71 @@
+package skinport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheBudget(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item A", Currency: r.URL.Query().Get("currency"), MinPrice: floatPtr(1), Quantity: 1}})
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	// Every entry holds the same items, so they all have the same size
+	probe := NewClient(Config{APIURL: ts.URL})
+	_, err := probe.GetAllItems(ctx, "440", "EUR")
+	require.NoError(t, err)
+	size := probe.CacheEntries()[0].SizeBytes
+	used, budget := probe.CacheBytes()
+	assert.Equal(t, size, used)
+	assert.Zero(t, budget)
+	assert.Equal(t, float64(size), testutil.ToFloat64(metrics.SkinportCacheBytes.WithLabelValues("440:EUR")))
+	probe.InvalidateCache("440", "EUR")
+	used, _ = probe.CacheBytes()
+	assert.Zero(t, used)
+	assert.Zero(t, testutil.ToFloat64(metrics.SkinportCacheBytes.WithLabelValues("440:EUR")))
+
+	client := NewClient(Config{APIURL: ts.URL, CacheMaxBytes: 2*size + size/2})
+	evictions := testutil.ToFloat64(metrics.SkinportCacheEvictions)
+	get := func(currency string) {
+		t.Helper()
+		_, err := client.GetAllItems(ctx, "730", currency)
+		require.NoError(t, err)
+		// Entries used within the same nanosecond would tie
+		time.Sleep(time.Millisecond)
+	}
+	keys := func() []string {
+		var keys []string
+		for _, e := range client.CacheEntries() {
+			keys = append(keys, e.Key)
+		}
+		return keys
+	}
+
+	get("EUR")
+	get("USD")
+	get("EUR")
+	get("GBP")
+	assert.Equal(t, []string{"730:EUR", "730:GBP"}, keys(), "USD was the least recently used")
+	assert.Equal(t, evictions+1, testutil.ToFloat64(metrics.SkinportCacheEvictions))
+	used, budget = client.CacheBytes()
+	assert.Equal(t, 2*size, used)
+	assert.Equal(t, 2*size+size/2, budget)
+
+	// An entry larger than the budget is kept, the others make room for it
+	client = NewClient(Config{APIURL: ts.URL, CacheMaxBytes: size / 2})
+	get("EUR")
+	get("USD")
+	assert.Equal(t, []string{"730:USD"}, keys())
+}
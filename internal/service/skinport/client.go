@@ -1,14 +1,27 @@
 package skinport
 
 import (
+	"cmp"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
+
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/buildinfo"
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/metrics"
 
 	"github.com/andybalholm/brotli"
 	"golang.org/x/sync/errgroup"
@@ -18,33 +31,258 @@ type Config struct {
 	APIURL   string
 	ClientID string
 	APIKey   string
+	// FetchTimeout bounds a whole fetch (both requests and the merge), on top of the
+	// HTTP client's per-request timeout; 0 leaves only the latter
+	FetchTimeout time.Duration
+	// RequestTimeout bounds a single request, 10s when 0. Ignored with HTTPClient.
+	RequestTimeout time.Duration
+	// UserAgent identifies us to Skinport, DefaultUserAgent (with our version) when empty
+	UserAgent string
+	// DefaultAppID and DefaultCurrency apply to requests without app_id or currency,
+	// DefaultAppID (Counter-Strike 2) and EUR when empty
+	DefaultAppID    string
+	DefaultCurrency string
+
+	// HTTPClient replaces the client built from Transport, for proxies or TLS setups the
+	// latter does not cover; requests still get the credentials
+	HTTPClient *http.Client
+	Transport  TransportConfig
+
+	// RateLimit bounds the requests a client sends per RateLimitWindow, queueing the
+	// others; 0 disables the limit. Every client of a Factory has its own.
+	RateLimit       int
+	RateLimitWindow time.Duration
+	// MaxConcurrentRequests bounds the requests in flight to Skinport, queueing the others;
+	// 0 disables the limit. Unlike the rate limit, the clients of a Factory share it.
+	MaxConcurrentRequests int
+
+	// Events receives a PriceRefreshed event after every fetch; nil publishes nothing
+	Events *eventbus.Bus
+
+	// SnapshotHistory is the number of previous snapshots every cache entry keeps for
+	// Diff; 0 keeps none
+	SnapshotHistory int
+	// CacheMaxBytes bounds the estimated memory of the cache, evicting the least recently
+	// used entries beyond it; 0 disables the limit. Every client of a Factory has its own.
+	CacheMaxBytes int64
+
+	// MergeWorkers is the number of goroutines merging large datasets, GOMAXPROCS when 0;
+	// 1 merges on the fetching goroutine
+	MergeWorkers int
+
+	// DecodeMode decides whether unknown fields and malformed items fail a fetch,
+	// DecodeStandard when empty
+	DecodeMode DecodeMode
 }
 
+const (
+	// cacheTTL applies when Skinport sends no Cache-Control max-age nor Expires
+	cacheTTL = 5 * time.Minute
+	// defaultCurrency applies without Config.DefaultCurrency
+	defaultCurrency = "EUR"
+
+	// requestIDHeader forwards our request ID, as set by the request ID middleware
+	requestIDHeader = "X-Request-Id"
+)
+
+// DefaultUserAgent names us and the deployed version to Skinport
+var DefaultUserAgent = "fsanano-go-test/" + buildinfo.Version
+
 type cachedResponse struct {
-	items  []ResponseItem
-	expiry time.Time
+	// id identifies the snapshot, a 304 Not Modified keeps it
+	id    int64
+	items []ResponseItem
+	// byName and bySlug index items by market_hash_name and slug, so lookups do not scan
+	// the catalogue
+	byName *itemIndex
+	bySlug *itemIndex
+	stats  *MarketStats
+	// history holds the previous snapshots, oldest first, see Config.SnapshotHistory
+	history   []snapshot
+	fetchedAt time.Time
+	expiry    time.Time
+	// size is the estimated memory held by items, in bytes
+	size int64
+	// tradableModified and nonTradableModified are the Last-Modified of the datasets,
+	// sent back as If-Modified-Since when the entry expires
+	tradableModified    string
+	nonTradableModified string
+	// lastUsed is when the entry was last read or stored, in Unix nanoseconds. Copies of
+	// the entry share it; it is set when the entry is stored, see Client.store.
+	lastUsed *atomic.Int64
+}
+
+// newCachedResponse caches items with their indexes and stats, comparing prices with
+// prev when set. byName may be passed when the items were built with it, otherwise it is
+// built here.
+func newCachedResponse(items []ResponseItem, byName *itemIndex, prev *cachedResponse, ttl time.Duration) cachedResponse {
+	if byName == nil {
+		names := make(map[string]int, len(items))
+		for i, item := range items {
+			names[item.MarketHashName] = i
+		}
+		byName = newItemIndex(names)
+	}
+	bySlug := make(map[string]int, len(items))
+	for i, item := range items {
+		if item.Slug != "" {
+			bySlug[item.Slug] = i
+		}
+	}
+
+	stats := newMarketStats(items, prev)
+
+	now := time.Now()
+	return cachedResponse{
+		id:        snapshotIDs.Add(1),
+		items:     items,
+		byName:    byName,
+		bySlug:    newItemIndex(bySlug),
+		stats:     stats,
+		fetchedAt: now,
+		expiry:    now.Add(ttl),
+		size:      estimateSize(items) + int64(len(stats.prices))*8,
+	}
+}
+
+// lookup returns copies of the items indexed under keys, in the order of keys. Keys
+// missing from index or repeated are skipped.
+func (r cachedResponse) lookup(index *itemIndex, keys []string) []ResponseItem {
+	seen := make(map[int]bool, len(keys))
+	found := make([]ResponseItem, 0, len(keys))
+	for _, key := range keys {
+		i, ok := index.get(key)
+		if !ok || seen[i] {
+			continue
+		}
+		seen[i] = true
+		found = append(found, r.items[i])
+	}
+	return cloneItems(found)
+}
+
+// revalidated returns the entry, fresh for ttl more, after Skinport answered 304 Not Modified
+func (r cachedResponse) revalidated(ttl time.Duration) cachedResponse {
+	r.fetchedAt = time.Now()
+	r.expiry = r.fetchedAt.Add(ttl)
+	return r
+}
+
+// lastModified is the validator of a dataset, empty without a previous entry
+func (r *cachedResponse) lastModified(tradable bool) string {
+	switch {
+	case r == nil:
+		return ""
+	case tradable:
+		return r.tradableModified
+	default:
+		return r.nonTradableModified
+	}
+}
+
+// item returns a copy of the item indexed under key, or notFound
+func (r cachedResponse) item(index *itemIndex, key string, notFound error) (CachedItem, error) {
+	i, ok := index.get(key)
+	if !ok {
+		return CachedItem{}, notFound
+	}
+	return CachedItem{Item: cloneItems(r.items[i : i+1])[0], FetchedAt: r.fetchedAt, ExpiresAt: r.expiry}, nil
+}
+
+// estimateSize approximates the memory held by items: the structs, their strings
+// and prices, and the entries of both indexes
+func estimateSize(items []ResponseItem) int64 {
+	size := int64(len(items)) * int64(unsafe.Sizeof(ResponseItem{})+2*indexEntrySize)
+	for _, item := range items {
+		size += int64(len(item.MarketHashName) + len(item.Currency) + len(item.Slug) + len(item.ItemPage) + len(item.MarketPage))
+		for _, p := range item.prices() {
+			if *p != nil {
+				size += 8
+			}
+		}
+		if item.Spread != nil {
+			size += 8
+		}
+		if item.SpreadPercent != nil {
+			size += 8
+		}
+	}
+	return size
+}
+
+// indexEntrySize approximates a map[string]int entry: the key's header and the value,
+// its string shared with the item
+const indexEntrySize = unsafe.Sizeof("") + unsafe.Sizeof(0)
+
+// CacheEntry describes a cached app_id/currency/view combination
+type CacheEntry struct {
+	Key      string `json:"key"`
+	AppID    string `json:"app_id"`
+	Currency string `json:"currency"`
+	View     View   `json:"view"`
+	Items    int    `json:"items"`
+	// SizeBytes includes the previous snapshots, Snapshots counts them
+	SizeBytes  int64     `json:"size_bytes"`
+	Snapshots  int       `json:"snapshots"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	// LastUsedAt is when the entry was last read; the least recently used entries are
+	// evicted first when the cache exceeds its memory budget
+	LastUsedAt time.Time `json:"last_used_at"`
+	// Expired entries are refetched by the next GetAllItems
+	Expired bool `json:"expired"`
+}
+
+// appCache is the cache partition of one app, by currency and view. Partitions have their own
+// lock, so fetching one app's items does not block readers of another.
+type appCache struct {
+	mu      sync.RWMutex
+	entries map[cacheKey]cachedResponse
 }
 
 type Client struct {
 	client *http.Client
 	config Config
-
-	cacheMu   sync.RWMutex
-	cacheData map[string]cachedResponse
+	// limiter queues requests beyond Config.RateLimit; nil without a limit
+	limiter *rateLimiter
+	// upstream queues requests beyond Config.MaxConcurrentRequests; nil without a limit
+	upstream *concurrencyLimiter
+	// appID and currency are the defaults of Config, resolved
+	appID    string
+	currency string
+
+	// caches holds a partition per supported app, it is not modified after NewClient
+	caches map[string]*appCache
+	// cacheBytes is the estimated memory of the cached entries, see Config.CacheMaxBytes
+	cacheBytes atomic.Int64
+	// released is set once the factory dropped the client, see releaseCache
+	released atomic.Bool
 }
 
 func NewClient(cfg Config) *Client {
+	return newClient(cfg, newHTTPClient(cfg))
+}
+
+func newClient(cfg Config, httpClient *http.Client) *Client {
+	caches := make(map[string]*appCache, len(supportedApps))
+	for _, app := range supportedApps {
+		caches[app.ID] = &appCache{entries: make(map[cacheKey]cachedResponse)}
+	}
+	// An invalid default currency is kept as is: requests relying on it fail like any
+	// unsupported currency
+	currency, err := NormalizeCurrency(cfg.DefaultCurrency)
+	if err != nil || currency == "" {
+		currency = cmp.Or(cfg.DefaultCurrency, defaultCurrency)
+	}
 	return &Client{
-		client: &http.Client{
-			Transport: &AuthTransport{
-				ClientID: cfg.ClientID,
-				APIKey:   cfg.APIKey,
-				Base:     http.DefaultTransport,
-			},
-			Timeout: 10 * time.Second,
-		},
-		config:    cfg,
-		cacheData: make(map[string]cachedResponse),
+		appID:    cmp.Or(cfg.DefaultAppID, DefaultAppID),
+		currency: currency,
+		client:   httpClient,
+		config:   cfg,
+		limiter:  newRateLimiter(cfg.RateLimit, cfg.RateLimitWindow),
+		upstream: newConcurrencyLimiter(cfg.MaxConcurrentRequests),
+		caches:   caches,
 	}
 }
 
@@ -53,10 +291,22 @@ type AuthTransport struct {
 	ClientID string
 	APIKey   string
 	Base     http.RoundTripper
+
+	// rotated replaces ClientID and APIKey once set, see SetCredentials
+	rotated atomic.Pointer[Credentials]
+}
+
+// SetCredentials makes later requests use creds, e.g. after they were rotated
+func (t *AuthTransport) SetCredentials(creds Credentials) {
+	t.rotated.Store(&creds)
 }
 
 func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	auth := t.ClientID + ":" + t.APIKey
+	creds := Credentials{ClientID: t.ClientID, APIKey: t.APIKey}
+	if rotated := t.rotated.Load(); rotated != nil {
+		creds = *rotated
+	}
+	auth := creds.ClientID + ":" + creds.APIKey
 	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
 	req.Header.Set("Authorization", "Basic "+encodedAuth)
 	req.Header.Set("Accept", "application/json")
@@ -64,41 +314,505 @@ func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.Base.RoundTrip(req)
 }
 
-func (c *Client) GetAllItems(ctx context.Context, appID, currency string) ([]ResponseItem, error) {
+// Defaults returns the app_id and currency of requests without them, see
+// Config.DefaultAppID
+func (c *Client) Defaults() (appID, currency string) {
+	return c.appID, c.currency
+}
+
+// normalizeParams applies the default app_id/currency, normalizes the currency and
+// returns the app's cache partition, so only valid combinations are fetched and cached
+func (c *Client) normalizeParams(appID, currency string) (string, string, *appCache, error) {
 	// Default values if empty
 	if appID == "" {
-		appID = "730" // Default CS2
+		appID = c.appID
 	}
 	if currency == "" {
-		currency = "EUR" // Default EUR
+		currency = c.currency
+	}
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return "", "", nil, err
+	}
+	cache, ok := c.caches[appID]
+	if !ok {
+		return "", "", nil, fmt.Errorf("%w: %s", ErrUnsupportedApp, appID)
+	}
+	return appID, currency, cache, nil
+}
+
+// cacheKey identifies a cache entry within an app's partition
+type cacheKey struct {
+	currency string
+	view     View
+}
+
+// String is the key's part of CacheEntry.Key: the currency, suffixed by the view unless merged
+func (k cacheKey) String() string {
+	if k.view == ViewMerged {
+		return k.currency
+	}
+	return k.currency + ":" + string(k.view)
+}
+
+// InvalidateCache drops the cached items, in every view, for the given app_id/currency,
+// so the next GetAllItems call fetches fresh data from Skinport
+func (c *Client) InvalidateCache(appID, currency string) {
+	appID, currency, cache, err := c.normalizeParams(appID, currency)
+	if err != nil {
+		return
+	}
+
+	cache.mu.Lock()
+	for key := range cache.entries {
+		if key.currency == currency {
+			c.remove(appID, cache, key)
+		}
+	}
+	cache.mu.Unlock()
+}
+
+// CacheEntries lists the cache entries, by key ("<app_id>:<currency>" for merged items,
+// "<app_id>:<currency>:<view>" for single views)
+func (c *Client) CacheEntries() []CacheEntry {
+	now := time.Now()
+
+	var entries []CacheEntry
+	for appID, cache := range c.caches {
+		cache.mu.RLock()
+		for key, data := range cache.entries {
+			entries = append(entries, CacheEntry{
+				Key:        appID + ":" + key.String(),
+				AppID:      appID,
+				Currency:   key.currency,
+				View:       key.view,
+				Items:      len(data.items),
+				SizeBytes:  data.totalSize(),
+				Snapshots:  len(data.history),
+				FetchedAt:  data.fetchedAt,
+				ExpiresAt:  data.expiry,
+				AgeSeconds: int64(now.Sub(data.fetchedAt).Seconds()),
+				LastUsedAt: time.Unix(0, data.lastUsed.Load()),
+				Expired:    !now.Before(data.expiry),
+			})
+		}
+		cache.mu.RUnlock()
+	}
+
+	slices.SortFunc(entries, func(a, b CacheEntry) int { return strings.Compare(a.Key, b.Key) })
+	if entries == nil {
+		entries = []CacheEntry{}
+	}
+	return entries
+}
+
+// InvalidateCacheKey drops the cache entry with the key (see CacheEntries) and reports
+// whether it existed
+func (c *Client) InvalidateCacheKey(key string) bool {
+	parts := strings.Split(key, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	cache, ok := c.caches[parts[0]]
+	if !ok {
+		return false
+	}
+	k := cacheKey{currency: parts[1], view: ViewMerged}
+	if len(parts) == 3 {
+		k.view = View(parts[2])
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return c.remove(parts[0], cache, k)
+}
+
+// ItemsParams selects the items GetItems returns; zero values mean the defaults
+type ItemsParams struct {
+	AppID    string
+	Currency string
+	// View defaults to ViewMerged
+	View View
+}
+
+// GetAllItems returns the merged items of app_id/currency, see GetItems
+func (c *Client) GetAllItems(ctx context.Context, appID, currency string) ([]ResponseItem, error) {
+	return c.GetItems(ctx, ItemsParams{AppID: appID, Currency: currency})
+}
+
+// GetItems returns the items selected by p, from cache when fresh. Tradable-only and
+// non-tradable-only views are fetched with a single upstream request and have their own
+// cache entries. Unsupported app IDs fail with ErrUnsupportedApp, unsupported currencies
+// with an UnsupportedCurrencyError. The items are the caller's copy, see cloneItems.
+func (c *Client) GetItems(ctx context.Context, p ItemsParams) ([]ResponseItem, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return cloneItems(entry.items), nil
+}
+
+// LookupItems returns the items selected by p whose market_hash_name is one of names,
+// in the order of names, like GetItems but copying only those items. Names not on sale
+// are left out.
+func (c *Client) LookupItems(ctx context.Context, p ItemsParams, names []string) ([]ResponseItem, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
 	}
+	return entry.lookup(entry.byName, names), nil
+}
 
-	cacheKey := fmt.Sprintf("%s:%s", appID, currency)
+// LookupSlugs is LookupItems by slug
+func (c *Client) LookupSlugs(ctx context.Context, p ItemsParams, slugs []string) ([]ResponseItem, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return entry.lookup(entry.bySlug, slugs), nil
+}
+
+// CachedItem is an item with the snapshot it was read from
+type CachedItem struct {
+	Item      ResponseItem
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ItemBySlug returns the item of the snapshot selected by p with the given slug, as a
+// copy. Items missing from the snapshot fail with an ItemNotFoundError.
+func (c *Client) ItemBySlug(ctx context.Context, p ItemsParams, slug string) (CachedItem, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return CachedItem{}, err
+	}
+	return entry.item(entry.bySlug, slug, &ItemNotFoundError{Slug: slug})
+}
+
+// ItemByName is ItemBySlug by market_hash_name
+func (c *Client) ItemByName(ctx context.Context, p ItemsParams, name string) (CachedItem, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return CachedItem{}, err
+	}
+	return entry.item(entry.byName, name, &ItemNotFoundError{MarketHashName: name})
+}
+
+// Stats returns the stats of the dataset selected by p, computed when it was fetched
+func (c *Client) Stats(ctx context.Context, p ItemsParams) (*MarketStats, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return entry.stats, nil
+}
+
+// cachedEntry returns the cache entry selected by p, fetching it when missing or
+// expired. Its items must not be modified.
+func (c *Client) cachedEntry(ctx context.Context, p ItemsParams) (cachedResponse, error) {
+	appID, currency, cache, err := c.normalizeParams(p.AppID, p.Currency)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	view, err := ParseView(string(p.View))
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	key := cacheKey{currency: currency, view: view}
 
-	c.cacheMu.RLock()
-	data, ok := c.cacheData[cacheKey]
+	cache.mu.RLock()
+	data, ok := cache.entries[key]
 	if ok && time.Now().Before(data.expiry) {
-		c.cacheMu.RUnlock()
-		return data.items, nil
+		cache.mu.RUnlock()
+		data.touch()
+		return data, nil
 	}
-	c.cacheMu.RUnlock()
+	cache.mu.RUnlock()
 
-	c.cacheMu.Lock()
-	defer c.cacheMu.Unlock()
+	entry, fetched, err := c.fetchEntry(ctx, appID, cache, key)
+	if fetched {
+		// Outside the partition's lock, eviction takes the locks of the others
+		c.enforceBudget(appID, key)
+	}
+	return entry, err
+}
+
+// fetchEntry fetches the entry under key unless another request did while it waited for
+// the partition's lock, and reports whether it stored a new entry
+func (c *Client) fetchEntry(ctx context.Context, appID string, cache *appCache, key cacheKey) (cachedResponse, bool, error) {
+	currency, view := key.currency, key.view
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
 
 	// Double check logic
-	data, ok = c.cacheData[cacheKey]
+	data, ok := cache.entries[key]
 	if ok && time.Now().Before(data.expiry) {
-		return data.items, nil
+		data.touch()
+		return data, false, nil
+	}
+
+	// An expired entry is revalidated with If-Modified-Since
+	var prev *cachedResponse
+	if ok {
+		prev = &data
+	}
+	entry, err := c.fetchView(ctx, appID, currency, view, prev)
+	if err != nil {
+		c.publishFailure(ctx, appID, currency, view, err)
+		return cachedResponse{}, false, err
+	}
+
+	// Update Cache
+	c.store(appID, cache, key, entry)
+	c.publishRefresh(ctx, appID, currency, view, len(entry.items))
+
+	return entry, true, nil
+}
+
+// Refresh fetches fresh items and replaces the cache entry. Unlike InvalidateCache
+// followed by GetAllItems, readers keep being served the previous entry meanwhile.
+func (c *Client) Refresh(ctx context.Context, appID, currency string) ([]ResponseItem, error) {
+	items, err := c.refresh(ctx, appID, currency, ViewMerged)
+	if err != nil {
+		return nil, err
+	}
+	return cloneItems(items), nil
+}
+
+// refresh replaces the cache entry and returns the cached items, which must not be modified
+func (c *Client) refresh(ctx context.Context, appID, currency string, view View) ([]ResponseItem, error) {
+	appID, currency, cache, err := c.normalizeParams(appID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	key := cacheKey{currency: currency, view: view}
+	cache.mu.RLock()
+	data, ok := cache.entries[key]
+	cache.mu.RUnlock()
+	var prev *cachedResponse
+	if ok {
+		prev = &data
+	}
+
+	entry, err := c.fetchView(ctx, appID, currency, view, prev)
+	if err != nil {
+		c.publishFailure(ctx, appID, currency, view, err)
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	c.store(appID, cache, key, entry)
+	cache.mu.Unlock()
+	c.enforceBudget(appID, key)
+	c.publishRefresh(ctx, appID, currency, view, len(entry.items))
+
+	return entry.items, nil
+}
+
+// publishRefresh announces the items fetched for appID/currency/view
+func (c *Client) publishRefresh(ctx context.Context, appID, currency string, view View, items int) {
+	eventbus.Publish(ctx, c.config.Events, eventbus.PriceRefreshed, eventbus.PriceRefreshedEvent{
+		Source:      eventbus.PriceSourceSkinport,
+		AppID:       appID,
+		Currency:    currency,
+		View:        string(view),
+		Items:       items,
+		RefreshedAt: time.Now().UTC(),
+	})
+}
+
+// publishFailure announces a failed fetch, unless the caller gave up on it
+func (c *Client) publishFailure(ctx context.Context, appID, currency string, view View, err error) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
 	}
+	eventbus.Publish(ctx, c.config.Events, eventbus.UpstreamFailed, eventbus.UpstreamFailedEvent{
+		Source:   eventbus.PriceSourceSkinport,
+		AppID:    appID,
+		Currency: currency,
+		View:     string(view),
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+}
 
-	g, ctx := errgroup.WithContext(ctx)
-	var tradableItems, nonTradableItems []RawItem
+// WarmUp refreshes every cached combination and loads the default app_id/currency when
+// it is not cached, so requests are served from cache instead of waiting for Skinport
+func (c *Client) WarmUp(ctx context.Context) error {
+	type warmKey struct {
+		appID string
+		cacheKey
+	}
+	// The value tells whether the entry is cached
+	keys := map[warmKey]bool{{c.appID, cacheKey{c.currency, ViewMerged}}: false}
+	for appID, cache := range c.caches {
+		cache.mu.RLock()
+		for key := range cache.entries {
+			keys[warmKey{appID, key}] = true
+		}
+		cache.mu.RUnlock()
+	}
+
+	var errs []error
+	for k := range keys {
+		var err error
+		if keys[k] {
+			_, err = c.refresh(ctx, k.appID, k.currency, k.view)
+		} else {
+			// Not cached yet: requests arriving meanwhile wait for this fetch
+			_, err = c.cachedEntry(ctx, ItemsParams{AppID: k.appID, Currency: k.currency, View: k.view})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", k.appID, k.cacheKey, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prefetch loads the entries selected by params that are missing or expired, concurrently,
+// e.g. on startup so the first requests do not wait for Skinport. Entries are fetched
+// under the cache lock: requests for them wait for the fetch instead of sending their own.
+func (c *Client) Prefetch(ctx context.Context, params ...ItemsParams) error {
+	errs := make([]error, len(params))
+	var wg sync.WaitGroup
+	for i, p := range params {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.cachedEntry(ctx, p); err != nil {
+				errs[i] = fmt.Errorf("%s/%s/%s: %w", cmp.Or(p.AppID, c.appID), cmp.Or(p.Currency, c.currency),
+					cmp.Or(p.View, ViewMerged), err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// cloneItems deep-copies cached items, prices included, so callers may modify their copy
+// without corrupting the cache. The copy takes two allocations: items and prices.
+func cloneItems(items []ResponseItem) []ResponseItem {
+	clone := slices.Clone(items)
+	n := 0
+	for _, item := range items {
+		for _, p := range item.prices() {
+			if *p != nil {
+				n++
+			}
+		}
+		if item.Spread != nil {
+			n++
+		}
+		if item.SpreadPercent != nil {
+			n++
+		}
+	}
+
+	prices := make([]float64, 0, n)
+	clonePrice := func(p *float64) *float64 {
+		if p == nil {
+			return nil
+		}
+		prices = append(prices, *p)
+		return &prices[len(prices)-1]
+	}
+	for i := range clone {
+		for _, p := range clone[i].prices() {
+			*p = clonePrice(*p)
+		}
+		clone[i].Spread = clonePrice(clone[i].Spread)
+		clone[i].SpreadPercent = clonePrice(clone[i].SpreadPercent)
+	}
+	return clone
+}
+
+// fetchView fetches the items of a view, both datasets merged or a single one, as a cache
+// entry. With prev, unchanged datasets are revalidated instead of downloaded again, and
+// prev joins the history of a new snapshot.
+func (c *Client) fetchView(ctx context.Context, appID, currency string, view View, prev *cachedResponse) (cachedResponse, error) {
+	var entry cachedResponse
+	var err error
+	if view == ViewMerged {
+		entry, err = c.fetchMerged(ctx, appID, currency, prev)
+	} else {
+		entry, err = c.fetchSingle(ctx, appID, currency, view, prev)
+	}
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	return c.keepHistory(entry, prev), nil
+}
+
+// fetchSingle fetches the items of a tradable or non-tradable view
+func (c *Client) fetchSingle(ctx context.Context, appID, currency string, view View, prev *cachedResponse) (cachedResponse, error) {
+	if c.config.FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.FetchTimeout)
+		defer cancel()
+	}
+	tradable := view == ViewTradable
+	raw := getRawItems()
+	defer putRawItems(raw)
+	meta, err := c.fetchItems(ctx, appID, currency, tradable, prev.lastModified(tradable), raw)
+	if err != nil {
+		return cachedResponse{}, fmt.Errorf("failed to fetch %s items: %w", view, err)
+	}
+	if meta.notModified {
+		return prev.revalidated(meta.ttl), nil
+	}
+
+	result := make([]ResponseItem, len(*raw))
+	for i, item := range *raw {
+		result[i] = newResponseItem(item, tradable)
+	}
+
+	entry := newCachedResponse(result, nil, prev, meta.ttl)
+	if tradable {
+		entry.tradableModified = meta.lastModified
+	} else {
+		entry.nonTradableModified = meta.lastModified
+	}
+	return entry, nil
+}
+
+// The decoded raw items are pooled, they are dropped after every fetch. The merged slice
+// and its index are cached, so they are never pooled.
+var rawItemsPool = sync.Pool{New: func() any { return new([]RawItem) }}
+
+func getRawItems() *[]RawItem {
+	return rawItemsPool.Get().(*[]RawItem)
+}
+
+// putRawItems zeroes the buffer before pooling it: decoding into a reused element would
+// otherwise write through its price pointers, which merged items still reference
+func putRawItems(items *[]RawItem) {
+	clear((*items)[:cap(*items)])
+	*items = (*items)[:0]
+	rawItemsPool.Put(items)
+}
+
+// fetchMerged fetches tradable and non-tradable items in parallel and merges them per item.
+// The merge needs both datasets: when only one of them is unchanged, it is downloaded again.
+func (c *Client) fetchMerged(ctx context.Context, appID, currency string, prev *cachedResponse) (cachedResponse, error) {
+	if c.config.FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.FetchTimeout)
+		defer cancel()
+	}
+
+	tradableItems, nonTradableItems := getRawItems(), getRawItems()
+	defer putRawItems(tradableItems)
+	defer putRawItems(nonTradableItems)
+
+	var tradableMeta, nonTradableMeta datasetMeta
+	g, gctx := errgroup.WithContext(ctx)
 
 	// Request A: Tradable
 	g.Go(func() error {
 		var err error
-		tradableItems, err = c.fetchItems(ctx, appID, currency, true)
+		tradableMeta, err = c.fetchItems(gctx, appID, currency, true, prev.lastModified(true), tradableItems)
 		if err != nil {
 			return fmt.Errorf("failed to fetch tradable items: %w", err)
 		}
@@ -108,7 +822,7 @@ func (c *Client) GetAllItems(ctx context.Context, appID, currency string) ([]Res
 	// Request B: Non-Tradable
 	g.Go(func() error {
 		var err error
-		nonTradableItems, err = c.fetchItems(ctx, appID, currency, false)
+		nonTradableMeta, err = c.fetchItems(gctx, appID, currency, false, prev.lastModified(false), nonTradableItems)
 		if err != nil {
 			return fmt.Errorf("failed to fetch non-tradable items: %w", err)
 		}
@@ -116,60 +830,56 @@ func (c *Client) GetAllItems(ctx context.Context, appID, currency string) ([]Res
 	})
 
 	if err := g.Wait(); err != nil {
-		return nil, err
+		return cachedResponse{}, err
 	}
 
-	// Merge Logic
-	itemMap := make(map[string]*ResponseItem)
-
-	// Process tradable items
-	for _, item := range tradableItems {
-		itemMap[item.MarketHashName] = &ResponseItem{
-			MarketHashName:   item.MarketHashName,
-			Currency:         item.Currency,
-			Slug:             item.Slug,
-			MinPriceTradable: item.MinPrice,
-			Quantity:         item.Quantity,
-		}
+	ttl := min(tradableMeta.ttl, nonTradableMeta.ttl)
+	if tradableMeta.notModified && nonTradableMeta.notModified {
+		return prev.revalidated(ttl), nil
 	}
 
-	// Process non-tradable items
-	for _, item := range nonTradableItems {
-		if existing, exists := itemMap[item.MarketHashName]; exists {
-			existing.MinPriceNonTradable = item.MinPrice
-			// Update quantity if needed, strictly speaking we might want to sum them
-			existing.Quantity += item.Quantity
-		} else {
-			itemMap[item.MarketHashName] = &ResponseItem{
-				MarketHashName:      item.MarketHashName,
-				Currency:            item.Currency,
-				Slug:                item.Slug,
-				MinPriceNonTradable: item.MinPrice,
-				Quantity:            item.Quantity,
-			}
+	var err error
+	if tradableMeta.notModified {
+		if tradableMeta, err = c.fetchItems(ctx, appID, currency, true, "", tradableItems); err != nil {
+			return cachedResponse{}, fmt.Errorf("failed to fetch tradable items: %w", err)
 		}
 	}
-
-	var result []ResponseItem
-	for _, item := range itemMap {
-		result = append(result, *item)
+	if nonTradableMeta.notModified {
+		if nonTradableMeta, err = c.fetchItems(ctx, appID, currency, false, "", nonTradableItems); err != nil {
+			return cachedResponse{}, fmt.Errorf("failed to fetch non-tradable items: %w", err)
+		}
 	}
 
-	// Update Cache
-	c.cacheData[cacheKey] = cachedResponse{
-		items:  result,
-		expiry: time.Now().Add(5 * time.Minute),
+	var items []ResponseItem
+	var byName *itemIndex
+	if workers := c.mergeWorkers(len(*tradableItems) + len(*nonTradableItems)); workers > 1 {
+		items, byName = mergeItemsConcurrent(*tradableItems, *nonTradableItems, workers)
+	} else {
+		items, byName = mergeItems(*tradableItems, *nonTradableItems)
 	}
+	entry := newCachedResponse(items, byName, prev, min(tradableMeta.ttl, nonTradableMeta.ttl))
+	entry.tradableModified = tradableMeta.lastModified
+	entry.nonTradableModified = nonTradableMeta.lastModified
+	return entry, nil
+}
 
-	return result, nil
+// datasetMeta describes a fetched dataset, for caching
+type datasetMeta struct {
+	// ttl is how long the dataset stays fresh, see cacheLifetime
+	ttl          time.Duration
+	lastModified string
+	// notModified means Skinport answered 304 and items were left empty
+	notModified bool
 }
 
-func (c *Client) fetchItems(ctx context.Context, appID, currency string, tradable bool) ([]RawItem, error) {
+// fetchItems decodes one dataset into items, reusing its capacity. A non-empty
+// ifModifiedSince makes the request conditional.
+func (c *Client) fetchItems(ctx context.Context, appID, currency string, tradable bool, ifModifiedSince string, items *[]RawItem) (datasetMeta, error) {
 	url := fmt.Sprintf("%s/items", c.config.APIURL)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return datasetMeta{}, err
 	}
 
 	q := req.URL.Query()
@@ -179,31 +889,88 @@ func (c *Client) fetchItems(ctx context.Context, appID, currency string, tradabl
 
 	req.URL.RawQuery = q.Encode()
 
+	userAgent := c.config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	requestID := audit.RequestIDFrom(ctx)
+	if requestID != "" {
+		req.Header.Set(requestIDHeader, requestID)
+	}
+	if ifModifiedSince != "" {
+		req.Header.Set("If-Modified-Since", ifModifiedSince)
+	}
+
+	// The slot is held until the body is read, the connection is in use until then
+	if err := c.upstream.acquire(ctx); err != nil {
+		return datasetMeta{}, err
+	}
+	defer c.upstream.release()
+	if err := c.limiter.wait(ctx); err != nil {
+		return datasetMeta{}, err
+	}
+	start := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		metrics.SkinportRequests.WithLabelValues("error").Inc()
+		return datasetMeta{}, err
 	}
+	observeResponse(ctx, resp, time.Since(start), "app_id", appID, "currency", currency, "tradable", tradable)
 
 	if resp.Header.Get("Content-Encoding") == "br" {
 		resp.Body = &readCloserWrapper{Reader: brotli.NewReader(resp.Body), Closer: resp.Body}
 	}
 	defer resp.Body.Close()
 
+	meta := datasetMeta{ttl: cacheLifetime(resp.Header, time.Now()), lastModified: resp.Header.Get("Last-Modified")}
+	if resp.StatusCode == http.StatusNotModified && ifModifiedSince != "" {
+		meta.notModified = true
+		if meta.lastModified == "" {
+			meta.lastModified = ifModifiedSince
+		}
+		return meta, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		var apiErr ErrorResponse
 		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && len(apiErr.Errors) > 0 {
-			return nil, &apiErr
+			return datasetMeta{}, &apiErr
 		}
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		return datasetMeta{}, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
 	}
 
-	var items []RawItem
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
-		return nil, err
+	skipped, err := decodeItems(resp.Body, c.config.DecodeMode, items)
+	if skipped > 0 {
+		metrics.SkinportDecodeErrors.WithLabelValues(appID).Add(float64(skipped))
+		slog.WarnContext(ctx, "skipped malformed skinport items", "app_id", appID, "currency", currency,
+			"tradable", tradable, "skipped", skipped)
 	}
+	return meta, err
+}
+
+// observeResponse records the response status and Skinport's rate limit headers in the
+// metrics and the debug log
+func observeResponse(ctx context.Context, resp *http.Response, elapsed time.Duration, attrs ...any) {
+	metrics.SkinportRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 
-	return items, nil
+	attrs = append(attrs, "status", resp.StatusCode, "duration", elapsed, "request_id", audit.RequestIDFrom(ctx))
+	for name, values := range resp.Header {
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "ratelimit") || lower == "retry-after" {
+			attrs = append(attrs, lower, strings.Join(values, ","))
+		}
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		metrics.SkinportRateLimitRemaining.Set(float64(remaining))
+	}
+
+	level := slog.LevelDebug
+	if resp.StatusCode == http.StatusTooManyRequests {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "skinport response", attrs...)
 }
 
 type readCloserWrapper struct {
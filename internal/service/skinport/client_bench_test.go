@@ -0,0 +1,96 @@
+package skinport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+// newBenchServer serves count items per tradable flag from pre-encoded bodies,
+// so the benchmarks measure the client rather than the mock
+func newBenchServer(b *testing.B, count int) *httptest.Server {
+	b.Helper()
+
+	bodies := map[string][]byte{}
+	for _, tradable := range []string{"true", "false"} {
+		items := make([]RawItem, count)
+		for i := range items {
+			items[i] = RawItem{
+				MarketHashName: fmt.Sprintf("Item-%d", i),
+				Currency:       "EUR",
+				Slug:           fmt.Sprintf("item-%d", i),
+				MinPrice:       floatPtr(float64(i) + 0.5),
+				Quantity:       1,
+			}
+		}
+		data, err := json.Marshal(items)
+		if err != nil {
+			b.Fatal(err)
+		}
+		bodies[tradable] = data
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bodies[r.URL.Query().Get("tradable")])
+	}))
+	b.Cleanup(ts.Close)
+	return ts
+}
+
+func BenchmarkGetAllItems_Cached(b *testing.B) {
+	client := NewClient(Config{APIURL: newBenchServer(b, 10000).URL})
+	ctx := context.Background()
+	if _, err := client.GetAllItems(ctx, "730", "EUR"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.GetAllItems(ctx, "730", "EUR"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkFetchMerged(b *testing.B) {
+	for _, count := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("items=%d", count), func(b *testing.B) {
+			client := NewClient(Config{APIURL: newBenchServer(b, count).URL})
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := client.fetchMerged(ctx, "730", "EUR", nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMergeItems compares merging in place with merging across GOMAXPROCS workers
+func BenchmarkMergeItems(b *testing.B) {
+	for _, count := range []int{100000, 1000000} {
+		tradable, nonTradable := rawDatasets(count)
+		b.Run(fmt.Sprintf("items=%d/sequential", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				mergeItems(tradable, nonTradable)
+			}
+		})
+		b.Run(fmt.Sprintf("items=%d/concurrent", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				mergeItemsConcurrent(tradable, nonTradable, runtime.GOMAXPROCS(0))
+			}
+		})
+	}
+}
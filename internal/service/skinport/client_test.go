@@ -2,14 +2,24 @@ package skinport
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"fsanano/go-test/internal/audit"
+	"fsanano/go-test/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -79,6 +89,10 @@ func TestGetAllItems_Success(t *testing.T) {
 		assert.Equal(t, 9.0, *itemA.MinPriceNonTradable)
 	}
 	assert.Equal(t, 7, itemA.Quantity) // 5 + 2
+	if assert.NotNil(t, itemA.Spread) && assert.NotNil(t, itemA.SpreadPercent) {
+		assert.Equal(t, 1.5, *itemA.Spread)
+		assert.Equal(t, 14.29, *itemA.SpreadPercent)
+	}
 
 	// Check Item B (Only tradable)
 	itemB, ok := itemMap["Item B"]
@@ -90,6 +104,8 @@ func TestGetAllItems_Success(t *testing.T) {
 	// The client logic does not set it to 0 explicitly if missing, it's a pointer.
 	// So it should be nil.
 	assert.Equal(t, 1, itemB.Quantity)
+	assert.Nil(t, itemB.Spread, "no spread without both prices")
+	assert.Nil(t, itemB.SpreadPercent)
 
 	// Check Item C (Only non-tradable)
 	itemC, ok := itemMap["Item C"]
@@ -101,6 +117,64 @@ func TestGetAllItems_Success(t *testing.T) {
 	assert.Equal(t, 3, itemC.Quantity)
 }
 
+func TestGetAllItems_Statistics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tradable") == "true" {
+			w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","suggested_price":12.3,
+				"item_page":"https://skinport.com/item/item-a","market_page":"https://skinport.com/market?item=Item%20A",
+				"min_price":10,"max_price":15,"mean_price":12.5,"median_price":12,"quantity":3}]`))
+			return
+		}
+		w.Write([]byte(`[{"market_hash_name":"Item A","currency":"EUR","suggested_price":12.3,"min_price":8,"max_price":9,
+			"mean_price":8.5,"median_price":8.4,"quantity":2},
+			{"market_hash_name":"Item B","currency":"EUR","item_page":"https://skinport.com/item/item-b","min_price":1,"quantity":1}]`))
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+
+	items, err := client.GetAllItems(context.Background(), "730", "EUR")
+	assert.NoError(t, err)
+	if !assert.Len(t, items, 2) {
+		return
+	}
+	a := items[0]
+	assert.Equal(t, 12.3, *a.SuggestedPrice)
+	assert.Equal(t, "https://skinport.com/item/item-a", a.ItemPage)
+	assert.Equal(t, "https://skinport.com/market?item=Item%20A", a.MarketPage)
+	assert.Equal(t, []float64{15, 9, 12.5, 8.5, 12, 8.4},
+		[]float64{*a.MaxPriceTradable, *a.MaxPriceNonTradable, *a.MeanPriceTradable, *a.MeanPriceNonTradable,
+			*a.MedianPriceTradable, *a.MedianPriceNonTradable})
+
+	b := items[1]
+	assert.Nil(t, b.SuggestedPrice)
+	assert.Nil(t, b.MaxPriceTradable)
+	assert.Nil(t, b.MaxPriceNonTradable, "prices Skinport leaves out stay nil")
+	assert.Equal(t, "https://skinport.com/item/item-b", b.ItemPage)
+
+	ConvertPrices(items, "USD", 2)
+	assert.Equal(t, 24.6, *items[0].SuggestedPrice)
+	assert.Equal(t, 16.8, *items[0].MedianPriceNonTradable)
+	cached, err := client.GetAllItems(context.Background(), "730", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, 8.4, *cached[0].MedianPriceNonTradable, "the copy's prices are converted, not the cache's")
+}
+
+func TestMergeItems_Times(t *testing.T) {
+	items, _ := mergeItems(
+		[]RawItem{{MarketHashName: "A", CreatedAt: 1700000000, UpdatedAt: 1760000000}, {MarketHashName: "B"}},
+		[]RawItem{{MarketHashName: "A", CreatedAt: 1600000000, UpdatedAt: 1750000000}, {MarketHashName: "B", UpdatedAt: 1760000000}},
+	)
+	assert.Equal(t, time.Unix(1600000000, 0).UTC(), items[0].CreatedAt, "the earliest listing")
+	assert.Equal(t, time.Unix(1760000000, 0).UTC(), items[0].UpdatedAt, "the latest change")
+	assert.True(t, items[1].CreatedAt.IsZero(), "not sent")
+	assert.Equal(t, time.Unix(1760000000, 0).UTC(), items[1].UpdatedAt)
+
+	body, err := json.Marshal(items[1])
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "created_at")
+	assert.Contains(t, string(body), `"updated_at":"2025-10-09T08:53:20Z"`)
+}
+
 func TestGetAllItems_Cache(t *testing.T) {
 	requestCount := 0
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -122,6 +196,249 @@ func TestGetAllItems_Cache(t *testing.T) {
 	assert.Equal(t, 2, requestCount, "Should not increment request count due to caching")
 }
 
+func TestCacheEntries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item A", Currency: r.URL.Query().Get("currency"), MinPrice: floatPtr(1)}})
+	}))
+	defer ts.Close()
+
+	client := NewClient(Config{APIURL: ts.URL})
+	assert.Empty(t, client.CacheEntries())
+
+	_, err := client.GetAllItems(context.Background(), "", "")
+	assert.NoError(t, err)
+	_, err = client.GetAllItems(context.Background(), "252490", "USD")
+	assert.NoError(t, err)
+
+	entries := client.CacheEntries()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "252490:USD", entries[0].Key)
+		assert.Equal(t, "730:EUR", entries[1].Key)
+		assert.Equal(t, "730", entries[1].AppID)
+		assert.Equal(t, 1, entries[1].Items)
+		assert.Positive(t, entries[1].SizeBytes)
+		assert.Equal(t, cacheTTL, entries[1].ExpiresAt.Sub(entries[1].FetchedAt))
+		assert.False(t, entries[1].Expired)
+	}
+
+	assert.True(t, client.InvalidateCacheKey("730:EUR"))
+	assert.False(t, client.InvalidateCacheKey("730:EUR"), "already dropped")
+	entries = client.CacheEntries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "252490:USD", entries[0].Key)
+	}
+}
+
+func TestGetAllItems_UnsupportedApp(t *testing.T) {
+	requested := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested[r.URL.Query().Get("app_id")] = true
+		json.NewEncoder(w).Encode([]RawItem{})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+
+	_, err := client.GetAllItems(context.Background(), "123", "")
+	assert.ErrorIs(t, err, ErrUnsupportedApp)
+	_, err = client.Refresh(context.Background(), "123", "")
+	assert.ErrorIs(t, err, ErrUnsupportedApp)
+	assert.Empty(t, requested, "unsupported apps are not fetched")
+
+	for _, app := range Apps() {
+		assert.NoError(t, ValidateAppID(app.ID))
+		_, err := client.GetAllItems(context.Background(), app.ID, "")
+		assert.NoError(t, err)
+	}
+	assert.Len(t, requested, 4)
+	assert.NoError(t, ValidateAppID(""), "default app")
+	assert.Len(t, client.CacheEntries(), 4, "one partition per app")
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	for input, want := range map[string]string{"": "", "eur": "EUR", " USD ": "USD", "€": "EUR", "R$": "BRL", "zł": "PLN"} {
+		got, err := NormalizeCurrency(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	_, err := NormalizeCurrency("XYZ")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+	var currencyErr *UnsupportedCurrencyError
+	if assert.ErrorAs(t, err, &currencyErr) {
+		assert.Equal(t, Currencies(), currencyErr.Allowed)
+	}
+}
+
+func TestGetAllItems_CurrencyCacheKey(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("currency"))
+		json.NewEncoder(w).Encode([]RawItem{})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+
+	for _, currency := range []string{"usd", "USD", "$"} {
+		_, err := client.GetAllItems(context.Background(), "", currency)
+		assert.NoError(t, err)
+	}
+	_, err := client.GetAllItems(context.Background(), "", "garbage")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+
+	assert.Equal(t, []string{"USD", "USD"}, requested, "fetched once, normalized")
+	entries := client.CacheEntries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "730:USD", entries[0].Key)
+	}
+}
+
+func TestGetItems_SingleView(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("tradable"))
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(9), Quantity: 2}})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	ctx := context.Background()
+
+	items, err := client.GetItems(ctx, ItemsParams{View: ViewNonTradable})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"false"}, requested, "a single upstream request")
+	if assert.Len(t, items, 1) {
+		assert.Nil(t, items[0].MinPriceTradable)
+		assert.Equal(t, floatPtr(9), items[0].MinPriceNonTradable)
+	}
+
+	_, err = client.GetItems(ctx, ItemsParams{View: ViewNonTradable})
+	assert.NoError(t, err)
+	_, err = client.GetAllItems(ctx, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, requested, 3, "the single view is cached, merged items are not shared with it")
+
+	keys := []string{}
+	for _, e := range client.CacheEntries() {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{"730:EUR", "730:EUR:nontradable"}, keys)
+
+	_, err = client.GetItems(ctx, ItemsParams{View: "both"})
+	assert.Error(t, err)
+}
+
+func TestFetchMerged_PooledBuffers(t *testing.T) {
+	price := 1.0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{
+			{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(price), Quantity: 1},
+			{MarketHashName: "Item B", Currency: "EUR", MinPrice: floatPtr(price), Quantity: 1},
+		})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	ctx := context.Background()
+
+	first, err := client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	price = 2
+	for range 3 {
+		second, err := client.Refresh(ctx, "730", "USD")
+		assert.NoError(t, err)
+		assert.Equal(t, 2.0, *second[0].MinPriceTradable)
+	}
+
+	// Later fetches decode into the pooled buffers of the first one
+	for _, item := range first {
+		assert.Equal(t, 1.0, *item.MinPriceTradable, item.MarketHashName)
+		assert.Equal(t, 1.0, *item.MinPriceNonTradable, item.MarketHashName)
+		assert.Equal(t, 2, item.Quantity)
+	}
+	assert.Equal(t, "Item A", first[0].MarketHashName, "merged in order of first appearance")
+}
+
+func TestGetAllItems_ReturnsCopies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{
+			{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(1), Quantity: 1},
+			{MarketHashName: "Item B", Currency: "EUR", MinPrice: floatPtr(2), Quantity: 1},
+		})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	ctx := context.Background()
+
+	_, err := client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+
+	// Callers modify their results while others read theirs; run with -race
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				items, err := client.GetAllItems(ctx, "730", "EUR")
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, "Item A", items[0].MarketHashName)
+				assert.Equal(t, 1.0, *items[0].MinPriceTradable)
+
+				items[0].MarketHashName = "changed"
+				*items[0].MinPriceTradable = 100
+				items[1].MinPriceNonTradable = nil
+			}
+		}()
+	}
+	wg.Wait()
+
+	refreshed, err := client.Refresh(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	*refreshed[1].MinPriceTradable = 100
+
+	items, err := client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "Item A", items[0].MarketHashName)
+	assert.Equal(t, 1.0, *items[0].MinPriceTradable)
+	assert.Equal(t, 2.0, *items[1].MinPriceTradable)
+	assert.Equal(t, 2.0, *items[1].MinPriceNonTradable)
+}
+
+func TestLookupItems(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode([]RawItem{
+			{MarketHashName: "Item A", Currency: "EUR", Slug: "item-a", MinPrice: floatPtr(1), Quantity: 1},
+			{MarketHashName: "Item B", Currency: "EUR", Slug: "item-b", MinPrice: floatPtr(2), Quantity: 1},
+			{MarketHashName: "Item C", Currency: "EUR", Slug: "item-c", MinPrice: floatPtr(3), Quantity: 1},
+		})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	ctx := context.Background()
+
+	items, err := client.LookupItems(ctx, ItemsParams{}, []string{"Item C", "Missing", "Item A", "Item C"})
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "Item C", items[0].MarketHashName, "in the order asked for")
+		assert.Equal(t, "Item A", items[1].MarketHashName)
+	}
+	*items[1].MinPriceTradable = 100
+
+	items, err = client.LookupSlugs(ctx, ItemsParams{}, []string{"item-b", "item-z"})
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "Item B", items[0].MarketHashName)
+	}
+	fetched := requests
+
+	all, err := client.GetAllItems(ctx, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, *all[0].MinPriceTradable, "lookups return copies")
+	assert.Equal(t, fetched, requests, "the lookup filled the cache of the merged view")
+}
+
 func TestGetAllItems_APIError(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -222,3 +539,347 @@ func TestGetAllItems_LargeDataset(t *testing.T) {
 
 	t.Logf("Processed %d items in %v", count*2, duration)
 }
+
+func TestGetAllItems_FetchTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(Config{APIURL: ts.URL, FetchTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := client.GetAllItems(context.Background(), "730", "EUR")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the fetch deadline applies before the client timeout")
+}
+
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+	auth     string
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests++
+	t.auth = req.Header.Get("Authorization")
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewClient_HTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{})
+	}))
+	defer ts.Close()
+
+	transport := &countingTransport{}
+	httpClient := &http.Client{Transport: transport}
+	client := NewClient(Config{APIURL: ts.URL, ClientID: "id", APIKey: "key", HTTPClient: httpClient})
+
+	_, err := client.GetAllItems(context.Background(), "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, transport.requests)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("id:key")), transport.auth)
+	assert.Same(t, transport, httpClient.Transport, "the caller's client is not modified")
+}
+
+func TestNewClient_Transport(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		var mu sync.Mutex
+		var proxied []string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Proxied requests carry the absolute upstream URL
+			mu.Lock()
+			proxied = append(proxied, r.URL.Host)
+			mu.Unlock()
+			json.NewEncoder(w).Encode([]RawItem{})
+		}))
+		defer proxy.Close()
+		proxyURL, _ := url.Parse(proxy.URL)
+
+		client := NewClient(Config{APIURL: "http://skinport.test/v1", Transport: TransportConfig{ProxyURL: proxyURL}})
+		_, err := client.GetAllItems(context.Background(), "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"skinport.test", "skinport.test"}, proxied)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]RawItem{})
+		}))
+		defer ts.Close()
+
+		_, err := NewClient(Config{APIURL: ts.URL}).GetAllItems(context.Background(), "", "")
+		assert.Error(t, err, "the test CA is not trusted by default")
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ts.Certificate())
+		client := NewClient(Config{APIURL: ts.URL, Transport: TransportConfig{
+			TLSConfig:       &tls.Config{RootCAs: roots},
+			MaxConnsPerHost: 1,
+		}})
+		_, err = client.GetAllItems(context.Background(), "", "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("dialer", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]RawItem{})
+		}))
+		defer ts.Close()
+
+		var mu sync.Mutex
+		var dialed []string
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+			return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+		}
+		client := NewClient(Config{APIURL: "http://skinport.test", Transport: TransportConfig{DialContext: dial}})
+		_, err := client.GetAllItems(context.Background(), "", "")
+		assert.NoError(t, err)
+		assert.Contains(t, dialed, "skinport.test:80")
+	})
+}
+
+func TestFetchItems_RequestHeaders(t *testing.T) {
+	var mu sync.Mutex
+	headers := map[string]http.Header{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.URL.Query().Get("currency")] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("X-RateLimit-Limit", "8")
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		json.NewEncoder(w).Encode([]RawItem{})
+	}))
+	defer ts.Close()
+
+	before := testutil.ToFloat64(metrics.SkinportRequests.WithLabelValues("200"))
+	client := NewClient(Config{APIURL: ts.URL, UserAgent: "shop-test/2.0"})
+	ctx := audit.WithRequestID(context.Background(), "req-123")
+	_, err := client.GetAllItems(ctx, "", "EUR")
+	assert.NoError(t, err)
+	_, err = NewClient(Config{APIURL: ts.URL}).GetAllItems(context.Background(), "", "USD")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "shop-test/2.0", headers["EUR"].Get("User-Agent"))
+	assert.Equal(t, "req-123", headers["EUR"].Get("X-Request-Id"))
+	assert.Equal(t, DefaultUserAgent, headers["USD"].Get("User-Agent"))
+	assert.Empty(t, headers["USD"].Get("X-Request-Id"), "no request ID outside requests")
+
+	assert.Equal(t, before+4, testutil.ToFloat64(metrics.SkinportRequests.WithLabelValues("200")))
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.SkinportRateLimitRemaining))
+}
+
+func TestCacheLifetime(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"no headers", http.Header{}, cacheTTL},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=120"}}, 2 * time.Minute},
+		{"max-age minus age", http.Header{"Cache-Control": {"max-age=300"}, "Age": {"60"}}, 4 * time.Minute},
+		{"max-age over expires", http.Header{"Cache-Control": {"max-age=120"}, "Expires": {"Wed, 01 Jan 2025 12:10:00 GMT"}}, 2 * time.Minute},
+		{"expires from date", http.Header{"Expires": {"Wed, 01 Jan 2025 10:10:00 GMT"}, "Date": {"Wed, 01 Jan 2025 10:00:00 GMT"}}, 10 * time.Minute},
+		{"expires from now", http.Header{"Expires": {"Wed, 01 Jan 2025 12:03:00 GMT"}}, 3 * time.Minute},
+		{"no-cache is clamped", http.Header{"Cache-Control": {"max-age=600, no-cache"}}, minCacheTTL},
+		{"invalid expires is clamped", http.Header{"Expires": {"0"}}, minCacheTTL},
+		{"long max-age is clamped", http.Header{"Cache-Control": {"max-age=86400"}}, maxCacheTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cacheLifetime(tt.header, now))
+		})
+	}
+}
+
+func TestGetAllItems_ConditionalGet(t *testing.T) {
+	const lastModified = "Wed, 01 Jan 2025 10:00:00 GMT"
+	var mu sync.Mutex
+	var conditional, full int
+	tradableChanged := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Cache-Control", "max-age=120")
+		tradable := r.URL.Query().Get("tradable") == "true"
+		if r.Header.Get("If-Modified-Since") == lastModified && !(tradable && tradableChanged) {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("Last-Modified", lastModified)
+		price := 1.0
+		if tradable && tradableChanged {
+			price = 3
+		}
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(price), Quantity: 1}})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	ctx := context.Background()
+	expire := func() {
+		cache := client.caches["730"]
+		cache.mu.Lock()
+		for key, entry := range cache.entries {
+			entry.expiry = time.Now().Add(-time.Second)
+			cache.entries[key] = entry
+		}
+		cache.mu.Unlock()
+	}
+
+	items, err := client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, full)
+	entry := client.CacheEntries()[0]
+	assert.Equal(t, 2*time.Minute, entry.ExpiresAt.Sub(entry.FetchedAt), "expiry follows max-age")
+
+	// Both datasets unchanged: the entry is revalidated, nothing is downloaded
+	expire()
+	items, err = client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, conditional)
+	assert.Equal(t, 2, full)
+	assert.Equal(t, 1.0, *items[0].MinPriceTradable)
+	assert.False(t, client.CacheEntries()[0].Expired)
+
+	// One dataset changed: the other is downloaded again for the merge
+	mu.Lock()
+	tradableChanged = true
+	mu.Unlock()
+	expire()
+	items, err = client.GetAllItems(ctx, "730", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, conditional)
+	assert.Equal(t, 4, full)
+	assert.Equal(t, 3.0, *items[0].MinPriceTradable)
+	assert.Equal(t, 1.0, *items[0].MinPriceNonTradable)
+}
+
+func TestRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	// Both requests of a fetch fit the limit, the next fetch would wait minutes
+	client := NewClient(Config{APIURL: ts.URL, RateLimit: 2, RateLimitWindow: 10 * time.Minute})
+	_, err := client.GetAllItems(context.Background(), "", "EUR")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = client.GetAllItems(ctx, "", "USD")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	mu.Lock()
+	assert.Equal(t, 2, requests)
+	mu.Unlock()
+
+	t.Run("tokens refill", func(t *testing.T) {
+		limiter := newRateLimiter(1, 50*time.Millisecond)
+		assert.NoError(t, limiter.wait(context.Background()))
+		start := time.Now()
+		assert.NoError(t, limiter.wait(context.Background()))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		assert.Nil(t, newRateLimiter(0, time.Minute))
+		var limiter *rateLimiter
+		assert.NoError(t, limiter.wait(context.Background()))
+	})
+}
+
+func TestPrefetch(t *testing.T) {
+	var requests atomic.Int32
+	started := make(chan struct{}, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`[{"market_hash_name": "AK-47", "currency": "EUR", "min_price": 10, "quantity": 1}]`))
+	}))
+	defer ts.Close()
+
+	client := NewClient(Config{APIURL: ts.URL})
+	done := make(chan error)
+	go func() { done <- client.Prefetch(context.Background(), ItemsParams{}) }()
+
+	// A request arriving during the warm-up waits for it instead of fetching again
+	<-started
+	items, err := client.GetAllItems(context.Background(), "730", "EUR")
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(2), requests.Load(), "one fetch: the tradable and non-tradable datasets")
+
+	assert.NoError(t, client.Prefetch(context.Background(), ItemsParams{}), "fresh entries are not fetched again")
+	assert.Equal(t, int32(2), requests.Load())
+
+	err = client.Prefetch(context.Background(), ItemsParams{AppID: "1"}, ItemsParams{Currency: "XXX"})
+	assert.ErrorIs(t, err, ErrUnsupportedApp)
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	// Six entries expiring together send twelve requests, two at a time
+	client := NewClient(Config{APIURL: ts.URL, MaxConcurrentRequests: 2})
+	var wg sync.WaitGroup
+	for _, currency := range []string{"EUR", "USD", "GBP", "PLN", "CNY", "BRL"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetAllItems(context.Background(), "", currency)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak.Load())
+
+	t.Run("waits until ctx is done", func(t *testing.T) {
+		limiter := newConcurrencyLimiter(1)
+		assert.NoError(t, limiter.acquire(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, limiter.acquire(ctx), context.DeadlineExceeded)
+		limiter.release()
+		assert.NoError(t, limiter.acquire(context.Background()))
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		assert.Nil(t, newConcurrencyLimiter(0))
+		var limiter *concurrencyLimiter
+		assert.NoError(t, limiter.acquire(context.Background()))
+		limiter.release()
+	})
+}
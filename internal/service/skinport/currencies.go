@@ -0,0 +1,88 @@
+package skinport
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// supportedCurrencies are the currencies Skinport prices items in
+var supportedCurrencies = []string{
+	"AUD", "BRL", "CAD", "CHF", "CNY", "CZK", "DKK", "EUR", "GBP", "HRK", "NOK", "PLN", "RUB", "SEK", "TRY", "USD",
+}
+
+// currencyAliases maps symbols to their currency; ambiguous symbols (kr, ¥) are left out
+// except where Skinport supports a single candidate
+var currencyAliases = map[string]string{
+	"€":   "EUR",
+	"$":   "USD",
+	"US$": "USD",
+	"£":   "GBP",
+	"A$":  "AUD",
+	"C$":  "CAD",
+	"R$":  "BRL",
+	"¥":   "CNY",
+	"Kč":  "CZK",
+	"zł":  "PLN",
+	"₽":   "RUB",
+	"₺":   "TRY",
+}
+
+// ErrUnsupportedCurrency is matched (via errors.Is) by UnsupportedCurrencyError
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// UnsupportedCurrencyError is returned for currencies Skinport does not price items in
+type UnsupportedCurrencyError struct {
+	Currency string   `json:"currency"`
+	Allowed  []string `json:"allowed"`
+}
+
+func (e *UnsupportedCurrencyError) Error() string {
+	return fmt.Sprintf("unsupported currency: %s", e.Currency)
+}
+
+func (e *UnsupportedCurrencyError) Is(target error) bool {
+	return target == ErrUnsupportedCurrency
+}
+
+// Currencies lists the supported currency codes
+func Currencies() []string {
+	return slices.Clone(supportedCurrencies)
+}
+
+// NormalizeCurrency resolves a currency code in any case or a currency symbol to its
+// upper-case code. Empty stays empty (the default currency).
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.TrimSpace(currency)
+	if currency == "" {
+		return "", nil
+	}
+	if code, ok := currencyAliases[currency]; ok {
+		return code, nil
+	}
+	code := strings.ToUpper(currency)
+	if !slices.Contains(supportedCurrencies, code) {
+		return "", &UnsupportedCurrencyError{Currency: currency, Allowed: Currencies()}
+	}
+	return code, nil
+}
+
+// ConvertPrices multiplies the items' prices by rate, rounded to cents, and sets their
+// currency. It updates items in place, which must be the caller's copy (see GetItems).
+func ConvertPrices(items []ResponseItem, currency string, rate float64) {
+	convert := func(p *float64) {
+		if p != nil {
+			*p = math.Round(*p*rate*100) / 100
+		}
+	}
+	for i := range items {
+		items[i].Currency = currency
+		for _, p := range items[i].prices() {
+			convert(*p)
+		}
+		// The spread of the rounded prices, not the converted one, so they add up
+		items[i].setSpread()
+	}
+}
@@ -0,0 +1,132 @@
+package skinport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeMode selects how the item lists of Skinport are decoded
+type DecodeMode string
+
+const (
+	// DecodeStandard ignores unknown fields and fails the fetch on any malformed item
+	DecodeStandard DecodeMode = "standard"
+	// DecodeStrict also fails it on unknown fields and on items missing a required field,
+	// catching changes of Skinport's schema before they corrupt the cache
+	DecodeStrict DecodeMode = "strict"
+	// DecodeTolerant skips malformed items instead of failing the fetch, counting them in
+	// skinport_decode_errors_total. Invalid JSON still fails it.
+	DecodeTolerant DecodeMode = "tolerant"
+)
+
+// ParseDecodeMode parses a decode mode name; empty means DecodeStandard
+func ParseDecodeMode(s string) (DecodeMode, error) {
+	switch DecodeMode(s) {
+	case "", DecodeStandard:
+		return DecodeStandard, nil
+	case DecodeStrict, DecodeTolerant:
+		return DecodeMode(s), nil
+	}
+	return "", fmt.Errorf("decode mode must be %s, %s or %s", DecodeStandard, DecodeStrict, DecodeTolerant)
+}
+
+// requiredFields are the fields DecodeStrict requires on every item; min_price may be null
+var requiredFields = []string{"market_hash_name", "currency", "min_price", "quantity"}
+
+// ErrInvalidItem is matched (via errors.Is) by InvalidItemError
+var ErrInvalidItem = errors.New("invalid skinport item")
+
+// InvalidItemError is returned for an item of a Skinport response that does not match
+// the expected schema
+type InvalidItemError struct {
+	// Index is the item's position in the response
+	Index  int
+	Reason string
+}
+
+func (e *InvalidItemError) Error() string {
+	return fmt.Sprintf("invalid skinport item %d: %s", e.Index, e.Reason)
+}
+
+func (e *InvalidItemError) Is(target error) bool {
+	return target == ErrInvalidItem
+}
+
+// decodeItems decodes a response's item list into items, reusing its capacity. It
+// returns the number of items DecodeTolerant skipped.
+func decodeItems(r io.Reader, mode DecodeMode, items *[]RawItem) (int, error) {
+	dec := json.NewDecoder(r)
+	if mode != DecodeStrict && mode != DecodeTolerant {
+		return 0, dec.Decode(items)
+	}
+
+	if tok, err := dec.Token(); err != nil {
+		return 0, err
+	} else if tok != json.Delim('[') {
+		return 0, fmt.Errorf("expected a list of items, got %v", tok)
+	}
+	*items = (*items)[:0]
+	skipped := 0
+	for i := 0; dec.More(); i++ {
+		if mode == DecodeStrict {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return 0, err
+			}
+			item, err := decodeStrict(raw)
+			if err != nil {
+				return 0, &InvalidItemError{Index: i, Reason: err.Error()}
+			}
+			*items = append(*items, item)
+			continue
+		}
+
+		// The decoder reads the whole element before decoding it, so a mistyped field
+		// leaves it at the next one; only invalid JSON cannot be skipped
+		*items = append(*items, RawItem{})
+		item := &(*items)[len(*items)-1]
+		err := dec.Decode(item)
+		var typeErr *json.UnmarshalTypeError
+		if err != nil && !errors.As(err, &typeErr) {
+			return 0, err
+		}
+		if err != nil || item.MarketHashName == "" {
+			*item = RawItem{}
+			*items = (*items)[:len(*items)-1]
+			skipped++
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return 0, err
+	}
+	return skipped, nil
+}
+
+// decodeStrict decodes one item, rejecting unknown fields and missing required ones
+func decodeStrict(raw json.RawMessage) (RawItem, error) {
+	var item RawItem
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&item); err != nil {
+		return RawItem{}, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return RawItem{}, err
+	}
+	var missing []string
+	for _, name := range requiredFields {
+		if _, ok := fields[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return RawItem{}, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return item, nil
+}
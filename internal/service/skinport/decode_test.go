@@ -0,0 +1,87 @@
+package skinport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fsanano/go-test/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeItems(t *testing.T) {
+	const valid = `{"market_hash_name":"A","currency":"EUR","min_price":1.5,"quantity":2}`
+	decode := func(mode DecodeMode, body string) ([]RawItem, int, error) {
+		var items []RawItem
+		skipped, err := decodeItems(strings.NewReader(body), mode, &items)
+		return items, skipped, err
+	}
+
+	for _, mode := range []DecodeMode{DecodeStandard, DecodeStrict, DecodeTolerant} {
+		items, skipped, err := decode(mode, "["+valid+`,{"market_hash_name":"B","currency":"EUR","min_price":null,"quantity":1}]`)
+		require.NoError(t, err, mode)
+		assert.Zero(t, skipped)
+		require.Len(t, items, 2, mode)
+		assert.Equal(t, 1.5, *items[0].MinPrice)
+		assert.Nil(t, items[1].MinPrice)
+
+		_, _, err = decode(mode, "["+valid+`,{"market_hash_name":`)
+		assert.Error(t, err, "%s: invalid JSON fails every mode", mode)
+	}
+
+	unknown := "[" + valid + `,{"market_hash_name":"B","currency":"EUR","min_price":1,"quantity":1,"rarity":"covert"}]`
+	_, _, err := decode(DecodeStandard, unknown)
+	assert.NoError(t, err)
+	_, _, err = decode(DecodeStrict, unknown)
+	var itemErr *InvalidItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+	assert.Equal(t, `invalid skinport item 1: unknown field "rarity"`, err.Error())
+
+	_, _, err = decode(DecodeStrict, `[{"market_hash_name":"A","min_price":null}]`)
+	assert.ErrorIs(t, err, ErrInvalidItem)
+	assert.EqualError(t, err, "invalid skinport item 0: missing currency, quantity")
+
+	malformed := "[" + valid + `,{"market_hash_name":"B","quantity":"many"},{"currency":"EUR"},42,` + valid + "]"
+	_, _, err = decode(DecodeStandard, malformed)
+	assert.Error(t, err)
+	items, skipped, err := decode(DecodeTolerant, malformed)
+	require.NoError(t, err)
+	assert.Equal(t, 3, skipped)
+	require.Len(t, items, 2)
+	assert.Equal(t, "A", items[1].MarketHashName)
+}
+
+func TestGetAllItems_TolerantDecoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"market_hash_name":"A","currency":"EUR","min_price":1,"quantity":1},
+			{"market_hash_name":"B","currency":"EUR","min_price":"1,50","quantity":1}]`))
+	}))
+	defer ts.Close()
+	before := testutil.ToFloat64(metrics.SkinportDecodeErrors.WithLabelValues("570"))
+
+	_, err := NewClient(Config{APIURL: ts.URL}).GetAllItems(context.Background(), "570", "EUR")
+	assert.Error(t, err, "a malformed item fails the standard mode")
+
+	items, err := NewClient(Config{APIURL: ts.URL, DecodeMode: DecodeTolerant}).GetAllItems(context.Background(), "570", "EUR")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "A", items[0].MarketHashName)
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.SkinportDecodeErrors.WithLabelValues("570")), "once per dataset")
+}
+
+func TestParseDecodeMode(t *testing.T) {
+	mode, err := ParseDecodeMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, DecodeStandard, mode)
+	mode, err = ParseDecodeMode("tolerant")
+	assert.NoError(t, err)
+	assert.Equal(t, DecodeTolerant, mode)
+	_, err = ParseDecodeMode("lenient")
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package skinport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/tenant"
+)
+
+// Credentials authenticate a client against the Skinport API
+type Credentials struct {
+	ClientID string
+	APIKey   string
+}
+
+// CredentialStore looks up the Skinport credentials tenants configured; ok is false for a
+// tenant using the deployment's own
+type CredentialStore interface {
+	SkinportCredentials(ctx context.Context, tenantID int) (creds Credentials, ok bool, err error)
+}
+
+// credentialsTTL is how long the factory trusts the credentials it looked up, so changes
+// made through another instance are picked up
+const credentialsTTL = time.Minute
+
+type tenantClient struct {
+	// client is nil for a tenant without credentials of its own
+	client    *Client
+	creds     Credentials
+	checkedAt time.Time
+}
+
+// Factory hands out the Skinport client of the tenant a context is scoped to. Tenants
+// with credentials of their own get a client of their own, with its own cache partitions
+// and rate limit. Other tenants and unscoped callers, such as background jobs, share the
+// client of the deployment's credentials. All clients share one HTTP transport.
+type Factory struct {
+	config Config
+	store  CredentialStore
+	// base is the HTTP client the tenants' clients add their credentials to
+	base   http.Client
+	shared *Client
+
+	mu      sync.Mutex
+	tenants map[int]*tenantClient
+}
+
+// NewFactory returns a factory whose shared client uses cfg's credentials. A nil store
+// hands out the shared client only.
+func NewFactory(cfg Config, store CredentialStore) *Factory {
+	base := baseHTTPClient(cfg)
+	return &Factory{
+		config:  cfg,
+		store:   store,
+		base:    base,
+		shared:  newClient(cfg, withCredentials(base, Credentials{ClientID: cfg.ClientID, APIKey: cfg.APIKey})),
+		tenants: map[int]*tenantClient{},
+	}
+}
+
+// Shared returns the client of the deployment's credentials
+func (f *Factory) Shared() *Client {
+	return f.shared
+}
+
+// Defaults returns the app_id and currency of requests without them, the same for every
+// client
+func (f *Factory) Defaults() (appID, currency string) {
+	return f.shared.Defaults()
+}
+
+// SetCredentials replaces the deployment's credentials, e.g. after they were rotated in a
+// secret manager. The shared client keeps its cache: the credentials are the same account's.
+func (f *Factory) SetCredentials(creds Credentials) {
+	f.shared.client.Transport.(*AuthTransport).SetCredentials(creds)
+}
+
+// Client returns the client of ctx's tenant. A client is kept, cache included, for as
+// long as its tenant's credentials do not change.
+func (f *Factory) Client(ctx context.Context) (*Client, error) {
+	id, ok := tenant.IDFrom(ctx)
+	if !ok || f.store == nil {
+		return f.shared, nil
+	}
+
+	f.mu.Lock()
+	if tc, ok := f.tenants[id]; ok && time.Since(tc.checkedAt) < credentialsTTL {
+		f.mu.Unlock()
+		return f.clientOf(tc), nil
+	}
+	f.mu.Unlock()
+
+	creds, ok, err := f.store.SkinportCredentials(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get skinport credentials: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.tenants[id]
+	tc := old
+	switch {
+	case !ok:
+		tc = &tenantClient{}
+	case tc == nil || tc.client == nil || tc.creds != creds:
+		cfg := f.config
+		cfg.ClientID, cfg.APIKey = creds.ClientID, creds.APIKey
+		client := newClient(cfg, withCredentials(f.base, creds))
+		// The clients share the transport, so they share its concurrency limit
+		client.upstream = f.shared.upstream
+		tc = &tenantClient{client: client, creds: creds}
+	}
+	tc.checkedAt = time.Now()
+	f.tenants[id] = tc
+	if old != nil && old.client != nil && old.client != tc.client {
+		old.client.releaseCache()
+	}
+	return f.clientOf(tc), nil
+}
+
+func (f *Factory) clientOf(tc *tenantClient) *Client {
+	if tc.client == nil {
+		return f.shared
+	}
+	return tc.client
+}
+
+// Forget drops the tenant's client, so its next request looks its credentials up again.
+// The client's cache is released.
+func (f *Factory) Forget(tenantID int) {
+	f.mu.Lock()
+	tc := f.tenants[tenantID]
+	delete(f.tenants, tenantID)
+	f.mu.Unlock()
+	if tc != nil && tc.client != nil {
+		tc.client.releaseCache()
+	}
+}
+
+// WarmUp warms up the shared client and the client of every tenant that has been used,
+// see Client.WarmUp
+func (f *Factory) WarmUp(ctx context.Context) error {
+	clients := []*Client{f.shared}
+	f.mu.Lock()
+	for _, tc := range f.tenants {
+		if tc.client != nil {
+			clients = append(clients, tc.client)
+		}
+	}
+	f.mu.Unlock()
+
+	var errs []error
+	for _, c := range clients {
+		if err := c.WarmUp(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
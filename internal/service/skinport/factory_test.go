@@ -0,0 +1,194 @@
+package skinport
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+	"fsanano/go-test/internal/tenant"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCredentialStore struct {
+	mu    sync.Mutex
+	creds map[int]Credentials
+}
+
+func (s *fakeCredentialStore) SkinportCredentials(ctx context.Context, tenantID int) (Credentials, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, ok := s.creds[tenantID]
+	return creds, ok, nil
+}
+
+func TestFactory(t *testing.T) {
+	// Every tenant's items are named after the client ID they were fetched with
+	var mu sync.Mutex
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		mu.Lock()
+		requests[user]++
+		mu.Unlock()
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item of " + user, Currency: "EUR", MinPrice: floatPtr(1), Quantity: 1}})
+	}))
+	defer ts.Close()
+
+	store := &fakeCredentialStore{creds: map[int]Credentials{2: {ClientID: "acme", APIKey: "acme-key"}}}
+	factory := NewFactory(Config{APIURL: ts.URL, ClientID: "shop", APIKey: "shop-key"}, store)
+
+	names := func(ctx context.Context) []string {
+		client, err := factory.Client(ctx)
+		require.NoError(t, err)
+		items, err := client.GetAllItems(ctx, "", "")
+		require.NoError(t, err)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.MarketHashName)
+		}
+		return names
+	}
+
+	t.Run("tenants without credentials share the deployment's client", func(t *testing.T) {
+		for _, ctx := range []context.Context{context.Background(), tenant.WithID(context.Background(), 1)} {
+			client, err := factory.Client(ctx)
+			require.NoError(t, err)
+			assert.Same(t, factory.Shared(), client)
+		}
+		assert.Equal(t, []string{"Item of shop"}, names(tenant.WithID(context.Background(), 1)))
+	})
+
+	t.Run("tenants with credentials get a client and cache of their own", func(t *testing.T) {
+		acme := tenant.WithID(context.Background(), 2)
+		client, err := factory.Client(acme)
+		require.NoError(t, err)
+		assert.NotSame(t, factory.Shared(), client)
+		again, err := factory.Client(acme)
+		require.NoError(t, err)
+		assert.Same(t, client, again)
+
+		assert.Equal(t, []string{"Item of acme"}, names(acme))
+		assert.Equal(t, []string{"Item of acme"}, names(acme))
+		assert.Equal(t, []string{"Item of shop"}, names(context.Background()))
+		mu.Lock()
+		assert.Equal(t, map[string]int{"shop": 2, "acme": 2}, requests, "each client fetched once and cached")
+		mu.Unlock()
+
+		client.InvalidateCache("", "")
+		assert.NotEmpty(t, factory.Shared().CacheEntries(), "invalidating a tenant's cache keeps the others")
+	})
+
+	t.Run("forget picks changed credentials up", func(t *testing.T) {
+		acme := tenant.WithID(context.Background(), 2)
+		before, err := factory.Client(acme)
+		require.NoError(t, err)
+
+		store.mu.Lock()
+		store.creds[2] = Credentials{ClientID: "acme2", APIKey: "acme-key"}
+		store.mu.Unlock()
+		factory.Forget(2)
+
+		after, err := factory.Client(acme)
+		require.NoError(t, err)
+		assert.NotSame(t, before, after)
+		assert.Equal(t, []string{"Item of acme2"}, names(acme))
+
+		store.mu.Lock()
+		delete(store.creds, 2)
+		store.mu.Unlock()
+		factory.Forget(2)
+		client, err := factory.Client(acme)
+		require.NoError(t, err)
+		assert.Same(t, factory.Shared(), client)
+	})
+}
+
+func TestFactory_ReleasesCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(1), Quantity: 1}})
+	}))
+	defer ts.Close()
+
+	store := &fakeCredentialStore{creds: map[int]Credentials{2: {ClientID: "acme", APIKey: "key"}}}
+	factory := NewFactory(Config{APIURL: ts.URL}, store)
+	ctx := tenant.WithID(context.Background(), 2)
+	gauge := metrics.SkinportCacheBytes.WithLabelValues("252490:EUR")
+	before := testutil.ToFloat64(gauge)
+
+	fetch := func() *Client {
+		t.Helper()
+		client, err := factory.Client(ctx)
+		require.NoError(t, err)
+		_, err = client.GetAllItems(ctx, "252490", "EUR")
+		require.NoError(t, err)
+		used, _ := client.CacheBytes()
+		assert.Equal(t, before+float64(used), testutil.ToFloat64(gauge))
+		return client
+	}
+
+	t.Run("forget", func(t *testing.T) {
+		client := fetch()
+		factory.Forget(2)
+		assert.Equal(t, before, testutil.ToFloat64(gauge))
+		used, _ := client.CacheBytes()
+		assert.Zero(t, used)
+
+		_, err := client.GetAllItems(ctx, "252490", "EUR")
+		require.NoError(t, err, "requests holding the dropped client still get items")
+		assert.Empty(t, client.CacheEntries(), "but no longer cache them")
+		assert.Equal(t, before, testutil.ToFloat64(gauge))
+	})
+
+	t.Run("changed credentials", func(t *testing.T) {
+		client := fetch()
+		store.mu.Lock()
+		store.creds[2] = Credentials{ClientID: "acme2", APIKey: "key"}
+		store.mu.Unlock()
+		factory.mu.Lock()
+		factory.tenants[2].checkedAt = time.Time{}
+		factory.mu.Unlock()
+
+		replaced := fetch()
+		assert.NotSame(t, client, replaced)
+		assert.Empty(t, client.CacheEntries())
+		factory.Forget(2)
+		assert.Equal(t, before, testutil.ToFloat64(gauge))
+	})
+}
+
+func TestFactory_Credentials(t *testing.T) {
+	var auth []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	store := &fakeCredentialStore{creds: map[int]Credentials{2: {ClientID: "acme", APIKey: "secret"}}}
+	factory := NewFactory(Config{APIURL: ts.URL}, store)
+	client, err := factory.Client(tenant.WithID(context.Background(), 2))
+	require.NoError(t, err)
+	_, err = client.GetAllItems(context.Background(), "", "")
+	require.NoError(t, err)
+
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("acme:secret"))
+	assert.Equal(t, []string{expected, expected}, auth)
+
+	t.Run("rotated deployment credentials", func(t *testing.T) {
+		auth = nil
+		factory.SetCredentials(Credentials{ClientID: "rotated", APIKey: "key"})
+		_, err := factory.Shared().GetAllItems(context.Background(), "", "")
+		require.NoError(t, err)
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("rotated:key"))
+		assert.Equal(t, []string{expected, expected}, auth)
+	})
+}
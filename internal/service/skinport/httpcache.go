@@ -0,0 +1,64 @@
+package skinport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upstream lifetimes are clamped: no-cache or max-age=0 would otherwise send every
+// request to Skinport and exhaust its rate limit, and a far Expires would pin stale prices
+const (
+	minCacheTTL = 30 * time.Second
+	maxCacheTTL = time.Hour
+)
+
+// cacheLifetime returns how long a response stays fresh, per its Cache-Control max-age
+// (minus Age) or else its Expires header, clamped to [minCacheTTL, maxCacheTTL].
+// Responses with neither get cacheTTL.
+func cacheLifetime(h http.Header, now time.Time) time.Duration {
+	ttl, ok := upstreamLifetime(h, now)
+	if !ok {
+		return cacheTTL
+	}
+	return min(max(ttl, minCacheTTL), maxCacheTTL)
+}
+
+func upstreamLifetime(h http.Header, now time.Time) (time.Duration, bool) {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	if noStore || noCache {
+		return 0, true
+	}
+	if v, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			ttl := time.Duration(seconds) * time.Second
+			if age, err := strconv.Atoi(h.Get("Age")); err == nil && age > 0 {
+				ttl -= time.Duration(age) * time.Second
+			}
+			return ttl, true
+		}
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// An invalid Expires means already expired
+			return 0, true
+		}
+		// Relative to the server's clock, so clock skew does not matter
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		return expires.Sub(date), true
+	}
+	return 0, false
+}
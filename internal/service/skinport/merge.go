@@ -0,0 +1,183 @@
+package skinport
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+)
+
+// concurrentMergeMin is the number of raw items from which merging is split across
+// workers; below it, starting them costs more than they save
+const concurrentMergeMin = 50_000
+
+// maxMergeWorkers bounds Config.MergeWorkers, shard numbers are stored in a byte
+const maxMergeWorkers = 256
+
+// itemIndex maps keys to the positions of items. An index built by a concurrent merge is
+// split into shards by the hash of the key, each covering a contiguous run of the items.
+type itemIndex struct {
+	seed   maphash.Seed
+	shards []map[string]int
+	// offsets are the positions of the shards' first items, shards store the positions
+	// relative to them
+	offsets []int
+}
+
+// newItemIndex is an index of a single shard
+func newItemIndex(m map[string]int) *itemIndex {
+	return &itemIndex{shards: []map[string]int{m}, offsets: []int{0}}
+}
+
+// get returns the position of the item indexed under key
+func (x *itemIndex) get(key string) (int, bool) {
+	shard := 0
+	if len(x.shards) > 1 {
+		shard = shardOf(x.seed, key, len(x.shards))
+	}
+	i, ok := x.shards[shard][key]
+	return i + x.offsets[shard], ok
+}
+
+func shardOf(seed maphash.Seed, key string, shards int) int {
+	return int(maphash.String(seed, key) % uint64(shards))
+}
+
+// mergeWorkers is the number of workers merging total raw items, 1 merges them in place
+func (c *Client) mergeWorkers(total int) int {
+	workers := c.config.MergeWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if total < concurrentMergeMin {
+		return 1
+	}
+	return min(workers, maxMergeWorkers)
+}
+
+// mergeItems merges the datasets into one item per market_hash_name, in order of first
+// appearance, and returns the index of the result by name. Both are allocated once at
+// their maximum size.
+func mergeItems(tradableItems, nonTradableItems []RawItem) ([]ResponseItem, *itemIndex) {
+	total := len(tradableItems) + len(nonTradableItems)
+	index := make(map[string]int, total)
+	result := make([]ResponseItem, 0, total)
+
+	// Process tradable items
+	for _, item := range tradableItems {
+		index[item.MarketHashName] = len(result)
+		result = append(result, newResponseItem(item, true))
+	}
+
+	// Process non-tradable items
+	for _, item := range nonTradableItems {
+		if i, exists := index[item.MarketHashName]; exists {
+			result[i].mergeNonTradable(item)
+		} else {
+			index[item.MarketHashName] = len(result)
+			result = append(result, newResponseItem(item, false))
+		}
+	}
+
+	return result, newItemIndex(index)
+}
+
+// mergeItemsConcurrent is mergeItems split across workers, each merging the items whose
+// names hash to its shard. The result holds the shards one after the other, each in order
+// of first appearance.
+//
+// Every name is hashed once, then each worker indexes its shard in a map of its own and
+// records where every raw item goes. Once the shards' sizes are known, the workers write
+// their items straight into their run of the result, without locks or copies.
+func mergeItemsConcurrent(tradableItems, nonTradableItems []RawItem, workers int) ([]ResponseItem, *itemIndex) {
+	index := &itemIndex{seed: maphash.MakeSeed(), shards: make([]map[string]int, workers), offsets: make([]int, workers)}
+
+	tradableShards := make([]uint8, len(tradableItems))
+	nonTradableShards := make([]uint8, len(nonTradableItems))
+	inParallel(workers, func(w int) {
+		for _, dataset := range []struct {
+			items  []RawItem
+			shards []uint8
+		}{{tradableItems, tradableShards}, {nonTradableItems, nonTradableShards}} {
+			lo, hi := chunk(len(dataset.items), workers, w)
+			for i := lo; i < hi; i++ {
+				dataset.shards[i] = uint8(shardOf(index.seed, dataset.items[i].MarketHashName, workers))
+			}
+		}
+	})
+
+	// positions are relative to the shard; a non-tradable item starting a new one is
+	// stored as -(position+1)
+	tradablePositions := make([]int32, len(tradableItems))
+	nonTradablePositions := make([]int32, len(nonTradableItems))
+	sizes := make([]int, workers)
+	perShard := (len(tradableItems) + len(nonTradableItems)) / workers
+	inParallel(workers, func(w int) {
+		shard := uint8(w)
+		m := make(map[string]int, perShard+perShard/8)
+		n := 0
+		for i, item := range tradableItems {
+			if tradableShards[i] == shard {
+				m[item.MarketHashName] = n
+				tradablePositions[i] = int32(n)
+				n++
+			}
+		}
+		for i, item := range nonTradableItems {
+			if nonTradableShards[i] != shard {
+				continue
+			}
+			if p, exists := m[item.MarketHashName]; exists {
+				nonTradablePositions[i] = int32(p)
+			} else {
+				m[item.MarketHashName] = n
+				nonTradablePositions[i] = -int32(n) - 1
+				n++
+			}
+		}
+		index.shards[w], sizes[w] = m, n
+	})
+
+	total := 0
+	for w, size := range sizes {
+		index.offsets[w] = total
+		total += size
+	}
+	result := make([]ResponseItem, total)
+	inParallel(workers, func(w int) {
+		shard, run := uint8(w), result[index.offsets[w]:]
+		for i, item := range tradableItems {
+			if tradableShards[i] == shard {
+				run[tradablePositions[i]] = newResponseItem(item, true)
+			}
+		}
+		for i, item := range nonTradableItems {
+			if nonTradableShards[i] != shard {
+				continue
+			}
+			if p := nonTradablePositions[i]; p >= 0 {
+				run[p].mergeNonTradable(item)
+			} else {
+				run[-p-1] = newResponseItem(item, false)
+			}
+		}
+	})
+	return result, index
+}
+
+// inParallel runs fn for every worker and waits for all of them
+func inParallel(workers int, fn func(w int)) {
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := range workers {
+		go func() {
+			defer wg.Done()
+			fn(w)
+		}()
+	}
+	wg.Wait()
+}
+
+// chunk returns the bounds of the w-th of workers nearly equal parts of n elements
+func chunk(n, workers, w int) (int, int) {
+	return n * w / workers, n * (w + 1) / workers
+}
@@ -0,0 +1,97 @@
+package skinport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawDatasets returns count tradable items and count non-tradable ones, every other one
+// listed in both
+func rawDatasets(count int) ([]RawItem, []RawItem) {
+	tradable := make([]RawItem, count)
+	nonTradable := make([]RawItem, count)
+	for i := range count {
+		tradable[i] = RawItem{MarketHashName: fmt.Sprintf("Item-%d", i), Currency: "EUR", Slug: fmt.Sprintf("item-%d", i),
+			MinPrice: floatPtr(float64(i) + 0.5), Quantity: 1}
+		nonTradable[i] = RawItem{MarketHashName: fmt.Sprintf("Item-%d", i+count/2*(i%2)), Currency: "EUR",
+			MinPrice: floatPtr(float64(i)), Quantity: 2}
+	}
+	return tradable, nonTradable
+}
+
+func TestMergeItemsConcurrent(t *testing.T) {
+	tradable, nonTradable := rawDatasets(1000)
+	want, wantIndex := mergeItems(tradable, nonTradable)
+
+	for _, workers := range []int{2, 3, 8} {
+		got, index := mergeItemsConcurrent(tradable, nonTradable, workers)
+		require.Len(t, got, len(want), "workers=%d", workers)
+		for _, item := range want {
+			i, ok := index.get(item.MarketHashName)
+			require.True(t, ok, item.MarketHashName)
+			assert.Equal(t, item, got[i], "workers=%d", workers)
+		}
+		_, ok := index.get("missing")
+		assert.False(t, ok)
+
+		// Shards keep the order of first appearance
+		for w := range workers {
+			end := len(got)
+			if w+1 < workers {
+				end = index.offsets[w+1]
+			}
+			run := got[index.offsets[w]:end]
+			assert.True(t, slices.IsSortedFunc(run, func(a, b ResponseItem) int {
+				ia, _ := wantIndex.get(a.MarketHashName)
+				ib, _ := wantIndex.get(b.MarketHashName)
+				return ia - ib
+			}), "shard %d of %d", w, workers)
+		}
+	}
+}
+
+func TestMergeWorkers(t *testing.T) {
+	c := NewClient(Config{MergeWorkers: 4})
+	assert.Equal(t, 1, c.mergeWorkers(concurrentMergeMin-1), "small datasets are merged in place")
+	assert.Equal(t, 4, c.mergeWorkers(concurrentMergeMin))
+	c = NewClient(Config{MergeWorkers: 1000})
+	assert.Equal(t, maxMergeWorkers, c.mergeWorkers(concurrentMergeMin))
+}
+
+func TestFetchMerged_Concurrent(t *testing.T) {
+	tradable, nonTradable := rawDatasets(concurrentMergeMin / 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tradable") == "true" {
+			json.NewEncoder(w).Encode(tradable)
+		} else {
+			json.NewEncoder(w).Encode(nonTradable)
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL, MergeWorkers: 4})
+	ctx := context.Background()
+
+	items, err := client.LookupItems(ctx, ItemsParams{AppID: "730", Currency: "EUR"}, []string{"Item-2", "Item-1"})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, 3, items[0].Quantity)
+	assert.Equal(t, 2.0, *items[0].MinPriceNonTradable)
+	assert.Nil(t, items[1].MinPriceNonTradable)
+
+	bySlug, err := client.ItemBySlug(ctx, ItemsParams{AppID: "730", Currency: "EUR"}, "item-2")
+	require.NoError(t, err)
+	assert.Equal(t, "Item-2", bySlug.Item.MarketHashName)
+
+	all, err := client.GetAllItems(ctx, "730", "EUR")
+	require.NoError(t, err)
+	want, _ := mergeItems(tradable, nonTradable)
+	assert.Len(t, all, len(want))
+}
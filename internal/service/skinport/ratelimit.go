@@ -0,0 +1,120 @@
+package skinport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/metrics"
+)
+
+// ErrRateLimited is returned when a request would have to wait for the client's rate limit
+// past the caller's deadline
+var ErrRateLimited = errors.New("skinport rate limit exhausted")
+
+// rateLimiter is a token bucket allowing requests per window, refilled evenly. Tokens go
+// negative while requests are queued, each waiting for its own token.
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	// interval refills one token
+	interval time.Duration
+	last     time.Time
+}
+
+// newRateLimiter returns nil, no limit, unless both requests and window are positive
+func newRateLimiter(requests int, window time.Duration) *rateLimiter {
+	if requests <= 0 || window <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		tokens:   float64(requests),
+		burst:    float64(requests),
+		interval: window / time.Duration(requests),
+		last:     time.Now(),
+	}
+}
+
+// wait takes a token, waiting until it is refilled. It fails with ErrRateLimited right away
+// when the token would come after ctx's deadline.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens * float64(l.interval))
+	}
+	if deadline, ok := ctx.Deadline(); ok && delay > 0 && now.Add(delay).After(deadline) {
+		l.tokens++
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// The token was not used, later requests may have it
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// concurrencyLimiter caps the requests in flight to Skinport, e.g. when many app_id/currency
+// entries expire together. A nil *concurrencyLimiter allows any number.
+type concurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// newConcurrencyLimiter returns nil, no limit, unless n is positive
+func newConcurrencyLimiter(n int) *concurrencyLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire takes a slot, waiting until one is released or ctx is done
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	metrics.SkinportRequestsQueued.Inc()
+	defer metrics.SkinportRequestsQueued.Dec()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the slot of an acquire that succeeded
+func (l *concurrencyLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
@@ -0,0 +1,200 @@
+package skinport
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// snapshotIDs numbers the fetched datasets of every client
+var snapshotIDs atomic.Int64
+
+// snapshot is a previous dataset of a cache entry, kept for Diff
+type snapshot struct {
+	id        int64
+	fetchedAt time.Time
+	items     []ResponseItem
+	byName    *itemIndex
+	size      int64
+}
+
+func (r cachedResponse) snapshot() snapshot {
+	return snapshot{id: r.id, fetchedAt: r.fetchedAt, items: r.items, byName: r.byName, size: r.size}
+}
+
+func (r cachedResponse) historySize() int64 {
+	var size int64
+	for _, s := range r.history {
+		size += s.size
+	}
+	return size
+}
+
+// keepHistory returns entry with prev's history, and prev itself when entry is a new
+// snapshot rather than prev revalidated, trimmed to the configured length
+func (c *Client) keepHistory(entry cachedResponse, prev *cachedResponse) cachedResponse {
+	keep := c.config.SnapshotHistory
+	if prev == nil || entry.id == prev.id || keep < 1 {
+		return entry
+	}
+	history := append(slices.Clone(prev.history), prev.snapshot())
+	entry.history = history[max(0, len(history)-keep):]
+	return entry
+}
+
+// SnapshotInfo describes a snapshot Diff can compare
+type SnapshotInfo struct {
+	ID        int64     `json:"id"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Items     int       `json:"items"`
+}
+
+// ErrSnapshotNotFound is matched (via errors.Is) by SnapshotNotFoundError
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// SnapshotNotFoundError is returned by Diff for a snapshot no longer kept, or when there
+// is no previous snapshot to compare with (ID 0)
+type SnapshotNotFoundError struct {
+	ID        int64   `json:"id,omitempty"`
+	Available []int64 `json:"available"`
+}
+
+func (e *SnapshotNotFoundError) Error() string {
+	if e.ID == 0 {
+		return "no previous snapshot to compare with"
+	}
+	return fmt.Sprintf("snapshot %d not found", e.ID)
+}
+
+func (e *SnapshotNotFoundError) Is(target error) bool {
+	return target == ErrSnapshotNotFound
+}
+
+// Snapshots lists the snapshots kept for the items selected by p, oldest first; the
+// last one is the current
+func (c *Client) Snapshots(ctx context.Context, p ItemsParams) ([]SnapshotInfo, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := entry.snapshots()
+	infos := make([]SnapshotInfo, len(snapshots))
+	for i, s := range snapshots {
+		infos[i] = SnapshotInfo{ID: s.id, FetchedAt: s.fetchedAt, Items: len(s.items)}
+	}
+	return infos, nil
+}
+
+func (r cachedResponse) snapshots() []snapshot {
+	return append(slices.Clone(r.history), r.snapshot())
+}
+
+// ItemChange is an item listed in both snapshots of a diff with another price or quantity
+type ItemChange struct {
+	MarketHashName string       `json:"market_hash_name"`
+	Before         ResponseItem `json:"before"`
+	After          ResponseItem `json:"after"`
+	// PriceDelta is the change of the lowest price, nil when either side has none
+	PriceDelta    *float64 `json:"price_delta"`
+	QuantityDelta int      `json:"quantity_delta"`
+}
+
+// SnapshotDiff lists what changed between two snapshots, by market_hash_name
+type SnapshotDiff struct {
+	From    SnapshotInfo   `json:"from"`
+	To      SnapshotInfo   `json:"to"`
+	Added   []ResponseItem `json:"added"`
+	Removed []ResponseItem `json:"removed"`
+	Changed []ItemChange   `json:"changed"`
+}
+
+// Diff compares the snapshots from and to of the items selected by p. A to of 0 is the
+// current snapshot, a from of 0 the one before to. Snapshots no longer kept fail with a
+// SnapshotNotFoundError.
+func (c *Client) Diff(ctx context.Context, p ItemsParams, from, to int64) (*SnapshotDiff, error) {
+	entry, err := c.cachedEntry(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := entry.snapshots()
+	available := make([]int64, len(snapshots))
+	for i, s := range snapshots {
+		available[i] = s.id
+	}
+
+	toIndex := len(snapshots) - 1
+	if to != 0 {
+		if toIndex = slices.Index(available, to); toIndex < 0 {
+			return nil, &SnapshotNotFoundError{ID: to, Available: available}
+		}
+	}
+	fromIndex := toIndex - 1
+	if from != 0 {
+		fromIndex = slices.Index(available, from)
+	}
+	if fromIndex < 0 {
+		return nil, &SnapshotNotFoundError{ID: from, Available: available}
+	}
+	return diffSnapshots(snapshots[fromIndex], snapshots[toIndex]), nil
+}
+
+// diffSnapshots compares two snapshots, each list sorted by market_hash_name. The items
+// are copies.
+func diffSnapshots(from, to snapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		From:    SnapshotInfo{ID: from.id, FetchedAt: from.fetchedAt, Items: len(from.items)},
+		To:      SnapshotInfo{ID: to.id, FetchedAt: to.fetchedAt, Items: len(to.items)},
+		Added:   []ResponseItem{},
+		Removed: []ResponseItem{},
+		Changed: []ItemChange{},
+	}
+	for _, after := range to.items {
+		i, ok := from.byName.get(after.MarketHashName)
+		if !ok {
+			diff.Added = append(diff.Added, after)
+			continue
+		}
+		before := from.items[i]
+		if samePrice(before.MinPriceTradable, after.MinPriceTradable) &&
+			samePrice(before.MinPriceNonTradable, after.MinPriceNonTradable) && before.Quantity == after.Quantity {
+			continue
+		}
+		change := ItemChange{
+			MarketHashName: after.MarketHashName,
+			Before:         cloneItems([]ResponseItem{before})[0],
+			After:          cloneItems([]ResponseItem{after})[0],
+			QuantityDelta:  after.Quantity - before.Quantity,
+		}
+		beforePrice, okBefore := lowestPrice(before)
+		afterPrice, okAfter := lowestPrice(after)
+		if okBefore && okAfter {
+			delta := math.Round((afterPrice-beforePrice)*100) / 100
+			change.PriceDelta = &delta
+		}
+		diff.Changed = append(diff.Changed, change)
+	}
+	for _, before := range from.items {
+		if _, ok := to.byName.get(before.MarketHashName); !ok {
+			diff.Removed = append(diff.Removed, before)
+		}
+	}
+
+	diff.Added, diff.Removed = cloneItems(diff.Added), cloneItems(diff.Removed)
+	byName := func(a, b ResponseItem) int { return cmp.Compare(a.MarketHashName, b.MarketHashName) }
+	slices.SortFunc(diff.Added, byName)
+	slices.SortFunc(diff.Removed, byName)
+	slices.SortFunc(diff.Changed, func(a, b ItemChange) int { return cmp.Compare(a.MarketHashName, b.MarketHashName) })
+	return diff
+}
+
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,70 @@
+package skinport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	items := []RawItem{
+		{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(10), Quantity: 2},
+		{MarketHashName: "Item B", Currency: "EUR", MinPrice: floatPtr(5), Quantity: 1},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(items)
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL, SnapshotHistory: 2})
+	ctx := context.Background()
+	p := ItemsParams{View: ViewTradable}
+
+	_, err := client.Diff(ctx, p, 0, 0)
+	assert.True(t, errors.Is(err, ErrSnapshotNotFound), "nothing to compare the first snapshot with")
+
+	refresh := func() {
+		t.Helper()
+		_, err := client.refresh(ctx, "", "", ViewTradable)
+		assert.NoError(t, err)
+	}
+	items = []RawItem{
+		{MarketHashName: "Item A", Currency: "EUR", MinPrice: floatPtr(8.5), Quantity: 3},
+		{MarketHashName: "Item C", Currency: "EUR", MinPrice: floatPtr(1), Quantity: 1},
+	}
+	refresh()
+
+	diff, err := client.Diff(ctx, p, 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, diff.Added, 1) && assert.Len(t, diff.Removed, 1) && assert.Len(t, diff.Changed, 1) {
+		assert.Equal(t, "Item C", diff.Added[0].MarketHashName)
+		assert.Equal(t, "Item B", diff.Removed[0].MarketHashName)
+		change := diff.Changed[0]
+		assert.Equal(t, "Item A", change.MarketHashName)
+		assert.Equal(t, -1.5, *change.PriceDelta)
+		assert.Equal(t, 1, change.QuantityDelta)
+	}
+	first := diff.From.ID
+
+	refresh()
+	refresh()
+	snapshots, err := client.Snapshots(ctx, p)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 3, "two previous snapshots and the current")
+	assert.NotEqual(t, first, snapshots[0].ID, "the oldest snapshot was dropped")
+	_, err = client.Diff(ctx, p, first, 0)
+	var notFound *SnapshotNotFoundError
+	if assert.ErrorAs(t, err, &notFound) {
+		assert.Equal(t, first, notFound.ID)
+	}
+
+	diff, err = client.Diff(ctx, p, snapshots[0].ID, snapshots[2].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Changed)
+	assert.Positive(t, client.CacheEntries()[0].Snapshots)
+}
@@ -0,0 +1,129 @@
+package skinport
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// topMovers is the number of items MarketStats ranks by price change
+const topMovers = 10
+
+// MarketStats summarizes a cached dataset. It is computed once per fetch and shared by
+// every reader, so it must not be modified.
+type MarketStats struct {
+	Currency string `json:"currency"`
+	// Listings counts the items, Quantity the offers across them
+	Listings int `json:"listings"`
+	Quantity int `json:"quantity"`
+	// Priced counts the items with a min price; MeanPrice and MedianPrice are over their
+	// lowest price, tradable or not, and nil without any
+	Priced      int      `json:"priced"`
+	MeanPrice   *float64 `json:"mean_price"`
+	MedianPrice *float64 `json:"median_price"`
+	// TopMovers are the items whose lowest price changed the most since the dataset
+	// fetched at ComparedTo, by relative change. It is empty after the first fetch.
+	TopMovers  []PriceMove `json:"top_movers"`
+	ComputedAt time.Time   `json:"computed_at"`
+	ComparedTo *time.Time  `json:"compared_to"`
+
+	// prices are the lowest prices of the priced items, sorted, for PricedUnder
+	prices []float64
+}
+
+// PriceMove is an item's price change between two fetches
+type PriceMove struct {
+	MarketHashName string  `json:"market_hash_name"`
+	Slug           string  `json:"slug"`
+	PreviousPrice  float64 `json:"previous_price"`
+	Price          float64 `json:"price"`
+	ChangePercent  float64 `json:"change_percent"`
+}
+
+// PricedUnder returns the number of items whose lowest price is below threshold
+func (s *MarketStats) PricedUnder(threshold float64) int {
+	n, _ := slices.BinarySearch(s.prices, threshold)
+	return n
+}
+
+// lowestPrice returns the lower of the item's tradable and non-tradable min prices
+func lowestPrice(item ResponseItem) (float64, bool) {
+	switch {
+	case item.MinPriceTradable == nil && item.MinPriceNonTradable == nil:
+		return 0, false
+	case item.MinPriceTradable == nil:
+		return *item.MinPriceNonTradable, true
+	case item.MinPriceNonTradable == nil:
+		return *item.MinPriceTradable, true
+	default:
+		return min(*item.MinPriceTradable, *item.MinPriceNonTradable), true
+	}
+}
+
+// newMarketStats computes the stats of items, with the price movers since prev when set
+func newMarketStats(items []ResponseItem, prev *cachedResponse) *MarketStats {
+	stats := &MarketStats{Listings: len(items), TopMovers: []PriceMove{}, ComputedAt: time.Now()}
+	if len(items) > 0 {
+		stats.Currency = items[0].Currency
+	}
+	if prev != nil {
+		comparedTo := prev.fetchedAt
+		stats.ComparedTo = &comparedTo
+	}
+
+	prices := make([]float64, 0, len(items))
+	var sum float64
+	var moves []PriceMove
+	for _, item := range items {
+		stats.Quantity += item.Quantity
+		price, ok := lowestPrice(item)
+		if !ok {
+			continue
+		}
+		prices = append(prices, price)
+		sum += price
+
+		if prev == nil {
+			continue
+		}
+		i, ok := prev.byName.get(item.MarketHashName)
+		if !ok {
+			continue
+		}
+		previous, ok := lowestPrice(prev.items[i])
+		if !ok || previous == 0 || previous == price {
+			continue
+		}
+		moves = append(moves, PriceMove{
+			MarketHashName: item.MarketHashName,
+			Slug:           item.Slug,
+			PreviousPrice:  previous,
+			Price:          price,
+			ChangePercent:  math.Round((price-previous)/previous*10000) / 100,
+		})
+	}
+
+	slices.Sort(prices)
+	stats.prices = prices
+	stats.Priced = len(prices)
+	if n := len(prices); n > 0 {
+		mean := math.Round(sum/float64(n)*100) / 100
+		median := prices[n/2]
+		if n%2 == 0 {
+			median = math.Round((prices[n/2-1]+prices[n/2])/2*100) / 100
+		}
+		stats.MeanPrice, stats.MedianPrice = &mean, &median
+	}
+
+	// Largest changes first, either way; ties by name for a stable ranking
+	slices.SortFunc(moves, func(a, b PriceMove) int {
+		return cmp.Or(cmp.Compare(math.Abs(b.ChangePercent), math.Abs(a.ChangePercent)),
+			cmp.Compare(a.MarketHashName, b.MarketHashName))
+	})
+	if len(moves) > topMovers {
+		moves = moves[:topMovers]
+	}
+	stats.TopMovers = append(stats.TopMovers, moves...)
+	return stats
+}
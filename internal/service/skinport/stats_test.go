@@ -0,0 +1,52 @@
+package skinport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	prices := map[string]*float64{"Item A": floatPtr(10), "Item B": floatPtr(2), "Item C": floatPtr(5)}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]RawItem{
+			{MarketHashName: "Item A", Currency: "EUR", MinPrice: prices["Item A"], Quantity: 2},
+			{MarketHashName: "Item B", Currency: "EUR", MinPrice: prices["Item B"], Quantity: 1},
+			{MarketHashName: "Item C", Currency: "EUR", MinPrice: prices["Item C"], Quantity: 1},
+			{MarketHashName: "Item D", Currency: "EUR", Quantity: 0},
+		})
+	}))
+	defer ts.Close()
+	client := NewClient(Config{APIURL: ts.URL})
+	// Both datasets list the same prices
+	p := ItemsParams{}
+
+	stats, err := client.Stats(context.Background(), p)
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", stats.Currency)
+	assert.Equal(t, 4, stats.Listings)
+	assert.Equal(t, 8, stats.Quantity)
+	assert.Equal(t, 3, stats.Priced)
+	assert.Equal(t, 5.67, *stats.MeanPrice)
+	assert.Equal(t, 5.0, *stats.MedianPrice)
+	assert.Equal(t, 1, stats.PricedUnder(5))
+	assert.Equal(t, 2, stats.PricedUnder(5.01))
+	assert.Empty(t, stats.TopMovers, "nothing to compare the first fetch with")
+	assert.Nil(t, stats.ComparedTo)
+
+	prices["Item A"], prices["Item B"] = floatPtr(11), floatPtr(1)
+	_, err = client.Refresh(context.Background(), "", "")
+	assert.NoError(t, err)
+	stats, err = client.Stats(context.Background(), p)
+	assert.NoError(t, err)
+	assert.NotNil(t, stats.ComparedTo)
+	assert.Equal(t, []PriceMove{
+		{MarketHashName: "Item B", PreviousPrice: 2, Price: 1, ChangePercent: -50},
+		{MarketHashName: "Item A", PreviousPrice: 10, Price: 11, ChangePercent: 10},
+	}, stats.TopMovers)
+	assert.Equal(t, 5.0, *stats.MedianPrice)
+}
@@ -0,0 +1,91 @@
+package skinport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultRequestTimeout bounds a single upstream request when Config.RequestTimeout is 0
+const defaultRequestTimeout = 10 * time.Second
+
+// TransportConfig tunes the HTTP transport of the client. Zero values keep the defaults
+// of http.DefaultTransport.
+type TransportConfig struct {
+	// ProxyURL routes requests through a proxy; nil uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	ProxyURL  *url.URL
+	TLSConfig *tls.Config
+	// DialContext replaces the dialer, DialTimeout is then ignored
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	DialTimeout time.Duration
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections to Skinport, idle ones included (0 is unlimited)
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+}
+
+func (tc TransportConfig) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if tc.ProxyURL != nil {
+		t.Proxy = http.ProxyURL(tc.ProxyURL)
+	}
+	if tc.TLSConfig != nil {
+		t.TLSClientConfig = tc.TLSConfig.Clone()
+	}
+	switch {
+	case tc.DialContext != nil:
+		t.DialContext = tc.DialContext
+	case tc.DialTimeout > 0:
+		t.DialContext = (&net.Dialer{Timeout: tc.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if tc.MaxIdleConns > 0 {
+		t.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	if tc.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = tc.MaxConnsPerHost
+	}
+	if tc.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = tc.IdleConnTimeout
+	}
+	return t
+}
+
+// newHTTPClient returns cfg.HTTPClient, or a client built from cfg.Transport, with the
+// Skinport credentials added to every request. The caller's client is copied, not modified.
+func newHTTPClient(cfg Config) *http.Client {
+	return withCredentials(baseHTTPClient(cfg), Credentials{ClientID: cfg.ClientID, APIKey: cfg.APIKey})
+}
+
+// baseHTTPClient returns a copy of cfg.HTTPClient, or a client built from cfg.Transport
+func baseHTTPClient(cfg Config) http.Client {
+	var client http.Client
+	if cfg.HTTPClient != nil {
+		client = *cfg.HTTPClient
+	} else {
+		client.Transport = cfg.Transport.newTransport()
+		client.Timeout = cfg.RequestTimeout
+		if client.Timeout == 0 {
+			client.Timeout = defaultRequestTimeout
+		}
+	}
+	return client
+}
+
+// withCredentials returns a copy of client adding creds to every request. Copies of the
+// same client share its transport and connections.
+func withCredentials(client http.Client, creds Credentials) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &AuthTransport{ClientID: creds.ClientID, APIKey: creds.APIKey, Base: base}
+	return &client
+}
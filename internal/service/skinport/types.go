@@ -1,15 +1,27 @@
 package skinport
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"time"
 )
 
 type RawItem struct {
 	MarketHashName string   `json:"market_hash_name"`
 	Currency       string   `json:"currency"`
 	Slug           string   `json:"slug"`
+	SuggestedPrice *float64 `json:"suggested_price"`
+	ItemPage       string   `json:"item_page"`
+	MarketPage     string   `json:"market_page"`
 	MinPrice       *float64 `json:"min_price"`
+	MaxPrice       *float64 `json:"max_price"`
+	MeanPrice      *float64 `json:"mean_price"`
+	MedianPrice    *float64 `json:"median_price"`
 	Quantity       int      `json:"quantity"`
+	// CreatedAt and UpdatedAt are Unix timestamps in seconds
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
 }
 
 type ResponseItem struct {
@@ -19,6 +31,118 @@ type ResponseItem struct {
 	MinPriceTradable    *float64 `json:"min_price_tradable"`
 	MinPriceNonTradable *float64 `json:"min_price_non_tradable"`
 	Quantity            int      `json:"quantity"`
+	// SuggestedPrice and the pages describe the item, not a listing; a merged item takes
+	// them from its tradable listings when it has any
+	SuggestedPrice *float64 `json:"suggested_price,omitempty"`
+	ItemPage       string   `json:"item_page,omitempty"`
+	MarketPage     string   `json:"market_page,omitempty"`
+	// The other statistics of the listings, by tradability like the minimum prices
+	MaxPriceTradable       *float64 `json:"max_price_tradable,omitempty"`
+	MaxPriceNonTradable    *float64 `json:"max_price_non_tradable,omitempty"`
+	MeanPriceTradable      *float64 `json:"mean_price_tradable,omitempty"`
+	MeanPriceNonTradable   *float64 `json:"mean_price_non_tradable,omitempty"`
+	MedianPriceTradable    *float64 `json:"median_price_tradable,omitempty"`
+	MedianPriceNonTradable *float64 `json:"median_price_non_tradable,omitempty"`
+	// CreatedAt is when the item was first listed and UpdatedAt when its listings last
+	// changed, on Skinport; a merged item takes the earliest and the latest of both sides
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// Spread is MinPriceTradable minus MinPriceNonTradable, the premium paid for an item
+	// that can be traded right away; SpreadPercent is that premium as a percentage of
+	// MinPriceTradable. Both are nil unless the item has both prices, see setSpread.
+	Spread        *float64 `json:"spread,omitempty"`
+	SpreadPercent *float64 `json:"spread_percent,omitempty"`
+}
+
+// newResponseItem is the item of a tradable or non-tradable raw item; the other side's
+// prices are nil
+func newResponseItem(raw RawItem, tradable bool) ResponseItem {
+	item := ResponseItem{
+		MarketHashName: raw.MarketHashName,
+		Currency:       raw.Currency,
+		Slug:           raw.Slug,
+		SuggestedPrice: raw.SuggestedPrice,
+		ItemPage:       raw.ItemPage,
+		MarketPage:     raw.MarketPage,
+		Quantity:       raw.Quantity,
+		CreatedAt:      unixTime(raw.CreatedAt),
+		UpdatedAt:      unixTime(raw.UpdatedAt),
+	}
+	item.setPrices(raw, tradable)
+	return item
+}
+
+// unixTime is the UTC time of a Unix timestamp, the zero time for 0 (not sent)
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+// mergeTimes widens the item's CreatedAt and UpdatedAt to those of raw, from the other
+// dataset
+func (item *ResponseItem) mergeTimes(raw RawItem) {
+	if created := unixTime(raw.CreatedAt); !created.IsZero() && (item.CreatedAt.IsZero() || created.Before(item.CreatedAt)) {
+		item.CreatedAt = created
+	}
+	if updated := unixTime(raw.UpdatedAt); updated.After(item.UpdatedAt) {
+		item.UpdatedAt = updated
+	}
+}
+
+// setPrices sets the tradable or non-tradable prices of item from raw
+func (item *ResponseItem) setPrices(raw RawItem, tradable bool) {
+	if tradable {
+		item.MinPriceTradable, item.MaxPriceTradable = raw.MinPrice, raw.MaxPrice
+		item.MeanPriceTradable, item.MedianPriceTradable = raw.MeanPrice, raw.MedianPrice
+	} else {
+		item.MinPriceNonTradable, item.MaxPriceNonTradable = raw.MinPrice, raw.MaxPrice
+		item.MeanPriceNonTradable, item.MedianPriceNonTradable = raw.MeanPrice, raw.MedianPrice
+	}
+}
+
+// mergeNonTradable merges the non-tradable listings of the item into it
+func (item *ResponseItem) mergeNonTradable(raw RawItem) {
+	item.setPrices(raw, false)
+	item.setSpread()
+	item.mergeTimes(raw)
+	if item.SuggestedPrice == nil {
+		item.SuggestedPrice = raw.SuggestedPrice
+	}
+	if item.ItemPage == "" {
+		item.ItemPage, item.MarketPage = raw.ItemPage, raw.MarketPage
+	}
+	// Update quantity if needed, strictly speaking we might want to sum them
+	item.Quantity += raw.Quantity
+}
+
+// prices returns the item's price fields, for code handling them all alike. Spread and
+// SpreadPercent are derived, they are not prices.
+func (item *ResponseItem) prices() [9]**float64 {
+	return [...]**float64{
+		&item.SuggestedPrice,
+		&item.MinPriceTradable, &item.MinPriceNonTradable,
+		&item.MaxPriceTradable, &item.MaxPriceNonTradable,
+		&item.MeanPriceTradable, &item.MeanPriceNonTradable,
+		&item.MedianPriceTradable, &item.MedianPriceNonTradable,
+	}
+}
+
+// setSpread derives Spread and SpreadPercent from the item's prices, rounded to cents
+// and hundredths of a percent. It runs again whenever the prices change.
+func (item *ResponseItem) setSpread() {
+	item.Spread, item.SpreadPercent = nil, nil
+	if item.MinPriceTradable == nil || item.MinPriceNonTradable == nil {
+		return
+	}
+	tradable, nonTradable := *item.MinPriceTradable, *item.MinPriceNonTradable
+	spread := math.Round((tradable-nonTradable)*100) / 100
+	item.Spread = &spread
+	if tradable != 0 {
+		percent := math.Round((tradable-nonTradable)/tradable*10000) / 100
+		item.SpreadPercent = &percent
+	}
 }
 
 type APIError struct {
@@ -33,3 +157,47 @@ type ErrorResponse struct {
 func (e *ErrorResponse) Error() string {
 	return fmt.Sprintf("skinport api error: %v", e.Errors)
 }
+
+// View selects which upstream dataset items come from
+type View string
+
+const (
+	// ViewMerged merges tradable and non-tradable items, one per market_hash_name
+	ViewMerged View = "merged"
+	// ViewTradable and ViewNonTradable fetch a single dataset, one upstream request;
+	// the other side's price is nil
+	ViewTradable    View = "tradable"
+	ViewNonTradable View = "nontradable"
+)
+
+// ParseView parses a view name; empty means ViewMerged
+func ParseView(s string) (View, error) {
+	switch View(s) {
+	case "", ViewMerged:
+		return ViewMerged, nil
+	case ViewTradable, ViewNonTradable:
+		return View(s), nil
+	}
+	return "", fmt.Errorf("view must be %s, %s or %s", ViewTradable, ViewNonTradable, ViewMerged)
+}
+
+// ErrItemNotFound is matched (via errors.Is) by ItemNotFoundError
+var ErrItemNotFound = errors.New("item not found")
+
+// ItemNotFoundError is returned for an item missing from the cached snapshot, looked up
+// by Slug or by MarketHashName
+type ItemNotFoundError struct {
+	Slug           string `json:"slug,omitempty"`
+	MarketHashName string `json:"market_hash_name,omitempty"`
+}
+
+func (e *ItemNotFoundError) Error() string {
+	if e.Slug != "" {
+		return fmt.Sprintf("item not found: slug %q", e.Slug)
+	}
+	return fmt.Sprintf("item not found: %q", e.MarketHashName)
+}
+
+func (e *ItemNotFoundError) Is(target error) bool {
+	return target == ErrItemNotFound
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fsanano/go-test/internal/model"
+)
+
+// StatsStore aggregates sales, implemented by repository.StatsRepository
+type StatsStore interface {
+	GetSalesStats(ctx context.Context, from, to time.Time, topLimit int) (*model.SalesStats, error)
+	// GetSalesStatsDaily reads day-aligned windows from the order_stats_daily view
+	GetSalesStatsDaily(ctx context.Context, from, to time.Time, topLimit int) (*model.SalesStats, error)
+	RefreshSalesStats(ctx context.Context) error
+}
+
+type StatsService struct {
+	repo StatsStore
+	// useDailyView serves day-aligned windows from the order_stats_daily materialized view
+	useDailyView bool
+}
+
+func NewStatsService(repo StatsStore, useDailyView bool) *StatsService {
+	return &StatsService{repo: repo, useDailyView: useDailyView}
+}
+
+// GetSalesStats returns sales aggregated over the last window (0 means all time)
+func (s *StatsService) GetSalesStats(ctx context.Context, window time.Duration, topLimit int) (*model.SalesStats, error) {
+	if topLimit <= 0 {
+		topLimit = 10
+	}
+
+	to := time.Now().UTC()
+	from := time.Unix(0, 0).UTC()
+	if window > 0 {
+		from = to.Add(-window)
+	}
+
+	// The view only has day granularity, shorter windows always go to the live table
+	if s.useDailyView && (window == 0 || window >= 24*time.Hour) {
+		return s.repo.GetSalesStatsDaily(ctx, from, to, topLimit)
+	}
+	return s.repo.GetSalesStats(ctx, from, to, topLimit)
+}
+
+// UsesDailyView reports whether stats are served from the materialized view,
+// which then needs a periodic Refresh
+func (s *StatsService) UsesDailyView() bool {
+	return s.useDailyView
+}
+
+// Refresh refreshes the order_stats_daily materialized view
+func (s *StatsService) Refresh(ctx context.Context) error {
+	return s.repo.RefreshSalesStats(ctx)
+}
+
+// ParseWindow parses stats windows such as "24h", "7d", "30d" or "all"
+func ParseWindow(s string) (time.Duration, error) {
+	switch s {
+	case "", "all":
+		return 0, nil
+	}
+
+	var days int
+	if _, err := fmt.Sscanf(s, "%dd", &days); err == nil && fmt.Sprintf("%dd", days) == s {
+		if days <= 0 {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
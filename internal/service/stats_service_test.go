@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindow(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    0,
+		"all": 0,
+		"24h": 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range cases {
+		got, err := ParseWindow(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"0d", "-1h", "week", "7dd"} {
+		_, err := ParseWindow(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/steam"
+)
+
+// SteamInventoryItem is one kind of item in a Steam inventory, valued at its lowest
+// Skinport listing
+type SteamInventoryItem struct {
+	MarketHashName string `json:"market_hash_name"`
+	Name           string `json:"name"`
+	IconURL        string `json:"icon_url,omitempty"`
+	Quantity       int    `json:"quantity"`
+	Tradable       int    `json:"tradable"`
+	// Listed is false when Skinport has no listing, the item is then not valued
+	Listed    bool     `json:"listed"`
+	UnitPrice *float64 `json:"unit_price,omitempty"`
+	Value     float64  `json:"value"`
+}
+
+// SteamInventory is a user's CS2 inventory with its total Skinport value
+type SteamInventory struct {
+	UserID     int                  `json:"user_id"`
+	SteamID    string               `json:"steam_id"`
+	Currency   string               `json:"currency"`
+	Items      []SteamInventoryItem `json:"items"`
+	TotalItems int                  `json:"total_items"`
+	TotalValue float64              `json:"total_value"`
+	// Priced is false when Skinport could not be reached and nothing is valued
+	Priced    bool      `json:"priced"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Inventory returns the CS2 inventory of the user's linked Steam account, valued in
+// currency (Skinport's default when empty) with the cached Skinport prices. The
+// inventory is still returned, unvalued, when Skinport cannot be reached.
+func (s *SteamService) Inventory(ctx context.Context, userID int, currency string) (*SteamInventory, error) {
+	currency, err := skinport.NormalizeCurrency(currency)
+	if err != nil {
+		return nil, invalid(fmt.Sprintf("%v (allowed: %s)", err, strings.Join(skinport.Currencies(), ", ")))
+	}
+	account, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := s.client.Inventory(ctx, account.SteamID, steam.CS2AppID, steam.CS2ContextID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SteamInventory{UserID: userID, SteamID: account.SteamID, Currency: currency, Items: []SteamInventoryItem{},
+		FetchedAt: inventory.FetchedAt}
+	index := map[string]int{}
+	for _, asset := range inventory.Items {
+		result.TotalItems += asset.Amount
+		i, ok := index[asset.MarketHashName]
+		if !ok {
+			i = len(result.Items)
+			index[asset.MarketHashName] = i
+			result.Items = append(result.Items, SteamInventoryItem{MarketHashName: asset.MarketHashName, Name: asset.Name, IconURL: asset.IconURL})
+		}
+		result.Items[i].Quantity += asset.Amount
+		if asset.Tradable {
+			result.Items[i].Tradable += asset.Amount
+		}
+	}
+	if len(result.Items) == 0 {
+		result.Priced = true
+		return result, nil
+	}
+
+	client, err := s.skinport.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := client.GetAllItems(ctx, steam.CS2AppID, currency)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "steam inventory served without skinport prices", "user_id", userID, "error", err)
+		return result, nil
+	}
+	valueInventory(result, items)
+	return result, nil
+}
+
+// valueInventory prices every inventory item listed in items and sums the total
+func valueInventory(inventory *SteamInventory, items []skinport.ResponseItem) {
+	index := make(map[string]int, len(inventory.Items))
+	for i, item := range inventory.Items {
+		index[item.MarketHashName] = i
+	}
+	total := 0.0
+	for _, listing := range items {
+		i, ok := index[listing.MarketHashName]
+		if !ok {
+			continue
+		}
+		price, ok := lowestPrice(listing)
+		if !ok {
+			continue
+		}
+		item := &inventory.Items[i]
+		item.Listed = true
+		item.UnitPrice = &price
+		item.Value = math.Round(price*float64(item.Quantity)*100) / 100
+		total += item.Value
+		if inventory.Currency == "" {
+			inventory.Currency = listing.Currency
+		}
+	}
+	inventory.TotalValue = math.Round(total*100) / 100
+	inventory.Priced = true
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueInventory(t *testing.T) {
+	tradable, nonTradable := 12.5, 10.333
+	inventory := &SteamInventory{Items: []SteamInventoryItem{
+		{MarketHashName: "AK-47 | Redline (Field-Tested)", Quantity: 3},
+		{MarketHashName: "Souvenir Package", Quantity: 1},
+	}}
+	valueInventory(inventory, []skinport.ResponseItem{
+		{MarketHashName: "AK-47 | Redline (Field-Tested)", Currency: "EUR", MinPriceTradable: &tradable, MinPriceNonTradable: &nonTradable},
+		{MarketHashName: "Souvenir Package", Currency: "EUR"},
+	})
+
+	assert.True(t, inventory.Priced)
+	assert.Equal(t, "EUR", inventory.Currency)
+	assert.True(t, inventory.Items[0].Listed)
+	assert.Equal(t, &nonTradable, inventory.Items[0].UnitPrice)
+	assert.Equal(t, 31.0, inventory.Items[0].Value)
+	assert.False(t, inventory.Items[1].Listed)
+	assert.Nil(t, inventory.Items[1].UnitPrice)
+	assert.Equal(t, 31.0, inventory.TotalValue)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+
+	"fsanano/go-test/internal/db"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service/skinport"
+	"fsanano/go-test/internal/steam"
+)
+
+// SteamLogin is the outcome of a Steam login
+type SteamLogin struct {
+	User    *model.User         `json:"user"`
+	Account *model.SteamAccount `json:"steam"`
+	// Created is set when the login registered a new user
+	Created bool `json:"created"`
+}
+
+// SteamStore keeps the Steam accounts linked to users, implemented by
+// repository.SteamRepository
+type SteamStore interface {
+	GetAccount(ctx context.Context, userID int) (*model.SteamAccount, error)
+	// GetAccountBySteamID returns the account with the SteamID64, locking it
+	GetAccountBySteamID(ctx context.Context, steamID string) (*model.SteamAccount, error)
+	SaveAccount(ctx context.Context, account *model.SteamAccount) error
+	DeleteAccount(ctx context.Context, userID int) error
+}
+
+// SteamUserStore creates and reads the users signing in with Steam, implemented by
+// repository.ShopRepository
+type SteamUserStore interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUser(ctx context.Context, userID int) (*model.User, error)
+}
+
+// SteamService signs users in with Steam OpenID. Steam accounts are keyed by their
+// SteamID64: the first login creates a user, later logins return it.
+type SteamService struct {
+	repo     SteamStore
+	users    SteamUserStore
+	uow      db.UnitOfWork
+	client   *steam.Client
+	skinport *skinport.Factory
+	audit    *AuditService
+}
+
+func NewSteamService(repo SteamStore, users SteamUserStore, uow db.UnitOfWork, client *steam.Client,
+	skinportClients *skinport.Factory, audit *AuditService) *SteamService {
+	return &SteamService{repo: repo, users: users, uow: uow, client: client, skinport: skinportClients, audit: audit}
+}
+
+// LoginURL is the Steam page users sign in on
+func (s *SteamService) LoginURL() string {
+	return s.client.LoginURL()
+}
+
+// Login verifies the callback of a Steam login and returns the account's user, creating
+// it on the first login. Deactivated and deleted users cannot sign in.
+func (s *SteamService) Login(ctx context.Context, callback url.Values) (*SteamLogin, error) {
+	steamID, err := s.client.Verify(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+	account := &model.SteamAccount{SteamID: steamID}
+	s.fillProfile(ctx, account)
+
+	login := &SteamLogin{Account: account}
+	err = runAtomic(ctx, s.uow, "steam_login", func(ctx context.Context) error {
+		existing, err := s.repo.GetAccountBySteamID(ctx, steamID)
+		switch {
+		case err == nil:
+			account.UserID = existing.UserID
+		case errors.Is(err, repository.ErrSteamAccountNotFound):
+			user := &model.User{FirstName: account.PersonaName, LastName: "Steam"}
+			if user.FirstName == "" {
+				user.FirstName = "Steam user " + steamID
+			}
+			if err := s.users.CreateUser(ctx, user); err != nil {
+				return err
+			}
+			account.UserID = user.ID
+			login.Created = true
+			if err := s.audit.Record(ctx, "user:"+strconv.Itoa(user.ID), "user.create", "user", strconv.Itoa(user.ID), nil, user); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if login.User, err = s.users.GetUser(ctx, account.UserID); err != nil {
+			return err
+		}
+		if login.User.Status != model.UserStatusActive {
+			return repository.ErrUserInactive
+		}
+		if err := s.repo.SaveAccount(ctx, account); err != nil {
+			return err
+		}
+		if login.Created {
+			return s.audit.Record(ctx, "user:"+strconv.Itoa(account.UserID), "steam.link", "user", strconv.Itoa(account.UserID),
+				nil, map[string]any{"steam_id": steamID})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+// Link links the Steam account to the user, replacing the user's previous account
+func (s *SteamService) Link(ctx context.Context, userID int, steamID string) (*model.SteamAccount, error) {
+	if !steam.ValidSteamID(steamID) {
+		return nil, invalid("steam_id must be a SteamID64")
+	}
+	account := &model.SteamAccount{UserID: userID, SteamID: steamID}
+	s.fillProfile(ctx, account)
+
+	err := runAtomic(ctx, s.uow, "steam_link", func(ctx context.Context) error {
+		var before any
+		if previous, err := s.repo.GetAccount(ctx, userID); err == nil {
+			before = map[string]any{"steam_id": previous.SteamID}
+		}
+		if err := s.repo.SaveAccount(ctx, account); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "steam.link", "user", strconv.Itoa(userID), before, map[string]any{"steam_id": steamID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Unlink removes the user's Steam account
+func (s *SteamService) Unlink(ctx context.Context, userID int) error {
+	return runAtomic(ctx, s.uow, "steam_unlink", func(ctx context.Context) error {
+		previous, err := s.repo.GetAccount(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteAccount(ctx, userID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "steam.unlink", "user", strconv.Itoa(userID), map[string]any{"steam_id": previous.SteamID}, nil)
+	})
+}
+
+// GetAccount returns the Steam account linked to the user
+func (s *SteamService) GetAccount(ctx context.Context, userID int) (*model.SteamAccount, error) {
+	return s.repo.GetAccount(ctx, userID)
+}
+
+// fillProfile adds the Steam profile to the account. Profiles are a nicety: when Steam
+// cannot be reached the stored profile is kept.
+func (s *SteamService) fillProfile(ctx context.Context, account *model.SteamAccount) {
+	profile, err := s.client.Profile(ctx, account.SteamID)
+	if err != nil {
+		slog.WarnContext(ctx, "steam profile lookup failed", "steam_id", account.SteamID, "error", err)
+		return
+	}
+	if profile != nil {
+		account.PersonaName, account.ProfileURL, account.AvatarURL = profile.PersonaName, profile.ProfileURL, profile.AvatarURL
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/db"
+)
+
+// TelegramLinkToken is a one-time token a user sends to the bot to link their chat
+type TelegramLinkToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TelegramStore keeps the Telegram chats linked to users, implemented by
+// repository.TelegramRepository
+type TelegramStore interface {
+	CreateLinkToken(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (time.Time, error)
+	// ConsumeLinkToken deletes the token and returns its user, if it has not expired
+	ConsumeLinkToken(ctx context.Context, tokenHash string) (int, error)
+	Link(ctx context.Context, userID int, chatID int64) error
+	// Unlink removes the chat's link and returns the user it was linked to
+	Unlink(ctx context.Context, chatID int64) (int, error)
+	UserIDForChat(ctx context.Context, chatID int64) (int, error)
+}
+
+// TelegramService links Telegram chats to users. Tokens are only stored hashed, so a
+// database leak does not let anyone link a chat to another user.
+type TelegramService struct {
+	repo     TelegramStore
+	uow      db.UnitOfWork
+	audit    *AuditService
+	tokenTTL time.Duration
+}
+
+func NewTelegramService(repo TelegramStore, uow db.UnitOfWork, audit *AuditService,
+	tokenTTL time.Duration) *TelegramService {
+	return &TelegramService{repo: repo, uow: uow, audit: audit, tokenTTL: tokenTTL}
+}
+
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueLinkToken creates a link token for the user
+func (s *TelegramService) IssueLinkToken(ctx context.Context, userID int) (*TelegramLinkToken, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(raw)
+
+	expiresAt, err := s.repo.CreateLinkToken(ctx, userID, hashLinkToken(token), s.tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TelegramLinkToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Link consumes the token and links the chat to its user, replacing the user's
+// previous chat and the chat's previous user
+func (s *TelegramService) Link(ctx context.Context, token string, chatID int64) (int, error) {
+	if strings.TrimSpace(token) == "" {
+		return 0, invalid("link token is required")
+	}
+
+	var userID int
+	err := runAtomic(ctx, s.uow, "link_telegram", func(ctx context.Context) error {
+		var err error
+		if userID, err = s.repo.ConsumeLinkToken(ctx, hashLinkToken(token)); err != nil {
+			return err
+		}
+		if err := s.repo.Link(ctx, userID, chatID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "telegram", "telegram.link", "user", strconv.Itoa(userID), nil, map[string]any{"chat_id": chatID})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Unlink removes the chat's link
+func (s *TelegramService) Unlink(ctx context.Context, chatID int64) error {
+	return runAtomic(ctx, s.uow, "unlink_telegram", func(ctx context.Context) error {
+		userID, err := s.repo.Unlink(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "telegram", "telegram.unlink", "user", strconv.Itoa(userID), map[string]any{"chat_id": chatID}, nil)
+	})
+}
+
+// UserIDForChat returns the user linked to the chat
+func (s *TelegramService) UserIDForChat(ctx context.Context, chatID int64) (int, error) {
+	return s.repo.UserIDForChat(ctx, chatID)
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/crypto"
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+const (
+	maxTenantSlugLength = 63
+	// unknownTenantTTL is how long a slug without a tenant is answered from memory, so
+	// requests for unknown shops do not each query the database
+	unknownTenantTTL = 10 * time.Second
+	// maxUnknownTenants bounds the slugs remembered as unknown, they come from requests
+	maxUnknownTenants = 10_000
+)
+
+// TenantStore keeps the shops and their sealed Skinport credentials, implemented by
+// repository.TenantRepository
+type TenantStore interface {
+	CreateTenant(ctx context.Context, t *model.Tenant) error
+	GetTenantBySlug(ctx context.Context, slug string) (*model.Tenant, error)
+	ListTenants(ctx context.Context) ([]model.Tenant, error)
+	SkinportCredentialReader
+	ListSkinportCredentials(ctx context.Context) ([]repository.SealedSkinportCredentials, error)
+	SetSkinportCredentials(ctx context.Context, tenantID int, c *repository.SealedSkinportCredentials) error
+	DeleteSkinportCredentials(ctx context.Context, tenantID int) error
+}
+
+// TenantService manages the shops served by the deployment and resolves the tenant of
+// requests from its slug
+type TenantService struct {
+	repo  TenantStore
+	audit *AuditService
+	// keys encrypt tenants' Skinport credentials; nil when ENCRYPTION_KEYS is not set
+	keys *crypto.Keyring
+	// skinportClients is told to drop a tenant's client when its credentials change
+	skinportClients *skinport.Factory
+
+	// ids caches tenant ids by slug, tenants are neither renamed nor removed. unknown
+	// holds until when slugs without a tenant are known not to have one.
+	mu      sync.RWMutex
+	ids     map[string]int
+	unknown map[string]time.Time
+	now     func() time.Time
+}
+
+func NewTenantService(repo TenantStore, audit *AuditService, keys *crypto.Keyring, skinportClients *skinport.Factory) *TenantService {
+	return &TenantService{repo: repo, audit: audit, keys: keys, skinportClients: skinportClients,
+		ids: map[string]int{}, unknown: map[string]time.Time{}, now: time.Now}
+}
+
+// ResolveTenant returns the id of the tenant with the slug. Unknown slugs are looked up
+// again after unknownTenantTTL, so a tenant created by another instance is served
+// within it; this instance serves the tenants it creates right away.
+func (s *TenantService) ResolveTenant(ctx context.Context, slug string) (int, error) {
+	s.mu.RLock()
+	id, ok := s.ids[slug]
+	unknownUntil, unknown := s.unknown[slug]
+	s.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+	if unknown && s.now().Before(unknownUntil) {
+		return 0, errors.New("tenant not found")
+	}
+
+	t, err := s.repo.GetTenantBySlug(ctx, slug)
+	if err != nil {
+		if err.Error() == "tenant not found" {
+			s.rememberUnknown(slug)
+		}
+		return 0, err
+	}
+	s.mu.Lock()
+	s.ids[slug] = t.ID
+	delete(s.unknown, slug)
+	s.mu.Unlock()
+	return t.ID, nil
+}
+
+// rememberUnknown caches that the slug has no tenant for unknownTenantTTL. When too many
+// slugs are remembered, the expired ones are dropped, or all of them if none expired.
+func (s *TenantService) rememberUnknown(slug string) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.unknown) >= maxUnknownTenants {
+		for k, until := range s.unknown {
+			if !now.Before(until) {
+				delete(s.unknown, k)
+			}
+		}
+		if len(s.unknown) >= maxUnknownTenants {
+			clear(s.unknown)
+		}
+	}
+	s.unknown[slug] = now.Add(unknownTenantTTL)
+}
+
+func (s *TenantService) ListTenants(ctx context.Context) ([]model.Tenant, error) {
+	return s.repo.ListTenants(ctx)
+}
+
+// CreateTenant adds a shop, served under its slug right away
+func (s *TenantService) CreateTenant(ctx context.Context, t *model.Tenant) error {
+	t.Slug = strings.TrimSpace(t.Slug)
+	t.Name = strings.TrimSpace(t.Name)
+	if !slugPattern.MatchString(t.Slug) || len(t.Slug) > maxTenantSlugLength {
+		return invalid("slug must be at most 63 lowercase letters, digits and dashes")
+	}
+	if t.Name == "" {
+		return invalid("name is required")
+	}
+
+	if err := s.repo.CreateTenant(ctx, t); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.unknown, t.Slug)
+	s.mu.Unlock()
+	return s.audit.Record(ctx, "admin", "tenant.create", "tenant", strconv.Itoa(t.ID), nil, t)
+}
+
+// GetSkinportCredentials describes the Skinport credentials the tenant configured
+func (s *TenantService) GetSkinportCredentials(ctx context.Context, tenantID int) (*model.TenantSkinportCredentials, error) {
+	sealed, err := s.repo.GetSkinportCredentials(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := s.keys.Open(sealed.ClientID, credentialData(tenantID, "client_id"))
+	if err != nil {
+		return nil, err
+	}
+	return &model.TenantSkinportCredentials{TenantID: tenantID, ClientID: string(clientID), UpdatedAt: sealed.UpdatedAt}, nil
+}
+
+// SetSkinportCredentials makes the tenant's Skinport requests use its own credentials,
+// with a client, cache and rate limit of its own. Both values are stored encrypted.
+func (s *TenantService) SetSkinportCredentials(ctx context.Context, tenantID int, clientID, apiKey string) (*model.TenantSkinportCredentials, error) {
+	clientID = strings.TrimSpace(clientID)
+	apiKey = strings.TrimSpace(apiKey)
+	if clientID == "" || apiKey == "" {
+		return nil, invalid("client_id and api_key are required")
+	}
+	if s.keys == nil {
+		return nil, invalid("skinport credentials cannot be stored without ENCRYPTION_KEYS")
+	}
+
+	before, err := s.GetSkinportCredentials(ctx, tenantID)
+	if err != nil && err.Error() != "skinport credentials not found" {
+		return nil, err
+	}
+	sealed := &repository.SealedSkinportCredentials{}
+	if sealed.ClientID, err = s.keys.Seal([]byte(clientID), credentialData(tenantID, "client_id")); err != nil {
+		return nil, err
+	}
+	if sealed.APIKey, err = s.keys.Seal([]byte(apiKey), credentialData(tenantID, "api_key")); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetSkinportCredentials(ctx, tenantID, sealed); err != nil {
+		return nil, err
+	}
+	s.forgetSkinportClient(tenantID)
+
+	after := &model.TenantSkinportCredentials{TenantID: tenantID, ClientID: clientID, UpdatedAt: sealed.UpdatedAt}
+	if err := s.audit.Record(ctx, "admin", "tenant.set_skinport_credentials", "tenant", strconv.Itoa(tenantID), before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteSkinportCredentials makes the tenant use the deployment's Skinport credentials again
+func (s *TenantService) DeleteSkinportCredentials(ctx context.Context, tenantID int) error {
+	before, err := s.GetSkinportCredentials(ctx, tenantID)
+	if err != nil && err.Error() != "skinport credentials not found" {
+		return err
+	}
+	if err := s.repo.DeleteSkinportCredentials(ctx, tenantID); err != nil {
+		return err
+	}
+	s.forgetSkinportClient(tenantID)
+	return s.audit.Record(ctx, "admin", "tenant.delete_skinport_credentials", "tenant", strconv.Itoa(tenantID), before, nil)
+}
+
+// RotateSkinportCredentials seals the stored Skinport credentials sealed with older keys
+// again with the primary key, returning how many tenants' were; once it has run on every
+// deployment sharing the database, the older keys can be removed from ENCRYPTION_KEYS
+func (s *TenantService) RotateSkinportCredentials(ctx context.Context) (int, error) {
+	if s.keys == nil {
+		return 0, crypto.ErrNoKey
+	}
+	stored, err := s.repo.ListSkinportCredentials(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, sealed := range stored {
+		if s.keys.Current(sealed.ClientID) && s.keys.Current(sealed.APIKey) {
+			continue
+		}
+		resealed := &repository.SealedSkinportCredentials{TenantID: sealed.TenantID}
+		if resealed.ClientID, err = s.keys.Reseal(sealed.ClientID, credentialData(sealed.TenantID, "client_id")); err != nil {
+			return rotated, fmt.Errorf("tenant %d: %w", sealed.TenantID, err)
+		}
+		if resealed.APIKey, err = s.keys.Reseal(sealed.APIKey, credentialData(sealed.TenantID, "api_key")); err != nil {
+			return rotated, fmt.Errorf("tenant %d: %w", sealed.TenantID, err)
+		}
+		if err := s.repo.SetSkinportCredentials(ctx, sealed.TenantID, resealed); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// credentialData binds a sealed credential to its tenant and column
+func credentialData(tenantID int, column string) []byte {
+	return fmt.Appendf(nil, "tenant_skinport_credentials.%s:%d", column, tenantID)
+}
+
+func (s *TenantService) forgetSkinportClient(tenantID int) {
+	if s.skinportClients != nil {
+		s.skinportClients.Forget(tenantID)
+	}
+}
+
+// SkinportCredentialReader reads a tenant's sealed Skinport credentials, implemented by
+// repository.TenantRepository
+type SkinportCredentialReader interface {
+	GetSkinportCredentials(ctx context.Context, tenantID int) (*repository.SealedSkinportCredentials, error)
+}
+
+// SkinportCredentialStore reads the tenants' Skinport credentials for skinport.Factory
+type SkinportCredentialStore struct {
+	repo SkinportCredentialReader
+	keys *crypto.Keyring
+}
+
+func NewSkinportCredentialStore(repo SkinportCredentialReader, keys *crypto.Keyring) *SkinportCredentialStore {
+	return &SkinportCredentialStore{repo: repo, keys: keys}
+}
+
+// SkinportCredentials implements skinport.CredentialStore. Stored credentials fail to
+// decrypt without the key of ENCRYPTION_KEYS they were sealed with.
+func (s *SkinportCredentialStore) SkinportCredentials(ctx context.Context, tenantID int) (skinport.Credentials, bool, error) {
+	sealed, err := s.repo.GetSkinportCredentials(ctx, tenantID)
+	if err != nil {
+		if err.Error() == "skinport credentials not found" {
+			return skinport.Credentials{}, false, nil
+		}
+		return skinport.Credentials{}, false, err
+	}
+	clientID, err := s.keys.Open(sealed.ClientID, credentialData(tenantID, "client_id"))
+	if err != nil {
+		return skinport.Credentials{}, false, err
+	}
+	apiKey, err := s.keys.Open(sealed.APIKey, credentialData(tenantID, "api_key"))
+	if err != nil {
+		return skinport.Credentials{}, false, err
+	}
+	return skinport.Credentials{ClientID: string(clientID), APIKey: string(apiKey)}, true, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTenant_UnknownSlugs(t *testing.T) {
+	now := time.Now()
+	// Without a repository, a lookup would panic: unknown slugs are answered from memory
+	s := NewTenantService(nil, nil, nil, nil)
+	s.now = func() time.Time { return now }
+	s.ids["acme"] = 2
+
+	id, err := s.ResolveTenant(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+
+	s.rememberUnknown("nope")
+	_, err = s.ResolveTenant(context.Background(), "nope")
+	assert.EqualError(t, err, "tenant not found")
+	now = now.Add(unknownTenantTTL)
+	assert.Panics(t, func() { s.ResolveTenant(context.Background(), "nope") }, "looked up again once expired")
+
+	for i := range maxUnknownTenants {
+		s.rememberUnknown(fmt.Sprint(i))
+	}
+	assert.Len(t, s.unknown, maxUnknownTenants, "the expired slug made room")
+	assert.NotContains(t, s.unknown, "nope")
+	s.rememberUnknown("one more")
+	assert.Len(t, s.unknown, 1)
+}
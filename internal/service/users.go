@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/eventbus"
+	"fsanano/go-test/internal/model"
+)
+
+// Account lifecycle errors
+var (
+	ErrUserDeleted = errors.New("user is deleted")
+	// ErrUserHasPendingPayouts is returned when deleting a user whose payout has not been
+	// sent yet, its destination is still needed
+	ErrUserHasPendingPayouts = errors.New("user has pending payouts")
+)
+
+// CreateUser registers a user with an opening balance
+func (s *ShopService) CreateUser(ctx context.Context, firstName, lastName string, balance float64) (*model.User, error) {
+	user := &model.User{
+		FirstName: strings.TrimSpace(firstName),
+		LastName:  strings.TrimSpace(lastName),
+		Balance:   model.NewMoney(balance, model.DefaultCurrency),
+	}
+	if user.FirstName == "" || user.LastName == "" {
+		return nil, invalid("first and last name are required")
+	}
+	if balance < 0 {
+		return nil, invalid("balance must not be negative")
+	}
+
+	err := runAtomic(ctx, s.uow, "create_user", func(ctx context.Context) error {
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "user.create", "user", strconv.Itoa(user.ID), nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// AdjustBalance credits (Delta > 0) or debits adj.UserID's balance on behalf of
+// support staff. The adjustment needs one of model.AdjustmentReasonCodes and a reason;
+// it is posted to the ledger, recorded with its source and audited. It returns the
+// recorded adjustment with the new balance.
+func (s *ShopService) AdjustBalance(ctx context.Context, adj model.BalanceAdjustment) (*model.BalanceAdjustment, error) {
+	adj.ReasonCode = strings.ToLower(strings.TrimSpace(adj.ReasonCode))
+	adj.Reason = strings.TrimSpace(adj.Reason)
+	if !slices.Contains(model.AdjustmentReasonCodes, adj.ReasonCode) {
+		return nil, invalid("reason_code must be one of " + strings.Join(model.AdjustmentReasonCodes, ", "))
+	}
+	if err := validateAdjustment(adj.Delta, adj.Reason); err != nil {
+		return nil, err
+	}
+
+	err := runAtomic(ctx, s.uow, "adjust_balance", func(ctx context.Context) error {
+		balance, err := s.repo.AdjustUserBalance(ctx, adj.UserID, adj.Delta, model.LedgerKindAdjustment,
+			"adjustment:"+adj.ReasonCode)
+		if err != nil {
+			return err
+		}
+		adj.Balance = balance
+		if err := s.repo.RecordBalanceAdjustment(ctx, &adj); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "balance.adjust", "user", strconv.Itoa(adj.UserID),
+			map[string]any{"balance": adj.Balance.Sub(adj.Delta)},
+			map[string]any{"balance": adj.Balance, "delta": adj.Delta, "reason_code": adj.ReasonCode,
+				"reason": adj.Reason, "source": adj.Source, "adjustment_id": adj.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.UserUpdated, eventbus.UserUpdatedEvent{UserID: adj.UserID, Fields: []string{"balance"}})
+	return &adj, nil
+}
+
+// SetUserEmail sets the address notifications are sent to; an empty email removes it
+func (s *ShopService) SetUserEmail(ctx context.Context, userID int, email string) error {
+	email = strings.TrimSpace(email)
+	if email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return invalid("invalid email address")
+		}
+	}
+
+	err := runAtomic(ctx, s.uow, "set_user_email", func(ctx context.Context) error {
+		if err := s.repo.SetUserEmail(ctx, userID, email); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "user.email", "user", strconv.Itoa(userID), nil, map[string]any{"email": email})
+	})
+	if err != nil {
+		return err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.UserUpdated, eventbus.UserUpdatedEvent{UserID: userID, Fields: []string{"email"}})
+	return nil
+}
+
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+// SetUserRegion sets the tax region of the user (an ISO 3166 code such as DE or US-CA);
+// an empty region removes it
+func (s *ShopService) SetUserRegion(ctx context.Context, userID int, region string) error {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if region != "" && !regionPattern.MatchString(region) {
+		return invalid("region must be an ISO 3166 code such as DE or US-CA")
+	}
+
+	err := runAtomic(ctx, s.uow, "set_user_region", func(ctx context.Context) error {
+		if err := s.repo.SetUserRegion(ctx, userID, region); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", "user.region", "user", strconv.Itoa(userID), nil, map[string]any{"region": region})
+	})
+	if err != nil {
+		return err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.UserUpdated, eventbus.UserUpdatedEvent{UserID: userID, Fields: []string{"region"}})
+	return nil
+}
+
+// DeactivateUser blocks the user from buying; orders and balances are kept
+func (s *ShopService) DeactivateUser(ctx context.Context, userID int) error {
+	return s.setUserStatus(ctx, userID, model.UserStatusDeactivated, "user.deactivate")
+}
+
+// ReactivateUser lets a deactivated user buy again
+func (s *ShopService) ReactivateUser(ctx context.Context, userID int) error {
+	return s.setUserStatus(ctx, userID, model.UserStatusActive, "user.reactivate")
+}
+
+func (s *ShopService) setUserStatus(ctx context.Context, userID int, status, action string) error {
+	err := runAtomic(ctx, s.uow, action, func(ctx context.Context) error {
+		previous, err := s.repo.LockUserStatus(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if previous == model.UserStatusDeleted {
+			return ErrUserDeleted
+		}
+		if err := s.repo.SetUserStatus(ctx, userID, status); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, "admin", action, "user", strconv.Itoa(userID),
+			map[string]any{"status": previous}, map[string]any{"status": status})
+	})
+	if err != nil {
+		return err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.UserUpdated, eventbus.UserUpdatedEvent{UserID: userID, Fields: []string{"status"}})
+	return nil
+}
+
+// DeleteUser fulfils a GDPR erasure request: the user's personal data is anonymized
+// and the account can no longer be used, while financial records (orders, payouts,
+// deposits, the ledger) are kept for accounting. The stored receipts of the user's
+// orders, which show the buyer's name and email, are deleted.
+func (s *ShopService) DeleteUser(ctx context.Context, userID int) error {
+	err := runAtomic(ctx, s.uow, "delete_user", func(ctx context.Context) error {
+		previous, err := s.repo.LockUserStatus(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if previous == model.UserStatusDeleted {
+			return ErrUserDeleted
+		}
+		pending, err := s.repo.HasPendingPayouts(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrUserHasPendingPayouts
+		}
+		if err := s.repo.AnonymizeUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.forgetReceipts(ctx, userID); err != nil {
+			return err
+		}
+		// The entry records the erasure itself, never the erased data
+		return s.audit.Record(ctx, "admin", "user.delete", "user", strconv.Itoa(userID),
+			map[string]any{"status": previous}, map[string]any{"status": model.UserStatusDeleted})
+	})
+	if err != nil {
+		return err
+	}
+	eventbus.Publish(ctx, s.bus, eventbus.UserUpdated, eventbus.UserUpdatedEvent{UserID: userID,
+		Fields: []string{"status", "first_name", "last_name", "email", "region"}})
+	return nil
+}
+
+// forgetReceipts deletes the stored receipts of the user's orders. It runs inside the
+// erasure, so a failure leaves the user to be erased again.
+func (s *ShopService) forgetReceipts(ctx context.Context, userID int) error {
+	if s.receipts == nil {
+		return nil
+	}
+	orderIDs, err := s.repo.ListUserOrderIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.receipts.Forget(ctx, orderIDs)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetUserRegion_Validation(t *testing.T) {
+	s := NewShopService(nil, nil)
+	for _, region := range []string{"Germany", "D", "US-", "US-CALI", "12"} {
+		err := s.SetUserRegion(context.Background(), 1, region)
+		assert.True(t, errors.Is(err, ErrValidation), region)
+	}
+}
+
+func TestAdjustBalance_Validation(t *testing.T) {
+	s := NewShopService(nil, nil)
+	for _, adj := range []model.BalanceAdjustment{
+		{UserID: 1, Delta: model.Money{Amount: 1000}, Reason: "missing code"},
+		{UserID: 1, Delta: model.Money{Amount: 1000}, ReasonCode: "bonus", Reason: "unknown code"},
+		{UserID: 1, Delta: model.Money{Amount: 1000}, ReasonCode: model.AdjustmentReasonGoodwill, Reason: "  "},
+		{UserID: 1, ReasonCode: model.AdjustmentReasonCorrection, Reason: "zero"},
+		{UserID: 1, Delta: model.NewMoney(maxAdjustmentDelta+1, ""), ReasonCode: model.AdjustmentReasonCorrection, Reason: "too large"},
+	} {
+		_, err := s.AdjustBalance(context.Background(), adj)
+		assert.True(t, errors.Is(err, ErrValidation), adj.Reason)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/internal/notifications"
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/tenant"
+)
+
+// ErrWaitlistDisabled is returned by the waitlist methods when no waitlist is configured
+var ErrWaitlistDisabled = errors.New("waitlist is disabled")
+
+// WaitlistEntryStore keeps the entries users put on waitlists, implemented by
+// repository.WaitlistRepository
+type WaitlistEntryStore interface {
+	CreateEntry(ctx context.Context, e *model.WaitlistEntry) error
+	ListUserEntries(ctx context.Context, userID int) ([]model.WaitlistEntry, error)
+	// CancelEntry takes the user's entry off the waitlist
+	CancelEntry(ctx context.Context, userID, id int) (*model.WaitlistEntry, error)
+}
+
+// WithWaitlist lets users wait for out-of-stock items, see JoinWaitlist. The entries are
+// bought by WaitlistService.ProcessWaitlist.
+func WithWaitlist(repo WaitlistEntryStore) ShopServiceOption {
+	return func(s *ShopService) {
+		s.waitlist = repo
+	}
+}
+
+// JoinWaitlist puts the user at the end of the item's waitlist for quantity; once the
+// item is restocked it is bought for them, in turn
+func (s *ShopService) JoinWaitlist(ctx context.Context, userID, itemID, quantity int) (*model.WaitlistEntry, error) {
+	if s.waitlist == nil {
+		return nil, ErrWaitlistDisabled
+	}
+	if quantity <= 0 {
+		return nil, invalid("quantity must be greater than 0")
+	}
+
+	entry := &model.WaitlistEntry{UserID: userID, ItemID: itemID, Quantity: quantity}
+	if err := s.waitlist.CreateEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListWaitlist returns the user's waitlist entries, newest first
+func (s *ShopService) ListWaitlist(ctx context.Context, userID int) ([]model.WaitlistEntry, error) {
+	if s.waitlist == nil {
+		return nil, ErrWaitlistDisabled
+	}
+	return s.waitlist.ListUserEntries(ctx, userID)
+}
+
+// LeaveWaitlist cancels the user's waiting entry
+func (s *ShopService) LeaveWaitlist(ctx context.Context, userID, entryID int) (*model.WaitlistEntry, error) {
+	if s.waitlist == nil {
+		return nil, ErrWaitlistDisabled
+	}
+	return s.waitlist.CancelEntry(ctx, userID, entryID)
+}
+
+// WaitlistStore serves the waitlists, implemented by repository.WaitlistRepository
+type WaitlistStore interface {
+	// ListRestockedItems returns the items in stock that users wait for
+	ListRestockedItems(ctx context.Context) ([]int, error)
+	// NextWaitingEntry returns the item's oldest waiting entry, nil if there is none
+	NextWaitingEntry(ctx context.Context, itemID int) (*model.WaitingEntry, error)
+	// CloseEntry records how a waiting entry ended, fulfilled with orderID or failed with
+	// reason, and reports false when it was no longer waiting
+	CloseEntry(ctx context.Context, id int, status string, orderID *int, reason string) (bool, error)
+}
+
+// WaitlistService buys restocked items for the users waiting for them; ProcessWaitlist is
+// meant to be run periodically as an exclusive background job
+type WaitlistService struct {
+	repo     WaitlistStore
+	shop     *ShopService
+	notifier *notifications.Notifier
+	// mu serializes the runs of this instance, the job's and those after a restock
+	mu sync.Mutex
+}
+
+func NewWaitlistService(repo WaitlistStore, shop *ShopService, notifier *notifications.Notifier) *WaitlistService {
+	return &WaitlistService{repo: repo, shop: shop, notifier: notifier}
+}
+
+// ProcessWaitlist serves the waitlists of the items back in stock. Each item's entries
+// are served first come, first served: an entry the stock cannot cover yet holds back
+// the ones behind it until the next restock. Stock bought by others between two runs is
+// not held for the waitlist.
+//
+// Runs on other instances may overlap: an entry's purchase is keyed by the entry, and
+// only the run that closes the entry notifies its user.
+func (s *WaitlistService) ProcessWaitlist(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	itemIDs, err := s.repo.ListRestockedItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, itemID := range itemIDs {
+		if err := s.processItem(ctx, itemID); err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", itemID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// processItem buys for the item's waiting entries in order until one runs out of stock
+func (s *WaitlistService) processItem(ctx context.Context, itemID int) error {
+	for {
+		next, err := s.repo.NextWaitingEntry(ctx, itemID)
+		if err != nil || next == nil {
+			return err
+		}
+		e := next.Entry
+
+		// The key makes the purchase idempotent: if closing the entry fails, the next run
+		// finds the order instead of buying again
+		order, err := s.shop.BuyItem(tenant.WithID(ctx, next.TenantID), BuyParams{
+			UserID:        e.UserID,
+			ItemID:        e.ItemID,
+			Quantity:      e.Quantity,
+			ClientOrderID: fmt.Sprintf("waitlist:%d", e.ID),
+		})
+		switch {
+		case waitlistHeld(err):
+			return nil
+		case err != nil && !waitlistRefusal(err):
+			return fmt.Errorf("entry %d: %w", e.ID, err)
+		}
+
+		update := notifications.WaitlistUpdate{FirstName: next.FirstName, ItemName: next.ItemName, Quantity: e.Quantity}
+		var closed bool
+		if err != nil {
+			update.Reason = err.Error()
+			closed, err = s.repo.CloseEntry(ctx, e.ID, model.WaitlistFailed, nil, update.Reason)
+		} else {
+			update.Fulfilled, update.OrderID, update.Price = true, order.ID, order.Price.Float()
+			closed, err = s.repo.CloseEntry(ctx, e.ID, model.WaitlistFulfilled, &order.ID, "")
+		}
+		if err != nil {
+			return err
+		}
+
+		// Notifications are best effort, the entry is closed either way
+		if closed && s.notifier.WaitlistEnabled() {
+			to := notifications.Recipient{Email: next.Email, ChatID: next.TelegramChatID}
+			if err := s.notifier.SendWaitlistUpdate(ctx, to, update); err != nil {
+				slog.Warn("failed to send waitlist update", "entry_id", e.ID, "error", err)
+			}
+		}
+	}
+}
+
+// waitlistHeld reports whether err leaves the entry waiting for more stock: the item's,
+// or that of its drop
+func waitlistHeld(err error) bool {
+	var dropErr *DropError
+	if errors.As(err, &dropErr) {
+		return dropErr.Reason != DropReasonPerUserLimit
+	}
+	return errors.Is(err, repository.ErrInsufficientStock)
+}
+
+// waitlistRefusal reports whether err refuses the entry's purchase for good, rather than
+// failing it for now (conflicts, timeouts, the per-minute order limit)
+func waitlistRefusal(err error) bool {
+	var limitErr *PurchaseLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr.Rule != RuleOrdersPerMinute
+	}
+	return errors.Is(err, repository.ErrInsufficientFunds) || errors.Is(err, repository.ErrUserInactive) ||
+		errors.Is(err, ErrValidation) || errors.Is(err, ErrDropUnavailable) || errors.Is(err, ErrClientOrderIDReused) ||
+		err.Error() == "user not found" || err.Error() == "item not found"
+}
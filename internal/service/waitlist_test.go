@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"fsanano/go-test/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitlistOutcome(t *testing.T) {
+	assert.True(t, waitlistHeld(fmt.Errorf("purchase: %w", repository.ErrInsufficientStock)))
+	assert.True(t, waitlistHeld(&DropError{Reason: DropReasonSoldOut}))
+	assert.False(t, waitlistHeld(&DropError{Reason: DropReasonPerUserLimit}))
+	assert.True(t, waitlistRefusal(&DropError{Reason: DropReasonPerUserLimit}))
+
+	assert.True(t, waitlistRefusal(repository.ErrInsufficientFunds))
+	assert.True(t, waitlistRefusal(errors.New("user not found")))
+	assert.True(t, waitlistRefusal(&PurchaseLimitError{Rule: RuleSpendPerDay}))
+	assert.False(t, waitlistRefusal(&PurchaseLimitError{Rule: RuleOrdersPerMinute}), "retried on a later run")
+	assert.False(t, waitlistRefusal(repository.ErrRetriesExhausted))
+}
+
+func TestJoinWaitlist_Disabled(t *testing.T) {
+	s := NewShopService(nil, nil)
+	_, err := s.JoinWaitlist(context.Background(), 1, 1, 1)
+	assert.ErrorIs(t, err, ErrWaitlistDisabled)
+	_, err = s.ListWaitlist(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrWaitlistDisabled)
+}
@@ -0,0 +1,183 @@
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCommunityURL = "https://steamcommunity.com"
+	// DefaultInventoryCacheTTL is how long fetched inventories are served when
+	// Config.InventoryCacheTTL is 0
+	DefaultInventoryCacheTTL = 10 * time.Minute
+
+	// CS2AppID and CS2ContextID address Counter-Strike 2 items in Steam inventories
+	CS2AppID     = "730"
+	CS2ContextID = "2"
+
+	// inventoryPageSize is the largest page the inventory endpoint serves
+	inventoryPageSize = 2000
+	// maxInventoryPages bounds pagination against an endpoint that keeps reporting more items
+	maxInventoryPages = 50
+)
+
+// ErrPrivateInventory is returned when the player's inventory is not public
+var ErrPrivateInventory = errors.New("steam inventory is private")
+
+// ErrInventoryUnavailable wraps failures to fetch an inventory from Steam
+var ErrInventoryUnavailable = errors.New("steam inventory is unavailable")
+
+// InventoryItem is a stack of identical items in a Steam inventory
+type InventoryItem struct {
+	AssetID        string `json:"asset_id"`
+	ClassID        string `json:"class_id"`
+	InstanceID     string `json:"instance_id"`
+	Amount         int    `json:"amount"`
+	MarketHashName string `json:"market_hash_name"`
+	Name           string `json:"name"`
+	IconURL        string `json:"icon_url,omitempty"`
+	Tradable       bool   `json:"tradable"`
+	Marketable     bool   `json:"marketable"`
+}
+
+// Inventory is a player's inventory of one app as fetched at FetchedAt
+type Inventory struct {
+	SteamID   string
+	AppID     string
+	Items     []InventoryItem
+	FetchedAt time.Time
+}
+
+// inventoryCache keeps fetched inventories per player and app for a TTL
+type inventoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*Inventory
+}
+
+// inventoryPage is a page of the community inventory endpoint
+type inventoryPage struct {
+	Success int `json:"success"`
+	Assets  []struct {
+		ClassID    string `json:"classid"`
+		InstanceID string `json:"instanceid"`
+		AssetID    string `json:"assetid"`
+		Amount     string `json:"amount"`
+	} `json:"assets"`
+	Descriptions []struct {
+		ClassID        string `json:"classid"`
+		InstanceID     string `json:"instanceid"`
+		MarketHashName string `json:"market_hash_name"`
+		Name           string `json:"name"`
+		IconURL        string `json:"icon_url"`
+		Tradable       int    `json:"tradable"`
+		Marketable     int    `json:"marketable"`
+	} `json:"descriptions"`
+	MoreItems   int    `json:"more_items"`
+	LastAssetID string `json:"last_assetid"`
+}
+
+// Inventory returns the player's inventory of appID (CS2 items live in context 2),
+// served from the cache while younger than Config.InventoryCacheTTL. The result is
+// shared and must not be modified.
+func (c *Client) Inventory(ctx context.Context, steamID, appID, contextID string) (*Inventory, error) {
+	key := steamID + "/" + appID + "/" + contextID
+	c.inventories.mu.Lock()
+	cached, ok := c.inventories.entries[key]
+	c.inventories.mu.Unlock()
+	if ok && time.Since(cached.FetchedAt) < c.cfg.InventoryCacheTTL {
+		return cached, nil
+	}
+
+	inventory, err := c.fetchInventory(ctx, steamID, appID, contextID)
+	if err != nil {
+		return nil, err
+	}
+	c.inventories.mu.Lock()
+	for k, e := range c.inventories.entries {
+		if time.Since(e.FetchedAt) >= c.cfg.InventoryCacheTTL {
+			delete(c.inventories.entries, k)
+		}
+	}
+	c.inventories.entries[key] = inventory
+	c.inventories.mu.Unlock()
+	return inventory, nil
+}
+
+func (c *Client) fetchInventory(ctx context.Context, steamID, appID, contextID string) (*Inventory, error) {
+	inventory := &Inventory{SteamID: steamID, AppID: appID, Items: []InventoryItem{}, FetchedAt: time.Now()}
+	startAssetID := ""
+	for range maxInventoryPages {
+		page, err := c.fetchInventoryPage(ctx, steamID, appID, contextID, startAssetID)
+		if err != nil {
+			if errors.Is(err, ErrPrivateInventory) || ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInventoryUnavailable, err)
+		}
+
+		type classKey struct{ classID, instanceID string }
+		descriptions := make(map[classKey]int, len(page.Descriptions))
+		for i, d := range page.Descriptions {
+			descriptions[classKey{d.ClassID, d.InstanceID}] = i
+		}
+		for _, a := range page.Assets {
+			amount, _ := strconv.Atoi(a.Amount)
+			item := InventoryItem{AssetID: a.AssetID, ClassID: a.ClassID, InstanceID: a.InstanceID, Amount: max(amount, 1)}
+			if i, ok := descriptions[classKey{a.ClassID, a.InstanceID}]; ok {
+				d := page.Descriptions[i]
+				item.MarketHashName, item.Name = d.MarketHashName, d.Name
+				item.Tradable, item.Marketable = d.Tradable == 1, d.Marketable == 1
+				if d.IconURL != "" {
+					item.IconURL = "https://community.cloudflare.steamstatic.com/economy/image/" + d.IconURL
+				}
+			}
+			inventory.Items = append(inventory.Items, item)
+		}
+
+		if page.MoreItems != 1 || page.LastAssetID == "" {
+			return inventory, nil
+		}
+		startAssetID = page.LastAssetID
+	}
+	return nil, fmt.Errorf("%w: inventory of %s has more than %d pages", ErrInventoryUnavailable, steamID, maxInventoryPages)
+}
+
+func (c *Client) fetchInventoryPage(ctx context.Context, steamID, appID, contextID, startAssetID string) (*inventoryPage, error) {
+	query := url.Values{"l": {"english"}, "count": {strconv.Itoa(inventoryPageSize)}}
+	if startAssetID != "" {
+		query.Set("start_assetid", startAssetID)
+	}
+	endpoint := fmt.Sprintf("%s/inventory/%s/%s/%s?%s", c.cfg.CommunityURL,
+		url.PathEscape(steamID), url.PathEscape(appID), url.PathEscape(contextID), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create steam inventory request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch steam inventory: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, ErrPrivateInventory
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("steam inventory request failed with status %d", resp.StatusCode)
+	}
+	var page inventoryPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode steam inventory: %w", err)
+	}
+	if page.Success != 1 {
+		return nil, fmt.Errorf("steam inventory request was not successful")
+	}
+	return &page, nil
+}
@@ -0,0 +1,209 @@
+// Package steam implements Steam's OpenID 2.0 login and the parts of the Steam Web API
+// the shop uses, so users can sign in with their Steam account.
+package steam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOpenIDURL = "https://steamcommunity.com/openid/login"
+	DefaultAPIURL    = "https://api.steampowered.com"
+
+	openIDNamespace      = "http://specs.openid.net/auth/2.0"
+	openIDIdentifierNone = "http://specs.openid.net/auth/2.0/identifier_select"
+	requestTimeout       = 10 * time.Second
+)
+
+// ErrInvalidAssertion is returned for login callbacks Steam does not vouch for: forged,
+// replayed or meant for another site
+var ErrInvalidAssertion = errors.New("invalid steam login")
+
+// claimedIDPattern extracts the SteamID64 from the identity Steam asserts
+var claimedIDPattern = regexp.MustCompile(`^https?://steamcommunity\.com/openid/id/(7656\d{13})$`)
+
+// steamIDPattern matches a SteamID64 of an individual account
+var steamIDPattern = regexp.MustCompile(`^7656\d{13}$`)
+
+// ValidSteamID reports whether id is a SteamID64 of an individual account
+func ValidSteamID(id string) bool {
+	return steamIDPattern.MatchString(id)
+}
+
+type Config struct {
+	// ReturnURL is our login callback Steam redirects back to
+	ReturnURL string
+	// OpenIDURL is Steam's OpenID endpoint, DefaultOpenIDURL when empty
+	OpenIDURL string
+	// APIURL is the Steam Web API, DefaultAPIURL when empty
+	APIURL string
+	// APIKey enables profile lookups; without it profiles are not fetched
+	APIKey string
+	// CommunityURL serves inventories, DefaultCommunityURL when empty
+	CommunityURL string
+	// InventoryCacheTTL is how long fetched inventories are served, DefaultInventoryCacheTTL when 0
+	InventoryCacheTTL time.Duration
+	// HTTPClient defaults to a client with a 10s timeout
+	HTTPClient *http.Client
+}
+
+// Client signs users in through Steam and looks up their profiles and inventories
+type Client struct {
+	cfg         Config
+	http        *http.Client
+	inventories inventoryCache
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.OpenIDURL == "" {
+		cfg.OpenIDURL = DefaultOpenIDURL
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultAPIURL
+	}
+	if cfg.CommunityURL == "" {
+		cfg.CommunityURL = DefaultCommunityURL
+	}
+	if cfg.InventoryCacheTTL <= 0 {
+		cfg.InventoryCacheTTL = DefaultInventoryCacheTTL
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	return &Client{cfg: cfg, http: httpClient, inventories: inventoryCache{entries: map[string]*Inventory{}}}
+}
+
+// LoginURL is where users are sent to sign in; Steam redirects them back to ReturnURL
+func (c *Client) LoginURL() string {
+	query := url.Values{
+		"openid.ns":         {openIDNamespace},
+		"openid.mode":       {"checkid_setup"},
+		"openid.return_to":  {c.cfg.ReturnURL},
+		"openid.realm":      {realm(c.cfg.ReturnURL)},
+		"openid.identity":   {openIDIdentifierNone},
+		"openid.claimed_id": {openIDIdentifierNone},
+	}
+	return c.cfg.OpenIDURL + "?" + query.Encode()
+}
+
+// realm is the scheme and host of the return URL, the site users are asked to trust
+func realm(returnURL string) string {
+	u, err := url.Parse(returnURL)
+	if err != nil {
+		return returnURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Verify checks the query of a login callback with Steam and returns the SteamID64 of
+// the signed-in user. Steam answers each assertion once, so a replayed callback fails.
+func (c *Client) Verify(ctx context.Context, query url.Values) (string, error) {
+	if query.Get("openid.mode") != "id_res" || query.Get("openid.op_endpoint") != c.cfg.OpenIDURL ||
+		!sameEndpoint(query.Get("openid.return_to"), c.cfg.ReturnURL) {
+		return "", ErrInvalidAssertion
+	}
+	match := claimedIDPattern.FindStringSubmatch(query.Get("openid.claimed_id"))
+	if match == nil || query.Get("openid.identity") != query.Get("openid.claimed_id") {
+		return "", ErrInvalidAssertion
+	}
+
+	// Direct verification: Steam checks the signature of the fields it signed
+	check := url.Values{}
+	for key, values := range query {
+		if strings.HasPrefix(key, "openid.") {
+			check[key] = values
+		}
+	}
+	check.Set("openid.mode", "check_authentication")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.OpenIDURL, strings.NewReader(check.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create steam verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify steam login: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read steam verification: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("steam verification failed with status %d", resp.StatusCode)
+	}
+	// The answer is key:value lines
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if string(bytes.TrimSpace(line)) == "is_valid:true" {
+			return match[1], nil
+		}
+	}
+	return "", ErrInvalidAssertion
+}
+
+// sameEndpoint reports whether returnTo points at our callback; Steam may append
+// query parameters
+func sameEndpoint(returnTo, expected string) bool {
+	got, err := url.Parse(returnTo)
+	if err != nil {
+		return false
+	}
+	want, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+	return got.Scheme == want.Scheme && got.Host == want.Host && got.Path == want.Path
+}
+
+// Profile is the public part of a Steam profile
+type Profile struct {
+	SteamID     string `json:"steamid"`
+	PersonaName string `json:"personaname"`
+	ProfileURL  string `json:"profileurl"`
+	AvatarURL   string `json:"avatarfull"`
+}
+
+// Profile returns the player's profile, nil without an API key
+func (c *Client) Profile(ctx context.Context, steamID string) (*Profile, error) {
+	if c.cfg.APIKey == "" {
+		return nil, nil
+	}
+	query := url.Values{"key": {c.cfg.APIKey}, "steamids": {steamID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.cfg.APIURL+"/ISteamUser/GetPlayerSummaries/v2/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create steam profile request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch steam profile: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("steam profile request failed with status %d", resp.StatusCode)
+	}
+
+	var summaries struct {
+		Response struct {
+			Players []Profile `json:"players"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		return nil, fmt.Errorf("failed to decode steam profile: %w", err)
+	}
+	if len(summaries.Response.Players) == 0 {
+		return nil, fmt.Errorf("steam profile %s not found", steamID)
+	}
+	return &summaries.Response.Players[0], nil
+}
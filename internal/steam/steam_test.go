@@ -0,0 +1,142 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSteamID = "76561197960287930"
+
+func TestLoginURL(t *testing.T) {
+	c := NewClient(Config{ReturnURL: "https://shop.example.com/v1/auth/steam/callback"})
+
+	u, err := url.Parse(c.LoginURL())
+	require.NoError(t, err)
+	assert.Equal(t, "steamcommunity.com", u.Host)
+	assert.Equal(t, "checkid_setup", u.Query().Get("openid.mode"))
+	assert.Equal(t, "https://shop.example.com/v1/auth/steam/callback", u.Query().Get("openid.return_to"))
+	assert.Equal(t, "https://shop.example.com", u.Query().Get("openid.realm"))
+}
+
+func TestVerify(t *testing.T) {
+	var checked url.Values
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		checked = r.PostForm
+		if r.PostForm.Get("openid.sig") == "valid" {
+			w.Write([]byte("ns:http://specs.openid.net/auth/2.0\nis_valid:true\n"))
+			return
+		}
+		w.Write([]byte("ns:http://specs.openid.net/auth/2.0\nis_valid:false\n"))
+	}))
+	defer provider.Close()
+
+	returnURL := "https://shop.example.com/v1/auth/steam/callback"
+	c := NewClient(Config{ReturnURL: returnURL, OpenIDURL: provider.URL})
+	callback := func(modify func(url.Values)) url.Values {
+		q := url.Values{
+			"openid.ns":          {openIDNamespace},
+			"openid.mode":        {"id_res"},
+			"openid.op_endpoint": {provider.URL},
+			"openid.claimed_id":  {"https://steamcommunity.com/openid/id/" + testSteamID},
+			"openid.identity":    {"https://steamcommunity.com/openid/id/" + testSteamID},
+			"openid.return_to":   {returnURL},
+			"openid.signed":      {"signed,op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle"},
+			"openid.sig":         {"valid"},
+		}
+		if modify != nil {
+			modify(q)
+		}
+		return q
+	}
+
+	steamID, err := c.Verify(context.Background(), callback(nil))
+	require.NoError(t, err)
+	assert.Equal(t, testSteamID, steamID)
+	assert.Equal(t, "check_authentication", checked.Get("openid.mode"))
+
+	for name, modify := range map[string]func(url.Values){
+		"bad signature":   func(q url.Values) { q.Set("openid.sig", "forged") },
+		"other site":      func(q url.Values) { q.Set("openid.return_to", "https://evil.example.com/v1/auth/steam/callback") },
+		"other provider":  func(q url.Values) { q.Set("openid.op_endpoint", "https://evil.example.com/openid") },
+		"not a steam id":  func(q url.Values) { q.Set("openid.claimed_id", "https://evil.example.com/id/1") },
+		"cancelled login": func(q url.Values) { q.Set("openid.mode", "cancel") },
+	} {
+		_, err := c.Verify(context.Background(), callback(modify))
+		assert.True(t, errors.Is(err, ErrInvalidAssertion), "%s: %v", name, err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ISteamUser/GetPlayerSummaries/v2/", r.URL.Path)
+		assert.Equal(t, "key", r.URL.Query().Get("key"))
+		w.Write([]byte(`{"response":{"players":[{"steamid":"` + r.URL.Query().Get("steamids") +
+			`","personaname":"gaben","profileurl":"https://steamcommunity.com/id/gaben/","avatarfull":"https://avatars.example.com/g.jpg"}]}}`))
+	}))
+	defer api.Close()
+
+	profile, err := NewClient(Config{APIURL: api.URL, APIKey: "key"}).Profile(context.Background(), testSteamID)
+	require.NoError(t, err)
+	assert.Equal(t, Profile{SteamID: testSteamID, PersonaName: "gaben", ProfileURL: "https://steamcommunity.com/id/gaben/",
+		AvatarURL: "https://avatars.example.com/g.jpg"}, *profile)
+
+	profile, err = NewClient(Config{APIURL: api.URL}).Profile(context.Background(), testSteamID)
+	assert.NoError(t, err)
+	assert.Nil(t, profile, "no API key, no lookup")
+
+	assert.True(t, ValidSteamID(testSteamID))
+	assert.False(t, ValidSteamID(strings.Repeat("1", 17)))
+}
+
+func TestInventory(t *testing.T) {
+	requests := 0
+	community := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/inventory/" + testSteamID + "/730/2":
+		case "/inventory/76561197960287931/730/2":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("null"))
+			return
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("start_assetid") == "" {
+			w.Write([]byte(`{"success":1,"more_items":1,"last_assetid":"2",
+				"assets":[{"assetid":"1","classid":"10","instanceid":"0","amount":"1"},{"assetid":"2","classid":"11","instanceid":"0","amount":"1"}],
+				"descriptions":[{"classid":"10","instanceid":"0","market_hash_name":"AK-47 | Redline (Field-Tested)","name":"AK-47 | Redline","tradable":1,"marketable":1,"icon_url":"abc"},
+					{"classid":"11","instanceid":"0","market_hash_name":"Sticker | Crown (Foil)","name":"Sticker | Crown","tradable":0,"marketable":1}]}`))
+			return
+		}
+		assert.Equal(t, "2", r.URL.Query().Get("start_assetid"))
+		w.Write([]byte(`{"success":1,"assets":[{"assetid":"3","classid":"10","instanceid":"0","amount":"1"}],
+			"descriptions":[{"classid":"10","instanceid":"0","market_hash_name":"AK-47 | Redline (Field-Tested)","name":"AK-47 | Redline","tradable":1,"marketable":1}]}`))
+	}))
+	defer community.Close()
+
+	c := NewClient(Config{CommunityURL: community.URL})
+	inventory, err := c.Inventory(context.Background(), testSteamID, CS2AppID, CS2ContextID)
+	require.NoError(t, err)
+	require.Len(t, inventory.Items, 3)
+	assert.Equal(t, "AK-47 | Redline (Field-Tested)", inventory.Items[0].MarketHashName)
+	assert.True(t, inventory.Items[0].Tradable)
+	assert.False(t, inventory.Items[1].Tradable)
+	assert.Equal(t, "3", inventory.Items[2].AssetID)
+	assert.Equal(t, 2, requests)
+
+	_, err = c.Inventory(context.Background(), testSteamID, CS2AppID, CS2ContextID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests, "served from the cache")
+
+	_, err = c.Inventory(context.Background(), "76561197960287931", CS2AppID, CS2ContextID)
+	assert.True(t, errors.Is(err, ErrPrivateInventory), err)
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local stores objects as files below Dir. The content type is kept in a sidecar
+// file next to the object. Signed URLs point at PublicURL, where the application
+// mounts the Local itself as an http.Handler.
+type Local struct {
+	Dir        string
+	PublicURL  string
+	SigningKey string
+	// now is the signing clock (tests)
+	now func() time.Time
+}
+
+var _ Blob = (*Local)(nil)
+
+const contentTypeSuffix = ".content-type"
+
+func (l *Local) Name() string {
+	return "local"
+}
+
+func (l *Local) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(_ context.Context, key string, data []byte, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create storage dir: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never observe a partial object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := os.WriteFile(path+contentTypeSuffix, []byte(contentType), 0o644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write object content type: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(_ context.Context, key string) (*Object, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if data, err := os.ReadFile(path + contentTypeSuffix); err == nil {
+		contentType = string(data)
+	}
+	return &Object{Body: f, ContentType: contentType, Size: info.Size()}, nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{path, path + contentTypeSuffix} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+	return nil
+}
+
+// SignedURL returns <PublicURL>/<key>?expires=<unix>&signature=<hmac>
+func (l *Local) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	if l.SigningKey == "" {
+		return "", fmt.Errorf("signed urls of the local storage need a signing key")
+	}
+	expires := strconv.FormatInt(l.clock().Add(ttl).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {l.sign(key, expires)}}
+	return strings.TrimRight(l.PublicURL, "/") + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+func (l *Local) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func (l *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, []byte(l.SigningKey))
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves objects requested through a signed URL, the request path being the
+// key; mount it with http.StripPrefix at PublicURL
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if l.SigningKey == "" || err != nil || l.clock().Unix() > unix ||
+		!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(l.sign(key, expires))) {
+		http.Error(w, "invalid or expired signature", http.StatusForbidden)
+		return
+	}
+
+	obj, err := l.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to read object", http.StatusInternalServerError)
+		return
+	}
+	defer obj.Body.Close()
+
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(unix-l.clock().Unix(), 0), 10))
+	io.Copy(w, obj.Body)
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"fsanano/go-test/internal/awsv4"
+)
+
+// S3Config configures an S3-compatible bucket (AWS, MinIO, R2, Google Cloud Storage
+// through its XML API and HMAC keys, ...)
+type S3Config struct {
+	// Endpoint defaults to https://s3.<region>.amazonaws.com; objects are addressed
+	// path-style as <endpoint>/<bucket>/<key>
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3 stores objects in an S3 bucket through the REST API, signing requests with
+// AWS Signature Version 4
+type S3 struct {
+	config S3Config
+	Client *http.Client
+	// now is the signing clock (tests)
+	now func() time.Time
+}
+
+var _ Blob = (*S3)(nil)
+
+func NewS3(cfg S3Config) *S3 {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &S3{config: cfg, Client: &http.Client{Timeout: 60 * time.Second}, now: time.Now}
+}
+
+func (s *S3) Name() string {
+	return "s3"
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	u.Path = u.Path + "/" + s.config.Bucket + "/" + key
+	u.RawPath = awsv4.EscapePath(u.Path)
+	return u, nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build s3 request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, awsv4.PayloadHash(body), s.now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// s3Error reads the error response of a failed request
+func s3Error(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s failed with status %d: %s", op, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &Object{Body: resp.Body, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("get", resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 answers 204 whether or not the object existed
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", resp)
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 headers to req
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	s.signer().Sign(req, payloadHash, now)
+}
+
+// maxSignedURLTTL is the longest validity S3 accepts for presigned URLs
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// SignedURL presigns a GET of the object valid for ttl (at most 7 days)
+func (s *S3) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		return "", fmt.Errorf("signed url ttl must be between 1s and %s", maxSignedURLTTL)
+	}
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	s.presign(u, ttl, s.now())
+	return u.String(), nil
+}
+
+// presign adds query string authentication for a GET of u to u
+func (s *S3) presign(u *url.URL, ttl time.Duration, now time.Time) {
+	s.signer().Presign(u, ttl, now)
+}
+
+func (s *S3) signer() awsv4.Signer {
+	return awsv4.Signer{AccessKeyID: s.config.AccessKeyID, SecretAccessKey: s.config.SecretAccessKey, Region: s.config.Region, Service: "s3"}
+}
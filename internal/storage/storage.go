@@ -0,0 +1,83 @@
+// Package storage keeps binary objects (item images, CSV reports, catalog snapshots) in
+// a pluggable blob store: a local directory or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for keys without an object
+var ErrNotFound = errors.New("object not found")
+
+// Object is a stored object being read. Close the body when done.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Blob stores objects under slash-separated keys such as items/12/image-3f2a
+type Blob interface {
+	Name() string
+	// Put creates or replaces the object at key
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the object at key, ErrNotFound if there is none
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object at key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the object at key without further
+	// credentials until ttl has passed
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// Config selects and configures the blob store
+type Config struct {
+	// Backend is "local" or "s3"
+	Backend string
+	// Dir is the root directory of the local backend
+	Dir string
+	// PublicURL is where the local backend is mounted as an http.Handler, the base of its signed URLs
+	PublicURL string
+	// SigningKey signs the local backend's URLs; signed URLs are disabled without it
+	SigningKey string
+	S3         S3Config
+}
+
+// New builds the Blob for cfg.Backend
+func New(cfg Config) (Blob, error) {
+	switch cfg.Backend {
+	case "local":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("storage dir is required")
+		}
+		return &Local{Dir: cfg.Dir, PublicURL: cfg.PublicURL, SigningKey: cfg.SigningKey}, nil
+	case "s3":
+		if cfg.S3.Bucket == "" || cfg.S3.Region == "" {
+			return nil, fmt.Errorf("s3 bucket and region are required")
+		}
+		if cfg.S3.AccessKeyID == "" || cfg.S3.SecretAccessKey == "" {
+			return nil, fmt.Errorf("s3 credentials are required")
+		}
+		return NewS3(cfg.S3), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (want local or s3)", cfg.Backend)
+	}
+}
+
+// validKey rejects keys that could escape the store's root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
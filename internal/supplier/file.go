@@ -0,0 +1,129 @@
+package supplier
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileFeed reads CSV files from Dir, one batch per file named after it. The header
+// holds quantity and item_id or name, optionally reference; lines without a reference
+// are referenced by file and line. Acknowledged files move to Dir/processed, files that
+// cannot be parsed to Dir/failed.
+type FileFeed struct {
+	Dir string
+}
+
+func (f *FileFeed) Name() string {
+	return "file"
+}
+
+func (f *FileFeed) Fetch(ctx context.Context) ([]Batch, error) {
+	names, err := filepath.Glob(filepath.Join(f.Dir, "*.csv"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supplier files: %w", err)
+	}
+	sort.Strings(names)
+
+	batches := make([]Batch, 0, len(names))
+	for _, name := range names {
+		batch, err := readBatchFile(name)
+		if err != nil {
+			slog.WarnContext(ctx, "invalid supplier file", "file", name, "error", err)
+			if err := f.move(filepath.Base(name), "failed"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+func (f *FileFeed) Ack(_ context.Context, batchID string) error {
+	return f.move(batchID, "processed")
+}
+
+// move files the batch's file under dir
+func (f *FileFeed) move(name, dir string) error {
+	target := filepath.Join(f.Dir, dir)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	if err := os.Rename(filepath.Join(f.Dir, name), filepath.Join(target, name)); err != nil {
+		return fmt.Errorf("failed to move supplier file: %w", err)
+	}
+	return nil
+}
+
+func readBatchFile(name string) (Batch, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return Batch{}, err
+	}
+	defer file.Close()
+	batch := Batch{ID: filepath.Base(name)}
+	batch.Restocks, err = parseRestocks(file, batch.ID)
+	return batch, err
+}
+
+// parseRestocks reads a restock CSV; references default to "<batchID>:<line>"
+func parseRestocks(in io.Reader, batchID string) ([]Restock, error) {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasID := cols["item_id"]
+	_, hasName := cols["name"]
+	if _, ok := cols["quantity"]; !ok || (!hasID && !hasName) {
+		return nil, errors.New("csv header must contain quantity and item_id or name columns")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var restocks []Restock
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return restocks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		r := Restock{Reference: field(record, "reference"), ItemName: field(record, "name")}
+		if r.Reference == "" {
+			r.Reference = batchID + ":" + strconv.Itoa(line)
+		}
+		if id := field(record, "item_id"); id != "" {
+			if r.ItemID, err = strconv.Atoi(id); err != nil {
+				return nil, fmt.Errorf("line %d: invalid item_id %q", line, id)
+			}
+		}
+		quantity := field(record, "quantity")
+		if r.Quantity, err = strconv.Atoi(quantity); err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, quantity)
+		}
+		restocks = append(restocks, r)
+	}
+}
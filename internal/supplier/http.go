@@ -0,0 +1,86 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTPFeed polls a supplier API. GET URL answers {"batches": [{"id", "restocks": [...]}]}
+// with the batches not acknowledged yet, POST URL/{id}/ack acknowledges one. Restocks
+// without a reference are referenced by batch and position.
+type HTTPFeed struct {
+	URL      string
+	APIToken string
+	Client   *http.Client
+}
+
+type httpFeedResponse struct {
+	Batches []Batch `json:"batches"`
+}
+
+func (f *HTTPFeed) Name() string {
+	return "http"
+}
+
+func (f *HTTPFeed) Fetch(ctx context.Context) ([]Batch, error) {
+	body, err := f.do(ctx, http.MethodGet, f.URL)
+	if err != nil {
+		return nil, err
+	}
+	var resp httpFeedResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode supplier batches: %w", err)
+	}
+	for _, batch := range resp.Batches {
+		if batch.ID == "" {
+			return nil, fmt.Errorf("supplier batch without an id")
+		}
+		for i := range batch.Restocks {
+			if batch.Restocks[i].Reference == "" {
+				batch.Restocks[i].Reference = batch.ID + ":" + strconv.Itoa(i+1)
+			}
+		}
+	}
+	return resp.Batches, nil
+}
+
+func (f *HTTPFeed) Ack(ctx context.Context, batchID string) error {
+	_, err := f.do(ctx, http.MethodPost, strings.TrimSuffix(f.URL, "/")+"/"+url.PathEscape(batchID)+"/ack")
+	return err
+}
+
+func (f *HTTPFeed) do(ctx context.Context, method, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create supplier request: %w", err)
+	}
+	if f.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.APIToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("supplier request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supplier response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("supplier returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,65 @@
+// Package supplier reads restocks from pluggable supplier feeds: files dropped in a
+// directory, or a supplier's HTTP API.
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Restock is one line of a delivery: Quantity more units of an item, identified by
+// ItemID or, when it is 0, by its ItemName. Reference identifies the line across
+// deliveries, so a line delivered twice is applied once.
+type Restock struct {
+	Reference string `json:"reference"`
+	ItemID    int    `json:"item_id"`
+	ItemName  string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Batch is one delivery of a feed (a file, an API batch), applied as a whole
+type Batch struct {
+	ID       string    `json:"id"`
+	Restocks []Restock `json:"restocks"`
+}
+
+// Feed delivers restock batches. Fetch returns the batches not acknowledged yet; Ack
+// is called once a batch was applied, so it is not fetched again.
+type Feed interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Batch, error)
+	Ack(ctx context.Context, batchID string) error
+}
+
+// Config selects and configures the feed
+type Config struct {
+	// Feed is "file" or "http"; empty disables restocks from a supplier
+	Feed string
+	// Dir is where the file feed finds its CSV files
+	Dir string
+	// URL and APIToken reach the supplier's API
+	URL      string
+	APIToken string
+}
+
+// NewFeed builds the Feed for cfg.Feed, nil when cfg.Feed is empty
+func NewFeed(cfg Config) (Feed, error) {
+	switch cfg.Feed {
+	case "":
+		return nil, nil
+	case "file":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("supplier feed directory is required")
+		}
+		return &FileFeed{Dir: cfg.Dir}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("supplier feed url is required")
+		}
+		return &HTTPFeed{URL: cfg.URL, APIToken: cfg.APIToken, Client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unknown supplier feed %q (want file or http)", cfg.Feed)
+	}
+}
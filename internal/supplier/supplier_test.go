@@ -0,0 +1,95 @@
+package supplier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFeed(t *testing.T) {
+	feed, err := NewFeed(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, feed, "disabled")
+
+	feed, err = NewFeed(Config{Feed: "file", Dir: t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, "file", feed.Name())
+
+	_, err = NewFeed(Config{Feed: "http"})
+	assert.Error(t, err, "http needs a url")
+	_, err = NewFeed(Config{Feed: "ftp"})
+	assert.Error(t, err)
+}
+
+func TestFileFeed(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("b.csv", "reference,item_id,name,quantity\nPO-7,3,,10\n,,Sword,2\n")
+	write("a.csv", "item_id,quantity\n1,5\n")
+	write("broken.csv", "item_id,quantity\n1,many\n")
+	write("notes.txt", "ignored")
+	feed := &FileFeed{Dir: dir}
+
+	batches, err := feed.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Batch{
+		{ID: "a.csv", Restocks: []Restock{{Reference: "a.csv:2", ItemID: 1, Quantity: 5}}},
+		{ID: "b.csv", Restocks: []Restock{
+			{Reference: "PO-7", ItemID: 3, Quantity: 10},
+			{Reference: "b.csv:3", ItemName: "Sword", Quantity: 2},
+		}},
+	}, batches)
+	assert.FileExists(t, filepath.Join(dir, "failed", "broken.csv"))
+
+	require.NoError(t, feed.Ack(context.Background(), "a.csv"))
+	assert.FileExists(t, filepath.Join(dir, "processed", "a.csv"))
+	batches, err = feed.Fetch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "b.csv", batches[0].ID)
+}
+
+func TestParseRestocks_Header(t *testing.T) {
+	_, err := parseRestocks(strings.NewReader("reference,quantity\nx,1\n"), "f")
+	assert.EqualError(t, err, "csv header must contain quantity and item_id or name columns")
+}
+
+func TestHTTPFeed(t *testing.T) {
+	var acked []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/restocks":
+			json.NewEncoder(w).Encode(map[string]any{"batches": []map[string]any{
+				{"id": "B1", "restocks": []map[string]any{{"item_id": 3, "quantity": 4}, {"reference": "L2", "name": "Sword", "quantity": 1}}},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/restocks/B1/ack":
+			acked = append(acked, "B1")
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	feed := &HTTPFeed{URL: ts.URL + "/restocks", APIToken: "token"}
+
+	batches, err := feed.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Batch{{ID: "B1", Restocks: []Restock{
+		{Reference: "B1:1", ItemID: 3, Quantity: 4},
+		{Reference: "L2", ItemName: "Sword", Quantity: 1},
+	}}}, batches)
+
+	require.NoError(t, feed.Ack(context.Background(), "B1"))
+	assert.Equal(t, []string{"B1"}, acked)
+	assert.ErrorContains(t, feed.Ack(context.Background(), "B2"), "404")
+}
@@ -0,0 +1,132 @@
+// Package tax computes the tax charged on purchases through pluggable calculators.
+package tax
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"fsanano/go-test/internal/model"
+)
+
+// Request is a purchase to tax. Amount is what the buyer pays before tax, after
+// quantity tiers and promo discounts.
+type Request struct {
+	UserID   int
+	ItemID   int
+	Quantity int
+	Amount   model.Money
+	// Region is the buyer's tax region, empty when unknown
+	Region string
+}
+
+// Tax is the tax charged on a purchase
+type Tax struct {
+	// Rate is a fraction, 0.2 is 20%
+	Rate   float64
+	Amount model.Money
+}
+
+// Calculator computes the tax of a purchase. Calculators run inside the purchase
+// transaction and must not call out to slow services.
+type Calculator interface {
+	Name() string
+	Calculate(ctx context.Context, req Request) (Tax, error)
+}
+
+// apply charges rate on amount, rounded to the currency's minor unit
+func apply(rate float64, amount model.Money) Tax {
+	return Tax{Rate: rate, Amount: amount.Scale(rate)}
+}
+
+// FlatRate charges the same rate on every purchase
+type FlatRate struct {
+	Rate float64
+}
+
+func (c *FlatRate) Name() string { return "flat" }
+
+func (c *FlatRate) Calculate(_ context.Context, req Request) (Tax, error) {
+	return apply(c.Rate, req.Amount), nil
+}
+
+// RegionRates charges the rate of the buyer's region, Default for regions without one
+type RegionRates struct {
+	// Rates maps upper-case region codes (DE, US-CA, ...) to rates
+	Rates   map[string]float64
+	Default float64
+}
+
+func (c *RegionRates) Name() string { return "region" }
+
+func (c *RegionRates) Calculate(_ context.Context, req Request) (Tax, error) {
+	rate, ok := c.Rates[strings.ToUpper(req.Region)]
+	if !ok {
+		rate = c.Default
+	}
+	return apply(rate, req.Amount), nil
+}
+
+// Config selects and configures the tax calculator
+type Config struct {
+	// Calculator is "none", "flat" or "region"
+	Calculator string
+	// Rate is the flat rate, or the default rate of the region calculator
+	Rate float64
+	// RegionRates lists region rates as "DE=0.19,US-CA=0.0725"
+	RegionRates string
+}
+
+// NewCalculator builds the Calculator for cfg.Calculator, nil for "none"
+func NewCalculator(cfg Config) (Calculator, error) {
+	if err := validateRate(cfg.Rate); err != nil {
+		return nil, err
+	}
+	switch cfg.Calculator {
+	case "", "none":
+		return nil, nil
+	case "flat":
+		return &FlatRate{Rate: cfg.Rate}, nil
+	case "region":
+		rates, err := ParseRegionRates(cfg.RegionRates)
+		if err != nil {
+			return nil, err
+		}
+		return &RegionRates{Rates: rates, Default: cfg.Rate}, nil
+	default:
+		return nil, fmt.Errorf("unknown tax calculator %q (want none, flat or region)", cfg.Calculator)
+	}
+}
+
+// ParseRegionRates parses "DE=0.19,US-CA=0.0725" into upper-case region codes and rates
+func ParseRegionRates(s string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		region, value, ok := strings.Cut(pair, "=")
+		region = strings.ToUpper(strings.TrimSpace(region))
+		if !ok || region == "" {
+			return nil, fmt.Errorf("invalid tax region rate %q (want REGION=rate)", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tax rate for region %s: %w", region, err)
+		}
+		if err := validateRate(rate); err != nil {
+			return nil, fmt.Errorf("region %s: %w", region, err)
+		}
+		rates[region] = rate
+	}
+	return rates, nil
+}
+
+func validateRate(rate float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate >= 1 {
+		return fmt.Errorf("tax rate %v must be in [0, 1)", rate)
+	}
+	return nil
+}
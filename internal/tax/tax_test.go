@@ -0,0 +1,55 @@
+package tax
+
+import (
+	"context"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCalculator(t *testing.T) {
+	c, err := NewCalculator(Config{Calculator: "none"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = NewCalculator(Config{Calculator: "flat", Rate: 0.2})
+	require.NoError(t, err)
+	assert.Equal(t, "flat", c.Name())
+
+	c, err = NewCalculator(Config{Calculator: "region", RegionRates: "de=0.19, US-CA=0.0725"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"DE": 0.19, "US-CA": 0.0725}, c.(*RegionRates).Rates)
+
+	_, err = NewCalculator(Config{Calculator: "flat", Rate: 1.5})
+	assert.Error(t, err)
+	_, err = NewCalculator(Config{Calculator: "region", RegionRates: "DE"})
+	assert.Error(t, err)
+	_, err = NewCalculator(Config{Calculator: "region", RegionRates: "DE=-0.1"})
+	assert.Error(t, err)
+	_, err = NewCalculator(Config{Calculator: "vat"})
+	assert.Error(t, err)
+}
+
+func TestCalculate(t *testing.T) {
+	ctx := context.Background()
+
+	got, err := (&FlatRate{Rate: 0.2}).Calculate(ctx, Request{Amount: model.Money{Amount: 1234}})
+	require.NoError(t, err)
+	assert.Equal(t, Tax{Rate: 0.2, Amount: model.Money{Amount: 247}}, got, "amounts are rounded to cents")
+
+	got, err = (&FlatRate{Rate: 0.1}).Calculate(ctx, Request{Amount: model.Money{Amount: 1234, Currency: "JPY"}})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money{Amount: 123, Currency: "JPY"}, got.Amount, "and to yen for JPY")
+
+	regions := &RegionRates{Rates: map[string]float64{"DE": 0.19}, Default: 0.05}
+	got, err = regions.Calculate(ctx, Request{Amount: model.Money{Amount: 10000}, Region: "de"})
+	require.NoError(t, err)
+	assert.Equal(t, Tax{Rate: 0.19, Amount: model.Money{Amount: 1900}}, got)
+
+	got, err = regions.Calculate(ctx, Request{Amount: model.Money{Amount: 10000}})
+	require.NoError(t, err)
+	assert.Equal(t, Tax{Rate: 0.05, Amount: model.Money{Amount: 500}}, got, "unknown regions pay the default rate")
+}
@@ -0,0 +1,375 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsanano/go-test/internal/repository"
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/service/skinport"
+)
+
+const (
+	// buyConfirmTTL is how long a /buy waits for its /confirm
+	buyConfirmTTL   = 2 * time.Minute
+	maxPriceResults = 5
+)
+
+const helpText = `Commands:
+/link <token> - link this chat to your shop account
+/unlink - unlink this chat
+/price <item> - current Skinport prices
+/favorites - your favorite Skinport items
+/balance - your balance
+/buy <item_id> [quantity] [promo_code] - buy a shop item
+/confirm, /cancel - confirm or cancel a pending purchase
+
+Price alerts and purchase receipts are sent here once the chat is linked.`
+
+const notLinkedText = "This chat is not linked to a shop account. Ask for a link token and send /link <token>."
+
+// Bot answers the commands of Telegram users, acting as the user linked to the chat.
+// Only private chats are served, so nobody else can act on a linked account.
+type Bot struct {
+	client      *Client
+	links       *service.TelegramService
+	shop        *service.ShopService
+	favorites   *service.FavoriteService
+	skinport    *skinport.Client
+	pollTimeout time.Duration
+
+	mu sync.Mutex
+	// pending holds the purchases awaiting /confirm, by chat
+	pending map[int64]pendingBuy
+}
+
+type pendingBuy struct {
+	params   service.BuyParams
+	itemName string
+	expires  time.Time
+}
+
+func NewBot(client *Client, links *service.TelegramService, shop *service.ShopService, favorites *service.FavoriteService,
+	skinportClient *skinport.Client, pollTimeout time.Duration) *Bot {
+	return &Bot{
+		client:      client,
+		links:       links,
+		shop:        shop,
+		favorites:   favorites,
+		skinport:    skinportClient,
+		pollTimeout: pollTimeout,
+		pending:     make(map[int64]pendingBuy),
+	}
+}
+
+// Run long-polls for messages and answers them until ctx is cancelled. The Bot API
+// serves updates to one poller at a time, so a single instance must run per bot token.
+func (b *Bot) Run(ctx context.Context) error {
+	var offset int64
+	backoff := time.Second
+	for {
+		updates, err := b.client.GetUpdates(ctx, offset, b.pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			slog.Warn("telegram poll failed", "error", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+			b.handleMessage(ctx, u.Message)
+		}
+	}
+}
+
+func (b *Bot) handleMessage(ctx context.Context, msg *Message) {
+	chatID := msg.Chat.ID
+	reply := "Please talk to me in a private chat."
+	if msg.Chat.Type == "private" {
+		reply = b.reply(ctx, chatID, msg.Text)
+	}
+	if err := b.client.SendText(ctx, chatID, reply); err != nil {
+		slog.Warn("telegram reply failed", "chat_id", chatID, "error", err)
+	}
+}
+
+// reply runs the command in text and returns the answer
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) string {
+	command, args := parseCommand(text)
+	switch command {
+	case "start":
+		// Deep links (t.me/<bot>?start=<token>) carry the link token
+		if args != "" {
+			return b.link(ctx, chatID, args)
+		}
+		return helpText
+	case "help":
+		return helpText
+	case "link":
+		return b.link(ctx, chatID, args)
+	case "price":
+		return b.price(ctx, args)
+	}
+
+	userID, err := b.links.UserIDForChat(ctx, chatID)
+	if err != nil {
+		if err.Error() == "telegram chat not linked" {
+			return notLinkedText
+		}
+		return errorText(ctx, err)
+	}
+
+	switch command {
+	case "unlink":
+		b.clearPending(chatID)
+		if err := b.links.Unlink(ctx, chatID); err != nil {
+			return errorText(ctx, err)
+		}
+		return "This chat is unlinked. You will no longer get notifications here."
+	case "balance":
+		user, err := b.shop.GetUser(ctx, userID)
+		if err != nil {
+			return errorText(ctx, err)
+		}
+		return fmt.Sprintf("Your balance is %s.", user.Balance)
+	case "favorites":
+		return b.listFavorites(ctx, userID)
+	case "buy":
+		return b.prepareBuy(ctx, chatID, userID, args)
+	case "confirm":
+		return b.confirmBuy(ctx, chatID, userID)
+	case "cancel":
+		if !b.clearPending(chatID) {
+			return "There is no pending purchase."
+		}
+		return "Purchase cancelled."
+	default:
+		return "Unknown command. Send /help for the list of commands."
+	}
+}
+
+// parseCommand splits "/cmd@bot args" into the lowercased command and its arguments.
+// Text that is not a command yields an empty command.
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	command, args, _ := strings.Cut(text[1:], " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(args)
+}
+
+func (b *Bot) link(ctx context.Context, chatID int64, token string) string {
+	userID, err := b.links.Link(ctx, token, chatID)
+	if err != nil {
+		return errorText(ctx, err)
+	}
+	b.clearPending(chatID)
+	user, err := b.shop.GetUser(ctx, userID)
+	if err != nil {
+		return errorText(ctx, err)
+	}
+	return fmt.Sprintf("Hi %s, this chat is now linked to your account. Send /help to see what I can do.", user.FirstName)
+}
+
+func (b *Bot) price(ctx context.Context, query string) string {
+	if query == "" {
+		return "Usage: /price <item name>"
+	}
+	items, err := b.skinport.GetAllItems(ctx, "", "")
+	if err != nil {
+		slog.WarnContext(ctx, "telegram price lookup failed", "error", err)
+		return "Skinport prices are unavailable right now, please try again later."
+	}
+
+	matches, total := findListings(items, query, maxPriceResults)
+	if total == 0 {
+		return fmt.Sprintf("No Skinport listing matches %q.", query)
+	}
+	var sb strings.Builder
+	for _, item := range matches {
+		sb.WriteString(formatListing(item))
+		sb.WriteString("\n")
+	}
+	if total > len(matches) {
+		fmt.Fprintf(&sb, "…and %d more, refine your search.", total-len(matches))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// findListings returns up to limit items whose name contains query, case-insensitively,
+// and how many matched. An exact name match is the only result.
+func findListings(items []skinport.ResponseItem, query string, limit int) ([]skinport.ResponseItem, int) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []skinport.ResponseItem
+	total := 0
+	for _, item := range items {
+		name := strings.ToLower(item.MarketHashName)
+		if name == query {
+			return []skinport.ResponseItem{item}, 1
+		}
+		if strings.Contains(name, query) {
+			total++
+			if len(matches) < limit {
+				matches = append(matches, item)
+			}
+		}
+	}
+	return matches, total
+}
+
+func formatListing(item skinport.ResponseItem) string {
+	return fmt.Sprintf("%s: tradable %s, non-tradable %s %s (%d listed)", item.MarketHashName,
+		formatPrice(item.MinPriceTradable), formatPrice(item.MinPriceNonTradable), item.Currency, item.Quantity)
+}
+
+func formatPrice(price *float64) string {
+	if price == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*price, 'f', 2, 64)
+}
+
+func (b *Bot) listFavorites(ctx context.Context, userID int) string {
+	favorites, err := b.favorites.ListFavorites(ctx, userID, "", "")
+	if err != nil {
+		return errorText(ctx, err)
+	}
+	if len(favorites) == 0 {
+		return "You have no favorite items yet."
+	}
+
+	var sb strings.Builder
+	for _, f := range favorites {
+		if f.Listed {
+			fmt.Fprintf(&sb, "%s: tradable %s, non-tradable %s %s", f.MarketHashName,
+				formatPrice(f.MinPriceTradable), formatPrice(f.MinPriceNonTradable), f.Currency)
+		} else {
+			fmt.Fprintf(&sb, "%s: no listing", f.MarketHashName)
+		}
+		if f.AlertBelow != nil {
+			fmt.Fprintf(&sb, " (alert below %.2f)", *f.AlertBelow)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// parseBuyArgs parses "<item_id> [quantity] [promo_code]"
+func parseBuyArgs(args string) (service.BuyParams, bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 3 {
+		return service.BuyParams{}, false
+	}
+	p := service.BuyParams{Quantity: 1}
+	var err error
+	if p.ItemID, err = strconv.Atoi(fields[0]); err != nil || p.ItemID <= 0 {
+		return service.BuyParams{}, false
+	}
+	if len(fields) > 1 {
+		if p.Quantity, err = strconv.Atoi(fields[1]); err != nil || p.Quantity <= 0 {
+			return service.BuyParams{}, false
+		}
+	}
+	if len(fields) > 2 {
+		p.PromoCode = fields[2]
+	}
+	return p, true
+}
+
+// prepareBuy records the purchase and asks for its confirmation, so a typo cannot
+// spend the user's balance
+func (b *Bot) prepareBuy(ctx context.Context, chatID int64, userID int, args string) string {
+	p, ok := parseBuyArgs(args)
+	if !ok {
+		return "Usage: /buy <item_id> [quantity] [promo_code]"
+	}
+	p.UserID = userID
+
+	item, err := b.shop.GetItem(ctx, p.ItemID)
+	if err != nil {
+		return errorText(ctx, err)
+	}
+
+	b.mu.Lock()
+	b.pending[chatID] = pendingBuy{params: p, itemName: item.Name, expires: time.Now().Add(buyConfirmTTL)}
+	b.mu.Unlock()
+
+	reply := fmt.Sprintf("Buy %d × %s for %s", p.Quantity, item.Name, item.Price.Mul(p.Quantity))
+	if p.PromoCode != "" {
+		reply += fmt.Sprintf(" before promo code %s", p.PromoCode)
+	}
+	return reply + fmt.Sprintf("? Send /confirm within %s, or /cancel.", buyConfirmTTL)
+}
+
+func (b *Bot) confirmBuy(ctx context.Context, chatID int64, userID int) string {
+	b.mu.Lock()
+	pending, ok := b.pending[chatID]
+	delete(b.pending, chatID)
+	b.mu.Unlock()
+
+	// A purchase prepared for another account (the chat was relinked) is dropped
+	if !ok || time.Now().After(pending.expires) || pending.params.UserID != userID {
+		return "There is no pending purchase, send /buy first."
+	}
+
+	order, err := b.shop.BuyItem(ctx, pending.params)
+	if err != nil {
+		return errorText(ctx, err)
+	}
+	reply := fmt.Sprintf("Order #%d placed: %d × %s for %s.", order.ID, order.Quantity, pending.itemName, order.Price)
+	if order.Discount.Amount > 0 {
+		reply += fmt.Sprintf(" You saved %s.", order.Discount)
+	}
+	return reply
+}
+
+// clearPending drops the chat's pending purchase and reports whether there was one
+func (b *Bot) clearPending(chatID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.pending[chatID]
+	delete(b.pending, chatID)
+	return ok
+}
+
+// errorText is the answer for a failed command: what the user can act on is shown,
+// anything else is logged
+func errorText(ctx context.Context, err error) string {
+	var limitErr *service.PurchaseLimitError
+	switch {
+	case errors.Is(err, service.ErrValidation), errors.Is(err, service.ErrInvalidPromoCode),
+		errors.Is(err, repository.ErrInsufficientFunds), errors.Is(err, repository.ErrInsufficientStock),
+		errors.Is(err, repository.ErrUserInactive), errors.Is(err, service.ErrDropUnavailable):
+		return "Sorry, " + err.Error() + "."
+	case errors.As(err, &limitErr):
+		return fmt.Sprintf("Sorry, this purchase exceeds the %s limit.", limitErr.Rule)
+	case errors.Is(err, repository.ErrRetriesExhausted), errors.Is(err, service.ErrPurchaseQueueFull):
+		return "The shop is busy right now, please try again."
+	}
+	switch err.Error() {
+	case "item not found", "user not found", "invalid or expired link token", "telegram chat not linked":
+		return "Sorry, " + err.Error() + "."
+	}
+	slog.ErrorContext(ctx, "telegram command failed", "error", err)
+	return "Something went wrong, please try again later."
+}
@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"testing"
+
+	"fsanano/go-test/internal/service"
+	"fsanano/go-test/internal/service/skinport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text, command, args string
+	}{
+		{"/help", "help", ""},
+		{"/Price@ShopBot  AK-47 | Redline ", "price", "AK-47 | Redline"},
+		{"  /start abc123", "start", "abc123"},
+		{"hello", "", ""},
+	}
+	for _, tt := range tests {
+		command, args := parseCommand(tt.text)
+		assert.Equal(t, tt.command, command, tt.text)
+		assert.Equal(t, tt.args, args, tt.text)
+	}
+}
+
+func TestParseBuyArgs(t *testing.T) {
+	p, ok := parseBuyArgs("3")
+	assert.True(t, ok)
+	assert.Equal(t, service.BuyParams{ItemID: 3, Quantity: 1}, p)
+
+	p, ok = parseBuyArgs("3 2 WINTER10")
+	assert.True(t, ok)
+	assert.Equal(t, service.BuyParams{ItemID: 3, Quantity: 2, PromoCode: "WINTER10"}, p)
+
+	for _, args := range []string{"", "x", "3 0", "0", "3 -1", "3 2 CODE extra"} {
+		_, ok := parseBuyArgs(args)
+		assert.False(t, ok, args)
+	}
+}
+
+func TestFindListings(t *testing.T) {
+	items := []skinport.ResponseItem{
+		{MarketHashName: "AK-47 | Redline (Field-Tested)"},
+		{MarketHashName: "AK-47 | Redline (Minimal Wear)"},
+		{MarketHashName: "AK-47 | Slate (Field-Tested)"},
+		{MarketHashName: "AWP | Asiimov (Field-Tested)"},
+	}
+
+	matches, total := findListings(items, "ak-47 | redline", 5)
+	assert.Equal(t, 2, total)
+	assert.Len(t, matches, 2)
+
+	matches, total = findListings(items, "field-tested", 2)
+	assert.Equal(t, 3, total)
+	assert.Len(t, matches, 2, "limited")
+
+	matches, total = findListings(items, "ak-47 | redline (minimal wear)", 5)
+	assert.Equal(t, 1, total, "exact match only")
+	assert.Equal(t, "AK-47 | Redline (Minimal Wear)", matches[0].MarketHashName)
+
+	_, total = findListings(items, "m4a4", 5)
+	assert.Zero(t, total)
+}
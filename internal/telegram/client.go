@@ -0,0 +1,133 @@
+// Package telegram implements a minimal Telegram Bot API client and the shop bot, which
+// lets users with a linked chat check Skinport prices and buy items.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultAPIURL = "https://api.telegram.org"
+	// maxMessageLength is the longest text sendMessage accepts
+	maxMessageLength = 4096
+	requestTimeout   = 30 * time.Second
+)
+
+// Client calls the Telegram Bot API
+type Client struct {
+	token  string
+	apiURL string
+	http   *http.Client
+}
+
+// NewClient creates a client for the bot token; an empty apiURL uses the public API
+func NewClient(token, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	// Requests are bounded by their context, long polls outlive any fixed client timeout
+	return &Client{token: token, apiURL: apiURL, http: &http.Client{}}
+}
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	Chat      Chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// APIError is an error answered by the Bot API
+type APIError struct {
+	Code        int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram api error %d: %s", e.Code, e.Description)
+}
+
+type apiResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result"`
+	APIError
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any, timeout time.Duration) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"/bot"+c.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// The URL carries the token, do not let it reach the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var res apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("failed to decode %s response (%s): %w", method, resp.Status, err)
+	}
+	if !res.OK {
+		return &res.APIError
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// GetUpdates long-polls for updates after offset, waiting up to timeout for one
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	params := map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}
+	var updates []Update
+	if err := c.call(ctx, "getUpdates", params, &updates, timeout+requestTimeout); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendText sends a plain-text message to the chat, truncated to the Bot API limit
+func (c *Client) SendText(ctx context.Context, chatID int64, text string) error {
+	if runes := []rune(text); len(runes) > maxMessageLength {
+		text = string(runes[:maxMessageLength-1]) + "…"
+	}
+	params := map[string]any{"chat_id": chatID, "text": text}
+	return c.call(ctx, "sendMessage", params, nil, requestTimeout)
+}
@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/botsecret/getUpdates":
+			var params map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+			assert.EqualValues(t, 10, params["offset"])
+			assert.EqualValues(t, 1, params["timeout"])
+			w.Write([]byte(`{"ok":true,"result":[{"update_id":10,"message":{"message_id":1,"chat":{"id":7,"type":"private"},"text":"/help"}}]}`))
+		case "/botsecret/sendMessage":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+			if sent["chat_id"] == float64(404) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"result":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := NewClient("secret", server.URL)
+	ctx := context.Background()
+
+	updates, err := client.GetUpdates(ctx, 10, time.Second)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	assert.Equal(t, int64(7), updates[0].Message.Chat.ID)
+	assert.Equal(t, "/help", updates[0].Message.Text)
+
+	require.NoError(t, client.SendText(ctx, 7, strings.Repeat("é", maxMessageLength+10)))
+	assert.Len(t, []rune(sent["text"].(string)), maxMessageLength, "long messages are truncated")
+
+	err = client.SendText(ctx, 404, "hi")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 400, apiErr.Code)
+}
+
+func TestClient_ErrorHidesToken(t *testing.T) {
+	client := NewClient("secret", "http://127.0.0.1:1")
+	err := client.SendText(context.Background(), 7, "hi")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
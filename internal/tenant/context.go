@@ -0,0 +1,22 @@
+// Package tenant carries the shop a request is served for, so repositories can scope
+// their connections to it without knowing about HTTP.
+package tenant
+
+import "context"
+
+// DefaultID is the shop existing data and unscoped writes belong to
+const DefaultID = 1
+
+type idKey struct{}
+
+// WithID scopes the operations run with ctx to the tenant
+func WithID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// IDFrom returns the tenant stored in ctx; ok is false for unscoped operations such as
+// background jobs, which see every tenant
+func IDFrom(ctx context.Context) (id int, ok bool) {
+	id, ok = ctx.Value(idKey{}).(int)
+	return id, ok && id > 0
+}
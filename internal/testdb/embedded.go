@@ -0,0 +1,71 @@
+//go:build embeddedpg
+
+package testdb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+var (
+	server     *embeddedpostgres.EmbeddedPostgres
+	runtimeDir string
+)
+
+// startEmbedded launches a throwaway server on a free port. Every test binary gets
+// its own, go test runs packages in parallel.
+func startEmbedded() (string, error) {
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+
+	runtimeDir, err = os.MkdirTemp("", "testdb-pg-")
+	if err != nil {
+		return "", err
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Version(embeddedpostgres.V16).
+		Port(port).
+		Database("shop_test").
+		RuntimePath(runtimeDir).
+		DataPath(filepath.Join(runtimeDir, "data")).
+		StartTimeout(time.Minute).
+		Logger(io.Discard)
+	if dir := os.Getenv("EMBEDDED_PG_CACHE"); dir != "" {
+		// Downloaded binaries are reused from here, useful for offline CI
+		cfg = cfg.CachePath(dir)
+	}
+
+	server = embeddedpostgres.NewDatabase(cfg)
+	if err := server.Start(); err != nil {
+		server = nil
+		os.RemoveAll(runtimeDir)
+		return "", fmt.Errorf("failed to start embedded postgres: %w", err)
+	}
+	return cfg.GetConnectionURL() + "?sslmode=disable", nil
+}
+
+func stopEmbedded() error {
+	if server == nil {
+		return nil
+	}
+	defer os.RemoveAll(runtimeDir)
+	return server.Stop()
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
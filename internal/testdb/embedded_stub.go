@@ -0,0 +1,8 @@
+//go:build !embeddedpg
+
+package testdb
+
+// Without the embeddedpg tag only DATABASE_URL is used
+func startEmbedded() (string, error) { return "", nil }
+
+func stopEmbedded() error { return nil }
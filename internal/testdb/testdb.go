@@ -0,0 +1,147 @@
+// Package testdb provides Postgres connections for integration tests.
+//
+// Tests use DATABASE_URL when it is set (the repo's .env is loaded first). Built
+// with -tags embeddedpg, an embedded Postgres is started instead when DATABASE_URL
+// is empty, so the tests run without Docker or an external database.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"fsanano/go-test/internal/model"
+	"fsanano/go-test/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+	"github.com/pressly/goose/v3"
+)
+
+var (
+	once   sync.Once
+	dbURL  string
+	urlErr error
+)
+
+// URL returns the connection string tests should use, or "" when no database is
+// available. The embedded server is started and migrated on first use.
+func URL() (string, error) {
+	once.Do(func() {
+		if root, err := moduleRoot(); err == nil {
+			_ = godotenv.Load(filepath.Join(root, ".env"))
+		}
+		if dbURL = os.Getenv("DATABASE_URL"); dbURL != "" {
+			return
+		}
+		if dbURL, urlErr = startEmbedded(); urlErr != nil || dbURL == "" {
+			return
+		}
+		urlErr = migrate(dbURL)
+	})
+	return dbURL, urlErr
+}
+
+// New connects to the test database and truncates the given tables, skipping the
+// test when no database is available
+func New(t testing.TB, truncate ...string) *pgxpool.Pool {
+	t.Helper()
+	return NewWith(t, nil, truncate...)
+}
+
+// NewWith is New with configure applied to the pool's config, after the defaults
+func NewWith(t testing.TB, configure func(*pgxpool.Config) error, truncate ...string) *pgxpool.Pool {
+	t.Helper()
+
+	url, err := URL()
+	if err != nil {
+		t.Fatalf("test database: %v", err)
+	}
+	if url == "" {
+		t.Skip("no database: set DATABASE_URL or run with -tags embeddedpg")
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("Unable to parse database URL: %v", err)
+	}
+	config.AfterConnect = model.RegisterPgTypes
+	if configure != nil {
+		if err := configure(config); err != nil {
+			t.Fatalf("Unable to configure pool: %v", err)
+		}
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Unable to connect to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := pool.Ping(context.Background()); err != nil {
+		t.Fatalf("Unable to ping database: %v", err)
+	}
+
+	Truncate(t, pool, truncate...)
+	return pool
+}
+
+// Truncate empties the tables and resets their identity sequences
+func Truncate(t testing.TB, pool *pgxpool.Pool, tables ...string) {
+	t.Helper()
+
+	for _, table := range tables {
+		if _, err := pool.Exec(context.Background(), fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", table)); err != nil {
+			t.Fatalf("Failed to truncate table %s: %v", table, err)
+		}
+	}
+}
+
+// Main runs the package's tests and stops the embedded server afterwards. Packages
+// using this package call it from TestMain: os.Exit(testdb.Main(m))
+func Main(m *testing.M) int {
+	code := m.Run()
+	if err := stopEmbedded(); err != nil {
+		fmt.Fprintf(os.Stderr, "testdb: stop embedded postgres: %v\n", err)
+	}
+	return code
+}
+
+func migrate(url string) error {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if _, err := provider.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return nil
+}
+
+// moduleRoot finds the directory holding go.mod, test binaries run in their package directory
+func moduleRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
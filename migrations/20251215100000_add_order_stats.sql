@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at);
+
+-- Pre-aggregated daily sales per item, refreshed by the stats refresher job
+CREATE MATERIALIZED VIEW IF NOT EXISTS order_stats_daily AS
+SELECT
+    date_trunc('day', created_at) AS day,
+    item_id,
+    COUNT(*) AS order_count,
+    SUM(quantity) AS quantity,
+    SUM(price) AS revenue
+FROM orders
+GROUP BY 1, 2;
+
+-- Required by REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_stats_daily_day_item ON order_stats_daily (day, item_id);
+
+-- +goose Down
+DROP MATERIALIZED VIEW IF EXISTS order_stats_daily;
+DROP INDEX IF EXISTS idx_orders_created_at;
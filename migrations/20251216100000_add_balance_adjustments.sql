@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    delta DECIMAL(10, 2) NOT NULL,
+    reason TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_user_id ON balance_adjustments (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS balance_adjustments;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Supports per-user purchase limit checks
+CREATE INDEX IF NOT EXISTS idx_orders_user_created_at ON orders (user_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_user_created_at;
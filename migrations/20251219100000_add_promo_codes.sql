@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    discount_type TEXT NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+    discount_value DECIMAL(10, 2) NOT NULL CHECK (discount_value > 0),
+    max_uses INT,
+    used_count INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (discount_type <> 'percentage' OR discount_value <= 100)
+);
+
+-- Items a promo code is restricted to; no rows means it applies to every item
+CREATE TABLE IF NOT EXISTS promo_code_items (
+    promo_code_id INT NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    PRIMARY KEY (promo_code_id, item_id)
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code_id INT REFERENCES promo_codes(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS promo_code_id;
+DROP TABLE IF EXISTS promo_code_items;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS item_tags (
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_tags_tag_id ON item_tags (tag_id);
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_items_category_id ON items (category_id);
+
+-- +goose Down
+ALTER TABLE items DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS item_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Transactional outbox of order lifecycle events, written in the same transaction as the change
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id INT NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_user_id ON order_events (user_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_events;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Existing orders were charged when created, so they start out paid
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'paid'
+    CHECK (status IN ('pending', 'paid', 'fulfilled', 'refunded', 'cancelled'));
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS fulfilled_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+UPDATE orders SET paid_at = created_at WHERE status = 'paid' AND paid_at IS NULL;
+
+-- Refunded and cancelled orders no longer count as sales
+DROP MATERIALIZED VIEW IF EXISTS order_stats_daily;
+CREATE MATERIALIZED VIEW order_stats_daily AS
+SELECT
+    date_trunc('day', created_at) AS day,
+    item_id,
+    COUNT(*) AS order_count,
+    SUM(quantity) AS quantity,
+    SUM(price) AS revenue
+FROM orders
+WHERE status NOT IN ('refunded', 'cancelled')
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_stats_daily_day_item ON order_stats_daily (day, item_id);
+
+-- +goose Down
+DROP MATERIALIZED VIEW IF EXISTS order_stats_daily;
+CREATE MATERIALIZED VIEW order_stats_daily AS
+SELECT
+    date_trunc('day', created_at) AS day,
+    item_id,
+    COUNT(*) AS order_count,
+    SUM(quantity) AS quantity,
+    SUM(price) AS revenue
+FROM orders
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_stats_daily_day_item ON order_stats_daily (day, item_id);
+
+ALTER TABLE orders DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS fulfilled_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS paid_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Last run of each exclusive background job, used to coordinate instances
+CREATE TABLE IF NOT EXISTS job_runs (
+    name TEXT PRIMARY KEY,
+    last_started_at TIMESTAMP NOT NULL,
+    last_finished_at TIMESTAMP,
+    last_error TEXT
+);
+
+-- Periodic copies of item prices and stock, taken by the price_snapshot job
+CREATE TABLE IF NOT EXISTS item_price_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    price DECIMAL(10, 2) NOT NULL,
+    stock INT NOT NULL,
+    taken_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_price_snapshots_item_taken ON item_price_snapshots (item_id, taken_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS item_price_snapshots;
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Quantities of shop items owned by users: granted by purchases, moved by transfers
+CREATE TABLE IF NOT EXISTS inventories (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    quantity INT NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, item_id)
+);
+
+-- Everything bought so far, refunded and cancelled orders gave their items back
+INSERT INTO inventories (user_id, item_id, quantity)
+SELECT user_id, item_id, SUM(quantity)
+FROM orders
+WHERE status NOT IN ('refunded', 'cancelled') AND user_id IS NOT NULL AND item_id IS NOT NULL
+GROUP BY user_id, item_id
+ON CONFLICT (user_id, item_id) DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS inventories;
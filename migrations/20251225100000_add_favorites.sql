@@ -0,0 +1,11 @@
+-- +goose Up
+-- Skinport items users follow, by market_hash_name
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    market_hash_name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, market_hash_name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS favorites;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Where notifications are emailed, users without one get none
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT;
+
+-- Receipts are sent by a background worker; orders placed before it existed get none
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS receipt_sent_at TIMESTAMP;
+UPDATE orders SET receipt_sent_at = created_at WHERE receipt_sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_orders_receipt_pending ON orders (id) WHERE receipt_sent_at IS NULL;
+
+-- Price alerts fire when a favorite is listed below alert_below, at most once per cooldown
+ALTER TABLE favorites ADD COLUMN IF NOT EXISTS alert_below DECIMAL(10, 2);
+ALTER TABLE favorites ADD COLUMN IF NOT EXISTS alerted_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE favorites DROP COLUMN IF EXISTS alerted_at;
+ALTER TABLE favorites DROP COLUMN IF EXISTS alert_below;
+DROP INDEX IF EXISTS idx_orders_receipt_pending;
+ALTER TABLE orders DROP COLUMN IF EXISTS receipt_sent_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Telegram chats linked to users; a user has at most one chat and a chat one user
+CREATE TABLE IF NOT EXISTS telegram_links (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    chat_id BIGINT NOT NULL UNIQUE,
+    linked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- One-time tokens a user sends to the bot to link their chat, stored hashed
+CREATE TABLE IF NOT EXISTS telegram_link_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS telegram_link_tokens;
+DROP TABLE IF EXISTS telegram_links;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Withdrawals debit the balance immediately and are paid out by a background worker
+CREATE TABLE IF NOT EXISTS payouts (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    destination TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    provider TEXT,
+    -- reference is the provider's id of the transfer
+    reference TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_user_id ON payouts (user_id);
+CREATE INDEX IF NOT EXISTS idx_payouts_pending ON payouts (id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS payouts;
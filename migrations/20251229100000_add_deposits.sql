@@ -0,0 +1,20 @@
+-- +goose Up
+-- Deposits are paid through Stripe Checkout and credited by its webhook, or by the
+-- reconciliation job when the webhook was missed
+CREATE TABLE IF NOT EXISTS deposits (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'expired', 'failed')),
+    session_id TEXT UNIQUE,
+    payment_intent_id TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    credited_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits (user_id);
+CREATE INDEX IF NOT EXISTS idx_deposits_pending ON deposits (id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS deposits;
@@ -0,0 +1,144 @@
+-- +goose Up
+-- Double-entry ledger: money moves in transactions whose entries sum to zero. Every user
+-- has an account; system accounts (sales, deposits, payouts, ...) are the counterparts.
+-- users.balance is the materialized sum of the user's entries, maintained by a trigger
+-- and never updated directly.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE REFERENCES users(id),
+    code VARCHAR(50) UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (code IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    -- What the money moved for, e.g. order:12 or deposit:3
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES ledger_transactions(id),
+    account_id INT NOT NULL REFERENCES ledger_accounts(id),
+    -- Positive amounts credit the account, negative ones debit it
+    amount DECIMAL(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries (account_id);
+
+INSERT INTO ledger_accounts (code) VALUES ('opening'), ('sales'), ('deposits'), ('payouts'), ('adjustments');
+INSERT INTO ledger_accounts (user_id) SELECT id FROM users;
+
+-- Balances predating the ledger are opened against the opening account
+WITH opened AS (
+    INSERT INTO ledger_transactions (kind, reference)
+    SELECT 'opening', 'user:' || id FROM users WHERE balance <> 0
+    RETURNING id, reference
+)
+INSERT INTO ledger_entries (transaction_id, account_id, amount)
+SELECT o.id, a.id, u.balance
+FROM opened o
+JOIN users u ON o.reference = 'user:' || u.id
+JOIN ledger_accounts a ON a.user_id = u.id
+UNION ALL
+SELECT o.id, (SELECT id FROM ledger_accounts WHERE code = 'opening'), -u.balance
+FROM opened o
+JOIN users u ON o.reference = 'user:' || u.id;
+
+-- +goose StatementBegin
+-- ledger_account returns the id of a system account, creating it on first use
+CREATE OR REPLACE FUNCTION ledger_account(account_code TEXT) RETURNS INT AS $$
+DECLARE
+    result INT;
+BEGIN
+    SELECT id INTO result FROM ledger_accounts WHERE code = account_code;
+    IF result IS NULL THEN
+        INSERT INTO ledger_accounts (code) VALUES (account_code) ON CONFLICT (code) DO NOTHING RETURNING id INTO result;
+        IF result IS NULL THEN
+            SELECT id INTO result FROM ledger_accounts WHERE code = account_code;
+        END IF;
+    END IF;
+    RETURN result;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Entries of user accounts are materialized into users.balance
+CREATE OR REPLACE FUNCTION ledger_apply_entry() RETURNS trigger AS $$
+BEGIN
+    UPDATE users u SET balance = u.balance + NEW.amount
+    FROM ledger_accounts a
+    WHERE a.id = NEW.account_id AND u.id = a.user_id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_apply AFTER INSERT ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_apply_entry();
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are append-only, post a correcting transaction instead';
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER ledger_entries_append_only BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_append_only();
+
+-- +goose StatementBegin
+-- New users get an account; their initial balance is posted against the opening account,
+-- the entry materializes it again
+CREATE OR REPLACE FUNCTION ledger_open_user_account() RETURNS trigger AS $$
+DECLARE
+    account INT;
+    tx BIGINT;
+BEGIN
+    INSERT INTO ledger_accounts (user_id) VALUES (NEW.id) RETURNING id INTO account;
+    IF NEW.balance <> 0 THEN
+        UPDATE users SET balance = 0 WHERE id = NEW.id;
+        INSERT INTO ledger_transactions (kind, reference) VALUES ('opening', 'user:' || NEW.id) RETURNING id INTO tx;
+        INSERT INTO ledger_entries (transaction_id, account_id, amount)
+        VALUES (tx, account, NEW.balance), (tx, ledger_account('opening'), -NEW.balance);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER users_open_ledger_account AFTER INSERT ON users
+    FOR EACH ROW EXECUTE FUNCTION ledger_open_user_account();
+
+-- +goose StatementBegin
+-- Only the ledger triggers may change a balance
+CREATE OR REPLACE FUNCTION users_balance_guard() RETURNS trigger AS $$
+BEGIN
+    IF NEW.balance IS DISTINCT FROM OLD.balance AND pg_trigger_depth() < 2 THEN
+        RAISE EXCEPTION 'users.balance is maintained by the ledger, post ledger entries instead';
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER users_balance_guard BEFORE UPDATE OF balance ON users
+    FOR EACH ROW EXECUTE FUNCTION users_balance_guard();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS users_balance_guard ON users;
+DROP TRIGGER IF EXISTS users_open_ledger_account ON users;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS users_balance_guard();
+DROP FUNCTION IF EXISTS ledger_open_user_account();
+DROP FUNCTION IF EXISTS ledger_entries_append_only();
+DROP FUNCTION IF EXISTS ledger_apply_entry();
+DROP FUNCTION IF EXISTS ledger_account(TEXT);
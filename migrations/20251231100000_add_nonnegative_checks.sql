@@ -0,0 +1,9 @@
+-- +goose Up
+-- Last line of defence behind the application's checks: a purchase or balance change
+-- that would overdraw a user or oversell an item fails instead of being committed
+ALTER TABLE users ADD CONSTRAINT users_balance_nonnegative CHECK (balance >= 0);
+ALTER TABLE items ADD CONSTRAINT items_stock_nonnegative CHECK (stock >= 0);
+
+-- +goose Down
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_stock_nonnegative;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_balance_nonnegative;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Clients may key a purchase with their own id, unique per user: a retried purchase
+-- returns the order created by the first attempt instead of buying again
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS client_order_id VARCHAR(64);
+ALTER TABLE orders ADD CONSTRAINT orders_user_client_order_id_key UNIQUE (user_id, client_order_id);
+
+-- +goose Down
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_user_client_order_id_key;
+ALTER TABLE orders DROP COLUMN IF EXISTS client_order_id;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Quantity discounts: buying at least min_qty of an item charges unit_price per unit.
+-- The tier with the highest min_qty not above the quantity applies, items.price otherwise.
+CREATE TABLE IF NOT EXISTS price_tiers (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    min_qty INT NOT NULL CHECK (min_qty >= 1),
+    unit_price DECIMAL(10, 2) NOT NULL CHECK (unit_price >= 0),
+    UNIQUE (item_id, min_qty)
+);
+
+-- The unit price the order was charged before promo discounts
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS unit_price DECIMAL(10, 2);
+UPDATE orders SET unit_price = ROUND((price + discount) / quantity, 2) WHERE quantity > 0;
+
+-- +goose StatementBegin
+-- item_unit_price returns the per-unit price of quantity units of an item listed at
+-- price: the tier with the highest min_qty not above the quantity, price without one.
+-- Callers pass the price read from the locked item row.
+CREATE OR REPLACE FUNCTION item_unit_price(item INT, price NUMERIC, quantity INT) RETURNS NUMERIC AS $$
+    SELECT COALESCE((
+        SELECT unit_price FROM price_tiers
+        WHERE item_id = item AND min_qty <= quantity
+        ORDER BY min_qty DESC
+        LIMIT 1), price)
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION IF EXISTS item_unit_price(INT, NUMERIC, INT);
+ALTER TABLE orders DROP COLUMN IF EXISTS unit_price;
+DROP TABLE IF EXISTS price_tiers;
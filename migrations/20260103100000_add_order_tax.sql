@@ -0,0 +1,11 @@
+-- +goose Up
+-- Purchases may be taxed: the buyer's region picks the rate of region-based calculators,
+-- orders record the rate and amount, which are included in orders.price
+ALTER TABLE users ADD COLUMN IF NOT EXISTS region VARCHAR(16);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(6, 4) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE users DROP COLUMN IF EXISTS region;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Item details for storefronts: a description, an image and free-form attributes
+-- (rarity, exterior, ...). image_key is the blob storage key of an uploaded image,
+-- image_url where clients load the image from.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS image_url VARCHAR(1024);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS image_key VARCHAR(255);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE items DROP COLUMN IF EXISTS attributes;
+ALTER TABLE items DROP COLUMN IF EXISTS image_key;
+ALTER TABLE items DROP COLUMN IF EXISTS image_url;
+ALTER TABLE items DROP COLUMN IF EXISTS description;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Shop items priced from Skinport: the price sync worker sets the item's price from the
+-- lowest Skinport listing of market_hash_name, with markup_percent overriding the
+-- configured markup when set
+CREATE TABLE IF NOT EXISTS skinport_price_mappings (
+    item_id INT PRIMARY KEY REFERENCES items(id) ON DELETE CASCADE,
+    market_hash_name TEXT NOT NULL,
+    markup_percent DECIMAL(6, 2) CHECK (markup_percent > -100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Every price set by the sync; reference_price is the Skinport price it was derived from
+CREATE TABLE IF NOT EXISTS price_changes (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    old_price DECIMAL(10, 2) NOT NULL,
+    new_price DECIMAL(10, 2) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    reference_price DECIMAL(10, 2),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_price_changes_item_created ON price_changes(item_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS price_changes;
+DROP TABLE IF EXISTS skinport_price_mappings;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Sync prices that move more than the approval threshold are proposed as pending
+-- changes for an admin to approve or reject. At most one change per item is pending:
+-- later syncs update its proposed price, an applied change supersedes it.
+ALTER TABLE price_changes ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'applied'
+    CHECK (status IN ('applied', 'pending', 'rejected', 'superseded'));
+ALTER TABLE price_changes ADD COLUMN IF NOT EXISTS decided_at TIMESTAMP;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_changes_pending ON price_changes(item_id) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_price_changes_pending;
+ALTER TABLE price_changes DROP COLUMN IF EXISTS decided_at;
+ALTER TABLE price_changes DROP COLUMN IF EXISTS status;
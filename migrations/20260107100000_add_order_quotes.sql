@@ -0,0 +1,8 @@
+-- +goose Up
+-- Orders bought at a quoted price reference the quote, a quote buys at most once
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS quote_id TEXT;
+ALTER TABLE orders ADD CONSTRAINT orders_quote_id_key UNIQUE (quote_id);
+
+-- +goose Down
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_quote_id_key;
+ALTER TABLE orders DROP COLUMN IF EXISTS quote_id;
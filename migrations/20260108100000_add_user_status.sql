@@ -0,0 +1,12 @@
+-- +goose Up
+-- Deactivated users keep their history but cannot buy. Deleted users are anonymized:
+-- their personal data is erased while orders, payouts and ledger entries are kept.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'deactivated', 'deleted'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
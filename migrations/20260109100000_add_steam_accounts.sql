@@ -0,0 +1,14 @@
+-- +goose Up
+-- Steam accounts users sign in with, a user has at most one and an account one user
+CREATE TABLE IF NOT EXISTS steam_accounts (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    steam_id TEXT NOT NULL CONSTRAINT steam_accounts_steam_id_key UNIQUE,
+    persona_name TEXT,
+    profile_url TEXT,
+    avatar_url TEXT,
+    linked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS steam_accounts;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Leaderboards rebuilt by the leaderboard job, so reads never aggregate orders. period
+-- is daily (the last 24 hours), weekly (the last 7 days) or all_time.
+CREATE TABLE IF NOT EXISTS leaderboard_buyers (
+    period TEXT NOT NULL,
+    rank INT NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    order_count INT NOT NULL,
+    items_bought INT NOT NULL,
+    spent DECIMAL(12, 2) NOT NULL,
+    PRIMARY KEY (period, rank)
+);
+
+CREATE TABLE IF NOT EXISTS leaderboard_items (
+    period TEXT NOT NULL,
+    rank INT NOT NULL,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    order_count INT NOT NULL,
+    quantity INT NOT NULL,
+    revenue DECIMAL(12, 2) NOT NULL,
+    PRIMARY KEY (period, rank)
+);
+
+-- When each period was last rebuilt and the window it covers
+CREATE TABLE IF NOT EXISTS leaderboard_refreshes (
+    period TEXT PRIMARY KEY,
+    since TIMESTAMP,
+    refreshed_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS leaderboard_refreshes;
+DROP TABLE IF EXISTS leaderboard_items;
+DROP TABLE IF EXISTS leaderboard_buyers;
@@ -0,0 +1,153 @@
+-- +goose Up
+-- Shops served by one deployment. Users, items and orders belong to one shop each;
+-- existing rows and rows created outside any shop go to the default one.
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'Default') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants));
+
+-- The application scopes a connection to a shop by setting app.tenant_id; unset, as in
+-- background jobs, every shop is visible
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION current_tenant_id() RETURNS INT
+LANGUAGE sql STABLE AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::int
+$$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION tenant_visible(tenant_id INT) RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT current_tenant_id() IS NULL OR tenant_id = current_tenant_id()
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_items_tenant ON items(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_orders_tenant ON orders(tenant_id);
+
+-- Leaderboards are ranked per shop
+DELETE FROM leaderboard_buyers;
+DELETE FROM leaderboard_items;
+DELETE FROM leaderboard_refreshes;
+ALTER TABLE leaderboard_buyers ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL REFERENCES tenants(id);
+ALTER TABLE leaderboard_buyers DROP CONSTRAINT IF EXISTS leaderboard_buyers_pkey;
+ALTER TABLE leaderboard_buyers ADD PRIMARY KEY (tenant_id, period, rank);
+ALTER TABLE leaderboard_items ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL REFERENCES tenants(id);
+ALTER TABLE leaderboard_items DROP CONSTRAINT IF EXISTS leaderboard_items_pkey;
+ALTER TABLE leaderboard_items ADD PRIMARY KEY (tenant_id, period, rank);
+
+-- Row level security keeps a scoped connection to its shop's rows. Superusers bypass it,
+-- so scoped connections also switch to shop_tenant, which the application's user must be
+-- able to SET ROLE to.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON users USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+ALTER TABLE items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE items FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON items USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON orders USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+ALTER TABLE leaderboard_buyers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE leaderboard_buyers FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON leaderboard_buyers USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+ALTER TABLE leaderboard_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE leaderboard_items FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON leaderboard_items USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'shop_tenant') THEN
+        CREATE ROLE shop_tenant NOLOGIN;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+GRANT shop_tenant TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO shop_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO shop_tenant;
+GRANT USAGE, SELECT, UPDATE ON ALL SEQUENCES IN SCHEMA public TO shop_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO shop_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT, UPDATE ON SEQUENCES TO shop_tenant;
+
+-- Materialized views have no row level security, readers filter on tenant_id
+DROP MATERIALIZED VIEW IF EXISTS order_stats_daily;
+CREATE MATERIALIZED VIEW order_stats_daily AS
+SELECT
+    date_trunc('day', created_at) AS day,
+    tenant_id,
+    item_id,
+    COUNT(*) AS order_count,
+    SUM(quantity) AS quantity,
+    SUM(price) AS revenue
+FROM orders
+WHERE status NOT IN ('refunded', 'cancelled')
+GROUP BY 1, 2, 3;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_stats_daily_day_item ON order_stats_daily (day, tenant_id, item_id);
+GRANT SELECT ON order_stats_daily TO shop_tenant;
+
+-- +goose Down
+DROP MATERIALIZED VIEW IF EXISTS order_stats_daily;
+CREATE MATERIALIZED VIEW order_stats_daily AS
+SELECT
+    date_trunc('day', created_at) AS day,
+    item_id,
+    COUNT(*) AS order_count,
+    SUM(quantity) AS quantity,
+    SUM(price) AS revenue
+FROM orders
+WHERE status NOT IN ('refunded', 'cancelled')
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_stats_daily_day_item ON order_stats_daily (day, item_id);
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON SEQUENCES FROM shop_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON TABLES FROM shop_tenant;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM shop_tenant;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM shop_tenant;
+REVOKE USAGE ON SCHEMA public FROM shop_tenant;
+
+DROP POLICY IF EXISTS tenant_isolation ON leaderboard_items;
+ALTER TABLE leaderboard_items NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE leaderboard_items DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON leaderboard_buyers;
+ALTER TABLE leaderboard_buyers NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE leaderboard_buyers DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON orders;
+ALTER TABLE orders NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE orders DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON items;
+ALTER TABLE items NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE items DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+DELETE FROM leaderboard_items;
+DELETE FROM leaderboard_buyers;
+DELETE FROM leaderboard_refreshes;
+ALTER TABLE leaderboard_items DROP CONSTRAINT IF EXISTS leaderboard_items_pkey;
+ALTER TABLE leaderboard_items DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE leaderboard_items ADD PRIMARY KEY (period, rank);
+ALTER TABLE leaderboard_buyers DROP CONSTRAINT IF EXISTS leaderboard_buyers_pkey;
+ALTER TABLE leaderboard_buyers DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE leaderboard_buyers ADD PRIMARY KEY (period, rank);
+
+ALTER TABLE orders DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE items DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+
+DROP FUNCTION IF EXISTS tenant_visible(INT);
+DROP FUNCTION IF EXISTS current_tenant_id();
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Skinport credentials of shops that use their own API key rather than the deployment's.
+-- Both values are encrypted by the application with internal/crypto (ENCRYPTION_KEYS).
+-- Values sealed with the former SECRETS_KEY are re-encrypted by `admin encryption rotate`.
+CREATE TABLE IF NOT EXISTS tenant_skinport_credentials (
+    tenant_id INT PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    client_id BYTEA NOT NULL,
+    api_key BYTEA NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS tenant_skinport_credentials;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE balance_adjustments ADD COLUMN IF NOT EXISTS reason_code TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE balance_adjustments DROP COLUMN IF EXISTS reason_code;
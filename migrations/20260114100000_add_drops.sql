@@ -0,0 +1,24 @@
+-- +goose Up
+-- Limited releases of an item: from starts_at until ends_at (or for good) its purchases
+-- come out of a pool of stock, with a cap per user. Orders bought in a drop reference it.
+CREATE TABLE IF NOT EXISTS drops (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id),
+    name TEXT NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    stock INT NOT NULL CHECK (stock > 0),
+    per_user_limit INT NOT NULL CHECK (per_user_limit > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT drops_window CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_drops_item_id ON drops (item_id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS drop_id INT REFERENCES drops(id);
+CREATE INDEX IF NOT EXISTS idx_orders_drop_id ON orders (drop_id, user_id) WHERE drop_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_drop_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS drop_id;
+DROP TABLE IF EXISTS drops;
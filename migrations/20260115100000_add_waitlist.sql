@@ -0,0 +1,22 @@
+-- +goose Up
+-- Users waiting for an out-of-stock item. Once the item is restocked the waitlist worker
+-- buys for the entries in arrival order and closes them: fulfilled with the order, or
+-- failed with the reason the purchase was refused.
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    item_id INT NOT NULL REFERENCES items(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'fulfilled', 'failed', 'cancelled')),
+    order_id INT REFERENCES orders(id),
+    failure_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP
+);
+
+-- A user waits at most once per item
+CREATE UNIQUE INDEX IF NOT EXISTS idx_waitlist_entries_waiting ON waitlist_entries (user_id, item_id) WHERE status = 'waiting';
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_item ON waitlist_entries (item_id, id) WHERE status = 'waiting';
+
+-- +goose Down
+DROP TABLE IF EXISTS waitlist_entries;
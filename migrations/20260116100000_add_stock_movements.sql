@@ -0,0 +1,19 @@
+-- +goose Up
+-- Changes of an item's stock with their origin. Restocks from a supplier feed are keyed
+-- by the feed and the delivery line's reference, so a line delivered twice is applied once.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id),
+    quantity INT NOT NULL,
+    reason TEXT NOT NULL,
+    source TEXT NOT NULL,
+    reference TEXT NOT NULL,
+    stock_after INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT stock_movements_reference UNIQUE (source, reference)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_item ON stock_movements (item_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS stock_movements;
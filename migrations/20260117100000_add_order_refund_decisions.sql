@@ -0,0 +1,7 @@
+-- +goose Up
+-- The refund policy's verdict on the order's last refund request, and the admin's
+-- reason when it was overridden
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_decision JSONB;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN IF EXISTS refund_decision;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Payouts are marked sending and committed before the provider is called, so no
+-- transaction stays open during the call. sending_at lets the worker send again payouts
+-- left sending by an instance that stopped before recording the outcome.
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS sending_at TIMESTAMP;
+ALTER TABLE payouts DROP CONSTRAINT IF EXISTS payouts_status_check;
+ALTER TABLE payouts ADD CONSTRAINT payouts_status_check
+    CHECK (status IN ('pending', 'sending', 'completed', 'failed'));
+CREATE INDEX IF NOT EXISTS idx_payouts_sending ON payouts (sending_at) WHERE status = 'sending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_payouts_sending;
+UPDATE payouts SET status = 'pending' WHERE status = 'sending';
+ALTER TABLE payouts DROP CONSTRAINT IF EXISTS payouts_status_check;
+ALTER TABLE payouts ADD CONSTRAINT payouts_status_check
+    CHECK (status IN ('pending', 'completed', 'failed'));
+ALTER TABLE payouts DROP COLUMN IF EXISTS sending_at;
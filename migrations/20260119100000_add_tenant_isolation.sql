@@ -0,0 +1,200 @@
+-- +goose Up
+-- Every table owned by a shop gets a tenant_id and the tenant_isolation policy, not only
+-- users, items and orders. Rows belonging to another row (a user's payouts, an item's
+-- price tiers, ...) are in the shop of that row: created on a scoped connection they get
+-- its shop, created outside any shop, as by background jobs, they inherit it.
+
+-- +goose StatementBegin
+-- inherit_tenant_id fills in tenant_id of a row created outside any shop from the row it
+-- belongs to. Its arguments are the parent table and the column referencing it; rows
+-- without a parent go to the default shop.
+CREATE OR REPLACE FUNCTION inherit_tenant_id() RETURNS trigger AS $$
+DECLARE
+    parent_id TEXT;
+BEGIN
+    IF NEW.tenant_id IS NULL THEN
+        parent_id := to_jsonb(NEW) ->> TG_ARGV[1];
+        IF parent_id IS NOT NULL THEN
+            EXECUTE format('SELECT tenant_id FROM %s WHERE id = $1', TG_ARGV[0])
+                INTO NEW.tenant_id USING parent_id::bigint;
+        END IF;
+        NEW.tenant_id := COALESCE(NEW.tenant_id, 1);
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- isolate_tenant_rows scopes a table with a tenant_id column to the connection's shop
+CREATE OR REPLACE FUNCTION isolate_tenant_rows(tbl REGCLASS) RETURNS VOID AS $$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', tbl);
+    EXECUTE format('ALTER TABLE %s FORCE ROW LEVEL SECURITY', tbl);
+    EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', tbl);
+    EXECUTE format('CREATE POLICY tenant_isolation ON %s USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id))', tbl);
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- add_inherited_tenant adds tenant_id to a table whose rows belong to a row of parent,
+-- referenced by fk, copies it from the parents and isolates the table
+CREATE OR REPLACE FUNCTION add_inherited_tenant(tbl REGCLASS, parent REGCLASS, fk TEXT) RETURNS VOID AS $$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ADD COLUMN IF NOT EXISTS tenant_id INT REFERENCES tenants(id)', tbl);
+    EXECUTE format('UPDATE %s c SET tenant_id = p.tenant_id FROM %s p WHERE p.id = c.%I', tbl, parent, fk);
+    EXECUTE format('UPDATE %s SET tenant_id = 1 WHERE tenant_id IS NULL', tbl);
+    EXECUTE format('ALTER TABLE %s ALTER COLUMN tenant_id SET DEFAULT current_tenant_id()', tbl);
+    EXECUTE format('ALTER TABLE %s ALTER COLUMN tenant_id SET NOT NULL', tbl);
+    EXECUTE format('DROP TRIGGER IF EXISTS inherit_tenant_id ON %s', tbl);
+    EXECUTE format('CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON %s FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id(%L, %L)',
+        tbl, parent::text, fk);
+    PERFORM isolate_tenant_rows(tbl);
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Orders placed outside any shop, as by the waitlist worker, belong to the buyer's shop
+ALTER TABLE orders ALTER COLUMN tenant_id SET DEFAULT current_tenant_id();
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON orders
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id');
+
+SELECT add_inherited_tenant('balance_adjustments', 'users', 'user_id');
+SELECT add_inherited_tenant('inventories', 'users', 'user_id');
+SELECT add_inherited_tenant('favorites', 'users', 'user_id');
+SELECT add_inherited_tenant('telegram_links', 'users', 'user_id');
+SELECT add_inherited_tenant('telegram_link_tokens', 'users', 'user_id');
+SELECT add_inherited_tenant('payouts', 'users', 'user_id');
+SELECT add_inherited_tenant('deposits', 'users', 'user_id');
+SELECT add_inherited_tenant('steam_accounts', 'users', 'user_id');
+SELECT add_inherited_tenant('order_events', 'orders', 'order_id');
+SELECT add_inherited_tenant('item_tags', 'items', 'item_id');
+SELECT add_inherited_tenant('item_price_snapshots', 'items', 'item_id');
+SELECT add_inherited_tenant('price_tiers', 'items', 'item_id');
+SELECT add_inherited_tenant('skinport_price_mappings', 'items', 'item_id');
+SELECT add_inherited_tenant('price_changes', 'items', 'item_id');
+
+-- Promo codes, categories and tags are defined per shop, their names only need to be
+-- unique within it
+ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+ALTER TABLE promo_codes DROP CONSTRAINT IF EXISTS promo_codes_code_key;
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_tenant_code_key UNIQUE (tenant_id, code);
+SELECT isolate_tenant_rows('promo_codes');
+SELECT add_inherited_tenant('promo_code_items', 'promo_codes', 'promo_code_id');
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_slug_key;
+ALTER TABLE categories ADD CONSTRAINT categories_tenant_slug_key UNIQUE (tenant_id, slug);
+SELECT isolate_tenant_rows('categories');
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_tenant_name_key UNIQUE (tenant_id, name);
+SELECT isolate_tenant_rows('tags');
+
+-- The audit log of a shop holds what was done on a scoped connection; entries recorded
+-- outside any shop go to the default one, like users and items
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS tenant_id INT NOT NULL DEFAULT COALESCE(current_tenant_id(), 1) REFERENCES tenants(id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant ON audit_log (tenant_id, id);
+SELECT isolate_tenant_rows('audit_log');
+
+SELECT isolate_tenant_rows('tenant_skinport_credentials');
+
+-- Ledger: user accounts are in their user's shop, system accounts (sales, payouts, ...)
+-- have no shop and are shared, their entries are not. A transaction is in the shop of
+-- the user whose balance it moves; the queries posting them set it.
+ALTER TABLE ledger_accounts ADD COLUMN IF NOT EXISTS tenant_id INT REFERENCES tenants(id);
+UPDATE ledger_accounts a SET tenant_id = u.tenant_id FROM users u WHERE u.id = a.user_id;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_tenant CHECK ((user_id IS NULL) = (tenant_id IS NULL));
+ALTER TABLE ledger_accounts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ledger_accounts FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON ledger_accounts
+    USING (user_id IS NULL OR tenant_visible(tenant_id)) WITH CHECK (user_id IS NULL OR tenant_visible(tenant_id));
+
+ALTER TABLE ledger_transactions ADD COLUMN IF NOT EXISTS tenant_id INT REFERENCES tenants(id);
+UPDATE ledger_transactions t SET tenant_id = a.tenant_id
+FROM ledger_entries e JOIN ledger_accounts a ON a.id = e.account_id
+WHERE e.transaction_id = t.id AND a.tenant_id IS NOT NULL;
+UPDATE ledger_transactions SET tenant_id = 1 WHERE tenant_id IS NULL;
+ALTER TABLE ledger_transactions ALTER COLUMN tenant_id SET DEFAULT COALESCE(current_tenant_id(), 1);
+ALTER TABLE ledger_transactions ALTER COLUMN tenant_id SET NOT NULL;
+SELECT isolate_tenant_rows('ledger_transactions');
+
+-- Entries are append-only, the backfill is the one update they get
+ALTER TABLE ledger_entries DISABLE TRIGGER ledger_entries_append_only;
+SELECT add_inherited_tenant('ledger_entries', 'ledger_transactions', 'transaction_id');
+ALTER TABLE ledger_entries ENABLE TRIGGER ledger_entries_append_only;
+
+-- +goose StatementBegin
+-- New users get an account in their shop; their initial balance is posted against the
+-- opening account, the entry materializes it again
+CREATE OR REPLACE FUNCTION ledger_open_user_account() RETURNS trigger AS $$
+DECLARE
+    account INT;
+    tx BIGINT;
+BEGIN
+    INSERT INTO ledger_accounts (user_id, tenant_id) VALUES (NEW.id, NEW.tenant_id) RETURNING id INTO account;
+    IF NEW.balance <> 0 THEN
+        UPDATE users SET balance = 0 WHERE id = NEW.id;
+        INSERT INTO ledger_transactions (kind, reference, tenant_id) VALUES ('opening', 'user:' || NEW.id, NEW.tenant_id) RETURNING id INTO tx;
+        INSERT INTO ledger_entries (transaction_id, account_id, amount)
+        VALUES (tx, account, NEW.balance), (tx, ledger_account('opening'), -NEW.balance);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION ledger_open_user_account() RETURNS trigger AS $$
+DECLARE
+    account INT;
+    tx BIGINT;
+BEGIN
+    INSERT INTO ledger_accounts (user_id) VALUES (NEW.id) RETURNING id INTO account;
+    IF NEW.balance <> 0 THEN
+        UPDATE users SET balance = 0 WHERE id = NEW.id;
+        INSERT INTO ledger_transactions (kind, reference) VALUES ('opening', 'user:' || NEW.id) RETURNING id INTO tx;
+        INSERT INTO ledger_entries (transaction_id, account_id, amount)
+        VALUES (tx, account, NEW.balance), (tx, ledger_account('opening'), -NEW.balance);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    tbl TEXT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['balance_adjustments', 'inventories', 'favorites', 'telegram_links',
+        'telegram_link_tokens', 'payouts', 'deposits', 'steam_accounts', 'order_events', 'item_tags',
+        'item_price_snapshots', 'price_tiers', 'skinport_price_mappings', 'price_changes', 'promo_codes',
+        'promo_code_items', 'categories', 'tags', 'audit_log', 'ledger_accounts', 'ledger_transactions',
+        'ledger_entries', 'tenant_skinport_credentials']
+    LOOP
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', tbl);
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY', tbl);
+        EXECUTE format('ALTER TABLE %I DISABLE ROW LEVEL SECURITY', tbl);
+        EXECUTE format('DROP TRIGGER IF EXISTS inherit_tenant_id ON %I', tbl);
+        IF tbl <> 'tenant_skinport_credentials' THEN
+            EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS tenant_id', tbl);
+        END IF;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (name);
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_code_key UNIQUE (code);
+
+DROP TRIGGER IF EXISTS inherit_tenant_id ON orders;
+ALTER TABLE orders ALTER COLUMN tenant_id SET DEFAULT COALESCE(current_tenant_id(), 1);
+
+DROP FUNCTION IF EXISTS add_inherited_tenant(REGCLASS, REGCLASS, TEXT);
+DROP FUNCTION IF EXISTS isolate_tenant_rows(REGCLASS);
+DROP FUNCTION IF EXISTS inherit_tenant_id();
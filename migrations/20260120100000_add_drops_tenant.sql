@@ -0,0 +1,10 @@
+-- +goose Up
+-- Drops are in the shop of their item
+SELECT add_inherited_tenant('drops', 'items', 'item_id');
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON drops;
+ALTER TABLE drops NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE drops DISABLE ROW LEVEL SECURITY;
+DROP TRIGGER IF EXISTS inherit_tenant_id ON drops;
+ALTER TABLE drops DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Waitlist entries are in the shop of their user
+SELECT add_inherited_tenant('waitlist_entries', 'users', 'user_id');
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON waitlist_entries;
+ALTER TABLE waitlist_entries NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE waitlist_entries DISABLE ROW LEVEL SECURITY;
+DROP TRIGGER IF EXISTS inherit_tenant_id ON waitlist_entries;
+ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Stock movements are in the shop of their item. References stay unique across shops:
+-- the supplier feed is the deployment's.
+SELECT add_inherited_tenant('stock_movements', 'items', 'item_id');
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON stock_movements;
+ALTER TABLE stock_movements NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements DISABLE ROW LEVEL SECURITY;
+DROP TRIGGER IF EXISTS inherit_tenant_id ON stock_movements;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Deposits paid with another amount or currency than they were created with are not
+-- credited; needs_review keeps them out of the reconciliation job until someone looks
+ALTER TABLE deposits DROP CONSTRAINT IF EXISTS deposits_status_check;
+ALTER TABLE deposits ADD CONSTRAINT deposits_status_check
+    CHECK (status IN ('pending', 'succeeded', 'expired', 'failed', 'needs_review'));
+
+-- +goose Down
+UPDATE deposits SET status = 'failed' WHERE status = 'needs_review';
+ALTER TABLE deposits DROP CONSTRAINT IF EXISTS deposits_status_check;
+ALTER TABLE deposits ADD CONSTRAINT deposits_status_check
+    CHECK (status IN ('pending', 'succeeded', 'expired', 'failed'));
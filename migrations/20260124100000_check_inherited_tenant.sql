@@ -0,0 +1,86 @@
+-- +goose Up
+-- A row belonging to another row is always in the shop of that row. Foreign keys are
+-- checked without row level security, so without this a connection scoped to one shop
+-- could insert rows referencing another shop's users or items. A parent outside the
+-- connection's shop is reported as a missing one.
+
+-- +goose StatementBegin
+-- inherit_tenant_id copies tenant_id of a row from the rows it belongs to. Its arguments
+-- are pairs of a parent table and the column referencing it; every parent must be in the
+-- same shop as the row, and visible to the connection. Rows without a parent go to the
+-- connection's shop, or the default one.
+CREATE OR REPLACE FUNCTION inherit_tenant_id() RETURNS trigger AS $$
+DECLARE
+    parent_id TEXT;
+    parent_tenant INT;
+    i INT := 0;
+BEGIN
+    WHILE i < TG_NARGS LOOP
+        parent_id := to_jsonb(NEW) ->> TG_ARGV[i + 1];
+        IF parent_id IS NOT NULL THEN
+            parent_tenant := NULL;
+            EXECUTE format('SELECT tenant_id FROM %s WHERE id = $1', TG_ARGV[i])
+                INTO parent_tenant USING parent_id::bigint;
+            IF parent_tenant IS NULL OR parent_tenant <> COALESCE(NEW.tenant_id, parent_tenant) THEN
+                RAISE EXCEPTION 'insert or update on table "%" violates foreign key constraint "%"',
+                        TG_TABLE_NAME, format('%s_%s_fkey', TG_TABLE_NAME, TG_ARGV[i + 1])
+                    USING ERRCODE = 'foreign_key_violation',
+                        CONSTRAINT = format('%s_%s_fkey', TG_TABLE_NAME, TG_ARGV[i + 1]),
+                        DETAIL = format('Key (%s)=(%s) is not present in table "%s" of tenant %s.',
+                            TG_ARGV[i + 1], parent_id, TG_ARGV[i], COALESCE(NEW.tenant_id::text, 'none'));
+            END IF;
+            NEW.tenant_id := parent_tenant;
+        END IF;
+        i := i + 2;
+    END LOOP;
+    NEW.tenant_id := COALESCE(NEW.tenant_id, current_tenant_id(), 1);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Rows referencing both a user and an item are checked against both
+DROP TRIGGER IF EXISTS inherit_tenant_id ON orders;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON orders
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id', 'items', 'item_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON inventories;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON inventories
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id', 'items', 'item_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON waitlist_entries;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON waitlist_entries
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id', 'items', 'item_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON promo_code_items;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON promo_code_items
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('promo_codes', 'promo_code_id', 'items', 'item_id');
+
+-- +goose Down
+DROP TRIGGER IF EXISTS inherit_tenant_id ON orders;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON orders
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON inventories;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON inventories
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON waitlist_entries;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON waitlist_entries
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('users', 'user_id');
+DROP TRIGGER IF EXISTS inherit_tenant_id ON promo_code_items;
+CREATE TRIGGER inherit_tenant_id BEFORE INSERT ON promo_code_items
+    FOR EACH ROW EXECUTE FUNCTION inherit_tenant_id('promo_codes', 'promo_code_id');
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION inherit_tenant_id() RETURNS trigger AS $$
+DECLARE
+    parent_id TEXT;
+BEGIN
+    IF NEW.tenant_id IS NULL THEN
+        parent_id := to_jsonb(NEW) ->> TG_ARGV[1];
+        IF parent_id IS NOT NULL THEN
+            EXECUTE format('SELECT tenant_id FROM %s WHERE id = $1', TG_ARGV[0])
+                INTO NEW.tenant_id USING parent_id::bigint;
+        END IF;
+        NEW.tenant_id := COALESCE(NEW.tenant_id, 1);
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
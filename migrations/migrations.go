@@ -0,0 +1,8 @@
+// Package migrations embeds the goose SQL migrations so binaries can apply them
+// without the migrations directory on disk.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,233 @@
+// Package sdk is a Go client for the shop HTTP API.
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Item struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+	Stock int     `json:"stock"`
+	// Category is the category slug, empty when uncategorised
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type User struct {
+	ID        int     `json:"id"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	Balance   float64 `json:"balance"`
+}
+
+type SkinportItem struct {
+	MarketHashName      string   `json:"market_hash_name"`
+	Currency            string   `json:"currency"`
+	Slug                string   `json:"slug"`
+	MinPriceTradable    *float64 `json:"min_price_tradable"`
+	MinPriceNonTradable *float64 `json:"min_price_non_tradable"`
+	Quantity            int      `json:"quantity"`
+
+	SuggestedPrice         *float64  `json:"suggested_price,omitempty"`
+	ItemPage               string    `json:"item_page,omitempty"`
+	MarketPage             string    `json:"market_page,omitempty"`
+	MaxPriceTradable       *float64  `json:"max_price_tradable,omitempty"`
+	MaxPriceNonTradable    *float64  `json:"max_price_non_tradable,omitempty"`
+	MeanPriceTradable      *float64  `json:"mean_price_tradable,omitempty"`
+	MeanPriceNonTradable   *float64  `json:"mean_price_non_tradable,omitempty"`
+	MedianPriceTradable    *float64  `json:"median_price_tradable,omitempty"`
+	MedianPriceNonTradable *float64  `json:"median_price_non_tradable,omitempty"`
+	CreatedAt              time.Time `json:"created_at,omitzero"`
+	UpdatedAt              time.Time `json:"updated_at,omitzero"`
+	Spread                 *float64  `json:"spread,omitempty"`
+	SpreadPercent          *float64  `json:"spread_percent,omitempty"`
+}
+
+type BuyRequest struct {
+	UserID    int    `json:"user_id"`
+	ItemID    int    `json:"item_id"`
+	Count     int    `json:"count"`
+	PromoCode string `json:"promo_code,omitempty"`
+}
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	// token is sent as a bearer token, required by /v1/admin endpoints
+	token string
+}
+
+// NewClient creates a client for the API served at baseURL (e.g. http://localhost:8080)
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithToken authenticates requests with a bearer token (the server's ADMIN_TOKEN for admin calls)
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithHTTPClient replaces the underlying http.Client
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// ListItems returns the whole catalogue, following pagination cursors
+func (c *Client) ListItems(ctx context.Context) ([]Item, error) {
+	var items []Item
+	query := url.Values{}
+	for {
+		var page []Item
+		header, err := c.request(ctx, http.MethodGet, "/v1/items", query, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+
+		next := header.Get("X-Next-Cursor")
+		if next == "" {
+			return items, nil
+		}
+		query.Set("cursor", next)
+	}
+}
+
+func (c *Client) GetUser(ctx context.Context, userID int) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/users/%d", userID), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) Buy(ctx context.Context, req BuyRequest) error {
+	return c.do(ctx, http.MethodPost, "/v1/buy", nil, req, nil)
+}
+
+func (c *Client) GetSkinportItems(ctx context.Context, appID, currency string) ([]SkinportItem, error) {
+	var items []SkinportItem
+	if err := c.do(ctx, http.MethodGet, "/v1/skinport/items", skinportQuery(appID, currency), nil, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// RefreshSkinportCache forces the server to refetch Skinport items and returns the new item
+// count (admin, requires WithToken)
+func (c *Client) RefreshSkinportCache(ctx context.Context, appID, currency string) (int, error) {
+	var resp struct {
+		Items int `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/skinport/cache/refresh", skinportQuery(appID, currency), nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Items, nil
+}
+
+// InvalidateSkinportCache drops the server's cached Skinport items for appID/currency
+// without refetching them (admin, requires WithToken)
+func (c *Client) InvalidateSkinportCache(ctx context.Context, appID, currency string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/admin/skinport/cache", skinportQuery(appID, currency), nil, nil)
+}
+
+func skinportQuery(appID, currency string) url.Values {
+	q := url.Values{}
+	if appID != "" {
+		q.Set("app_id", appID)
+	}
+	if currency != "" {
+		q.Set("currency", currency)
+	}
+	return q
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	_, err := c.request(ctx, method, path, query, body, out)
+	return err
+}
+
+// request performs the call and returns the response headers
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body, out any) (http.Header, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, decodeAPIError(resp)
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.Header, nil
+}
+
+func decodeAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var payload struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &payload); err == nil && payload.Error != "" {
+		message = payload.Error
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
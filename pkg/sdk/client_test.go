@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Buy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/buy", r.URL.Path)
+
+		var req BuyRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, BuyRequest{UserID: 1, ItemID: 2, Count: 3}, req)
+
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer ts.Close()
+
+	err := NewClient(ts.URL).Buy(context.Background(), BuyRequest{UserID: 1, ItemID: 2, Count: 3})
+	assert.NoError(t, err)
+}
+
+func TestClient_APIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"user not found"}`))
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).GetUser(context.Background(), 42)
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "user not found", apiErr.Message)
+	}
+}
+
+func TestClient_PlainTextError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "insufficient funds", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	err := NewClient(ts.URL).Buy(context.Background(), BuyRequest{UserID: 1, ItemID: 1, Count: 1})
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "insufficient funds", apiErr.Message)
+	}
+}
+
+func TestClient_ListItemsFollowsCursor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set("X-Next-Cursor", "page2")
+			w.Write([]byte(`[{"id":1,"name":"a"}]`))
+		case "page2":
+			w.Write([]byte(`[{"id":2,"name":"b"}]`))
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+	defer ts.Close()
+
+	items, err := NewClient(ts.URL).ListItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, items)
+}